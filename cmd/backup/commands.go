@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// Exit codes for subcommands.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+
+	// exitRefused is returned when a safety interlock prevents a destructive command
+	exitRefused = 3
+
+	// A failed backup run exits with the code of the phase it failed in
+	exitDumpFailed   = 4
+	exitUploadFailed = 5
+	exitVerifyFailed = 6
+	exitLockFailed   = 7
+)
+
+// failureExitCode returns the exit code of a backup run that failed with err.
+// Failures before the dump, such as an unreachable database, exit with 1.
+func failureExitCode(err error) int {
+	switch backup.FailedPhase(err) {
+	case storage.PhaseDumping:
+		return exitDumpFailed
+	case storage.PhaseUploading:
+		return exitUploadFailed
+	case storage.PhaseVerifying:
+		return exitVerifyFailed
+	case backup.PhaseLocking:
+		return exitLockFailed
+	default:
+		return exitError
+	}
+}
+
+// command is a subcommand entry point. It returns the process exit code.
+type command func(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int
+
+// commands maps subcommand names to their entry points.
+var commands = map[string]command{
+	"doctor":      runDoctor,
+	"list":        runList,
+	"pre-migrate": runPreMigrate,
+	"prune":       runPrune,
+	"reconcile":   runReconcile,
+	"rollback":    runRollback,
+	"selftest":    runSelfTest,
+	"share":       runShare,
+	"top":         runTop,
+	"verify":      runVerify,
+}
+
+// standaloneCommand is a subcommand that runs without loading the
+// configuration, so it works before the environment is set up.
+type standaloneCommand func(args []string, logger *slog.Logger) int
+
+// standaloneCommands maps subcommand names to their entry points.
+var standaloneCommands = map[string]standaloneCommand{
+	"config": runConfig,
+	"init":   runInit,
+}
+
+// latestBackup finds the most recent backup: from the catalog when
+// BACKUP_CATALOG is enabled, saving a listing of the bucket, otherwise by
+// listing it. A catalog that cannot be read falls back to the listing.
+func latestBackup(ctx context.Context, store storage.Storage, cfg *config.Config, logger *slog.Logger) (*storage.ObjectInfo, time.Time, error) {
+	if cfg.BackupCatalog {
+		object, taken, err := storage.LatestCatalogued(ctx, store, cfg.BackupFilePrefix)
+		if err == nil {
+			return object, taken, nil
+		}
+		logger.Warn("Failed to find the latest backup in the catalog, listing the bucket", "error", err)
+	}
+	return storage.LatestBackup(ctx, store, cfg.BackupFilePrefix)
+}
+
+// splitCommand separates a leading subcommand name from its arguments.
+// Without a subcommand the service runs a regular backup (or serve mode).
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", args
+	}
+	return args[0], args[1:]
+}
+
+// runCommand dispatches a subcommand with a context cancelled on SIGINT/SIGTERM.
+func runCommand(name string, args []string, cfg *config.Config, logger *slog.Logger) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return cmd(ctx, args, cfg, logger.With("command", name))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+// runConfig prints the configuration schema or validates a configuration:
+//
+//	config schema               JSON Schema of CONFIG_FILE, with the environment under $defs
+//	config validate [--file f]  Check a config file, or the whole environment when no file is given
+func runConfig(args []string, logger *slog.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: config schema | config validate [--file path]")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "schema":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(config.JSONSchema()); err != nil {
+			logger.Error("Failed to write schema", "error", err)
+			return exitError
+		}
+		return exitOK
+
+	case "validate":
+		fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+		file := fs.String("file", "", "Config file to check on its own (default: load the environment and CONFIG_FILE)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+
+		var err error
+		if *file != "" {
+			err = config.ValidateFile(*file)
+		} else {
+			_, err = config.Load()
+		}
+		if err != nil {
+			// One line per problem, so CI logs point at each location
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		logger.Info("Configuration is valid")
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown config command %q\n", args[0])
+	return exitUsage
+}
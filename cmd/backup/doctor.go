@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// doctorTimeout bounds the readiness checks of the doctor command.
+const doctorTimeout = 2 * time.Minute
+
+// runDoctor checks the database, client binaries and storage without taking a
+// backup, and prints a readiness report. The configuration has already been
+// loaded and validated by the time it runs.
+func runDoctor(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		logger.Error("doctor takes no arguments")
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	report := backup.Doctor(ctx, cfg, storage.NewStorage, logger)
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = printDoctorReport(os.Stdout, report)
+	}
+	if err != nil {
+		logger.Error("Failed to write report", "error", err)
+		return exitError
+	}
+	if !report.Ready {
+		logger.Error("Not ready to back up; fix the failed checks")
+		return exitError
+	}
+	logger.Info("Ready to back up")
+	return exitOK
+}
+
+// printDoctorReport writes the checks as an aligned table followed by a verdict.
+func printDoctorReport(w io.Writer, report *backup.DoctorReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL\tERROR")
+	for _, c := range report.Checks {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, strings.ToUpper(c.Status), orDash(c.Detail), orDash(c.Error))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	verdict := "READY"
+	if !report.Ready {
+		verdict = "NOT READY"
+	}
+	_, err := fmt.Fprintln(w, "\n"+verdict)
+	return err
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// initCheckTimeout bounds the preflight checks of the init command.
+const initCheckTimeout = 2 * time.Minute
+
+// defaultDatabaseURL references the DATABASE_URL of a Railway service named Postgres.
+const defaultDatabaseURL = "${{Postgres.DATABASE_URL}}"
+
+// initQuestion is a setting asked for by the init command.
+type initQuestion struct {
+	env      string // Variable the answer is stored in
+	flag     string // Flag that answers it up front
+	prompt   string
+	fallback string // Suggested answer, used when the prompt is left empty
+	optional bool
+}
+
+// providerQuestions lists the settings each storage provider needs.
+var providerQuestions = map[string][]initQuestion{
+	"s3": {
+		{env: "S3_BUCKET", flag: "bucket", prompt: "S3 bucket"},
+		{env: "S3_REGION", flag: "region", prompt: "S3 region", fallback: "us-east-1"},
+		{env: "S3_ENDPOINT", flag: "endpoint", prompt: "S3 endpoint (empty for AWS)", optional: true},
+	},
+	"r2": {
+		{env: "S3_BUCKET", flag: "bucket", prompt: "R2 bucket"},
+		{env: "R2_ACCOUNT_ID", flag: "account-id", prompt: "Cloudflare account ID"},
+	},
+	"spaces": {
+		{env: "S3_BUCKET", flag: "bucket", prompt: "Spaces bucket"},
+		{env: "S3_REGION", flag: "region", prompt: "Spaces region", fallback: "nyc3"},
+	},
+	"wasabi": {
+		{env: "S3_BUCKET", flag: "bucket", prompt: "Wasabi bucket"},
+		{env: "S3_REGION", flag: "region", prompt: "Wasabi region", fallback: "us-east-1"},
+	},
+	"gcs": {
+		{env: "GCS_BUCKET", flag: "bucket", prompt: "GCS bucket"},
+		{env: "GOOGLE_PROJECT_ID", flag: "project-id", prompt: "Google Cloud project ID"},
+	},
+	"filesystem": {
+		{env: "FILESYSTEM_PATH", flag: "path", prompt: "Backup directory (a mounted Railway volume)", fallback: "/backups"},
+	},
+	"rclone": {
+		{env: "RCLONE_REMOTE", flag: "remote", prompt: "rclone remote (remote:path)"},
+	},
+}
+
+// providerSecrets lists the credentials each storage provider reads. They are
+// never asked for: the checks take them from the environment, and the output
+// leaves them to be filled in on Railway.
+var providerSecrets = map[string][]string{
+	"s3":     {"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"},
+	"r2":     {"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"},
+	"spaces": {"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"},
+	"wasabi": {"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"},
+	"gcs":    {"GOOGLE_SERVICE_ACCOUNT_JSON"},
+}
+
+// commonQuestions are asked for every provider.
+var commonQuestions = []initQuestion{
+	{env: "DATABASE_URL", flag: "database-url", prompt: "Database URL or Railway reference", fallback: defaultDatabaseURL},
+	{env: "RETENTION_DAYS", flag: "retention-days", prompt: "Days to keep backups (0 keeps them all)", fallback: "7"},
+}
+
+// runInit asks for the settings of a first deployment, checks them, and prints
+// the Railway variables and railway.json to deploy. Answers given as flags are
+// not asked for; with --no-input nothing is.
+func runInit(args []string, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	provider := fs.String("provider", "", "Storage provider: s3, r2, spaces, wasabi, gcs, filesystem or rclone")
+	schedule := fs.String("schedule", "", "Cron schedule of the backup service (default \"0 3 * * *\")")
+	noInput := fs.Bool("no-input", false, "Never prompt; fail when a required setting is not given as a flag")
+	skipChecks := fs.Bool("skip-checks", false, "Print the configuration without checking the database and storage")
+	showSecrets := fs.Bool("show-secrets", false, "Print credentials taken from the environment instead of leaving them blank")
+	format := fs.String("format", "env", "Output format: env or json")
+	answers := map[string]*string{
+		"bucket":         fs.String("bucket", "", "Bucket to store backups in"),
+		"region":         fs.String("region", "", "Bucket region (s3, spaces, wasabi)"),
+		"endpoint":       fs.String("endpoint", "", "S3-compatible endpoint (s3)"),
+		"account-id":     fs.String("account-id", "", "Cloudflare account ID (r2)"),
+		"project-id":     fs.String("project-id", "", "Google Cloud project ID (gcs)"),
+		"path":           fs.String("path", "", "Backup directory (filesystem)"),
+		"remote":         fs.String("remote", "", "rclone remote as remote:path (rclone)"),
+		"database-url":   fs.String("database-url", "", "Database URL or Railway reference (default \"${{Postgres.DATABASE_URL}}\")"),
+		"retention-days": fs.String("retention-days", "", "Days to keep backups, 0 to keep them all (default 7)"),
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "env" && *format != "json" {
+		logger.Error("--format must be env or json")
+		return exitUsage
+	}
+
+	w := &initWizard{in: bufio.NewReader(os.Stdin), out: os.Stderr, noInput: *noInput}
+
+	// Provider first, as it decides the remaining questions
+	if *provider == "" {
+		*provider = w.ask(initQuestion{env: "STORAGE_PROVIDER", flag: "provider", prompt: "Storage provider (s3, r2, spaces, wasabi, gcs, filesystem, rclone)", fallback: "s3"}, "")
+	}
+	questions, ok := providerQuestions[*provider]
+	if !ok {
+		logger.Error("Unknown storage provider", "provider", *provider)
+		return exitUsage
+	}
+
+	vars := map[string]string{"STORAGE_PROVIDER": *provider}
+	for _, q := range slices.Concat(questions, commonQuestions) {
+		if value := w.ask(q, *answers[q.flag]); value != "" {
+			vars[q.env] = value
+		}
+	}
+	if *schedule == "" {
+		*schedule = w.ask(initQuestion{flag: "schedule", prompt: "Cron schedule (UTC)", fallback: "0 3 * * *"}, "")
+	}
+	if w.err != nil {
+		logger.Error("Setup incomplete", "error", w.err)
+		return exitUsage
+	}
+	if err := checkSchedule(*schedule); err != nil {
+		logger.Error("Invalid schedule", "schedule", *schedule, "error", err)
+		return exitUsage
+	}
+	if days, err := strconv.Atoi(vars["RETENTION_DAYS"]); err != nil || days < 0 {
+		logger.Error("RETENTION_DAYS must be a non-negative number of days", "value", vars["RETENTION_DAYS"])
+		return exitUsage
+	}
+	for _, name := range providerSecrets[*provider] {
+		vars[name] = os.Getenv(name)
+	}
+
+	if !*skipChecks {
+		if err := preflight(vars, logger); err != nil {
+			logger.Error("Preflight checks failed; fix the settings or rerun with --skip-checks", "error", err)
+			return exitError
+		}
+	}
+
+	// Credentials are only echoed on request, as the output often ends up in a terminal log
+	if !*showSecrets {
+		for _, name := range providerSecrets[*provider] {
+			vars[name] = ""
+		}
+	}
+	if err := printDeployment(os.Stdout, *format, vars, providerSecrets[*provider], *schedule); err != nil {
+		logger.Error("Failed to write configuration", "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// initWizard prompts for answers on stderr, keeping stdout for the configuration.
+type initWizard struct {
+	in      *bufio.Reader
+	out     io.Writer
+	noInput bool
+	err     error // First required setting left unanswered
+}
+
+// ask returns the answer given as a flag, or prompts for one. An empty answer
+// selects the suggested one.
+func (w *initWizard) ask(q initQuestion, given string) string {
+	if given != "" || w.err != nil {
+		return given
+	}
+	if !w.noInput {
+		if q.fallback != "" {
+			_, _ = fmt.Fprintf(w.out, "%s [%s]: ", q.prompt, q.fallback)
+		} else {
+			_, _ = fmt.Fprintf(w.out, "%s: ", q.prompt)
+		}
+		line, err := w.in.ReadString('\n')
+		if answer := strings.TrimSpace(line); answer != "" {
+			return answer
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			w.err = err
+			return ""
+		}
+	}
+	if q.fallback == "" && !q.optional {
+		w.err = fmt.Errorf("--%s is required", q.flag)
+	}
+	return q.fallback
+}
+
+// checkSchedule accepts the five-field cron expressions Railway schedules services with.
+func checkSchedule(schedule string) error {
+	if n := len(strings.Fields(schedule)); n != 5 {
+		return fmt.Errorf("expected 5 fields (minute hour day month weekday), got %d", n)
+	}
+	return nil
+}
+
+// preflight loads the answers exactly as the service would and checks that
+// the database is reachable and the storage writable with them.
+func preflight(vars map[string]string, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, initCheckTimeout)
+	defer cancel()
+
+	for name, value := range vars {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	logger.Info("Configuration is valid")
+
+	// References are resolved by Railway at deploy time
+	if strings.Contains(cfg.DatabaseURL, "${{") {
+		logger.Warn("Skipping database check for a Railway reference; run init with the resolved URL to check it",
+			"database_url", cfg.DatabaseURL)
+	} else {
+		version, err := backup.GetServerVersion(ctx, cfg.DatabaseURL)
+		if err != nil {
+			return fmt.Errorf("database check failed: %w", err)
+		}
+		if _, err := backup.FindBestPGDump(version); err != nil {
+			return fmt.Errorf("no pg_dump can back up PostgreSQL %s: %w", version.Full, err)
+		}
+		logger.Info("Database is reachable", "version", version.Full)
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("storage check failed: %w", err)
+	}
+	objects, err := store.List(ctx, cfg.BackupFilePrefix)
+	if err != nil {
+		return fmt.Errorf("storage check failed: %w", err)
+	}
+	var backups int
+	for _, obj := range objects {
+		if !storage.IsReservedKey(obj.Key) && !utils.IsSidecar(obj.Key) {
+			backups++
+		}
+	}
+	if err := backup.CheckStorageWrite(ctx, store); err != nil {
+		return fmt.Errorf("storage check failed: %w", err)
+	}
+	logger.Info("Storage is writable", "provider", cfg.StorageProvider, "existing_backups", backups)
+	return nil
+}
+
+// railwayConfig is the railway.json of the backup service.
+func railwayConfig(schedule string) map[string]any {
+	return map[string]any{
+		"$schema": "https://railway.app/railway.schema.json",
+		"build":   map[string]any{"builder": "DOCKERFILE", "dockerfilePath": "Dockerfile"},
+		"deploy": map[string]any{
+			"numReplicas":             1,
+			"cronSchedule":            schedule,
+			"restartPolicyType":       "ON_FAILURE",
+			"restartPolicyMaxRetries": 3,
+		},
+	}
+}
+
+// printDeployment writes the variables and railway.json, either as a .env
+// block for Railway's raw variable editor followed by railway.json, or as a
+// single JSON document.
+func printDeployment(w io.Writer, format string, vars map[string]string, secrets []string, schedule string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"variables": vars, "railway": railwayConfig(schedule)})
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	_, _ = fmt.Fprintln(w, "# Railway variables: paste into the service's Variables > Raw Editor")
+	for _, name := range names {
+		if vars[name] == "" && slices.Contains(secrets, name) {
+			_, _ = fmt.Fprintf(w, "# %s is a secret: fill it in on Railway\n%s=\n", name, name)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s=%s\n", name, vars[name])
+	}
+
+	data, err := json.MarshalIndent(railwayConfig(schedule), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "# railway.json")
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		wantErr  bool
+	}{
+		{schedule: "0 3 * * *"},
+		{schedule: "*/15  *  * * 1-5"},
+		{schedule: "", wantErr: true},
+		{schedule: "0 3 * *", wantErr: true},
+		{schedule: "0 0 3 * * *", wantErr: true},
+		{schedule: "@daily", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			if err := checkSchedule(tt.schedule); (err != nil) != tt.wantErr {
+				t.Errorf("checkSchedule(%q) error = %v, wantErr %v", tt.schedule, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitWizard_Ask(t *testing.T) {
+	bucket := initQuestion{env: "S3_BUCKET", flag: "bucket", prompt: "S3 bucket"}
+	region := initQuestion{env: "S3_REGION", flag: "region", prompt: "S3 region", fallback: "us-east-1"}
+	endpoint := initQuestion{env: "S3_ENDPOINT", flag: "endpoint", prompt: "S3 endpoint", optional: true}
+
+	tests := []struct {
+		name       string
+		q          initQuestion
+		given      string
+		input      string
+		noInput    bool
+		want       string
+		wantPrompt string
+		wantErr    string
+	}{
+		{name: "flag given", q: bucket, given: "flagged", input: "typed\n", want: "flagged"},
+		{name: "answer typed", q: bucket, input: "  typed \n", want: "typed", wantPrompt: "S3 bucket: "},
+		{name: "last line without newline", q: bucket, input: "typed", want: "typed", wantPrompt: "S3 bucket: "},
+		{name: "empty answer takes the fallback", q: region, input: "\n", want: "us-east-1", wantPrompt: "S3 region [us-east-1]: "},
+		{name: "empty optional answer", q: endpoint, input: "\n", want: "", wantPrompt: "S3 endpoint: "},
+		{name: "required answer missing", q: bucket, input: "\n", want: "", wantPrompt: "S3 bucket: ", wantErr: "--bucket is required"},
+		{name: "input closed", q: bucket, input: "", want: "", wantPrompt: "S3 bucket: ", wantErr: "--bucket is required"},
+		{name: "no input takes the fallback", q: region, input: "typed\n", noInput: true, want: "us-east-1"},
+		{name: "no input with a required question", q: bucket, noInput: true, want: "", wantErr: "--bucket is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &initWizard{in: bufio.NewReader(strings.NewReader(tt.input)), out: &out, noInput: tt.noInput}
+			if got := w.ask(tt.q, tt.given); got != tt.want {
+				t.Errorf("ask() = %q, want %q", got, tt.want)
+			}
+			if out.String() != tt.wantPrompt {
+				t.Errorf("prompt = %q, want %q", out.String(), tt.wantPrompt)
+			}
+			var gotErr string
+			if w.err != nil {
+				gotErr = w.err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("err = %q, want %q", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestInitWizard_Script answers the questions of a provider from scripted
+// input, as runInit asks them, and checks the variables and railway.json
+// printed for them.
+func TestInitWizard_Script(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		given    map[string]string
+		input    string
+		wantVars map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "s3 with defaults",
+			provider: "s3",
+			input:    "my-bucket\n\n\n\n\n",
+			wantVars: map[string]string{
+				"STORAGE_PROVIDER": "s3",
+				"S3_BUCKET":        "my-bucket",
+				"S3_REGION":        "us-east-1",
+				"DATABASE_URL":     defaultDatabaseURL,
+				"RETENTION_DAYS":   "7",
+			},
+		},
+		{
+			name:     "r2 with flags and answers",
+			provider: "r2",
+			given:    map[string]string{"bucket": "flagged"},
+			input:    "abc123\npostgres://db\n30\n",
+			wantVars: map[string]string{
+				"STORAGE_PROVIDER": "r2",
+				"S3_BUCKET":        "flagged",
+				"R2_ACCOUNT_ID":    "abc123",
+				"DATABASE_URL":     "postgres://db",
+				"RETENTION_DAYS":   "30",
+			},
+		},
+		{
+			name:     "gcs without a project",
+			provider: "gcs",
+			input:    "my-bucket\n\n",
+			wantErr:  "--project-id is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &initWizard{in: bufio.NewReader(strings.NewReader(tt.input)), out: &bytes.Buffer{}}
+			vars := map[string]string{"STORAGE_PROVIDER": tt.provider}
+			for _, q := range slices.Concat(providerQuestions[tt.provider], commonQuestions) {
+				if value := w.ask(q, tt.given[q.flag]); value != "" {
+					vars[q.env] = value
+				}
+			}
+			if tt.wantErr != "" {
+				if w.err == nil || w.err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", w.err, tt.wantErr)
+				}
+				return
+			}
+			if w.err != nil {
+				t.Fatalf("err = %v", w.err)
+			}
+
+			var out bytes.Buffer
+			if err := printDeployment(&out, "json", vars, nil, "0 3 * * *"); err != nil {
+				t.Fatalf("printDeployment() error = %v", err)
+			}
+			var got struct {
+				Variables map[string]string `json:"variables"`
+				Railway   struct {
+					Deploy struct {
+						CronSchedule string `json:"cronSchedule"`
+					} `json:"deploy"`
+				} `json:"railway"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+			}
+			if len(got.Variables) != len(tt.wantVars) {
+				t.Errorf("variables = %v, want %v", got.Variables, tt.wantVars)
+			}
+			for name, want := range tt.wantVars {
+				if got.Variables[name] != want {
+					t.Errorf("%s = %q, want %q", name, got.Variables[name], want)
+				}
+			}
+			if got.Railway.Deploy.CronSchedule != "0 3 * * *" {
+				t.Errorf("cronSchedule = %q, want %q", got.Railway.Deploy.CronSchedule, "0 3 * * *")
+			}
+		})
+	}
+}
+
+func TestPrintDeployment(t *testing.T) {
+	vars := map[string]string{
+		"STORAGE_PROVIDER":      "s3",
+		"S3_BUCKET":             "my-bucket",
+		"AWS_ACCESS_KEY_ID":     "",
+		"AWS_SECRET_ACCESS_KEY": "shown",
+	}
+	secrets := []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+
+	tests := []struct {
+		name   string
+		format string
+		want   []string // Substrings of the output, in order
+	}{
+		{
+			name:   "env",
+			format: "env",
+			want: []string{
+				"# Railway variables: paste into the service's Variables > Raw Editor\n",
+				"# AWS_ACCESS_KEY_ID is a secret: fill it in on Railway\nAWS_ACCESS_KEY_ID=\n",
+				"AWS_SECRET_ACCESS_KEY=shown\n",
+				"S3_BUCKET=my-bucket\n",
+				"STORAGE_PROVIDER=s3\n",
+				"\n# railway.json\n{",
+				`"dockerfilePath": "Dockerfile"`,
+				`"cronSchedule": "*/30 * * * *"`,
+			},
+		},
+		{
+			name:   "json",
+			format: "json",
+			want: []string{
+				`"railway": {`,
+				`"cronSchedule": "*/30 * * * *"`,
+				`"variables": {`,
+				`"AWS_ACCESS_KEY_ID": ""`,
+				`"S3_BUCKET": "my-bucket"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printDeployment(&out, tt.format, vars, secrets, "*/30 * * * *"); err != nil {
+				t.Fatalf("printDeployment() error = %v", err)
+			}
+			rest := out.String()
+			for _, want := range tt.want {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("output lacks %q after the earlier lines:\n%s", want, out.String())
+				}
+				rest = rest[i+len(want):]
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// listedBackup is one backup as printed by the list command.
+type listedBackup struct {
+	Key        string            `json:"key"`
+	SizeBytes  int64             `json:"size_bytes"`
+	Time       time.Time         `json:"time"`
+	AgeSeconds int64             `json:"age_seconds"`
+	PGVersion  string            `json:"pg_version,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Foreign    string            `json:"foreign_format,omitempty"` // Archive format of a backup written by another tool
+}
+
+// runList prints the backups stored for the configured prefix, newest first,
+// as a table or as JSON. Dumps written by other tools are listed with their
+// archive format.
+func runList(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the backups as a JSON array")
+	label := fs.String("label", "", "Only list backups with this label")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		logger.Error("list takes no arguments")
+		return exitUsage
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	objects, err := listBackups(ctx, store, cfg, logger)
+	if err != nil {
+		logger.Error("Failed to list backups", "error", err)
+		return exitError
+	}
+
+	now := time.Now()
+	backups := make([]listedBackup, 0, len(objects))
+	for _, obj := range objects {
+		if *label != "" && obj.Metadata[backup.MetadataKeyLabel] != *label {
+			continue
+		}
+		taken := storage.BackupTime(obj)
+		backups = append(backups, listedBackup{
+			Key:        obj.Key,
+			SizeBytes:  obj.Size,
+			Time:       taken.UTC(),
+			AgeSeconds: int64(now.Sub(taken).Seconds()),
+			PGVersion:  utils.ParseBackupVersion(path.Base(obj.Key)),
+			Metadata:   obj.Metadata,
+			Foreign:    backup.ForeignFormat(obj),
+		})
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(backups)
+	} else {
+		err = printBackupTable(os.Stdout, backups)
+	}
+	if err != nil {
+		logger.Error("Failed to write backups", "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// listBackups returns the backups for the configured prefix, newest first:
+// from the catalog when BACKUP_CATALOG is enabled, otherwise by listing the
+// bucket. Failed runs recorded in the catalog are skipped.
+func listBackups(ctx context.Context, store storage.Storage, cfg *config.Config, logger *slog.Logger) ([]storage.ObjectInfo, error) {
+	if cfg.BackupCatalog {
+		catalog, err := storage.ReadCatalog(ctx, store)
+		if err == nil {
+			var objects []storage.ObjectInfo
+			for i := len(catalog.Backups) - 1; i >= 0; i-- {
+				e := catalog.Backups[i]
+				if e.Status != storage.CatalogStatusFailed && strings.HasPrefix(path.Base(e.Key), cfg.BackupFilePrefix) {
+					objects = append(objects, e.Object())
+				}
+			}
+			return objects, nil
+		}
+		logger.Warn("Failed to read the catalog, listing the bucket", "error", err)
+	}
+	return storage.ListBackups(ctx, store, cfg.BackupFilePrefix)
+}
+
+// printBackupTable writes backups as an aligned table.
+func printBackupTable(w io.Writer, backups []listedBackup) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KEY\tSIZE\tAGE\tPG\tMETADATA")
+	for _, b := range backups {
+		metadata := formatMetadata(b.Metadata)
+		if b.Foreign != "" {
+			metadata = "foreign " + b.Foreign + " dump"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Key, utils.FormatBytes(b.SizeBytes),
+			formatAge(time.Duration(b.AgeSeconds)*time.Second), orDash(b.PGVersion), metadata)
+	}
+	return tw.Flush()
+}
+
+// formatAge formats how long ago a backup was taken, e.g. "3d4h" or "25m".
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(age.Hours()), int(age.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(age.Hours())/24, int(age.Hours())%24)
+	}
+}
+
+// formatMetadata formats metadata as sorted key=value pairs, or "-".
+func formatMetadata(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(metadata))
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		pairs = append(pairs, k+"="+metadata[k])
+	}
+	return strings.Join(pairs, ",")
+}
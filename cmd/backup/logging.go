@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// newLogger builds the logger from LOG_LEVEL, LOG_FORMAT, LOG_ATTRS and
+// LOG_RETRY_EVERY.
+// It is set up before the configuration is loaded, so invalid values fall
+// back to the defaults here and are reported when the configuration is
+// validated.
+func newLogger(w io.Writer) *slog.Logger {
+	level, _ := config.ParseLogLevel(os.Getenv("LOG_LEVEL"))
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if os.Getenv("LOG_FORMAT") == config.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	if every, err := strconv.Atoi(os.Getenv("LOG_RETRY_EVERY")); err == nil && every > 1 {
+		handler = utils.NewSamplingHandler(handler, every)
+	}
+
+	// Connection URLs in errors and stderr are logged with their passwords redacted
+	handler = utils.NewRedactingHandler(handler)
+
+	if attrs, err := config.ParseLogAttrs(os.Getenv("LOG_ATTRS")); err == nil && len(attrs) > 0 {
+		handler = handler.WithAttrs(attrs)
+	}
+	return slog.New(handler)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -11,18 +14,41 @@ import (
 	"time"
 
 	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/catalog"
 	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/fleet"
 	"github.com/imedwei/railway-postgres-backup/internal/health"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/ratelimit"
+	"github.com/imedwei/railway-postgres-backup/internal/schedule"
 	"github.com/imedwei/railway-postgres-backup/internal/server"
 	"github.com/imedwei/railway-postgres-backup/internal/storage"
 	"github.com/imedwei/railway-postgres-backup/internal/utils"
+	"github.com/imedwei/railway-postgres-backup/internal/version"
 )
 
 func main() {
+	// Subcommands and dry runs print machine-readable output on stdout, so their logs go to stderr
+	command, args := splitCommand(os.Args[1:])
+	var flags runFlags
+	if command == "" {
+		var err error
+		if flags, err = parseRunFlags(args); err != nil {
+			os.Exit(exitUsage)
+		}
+	}
+	if flags.version {
+		fmt.Println(version.Get())
+		os.Exit(exitOK)
+	}
+	envDryRun, _ := strconv.ParseBool(os.Getenv("DRY_RUN"))
+	logOutput := os.Stdout
+	if command != "" || flags.dryRun || envDryRun {
+		logOutput = os.Stderr
+	}
+
 	// Set up logger
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	logger := newLogger(logOutput)
 	slog.SetDefault(logger)
 
 	// Set up panic recovery
@@ -33,8 +59,14 @@ func main() {
 		}
 	}()
 
+	if cmd, ok := standaloneCommands[command]; ok {
+		os.Exit(cmd(args, logger.With("command", command)))
+	}
+
 	// Log startup
-	logger.Info("Railway PostgreSQL Backup Service starting")
+	build := version.Get()
+	logger.Info("Railway PostgreSQL Backup Service starting",
+		"version", build.Version, "commit", build.Commit, "build_date", build.BuildDate)
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -42,16 +74,41 @@ func main() {
 		logger.Error("Failed to load configuration", "error", err)
 		os.Exit(1)
 	}
+	// Configured credentials are redacted wherever they appear, including in
+	// pg_dump and psql stderr
+	utils.RegisterSecrets(cfg.Secrets()...)
+	if flags.dryRun {
+		cfg.DryRun = true
+	}
+	if flags.label != "" {
+		if err := config.ValidateLabel(flags.label); err != nil {
+			logger.Error("Invalid --label", "error", err)
+			os.Exit(exitUsage)
+		}
+		cfg.BackupLabel = flags.label
+	}
+	if flags.keep {
+		cfg.BackupKeep = true
+	}
+	metrics.Info.WithLabelValues(build.Version, build.Commit, build.BuildDate, cfg.StorageProvider).Set(1)
 
 	// Log configuration (without sensitive data)
 	logger.Info("Configuration loaded",
+		"mode", cfg.Mode,
 		"storage_provider", cfg.StorageProvider,
 		"backup_prefix", cfg.BackupFilePrefix,
 		"respawn_protection_hours", cfg.RespawnProtectionHours,
 		"force_backup", cfg.ForceBackup,
 		"retention_days", cfg.RetentionDays,
+		"dry_run", cfg.DryRun,
+		"label", cfg.BackupLabel,
+		"keep", cfg.BackupKeep,
 	)
 
+	if command != "" {
+		os.Exit(runCommand(command, args, cfg, logger))
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -60,15 +117,17 @@ func main() {
 	var httpServer *server.Server
 	var wg sync.WaitGroup
 
-	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" {
-		port, err := strconv.Atoi(metricsPort)
-		if err != nil {
-			logger.Warn("Invalid METRICS_PORT, using default", "error", err)
-			port = 8080
-		}
-
+	// Long-running modes always expose metrics, on the default port unless METRICS_PORT is set
+	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" || cfg.Mode == config.ModeServe || cfg.Mode == config.ModeFleet || cfg.Mode == config.ModeDaemon || cfg.Mode == config.ModeVerify {
 		serverConfig := server.DefaultConfig()
-		serverConfig.Port = port
+		if metricsPort != "" {
+			port, err := strconv.Atoi(metricsPort)
+			if err != nil {
+				logger.Warn("Invalid METRICS_PORT, using default", "error", err)
+				port = 8080
+			}
+			serverConfig.Port = port
+		}
 		httpServer = server.New(serverConfig, logger)
 
 		// Register health checks
@@ -81,42 +140,44 @@ func main() {
 			}
 		})
 
-		httpServer.RegisterHealthCheck("database", func(ctx context.Context) health.Check {
-			// Use connection pool with health check retry config
-			healthCheckRetryConfig := utils.HealthCheckRetryConfig()
-			pool, err := utils.NewConnectionPoolWithRetry(ctx, cfg.DatabaseURL, healthCheckRetryConfig)
-			if err != nil {
-				return health.Check{
-					Status:    health.StatusUnhealthy,
-					Timestamp: time.Now(),
-					Details:   map[string]interface{}{"error": err.Error()},
-				}
-			}
-			defer func() {
-				if err := pool.Close(); err != nil {
-					logger.Warn("Failed to close connection pool", "error", err)
+		if cfg.DatabaseURL != "" {
+			httpServer.RegisterHealthCheck("database", func(ctx context.Context) health.Check {
+				// Use connection pool with health check retry config
+				healthCheckRetryConfig := utils.HealthCheckRetryConfig()
+				pool, err := utils.NewConnectionPoolWithRetry(ctx, cfg.DatabaseURL, healthCheckRetryConfig)
+				if err != nil {
+					return health.Check{
+						Status:    health.StatusUnhealthy,
+						Timestamp: time.Now(),
+						Details:   map[string]interface{}{"error": err.Error()},
+					}
 				}
-			}()
+				defer func() {
+					if err := pool.Close(); err != nil {
+						logger.Warn("Failed to close connection pool", "error", err)
+					}
+				}()
 
-			// Get database info using the pool
-			info, err := pool.GetDatabaseInfo()
-			if err != nil {
+				// Get database info using the pool
+				info, err := pool.GetDatabaseInfo()
+				if err != nil {
+					return health.Check{
+						Status:    health.StatusUnhealthy,
+						Timestamp: time.Now(),
+						Details:   map[string]interface{}{"error": err.Error()},
+					}
+				}
 				return health.Check{
-					Status:    health.StatusUnhealthy,
+					Status:    health.StatusHealthy,
 					Timestamp: time.Now(),
-					Details:   map[string]interface{}{"error": err.Error()},
+					Details: map[string]interface{}{
+						"database": info.Name,
+						"version":  info.Version,
+						"size":     info.Size,
+					},
 				}
-			}
-			return health.Check{
-				Status:    health.StatusHealthy,
-				Timestamp: time.Now(),
-				Details: map[string]interface{}{
-					"database": info.Name,
-					"version":  info.Version,
-					"size":     info.Size,
-				},
-			}
-		})
+			})
+		}
 
 		// Start server in background
 		wg.Add(1)
@@ -156,15 +217,133 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Signed download URLs are only served to callers presenting SHARE_TOKEN
+	if httpServer != nil && cfg.ShareToken != "" {
+		httpServer.Handle("/share", server.ShareHandler(storageProvider, cfg.ShareToken, cfg.GetShareExpiry(),
+			logger.With("component", "share")))
+	}
+
+	// Backups are paused and resumed only by callers presenting API_TOKEN
+	if httpServer != nil && cfg.APIToken != "" {
+		httpServer.Handle("/pause", server.PauseHandler(storageProvider, cfg.APIToken, logger.With("component", "pause")))
+	}
+
+	// The stored backups and run status are served to anyone who can reach the server unless API_TOKEN is set
+	if httpServer != nil {
+		httpServer.Handle("/backups", server.BackupsHandler(storageProvider, cfg.BackupFilePrefix, cfg.APIToken,
+			logger.With("component", "backups")))
+
+		// The next allowed backup is counted by the same rate limiter the orchestrator uses
+		limiter := ratelimit.NewTimeBasedLimiter(ratelimit.Config{
+			MinInterval: cfg.GetRespawnProtectionDuration(),
+			ForceBackup: cfg.ForceBackup,
+		})
+		httpServer.Handle("/status", server.StatusHandler(storageProvider, limiter, cfg.APIToken,
+			logger.With("component", "status")))
+	}
+
+	// Serve mode only watches the catalog; it never runs backups
+	if cfg.Mode == config.ModeServe {
+		scanner := catalog.NewScanner(storageProvider, cfg.StorageProvider, cfg.GetCatalogScanInterval(),
+			logger.With("component", "catalog"))
+		if httpServer != nil {
+			httpServer.RegisterHealthCheck("backups", scanner.Health)
+			httpServer.Handle("/monitor", server.MonitorHandler(storageProvider, scanner.Summaries, cfg.APIToken,
+				logger.With("component", "monitor")))
+		}
+		logger.Info("Serving catalog metrics", "scan_interval", cfg.GetCatalogScanInterval())
+		scanner.Run(ctx)
+
+		wg.Wait()
+		os.Exit(0)
+	}
+
+	// Verify mode checks backups written elsewhere; it never writes to storage
+	if cfg.Mode == config.ModeVerify {
+		var restorer backup.Backup
+		if cfg.VerifyRestore {
+			restorer = backup.NewPostgresBackupWithConfig(backup.PostgresConfig{
+				ConnectionURL: cfg.VerifyDatabaseURL,
+				TempDir:       cfg.TempDir,
+			})
+		}
+		verifier := backup.NewVerifier(cfg, storageProvider, restorer, logger.With("component", "verify"))
+		logger.Info("Running backup verifier", "interval", cfg.GetVerifyInterval(),
+			"max_backup_age_hours", cfg.MaxBackupAgeHours, "restore_drills", cfg.VerifyRestore)
+		verifier.Run(ctx)
+
+		wg.Wait()
+		os.Exit(0)
+	}
+
+	// Fleet mode backs up every Postgres service in the project until shutdown
+	if cfg.Mode == config.ModeFleet {
+		client := fleet.NewRailwayClient(cfg.RailwayAPIToken, cfg.RailwayProjectID, cfg.RailwayEnvironmentID)
+		coordinator := fleet.NewCoordinator(cfg, client, storageProvider, func(cfg *config.Config) (backup.Backup, error) {
+			return newBackupProvider(cfg, logger)
+		}, logger.With("component", "fleet"))
+		logger.Info("Running fleet coordinator", "project_id", cfg.RailwayProjectID, "discovery_interval", cfg.GetFleetDiscoveryInterval())
+		coordinator.Run(ctx)
+
+		wg.Wait()
+		os.Exit(0)
+	}
+
 	// Create backup provider
-	backupProvider := backup.NewPostgresBackup(cfg.DatabaseURL, cfg.PGDumpOptions)
+	backupProvider, err := newBackupProvider(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to create backup provider", "error", err)
+		os.Exit(1)
+	}
 
 	// Create and run orchestrator
 	orchestrator := backup.NewOrchestrator(cfg, storageProvider, backupProvider, logger)
 
+	// A dry run prints its plan instead of backing up
+	if cfg.DryRun {
+		result, err := orchestrator.Execute(ctx)
+		if err != nil {
+			logger.Error("Dry run failed", "error", err)
+			os.Exit(1)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result.Plan); err != nil {
+			logger.Error("Failed to write dry run plan", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Daemon mode backs up on BACKUP_SCHEDULE until shutdown
+	if cfg.Mode == config.ModeDaemon {
+		runner, err := newDaemonRunner(cfg, storageProvider, logger.With("component", "schedule"))
+		if err != nil {
+			logger.Error("Failed to create the backup scheduler", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Running backup daemon", "schedule", cfg.BackupSchedule, "interval", cfg.BackupInterval)
+		runner.Run(ctx, func(ctx context.Context) {
+			result, err := orchestrator.Execute(ctx)
+			switch {
+			case err != nil:
+				if ctx.Err() == nil {
+					logger.Error("Backup failed", "error", err)
+				}
+			case result.Skipped:
+				logger.Info("Backup skipped", "reason", result.Reason)
+			default:
+				logger.Info("Backup completed successfully", "key", result.Key)
+			}
+		})
+
+		wg.Wait()
+		os.Exit(0)
+	}
+
 	if err := orchestrator.Run(ctx); err != nil {
-		logger.Error("Backup failed", "error", err)
-		os.Exit(1)
+		logger.Error("Backup failed", "error", err, "phase", backup.FailedPhase(err))
+		os.Exit(failureExitCode(err))
 	}
 
 	logger.Info("Backup completed successfully")
@@ -174,3 +353,69 @@ func main() {
 
 	os.Exit(0)
 }
+
+// runFlags are the flags of a regular backup run.
+type runFlags struct {
+	dryRun  bool
+	version bool
+	label   string
+	keep    bool
+}
+
+// parseRunFlags parses the flags of a regular backup run.
+func parseRunFlags(args []string) (runFlags, error) {
+	var flags runFlags
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.BoolVar(&flags.dryRun, "dry-run", false, "Report what the run would do without dumping, uploading or deleting (DRY_RUN)")
+	fs.BoolVar(&flags.version, "version", false, "Print the version and build information and exit")
+	fs.StringVar(&flags.label, "label", "", "Label the backup, e.g. pre-launch (BACKUP_LABEL)")
+	fs.BoolVar(&flags.keep, "keep", false, "Pin the backup so retention never deletes it (BACKUP_KEEP)")
+	err := fs.Parse(args)
+	return flags, err
+}
+
+// newDaemonRunner creates the scheduler of daemon mode: on BACKUP_INTERVAL
+// after the last backup in storage, counted by the same rate limiter the
+// orchestrator uses, or else on the BACKUP_SCHEDULE cron expression.
+func newDaemonRunner(cfg *config.Config, store storage.Storage, logger *slog.Logger) (*schedule.Runner, error) {
+	if cfg.GetBackupInterval() > 0 {
+		limiter := ratelimit.NewTimeBasedLimiter(ratelimit.Config{MinInterval: cfg.GetRespawnProtectionDuration()})
+		last := func(ctx context.Context) (time.Time, error) {
+			return storage.LastBackupTime(ctx, store)
+		}
+		return schedule.NewIntervalRunner(limiter, last, cfg.BackupFilePrefix, logger), nil
+	}
+
+	sched, err := schedule.Parse(cfg.BackupSchedule)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.NewRunner(sched, cfg.BackupFilePrefix, logger), nil
+}
+
+// newBackupProvider creates the PostgreSQL backup provider from configuration.
+func newBackupProvider(cfg *config.Config, logger *slog.Logger) (*backup.PostgresBackup, error) {
+	compressor, err := backup.ConfigureCompression(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure compression: %w", err)
+	}
+
+	if err := backup.CheckTableFilters(cfg.PGDumpOptions, cfg.TableFilters); err != nil {
+		return nil, fmt.Errorf("invalid table filters: %w", err)
+	}
+
+	return backup.NewPostgresBackupWithConfig(backup.PostgresConfig{
+		ConnectionURL:  cfg.DatabaseURL,
+		PGDumpOptions:  cfg.PGDumpOptions,
+		StallTimeout:   cfg.GetDumpStallTimeout(),
+		LockDiagnosis:  cfg.DumpLockDiagnostics,
+		Compressor:     compressor,
+		TableFilters:   cfg.TableFilters,
+		DumpFilter:     cfg.DumpFilter,
+		TempDir:        cfg.TempDir,
+		ProcessLimits:  cfg.GetProcessLimits(),
+		CgroupAware:    cfg.ChildCgroupAware,
+		ExportFallback: cfg.DumpFallbackExport,
+		DataOnly:       cfg.SchemaDedup,
+	}), nil
+}
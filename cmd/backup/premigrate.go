@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// runPreMigrate forces a backup, re-downloads it to compare it with its
+// checksum sidecar, waits until it is visible in every destination and prints
+// its key, so CI can gate schema migrations on a good backup.
+func runPreMigrate(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("pre-migrate", flag.ContinueOnError)
+	output := fs.String("output", "", "Write the backup key to this file")
+	verifyTimeout := fs.Duration("verify-timeout", 2*time.Minute, "How long to wait for the backup to be verified")
+	label := fs.String("label", "", "Label the backup, e.g. pre-migration-2025-06 (BACKUP_LABEL)")
+	keep := fs.Bool("keep", false, "Pin the backup so retention never deletes it (BACKUP_KEEP)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *label != "" {
+		if err := config.ValidateLabel(*label); err != nil {
+			logger.Error("Invalid --label", "error", err)
+			return exitUsage
+		}
+		cfg.BackupLabel = *label
+	}
+	if *keep {
+		cfg.BackupKeep = true
+	}
+
+	// A migration gate must always produce a fresh backup, without delay
+	cfg.ForceBackup = true
+	cfg.BackupJitter = ""
+
+	// The gate needs proof the stored backup reads back intact, whatever VERIFY_AFTER_UPLOAD says
+	cfg.VerifyAfterUpload = true
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	backupProvider, err := newBackupProvider(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to create backup provider", "error", err)
+		return exitError
+	}
+
+	orchestrator := backup.NewOrchestrator(cfg, store, backupProvider, logger)
+	result, err := orchestrator.Execute(ctx)
+	if err != nil {
+		logger.Error("Pre-migration backup failed", "error", err)
+		return exitError
+	}
+	if result.Skipped {
+		logger.Error("Pre-migration backup was skipped", "reason", result.Reason)
+		return exitError
+	}
+
+	if err := orchestrator.VerifyUpload(ctx, result, *verifyTimeout); err != nil {
+		logger.Error("Pre-migration backup could not be verified", "error", err)
+		return exitError
+	}
+	if err := orchestrator.VerifyChecksum(ctx, result); err != nil {
+		logger.Error("Pre-migration backup could not be verified", "error", err)
+		return exitError
+	}
+
+	if err := emitBackupKey(result.Key, *output); err != nil {
+		logger.Error("Failed to write backup key", "error", err)
+		return exitError
+	}
+
+	logger.Info("Pre-migration backup verified", "key", result.Key, "size", result.Size)
+	return exitOK
+}
+
+// emitBackupKey prints the key on stdout and records it for CI consumers:
+// the --output file and, on GitHub Actions, the step outputs file.
+func emitBackupKey(key, output string) error {
+	fmt.Println(key)
+
+	var errs []error
+	if output != "" {
+		if err := os.WriteFile(output, []byte(key+"\n"), 0o644); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ghOutput := os.Getenv("GITHUB_OUTPUT"); ghOutput != "" {
+		f, err := os.OpenFile(ghOutput, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			if _, err := fmt.Fprintf(f, "backup_key=%s\n", key); err != nil {
+				errs = append(errs, err)
+			}
+			if err := f.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// runPrune applies retention without taking a backup and prints the deleted
+// keys. It lets a deployment that holds the delete credentials prune the
+// backups written by one that can only write.
+func runPrune(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", cfg.RetentionDryRun, "Print what retention would delete instead of deleting")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		logger.Error("prune takes no arguments")
+		return exitUsage
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	// Retention never dumps the database
+	orchestrator := backup.NewOrchestrator(cfg, store, nil, logger)
+	pruned, err := orchestrator.Prune(ctx, *dryRun)
+	for _, key := range pruned {
+		fmt.Println(key)
+	}
+	if err != nil {
+		logger.Error("Failed to prune backups", "error", err)
+		return exitError
+	}
+
+	logger.Info("Pruned backups", "deleted", len(pruned), "dry_run", *dryRun)
+	return exitOK
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// runReconcile compares the catalog against the bucket, repairs the entries
+// of backups added or deleted out-of-band, and prints every difference along
+// with the unknown objects under the backup prefix.
+func runReconcile(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Print the differences without repairing the catalog")
+	asJSON := fs.Bool("json", false, "Print the differences as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		logger.Error("reconcile takes no arguments")
+		return exitUsage
+	}
+	if !cfg.BackupCatalog {
+		logger.Error("reconcile requires BACKUP_CATALOG=true")
+		return exitUsage
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	result, err := storage.ReconcileCatalog(ctx, store, cfg.BackupFilePrefix, *dryRun)
+	if err != nil {
+		logger.Error("Failed to reconcile the catalog", "error", err)
+		return exitError
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	} else {
+		err = printReconciliation(os.Stdout, result)
+	}
+	if err != nil {
+		logger.Error("Failed to write differences", "error", err)
+		return exitError
+	}
+
+	logger.Info("Reconciled the catalog", "added", len(result.Added), "removed", len(result.Removed),
+		"changed", len(result.Changed), "unknown", len(result.Unknown), "dry_run", *dryRun)
+	return exitOK
+}
+
+// printReconciliation writes one aligned line per difference, e.g.
+// "added <key> <size>".
+func printReconciliation(w io.Writer, result *storage.Reconciliation) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, section := range []struct {
+		action  string
+		entries []storage.CatalogEntry
+	}{{"added", result.Added}, {"removed", result.Removed}, {"changed", result.Changed}} {
+		for _, e := range section.entries {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", section.action, e.Key, utils.FormatBytes(e.SizeBytes))
+		}
+	}
+	for _, obj := range result.Unknown {
+		_, _ = fmt.Fprintf(tw, "unknown\t%s\t%s\n", obj.Key, utils.FormatBytes(obj.Size))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// rollbackEvent is one line of the JSON progress stream written to stdout.
+type rollbackEvent struct {
+	Event      string    `json:"event"` // selected, start, progress, complete or error
+	Time       time.Time `json:"time"`
+	Key        string    `json:"key,omitempty"`
+	BackupTime time.Time `json:"backup_time,omitzero"`
+	Database   string    `json:"database,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	TotalBytes int64     `json:"total_bytes,omitempty"`
+	Percent    float64   `json:"percent,omitempty"`
+	Seconds    float64   `json:"duration_seconds,omitempty"`
+	DryRun     bool      `json:"dry_run,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Missing    []string  `json:"missing_extensions,omitempty"`
+}
+
+// eventWriter serialises rollback events as JSON lines.
+type eventWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *eventWriter) emit(e rollbackEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	e.Time = time.Now().UTC()
+	_ = w.enc.Encode(e)
+}
+
+// runRollback restores the backup stored under --key into the target database,
+// typically the key printed by pre-migrate, and streams progress as JSON lines.
+func runRollback(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	key := fs.String("key", "", "Storage key of the backup to restore (required unless --latest)")
+	latest := fs.Bool("latest", cfg.RestoreLatest, "Restore the most recent backup when --key is not given")
+	confirm := fs.String("confirm", "", "Name of the target database, required to confirm the restore")
+	targetURL := fs.String("target-url", cfg.DatabaseURL, "Database to restore into (defaults to DATABASE_URL)")
+	clean := fs.Bool("clean", true, "Drop existing objects before restoring them")
+	singleTx := fs.Bool("single-transaction", true, "Restore in a single transaction (defaults to false with --jobs above 1)")
+	jobs := fs.Int("jobs", cfg.RestoreJobs, "Parallel pg_restore workers for custom archives")
+	analyze := fs.Bool("analyze", cfg.RestoreAnalyze, "Run ANALYZE after the restore")
+	dryRun := fs.Bool("dry-run", false, "Check the interlocks and the backup without restoring")
+	forceRemote := fs.Bool("force-remote", cfg.RestoreForceRemote, "Read the backup from remote storage even when the local cache holds it")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	// A parallel restore cannot run in a single transaction, whether the
+	// workers come from RESTORE_JOBS or --jobs
+	if !given["single-transaction"] {
+		*singleTx = *jobs <= 1
+	}
+
+	events := &eventWriter{enc: json.NewEncoder(os.Stdout)}
+	fail := func(code int, err error) int {
+		logger.Error("Rollback failed", "error", err)
+		e := rollbackEvent{Event: "error", Key: *key, Error: err.Error()}
+		var missing *missingExtensionsError
+		if errors.As(err, &missing) {
+			e.Missing = missing.extensions
+		}
+		events.emit(e)
+		return code
+	}
+
+	if *key == "" && !*latest {
+		return fail(exitUsage, fmt.Errorf("--key or --latest is required"))
+	}
+
+	// Interlock: the operator must name the database that is about to be overwritten
+	database, err := backup.DatabaseName(*targetURL)
+	if err != nil {
+		return fail(exitRefused, fmt.Errorf("invalid target database: %w", err))
+	}
+	if *confirm != database {
+		return fail(exitRefused, fmt.Errorf("--confirm must match the target database %q", database))
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		return fail(exitError, fmt.Errorf("failed to create storage provider: %w", err))
+	}
+	if *forceRemote {
+		store = storage.Uncached(store)
+	}
+
+	if *key == "" {
+		latest, taken, err := latestBackup(ctx, store, cfg, logger)
+		if err != nil {
+			return fail(exitRefused, fmt.Errorf("failed to find the latest backup: %w", err))
+		}
+		*key = latest.Key
+		logger.Info("Selected latest backup", "key", *key, "backup_time", taken)
+		events.emit(rollbackEvent{Event: "selected", Key: *key, BackupTime: taken})
+	}
+
+	// Interlock: the backup must exist before anything touches the database. A
+	// catalog entry may be stale, so the size the restore spool is checked
+	// against always comes from the stored object.
+	object, err := findBackup(ctx, store, *key)
+	if err != nil {
+		return fail(exitRefused, err)
+	}
+
+	// Backups written by other tools carry no metadata; psql cannot clean before replaying plain SQL
+	if format := backup.ForeignFormat(*object); format != "" {
+		logger.Info("Restoring a backup written by another tool", "key", *key, "format", format)
+	}
+	if backup.StoredFormat(*object) == backup.FormatPlain && *clean && !given["clean"] {
+		logger.Warn("Plain SQL backups cannot be restored with --clean, restoring over the existing objects", "key", *key)
+		*clean = false
+	}
+
+	// The manifest describes the backup without downloading it
+	if manifest, err := backup.ReadManifest(ctx, store, *key); err == nil {
+		logger.Info("Backup manifest",
+			"database", manifest.Database.Name, "version", manifest.Database.Version,
+			"tables", len(manifest.Tables), "rows_estimate", manifest.EstimatedRows(), "sha256", manifest.SHA256)
+	}
+
+	// Interlock: every extension the backup uses must be installable on the target
+	restorer := backup.NewPostgresBackupWithConfig(backup.PostgresConfig{
+		ConnectionURL: *targetURL,
+		TempDir:       cfg.TempDir,
+		ProcessLimits: cfg.GetProcessLimits(),
+		CgroupAware:   cfg.ChildCgroupAware,
+	})
+	if err := checkExtensions(ctx, restorer, object, logger); err != nil {
+		return fail(exitRefused, err)
+	}
+
+	// Interlock: a data-only backup needs the schema stored apart from it
+	schema, err := backup.OpenSchema(ctx, store, object)
+	if err != nil {
+		return fail(exitRefused, err)
+	}
+	if schema != nil {
+		defer func() {
+			_ = schema.Close()
+		}()
+		logger.Info("Backup holds data only, restoring its stored schema first", "schema", object.Metadata[backup.MetadataKeySchema])
+	}
+
+	events.emit(rollbackEvent{Event: "start", Key: *key, Database: database, TotalBytes: object.Size, DryRun: *dryRun})
+	if *dryRun {
+		events.emit(rollbackEvent{Event: "complete", Key: *key, Database: database, DryRun: true})
+		return exitOK
+	}
+
+	start := time.Now()
+	n, err := restoreBackup(ctx, store, restorer, *key, object.Size, backup.RestoreOptions{
+		Clean:              *clean,
+		SingleTransaction:  *singleTx,
+		Jobs:               *jobs,
+		SizeBytes:          object.Size,
+		DisableTriggers:    cfg.RestoreDisableTriggers,
+		MaintenanceWorkMem: cfg.RestoreMaintenanceWorkMem,
+		Analyze:            *analyze,
+		Schema:             schema,
+	}, events)
+	if err != nil {
+		return fail(exitError, err)
+	}
+
+	events.emit(rollbackEvent{
+		Event:    "complete",
+		Key:      *key,
+		Database: database,
+		Bytes:    n,
+		Seconds:  time.Since(start).Seconds(),
+	})
+	logger.Info("Rollback completed", "key", *key, "database", database, "bytes", n)
+	return exitOK
+}
+
+// findBackup looks up the object stored under key, including its metadata.
+func findBackup(ctx context.Context, store storage.Storage, key string) (*storage.ObjectInfo, error) {
+	obj, err := store.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("backup %s not found", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	return obj, nil
+}
+
+// missingExtensionsError reports extensions the target server cannot provide.
+type missingExtensionsError struct {
+	extensions []string
+}
+
+func (e *missingExtensionsError) Error() string {
+	return fmt.Sprintf("target server is missing extensions required by the backup: %s", strings.Join(e.extensions, ", "))
+}
+
+// checkExtensions compares the extensions recorded in the backup metadata with
+// those available on the target server, so a restore fails before it starts.
+func checkExtensions(ctx context.Context, restorer *backup.PostgresBackup, object *storage.ObjectInfo, logger *slog.Logger) error {
+	required := backup.ParseExtensions(object.Metadata[backup.MetadataKeyExtensions])
+	if len(required) == 0 {
+		logger.Warn("Backup metadata does not list extensions, skipping extension preflight", "key", object.Key)
+		return nil
+	}
+
+	missing, err := restorer.MissingExtensions(ctx, required)
+	if err != nil {
+		return fmt.Errorf("extension preflight failed: %w", err)
+	}
+	if len(missing) > 0 {
+		return &missingExtensionsError{extensions: missing}
+	}
+
+	logger.Info("Extension preflight passed", "extensions", required)
+	return nil
+}
+
+// restoreBackup streams the backup through its codec into the target database
+// and returns the number of stored bytes read.
+func restoreBackup(ctx context.Context, store storage.Storage, restorer *backup.PostgresBackup, key string, total int64,
+	opts backup.RestoreOptions, events *eventWriter) (int64, error) {
+	// A mismatch surfaces as a read error at the end of the object
+	reader, verified, err := storage.OpenVerified(ctx, store, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if !verified {
+		slog.Warn("Backup has no checksum sidecar, restoring it unverified", "key", key)
+	}
+
+	progress := utils.NewProgressReader(reader, func(bytesRead int64, elapsed time.Duration) {
+		e := rollbackEvent{Event: "progress", Key: key, Bytes: bytesRead, TotalBytes: total, Seconds: elapsed.Seconds()}
+		if total > 0 {
+			e.Percent = float64(bytesRead) * 100 / float64(total)
+		}
+		events.emit(e)
+	})
+
+	codec, ok := compression.ForFilename(key)
+	if !ok {
+		return 0, fmt.Errorf("no codec available for %s", key)
+	}
+	dr, err := codec.NewReader(progress)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s backup: %w", codec.Name(), err)
+	}
+	defer func() {
+		_ = dr.Close()
+	}()
+
+	if err := restorer.Restore(ctx, dr, opts); err != nil {
+		return progress.BytesRead(), err
+	}
+
+	// Drain trailing bytes so the count reflects the whole object and the checksum is compared
+	if _, err := io.Copy(io.Discard, progress); err != nil {
+		return progress.BytesRead(), err
+	}
+	return progress.BytesRead(), nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// runSelfTest runs the pipeline on a synthetic archive against the configured
+// storage and prints the report as JSON. The database is never touched.
+func runSelfTest(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	if len(args) > 0 {
+		logger.Error("selftest takes no arguments")
+		return exitUsage
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	report, err := backup.SelfTest(ctx, cfg, store, logger)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(report); encErr != nil {
+		logger.Error("Failed to write report", "error", encErr)
+	}
+	if err != nil || !report.Passed {
+		logger.Error("Self-test failed", "key", report.Key, "error", err)
+		return exitError
+	}
+	logger.Info("Self-test passed", "key", report.Key)
+	return exitOK
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// runShare prints a time-limited download URL for a backup, so it can be
+// handed to a teammate without sharing bucket credentials.
+func runShare(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("share", flag.ContinueOnError)
+	key := fs.String("key", "", "Storage key of the backup to share (required unless --latest)")
+	latest := fs.Bool("latest", false, "Share the most recent backup when --key is not given")
+	expires := fs.Duration("expires", cfg.GetShareExpiry(), "How long the URL stays valid (at most 168h)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *key == "" && !*latest {
+		logger.Error("--key or --latest is required")
+		return exitUsage
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	if *key == "" {
+		object, _, err := latestBackup(ctx, store, cfg, logger)
+		if err != nil {
+			logger.Error("Failed to find the latest backup", "error", err)
+			return exitError
+		}
+		*key = object.Key
+	}
+
+	url, err := storage.ShareURL(ctx, store, *key, *expires)
+	if err != nil {
+		logger.Error("Failed to share backup", "key", *key, "error", err)
+		return exitError
+	}
+
+	// The URL is a credential, so it goes to stdout only
+	logger.Info("Shared backup", "key", *key, "expires_at", time.Now().Add(*expires).UTC())
+	fmt.Println(url)
+	return exitOK
+}
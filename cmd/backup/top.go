@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/server"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// clearScreen moves the cursor home and clears the terminal, so each refresh
+// redraws the screen in place.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// runTop polls the /monitor endpoint of a serve-mode instance and redraws the
+// run phase, throughput, recent runs and catalog summary until interrupted.
+func runTop(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	addr := fs.String("url", defaultMonitorURL(), "Base URL of the serve-mode instance")
+	interval := fs.Duration("interval", 2*time.Second, "How often the screen is refreshed")
+	prefix := fs.String("prefix", "", "Show the runs of one fleet service")
+	once := fs.Bool("once", false, "Print the status once, without clearing the screen")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		logger.Error("top takes no arguments")
+		return exitUsage
+	}
+	if *interval < time.Second {
+		logger.Error("--interval must be at least 1s")
+		return exitUsage
+	}
+
+	endpoint, err := monitorEndpoint(*addr, *prefix)
+	if err != nil {
+		logger.Error("Invalid --url", "error", err)
+		return exitUsage
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	if *once {
+		status, err := fetchMonitor(ctx, client, endpoint, cfg.APIToken)
+		if err != nil {
+			logger.Error("Failed to read the monitor endpoint", "url", endpoint, "error", err)
+			return exitError
+		}
+		if err := renderMonitor(os.Stdout, *addr, status, time.Now()); err != nil {
+			logger.Error("Failed to write status", "error", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		status, err := fetchMonitor(ctx, client, endpoint, cfg.APIToken)
+		if ctx.Err() != nil {
+			return exitOK
+		}
+		fmt.Print(clearScreen)
+		if err != nil {
+			fmt.Printf("%s unreachable: %v\nRetrying every %s (Ctrl-C to quit)\n", *addr, err, *interval)
+		} else {
+			_ = renderMonitor(os.Stdout, *addr, status, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return exitOK
+		case <-ticker.C:
+		}
+	}
+}
+
+// defaultMonitorURL is the local serve-mode instance, on METRICS_PORT when set.
+func defaultMonitorURL() string {
+	port := os.Getenv("METRICS_PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
+
+// monitorEndpoint returns the /monitor URL of the instance at base.
+func monitorEndpoint(base, prefix string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https URL", base)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/monitor"
+	if prefix != "" {
+		u.RawQuery = url.Values{"prefix": {prefix}}.Encode()
+	}
+	return u.String(), nil
+}
+
+// fetchMonitor reads the monitor endpoint, presenting token when set.
+func fetchMonitor(ctx context.Context, client *http.Client, endpoint, token string) (server.MonitorResponse, error) {
+	var status server.MonitorResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return status, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return status, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return status, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("invalid monitor response: %w", err)
+	}
+	return status, nil
+}
+
+// renderMonitor writes one screen of status.
+func renderMonitor(w io.Writer, addr string, status server.MonitorResponse, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "backup top - %s - %s (Ctrl-C to quit)\n\n", addr, now.UTC().Format("2006-01-02 15:04:05 UTC"))
+
+	if run := status.Run; run != nil {
+		phase := run.Phase
+		if run.Interrupted() {
+			phase += " for " + formatDuration(now.Sub(run.UpdatedAt))
+		}
+		_, _ = fmt.Fprintf(tw, "Run:\t%s\t%s, started %s\n", phase, run.Key, formatAgo(now.Sub(run.StartedAt)))
+		_, _ = fmt.Fprintf(tw, "Phases:\t%s\n", formatPhases(run, now))
+		if run.Error != "" {
+			_, _ = fmt.Fprintf(tw, "Error:\t%s\n", run.Error)
+		}
+	} else {
+		_, _ = fmt.Fprintln(tw, "Run:\tnone recorded")
+	}
+	if !status.LastBackupTime.IsZero() {
+		_, _ = fmt.Fprintf(tw, "Last backup:\t%s\t%s, %s\n", formatAgo(now.Sub(status.LastBackupTime)),
+			status.LastKey, utils.FormatBytes(status.LastSize))
+	}
+	if status.ConsecutiveFailures > 0 {
+		_, _ = fmt.Fprintf(tw, "Failures:\t%d consecutive\n", status.ConsecutiveFailures)
+	}
+	if p := status.Paused; p != nil {
+		_, _ = fmt.Fprintf(tw, "Paused:\t%s\t%s\n", formatAgo(now.Sub(p.PausedAt)), orDash(p.Reason))
+	}
+	_, _ = fmt.Fprintf(tw, "Throughput:\t%s\n", formatThroughput(status.RecentRuns))
+
+	_, _ = fmt.Fprintln(tw, "\nRECENT RUNS")
+	_, _ = fmt.Fprintln(tw, "AGE\tSTATUS\tSIZE\tDURATION\tRATE\tKEY")
+	for _, r := range status.RecentRuns {
+		detail := r.Key
+		if r.Error != "" {
+			detail = r.Error
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", formatAge(now.Sub(r.Time)), r.Status,
+			utils.FormatBytes(r.SizeBytes), formatDuration(time.Duration(r.DurationSeconds*float64(time.Second))),
+			formatRate(r.BytesPerSecond), detail)
+	}
+
+	_, _ = fmt.Fprintln(tw, "\nCATALOG")
+	_, _ = fmt.Fprintln(tw, "DESTINATION\tBACKUPS\tSIZE\tNEWEST\tOLDEST\tSCANNED")
+	for _, c := range status.Catalog {
+		newest, oldest := "-", "-"
+		if c.Backups > 0 {
+			newest, oldest = formatAge(now.Sub(c.Newest)), formatAge(now.Sub(c.Oldest))
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", c.Destination, c.Backups,
+			utils.FormatBytes(c.Bytes), newest, oldest, formatAgo(now.Sub(c.ScannedAt)))
+	}
+	return tw.Flush()
+}
+
+// formatPhases lists how long the run spent in each phase, the current phase
+// counting up to now.
+func formatPhases(run *storage.RunCheckpoint, now time.Time) string {
+	var phases []string
+	for i, p := range run.Phases {
+		if p.Phase == storage.PhaseComplete || p.Phase == storage.PhaseFailed {
+			continue
+		}
+		end := now
+		if i+1 < len(run.Phases) {
+			end = run.Phases[i+1].At
+		} else if !run.Interrupted() {
+			end = run.UpdatedAt
+		}
+		phases = append(phases, p.Phase+" "+formatDuration(end.Sub(p.At)))
+	}
+	if len(phases) == 0 {
+		return "-"
+	}
+	return strings.Join(phases, ", ")
+}
+
+// formatThroughput summarizes the upload rate of the latest successful run
+// and the average over the recent ones.
+func formatThroughput(runs []storage.HistoryRecord) string {
+	var latest, total float64
+	var n int
+	for _, r := range runs {
+		if r.Status != backup.HistorySuccess || r.BytesPerSecond <= 0 {
+			continue
+		}
+		if n == 0 {
+			latest = r.BytesPerSecond
+		}
+		total += r.BytesPerSecond
+		n++
+	}
+	if n == 0 {
+		return "-"
+	}
+	noun := "runs"
+	if n == 1 {
+		noun = "run"
+	}
+	return fmt.Sprintf("%s latest, %s average over %d %s", formatRate(latest), formatRate(total/float64(n)), n, noun)
+}
+
+// formatAgo formats how long ago something happened, e.g. "25m ago" or "now".
+func formatAgo(age time.Duration) string {
+	if age < time.Minute {
+		return "now"
+	}
+	return formatAge(age) + " ago"
+}
+
+// formatRate formats a transfer rate, or "-" when it was not recorded.
+func formatRate(bytesPerSecond float64) string {
+	if bytesPerSecond <= 0 {
+		return "-"
+	}
+	return utils.FormatBytes(int64(bytesPerSecond)) + "/s"
+}
+
+// formatDuration formats a phase or run duration to the second.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
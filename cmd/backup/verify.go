@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// runVerify downloads stored backups and checks each one, printing a
+// pass/fail report. The database is never touched.
+func runVerify(ctx context.Context, args []string, cfg *config.Config, logger *slog.Logger) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var keys []string
+	fs.Func("key", "Storage key of a backup to verify (repeatable)", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	last := fs.Int("last", 1, "Verify the N most recent backups when no --key is given")
+	all := fs.Bool("all", false, "Verify every stored backup")
+	requireChecksum := fs.Bool("require-checksum", false, "Fail backups that have no checksum sidecar")
+	asJSON := fs.Bool("json", false, "Print the report as a JSON array")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 || *last < 1 || (*all && len(keys) > 0) {
+		logger.Error("verify takes --key, --last N or --all")
+		return exitUsage
+	}
+
+	store, err := storage.NewStorage(ctx, cfg)
+	if err != nil {
+		logger.Error("Failed to create storage provider", "error", err)
+		return exitError
+	}
+
+	var objects []storage.ObjectInfo
+	if len(keys) > 0 {
+		for _, key := range keys {
+			object, err := findBackup(ctx, store, key)
+			if err != nil {
+				logger.Error("Failed to find backup", "key", key, "error", err)
+				return exitError
+			}
+			objects = append(objects, *object)
+		}
+	} else {
+		if objects, err = listBackups(ctx, store, cfg, logger); err != nil {
+			logger.Error("Failed to list backups", "error", err)
+			return exitError
+		}
+		if !*all && len(objects) > *last {
+			objects = objects[:*last]
+		}
+	}
+	if len(objects) == 0 {
+		logger.Error("No backups to verify")
+		return exitError
+	}
+
+	opts := backup.VerifyOptions{RequireChecksum: *requireChecksum}
+	if opts.PGRestore, err = backup.FindNewestPGRestore(); err != nil {
+		logger.Warn("pg_restore not found, archive tables of contents are not listed", "error", err)
+	}
+
+	checks := make([]*backup.BackupCheck, 0, len(objects))
+	failed := 0
+	for _, obj := range objects {
+		logger.Info("Verifying backup", "key", obj.Key, "size", obj.Size)
+		check := backup.VerifyBackup(ctx, store, obj, opts)
+		if check.Passed {
+			logger.Info("Backup verified", "key", check.Key, "format", check.Format, "checksum", check.Checksum)
+		} else {
+			failed++
+			logger.Error("Backup failed verification", "key", check.Key, "error", check.Error)
+		}
+		checks = append(checks, check)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(checks)
+	} else {
+		err = printCheckTable(os.Stdout, checks)
+	}
+	if err != nil {
+		logger.Error("Failed to write report", "error", err)
+		return exitError
+	}
+
+	if failed > 0 {
+		logger.Error("Verification failed", "failed", failed, "verified", len(checks))
+		return exitError
+	}
+	return exitOK
+}
+
+// printCheckTable writes verification results as an aligned table.
+func printCheckTable(w io.Writer, checks []*backup.BackupCheck) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KEY\tRESULT\tSIZE\tFORMAT\tCHECKSUM\tERROR")
+	for _, c := range checks {
+		result, errText := "pass", "-"
+		if !c.Passed {
+			result, errText = "FAIL", c.Error
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Key, result, utils.FormatBytes(c.SizeBytes),
+			orDash(c.Format), orDash(c.Checksum), errText)
+	}
+	return tw.Flush()
+}
+
+// orDash returns s, or "-" when it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
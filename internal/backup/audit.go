@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// Checksum states of a verified backup. Backups written before checksums were
+// stored have none.
+const (
+	ChecksumVerified = "verified"
+	ChecksumMissing  = "missing"
+	ChecksumMismatch = "mismatch"
+)
+
+// VerifyOptions configures VerifyBackup.
+type VerifyOptions struct {
+	PGRestore       string // pg_restore binary listing the archive's table of contents ("" skips the listing)
+	RequireChecksum bool   // Fail backups that have no checksum sidecar
+}
+
+// BackupCheck is the outcome of verifying one stored backup.
+type BackupCheck struct {
+	Key             string  `json:"key"`
+	Passed          bool    `json:"passed"`
+	SizeBytes       int64   `json:"size_bytes"`
+	Compression     string  `json:"compression,omitempty"`
+	Format          string  `json:"format,omitempty"`
+	Checksum        string  `json:"checksum,omitempty"`
+	TarMembers      int     `json:"tar_members,omitempty"`
+	TOCEntries      int     `json:"toc_entries,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// VerifyBackup downloads the stored backup obj in full and checks it: the
+// codec stream, every tar member, the table of contents as listed by
+// pg_restore, the size against the listing and the SHA-256 against the
+// checksum sidecar. It never contacts a database.
+func VerifyBackup(ctx context.Context, store storage.Storage, obj storage.ObjectInfo, opts VerifyOptions) *BackupCheck {
+	start := time.Now()
+	check := &BackupCheck{Key: obj.Key, SizeBytes: obj.Size}
+	err := check.run(ctx, store, obj, opts)
+	if errors.Is(err, storage.ErrChecksumMismatch) {
+		check.Checksum = ChecksumMismatch
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	check.Passed = err == nil
+	check.DurationSeconds = time.Since(start).Seconds()
+	return check
+}
+
+// run reads the backup through each check in a single pass.
+func (c *BackupCheck) run(ctx context.Context, store storage.Storage, obj storage.ObjectInfo, opts VerifyOptions) error {
+	codec, ok := compression.ForFilename(obj.Key)
+	if !ok {
+		return fmt.Errorf("no codec available for %s", obj.Key)
+	}
+	c.Compression = codec.Name()
+
+	reader, verified, err := storage.OpenVerified(ctx, store, obj.Key)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	c.Checksum = ChecksumVerified
+	if !verified {
+		c.Checksum = ChecksumMissing
+		if opts.RequireChecksum {
+			return fmt.Errorf("backup has no checksum sidecar")
+		}
+	}
+
+	counter := &countingReader{reader: reader}
+	dr, err := codec.NewReader(counter)
+	if err != nil {
+		return fmt.Errorf("invalid %s stream: %w", codec.Name(), err)
+	}
+	defer func() {
+		_ = dr.Close()
+	}()
+	br := bufio.NewReaderSize(dr, 4096)
+	c.Format = DetectArchiveFormat(br)
+	if c.Format == FormatPlain {
+		// pg_dump scripts and fallback exports open with a comment
+		if start, _ := br.Peek(len("--")); string(start) != "--" {
+			return fmt.Errorf("not a pg_dump archive or SQL script")
+		}
+	}
+
+	var stream io.Reader = br
+	var lister *tocLister
+	if opts.PGRestore != "" && c.Format != FormatPlain {
+		if lister, err = startTOCListing(ctx, opts.PGRestore); err != nil {
+			return err
+		}
+		stream = io.TeeReader(br, lister)
+	}
+
+	if c.Format == FormatTar {
+		sample, err := SampleArchive(ctx, stream, SampleOptions{Percent: 100})
+		if sample != nil {
+			c.TarMembers = sample.Members
+		}
+		if err != nil {
+			_, _ = lister.finish()
+			return err
+		}
+	} else if _, err := io.Copy(io.Discard, stream); err != nil {
+		_, _ = lister.finish()
+		return fmt.Errorf("invalid %s stream: %w", codec.Name(), err)
+	}
+
+	if lister != nil {
+		if c.TOCEntries, err = lister.finish(); err != nil {
+			return err
+		}
+	}
+
+	// Reach the end of the object so its size and checksum are compared
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return err
+	}
+	if counter.count != obj.Size {
+		return fmt.Errorf("size mismatch: read %d bytes, listed %d", counter.count, obj.Size)
+	}
+	return nil
+}
+
+// tocLister feeds an archive to pg_restore --list as it is read. pg_restore
+// stops reading once it has the table of contents; later writes are dropped.
+type tocLister struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	done   bool
+}
+
+// startTOCListing starts pg_restore --list reading an archive from stdin.
+func startTOCListing(ctx context.Context, pgRestore string) (*tocLister, error) {
+	l := &tocLister{cmd: command(ctx, pgRestore, "--list")}
+	l.cmd.Stdout = &l.stdout
+	l.cmd.Stderr = &l.stderr
+	stdin, err := l.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	l.stdin = stdin
+	if err := l.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", pgRestore, err)
+	}
+	return l, nil
+}
+
+// Write implements io.Writer.
+func (l *tocLister) Write(p []byte) (int, error) {
+	if !l.done {
+		if _, err := l.stdin.Write(p); err != nil {
+			l.done = true
+		}
+	}
+	return len(p), nil
+}
+
+// finish waits for pg_restore and returns the number of entries it listed.
+// It is safe to call on a nil lister.
+func (l *tocLister) finish() (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	_ = l.stdin.Close()
+	if err := l.cmd.Wait(); err != nil {
+		return 0, fmt.Errorf("pg_restore --list failed: %w, stderr: %s", err, strings.TrimSpace(stderrText(&l.stderr)))
+	}
+	return countTOCEntries(l.stdout.String()), nil
+}
+
+// countTOCEntries counts the entries of a pg_restore --list output, skipping
+// its comment lines.
+func countTOCEntries(listing string) int {
+	n := 0
+	for _, line := range strings.Split(listing, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, ";") {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	ctx := context.Background()
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := selfTestArchive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := compression.Get("gzip", compression.DefaultLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	w, err := codec.NewWriter(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write(archive)
+	_ = w.Close()
+	data := compressed.Bytes()
+
+	// store uploads data under key, with its checksum unless sum is nil
+	store := func(key string, data []byte, sum []byte) storage.ObjectInfo {
+		t.Helper()
+		if err := fs.Upload(ctx, key, bytes.NewReader(data), nil); err != nil {
+			t.Fatal(err)
+		}
+		if sum != nil {
+			if err := storage.WriteChecksum(ctx, fs, key, sum); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return storage.ObjectInfo{Key: key, Size: int64(len(data))}
+	}
+	good := sha256.Sum256(data)
+	wrong := sha256.Sum256([]byte("other"))
+	truncated := data[:len(data)-10]
+
+	tests := []struct {
+		name         string
+		obj          storage.ObjectInfo
+		opts         VerifyOptions
+		wantPassed   bool
+		wantChecksum string
+		wantErr      string
+	}{
+		{name: "valid", obj: store("good.tar.gz", data, good[:]), wantPassed: true, wantChecksum: ChecksumVerified},
+		{name: "without checksum", obj: store("old.tar.gz", data, nil), wantPassed: true, wantChecksum: ChecksumMissing},
+		{name: "checksum required", obj: store("old2.tar.gz", data, nil), opts: VerifyOptions{RequireChecksum: true},
+			wantChecksum: ChecksumMissing, wantErr: "no checksum"},
+		{name: "checksum mismatch", obj: store("bad.tar.gz", data, wrong[:]), wantChecksum: ChecksumMismatch, wantErr: "checksum"},
+		{name: "truncated", obj: store("short.tar.gz", truncated, nil), wantChecksum: ChecksumMissing, wantErr: "unexpected EOF"},
+		{name: "not a dump", obj: store("backup.sql", data, nil), wantChecksum: ChecksumMissing, wantErr: "not a pg_dump"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := VerifyBackup(ctx, fs, tt.obj, tt.opts)
+			if check.Passed != tt.wantPassed || check.Checksum != tt.wantChecksum || !strings.Contains(check.Error, tt.wantErr) {
+				t.Errorf("VerifyBackup() = %+v, want passed %v, checksum %q, error containing %q",
+					check, tt.wantPassed, tt.wantChecksum, tt.wantErr)
+			}
+			if tt.wantPassed && (check.Format != FormatTar || check.TarMembers != 3 || check.Compression != "gzip") {
+				t.Errorf("VerifyBackup() = %+v, want a gzip tar archive of 3 members", check)
+			}
+		})
+	}
+}
+
+func TestCountTOCEntries(t *testing.T) {
+	listing := `;
+; Archive created at 2025-01-15 02:00:00 UTC
+;     dbname: railway
+;
+3; 2615 2200 SCHEMA - public pg_database_owner
+215; 1259 16385 TABLE public users postgres
+3301; 0 16385 TABLE DATA public users postgres
+`
+	if got := countTOCEntries(listing); got != 3 {
+		t.Errorf("countTOCEntries() = %d, want 3", got)
+	}
+}
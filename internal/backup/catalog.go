@@ -0,0 +1,29 @@
+package backup
+
+import (
+	"context"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// updateCatalog records the run's backup in the catalog, marked failed when its
+// verification failed, and drops the backups deleted by retention. The catalog
+// is written even when the run was cancelled, and a failure to write it is only
+// logged.
+func (o *Orchestrator) updateCatalog(ctx context.Context, entry *storage.CatalogEntry, result *Result, runErr error) {
+	entry.Status = storage.CatalogStatusComplete
+	if runErr != nil {
+		entry.Status = storage.CatalogStatusFailed
+		entry.Error = runErr.Error()
+	}
+
+	err := storage.UpdateCatalog(context.WithoutCancel(ctx), o.storage, func(c *storage.Catalog) {
+		if result != nil {
+			c.Remove(result.Pruned...)
+		}
+		c.Put(*entry)
+	})
+	if err != nil {
+		o.logger.Warn("Failed to update backup catalog", "error", err)
+	}
+}
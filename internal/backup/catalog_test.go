@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestOrchestrator_UpdatesCatalog(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	oldKey := "2020/01/test-pg16-2020-01-01T00-00-00-000Z.tar.gz"
+	store := &mockStorage{
+		objects:    map[string][]byte{},
+		listResult: []storage.ObjectInfo{{Key: oldKey, Size: 4, LastModified: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		BackupCatalog:    true,
+		RetentionDays:    7,
+	}
+
+	result, err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	catalog, err := storage.ReadCatalog(context.Background(), store)
+	if err != nil {
+		t.Fatalf("ReadCatalog() error = %v", err)
+	}
+	if len(catalog.Backups) != 1 {
+		t.Fatalf("catalog = %+v, want only the new backup after retention", catalog.Backups)
+	}
+	entry := catalog.Backups[0]
+	if entry.Key != result.Key || entry.SizeBytes != result.Size || entry.SHA256 != result.SHA256 || entry.Status != storage.CatalogStatusComplete {
+		t.Errorf("catalog entry = %+v, want the new backup complete", entry)
+	}
+	if entry.Metadata["compression"] == "" {
+		t.Errorf("catalog entry metadata = %v, want the upload metadata", entry.Metadata)
+	}
+}
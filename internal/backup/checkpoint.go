@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// runTracker persists the phase transitions of a run to the state object, so
+// a container killed midway leaves a record of where it died.
+type runTracker struct {
+	store  storage.Storage
+	state  storage.State
+	logger *slog.Logger
+}
+
+// startRun records the start of a run uploading to key. An earlier run that
+// was killed is reported, and its partial uploads are removed when the run
+// lock guarantees it is no longer running.
+func (o *Orchestrator) startRun(ctx context.Context, key string) *runTracker {
+	run := &runTracker{store: o.storage, logger: o.logger}
+	if state, err := storage.ReadState(ctx, o.storage); err == nil {
+		run.state = *state
+	}
+
+	if previous := run.state.Run; previous != nil && previous.Interrupted() {
+		metrics.InterruptedRuns.Inc()
+		o.logger.Warn("Previous run was interrupted",
+			"key", previous.Key, "phase", previous.Phase, "holder", previous.Holder,
+			"started_at", previous.StartedAt, "updated_at", previous.UpdatedAt)
+		o.cleanupInterruptedRun(ctx, previous)
+	}
+
+	now := time.Now().UTC()
+	run.state.Run = &storage.RunCheckpoint{Key: key, Holder: leaseHolder(), StartedAt: now}
+	return run
+}
+
+// cleanupInterruptedRun removes the partial uploads of an interrupted run.
+// Without the run lock the run might still be alive on another instance, so
+// its leftovers are only reported.
+func (o *Orchestrator) cleanupInterruptedRun(ctx context.Context, previous *storage.RunCheckpoint) {
+	if previous.Phase != storage.PhaseDumping && previous.Phase != storage.PhaseUploading {
+		return
+	}
+	if !o.config.RunLock {
+		o.logger.Warn("Partial uploads of the interrupted run may remain; enable RUN_LOCK to clean them up",
+			"key", previous.Key)
+		return
+	}
+
+	removed, err := storage.CleanupLeftovers(ctx, o.storage, previous.Key)
+	if err != nil {
+		o.logger.Warn("Failed to clean up the interrupted run", "key", previous.Key, "error", err)
+	}
+	if removed > 0 {
+		o.logger.Info("Cleaned up partial uploads of the interrupted run", "key", previous.Key, "removed", removed)
+	}
+}
+
+// enter records the transition to phase.
+func (r *runTracker) enter(ctx context.Context, phase string) {
+	r.state.Run.Enter(phase)
+	r.write(ctx)
+}
+
+// recordBackup records the uploaded backup, so the next run need not list the bucket.
+func (r *runTracker) recordBackup(ctx context.Context, timestamp time.Time, key string, size int64) {
+	r.state.LastBackupTime = timestamp
+	r.state.LastKey = key
+	r.state.LastSize = size
+	r.state.ConsecutiveFailures = 0
+	r.state.Run.SizeBytes = size
+	if err := storage.WriteState(ctx, r.store, r.state); err != nil {
+		r.logger.Warn("Failed to update backup state, the next run will list storage instead", "error", err)
+	}
+}
+
+// finish records the outcome of the run, even when it was cancelled.
+func (r *runTracker) finish(ctx context.Context, err error) {
+	if err != nil {
+		r.state.Run.Error = err.Error()
+		r.enter(context.WithoutCancel(ctx), storage.PhaseFailed)
+		return
+	}
+	r.enter(ctx, storage.PhaseComplete)
+}
+
+func (r *runTracker) write(ctx context.Context) {
+	if err := storage.WriteState(ctx, r.store, r.state); err != nil {
+		r.logger.Warn("Failed to record run phase", "phase", r.state.Run.Phase, "error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestOrchestrator_RunCheckpoints(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	previous := time.Now().Add(-48 * time.Hour).UTC()
+
+	tests := []struct {
+		name       string
+		dumpErr    error
+		interrupt  *storage.RunCheckpoint
+		wantPhases []string
+	}{
+		{
+			name:       "completed run",
+			wantPhases: []string{storage.PhaseDumping, storage.PhaseUploading, storage.PhaseRetaining, storage.PhaseComplete},
+		},
+		{
+			name:       "failed dump",
+			dumpErr:    errors.New("pg_dump: connection refused"),
+			wantPhases: []string{storage.PhaseDumping, storage.PhaseFailed},
+		},
+		{
+			name:       "after an interrupted run",
+			interrupt:  &storage.RunCheckpoint{Key: "old.tar.gz", Phase: storage.PhaseUploading},
+			wantPhases: []string{storage.PhaseDumping, storage.PhaseUploading, storage.PhaseRetaining, storage.PhaseComplete},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				Compression:      "none",
+				RetentionDays:    7,
+				ForceBackup:      true,
+			}
+			store := &mockStorage{state: &storage.State{LastBackupTime: previous, LastKey: "old", Run: tt.interrupt}}
+			backup := &mockBackup{dumpData: "backup data", dumpErr: tt.dumpErr}
+
+			result, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+			if (err != nil) != (tt.dumpErr != nil) {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			run := store.state.Run
+			var phases []string
+			for _, transition := range run.Phases {
+				phases = append(phases, transition.Phase)
+			}
+			if !slices.Equal(phases, tt.wantPhases) {
+				t.Errorf("phases = %v, want %v", phases, tt.wantPhases)
+			}
+			if run.Interrupted() {
+				t.Errorf("run phase = %s, want an outcome recorded", run.Phase)
+			}
+
+			if tt.dumpErr != nil {
+				if run.Error == "" || !store.state.LastBackupTime.Equal(previous) {
+					t.Errorf("state = %+v, want the error recorded and the last backup kept", store.state)
+				}
+				return
+			}
+			if run.Key != result.Key || store.state.LastKey != result.Key {
+				t.Errorf("state = %+v, want run and last key %s", store.state, result.Key)
+			}
+			if run.SizeBytes != result.Size || run.Retention == nil || run.Retention.RetentionDays != 7 {
+				t.Errorf("run size = %d, retention = %+v, want %d bytes and the 7 day retention pass", run.SizeBytes, run.Retention, result.Size)
+			}
+		})
+	}
+}
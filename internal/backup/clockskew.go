@@ -0,0 +1,31 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// checkClockSkew compares the local clock with the storage provider's, from
+// the last modified time of a small object the run wrote between start and
+// end. Respawn protection and retention compare local times with stored ones,
+// so a skewed clock makes them allow backups early or delete them late.
+func (o *Orchestrator) checkClockSkew(ctx context.Context, key string, start, end time.Time) {
+	skew, err := storage.MeasureClockSkew(ctx, o.storage, key, start, end)
+	if err != nil {
+		o.logger.Warn("Failed to compare the local clock with the storage provider's", "key", key, "error", err)
+		return
+	}
+	metrics.ClockSkew.WithLabelValues(o.config.BackupFilePrefix).Set(skew.Seconds())
+
+	threshold := time.Duration(o.config.ClockSkewWarnSeconds) * time.Second
+	if skew > threshold || skew < -threshold {
+		o.logger.Warn("CLOCK SKEW: the local clock differs from the storage provider's; respawn protection and retention may misjudge backup ages",
+			"skew", skew.Round(time.Second).String(),
+			"local_clock_ahead", skew > 0,
+			"threshold", threshold.String(),
+		)
+	}
+}
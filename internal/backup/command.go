@@ -0,0 +1,55 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sync"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// commandLogKey is the context key of a run's commandLog.
+type commandLogKey struct{}
+
+// commandLog records the command lines a run executes, credentials redacted,
+// for the run's report.
+type commandLog struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+// withCommandLog returns a context recording the commands started under it.
+func withCommandLog(ctx context.Context) (context.Context, *commandLog) {
+	log := &commandLog{}
+	return context.WithValue(ctx, commandLogKey{}, log), log
+}
+
+// list returns the recorded command lines, oldest first.
+func (l *commandLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.commands)
+}
+
+// command returns the exec.Cmd running pg_dump, psql or pg_restore, like
+// exec.CommandContext. The command line is logged at debug level and recorded
+// in the run's command log, with the credentials of connection URLs redacted.
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	argv := utils.FormatArgv(append([]string{name}, args...))
+	slog.Default().DebugContext(ctx, "Running command", "argv", argv)
+	if log, ok := ctx.Value(commandLogKey{}).(*commandLog); ok {
+		log.mu.Lock()
+		log.commands = append(log.commands, argv)
+		log.mu.Unlock()
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// stderrText returns a command's captured stderr with credentials redacted,
+// for errors and logs.
+func stderrText(stderr *bytes.Buffer) string {
+	return utils.RedactCredentials(stderr.String())
+}
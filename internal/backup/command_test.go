@@ -0,0 +1,30 @@
+package backup
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCommand_RecordsRedactedArgv(t *testing.T) {
+	ctx, log := withCommandLog(context.Background())
+
+	cmd := command(ctx, "pg_dump", "--format=custom", "postgres://app:s3cret@db/app")
+	if !slices.Equal(cmd.Args, []string{"pg_dump", "--format=custom", "postgres://app:s3cret@db/app"}) {
+		t.Errorf("command() args = %v, want the credentials passed through", cmd.Args)
+	}
+	command(ctx, "psql", "--command", "SELECT 1", "postgres://app:s3cret@db/app")
+
+	want := []string{
+		"pg_dump --format=custom postgres://app:xxxxx@db/app",
+		`psql --command "SELECT 1" postgres://app:xxxxx@db/app`,
+	}
+	if got := log.list(); !slices.Equal(got, want) {
+		t.Errorf("recorded commands = %q, want %q", got, want)
+	}
+
+	// Commands outside a run are not recorded
+	if cmd := command(context.Background(), "pg_restore", "--list"); cmd == nil {
+		t.Error("command() without a command log returned nil")
+	}
+}
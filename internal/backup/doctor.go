@@ -0,0 +1,300 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Outcomes of a doctor check.
+const (
+	DoctorOK      = "ok"
+	DoctorWarn    = "warn"
+	DoctorFail    = "fail"
+	DoctorSkipped = "skipped"
+)
+
+// doctorPrefix names the marker objects written by the doctor, so a leftover
+// one is recognisable.
+const doctorPrefix = "doctor"
+
+// doctorRetryConfig keeps the database check short: the doctor reports an
+// unreachable database rather than waiting for it to boot.
+var doctorRetryConfig = RetryConfig{
+	MaxRetries:    1,
+	InitialDelay:  2 * time.Second,
+	MaxDelay:      2 * time.Second,
+	BackoffFactor: 1,
+}
+
+// DoctorCheck is one readiness check and how it went.
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DoctorReport is the outcome of the readiness checks. The service is ready
+// when no check failed; warnings point at settings that work but degrade it.
+type DoctorReport struct {
+	Ready  bool          `json:"ready"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// add records the outcome of a check.
+func (r *DoctorReport) add(logger *slog.Logger, name, status, detail string, err error) {
+	c := DoctorCheck{Name: name, Status: status, Detail: detail}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	switch status {
+	case DoctorFail:
+		r.Ready = false
+		logger.Error("Doctor check failed", "check", name, "detail", detail, "error", err)
+	case DoctorWarn:
+		logger.Warn("Doctor check warned", "check", name, "detail", detail, "error", err)
+	default:
+		logger.Info("Doctor check done", "check", name, "status", status, "detail", detail)
+	}
+	r.Checks = append(r.Checks, c)
+}
+
+// Doctor checks everything a backup needs without taking one: the database is
+// reachable and its version known, pg_dump and psql binaries able to handle
+// that version are installed, and the storage accepts writes with metadata,
+// listings and deletes. Every check runs even when an earlier one fails, so a
+// first deployment sees all of its problems at once. Only small marker objects
+// are written, and they are deleted again.
+func Doctor(ctx context.Context, cfg *config.Config, openStorage func(context.Context, *config.Config) (storage.Storage, error), logger *slog.Logger) *DoctorReport {
+	report := &DoctorReport{Ready: true}
+
+	version := doctorDatabase(ctx, cfg, report, logger)
+	doctorBinaries(ctx, cfg, version, report, logger)
+
+	store, err := openStorage(ctx, cfg)
+	if err != nil {
+		report.add(logger, "storage", DoctorFail, cfg.StorageProvider, err)
+		return report
+	}
+	report.add(logger, "storage", DoctorOK, cfg.StorageProvider, nil)
+	doctorStorage(ctx, cfg, store, report, logger)
+	return report
+}
+
+// doctorDatabase connects to the database and returns its version, or nil when
+// it cannot be reached. psql is used as the backup does; without it the
+// built-in driver is.
+func doctorDatabase(ctx context.Context, cfg *config.Config, report *DoctorReport, logger *slog.Logger) *PGVersion {
+	// References are resolved by Railway at deploy time
+	if strings.Contains(cfg.DatabaseURL, "${{") {
+		report.add(logger, "database", DoctorFail, "", errors.New("DATABASE_URL is an unresolved Railway reference"))
+		return nil
+	}
+
+	db := &PostgresBackup{connectionURL: cfg.DatabaseURL, psqlBin: findAvailablePSQL(), logger: logger}
+	var info *DatabaseInfo
+	var err error
+	if _, lookErr := exec.LookPath(db.psqlBin); lookErr != nil {
+		info, err = db.exportInfo(ctx)
+	} else {
+		info, err = db.GetInfoWithRetry(ctx, doctorRetryConfig)
+	}
+	if err != nil {
+		report.add(logger, "database", DoctorFail, "", err)
+		return nil
+	}
+
+	version, err := ParsePGVersion(info.Version)
+	if err != nil {
+		report.add(logger, "database", DoctorFail, info.Name, err)
+		return nil
+	}
+	report.add(logger, "database", DoctorOK,
+		fmt.Sprintf("%s on PostgreSQL %d.%d, %s", info.Name, version.Major, version.Minor, utils.FormatBytes(info.Size)), nil)
+	return version
+}
+
+// doctorBinaries checks that the pg_dump and psql binaries chosen for the
+// server version exist, and that pg_dump is not older than the server.
+func doctorBinaries(ctx context.Context, cfg *config.Config, version *PGVersion, report *DoctorReport, logger *slog.Logger) {
+	if version == nil {
+		report.add(logger, "pg_dump", DoctorSkipped, "the database version is unknown", nil)
+		report.add(logger, "psql", DoctorSkipped, "the database version is unknown", nil)
+		return
+	}
+
+	// A newer pg_dump can dump older servers, so filters select at least pg_dump 17
+	dumpVersion := version
+	if !cfg.DumpFilter.IsEmpty() && version.Major < minFilterFileVersion {
+		dumpVersion = &PGVersion{Major: minFilterFileVersion}
+	}
+	switch bin, err := FindBestPGDump(dumpVersion); {
+	case err != nil && cfg.DumpFallbackExport:
+		report.add(logger, "pg_dump", DoctorWarn, "not installed; DUMP_FALLBACK_EXPORT will export plain SQL instead", err)
+	case err != nil:
+		report.add(logger, "pg_dump", DoctorFail, "", err)
+	default:
+		major, err := pgDumpMajorVersion(ctx, bin)
+		switch {
+		case err != nil:
+			report.add(logger, "pg_dump", DoctorFail, bin, err)
+		case major < dumpVersion.Major:
+			report.add(logger, "pg_dump", DoctorFail, bin,
+				fmt.Errorf("pg_dump %d cannot dump PostgreSQL %d", major, dumpVersion.Major))
+		default:
+			report.add(logger, "pg_dump", DoctorOK, fmt.Sprintf("%s (version %d)", bin, major), nil)
+		}
+
+		if options := strings.Fields(cfg.PGDumpOptions); len(options) > 0 {
+			if err := checkDumpOptions(ctx, bin, options); err != nil {
+				report.add(logger, "pg_dump-options", DoctorFail, cfg.PGDumpOptions, err)
+			} else {
+				report.add(logger, "pg_dump-options", DoctorOK, cfg.PGDumpOptions, nil)
+			}
+		}
+	}
+
+	switch bin, err := FindBestPSQL(version); {
+	case err != nil && cfg.DumpFallbackExport:
+		report.add(logger, "psql", DoctorWarn, "not installed; database details are read with the built-in driver", err)
+	case err != nil:
+		report.add(logger, "psql", DoctorFail, "", err)
+	default:
+		major, err := pgDumpMajorVersion(ctx, bin)
+		switch {
+		case err != nil:
+			report.add(logger, "psql", DoctorFail, bin, err)
+		case major < version.Major:
+			report.add(logger, "psql", DoctorWarn, bin,
+				fmt.Errorf("psql %d is older than PostgreSQL %d", major, version.Major))
+		default:
+			report.add(logger, "psql", DoctorOK, fmt.Sprintf("%s (version %d)", bin, major), nil)
+		}
+	}
+}
+
+// doctorStorage writes a marker object with metadata, reads the metadata back
+// from a listing, compares the clocks, checks the conditional writes RUN_LOCK
+// and the catalog rely on, and deletes what it wrote.
+func doctorStorage(ctx context.Context, cfg *config.Config, store storage.Storage, report *DoctorReport, logger *slog.Logger) {
+	start := time.Now()
+	key, err := writeDoctorMarker(ctx, store)
+	end := time.Now()
+	if err != nil {
+		report.add(logger, "storage-write", DoctorFail, key, err)
+		report.add(logger, "storage-metadata", DoctorSkipped, "the marker could not be written", nil)
+		report.add(logger, "clock-skew", DoctorSkipped, "the marker could not be written", nil)
+		report.add(logger, "storage-delete", DoctorSkipped, "the marker could not be written", nil)
+	} else {
+		report.add(logger, "storage-write", DoctorOK, key, nil)
+		doctorMetadata(ctx, store, key, report, logger)
+		doctorClock(ctx, cfg, store, key, start, end, report, logger)
+
+		// Remove the marker even when the run is cancelled
+		if err := store.Delete(context.WithoutCancel(ctx), key); err != nil {
+			report.add(logger, "storage-delete", DoctorFail, key, err)
+		} else {
+			report.add(logger, "storage-delete", DoctorOK, key, nil)
+		}
+	}
+
+	conditional, ok := storage.AsConditional(store)
+	switch {
+	case !ok && cfg.RunLock:
+		report.add(logger, "conditional-write", DoctorFail, "", fmt.Errorf("RUN_LOCK is not supported by storage provider %s", cfg.StorageProvider))
+	case !ok:
+		report.add(logger, "conditional-write", DoctorSkipped, "not supported by the provider; RUN_LOCK cannot be enabled", nil)
+	default:
+		lockKey := fmt.Sprintf("%s-%d.json", doctorPrefix, time.Now().UnixNano())
+		version, err := conditional.WriteIfVersion(ctx, lockKey, []byte(`{"doctor":true}`), "")
+		if err != nil {
+			report.add(logger, "conditional-write", DoctorFail, lockKey, err)
+			return
+		}
+		if err := conditional.DeleteIfVersion(context.WithoutCancel(ctx), lockKey, version); err != nil {
+			report.add(logger, "conditional-write", DoctorFail, lockKey, err)
+			return
+		}
+		report.add(logger, "conditional-write", DoctorOK, lockKey, nil)
+	}
+}
+
+// CheckStorageWrite proves store accepts writes and deletes by writing a
+// doctor marker object and deleting it again.
+func CheckStorageWrite(ctx context.Context, store storage.Storage) error {
+	key, err := writeDoctorMarker(ctx, store)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	// Remove the marker even when the run is cancelled
+	if err := store.Delete(context.WithoutCancel(ctx), key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// writeDoctorMarker uploads a small marker object with metadata and returns its key.
+func writeDoctorMarker(ctx context.Context, store storage.Storage) (string, error) {
+	key := fmt.Sprintf("%s-%d.txt", doctorPrefix, time.Now().UnixNano())
+	err := store.Upload(ctx, key, strings.NewReader("railway-postgres-backup doctor marker\n"), map[string]string{
+		"backup-tool": "railway-postgres-backup",
+		"doctor":      "true",
+	})
+	return key, err
+}
+
+// doctorClock compares the local clock with the storage provider's from the
+// marker's last modified time, as backup runs do with the checksum sidecar.
+func doctorClock(ctx context.Context, cfg *config.Config, store storage.Storage, key string, start, end time.Time, report *DoctorReport, logger *slog.Logger) {
+	if cfg.ClockSkewWarnSeconds == 0 {
+		report.add(logger, "clock-skew", DoctorSkipped, "CLOCK_SKEW_WARN_SECONDS is 0", nil)
+		return
+	}
+	skew, err := storage.MeasureClockSkew(ctx, store, key, start, end)
+	if err != nil {
+		report.add(logger, "clock-skew", DoctorFail, key, err)
+		return
+	}
+
+	detail := fmt.Sprintf("local clock %s ahead of the storage provider's", skew.Round(time.Second))
+	if skew < 0 {
+		detail = fmt.Sprintf("local clock %s behind the storage provider's", (-skew).Round(time.Second))
+	}
+	if threshold := time.Duration(cfg.ClockSkewWarnSeconds) * time.Second; skew > threshold || skew < -threshold {
+		report.add(logger, "clock-skew", DoctorWarn, detail,
+			errors.New("respawn protection and retention may misjudge backup ages"))
+		return
+	}
+	report.add(logger, "clock-skew", DoctorOK, detail, nil)
+}
+
+// doctorMetadata checks the marker is listed with the metadata it was written with.
+func doctorMetadata(ctx context.Context, store storage.Storage, key string, report *DoctorReport, logger *slog.Logger) {
+	objects, err := storage.ListWithMetadata(ctx, store, key)
+	if err != nil {
+		report.add(logger, "storage-metadata", DoctorFail, key, err)
+		return
+	}
+	for _, obj := range objects {
+		if obj.Key != key {
+			continue
+		}
+		if obj.Metadata["doctor"] != "true" {
+			report.add(logger, "storage-metadata", DoctorWarn, key,
+				errors.New("object metadata is not kept; retention and list fall back to filename timestamps"))
+			return
+		}
+		report.add(logger, "storage-metadata", DoctorOK, key, nil)
+		return
+	}
+	report.add(logger, "storage-metadata", DoctorFail, key, fmt.Errorf("%s is not listed after upload", key))
+}
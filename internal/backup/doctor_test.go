@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestDoctor(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Nothing listens on port 1, so the database check fails fast
+	const unreachable = "postgres://doctor@127.0.0.1:1/db?sslmode=disable&connect_timeout=2"
+
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := func(store storage.Storage) func(context.Context, *config.Config) (storage.Storage, error) {
+		return func(context.Context, *config.Config) (storage.Storage, error) { return store, nil }
+	}
+
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		openStorage func(context.Context, *config.Config) (storage.Storage, error)
+		want        map[string]string
+	}{
+		{
+			name:        "unreachable database, working storage",
+			cfg:         &config.Config{StorageProvider: "filesystem", DatabaseURL: unreachable, ClockSkewWarnSeconds: 60},
+			openStorage: opened(storage.NewPrefixedStorage(fs, "db/")),
+			want: map[string]string{
+				"database":          DoctorFail,
+				"pg_dump":           DoctorSkipped,
+				"psql":              DoctorSkipped,
+				"storage":           DoctorOK,
+				"storage-write":     DoctorOK,
+				"storage-metadata":  DoctorOK,
+				"clock-skew":        DoctorOK,
+				"storage-delete":    DoctorOK,
+				"conditional-write": DoctorOK,
+			},
+		},
+		{
+			name:        "unresolved Railway reference",
+			cfg:         &config.Config{StorageProvider: "filesystem", DatabaseURL: "${{Postgres.DATABASE_URL}}"},
+			openStorage: opened(fs),
+			want:        map[string]string{"database": DoctorFail, "storage-write": DoctorOK},
+		},
+		{
+			name: "storage cannot be created",
+			cfg:  &config.Config{StorageProvider: "s3", DatabaseURL: unreachable},
+			openStorage: func(context.Context, *config.Config) (storage.Storage, error) {
+				return nil, errors.New("no credentials")
+			},
+			want: map[string]string{"storage": DoctorFail, "storage-write": ""},
+		},
+		{
+			name:        "storage refuses writes",
+			cfg:         &config.Config{StorageProvider: "s3", DatabaseURL: unreachable, RunLock: true},
+			openStorage: opened(&mockStorage{uploadErr: errors.New("access denied")}),
+			want: map[string]string{
+				"storage-write":     DoctorFail,
+				"storage-metadata":  DoctorSkipped,
+				"clock-skew":        DoctorSkipped,
+				"storage-delete":    DoctorSkipped,
+				"conditional-write": DoctorFail,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Doctor(ctx, tt.cfg, tt.openStorage, logger)
+			if report.Ready {
+				t.Error("Doctor() ready = true, want false")
+			}
+			got := make(map[string]string)
+			for _, c := range report.Checks {
+				got[c.Name] = c.Status
+			}
+			for name, status := range tt.want {
+				if got[name] != status {
+					t.Errorf("check %s = %q, want %q", name, got[name], status)
+				}
+			}
+		})
+	}
+
+	// The markers are deleted again
+	objects, err := fs.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("objects left after doctor: %v", objects)
+	}
+}
+
+func TestCheckStorageWrite(t *testing.T) {
+	ctx := context.Background()
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		store   storage.Storage
+		wantErr bool
+	}{
+		{name: "writable", store: fs},
+		{name: "listing only", store: storage.NewReadOnlyStorage(fs), wantErr: true},
+		{name: "writes refused", store: &mockStorage{uploadErr: errors.New("access denied")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckStorageWrite(ctx, tt.store); (err != nil) != tt.wantErr {
+				t.Errorf("CheckStorageWrite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The marker is deleted again
+	objects, err := fs.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("objects left after CheckStorageWrite: %v", objects)
+	}
+}
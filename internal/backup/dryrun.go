@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// DryRunPlan is what a run would do, as worked out by a dry run.
+type DryRunPlan struct {
+	Key             string            `json:"key"`
+	Database        string            `json:"database"`
+	DatabaseVersion string            `json:"database_version"`
+	DatabaseSize    int64             `json:"database_size_bytes"`
+	Binaries        map[string]string `json:"binaries,omitempty"`
+	WouldBackup     bool              `json:"would_backup"`
+	Reason          string            `json:"reason"`
+	WouldDelete     []string          `json:"would_delete"`
+}
+
+// dryRun works out what a run would do without dumping, uploading or deleting:
+// the rate limiter and precondition decisions, the binaries chosen for the
+// server, the key of the backup and the keys retention would delete. Unlike a
+// real run, a database that cannot be reached fails it, as nothing else can be
+// worked out reliably without one.
+func (o *Orchestrator) dryRun(ctx context.Context) (*Result, error) {
+	o.logger.Info("Starting dry run; nothing will be dumped, uploaded or deleted")
+	plan := &DryRunPlan{WouldBackup: true, WouldDelete: []string{}}
+
+	lastBackupTime, err := storage.LastBackupTime(ctx, o.storage)
+	switch reason := o.pauseReason(ctx); {
+	case reason != "":
+		plan.WouldBackup, plan.Reason = false, reason
+	case err != nil:
+		plan.Reason = fmt.Sprintf("last backup time unknown: %v", err)
+	default:
+		plan.WouldBackup, plan.Reason = o.rateLimiter.ShouldBackup(lastBackupTime)
+	}
+	if plan.WouldBackup {
+		if reason := o.checkPreconditions(ctx); reason != "" {
+			plan.WouldBackup, plan.Reason = false, reason
+		}
+	}
+
+	info, err := o.backup.GetInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database info: %w", err)
+	}
+	plan.Database, plan.DatabaseVersion, plan.DatabaseSize = info.Name, info.Version, info.Size
+
+	if resolver, ok := o.backup.(BinaryResolver); ok {
+		plan.Binaries = make(map[string]string)
+		for tool, bin := range resolver.Binaries() {
+			if path, err := exec.LookPath(bin); err == nil {
+				plan.Binaries[tool] = path
+			} else {
+				plan.Binaries[tool] = bin + " (not found)"
+			}
+		}
+	}
+
+	_, plan.Key, _, err = o.backupKey(time.Now(), info)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.config.RetentionEnabled() {
+		keys, err := o.cleanupOldBackups(ctx, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate retention: %w", err)
+		}
+		plan.WouldDelete = append(plan.WouldDelete, keys...)
+	}
+
+	o.logger.Info("Dry run completed",
+		"would_backup", plan.WouldBackup,
+		"reason", plan.Reason,
+		"storage_key", plan.Key,
+		"would_delete", len(plan.WouldDelete),
+	)
+	return &Result{Skipped: true, Reason: "dry run", Plan: plan}, nil
+}
@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestOrchestrator_DryRun(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Now()
+	oldKey := "test-" + now.AddDate(0, 0, -10).Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+	recentKey := "test-" + now.AddDate(0, 0, -2).Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+
+	tests := []struct {
+		name            string
+		respawnHours    int
+		backup          *mockBackup
+		wantErr         bool
+		wantWouldBackup bool
+	}{
+		{name: "backup due", respawnHours: 6, backup: &mockBackup{}, wantWouldBackup: true},
+		{name: "rate limited", respawnHours: 72, backup: &mockBackup{}, wantWouldBackup: false},
+		{name: "database unreachable", respawnHours: 6, backup: &mockBackup{infoErr: errors.New("connection refused")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mockStorage{
+				lastBackup: now.AddDate(0, 0, -2),
+				listResult: []storage.ObjectInfo{
+					{Key: oldKey, LastModified: now.AddDate(0, 0, -10)},
+					{Key: recentKey, LastModified: now.AddDate(0, 0, -2)},
+				},
+			}
+			cfg := &config.Config{
+				StorageProvider:        "s3",
+				BackupFilePrefix:       "test",
+				RespawnProtectionHours: tt.respawnHours,
+				RetentionDays:          7,
+				DryRun:                 true,
+			}
+
+			result, err := NewOrchestrator(cfg, mockStorage, tt.backup, logger).Execute(context.Background())
+			if mockStorage.uploadCalled || len(mockStorage.deleteCalls) > 0 {
+				t.Fatalf("dry run uploaded %v or deleted %v", mockStorage.uploadCalled, mockStorage.deleteCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Execute() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			plan := result.Plan
+			if !result.Skipped || plan == nil {
+				t.Fatalf("Execute() = %+v, want a skipped run with a plan", result)
+			}
+			if plan.WouldBackup != tt.wantWouldBackup {
+				t.Errorf("WouldBackup = %v (%s), want %v", plan.WouldBackup, plan.Reason, tt.wantWouldBackup)
+			}
+			if !strings.HasPrefix(path.Base(plan.Key), "test-") || plan.Database != "testdb" {
+				t.Errorf("plan key = %q, database = %q", plan.Key, plan.Database)
+			}
+			if !slices.Equal(plan.WouldDelete, []string{oldKey}) {
+				t.Errorf("WouldDelete = %v, want [%s]", plan.WouldDelete, oldKey)
+			}
+		})
+	}
+}
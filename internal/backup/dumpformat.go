@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+// pg_dump output formats.
+const (
+	FormatPlain     = "plain"
+	FormatCustom    = "custom"
+	FormatTar       = "tar"
+	FormatDirectory = "directory"
+)
+
+// DumpFormat returns the pg_dump output format selected by the given options.
+// Dump always passes --format=tar ahead of the configured options, so tar is the
+// default and only an explicit format option overrides it.
+func DumpFormat(pgDumpOptions string) string {
+	format := FormatTar
+	args := strings.Fields(pgDumpOptions)
+	for i := 0; i < len(args); i++ {
+		var value string
+		switch arg := args[i]; {
+		case arg == "-F" || arg == "--format":
+			if i+1 < len(args) {
+				value = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(arg, "--format="):
+			value = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "-F"):
+			value = strings.TrimPrefix(arg, "-F")
+		default:
+			continue
+		}
+		if f := normalizeFormat(value); f != "" {
+			format = f
+		}
+	}
+	return format
+}
+
+// ConfigureCompression resolves the compressor for the configured pg_dump options.
+// When COMPRESSION is unset and pg_dump already compresses its output, the outer
+// codec is disabled by switching cfg.Compression to "none", so filenames and
+// metadata stay consistent; an explicit codec is kept but double compression is logged.
+func ConfigureCompression(cfg *config.Config, logger *slog.Logger) (compression.Compressor, error) {
+	format := DumpFormat(cfg.PGDumpOptions)
+	if format == FormatDirectory {
+		return nil, fmt.Errorf("directory-format dumps cannot be streamed; use --format=custom")
+	}
+
+	dumpCompressed := DumpCompressed(cfg.PGDumpOptions)
+	if cfg.Compression == "" && dumpCompressed {
+		logger.Info("pg_dump already compresses its output, skipping outer compression", "format", format)
+		cfg.Compression = "none"
+	}
+
+	compressor, err := cfg.GetCompressor()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case dumpCompressed && compressor.Name() != "none":
+		logger.Warn("pg_dump output is already compressed; COMPRESSION adds CPU cost for little gain",
+			"format", format, "compression", compressor.Name())
+	case !dumpCompressed && compressor.Name() == "none":
+		logger.Warn("Backups are stored uncompressed; consider --format=custom", "format", format)
+	}
+
+	return compressor, nil
+}
+
+// DumpCompressed reports whether pg_dump compresses its own output with the given options.
+// Custom format compresses by default unless -Z 0 / --compress=none is set; plain format
+// is only compressed when a compression level is requested explicitly.
+func DumpCompressed(pgDumpOptions string) bool {
+	level, set := dumpCompression(pgDumpOptions)
+
+	switch DumpFormat(pgDumpOptions) {
+	case FormatCustom, FormatDirectory:
+		return !set || level != "0" && level != "none"
+	case FormatPlain:
+		return set && level != "0" && level != "none"
+	default:
+		// Tar archives do not support compression
+		return false
+	}
+}
+
+// dumpCompression returns the value of the last -Z/--compress option, if any.
+// PostgreSQL 16+ accepts method[:detail] values such as "zstd" or "gzip:9".
+func dumpCompression(pgDumpOptions string) (string, bool) {
+	var value string
+	var set bool
+	args := strings.Fields(pgDumpOptions)
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-Z" || arg == "--compress":
+			if i+1 < len(args) {
+				value, set = args[i+1], true
+				i++
+			}
+		case strings.HasPrefix(arg, "--compress="):
+			value, set = strings.TrimPrefix(arg, "--compress="), true
+		case strings.HasPrefix(arg, "-Z"):
+			value, set = strings.TrimPrefix(arg, "-Z"), true
+		}
+	}
+
+	// "gzip:0" and "none:..." disable compression just like a bare level
+	method, detail, hasDetail := strings.Cut(value, ":")
+	if hasDetail && (detail == "0" || detail == "level=0") {
+		return "0", set
+	}
+	return method, set
+}
+
+// normalizeFormat maps pg_dump format abbreviations (c, p, t, d) to full names.
+func normalizeFormat(value string) string {
+	switch strings.ToLower(value) {
+	case "p", "plain":
+		return FormatPlain
+	case "c", "custom":
+		return FormatCustom
+	case "t", "tar":
+		return FormatTar
+	case "d", "directory":
+		return FormatDirectory
+	}
+	return ""
+}
+
+// BackupExtension returns the filename extension for a backup.
+// Compressed backups keep the historical ".tar" base (e.g. ".tar.gz"); uncompressed
+// backups are named after the pg_dump format so restore tools recognise them.
+func BackupExtension(pgDumpOptions string, compressor compression.Compressor) string {
+	if ext := compressor.Extension(); ext != "" {
+		return ".tar" + ext
+	}
+
+	switch DumpFormat(pgDumpOptions) {
+	case FormatCustom:
+		return ".dump"
+	case FormatTar:
+		return ".tar"
+	default:
+		return ".sql"
+	}
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+func TestDumpFormat(t *testing.T) {
+	tests := []struct {
+		options string
+		want    string
+	}{
+		{options: "", want: FormatTar},
+		{options: "--no-owner", want: FormatTar},
+		{options: "-Fp", want: FormatPlain},
+		{options: "-Fc", want: FormatCustom},
+		{options: "-F c --no-owner", want: FormatCustom},
+		{options: "--format=custom -Z 6", want: FormatCustom},
+		{options: "--format tar", want: FormatTar},
+		{options: "-Fd", want: FormatDirectory},
+		{options: "--format=bogus", want: FormatTar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.options, func(t *testing.T) {
+			if got := DumpFormat(tt.options); got != tt.want {
+				t.Errorf("DumpFormat(%q) = %v, want %v", tt.options, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		options     string
+		want        string
+	}{
+		{name: "gzip", compression: "gzip", options: "", want: ".tar.gz"},
+		{name: "zstd custom", compression: "zstd", options: "-Fc", want: ".tar.zst"},
+		{name: "none custom", compression: "none", options: "--format=custom", want: ".dump"},
+		{name: "none tar", compression: "none", options: "-Ft", want: ".tar"},
+		{name: "none default tar", compression: "none", options: "", want: ".tar"},
+		{name: "none plain", compression: "none", options: "-Fp", want: ".sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := compression.Get(tt.compression, compression.DefaultLevel)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := BackupExtension(tt.options, c); got != tt.want {
+				t.Errorf("BackupExtension() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpCompressed(t *testing.T) {
+	tests := []struct {
+		options string
+		want    bool
+	}{
+		{options: "", want: false},
+		{options: "--no-owner", want: false},
+		{options: "-Fc", want: true},
+		{options: "--format=custom -Z 6", want: true},
+		{options: "-Fc -Z0", want: false},
+		{options: "-Fc --compress=none", want: false},
+		{options: "-Fc --compress=zstd", want: true},
+		{options: "-Fc --compress=gzip:0", want: false},
+		{options: "-Fd", want: true},
+		{options: "-Z 9", want: false},
+		{options: "-Fp -Z 9", want: true},
+		{options: "-Ft -Z 9", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.options, func(t *testing.T) {
+			if got := DumpCompressed(tt.options); got != tt.want {
+				t.Errorf("DumpCompressed(%q) = %v, want %v", tt.options, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		options     string
+		want        string
+		wantErr     bool
+	}{
+		{name: "default tar uses gzip", options: "", want: "gzip"},
+		{name: "default custom skips outer compression", options: "-Fc", want: "none"},
+		{name: "default custom without compression uses gzip", options: "-Fc -Z0", want: "gzip"},
+		{name: "explicit codec is kept", compression: "zstd", options: "-Fc", want: "zstd"},
+		{name: "directory format rejected", options: "-Fd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Compression: tt.compression, PGDumpOptions: tt.options}
+			c, err := ConfigureCompression(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigureCompression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if c.Name() != tt.want {
+				t.Errorf("ConfigureCompression() = %v, want %v", c.Name(), tt.want)
+			}
+			// The config must agree so the orchestrator names files consistently
+			if cc, _ := cfg.GetCompressor(); cc.Name() != tt.want {
+				t.Errorf("cfg.GetCompressor() = %v, want %v", cc.Name(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dumpOptionVersions maps pg_dump options to the first major release that
+// accepts them, for explaining an option the selected pg_dump rejects.
+var dumpOptionVersions = map[string]int{
+	"--on-conflict-do-nothing":          12,
+	"--rows-per-insert":                 12,
+	"--include-foreign-data":            13,
+	"--extension":                       14,
+	"--no-toast-compression":            14,
+	"--no-table-access-method":          15,
+	"--table-and-children":              16,
+	"--exclude-table-and-children":      16,
+	"--exclude-table-data-and-children": 16,
+	"--large-objects":                   16,
+	"--no-large-objects":                16,
+	"--filter":                          17,
+	"--exclude-extension":               17,
+	"--sync-method":                     17,
+	"--no-data":                         18,
+	"--no-schema":                       18,
+	"--no-statistics":                   18,
+	"--statistics-only":                 18,
+	"--with-data":                       18,
+	"--with-schema":                     18,
+	"--with-statistics":                 18,
+	"--sequence-data":                   18,
+	"--no-policies":                     18,
+}
+
+// shortDumpOptions maps the short options of newer releases to their long form.
+var shortDumpOptions = map[string]string{
+	"-e": "--extension",
+}
+
+// minCompressMethodVersion is the first pg_dump release that accepts a
+// compression method (gzip, lz4, zstd) rather than only a level.
+const minCompressMethodVersion = 16
+
+// helpOptionPattern matches the long options listed by `pg_dump --help`.
+var helpOptionPattern = regexp.MustCompile(`--[a-z][a-z0-9-]*`)
+
+// CheckDumpOptions implements DumpOptionChecker.
+func (p *PostgresBackup) CheckDumpOptions(ctx context.Context) error {
+	if len(p.pgDumpOptions) == 0 || (p.exportFallback && p.pgDumpMissing()) {
+		return nil
+	}
+	return checkDumpOptions(ctx, p.pgDumpBin, p.pgDumpOptions)
+}
+
+// checkDumpOptions checks options against the pg_dump at bin: its --help
+// output when it can be read, the known option matrix otherwise.
+func checkDumpOptions(ctx context.Context, bin string, options []string) error {
+	major, err := pgDumpMajorVersion(ctx, bin)
+	if err != nil {
+		return err
+	}
+	var supported map[string]bool
+	if help, err := command(ctx, bin, "--help").Output(); err == nil {
+		supported = helpOptions(string(help))
+	}
+	return compareDumpOptions(options, major, supported)
+}
+
+// helpOptions returns the long options listed in `pg_dump --help` output.
+func helpOptions(help string) map[string]bool {
+	supported := make(map[string]bool)
+	for _, option := range helpOptionPattern.FindAllString(help, -1) {
+		supported[option] = true
+	}
+	return supported
+}
+
+// compareDumpOptions returns an error naming the first option pg_dump major
+// does not accept. A long option is accepted when supported lists it, or,
+// when supported is nil, unless the option matrix places it in a later
+// release.
+func compareDumpOptions(options []string, major int, supported map[string]bool) error {
+	for i := 0; i < len(options); i++ {
+		name, value, hasValue := strings.Cut(options[i], "=")
+		if long, ok := shortDumpOptions[name]; ok {
+			name = long
+		}
+
+		// A compression method needs pg_dump 16; older releases only take a level
+		if name == "--compress" || strings.HasPrefix(name, "-Z") {
+			switch {
+			case name != "--compress" && len(name) > 2:
+				value, hasValue = name[2:], true
+			case !hasValue && i+1 < len(options):
+				value, hasValue = options[i+1], true
+			}
+			if hasValue && major < minCompressMethodVersion && strings.Trim(value, "0123456789") != "" {
+				return fmt.Errorf("compression method %q requires pg_dump >= %d, the selected pg_dump is version %d",
+					value, minCompressMethodVersion, major)
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, "--") {
+			continue
+		}
+
+		since, known := dumpOptionVersions[name]
+		switch {
+		case supported != nil && supported[name]:
+			continue
+		case known && major < since:
+			return fmt.Errorf("%s requires pg_dump >= %d, the selected pg_dump is version %d", name, since, major)
+		case supported != nil:
+			return fmt.Errorf("%s is not a pg_dump %d option", name, major)
+		}
+	}
+	return nil
+}
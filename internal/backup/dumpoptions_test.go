@@ -0,0 +1,56 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareDumpOptions(t *testing.T) {
+	help16 := `pg_dump dumps a database as a text file or to other formats.
+
+General options:
+  -f, --file=FILENAME          output file or directory name
+  -F, --format=c|d|t|p         output file format (custom, directory, tar,
+  -Z, --compress=METHOD[:DETAIL]
+  -e, --extension=PATTERN      dump the specified extension(s) only
+  --table-and-children=PATTERN dump only the specified table(s), including
+  --no-comments                do not dump comments
+`
+
+	tests := []struct {
+		name      string
+		options   string
+		major     int
+		help      string
+		wantError string
+	}{
+		{name: "listed options", options: "--no-comments --table-and-children=orders", major: 16, help: help16},
+		{name: "short options are not checked against help", options: "-t orders -n public", major: 16, help: help16},
+		{name: "option missing from help", options: "--filter=rules.txt", major: 16, help: help16, wantError: "--filter requires pg_dump >= 17"},
+		{name: "unknown option missing from help", options: "--no-such-option", major: 16, help: help16, wantError: "--no-such-option is not a pg_dump 16 option"},
+		{name: "short form of a newer option", options: "-e postgis", major: 13, wantError: "--extension requires pg_dump >= 14"},
+		{name: "matrix without help", options: "--table-and-children=orders", major: 15, wantError: "--table-and-children requires pg_dump >= 16"},
+		{name: "unknown option without help", options: "--no-such-option", major: 15},
+		{name: "help lists a backported option", options: "--exclude-extension=postgis", major: 16, help: help16 + "  --exclude-extension=PATTERN\n"},
+		{name: "compression level", options: "-Z 9 --compress=6", major: 14},
+		{name: "compression method", options: "--compress=zstd:3", major: 15, wantError: `compression method "zstd:3" requires pg_dump >= 16`},
+		{name: "attached compression method", options: "-Zlz4", major: 15, wantError: `compression method "lz4"`},
+		{name: "compression method on pg_dump 16", options: "-Z zstd", major: 16, help: help16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var supported map[string]bool
+			if tt.help != "" {
+				supported = helpOptions(tt.help)
+			}
+			err := compareDumpOptions(strings.Fields(tt.options), tt.major, supported)
+			switch {
+			case tt.wantError == "" && err != nil:
+				t.Errorf("compareDumpOptions() error = %v, want none", err)
+			case tt.wantError != "" && (err == nil || !strings.Contains(err.Error(), tt.wantError)):
+				t.Errorf("compareDumpOptions() error = %v, want %q", err, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// exportHeader opens every fallback export, so whoever restores it knows what it
+// holds and what it leaves out compared to pg_dump.
+const exportHeader = `--
+-- PostgreSQL database export by railway-postgres-backup
+--
+-- This is NOT a pg_dump archive. It was written by the built-in fallback
+-- exporter because no suitable pg_dump binary was found in the image.
+-- It holds schemas, extensions, enum types, sequences, tables with their
+-- data, constraints and indexes. Views, materialized views, functions,
+-- triggers, rules, policies, grants, ownership, comments and large objects
+-- are NOT included, and partitions are restored as standalone tables.
+--
+-- Restore with: psql --set ON_ERROR_STOP=1 --single-transaction --file <export>
+--
+
+SET statement_timeout = 0;
+SET lock_timeout = 0;
+SET client_encoding = 'UTF8';
+SET standard_conforming_strings = on;
+SET check_function_bodies = false;
+SELECT pg_catalog.set_config('search_path', '', false);
+
+`
+
+// userObjects restricts a catalog query to objects outside the system schemas
+// that are not owned by an extension. It expects the namespace as n, and is
+// formatted with the column holding the object's oid.
+const userObjects = `n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%%'
+	AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend d WHERE d.objid = %s AND d.deptype = 'e')`
+
+var (
+	exportSchemasQuery = `SELECT n.nspname FROM pg_catalog.pg_namespace n
+		WHERE n.nspname <> 'public' AND ` + fmt.Sprintf(userObjects, "n.oid") + ` ORDER BY 1`
+
+	exportExtensionsQuery = `SELECT e.extname, n.nspname FROM pg_catalog.pg_extension e
+		JOIN pg_catalog.pg_namespace n ON n.oid = e.extnamespace
+		WHERE e.extname <> 'plpgsql' ORDER BY 1`
+
+	exportEnumsQuery = `SELECT n.nspname, t.typname,
+			string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder)
+		FROM pg_catalog.pg_type t
+		JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_catalog.pg_enum e ON e.enumtypid = t.oid
+		WHERE ` + fmt.Sprintf(userObjects, "t.oid") + `
+		GROUP BY n.nspname, t.typname ORDER BY 1, 2`
+
+	// Identity sequences are created with their column
+	exportSequencesQuery = `SELECT n.nspname, c.relname, format_type(s.seqtypid, NULL), s.seqstart,
+			s.seqincrement, s.seqmin, s.seqmax, s.seqcycle, ps.last_value
+		FROM pg_catalog.pg_sequence s
+		JOIN pg_catalog.pg_class c ON c.oid = s.seqrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_catalog.pg_sequences ps ON ps.schemaname = n.nspname AND ps.sequencename = c.relname
+		WHERE ` + fmt.Sprintf(userObjects, "c.oid") + `
+			AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend d WHERE d.objid = c.oid AND d.deptype = 'i')
+		ORDER BY 1, 2`
+
+	exportTablesQuery = `SELECT c.oid, n.nspname, c.relname FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'r' AND c.relpersistence <> 't' AND ` + fmt.Sprintf(userObjects, "c.oid") + `
+		ORDER BY 2, 3`
+)
+
+// exportColumnsQuery lists a table's columns, with the last value of the
+// sequence behind each identity column.
+const exportColumnsQuery = `SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+		coalesce(pg_get_expr(ad.adbin, ad.adrelid), ''), a.attidentity, a.attgenerated,
+		CASE WHEN a.attidentity <> '' THEN (
+			SELECT ps.last_value FROM pg_catalog.pg_sequences ps
+			WHERE format('%I.%I', ps.schemaname, ps.sequencename) =
+				pg_get_serial_sequence(a.attrelid::regclass::text, a.attname)
+		) END
+	FROM pg_catalog.pg_attribute a
+	LEFT JOIN pg_catalog.pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+	WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY a.attnum`
+
+// exportConstraintsQuery lists a table's constraints, foreign keys last.
+const exportConstraintsQuery = `SELECT conname, contype = 'f', pg_get_constraintdef(oid)
+	FROM pg_catalog.pg_constraint
+	WHERE conrelid = $1 AND contype IN ('p', 'u', 'c', 'x', 'f')
+	ORDER BY contype = 'f', conname`
+
+// exportIndexesQuery lists a table's indexes that no constraint creates.
+const exportIndexesQuery = `SELECT pg_get_indexdef(i.indexrelid) FROM pg_catalog.pg_index i
+	WHERE i.indrelid = $1
+		AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint c WHERE c.conindid = i.indexrelid)
+	ORDER BY 1`
+
+// exportColumn is a table column as exported.
+type exportColumn struct {
+	Name      string
+	Type      string
+	NotNull   bool
+	Default   string
+	Identity  string // "a" (always), "d" (by default) or ""
+	Generated string // "s" (stored) or ""
+	LastValue sql.NullInt64
+}
+
+// exportTable is a table with everything exported for it.
+type exportTable struct {
+	OID         uint32
+	Schema      string
+	Name        string
+	Columns     []exportColumn
+	Constraints []string
+	ForeignKeys []string
+	Indexes     []string
+}
+
+// qualified returns the quoted schema-qualified table name.
+func (t *exportTable) qualified() string {
+	return quoteIdent(t.Schema) + "." + quoteIdent(t.Name)
+}
+
+// createStatement returns the CREATE TABLE statement of the table, without
+// its constraints.
+func (t *exportTable) createStatement() string {
+	defs := make([]string, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		def := quoteIdent(c.Name) + " " + c.Type
+		switch {
+		case c.Generated == "s":
+			def += " GENERATED ALWAYS AS (" + c.Default + ") STORED"
+		case c.Identity == "a":
+			def += " GENERATED ALWAYS AS IDENTITY"
+		case c.Identity == "d":
+			def += " GENERATED BY DEFAULT AS IDENTITY"
+		case c.Default != "":
+			def += " DEFAULT " + c.Default
+		}
+		if c.NotNull {
+			def += " NOT NULL"
+		}
+		defs = append(defs, "    "+def)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);\n", t.qualified(), strings.Join(defs, ",\n"))
+}
+
+// copyColumns returns the columns whose values are dumped; generated columns
+// are computed again on restore.
+func (t *exportTable) copyColumns() []string {
+	var cols []string
+	for _, c := range t.Columns {
+		if c.Generated == "" {
+			cols = append(cols, quoteIdent(c.Name))
+		}
+	}
+	return cols
+}
+
+// pgDumpMissing reports whether the pg_dump binary chosen for the server
+// cannot be run.
+func (p *PostgresBackup) pgDumpMissing() bool {
+	_, err := exec.LookPath(p.pgDumpBin)
+	return err != nil
+}
+
+// OutputFormat returns the format Dump writes: plain SQL when it falls back to
+// the built-in exporter, the format PG_DUMP_OPTIONS selects otherwise.
+func (p *PostgresBackup) OutputFormat() string {
+	if p.exportFallback && p.pgDumpMissing() {
+		return FormatPlain
+	}
+	return DumpFormat(strings.Join(p.pgDumpOptions, " "))
+}
+
+// exportDump writes a plain SQL export of the database without pg_dump, reading
+// everything in one repeatable-read transaction so the export is consistent.
+// It is the fallback for images without a usable pg_dump binary.
+func (p *PostgresBackup) exportDump(ctx context.Context) (io.ReadCloser, error) {
+	db, err := sql.Open("postgres", p.connectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to start export transaction: %w", err)
+	}
+
+	p.logger.Warn("No usable pg_dump binary, exporting with the built-in fallback exporter; "+
+		"views, functions, triggers, grants and large objects are not included", "pg_dump", p.pgDumpBin)
+
+	pr, pw := io.Pipe()
+	codec := p.codec()
+	go func() {
+		defer func() {
+			_ = tx.Rollback()
+			_ = db.Close()
+		}()
+
+		cw, err := codec.NewWriter(pw)
+		if err != nil {
+			_ = pw.CloseWithError(fmt.Errorf("failed to create %s writer: %w", codec.Name(), err))
+			return
+		}
+		bw := bufio.NewWriterSize(cw, 64*1024)
+		exportErr := p.exportDatabase(ctx, tx, bw)
+		if exportErr == nil {
+			exportErr = bw.Flush()
+		}
+		if closeErr := cw.Close(); exportErr == nil && closeErr != nil {
+			exportErr = fmt.Errorf("failed to close %s writer: %w", codec.Name(), closeErr)
+		}
+		if exportErr != nil {
+			_ = pw.CloseWithError(fmt.Errorf("fallback export failed: %w", exportErr))
+			return
+		}
+		_ = pw.Close()
+	}()
+
+	return pr, nil
+}
+
+// exportDatabase writes the export: schemas, extensions, types and sequences,
+// then each table and its rows, then sequence values, constraints, indexes and
+// finally foreign keys, so data loads before anything checks it.
+func (p *PostgresBackup) exportDatabase(ctx context.Context, tx *sql.Tx, w io.Writer) error {
+	// Definitions are read with an empty search_path so every name is qualified
+	if _, err := tx.ExecContext(ctx, "SELECT pg_catalog.set_config('search_path', '', true)"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, exportHeader); err != nil {
+		return err
+	}
+
+	err := queryRows(ctx, tx, exportSchemasQuery, nil, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "CREATE SCHEMA IF NOT EXISTS %s;\n", quoteIdent(name))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export schemas: %w", err)
+	}
+
+	err = queryRows(ctx, tx, exportExtensionsQuery, nil, func(rows *sql.Rows) error {
+		var name, schema string
+		if err := rows.Scan(&name, &schema); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;\n", quoteIdent(name), quoteIdent(schema))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export extensions: %w", err)
+	}
+
+	err = queryRows(ctx, tx, exportEnumsQuery, nil, func(rows *sql.Rows) error {
+		var schema, name, labels string
+		if err := rows.Scan(&schema, &name, &labels); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "CREATE TYPE %s.%s AS ENUM (%s);\n", quoteIdent(schema), quoteIdent(name), labels)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export enum types: %w", err)
+	}
+
+	var setvals []string
+	err = queryRows(ctx, tx, exportSequencesQuery, nil, func(rows *sql.Rows) error {
+		var schema, name, typ string
+		var start, increment, minValue, maxValue int64
+		var cycle bool
+		var last sql.NullInt64
+		if err := rows.Scan(&schema, &name, &typ, &start, &increment, &minValue, &maxValue, &cycle, &last); err != nil {
+			return err
+		}
+		qualified := quoteIdent(schema) + "." + quoteIdent(name)
+		cycleClause := "NO CYCLE"
+		if cycle {
+			cycleClause = "CYCLE"
+		}
+		if last.Valid {
+			setvals = append(setvals, fmt.Sprintf("SELECT pg_catalog.setval(%s, %d, true);\n", quoteLiteral(qualified), last.Int64))
+		}
+		_, err := fmt.Fprintf(w, "CREATE SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d %s;\n",
+			qualified, typ, start, increment, minValue, maxValue, cycleClause)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export sequences: %w", err)
+	}
+
+	tables, err := exportTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if _, err := fmt.Fprintf(w, "\n%s", t.createStatement()); err != nil {
+			return err
+		}
+		for _, c := range t.Columns {
+			if c.Identity != "" && c.LastValue.Valid {
+				setvals = append(setvals, fmt.Sprintf("SELECT pg_catalog.setval(pg_catalog.pg_get_serial_sequence(%s, %s), %d, true);\n",
+					quoteLiteral(t.qualified()), quoteLiteral(c.Name), c.LastValue.Int64))
+			}
+		}
+	}
+
+	for i := range tables {
+		if err := exportRows(ctx, tx, &tables[i], w); err != nil {
+			return fmt.Errorf("failed to export rows of %s: %w", tables[i].qualified(), err)
+		}
+	}
+
+	var statements []string
+	statements = append(statements, setvals...)
+	for _, t := range tables {
+		for _, def := range t.Constraints {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE ONLY %s ADD %s;\n", t.qualified(), def))
+		}
+		for _, def := range t.Indexes {
+			statements = append(statements, def+";\n")
+		}
+	}
+	for _, t := range tables {
+		for _, def := range t.ForeignKeys {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE ONLY %s ADD %s;\n", t.qualified(), def))
+		}
+	}
+	if _, err := io.WriteString(w, "\n"+strings.Join(statements, "")); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n--\n-- Export complete\n--\n")
+	return err
+}
+
+// exportTables reads the definition of every user table.
+func exportTables(ctx context.Context, tx *sql.Tx) ([]exportTable, error) {
+	var tables []exportTable
+	err := queryRows(ctx, tx, exportTablesQuery, nil, func(rows *sql.Rows) error {
+		var t exportTable
+		if err := rows.Scan(&t.OID, &t.Schema, &t.Name); err != nil {
+			return err
+		}
+		tables = append(tables, t)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	for i := range tables {
+		t := &tables[i]
+		err := queryRows(ctx, tx, exportColumnsQuery, []any{t.OID}, func(rows *sql.Rows) error {
+			var c exportColumn
+			if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &c.Default, &c.Identity, &c.Generated, &c.LastValue); err != nil {
+				return err
+			}
+			t.Columns = append(t.Columns, c)
+			return nil
+		})
+		if err == nil {
+			err = queryRows(ctx, tx, exportConstraintsQuery, []any{t.OID}, func(rows *sql.Rows) error {
+				var name, def string
+				var foreign bool
+				if err := rows.Scan(&name, &foreign, &def); err != nil {
+					return err
+				}
+				def = "CONSTRAINT " + quoteIdent(name) + " " + def
+				if foreign {
+					t.ForeignKeys = append(t.ForeignKeys, def)
+				} else {
+					t.Constraints = append(t.Constraints, def)
+				}
+				return nil
+			})
+		}
+		if err == nil {
+			err = queryRows(ctx, tx, exportIndexesQuery, []any{t.OID}, func(rows *sql.Rows) error {
+				var def string
+				if err := rows.Scan(&def); err != nil {
+					return err
+				}
+				t.Indexes = append(t.Indexes, def)
+				return nil
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read definition of %s: %w", t.qualified(), err)
+		}
+	}
+	return tables, nil
+}
+
+// exportRows writes the rows of t as a COPY block. Values are read as text,
+// which is what COPY loads them from.
+func exportRows(ctx context.Context, tx *sql.Tx, t *exportTable, w io.Writer) error {
+	cols := t.copyColumns()
+	if len(cols) == 0 {
+		return nil
+	}
+	selects := make([]string, len(cols))
+	for i, c := range cols {
+		selects[i] = c + "::text"
+	}
+
+	if _, err := fmt.Fprintf(w, "\nCOPY %s (%s) FROM stdin;\n", t.qualified(), strings.Join(cols, ", ")); err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	query := fmt.Sprintf("SELECT %s FROM ONLY %s", strings.Join(selects, ", "), t.qualified())
+	err := queryRows(ctx, tx, query, nil, func(rows *sql.Rows) error {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, copyRow(values))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\\.\n")
+	return err
+}
+
+// copyRow formats values as a line of COPY text format.
+func copyRow(values []sql.NullString) string {
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		if !v.Valid {
+			b.WriteString(`\N`)
+			continue
+		}
+		b.WriteString(copyEscaper.Replace(v.String))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// copyEscaper escapes the characters COPY text format gives a meaning.
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// quoteLiteral quotes a PostgreSQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// queryRows runs query in tx and calls fn for each row.
+func queryRows(ctx context.Context, tx *sql.Tx, query string, args []any, fn func(*sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// exportInfo reads the database information over a direct connection, for
+// images that lack psql as well as pg_dump.
+func (p *PostgresBackup) exportInfo(ctx context.Context) (*DatabaseInfo, error) {
+	db, err := sql.Open("postgres", p.connectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	info := &DatabaseInfo{}
+	var extensions string
+	err = db.QueryRowContext(ctx, `SELECT current_database(), pg_database_size(current_database()),
+		(SELECT coalesce(string_agg(extname, ',' ORDER BY extname), '') FROM pg_extension), version()`,
+	).Scan(&info.Name, &info.Size, &extensions, &info.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database info: %w", err)
+	}
+	info.Extensions = ParseExtensions(extensions)
+	return info, nil
+}
@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+)
+
+func TestCopyRow(t *testing.T) {
+	values := []sql.NullString{
+		{String: "plain", Valid: true},
+		{},
+		{String: "tab\there\nnew line\\back\rslash", Valid: true},
+		{String: "", Valid: true},
+	}
+	want := "plain\t\\N\ttab\\there\\nnew line\\\\back\\rslash\t\n"
+	if got := copyRow(values); got != want {
+		t.Errorf("copyRow() = %q, want %q", got, want)
+	}
+}
+
+func TestExportTable_CreateStatement(t *testing.T) {
+	table := &exportTable{
+		Schema: "public",
+		Name:   "Orders",
+		Columns: []exportColumn{
+			{Name: "id", Type: "bigint", NotNull: true, Identity: "a"},
+			{Name: "customer_id", Type: "integer", NotNull: true, Default: "nextval('public.customer_seq'::regclass)"},
+			{Name: "total", Type: "numeric(10,2)"},
+			{Name: "total_cents", Type: "bigint", Default: "(total * (100)::numeric)", Generated: "s"},
+		},
+	}
+
+	want := `CREATE TABLE "public"."Orders" (
+    "id" bigint GENERATED ALWAYS AS IDENTITY NOT NULL,
+    "customer_id" integer DEFAULT nextval('public.customer_seq'::regclass) NOT NULL,
+    "total" numeric(10,2),
+    "total_cents" bigint GENERATED ALWAYS AS ((total * (100)::numeric)) STORED
+);
+`
+	if got := table.createStatement(); got != want {
+		t.Errorf("createStatement() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := strings.Join(table.copyColumns(), ", "); got != `"id", "customer_id", "total"` {
+		t.Errorf("copyColumns() = %s, want generated columns skipped", got)
+	}
+}
+
+func TestPostgresBackup_ValidatePlain(t *testing.T) {
+	none, err := compression.Get("none", compression.DefaultLevel)
+	if err != nil {
+		t.Fatalf("Get(none) error = %v", err)
+	}
+	pb := &PostgresBackup{compressor: none}
+
+	if err := pb.Validate(context.Background(), strings.NewReader(exportHeader)); err != nil {
+		t.Errorf("Validate() fallback export error = %v", err)
+	}
+	if err := pb.Validate(context.Background(), strings.NewReader("not a dump")); err == nil {
+		t.Error("Validate() arbitrary text expected error, got nil")
+	}
+}
+
+func TestPostgresBackup_PGDumpMissing(t *testing.T) {
+	if pb := (&PostgresBackup{pgDumpBin: "pg_dump-missing-for-test"}); !pb.pgDumpMissing() {
+		t.Error("pgDumpMissing() = false for a binary that does not exist")
+	}
+	if pb := (&PostgresBackup{pgDumpBin: "sh"}); pb.pgDumpMissing() {
+		t.Error("pgDumpMissing() = true for a binary on PATH")
+	}
+}
+
+func TestPostgresBackup_OutputFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		pb   *PostgresBackup
+		want string
+	}{
+		{"pg_dump", &PostgresBackup{pgDumpBin: "sh"}, FormatTar},
+		{"pg_dump with options", &PostgresBackup{pgDumpBin: "sh", pgDumpOptions: []string{"--format=custom"}}, FormatCustom},
+		{"pg_dump missing without fallback", &PostgresBackup{pgDumpBin: "pg_dump-missing-for-test"}, FormatTar},
+		{"fallback export", &PostgresBackup{pgDumpBin: "pg_dump-missing-for-test", exportFallback: true, pgDumpOptions: []string{"-Fc"}}, FormatPlain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pb.OutputFormat(); got != tt.want {
+				t.Errorf("OutputFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
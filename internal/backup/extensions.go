@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MetadataKeyExtensions is the backup metadata key listing the database's extensions.
+const MetadataKeyExtensions = "database-extensions"
+
+// ParseExtensions splits a comma-separated extension list, dropping blanks.
+func ParseExtensions(list string) []string {
+	var extensions []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			extensions = append(extensions, name)
+		}
+	}
+	return extensions
+}
+
+// MissingExtensions returns the required extensions that are not available for
+// installation on the database server, sorted by name.
+func (p *PostgresBackup) MissingExtensions(ctx context.Context, required []string) ([]string, error) {
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	cmd := command(ctx, p.psqlBinary(),
+		"--no-psqlrc", "--no-password", "--tuples-only", "--no-align",
+		"--command", "SELECT name FROM pg_available_extensions",
+		p.connectionURL)
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available extensions: %w, stderr: %s", err, stderrText(&stderr))
+	}
+
+	available := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			available[name] = true
+		}
+	}
+
+	var missing []string
+	for _, name := range required {
+		if !available[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
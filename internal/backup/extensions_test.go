@@ -0,0 +1,60 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseExtensions(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{list: "", want: nil},
+		{list: "plpgsql", want: []string{"plpgsql"}},
+		{list: "pgcrypto, plpgsql,,postgis ", want: []string{"pgcrypto", "plpgsql", "postgis"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			if got := ParseExtensions(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseExtensions(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresBackup_MissingExtensions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// Fake psql that lists the extensions available on the server
+	script := filepath.Join(t.TempDir(), "psql")
+	content := "#!/bin/sh\nprintf 'plpgsql\\npgcrypto\\nuuid-ossp\\n'\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &PostgresBackup{connectionURL: "postgres://localhost/db", psqlBin: script}
+
+	missing, err := p.MissingExtensions(context.Background(), []string{"postgis", "plpgsql", "pgcrypto", "timescaledb"})
+	if err != nil {
+		t.Fatalf("MissingExtensions() error = %v", err)
+	}
+	if want := []string{"postgis", "timescaledb"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("MissingExtensions() = %v, want %v", missing, want)
+	}
+
+	missing, err = p.MissingExtensions(context.Background(), []string{"plpgsql"})
+	if err != nil {
+		t.Fatalf("MissingExtensions() error = %v", err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("MissingExtensions() = %v, want none", missing)
+	}
+}
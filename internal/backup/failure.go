@@ -0,0 +1,49 @@
+package backup
+
+import (
+	"context"
+	"errors"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// Phases a run can fail in before it records its checkpoints. Later failures
+// are attributed to the checkpointed phase (dumping, uploading, verifying).
+const (
+	PhasePreparing = "preparing" // Connecting, checking disk space, rendering the key
+	PhaseLocking   = "locking"   // Acquiring the run lock
+)
+
+// PhaseError is a failed run and the phase it failed in.
+type PhaseError struct {
+	Phase string
+	Err   error
+}
+
+func (e *PhaseError) Error() string { return e.Err.Error() }
+
+func (e *PhaseError) Unwrap() error { return e.Err }
+
+// FailedPhase returns the phase a run returning err failed in.
+func FailedPhase(err error) string {
+	var phased *PhaseError
+	if errors.As(err, &phased) {
+		return phased.Phase
+	}
+	return PhasePreparing
+}
+
+// recordFailure counts a failed run in the state object and returns the
+// number of consecutive failed runs, this one included. A successful upload
+// resets the count.
+func (o *Orchestrator) recordFailure(ctx context.Context) int {
+	state := &storage.State{}
+	if current, err := storage.ReadState(ctx, o.storage); err == nil {
+		state = current
+	}
+	state.ConsecutiveFailures++
+	if err := storage.WriteState(ctx, o.storage, *state); err != nil {
+		o.logger.Warn("Failed to record the failed run", "error", err)
+	}
+	return state.ConsecutiveFailures
+}
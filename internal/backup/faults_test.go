@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// TestOrchestrator_StorageFaults runs backups against a filesystem bucket that
+// fails a fifth of every storage operation, through the retry wrapper as in
+// production. Each run must either store a complete backup or fail on an
+// injected fault, with its outcome recorded and nothing partial left behind;
+// the partial upload of an earlier interrupted run must be cleaned up, and a
+// run must never be blocked by a lease left behind.
+func TestOrchestrator_StorageFaults(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	// A run killed during its upload left a temp file behind
+	interrupted := "2020/01/test-pg16-2020-01-01T00-00-00-000Z.tar"
+	leftover := filepath.Join(dir, path.Dir(interrupted), "."+path.Base(interrupted)+".tmp-1")
+	if err := os.MkdirAll(filepath.Dir(leftover), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(leftover, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := &storage.RunCheckpoint{Key: interrupted, Phase: storage.PhaseUploading, StartedAt: time.Now().Add(-time.Hour)}
+	if err := storage.WriteState(ctx, fs, storage.State{Run: run}); err != nil {
+		t.Fatalf("WriteState() error = %v", err)
+	}
+
+	faulty := storage.NewFaultyStorage(fs, storage.FaultConfig{ReadPercent: 20, UploadPercent: 20, DeletePercent: 20, Seed: 3}, logger)
+	store := storage.NewRetryableStorage(faulty, storage.RetryConfig{
+		MaxAttempts: 5, InitialDelay: time.Microsecond, MaxDelay: time.Microsecond, Multiplier: 1,
+	})
+	cfg := &config.Config{
+		StorageProvider:   "filesystem",
+		FilesystemPath:    dir,
+		BackupFilePrefix:  "test",
+		Compression:       "none",
+		RetentionDays:     7,
+		ForceBackup:       true,
+		MetricsHistory:    true,
+		BackupCatalog:     true,
+		RunLock:           true,
+		RunLockTTLSeconds: 60,
+	}
+
+	const runs = 25
+	const data = "backup data"
+	var stored []string
+	var failed int
+	for i := range runs {
+		result, err := NewOrchestrator(cfg, store, &mockBackup{dumpData: data}, logger).Execute(ctx)
+		// Runs failing before they took the lease leave the interrupted run
+		// for the next one to clean up
+		if state, err := storage.ReadState(ctx, fs); err == nil && state.Run != nil && state.Run.Key != interrupted && state.Run.Interrupted() {
+			t.Errorf("run %d left phase %s recorded, want its outcome", i, state.Run.Phase)
+		}
+		if err != nil {
+			if !errors.Is(err, storage.ErrInjectedFault) {
+				t.Errorf("run %d error = %v, want an injected fault", i, err)
+			}
+			failed++
+			continue
+		}
+		if result.Skipped {
+			t.Fatalf("run %d skipped: %s", i, result.Reason)
+		}
+
+		got, err := os.ReadFile(filepath.Join(dir, result.Key))
+		if err != nil || string(got) != data {
+			t.Errorf("run %d stored %q, %v; want the complete backup", i, got, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, result.Key+utils.ChecksumSuffix)); err != nil {
+			t.Errorf("run %d checksum sidecar missing: %v", i, err)
+		}
+		stored = append(stored, result.Key)
+	}
+
+	faults := faulty.Faults()
+	if len(stored) == 0 || failed == 0 || faults["upload"] == 0 || faults["open"] == 0 || faults["delete"] == 0 {
+		t.Fatalf("%d runs stored, %d failed, faults %v; want both outcomes under read, upload and delete faults",
+			len(stored), failed, faults)
+	}
+
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("interrupted run's partial upload still present: %v", err)
+	}
+	if _, _, err := fs.ReadVersion(ctx, storage.LeaseKey); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("run lock left behind: %v", err)
+	}
+	_ = filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && strings.Contains(d.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", p)
+		}
+		return nil
+	})
+
+	history, err := storage.ReadHistory(ctx, fs)
+	if err != nil {
+		t.Fatalf("ReadHistory() error = %v", err)
+	}
+	recorded := make(map[string]string)
+	for _, record := range history {
+		recorded[record.Key] = record.Status
+	}
+	for _, key := range stored {
+		if recorded[key] != HistorySuccess {
+			t.Errorf("history records %s as %q, want %s", key, recorded[key], HistorySuccess)
+		}
+	}
+
+	// A catalog update can still run out of retries; what a fault left out
+	// is repaired by reconciling against the bucket
+	if _, err := storage.ReconcileCatalog(ctx, fs, cfg.BackupFilePrefix, false); err != nil {
+		t.Fatalf("ReconcileCatalog() error = %v", err)
+	}
+	catalog, err := storage.ReadCatalog(ctx, fs)
+	if err != nil {
+		t.Fatalf("ReadCatalog() error = %v", err)
+	}
+	if len(catalog.Backups) != len(stored) {
+		t.Errorf("catalog holds %d backups after reconciling, want %d", len(catalog.Backups), len(stored))
+	}
+}
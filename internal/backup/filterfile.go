@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// minFilterFileVersion is the first pg_dump release that accepts --filter.
+const minFilterFileVersion = 17
+
+// pgDumpVersionPattern matches `pg_dump --version` output such as "pg_dump (PostgreSQL) 17.2".
+var pgDumpVersionPattern = regexp.MustCompile(`\(PostgreSQL\) (\d+)`)
+
+// FilterFileContent renders a dump filter in pg_dump's --filter file format,
+// one "include|exclude <object type> <pattern>" rule per line.
+func FilterFileContent(f *config.DumpFilter) string {
+	var b strings.Builder
+	b.WriteString("# Generated by railway-postgres-backup from dump_filter\n")
+
+	rules := []struct {
+		action, object string
+		patterns       []string
+	}{
+		{"include", "schema", f.IncludeSchemas},
+		{"exclude", "schema", f.ExcludeSchemas},
+		{"include", "table", f.IncludeTables},
+		{"exclude", "table", f.ExcludeTables},
+		{"exclude", "table_data", f.ExcludeTableData},
+		{"include", "extension", f.IncludeExtensions},
+		{"exclude", "extension", f.ExcludeExtensions},
+		{"include", "foreign_data", f.IncludeForeignData},
+	}
+	for _, rule := range rules {
+		for _, pattern := range rule.patterns {
+			fmt.Fprintf(&b, "%s %s %s\n", rule.action, rule.object, strings.TrimSpace(pattern))
+		}
+	}
+
+	return b.String()
+}
+
+// writeFilterFile writes the dump filter to a spool file that pg_dump reads via --filter.
+func (p *PostgresBackup) writeFilterFile(ctx context.Context) (*utils.SpoolFile, error) {
+	version, err := pgDumpMajorVersion(ctx, p.pgDumpBin)
+	if err != nil {
+		return nil, err
+	}
+	if version < minFilterFileVersion {
+		return nil, fmt.Errorf("dump_filter requires pg_dump %d or newer, %s is version %d",
+			minFilterFileVersion, p.pgDumpBin, version)
+	}
+
+	content := FilterFileContent(p.dumpFilter)
+	spool, err := utils.NewSpoolFile(utils.TempDir(p.tempDir), "pg_dump-filter-*", int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := spool.WriteString(content); err != nil {
+		_ = spool.Cleanup()
+		return nil, fmt.Errorf("failed to write filter file: %w", err)
+	}
+	if err := spool.Sync(); err != nil {
+		_ = spool.Cleanup()
+		return nil, fmt.Errorf("failed to write filter file: %w", err)
+	}
+
+	return spool, nil
+}
+
+// pgDumpMajorVersion returns the major version of a pg_dump binary.
+func pgDumpMajorVersion(ctx context.Context, bin string) (int, error) {
+	out, err := command(ctx, bin, "--version").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s version: %w", bin, err)
+	}
+	return parsePGDumpVersion(string(out))
+}
+
+// parsePGDumpVersion extracts the major version from `pg_dump --version` output.
+func parsePGDumpVersion(output string) (int, error) {
+	matches := pgDumpVersionPattern.FindStringSubmatch(output)
+	if len(matches) < 2 {
+		return 0, fmt.Errorf("could not parse pg_dump version from: %s", strings.TrimSpace(output))
+	}
+	return strconv.Atoi(matches[1])
+}
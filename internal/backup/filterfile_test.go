@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+func TestFilterFileContent(t *testing.T) {
+	f := &config.DumpFilter{
+		IncludeSchemas:     []string{"public"},
+		ExcludeTables:      []string{"public.audit_*", " public.tmp "},
+		ExcludeTableData:   []string{"public.events"},
+		ExcludeExtensions:  []string{"postgis"},
+		IncludeForeignData: []string{"remote"},
+	}
+
+	want := []string{
+		"include schema public",
+		"exclude table public.audit_*",
+		"exclude table public.tmp",
+		"exclude table_data public.events",
+		"exclude extension postgis",
+		"include foreign_data remote",
+	}
+
+	lines := strings.Split(strings.TrimSpace(FilterFileContent(f)), "\n")
+	if !strings.HasPrefix(lines[0], "#") {
+		t.Errorf("first line = %q, want a comment", lines[0])
+	}
+	if got := lines[1:]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("FilterFileContent() rules =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParsePGDumpVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    int
+		wantErr bool
+	}{
+		{output: "pg_dump (PostgreSQL) 17.2\n", want: 17},
+		{output: "pg_dump (PostgreSQL) 16.4 (Debian 16.4-1.pgdg120+1)", want: 16},
+		{output: "pg_dump 9000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			got, err := parsePGDumpVersion(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePGDumpVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePGDumpVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresBackup_DumpWithFilterFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// Fake pg_dump that reports a version and echoes the filter file it was given
+	fakePGDump := func(t *testing.T, version string) string {
+		script := filepath.Join(t.TempDir(), "pg_dump")
+		content := "#!/bin/sh\n" +
+			"if [ \"$1\" = \"--version\" ]; then echo \"pg_dump (PostgreSQL) " + version + "\"; exit 0; fi\n" +
+			"for arg; do case \"$arg\" in --filter=*) cat \"${arg#--filter=}\";; esac; done\n"
+		if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return script
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{name: "pg_dump 17 accepts filter files", version: "17.2"},
+		{name: "pg_dump 16 is rejected", version: "16.4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			pb := &PostgresBackup{
+				connectionURL: "postgres://localhost/test",
+				pgDumpBin:     fakePGDump(t, tt.version),
+				dumpFilter:    &config.DumpFilter{ExcludeTableData: []string{"public.events"}},
+				tempDir:       tempDir,
+				logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			reader, err := pb.Dump(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dump() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				t.Fatalf("gzip.NewReader() error = %v", err)
+			}
+			data, err := io.ReadAll(gz)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			_ = reader.Close()
+
+			if !strings.Contains(string(data), "exclude table_data public.events") {
+				t.Errorf("pg_dump did not receive the filter file, got %q", data)
+			}
+			if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+				t.Errorf("filter file was not removed, found %d entries", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package backup
+
+import (
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// ForeignFormat returns the archive format of a backup written by another
+// tool, judged from its extension, or "" when obj was written by this tool or
+// is not a recognised dump. Restore reads such backups like its own: the codec
+// follows the extension and the archive format the content.
+func ForeignFormat(obj storage.ObjectInfo) string {
+	return storage.ForeignDumpFormat(obj)
+}
+
+// StoredFormat returns the archive format of a stored backup: the one recorded
+// in its metadata, or the one ForeignFormat judges for a backup written by
+// another tool. It returns "" when neither tells.
+func StoredFormat(obj storage.ObjectInfo) string {
+	if format := obj.Metadata[MetadataKeyFormat]; format != "" {
+		return format
+	}
+	return ForeignFormat(obj)
+}
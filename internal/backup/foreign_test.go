@@ -0,0 +1,58 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestForeignFormat(t *testing.T) {
+	ours := map[string]string{"backup-tool": "railway-postgres-backup"}
+
+	tests := []struct {
+		name string
+		obj  storage.ObjectInfo
+		want string
+	}{
+		{"gzipped pg_dump script", storage.ObjectInfo{Key: "db/nightly-2024-01-01.sql.gz"}, FormatPlain},
+		{"plain pg_dump script", storage.ObjectInfo{Key: "dump.sql"}, FormatPlain},
+		{"custom archive", storage.ObjectInfo{Key: "db/app_20240101.dump"}, FormatCustom},
+		{"pgAdmin backup", storage.ObjectInfo{Key: "app.backup"}, FormatCustom},
+		{"our backup", storage.ObjectInfo{Key: "backup-pg16-2024-01-01T00-00-00-000Z.tar.gz"}, ""},
+		{"our uncompressed custom backup", storage.ObjectInfo{Key: "backup-pg16-2024-01-01T00-00-00-000Z.dump"}, ""},
+		{"our templated backup", storage.ObjectInfo{Key: "2024/01/01/app.dump", Metadata: ours}, ""},
+		{"sidecar", storage.ObjectInfo{Key: "dump.sql.sha256"}, ""},
+		{"unrelated object", storage.ObjectInfo{Key: "notes.txt"}, ""},
+		{"reserved object", storage.ObjectInfo{Key: storage.StateKey}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ForeignFormat(tt.obj); got != tt.want {
+				t.Errorf("ForeignFormat(%q) = %q, want %q", tt.obj.Key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  storage.ObjectInfo
+		want string
+	}{
+		{"fallback export", storage.ObjectInfo{Key: "backup-pg16-2024-01-01T00-00-00-000Z.sql.gz", Metadata: map[string]string{
+			"backup-tool": "railway-postgres-backup", MetadataKeyFormat: FormatPlain,
+		}}, FormatPlain},
+		{"pg_dump script", storage.ObjectInfo{Key: "dump.sql"}, FormatPlain},
+		{"our backup without a recorded format", storage.ObjectInfo{Key: "backup-pg16-2024-01-01T00-00-00-000Z.tar.gz"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StoredFormat(tt.obj); got != tt.want {
+				t.Errorf("StoredFormat(%q) = %q, want %q", tt.obj.Key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// Run outcomes recorded in the history.
+const (
+	HistorySuccess = "success"
+	HistoryFailed  = "failed"
+)
+
+// appendHistory records the run in the history object. The record is written
+// even when the run was cancelled, and a failure to write it is only logged.
+func (o *Orchestrator) appendHistory(ctx context.Context, key, database, compression string, start time.Time, result *Result, runErr error) {
+	record := storage.HistoryRecord{
+		Time:            start.UTC(),
+		Key:             key,
+		Database:        database,
+		Status:          HistorySuccess,
+		Compression:     compression,
+		Label:           o.config.BackupLabel,
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	if runErr != nil {
+		record.Status = HistoryFailed
+		record.Error = runErr.Error()
+	}
+	if result != nil {
+		record.SizeBytes = result.Size
+		record.UploadSeconds = result.Upload.Seconds()
+		if result.Upload > 0 {
+			record.BytesPerSecond = float64(result.Size) / result.Upload.Seconds()
+		}
+	}
+
+	if err := storage.AppendHistory(context.WithoutCancel(ctx), o.storage, record); err != nil {
+		o.logger.Warn("Failed to append run history", "error", err)
+	}
+}
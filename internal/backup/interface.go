@@ -18,9 +18,76 @@ type Backup interface {
 	GetInfo(ctx context.Context) (*DatabaseInfo, error)
 }
 
+// Querier is implemented by backups that can evaluate scalar SQL queries
+// against the database, as used by preconditions.
+type Querier interface {
+	// QueryValue runs a query returning a single number.
+	QueryValue(ctx context.Context, query string) (float64, error)
+}
+
+// SettingsReader is implemented by backups that can snapshot the server's
+// configuration for drift detection.
+type SettingsReader interface {
+	// Settings returns the settings that differ from their built-in defaults.
+	Settings(ctx context.Context) (map[string]string, error)
+}
+
+// TableLister is implemented by backups that can list the database's tables
+// for the backup manifest.
+type TableLister interface {
+	// Tables returns the user tables with their estimated row counts.
+	Tables(ctx context.Context) ([]TableEstimate, error)
+}
+
+// ScratchRestorer is implemented by backups that can prove a backup restores by
+// loading it into a scratch database.
+type ScratchRestorer interface {
+	// RestoreScratch restores a decompressed backup into targetURL, or into a
+	// database created on the source server and dropped afterwards when
+	// targetURL is empty, and reports what was loaded. A data-only backup is
+	// restored onto schema, which is nil for complete backups.
+	RestoreScratch(ctx context.Context, reader, schema io.Reader, targetURL string) (*RestoreCheck, error)
+}
+
+// SchemaDumper is implemented by backups that can dump the database's schema
+// on its own, so SCHEMA_DEDUP can store it apart from data-only backups.
+type SchemaDumper interface {
+	// DumpSchema returns the schema as a plain SQL script.
+	DumpSchema(ctx context.Context) ([]byte, error)
+}
+
+// DumpOptionChecker is implemented by backups that can check PG_DUMP_OPTIONS
+// against the selected pg_dump before dumping.
+type DumpOptionChecker interface {
+	// CheckDumpOptions returns an error naming an option pg_dump does not accept.
+	CheckDumpOptions(ctx context.Context) error
+}
+
+// FilterConditionChecker is implemented by backups that can check the WHERE
+// conditions of table row filters against the database before dumping.
+type FilterConditionChecker interface {
+	// CheckFilterConditions returns an error naming a filter whose condition is not valid SQL.
+	CheckFilterConditions(ctx context.Context) error
+}
+
+// FormatReporter is implemented by backups whose output format can differ from
+// the one PG_DUMP_OPTIONS selects, such as the plain SQL fallback export.
+type FormatReporter interface {
+	// OutputFormat returns the format Dump writes: FormatPlain, FormatCustom or FormatTar.
+	OutputFormat() string
+}
+
+// BinaryResolver is implemented by backups that run client binaries, so a dry
+// run can report which ones were chosen for the server version.
+type BinaryResolver interface {
+	// Binaries returns the binary selected for each client tool, by tool name.
+	Binaries() map[string]string
+}
+
 // DatabaseInfo contains information about the database.
 type DatabaseInfo struct {
-	Name    string
-	Size    int64
-	Version string
+	Name       string
+	Size       int64
+	Version    string
+	Extensions []string // Installed extensions, sorted by name
 }
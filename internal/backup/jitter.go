@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// waitJitter waits a random time within BACKUP_JITTER before the dump, so
+// services whose schedules fire together spread their load on shared
+// databases and storage endpoints. It returns early with the context's error
+// when the run is cancelled.
+func (o *Orchestrator) waitJitter(ctx context.Context) error {
+	lo, hi := o.config.GetBackupJitter()
+	if hi <= 0 {
+		return nil
+	}
+	delay := lo
+	if hi > lo {
+		delay += rand.N(hi - lo + 1)
+	}
+	o.logger.Info("Delaying backup by jitter", "delay", delay.Round(time.Millisecond), "jitter", o.config.BackupJitter)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
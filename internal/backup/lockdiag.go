@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// LockContentionError wraps a dump failure with the sessions holding conflicting locks.
+type LockContentionError struct {
+	Err      error
+	Sessions []utils.BlockingSession
+}
+
+// Error implements error.
+func (e *LockContentionError) Error() string {
+	return fmt.Sprintf("%v; blocking sessions: %s", e.Err, FormatBlockingSessions(e.Sessions))
+}
+
+// Unwrap returns the underlying dump error.
+func (e *LockContentionError) Unwrap() error {
+	return e.Err
+}
+
+// lockErrorMarkers are pg_dump stderr fragments that indicate lock contention.
+var lockErrorMarkers = []string{
+	"lock timeout",
+	"could not obtain lock",
+	"deadlock detected",
+	"SQLSTATE 55P03",
+}
+
+// isLockError checks whether pg_dump stderr indicates a lock-related failure.
+func isLockError(stderr string) bool {
+	for _, marker := range lockErrorMarkers {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// findBlockingSessions queries the database for sessions that can block pg_dump.
+// Diagnostics are best-effort: failures are logged and an empty result is returned.
+func (p *PostgresBackup) findBlockingSessions(ctx context.Context) []utils.BlockingSession {
+	if !p.lockDiag {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Single attempt: the database is up, pg_dump just could not get its locks
+	pool, err := utils.NewConnectionPoolWithRetry(ctx, p.connectionURL, utils.RetryConfig{MaxRetries: 0})
+	if err != nil {
+		p.logger.Warn("Failed to connect for lock diagnostics", "error", err)
+		return nil
+	}
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	sessions, err := pool.GetBlockingSessions(ctx)
+	if err != nil {
+		p.logger.Warn("Failed to query lock diagnostics", "error", err)
+		return nil
+	}
+
+	for _, s := range sessions {
+		p.logger.Error("Session holding lock that blocks pg_dump",
+			"pid", s.PID,
+			"user", s.User,
+			"application", s.ApplicationName,
+			"state", s.State,
+			"lock_mode", s.LockMode,
+			"relation", s.Relation,
+			"transaction_age", s.TransactionAge,
+			"query", s.Query,
+		)
+	}
+
+	return sessions
+}
+
+// withBlockingSessions attaches blocking session details to a dump error.
+func withBlockingSessions(err error, sessions []utils.BlockingSession) error {
+	if len(sessions) == 0 {
+		return err
+	}
+	return &LockContentionError{Err: err, Sessions: sessions}
+}
+
+// FormatBlockingSessions renders blocking sessions as a compact, human-readable list.
+func FormatBlockingSessions(sessions []utils.BlockingSession) string {
+	if len(sessions) == 0 {
+		return "none found"
+	}
+
+	parts := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		desc := fmt.Sprintf("pid=%d user=%s state=%s lock=%s", s.PID, s.User, s.State, s.LockMode)
+		if s.Relation != "" {
+			desc += " relation=" + s.Relation
+		}
+		if s.TransactionAge > 0 {
+			desc += " xact_age=" + s.TransactionAge.String()
+		}
+		if s.Query != "" {
+			desc += fmt.Sprintf(" query=%q", s.Query)
+		}
+		parts = append(parts, desc+fmt.Sprintf(" (terminate with: SELECT pg_terminate_backend(%d))", s.PID))
+	}
+	return strings.Join(parts, "; ")
+}
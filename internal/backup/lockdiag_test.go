@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+func TestIsLockError(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{
+			name:   "lock timeout",
+			stderr: `pg_dump: error: query failed: ERROR:  canceling statement due to lock timeout`,
+			want:   true,
+		},
+		{
+			name:   "could not obtain lock",
+			stderr: `pg_dump: error: could not obtain lock on relation "public.orders"`,
+			want:   true,
+		},
+		{
+			name:   "deadlock",
+			stderr: "ERROR:  deadlock detected",
+			want:   true,
+		},
+		{
+			name:   "connection refused",
+			stderr: "pg_dump: error: connection to server failed: Connection refused",
+			want:   false,
+		},
+		{
+			name:   "empty",
+			stderr: "",
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLockError(tt.stderr); got != tt.want {
+				t.Errorf("isLockError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockContentionError(t *testing.T) {
+	base := errors.New("pg_dump failed")
+	sessions := []utils.BlockingSession{
+		{
+			PID:            4242,
+			User:           "app",
+			State:          "idle in transaction",
+			LockMode:       "AccessExclusiveLock",
+			Relation:       "public.orders",
+			TransactionAge: 90 * time.Second,
+			Query:          "ALTER TABLE orders ADD COLUMN note text",
+		},
+	}
+
+	err := withBlockingSessions(base, sessions)
+
+	var lockErr *LockContentionError
+	if !errors.As(err, &lockErr) {
+		t.Fatalf("expected LockContentionError, got %T", err)
+	}
+	if !errors.Is(err, base) {
+		t.Error("LockContentionError should unwrap to the original error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{"pid=4242", "relation=public.orders", "AccessExclusiveLock", "xact_age=1m30s", "pg_terminate_backend(4242)"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error message %q missing %q", msg, want)
+		}
+	}
+
+	if got := withBlockingSessions(base, nil); got != base {
+		t.Errorf("withBlockingSessions() with no sessions = %v, want original error", got)
+	}
+}
@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// tablesQuery lists the user tables with the planner's row estimates, which
+// cost nothing to read. The estimate is -1 for tables never analyzed on
+// PostgreSQL 14 and later.
+const tablesQuery = `SELECT n.nspname, c.relname, c.reltuples::bigint
+	FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p') AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	AND n.nspname NOT LIKE 'pg\_toast%'
+	ORDER BY 1, 2`
+
+// Manifest describes the content of a backup without downloading it, and is
+// stored next to the backup.
+type Manifest struct {
+	Key             string           `json:"key"`
+	CreatedAt       time.Time        `json:"created_at"`
+	Database        ManifestDatabase `json:"database"`
+	Tables          []TableEstimate  `json:"tables,omitempty"`
+	PGDumpOptions   string           `json:"pg_dump_options,omitempty"`
+	Compression     string           `json:"compression"`
+	SizeBytes       int64            `json:"size_bytes"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	SHA256          string           `json:"sha256"`
+}
+
+// ManifestDatabase identifies the database a backup was taken from.
+type ManifestDatabase struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// TableEstimate is a user table and its estimated row count when the dump started.
+type TableEstimate struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows_estimate"`
+}
+
+// Tables implements TableLister.
+func (p *PostgresBackup) Tables(ctx context.Context) ([]TableEstimate, error) {
+	cmd := command(ctx, p.psqlBinary(),
+		"--no-psqlrc", "--no-password", "--tuples-only", "--no-align",
+		"--field-separator=\t",
+		"--command", tablesQuery,
+		p.connectionURL)
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w, stderr: %s", err, stderrText(&stderr))
+	}
+	return parseTables(string(output)), nil
+}
+
+// parseTables parses tab-separated schema/table/estimate rows.
+func parseTables(output string) []TableEstimate {
+	tables := []TableEstimate{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		rows, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		tables = append(tables, TableEstimate{Schema: fields[0], Name: fields[1], Rows: rows})
+	}
+	return tables
+}
+
+// listTables lists the source tables before the dump for the manifest, or
+// returns nil when the backup cannot list them.
+func (o *Orchestrator) listTables(ctx context.Context) []TableEstimate {
+	lister, ok := o.backup.(TableLister)
+	if !ok {
+		return nil
+	}
+	tables, err := lister.Tables(ctx)
+	if err != nil {
+		o.logger.Warn("Failed to list tables for the backup manifest", "error", err)
+		return nil
+	}
+	return tables
+}
+
+// writeManifest stores the manifest next to the backup it describes. Failures
+// are logged and never fail the backup.
+func (o *Orchestrator) writeManifest(ctx context.Context, manifest *Manifest) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		o.logger.Warn("Failed to encode backup manifest", "error", err)
+		return
+	}
+	if err := o.storage.Upload(ctx, manifest.Key+utils.ManifestSuffix, bytes.NewReader(data), map[string]string{
+		"backup-tool": "railway-postgres-backup",
+	}); err != nil {
+		o.logger.Warn("Failed to store backup manifest", "key", manifest.Key, "error", err)
+	}
+}
+
+// ReadManifest loads the manifest stored next to the backup at key.
+func ReadManifest(ctx context.Context, store storage.Storage, key string) (*Manifest, error) {
+	r, err := store.Open(ctx, key+utils.ManifestSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest for %s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// EstimatedRows returns the sum of the row estimates, counting unanalyzed tables as empty.
+func (m *Manifest) EstimatedRows() int64 {
+	var rows int64
+	for _, t := range m.Tables {
+		rows += max(t.Rows, 0)
+	}
+	return rows
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// tablesBackup is a mockBackup that also lists tables.
+type tablesBackup struct {
+	mockBackup
+	tables []TableEstimate
+}
+
+func (m *tablesBackup) Tables(ctx context.Context) ([]TableEstimate, error) {
+	return m.tables, nil
+}
+
+func TestParseTables(t *testing.T) {
+	got := parseTables("public\tusers\t1200\npublic\tevents\t-1\n\naudit\tlog\tnot a number\n")
+	want := []TableEstimate{
+		{Schema: "public", Name: "users", Rows: 1200},
+		{Schema: "public", Name: "events", Rows: -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTables() = %v, want %v", got, want)
+	}
+}
+
+func TestOrchestrator_WritesManifest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tables := []TableEstimate{{Schema: "public", Name: "users", Rows: 1200}, {Schema: "public", Name: "events", Rows: -1}}
+	backup := &tablesBackup{
+		mockBackup: mockBackup{
+			dumpData: "backup data",
+			info:     &DatabaseInfo{Name: "app", Version: "PostgreSQL 16.2", Size: 4096},
+		},
+		tables: tables,
+	}
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		Compression:      "gzip",
+		PGDumpOptions:    "--no-owner",
+	}
+	store := &mockStorage{objects: map[string][]byte{}}
+
+	result, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, ok := store.objects[result.Key+utils.ManifestSuffix]; !ok {
+		t.Fatalf("manifest for %s not stored", result.Key)
+	}
+
+	manifest, err := ReadManifest(context.Background(), store, result.Key)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	want := ManifestDatabase{Name: "app", Version: "PostgreSQL 16.2", SizeBytes: 4096}
+	if manifest.Key != result.Key || manifest.Database != want {
+		t.Errorf("manifest = %+v, want key %s and database %+v", manifest, result.Key, want)
+	}
+	if !reflect.DeepEqual(manifest.Tables, tables) || manifest.EstimatedRows() != 1200 {
+		t.Errorf("manifest tables = %v, want %v", manifest.Tables, tables)
+	}
+	if manifest.PGDumpOptions != "--no-owner" || manifest.Compression != "gzip" {
+		t.Errorf("manifest options = %q, %q", manifest.PGDumpOptions, manifest.Compression)
+	}
+	if manifest.SizeBytes != result.Size || manifest.SHA256 != result.SHA256 {
+		t.Errorf("manifest size/checksum = %d/%s, want %d/%s", manifest.SizeBytes, manifest.SHA256, result.Size, result.SHA256)
+	}
+}
@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"context"
+
+	"github.com/imedwei/railway-postgres-backup/internal/notify"
+)
+
+// notify sends the summary of a finished run to the configured notifiers,
+// unless NOTIFY_ON limits them to failures. result may be nil or partial when
+// the run failed.
+func (o *Orchestrator) notify(ctx context.Context, result *Result, err error) {
+	if len(o.notifiers) == 0 || (err == nil && o.config.NotifyOn == notify.OnFailure) {
+		return
+	}
+	notify.Send(ctx, o.notifiers, o.summary(result, err), o.logger)
+}
+
+// healthcheckStart pings HEALTHCHECK_URL's /start as a run begins.
+func (o *Orchestrator) healthcheckStart(ctx context.Context) {
+	if o.healthcheck == nil {
+		return
+	}
+	if err := o.healthcheck.Start(ctx); err != nil {
+		o.logger.Warn("Failed to ping healthcheck", "error", err)
+	}
+}
+
+// healthcheckEnd pings HEALTHCHECK_URL as a run ends. Skipped runs and, unlike
+// notifications, successful ones under NOTIFY_ON=failure are reported too, as
+// the check alerts when the pings stop.
+func (o *Orchestrator) healthcheckEnd(ctx context.Context, result *Result, err error) {
+	if o.healthcheck == nil {
+		return
+	}
+	notify.Send(ctx, []notify.Notifier{o.healthcheck}, o.summary(result, err), o.logger)
+}
+
+// summary describes a run for notifications.
+func (o *Orchestrator) summary(result *Result, err error) notify.Summary {
+	s := notify.Summary{Status: notify.StatusSuccess}
+	if result != nil {
+		s.Database = result.Database
+		s.DatabaseVersion = result.DatabaseVersion
+		s.Key = result.Key
+		s.Bytes = result.Size
+		s.DumpSeconds = result.Dump.Seconds()
+		s.UploadSeconds = result.Upload.Seconds()
+		s.DurationSeconds = result.Duration.Seconds()
+	}
+	if err != nil {
+		s.Status = notify.StatusFailure
+		s.Phase = FailedPhase(err)
+		s.Error = err.Error()
+	}
+	// A run that failed before reaching the database is named by its URL
+	if s.Database == "" {
+		s.Database, _ = DatabaseName(o.config.DatabaseURL)
+	}
+	return s
+}
@@ -2,24 +2,43 @@ package backup
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
 	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
 	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/notify"
 	"github.com/imedwei/railway-postgres-backup/internal/ratelimit"
 	"github.com/imedwei/railway-postgres-backup/internal/storage"
 	"github.com/imedwei/railway-postgres-backup/internal/utils"
 )
 
+// Backup metadata keys describing the run that took a backup.
+const (
+	MetadataKeyLabel  = "backup-label" // The run's BACKUP_LABEL
+	MetadataKeyKeep   = "backup-keep"  // "true" when retention must never delete the backup
+	MetadataKeyFormat = "dump-format"  // Archive format of the dump: plain, custom or tar
+)
+
 // Orchestrator coordinates the backup process.
 type Orchestrator struct {
 	config      *config.Config
 	storage     storage.Storage
 	backup      Backup
 	rateLimiter ratelimit.RateLimiter
+	hooks       *hooks.Registry
+	notifiers   []notify.Notifier
+	healthcheck *notify.Healthcheck
 	logger      *slog.Logger
 }
 
@@ -32,39 +51,212 @@ func NewOrchestrator(cfg *config.Config, storage storage.Storage, backup Backup,
 	}
 	rateLimiter := ratelimit.NewTimeBasedLimiter(rlConfig)
 
+	// External hooks configured through the environment
+	registry := hooks.NewRegistry(logger)
+	if cfg.HookCommand != "" {
+		registry.Register(hooks.Only(hooks.NewExec(cfg.HookCommand, cfg.GetHookTimeout()), cfg.GetHookEvents()))
+	}
+	if cfg.HookURL != "" {
+		registry.Register(hooks.Only(hooks.NewHTTP(cfg.HookURL, cfg.GetHookTimeout()), cfg.GetHookEvents()))
+	}
+	if cfg.OnFailureCommand != "" {
+		registry.Register(hooks.Only(hooks.NewExec(cfg.OnFailureCommand, cfg.GetHookTimeout()), []string{hooks.EventFailure}))
+	}
+	if cfg.OnFailureURL != "" {
+		registry.Register(hooks.Only(hooks.NewHTTP(cfg.OnFailureURL, cfg.GetHookTimeout()), []string{hooks.EventFailure}))
+	}
+
+	// Run summaries delivered after every run
+	var notifiers []notify.Notifier
+	if cfg.NotifyWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhook(cfg.NotifyWebhookURL, cfg.GetNotifyTimeout(), cfg.NotifyMaxAttempts))
+	}
+	if cfg.DiscordWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewDiscord(cfg.DiscordWebhookURL, cfg.GetNotifyTimeout(), cfg.NotifyMaxAttempts))
+	}
+	if cfg.TelegramBotToken != "" {
+		notifiers = append(notifiers, notify.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.GetNotifyTimeout(), cfg.NotifyMaxAttempts))
+	}
+	var healthcheck *notify.Healthcheck
+	if cfg.HealthcheckURL != "" {
+		healthcheck = notify.NewHealthcheck(cfg.HealthcheckURL, cfg.GetNotifyTimeout(), cfg.NotifyMaxAttempts)
+	}
+
 	return &Orchestrator{
 		config:      cfg,
 		storage:     storage,
 		backup:      backup,
 		rateLimiter: rateLimiter,
+		hooks:       registry,
+		notifiers:   notifiers,
+		healthcheck: healthcheck,
 		logger:      logger,
 	}
 }
 
+// RegisterHook adds a hook that receives the events of every run.
+func (o *Orchestrator) RegisterHook(h hooks.Hook) {
+	o.hooks.Register(h)
+}
+
+// Result describes the outcome of a backup run.
+type Result struct {
+	Key       string        // Storage key of the uploaded backup
+	Size      int64         // Bytes uploaded
+	Timestamp time.Time     // Time the backup was taken
+	Dump      time.Duration // Time until pg_dump finished writing
+	Upload    time.Duration // Time spent streaming the dump to storage
+	Duration  time.Duration // Time the whole run took
+	SHA256    string        // Hex SHA-256 of the uploaded backup
+	Pruned    []string      // Keys deleted by retention, sidecars included
+	Commands  []string      // pg_dump, psql and pg_restore command lines run, credentials redacted
+	Skipped   bool          // True when respawn protection or a precondition skipped the run
+	Paused    bool          // True when the run was skipped because backups are paused
+	Reason    string        // Why the run was skipped
+
+	// Database and DatabaseVersion describe the database backed up
+	Database        string
+	DatabaseVersion string
+
+	// SettingsDrift lists server settings changed since the previous backup
+	SettingsDrift []SettingChange
+
+	// Recovery is the time the backup took to restore, set when it was
+	// verified by restoring it
+	Recovery *storage.RecoveryTime
+
+	// Plan is what the run would have done, set by dry runs only
+	Plan *DryRunPlan
+}
+
 // Run executes the backup process.
 func (o *Orchestrator) Run(ctx context.Context) error {
+	_, err := o.Execute(ctx)
+	return err
+}
+
+// Execute runs the backup process and reports what was uploaded. With
+// DRY_RUN, it only reports what the run would do.
+func (o *Orchestrator) Execute(ctx context.Context) (*Result, error) {
+	if o.config.DryRun {
+		return o.dryRun(ctx)
+	}
+
+	// A paused run is skipped before it takes the run lock
+	if reason := o.pauseReason(ctx); reason != "" {
+		o.logger.Info("Skipping backup", "reason", reason)
+		metrics.RateLimitBlocked.WithLabelValues(ratelimit.SkipManualHold).Inc()
+		return &Result{Skipped: true, Paused: true, Reason: reason}, nil
+	}
+
+	o.healthcheckStart(ctx)
+	result, err := o.executeLocked(ctx)
+	err = utils.RedactError(err) // Hooks, notifications and callers never see credentials
+	if err != nil {
+		// Deliver the failure even when the run was cancelled
+		ctx := context.WithoutCancel(ctx)
+		o.hooks.Fire(ctx, hooks.Event{
+			Type:     hooks.EventFailure,
+			Error:    err.Error(),
+			Phase:    FailedPhase(err),
+			Attempts: o.recordFailure(ctx),
+		})
+	}
+	if err != nil || (result != nil && !result.Skipped) {
+		o.notify(context.WithoutCancel(ctx), result, err)
+	}
+	o.healthcheckEnd(context.WithoutCancel(ctx), result, err)
+	return result, err
+}
+
+// executeLocked runs the backup while holding the run lock, when enabled.
+// A run that finds the lock held by another instance is skipped.
+func (o *Orchestrator) executeLocked(ctx context.Context) (*Result, error) {
+	if !o.config.RunLock {
+		return o.execute(ctx)
+	}
+
+	store, ok := storage.AsConditional(o.storage)
+	if !ok {
+		return nil, &PhaseError{Phase: PhaseLocking, Err: fmt.Errorf("RUN_LOCK is not supported by storage provider %s", o.config.StorageProvider)}
+	}
+
+	lease, err := storage.AcquireLease(ctx, store, leaseHolder(), o.config.GetRunLockTTL(), o.logger)
+	var held *storage.LeaseHeldError
+	if errors.As(err, &held) {
+		o.logger.Info("Skipping backup, another instance holds the run lock",
+			"holder", held.Holder, "expires_at", held.ExpiresAt)
+		return &Result{Skipped: true, Reason: held.Error()}, nil
+	}
+	if err != nil {
+		return nil, &PhaseError{Phase: PhaseLocking, Err: fmt.Errorf("failed to acquire run lock: %w", err)}
+	}
+	o.logger.Info("Acquired run lock", "ttl", o.config.GetRunLockTTL())
+
+	defer func() {
+		// Release even when the run was cancelled, so the next run need not wait for expiry
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), leaseReleaseTimeout)
+		defer cancel()
+		if err := lease.Release(releaseCtx); err != nil {
+			o.logger.Warn("Failed to release run lock", "error", err)
+		}
+	}()
+
+	result, err := o.execute(lease.Context())
+	if err != nil && errors.Is(context.Cause(lease.Context()), storage.ErrLeaseLost) {
+		return nil, fmt.Errorf("run lock lost during backup: %w", err)
+	}
+	return result, err
+}
+
+// leaseReleaseTimeout bounds the request deleting the run lock.
+const leaseReleaseTimeout = 30 * time.Second
+
+// leaseHolder identifies this process in the run lock.
+func leaseHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+func (o *Orchestrator) execute(ctx context.Context) (result *Result, err error) {
 	startTime := time.Now()
 	o.logger.Info("Starting backup orchestration")
-
-	// Initialize metrics
-	metrics.Info.WithLabelValues("1.0.0", o.config.StorageProvider).Set(1)
+	ctx, commands := withCommandLog(ctx)
 
 	// Check respawn protection
-	lastBackupTime, err := o.storage.GetLastBackupTime(ctx)
+	lastBackupTime, err := storage.LastBackupTime(ctx, o.storage)
 	if err != nil {
 		o.logger.Warn("Failed to get last backup time, proceeding with backup", "error", err)
 		// Continue with backup if we can't determine last backup time
 	} else {
-		shouldBackup, reason := o.rateLimiter.ShouldBackup(lastBackupTime)
-		o.logger.Info("Rate limiter decision", "should_backup", shouldBackup, "reason", reason)
+		decision := o.rateLimiter.Decide(lastBackupTime)
+		o.logger.Info("Rate limiter decision", "should_backup", decision.Allowed, "reason", decision.Reason)
+		if !decision.NextAllowed.IsZero() {
+			metrics.NextBackupAllowed.WithLabelValues(o.config.BackupFilePrefix).Set(float64(decision.NextAllowed.Unix()))
+		}
 
-		if !shouldBackup {
-			o.logger.Info("Skipping backup due to rate limiting", "reason", reason)
-			metrics.RateLimitBlocked.Inc()
-			return nil
+		if !decision.Allowed {
+			o.logger.Info("Skipping backup due to rate limiting", "reason", decision.Reason, "skip_reason", decision.SkipReason)
+			metrics.RateLimitBlocked.WithLabelValues(decision.SkipReason).Inc()
+			return &Result{Skipped: true, Reason: decision.Reason}, nil
 		}
 	}
 
+	// Spread runs sharing a schedule; the wait is not part of the run's duration
+	if err := o.waitJitter(ctx); err != nil {
+		return nil, err
+	}
+	startTime = time.Now()
+
+	// Skip the run when the database is in a state where a dump would be harmful
+	if reason := o.checkPreconditions(ctx); reason != "" {
+		o.logger.Warn("Skipping backup", "reason", reason)
+		return &Result{Skipped: true, Reason: reason}, nil
+	}
+
 	// Get database info
 	info, err := o.backup.GetInfo(ctx)
 	if err != nil {
@@ -77,19 +269,89 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 			"size_bytes", info.Size,
 			"version", info.Version,
 		)
-		metrics.DatabaseSize.Set(float64(info.Size))
+		metrics.DatabaseSize.WithLabelValues(o.config.BackupFilePrefix).Set(float64(info.Size))
+	}
+
+	// List the tables before the dump for the manifest
+	tables := o.listTables(ctx)
+
+	// Count the tables before the dump so restore verification can compare them
+	var sourceTables int64
+	if o.config.VerifyRestore {
+		sourceTables = o.sourceTables(ctx)
+	}
+
+	// Fail fast if a local destination cannot hold the dump
+	if err := o.checkDiskSpace(info); err != nil {
+		metrics.RecordBackupAttempt(false)
+		return nil, err
+	}
+
+	// Fail fast on options the selected pg_dump would reject mid-run
+	if checker, ok := o.backup.(DumpOptionChecker); ok {
+		if err := checker.CheckDumpOptions(ctx); err != nil {
+			metrics.RecordBackupAttempt(false)
+			return nil, fmt.Errorf("invalid PG_DUMP_OPTIONS: %w", err)
+		}
+	}
+	if checker, ok := o.backup.(FilterConditionChecker); ok {
+		if err := checker.CheckFilterConditions(ctx); err != nil {
+			metrics.RecordBackupAttempt(false)
+			return nil, fmt.Errorf("invalid table_filters: %w", err)
+		}
 	}
 
 	// Generate backup filename and key
 	timestamp := time.Now()
-	filename := utils.GenerateBackupFilename(o.config.BackupFilePrefix, timestamp, info.Version)
-
-	// Create storage key with year/month directory structure
-	storageKey := fmt.Sprintf("%d/%02d/%s", timestamp.Year(), timestamp.Month(), filename)
+	fields, storageKey, compressor, err := o.backupKey(timestamp, info)
+	if err != nil {
+		metrics.RecordBackupAttempt(false)
+		return nil, err
+	}
+	filename := fields.Filename
 
 	o.logger.Info("Generated backup filename", "filename", filename, "storage_key", storageKey)
+	o.hooks.Fire(ctx, hooks.Event{Type: hooks.EventRunStart, Database: info.Name, Key: storageKey})
+
+	// Checkpoint each phase from here on, and index the backup once the run's outcome is known
+	run := o.startRun(ctx, storageKey)
+	var catalogued *storage.CatalogEntry
+	defer func() {
+		if result != nil {
+			result.Duration = time.Since(startTime)
+			result.Commands = commands.list()
+		}
+		if err != nil {
+			// The error is recorded in state, history and the catalog from here on
+			err = utils.RedactError(&PhaseError{Phase: run.state.Run.Phase, Err: err})
+		}
+		run.state.Run.Commands = commands.list()
+		run.finish(ctx, err)
+		if o.config.MetricsHistory {
+			o.appendHistory(ctx, storageKey, info.Name, compressor.Name(), startTime, result, err)
+		}
+		if o.config.BackupCatalog && catalogued != nil {
+			o.updateCatalog(ctx, catalogued, result, err)
+		}
+	}()
 
 	// Create backup
+	run.enter(ctx, storage.PhaseDumping)
+
+	// The schema is stored apart from the data-only dump, only when it changed
+	var schemaKey string
+	if o.config.SchemaDedup {
+		dumper, ok := o.backup.(SchemaDumper)
+		if !ok {
+			metrics.RecordBackupAttempt(false)
+			return nil, errors.New("SCHEMA_DEDUP is not supported by the backup provider")
+		}
+		if schemaKey, err = o.storeSchema(ctx, dumper, compressor); err != nil {
+			metrics.RecordBackupAttempt(false)
+			return nil, fmt.Errorf("failed to store schema: %w", err)
+		}
+	}
+
 	o.logger.Info("Starting database dump")
 	dumpTimer := metrics.BackupDuration.WithLabelValues("dump")
 	dumpStart := time.Now()
@@ -97,7 +359,7 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 	reader, err := o.backup.Dump(ctx)
 	if err != nil {
 		metrics.RecordBackupAttempt(false)
-		return fmt.Errorf("failed to create backup: %w", err)
+		return nil, fmt.Errorf("failed to create backup: %w", err)
 	}
 	defer func() {
 		if err := reader.Close(); err != nil {
@@ -109,9 +371,20 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 
 	// Create a counting reader and upload in a single operation
 	// This ensures we don't create partial files on storage if something fails
+	var dumpDuration time.Duration
 	countingReader := &countingReader{
 		reader: reader,
 		count:  0,
+		onEOF: func(n int64) {
+			dumpDuration = time.Since(dumpStart)
+			o.hooks.Fire(ctx, hooks.Event{
+				Type:     hooks.EventDumpComplete,
+				Database: info.Name,
+				Key:      storageKey,
+				Bytes:    n,
+				Seconds:  time.Since(dumpStart).Seconds(),
+			})
+		},
 	}
 
 	// Prepare metadata
@@ -120,18 +393,41 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 		"database-name":    info.Name,
 		"database-version": info.Version,
 		"backup-tool":      "railway-postgres-backup",
+		"compression":      compressor.Name(),
+		MetadataKeyFormat:  o.dumpFormat(),
+	}
+	if len(info.Extensions) > 0 {
+		metadata[MetadataKeyExtensions] = strings.Join(info.Extensions, ",")
+	}
+	if o.config.BackupLabel != "" {
+		metadata[MetadataKeyLabel] = o.config.BackupLabel
+	}
+	if o.config.BackupKeep {
+		metadata[MetadataKeyKeep] = "true"
+	}
+	if schemaKey != "" {
+		metadata[MetadataKeySchema] = schemaKey
 	}
 
 	// Upload to storage
+	run.enter(ctx, storage.PhaseUploading)
 	o.logger.Info("Starting upload to storage", "provider", o.config.StorageProvider)
 	uploadTimer := metrics.BackupDuration.WithLabelValues("upload")
 	uploadStart := time.Now()
 
-	// The upload will either complete fully or not create a file at all
-	if err := o.storage.Upload(ctx, storageKey, countingReader, metadata); err != nil {
+	// Hash the streamed data for the checksum sidecar
+	streamed := sha256.New()
+	upload := io.TeeReader(countingReader, streamed)
+
+	// The upload will either complete fully or not create a file at all. A
+	// spooled upload is checked against the database size, as the dump was.
+	if err := o.storage.Upload(storage.WithUploadSize(ctx, info.Size), storageKey, upload, metadata); err != nil {
 		metrics.RecordStorageOperation("upload", o.config.StorageProvider, false)
 		metrics.RecordBackupAttempt(false)
-		return fmt.Errorf("failed to upload backup: %w", err)
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			return nil, o.quotaExceeded(ctx, err)
+		}
+		return nil, fmt.Errorf("failed to upload backup: %w", err)
 	}
 
 	bytesWritten := countingReader.count
@@ -139,13 +435,56 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 	uploadDuration := time.Since(uploadStart)
 	uploadTimer.Observe(uploadDuration.Seconds())
 	metrics.RecordStorageOperation("upload", o.config.StorageProvider, true)
-	metrics.BackupSize.Set(float64(bytesWritten))
-	metrics.LastBackupTimestamp.Set(float64(timestamp.Unix()))
+	metrics.BackupSize.WithLabelValues(o.config.BackupFilePrefix).Set(float64(bytesWritten))
+	metrics.LastBackupTimestamp.WithLabelValues(o.config.BackupFilePrefix).Set(float64(timestamp.Unix()))
+	metrics.NextBackupAllowed.WithLabelValues(o.config.BackupFilePrefix).Set(float64(timestamp.Add(o.rateLimiter.GetMinInterval()).Unix()))
 	metrics.RecordBackupAttempt(true)
 
+	// Restores verify the backup against its checksum
+	checksum := streamed.Sum(nil)
+	checksumStart := time.Now()
+	if err := storage.WriteChecksum(ctx, o.storage, storageKey, checksum); err != nil {
+		o.logger.Warn("Failed to record backup checksum", "key", storageKey, "error", err)
+	} else if o.config.ClockSkewWarnSeconds > 0 {
+		// The sidecar is written in one request, so its last modified time dates the write
+		o.checkClockSkew(ctx, storageKey+utils.ChecksumSuffix, checksumStart, time.Now())
+	}
+
+	o.writeManifest(ctx, &Manifest{
+		Key:             storageKey,
+		CreatedAt:       timestamp.UTC(),
+		Database:        ManifestDatabase{Name: info.Name, Version: info.Version, SizeBytes: info.Size},
+		Tables:          tables,
+		PGDumpOptions:   o.config.PGDumpOptions,
+		Compression:     compressor.Name(),
+		SizeBytes:       bytesWritten,
+		DurationSeconds: time.Since(startTime).Seconds(),
+		SHA256:          hex.EncodeToString(checksum),
+	})
+
+	// Record the backup so the next run need not list the bucket
+	run.recordBackup(ctx, timestamp, storageKey, bytesWritten)
+	catalogued = &storage.CatalogEntry{
+		Key:       storageKey,
+		Time:      timestamp.UTC(),
+		SizeBytes: bytesWritten,
+		SHA256:    hex.EncodeToString(checksum),
+		Metadata:  metadata,
+	}
+
+	o.hooks.Fire(ctx, hooks.Event{
+		Type:     hooks.EventUploadComplete,
+		Database: info.Name,
+		Key:      storageKey,
+		Bytes:    bytesWritten,
+		Seconds:  time.Since(startTime).Seconds(),
+	})
+
 	o.logger.Info("Backup completed successfully",
 		"filename", filename,
 		"storage_key", storageKey,
+		"label", o.config.BackupLabel,
+		"keep", o.config.BackupKeep,
 		"bytes_written", bytesWritten,
 		"upload_duration", uploadDuration,
 		"bytes_per_second", float64(bytesWritten)/uploadDuration.Seconds(),
@@ -154,77 +493,414 @@ func (o *Orchestrator) Run(ctx context.Context) error {
 	// Record total duration
 	metrics.BackupDuration.WithLabelValues("total").Observe(time.Since(startTime).Seconds())
 
+	var drift []SettingChange
+	if o.config.SettingsSnapshot {
+		drift = o.snapshotSettings(ctx, storageKey)
+	}
+
+	result = &Result{
+		Key:             storageKey,
+		Size:            bytesWritten,
+		Timestamp:       timestamp,
+		Dump:            dumpDuration,
+		Upload:          uploadDuration,
+		SHA256:          hex.EncodeToString(checksum),
+		Database:        info.Name,
+		DatabaseVersion: info.Version,
+		SettingsDrift:   drift,
+	}
+
+	// Older backups are kept when the new one is damaged or does not restore
+	if o.config.VerifyAfterUpload || o.config.VerifySample || o.config.VerifyRestore {
+		run.enter(ctx, storage.PhaseVerifying)
+	}
+	if o.config.VerifyAfterUpload {
+		if err := o.verifyAfterUpload(ctx, storageKey, bytesWritten, checksum); err != nil {
+			return result, err
+		}
+	}
+	if o.config.VerifySample {
+		if err := o.sampleVerify(ctx, storageKey, bytesWritten); err != nil {
+			return result, err
+		}
+	}
+	if o.config.VerifyRestore {
+		recovery, err := o.verifyRestore(ctx, storageKey, sourceTables)
+		if err != nil {
+			return result, err
+		}
+		result.Recovery = recovery
+		catalogued.Recovery = recovery
+	}
+
+	// Only backups that passed their checks get restore instructions
+	o.storeRunbook(ctx, result, info, fields.PGVersion, metadata[MetadataKeyFormat], compressor.Name())
+
 	// Optional: Clean up old backups if retention is configured
-	if o.config.RetentionDays > 0 {
-		if err := o.cleanupOldBackups(ctx); err != nil {
+	if o.config.RetentionEnabled() {
+		run.enter(ctx, storage.PhaseRetaining)
+		pruned, err := o.cleanupOldBackups(ctx, o.config.RetentionDryRun)
+		if !o.config.RetentionDryRun {
+			result.Pruned = pruned
+		}
+		run.state.Run.Retention = &storage.RetentionOutcome{
+			RetentionDays: o.config.RetentionDays,
+			DryRun:        o.config.RetentionDryRun,
+			Deleted:       len(pruned),
+		}
+		if err != nil {
 			o.logger.Warn("Failed to cleanup old backups", "error", err)
 			// Don't fail the backup operation due to cleanup failure
+			run.state.Run.Retention.Error = utils.RedactError(err).Error()
+		}
+	}
+
+	return result, nil
+}
+
+// backupKey renders the storage key of a backup of the database described by
+// info taken at timestamp, and returns the codec it is compressed with.
+func (o *Orchestrator) backupKey(timestamp time.Time, info *DatabaseInfo) (utils.KeyFields, string, compression.Compressor, error) {
+	compressor, err := o.config.GetCompressor()
+	if err != nil {
+		return utils.KeyFields{}, "", nil, fmt.Errorf("invalid compression configuration: %w", err)
+	}
+	keyTemplate, err := o.config.GetKeyTemplate()
+	if err != nil {
+		return utils.KeyFields{}, "", nil, fmt.Errorf("invalid storage key template: %w", err)
+	}
+	ext := BackupExtension(o.config.PGDumpOptions, compressor)
+	if o.dumpFormat() == FormatPlain && DumpFormat(o.config.PGDumpOptions) != FormatPlain {
+		// The fallback export is plain SQL whatever PG_DUMP_OPTIONS selects
+		ext = ".sql" + compressor.Extension()
+	}
+	fields := utils.NewKeyFields(o.config.BackupFilePrefix, timestamp, info.Version, ext)
+
+	// Create storage key from the template (year/month directories by default)
+	key, err := keyTemplate.Render(fields)
+	if err != nil {
+		return utils.KeyFields{}, "", nil, err
+	}
+	return fields, key, compressor, nil
+}
+
+// dumpFormat returns the archive format the backup writes: the one the backup
+// reports, or the one PG_DUMP_OPTIONS selects.
+func (o *Orchestrator) dumpFormat() string {
+	if reporter, ok := o.backup.(FormatReporter); ok {
+		return reporter.OutputFormat()
+	}
+	return DumpFormat(o.config.PGDumpOptions)
+}
+
+// checkDiskSpace verifies local destinations, and the spool directory when
+// uploads are staged on disk, have room for the estimated dump size. The
+// database size is used as an upper bound since the dump is compressed.
+func (o *Orchestrator) checkDiskSpace(info *DatabaseInfo) error {
+	if o.config.HasStorageProvider("filesystem") {
+		if err := utils.CheckDiskSpace(o.config.FilesystemPath, info.Size); err != nil {
+			return fmt.Errorf("disk space check failed: %w", err)
+		}
+	}
+
+	if storage.SpoolsUploads(o.storage) {
+		if err := utils.CheckDiskSpace(utils.TempDir(o.config.TempDir), info.Size); err != nil {
+			return fmt.Errorf("disk space check failed, set BACKUP_TMPDIR to a larger volume: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// cleanupOldBackups removes backups older than the retention period.
-func (o *Orchestrator) cleanupOldBackups(ctx context.Context) error {
-	o.logger.Info("Starting cleanup of old backups", "retention_days", o.config.RetentionDays)
+// Prune applies retention without taking a backup and returns the deleted
+// keys, or only returns them on a dry run. It lets a deployment that holds the
+// delete credentials prune the backups written by a write-only one.
+func (o *Orchestrator) Prune(ctx context.Context, dryRun bool) ([]string, error) {
+	if !o.config.RetentionEnabled() {
+		return nil, fmt.Errorf("retention is not configured: set RETENTION_DAYS")
+	}
+	return o.cleanupOldBackups(ctx, dryRun)
+}
+
+// cleanupOldBackups removes backups older than the retention period and returns
+// the deleted keys. With multiple destinations, each destination applies its
+// own retention period. A dry run only returns the keys it would delete.
+func (o *Orchestrator) cleanupOldBackups(ctx context.Context, dryRun bool) ([]string, error) {
+	return o.pruneDestinations(ctx, dryRun, 0, nil)
+}
+
+// pruneDestinations applies retention to every destination like
+// cleanupOldBackups. When emergencyDays is positive, only the destinations
+// named in full are pruned, each with the shorter of emergencyDays and its
+// configured period; a single destination counts as full. Destinations without
+// retention are never pruned.
+func (o *Orchestrator) pruneDestinations(ctx context.Context, dryRun bool, emergencyDays int, full []string) ([]string, error) {
+	// The local cache keeps its own count of backups
+	store := o.storage
+	if cached, ok := store.(*storage.CachedStorage); ok {
+		store = cached.Remote()
+	}
+
+	multi, ok := store.(*storage.MultiStorage)
+	if !ok {
+		days := o.config.RetentionDays
+		if days <= 0 {
+			return nil, nil
+		}
+		if emergencyDays > 0 {
+			days = min(days, emergencyDays)
+		}
+		return o.cleanupDestination(ctx, store, o.config.StorageProvider, days, dryRun)
+	}
+
+	var deleted []string
+	var errs []error
+	for _, dest := range multi.Destinations() {
+		days := dest.RetentionDays
+		if days <= 0 {
+			continue
+		}
+		if emergencyDays > 0 {
+			if !slices.Contains(full, dest.Name) {
+				continue
+			}
+			days = min(days, emergencyDays)
+		}
+		keys, err := o.cleanupDestination(ctx, dest.Storage, dest.Name, days, dryRun)
+		deleted = append(deleted, keys...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dest.Name, err))
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// cleanupDestination removes backups older than retentionDays from a single
+// storage and returns the deleted keys, or only returns them on a dry run.
+func (o *Orchestrator) cleanupDestination(ctx context.Context, store storage.Storage, provider string, retentionDays int, dryRun bool) ([]string, error) {
+	o.logger.Info("Starting cleanup of old backups", "destination", provider, "retention_days", retentionDays)
 
 	// Calculate cutoff time
-	cutoff := time.Now().AddDate(0, 0, -o.config.RetentionDays)
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
 
-	// List all backups
-	objects, err := o.storage.List(ctx, o.config.BackupFilePrefix)
+	keyTemplate, err := o.config.GetKeyTemplate()
 	if err != nil {
-		return fmt.Errorf("failed to list backups: %w", err)
+		return nil, fmt.Errorf("invalid storage key template: %w", err)
 	}
 
-	var deleted int
-	for _, obj := range objects {
-		// Try to parse timestamp from filename
-		backupTime, err := utils.ParseBackupFilename(obj.Key)
+	// Collect the expired objects first, so the sidecars of pinned and spared
+	// backups survive whichever order they are listed in. The listing is
+	// streamed, so only the expired objects are held in memory.
+	type expiredObject struct {
+		key        string
+		backupTime time.Time
+	}
+	var expired []expiredObject
+	kept := make(map[string]bool)
+	backups := 0
+	err = storage.ListIter(ctx, store, o.config.BackupFilePrefix, func(obj storage.ObjectInfo) error {
+		// The state and lease objects are rewritten by every run, not backups
+		if storage.IsReservedKey(obj.Key) {
+			return nil
+		}
+		if !utils.IsSidecar(obj.Key) {
+			backups++
+		}
+
+		// Try to parse timestamp from the key
+		backupTime, err := parseBackupTime(keyTemplate, obj.Key)
 		if err != nil {
-			o.logger.Warn("Failed to parse backup timestamp, using last modified time",
+			o.logger.Warn("Failed to parse backup timestamp, using metadata or last modified time",
 				"filename", obj.Key,
 				"error", err,
 			)
-			backupTime = obj.LastModified
+			backupTime = storedBackupTime(ctx, store, obj)
 		}
 
-		if backupTime.Before(cutoff) {
-			o.logger.Info("Deleting old backup",
-				"filename", obj.Key,
-				"backup_time", backupTime,
-				"age_days", int(time.Since(backupTime).Hours()/24),
+		if !backupTime.Before(cutoff) {
+			return nil
+		}
+		if !utils.IsSidecar(obj.Key) {
+			// A backup whose metadata cannot be read may be pinned, so it is kept
+			metadata, err := objectMetadata(ctx, store, obj)
+			if err != nil {
+				o.logger.Warn("Failed to read backup metadata, keeping it", "filename", obj.Key, "error", err)
+				kept[obj.Key] = true
+				return nil
+			}
+			if metadata[MetadataKeyKeep] == "true" {
+				o.logger.Info("Keeping pinned backup", "filename", obj.Key, "backup_time", backupTime)
+				kept[obj.Key] = true
+				return nil
+			}
+		}
+		expired = append(expired, expiredObject{key: obj.Key, backupTime: backupTime})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	// RETENTION_MIN_KEEP spares the newest expired backups, for instance after
+	// backups were paused for longer than the retention period
+	var expiredBackups []expiredObject
+	for _, obj := range expired {
+		if !utils.IsSidecar(obj.key) {
+			expiredBackups = append(expiredBackups, obj)
+		}
+	}
+	slices.SortFunc(expiredBackups, func(a, b expiredObject) int {
+		return b.backupTime.Compare(a.backupTime)
+	})
+	remaining := backups - len(expiredBackups)
+	for _, obj := range expiredBackups {
+		if remaining >= o.config.RetentionMinKeep {
+			break
+		}
+		o.logger.Info("Keeping expired backup to respect RETENTION_MIN_KEEP",
+			"filename", obj.key,
+			"backup_time", obj.backupTime,
+			"min_keep", o.config.RetentionMinKeep,
+		)
+		kept[obj.key] = true
+		remaining++
+	}
+
+	var deleted []string
+	var deletions []hooks.Deletion
+	for _, obj := range expired {
+		backupKey := utils.TrimSidecarSuffix(obj.key)
+		if kept[backupKey] {
+			continue
+		}
+		deletion := hooks.Deletion{Key: obj.key, BackupTime: obj.backupTime, Reason: fmt.Sprintf("older than %d days", retentionDays)}
+		if backupKey != obj.key {
+			deletion.Reason = fmt.Sprintf("sidecar of %s, %s", backupKey, deletion.Reason)
+		}
+		if dryRun {
+			o.logger.Info("Would delete old backup",
+				"filename", obj.key,
+				"backup_time", obj.backupTime,
+				"age_days", int(time.Since(obj.backupTime).Hours()/24),
+				"reason", deletion.Reason,
 			)
+			deleted = append(deleted, obj.key)
+			deletions = append(deletions, deletion)
+			continue
+		}
 
-			if err := o.storage.Delete(ctx, obj.Key); err != nil {
-				o.logger.Error("Failed to delete old backup",
-					"filename", obj.Key,
-					"error", err,
-				)
-				metrics.RecordStorageOperation("delete", o.config.StorageProvider, false)
-				// Continue with other deletions
-			} else {
-				deleted++
-				metrics.RecordStorageOperation("delete", o.config.StorageProvider, true)
-				metrics.BackupsDeleted.Inc()
-			}
+		o.logger.Info("Deleting old backup",
+			"filename", obj.key,
+			"backup_time", obj.backupTime,
+			"age_days", int(time.Since(obj.backupTime).Hours()/24),
+			"reason", deletion.Reason,
+		)
+
+		if err := store.Delete(ctx, obj.key); err != nil {
+			o.logger.Error("Failed to delete old backup",
+				"filename", obj.key,
+				"error", err,
+			)
+			metrics.RecordStorageOperation("delete", provider, false)
+			// Continue with other deletions
+		} else {
+			deleted = append(deleted, obj.key)
+			deletions = append(deletions, deletion)
+			metrics.RecordStorageOperation("delete", provider, true)
+			metrics.BackupsDeleted.Inc()
 		}
 	}
 
-	o.logger.Info("Cleanup completed", "deleted_count", deleted)
-	return nil
+	if dryRun {
+		o.logger.Info("Cleanup dry run completed", "destination", provider, "would_delete_count", len(deleted))
+		// DRY_RUN writes nothing; RETENTION_DRY_RUN reports for review
+		if o.config.RetentionDryRun && !o.config.DryRun {
+			o.reportRetention(ctx, store, storage.RetentionReport{
+				Destination:   provider,
+				GeneratedAt:   time.Now().UTC(),
+				RetentionDays: retentionDays,
+				Cutoff:        cutoff.UTC(),
+				WouldDelete:   deletions,
+			})
+		}
+		return deleted, nil
+	}
+	// Schemas are shared by backups, so they go once no backup references them
+	if o.config.SchemaDedup {
+		deleted = append(deleted, o.pruneSchemas(ctx, store, provider, cutoff)...)
+	}
+	o.logger.Info("Cleanup completed", "destination", provider, "deleted_count", len(deleted))
+	o.hooks.Fire(ctx, hooks.Event{Type: hooks.EventRetention, Destination: provider, Deleted: len(deleted), Deletions: deletions})
+	return deleted, nil
+}
+
+// reportRetention stores the report of a retention dry run in the destination
+// it describes and sends it with the retention event.
+func (o *Orchestrator) reportRetention(ctx context.Context, store storage.Storage, report storage.RetentionReport) {
+	if report.WouldDelete == nil {
+		report.WouldDelete = []hooks.Deletion{}
+	}
+	if err := storage.WriteRetentionReport(ctx, store, report); err != nil {
+		o.logger.Warn("Failed to write the retention report", "destination", report.Destination, "error", err)
+	}
+	o.hooks.Fire(ctx, hooks.Event{
+		Type:        hooks.EventRetention,
+		Destination: report.Destination,
+		Deletions:   report.WouldDelete,
+		DryRun:      true,
+	})
+}
+
+// parseBackupTime returns the time a backup, or the backup a sidecar belongs
+// to, was taken according to its storage key.
+func parseBackupTime(keyTemplate *utils.KeyTemplate, key string) (time.Time, error) {
+	key = utils.TrimSidecarSuffix(key)
+	if t, err := keyTemplate.ParseTime(key); err == nil {
+		return t, nil
+	}
+	// Keys written under a previous template still end in a timestamped filename
+	return utils.ParseBackupFilename(key)
+}
+
+// storedBackupTime returns the backup-timestamp recorded in an object's metadata,
+// or else its last modified time.
+func storedBackupTime(ctx context.Context, store storage.Storage, obj storage.ObjectInfo) time.Time {
+	metadata, _ := objectMetadata(ctx, store, obj)
+	if t, err := time.Parse(time.RFC3339, metadata["backup-timestamp"]); err == nil {
+		return t
+	}
+	return obj.LastModified
+}
+
+// objectMetadata returns an object's metadata, reading it if the listing
+// omitted it. Every backup records its backup-timestamp. The error of a failed
+// read is returned, as a listing without metadata does not tell what the object
+// carries.
+func objectMetadata(ctx context.Context, store storage.Storage, obj storage.ObjectInfo) (map[string]string, error) {
+	if _, ok := obj.Metadata["backup-timestamp"]; ok {
+		return obj.Metadata, nil
+	}
+	info, err := store.Stat(ctx, obj.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", obj.Key, err)
+	}
+	return info.Metadata, nil
 }
 
 // countingReader wraps an io.Reader and counts bytes read
 type countingReader struct {
 	reader io.Reader
 	count  int64
+	onEOF  func(n int64) // Called once when the reader is exhausted
 }
 
 // Read implements io.Reader and counts bytes
 func (cr *countingReader) Read(p []byte) (int, error) {
 	n, err := cr.reader.Read(p)
 	cr.count += int64(n)
+	if err == io.EOF && cr.onEOF != nil {
+		cr.onEOF(cr.count)
+		cr.onEOF = nil
+	}
 	return n, err
 }
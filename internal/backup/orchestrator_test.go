@@ -1,27 +1,40 @@
 package backup
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
 	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // Mock implementations for testing
 
 type mockBackup struct {
-	dumpErr   error
-	dumpData  string
-	infoErr   error
-	info      *DatabaseInfo
-	validated bool
+	dumpErr     error
+	dumpData    string
+	infoErr     error
+	info        *DatabaseInfo
+	validated   bool
+	queryValues map[string]float64
 }
 
 func (m *mockBackup) Dump(ctx context.Context) (io.ReadCloser, error) {
@@ -36,6 +49,14 @@ func (m *mockBackup) Validate(ctx context.Context, reader io.Reader) error {
 	return nil
 }
 
+func (m *mockBackup) QueryValue(ctx context.Context, query string) (float64, error) {
+	value, ok := m.queryValues[query]
+	if !ok {
+		return 0, fmt.Errorf("unexpected query %q", query)
+	}
+	return value, nil
+}
+
 func (m *mockBackup) GetInfo(ctx context.Context) (*DatabaseInfo, error) {
 	if m.infoErr != nil {
 		return nil, m.infoErr
@@ -58,24 +79,93 @@ type mockStorage struct {
 	lastBackup   time.Time
 	listResult   []storage.ObjectInfo
 	deleteCalls  []string
+	objects      map[string][]byte
+	state        *storage.State
 }
 
 func (m *mockStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	// State updates are tracked apart from the backup upload
+	if key == storage.StateKey {
+		m.state = &storage.State{}
+		return json.NewDecoder(reader).Decode(m.state)
+	}
+
+	// Sidecars and other reserved objects are kept apart from the backup upload too
+	if utils.IsSidecar(key) || storage.IsReservedKey(key) {
+		data, _ := io.ReadAll(reader)
+		if m.objects != nil {
+			m.objects[key] = data
+		}
+		return nil
+	}
+
 	m.uploadCalled = true
 	m.uploadKey = key
 	m.metadata = metadata
 
-	// Consume the reader
-	_, _ = io.ReadAll(reader)
+	// Consume the reader, keeping the data when objects are tracked
+	data, _ := io.ReadAll(reader)
+	if m.objects != nil && m.uploadErr == nil {
+		m.objects[key] = data
+	}
 
 	return m.uploadErr
 }
 
+func (m *mockStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if key == storage.StateKey && m.state != nil {
+		data, _ := json.Marshal(m.state)
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *mockStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	data = data[min(offset, int64(len(data))):]
+	if length >= 0 {
+		data = data[:min(length, int64(len(data)))]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
 func (m *mockStorage) Delete(ctx context.Context, key string) error {
 	m.deleteCalls = append(m.deleteCalls, key)
 	return nil
 }
 
+func (m *mockStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	data, ok := m.objects[srcKey]
+	if !ok {
+		return fmt.Errorf("object %s not found", srcKey)
+	}
+	m.objects[dstKey] = data
+	return nil
+}
+
+func (m *mockStorage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	for _, obj := range m.listResult {
+		if obj.Key == key {
+			return &obj, nil
+		}
+	}
+	if data, ok := m.objects[key]; ok {
+		info := &storage.ObjectInfo{Key: key, Size: int64(len(data))}
+		if key == m.uploadKey {
+			info.Metadata = m.metadata
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+}
+
 func (m *mockStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
 	return m.listResult, nil
 }
@@ -96,6 +186,7 @@ func TestOrchestrator_Run(t *testing.T) {
 		wantErr       bool
 		wantUpload    bool
 		checkMetadata bool
+		wantMetadata  map[string]string
 	}{
 		{
 			name: "successful backup",
@@ -113,6 +204,81 @@ func TestOrchestrator_Run(t *testing.T) {
 			wantErr:    false,
 			wantUpload: true,
 		},
+		{
+			name: "records database extensions",
+			config: &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+			},
+			mockBackup: &mockBackup{
+				dumpData: "backup data",
+				info: &DatabaseInfo{
+					Name:       "testdb",
+					Version:    "PostgreSQL 16.0",
+					Extensions: []string{"pgcrypto", "plpgsql"},
+				},
+			},
+			mockStorage:  &mockStorage{},
+			wantUpload:   true,
+			wantMetadata: map[string]string{MetadataKeyExtensions: "pgcrypto,plpgsql"},
+		},
+		{
+			name: "records the backup label",
+			config: &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				BackupLabel:      "pre-migration-2025-06",
+			},
+			mockBackup:   &mockBackup{dumpData: "backup data"},
+			mockStorage:  &mockStorage{},
+			wantUpload:   true,
+			wantMetadata: map[string]string{MetadataKeyLabel: "pre-migration-2025-06"},
+		},
+		{
+			name: "pins a kept backup",
+			config: &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				BackupKeep:       true,
+			},
+			mockBackup:   &mockBackup{dumpData: "backup data"},
+			mockStorage:  &mockStorage{},
+			wantUpload:   true,
+			wantMetadata: map[string]string{MetadataKeyKeep: "true"},
+		},
+		{
+			name: "skip precondition blocks backup",
+			config: &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				Preconditions: []config.Precondition{
+					{Name: "queue depth", Query: "SELECT count(*) FROM jobs", Max: 100, Action: config.PreconditionSkip},
+				},
+			},
+			mockBackup: &mockBackup{
+				dumpData:    "backup data",
+				queryValues: map[string]float64{"SELECT count(*) FROM jobs": 250},
+			},
+			mockStorage: &mockStorage{},
+			wantUpload:  false,
+		},
+		{
+			name: "warn precondition allows backup",
+			config: &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				Preconditions: []config.Precondition{
+					{Name: "queue depth", Query: "SELECT count(*) FROM jobs", Max: 100},
+					{Name: "unreachable", Query: "SELECT broken()", Action: config.PreconditionSkip},
+				},
+			},
+			mockBackup: &mockBackup{
+				dumpData:    "backup data",
+				queryValues: map[string]float64{"SELECT count(*) FROM jobs": 250},
+			},
+			mockStorage: &mockStorage{},
+			wantUpload:  true,
+		},
 		{
 			name: "respawn protection blocks backup",
 			config: &config.Config{
@@ -256,11 +422,480 @@ func TestOrchestrator_Run(t *testing.T) {
 				if tt.mockStorage.metadata["backup-tool"] != "railway-postgres-backup" {
 					t.Errorf("Missing or incorrect backup-tool metadata")
 				}
+				for k, want := range tt.wantMetadata {
+					if got := tt.mockStorage.metadata[k]; got != want {
+						t.Errorf("metadata[%s] = %q, want %q", k, got, want)
+					}
+				}
 			}
 		})
 	}
 }
 
+// TestOrchestrator_UncompressedBackupKey checks the extension of backups stored
+// with COMPRESSION=none: Dump passes --format=tar ahead of PG_DUMP_OPTIONS, so
+// without a format option the backup is a tar archive.
+func TestOrchestrator_UncompressedBackupKey(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name    string
+		options string
+		want    string
+	}{
+		{name: "default", options: "", want: ".tar"},
+		{name: "no format option", options: "--no-owner", want: ".tar"},
+		{name: "custom", options: "-Fc", want: ".dump"},
+		{name: "plain", options: "--format=plain", want: ".sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				Compression:      "none",
+				PGDumpOptions:    tt.options,
+			}
+			store := &mockStorage{}
+			result, err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !strings.HasSuffix(result.Key, "Z"+tt.want) {
+				t.Errorf("Key = %s, want the %s extension", result.Key, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_Hooks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		mockBackup *mockBackup
+		retention  int
+		want       []string
+	}{
+		{
+			name:       "successful run",
+			mockBackup: &mockBackup{dumpData: "backup data"},
+			retention:  7,
+			want:       []string{hooks.EventRunStart, hooks.EventDumpComplete, hooks.EventUploadComplete, hooks.EventRetention},
+		},
+		{
+			name:       "failed dump",
+			mockBackup: &mockBackup{dumpErr: errors.New("pg_dump failed")},
+			want:       []string{hooks.EventRunStart, hooks.EventFailure},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				StorageProvider:        "s3",
+				RespawnProtectionHours: 6,
+				RetentionDays:          tt.retention,
+			}
+			orchestrator := NewOrchestrator(cfg, &mockStorage{}, tt.mockBackup, logger)
+
+			var events []hooks.Event
+			orchestrator.RegisterHook(hooks.Func(func(ctx context.Context, e hooks.Event) error {
+				events = append(events, e)
+				return nil
+			}))
+
+			_, _ = orchestrator.Execute(context.Background())
+
+			var got []string
+			for _, e := range events {
+				got = append(got, e.Type)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("events = %v, want %v", got, tt.want)
+			}
+
+			last := events[len(events)-1]
+			switch last.Type {
+			case hooks.EventFailure:
+				if !strings.Contains(last.Error, "pg_dump failed") {
+					t.Errorf("failure event error = %q", last.Error)
+				}
+			case hooks.EventRetention:
+				if events[2].Bytes != int64(len("backup data")) || events[2].Database != "testdb" {
+					t.Errorf("upload_complete event = %+v", events[2])
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_FailureContext(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	out := filepath.Join(t.TempDir(), "failures")
+
+	cfg := &config.Config{
+		StorageProvider:    "s3",
+		OnFailureCommand:   "cat >> " + out + " && echo >> " + out,
+		HookTimeoutSeconds: 5,
+	}
+	store := &mockStorage{}
+	mock := &mockBackup{}
+	orchestrator := NewOrchestrator(cfg, store, mock, logger)
+
+	var failures []hooks.Event
+	orchestrator.RegisterHook(hooks.Func(func(ctx context.Context, e hooks.Event) error {
+		if e.Type == hooks.EventFailure {
+			failures = append(failures, e)
+		}
+		return nil
+	}))
+
+	steps := []struct {
+		name         string
+		dumpErr      error
+		uploadErr    error
+		runLock      bool
+		wantPhase    string
+		wantAttempts int
+	}{
+		{name: "dump fails", dumpErr: errors.New("pg_dump failed"), wantPhase: storage.PhaseDumping, wantAttempts: 1},
+		{name: "upload fails", uploadErr: errors.New("access denied"), wantPhase: storage.PhaseUploading, wantAttempts: 2},
+		{name: "success resets the count"},
+		{name: "run lock unsupported", runLock: true, wantPhase: PhaseLocking, wantAttempts: 1},
+	}
+
+	for _, step := range steps {
+		mock.dumpErr, store.uploadErr, cfg.RunLock = step.dumpErr, step.uploadErr, step.runLock
+		before := len(failures)
+
+		_, err := orchestrator.Execute(context.Background())
+		if (err != nil) != (step.wantPhase != "") {
+			t.Fatalf("%s: Execute() error = %v", step.name, err)
+		}
+		if step.wantPhase == "" {
+			if len(failures) != before || store.state.ConsecutiveFailures != 0 {
+				t.Errorf("%s: failures = %d, consecutive = %d", step.name, len(failures)-before, store.state.ConsecutiveFailures)
+			}
+			continue
+		}
+
+		if got := FailedPhase(err); got != step.wantPhase {
+			t.Errorf("%s: FailedPhase() = %q, want %q", step.name, got, step.wantPhase)
+		}
+		e := failures[len(failures)-1]
+		if e.Phase != step.wantPhase || e.Attempts != step.wantAttempts || e.Error != err.Error() {
+			t.Errorf("%s: failure event = %+v, want phase %q and %d attempts", step.name, e, step.wantPhase, step.wantAttempts)
+		}
+	}
+
+	// ON_FAILURE_COMMAND receives the failures only
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ON_FAILURE_COMMAND did not run: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 {
+		t.Errorf("ON_FAILURE_COMMAND ran %d times, want 3:\n%s", len(lines), data)
+	}
+}
+
+func TestOrchestrator_State(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:        "s3",
+		BackupFilePrefix:       "test",
+		RespawnProtectionHours: 6,
+	}
+
+	t.Run("successful backup records state", func(t *testing.T) {
+		mockStorage := &mockStorage{}
+		orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{dumpData: "backup data"}, logger)
+
+		result, err := orchestrator.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if mockStorage.state == nil {
+			t.Fatal("state was not written")
+		}
+		if mockStorage.state.LastKey != result.Key || mockStorage.state.LastSize != result.Size ||
+			!mockStorage.state.LastBackupTime.Equal(result.Timestamp) {
+			t.Errorf("state = %+v, want key %s, size %d, time %v", mockStorage.state, result.Key, result.Size, result.Timestamp)
+		}
+	})
+
+	t.Run("state takes precedence over listing", func(t *testing.T) {
+		// The listing finds no backups, but the state records one an hour ago
+		mockStorage := &mockStorage{state: &storage.State{LastBackupTime: time.Now().Add(-time.Hour)}}
+		orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{dumpData: "backup data"}, logger)
+
+		result, err := orchestrator.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !result.Skipped || mockStorage.uploadCalled {
+			t.Errorf("Execute() skipped = %v, uploaded = %v; want skipped by respawn protection", result.Skipped, mockStorage.uploadCalled)
+		}
+	})
+}
+
+func TestOrchestrator_MetricsPerPrefix(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dumps := map[string]string{
+		"metrics-small": "small",
+		"metrics-large": strings.Repeat("large backup data ", 100),
+	}
+
+	// Run the backups concurrently, as fleet mode does
+	results := make(map[string]*Result)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for prefix, data := range dumps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: prefix}
+			result, err := NewOrchestrator(cfg, &mockStorage{}, &mockBackup{dumpData: data}, logger).Execute(context.Background())
+			if err != nil {
+				t.Errorf("Execute(%s) error = %v", prefix, err)
+				return
+			}
+			mu.Lock()
+			results[prefix] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for prefix, result := range results {
+		if got := testutil.ToFloat64(metrics.BackupSize.WithLabelValues(prefix)); got != float64(result.Size) {
+			t.Errorf("BackupSize{%s} = %v, want %d", prefix, got, result.Size)
+		}
+		if got := testutil.ToFloat64(metrics.LastBackupTimestamp.WithLabelValues(prefix)); got != float64(result.Timestamp.Unix()) {
+			t.Errorf("LastBackupTimestamp{%s} = %v, want %d", prefix, got, result.Timestamp.Unix())
+		}
+		if got := testutil.ToFloat64(metrics.DatabaseSize.WithLabelValues(prefix)); got != 1024*1024 {
+			t.Errorf("DatabaseSize{%s} = %v, want %d", prefix, got, 1024*1024)
+		}
+	}
+	if results["metrics-small"] != nil && results["metrics-large"] != nil && results["metrics-small"].Size == results["metrics-large"].Size {
+		t.Error("backups of different sizes have the same size; the test cannot tell the gauges apart")
+	}
+}
+
+func TestOrchestrator_RateLimitMetrics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	last := time.Now().Add(-time.Hour).Truncate(time.Second)
+	cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "ratelimit", RespawnProtectionHours: 6}
+	blocked := testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("too-recent"))
+
+	result, err := NewOrchestrator(cfg, &mockStorage{lastBackup: last}, &mockBackup{dumpData: "backup data"}, logger).Execute(context.Background())
+	if err != nil || !result.Skipped {
+		t.Fatalf("Execute() = %+v, %v; want skipped by respawn protection", result, err)
+	}
+	if got := testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("too-recent")); got != blocked+1 {
+		t.Errorf("RateLimitBlocked{too-recent} = %v, want %v", got, blocked+1)
+	}
+	if got, want := testutil.ToFloat64(metrics.NextBackupAllowed.WithLabelValues("ratelimit")), float64(last.Add(6*time.Hour).Unix()); got != want {
+		t.Errorf("NextBackupAllowed = %v, want %v", got, want)
+	}
+}
+
+// skewedStorage lists every object as last modified at a fixed time, as a
+// provider whose clock differs from the local one would.
+type skewedStorage struct {
+	*mockStorage
+	lastModified time.Time
+}
+
+func (s *skewedStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	return []storage.ObjectInfo{{Key: prefix, LastModified: s.lastModified}}, nil
+}
+
+func TestOrchestrator_Jitter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "jitter", BackupJitter: "20ms-40ms"}
+
+	store := &mockStorage{}
+	start := time.Now()
+	if err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Run() took %v, want at least the 20ms jitter", elapsed)
+	}
+	if !store.uploadCalled {
+		t.Error("backup was not uploaded after the jitter")
+	}
+
+	// A cancelled run stops waiting and uploads nothing
+	cfg.BackupJitter = "1h"
+	store = &mockStorage{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want context.DeadlineExceeded", err)
+	}
+	if store.uploadCalled {
+		t.Error("backup was uploaded although the run was cancelled during the jitter")
+	}
+}
+
+func TestOrchestrator_ClockSkew(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "clockskew", ClockSkewWarnSeconds: 60}
+
+	// The provider's clock is ten minutes behind the local one
+	store := &skewedStorage{mockStorage: &mockStorage{}, lastModified: time.Now().Add(-10 * time.Minute)}
+	if _, err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.ClockSkew.WithLabelValues("clockskew")); got < 600 || got > 660 {
+		t.Errorf("ClockSkew = %v, want about 600", got)
+	}
+}
+
+func TestOrchestrator_Pause(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pausedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pauseObject, _ := json.Marshal(storage.Pause{Reason: "maintenance", PausedAt: pausedAt})
+
+	tests := []struct {
+		name       string
+		paused     bool
+		store      *mockStorage
+		wantReason string
+	}{
+		{
+			name:       "BACKUP_PAUSED",
+			paused:     true,
+			store:      &mockStorage{},
+			wantReason: "backups are paused by BACKUP_PAUSED",
+		},
+		{
+			name: "pause object",
+			store: &mockStorage{
+				listResult: []storage.ObjectInfo{{Key: storage.PauseKey}},
+				objects:    map[string][]byte{storage.PauseKey: pauseObject},
+			},
+			wantReason: "backups are paused since 2026-03-01T12:00:00Z: maintenance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "test", BackupPaused: tt.paused}
+			blocked := testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("manual-hold"))
+
+			result, err := NewOrchestrator(cfg, tt.store, &mockBackup{dumpData: "backup data"}, logger).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !result.Skipped || !result.Paused || result.Reason != tt.wantReason {
+				t.Errorf("Execute() = %+v, want paused with reason %q", result, tt.wantReason)
+			}
+			if tt.store.uploadCalled {
+				t.Error("a paused run uploaded a backup")
+			}
+			if got := testutil.ToFloat64(metrics.RateLimitBlocked.WithLabelValues("manual-hold")); got != blocked+1 {
+				t.Errorf("RateLimitBlocked{manual-hold} = %v, want %v", got, blocked+1)
+			}
+		})
+	}
+}
+
+// lockingStorage adds conditional writes, backed by a directory, to a mock storage.
+type lockingStorage struct {
+	*mockStorage
+	*storage.FilesystemStorage
+}
+
+// Storage methods come from the mock; conditional ones from the filesystem.
+func (l *lockingStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	return l.mockStorage.Upload(ctx, key, reader, metadata)
+}
+func (l *lockingStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return l.mockStorage.Open(ctx, key)
+}
+func (l *lockingStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return l.mockStorage.OpenRange(ctx, key, offset, length)
+}
+func (l *lockingStorage) Delete(ctx context.Context, key string) error {
+	return l.mockStorage.Delete(ctx, key)
+}
+func (l *lockingStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return l.mockStorage.Copy(ctx, srcKey, dstKey)
+}
+func (l *lockingStorage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	return l.mockStorage.Stat(ctx, key)
+}
+func (l *lockingStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	return l.mockStorage.List(ctx, prefix)
+}
+func (l *lockingStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return l.mockStorage.GetLastBackupTime(ctx)
+}
+
+func TestOrchestrator_RunLock(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:   "s3",
+		BackupFilePrefix:  "test",
+		RunLock:           true,
+		RunLockTTLSeconds: 60,
+	}
+
+	newStore := func(t *testing.T) *lockingStorage {
+		fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewFilesystemStorage() error = %v", err)
+		}
+		return &lockingStorage{mockStorage: &mockStorage{}, FilesystemStorage: fs}
+	}
+
+	t.Run("backs up and releases the lock", func(t *testing.T) {
+		store := newStore(t)
+		result, err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Execute(ctx)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if result.Skipped || !store.uploadCalled {
+			t.Errorf("Execute() skipped = %v, uploaded = %v; want a backup", result.Skipped, store.uploadCalled)
+		}
+		if _, _, err := store.ReadVersion(ctx, storage.LeaseKey); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("lock not released: %v", err)
+		}
+	})
+
+	t.Run("skips while another instance holds the lock", func(t *testing.T) {
+		store := newStore(t)
+		other, err := storage.AcquireLease(ctx, store, "other", time.Minute, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+		defer func() {
+			_ = other.Release(ctx)
+		}()
+
+		result, err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Execute(ctx)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !result.Skipped || !strings.Contains(result.Reason, "other") || store.uploadCalled {
+			t.Errorf("Execute() = %+v, uploaded = %v; want skipped naming the holder", result, store.uploadCalled)
+		}
+	})
+
+	t.Run("storage without conditional writes", func(t *testing.T) {
+		_, err := NewOrchestrator(cfg, &mockStorage{}, &mockBackup{dumpData: "backup data"}, logger).Execute(ctx)
+		if err == nil || !strings.Contains(err.Error(), "RUN_LOCK") {
+			t.Errorf("Execute() error = %v, want RUN_LOCK unsupported", err)
+		}
+	})
+}
+
 func TestOrchestrator_CleanupOldBackups(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
@@ -294,7 +929,7 @@ func TestOrchestrator_CleanupOldBackups(t *testing.T) {
 
 	orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{}, logger)
 
-	err := orchestrator.cleanupOldBackups(context.Background())
+	deleted, err := orchestrator.cleanupOldBackups(context.Background(), false)
 	if err != nil {
 		t.Fatalf("cleanupOldBackups() error = %v", err)
 	}
@@ -303,6 +938,9 @@ func TestOrchestrator_CleanupOldBackups(t *testing.T) {
 	if len(mockStorage.deleteCalls) != 2 {
 		t.Errorf("Expected 2 deletions, got %d", len(mockStorage.deleteCalls))
 	}
+	if !reflect.DeepEqual(deleted, mockStorage.deleteCalls) {
+		t.Errorf("cleanupOldBackups() = %v, want the deleted keys %v", deleted, mockStorage.deleteCalls)
+	}
 
 	// Check that the correct backups were deleted
 	deletedKeys := make(map[string]bool)
@@ -321,6 +959,476 @@ func TestOrchestrator_CleanupOldBackups(t *testing.T) {
 	}
 }
 
+func TestOrchestrator_Prune(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	old := time.Now().AddDate(0, 0, -10)
+	oldKey := utils.GenerateBackupFilename("test", old, "16")
+
+	tests := []struct {
+		name        string
+		retention   int
+		dryRun      bool
+		wantErr     bool
+		wantPruned  int
+		wantDeletes int
+	}{
+		{name: "prunes expired backups", retention: 7, wantPruned: 1, wantDeletes: 1},
+		{name: "dry run deletes nothing", retention: 7, dryRun: true, wantPruned: 1},
+		{name: "retention not configured", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStorage{listResult: []storage.ObjectInfo{{Key: oldKey, LastModified: old}}}
+			cfg := &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				RetentionDays:    tt.retention,
+			}
+			o := NewOrchestrator(cfg, store, &mockBackup{}, logger)
+
+			pruned, err := o.Prune(context.Background(), tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Prune() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(pruned) != tt.wantPruned {
+				t.Errorf("Prune() = %v, want %d keys", pruned, tt.wantPruned)
+			}
+			if len(store.deleteCalls) != tt.wantDeletes {
+				t.Errorf("deletes = %v, want %d", store.deleteCalls, tt.wantDeletes)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_CleanupKeyTemplate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// LastModified is recent for every object, so only the parsed key decides
+	now := time.Now()
+	oldKey := now.AddDate(0, 0, -10).UTC().Format("2006/01/02/test-2006-01-02T15-04-05") + "-000Z.tar.gz"
+	recentKey := now.AddDate(0, 0, -2).UTC().Format("2006/01/02/test-2006-01-02T15-04-05") + "-000Z.tar.gz"
+
+	mockStorage := &mockStorage{
+		listResult: []storage.ObjectInfo{
+			{Key: oldKey, LastModified: now},
+			{Key: oldKey + utils.SettingsSuffix, LastModified: now},
+			{Key: recentKey, LastModified: now},
+		},
+	}
+
+	cfg := &config.Config{
+		StorageProvider:    "s3",
+		BackupFilePrefix:   "test",
+		RetentionDays:      7,
+		StorageKeyTemplate: "{{.Year}}/{{.Month}}/{{.Day}}/{{.Prefix}}-{{.Timestamp}}.tar.gz",
+	}
+
+	orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{}, logger)
+	if _, err := orchestrator.cleanupOldBackups(context.Background(), false); err != nil {
+		t.Fatalf("cleanupOldBackups() error = %v", err)
+	}
+
+	want := []string{oldKey, oldKey + utils.SettingsSuffix}
+	if !slices.Equal(mockStorage.deleteCalls, want) {
+		t.Errorf("deleted %v, want %v", mockStorage.deleteCalls, want)
+	}
+}
+
+func TestOrchestrator_CleanupMetadataTimestamp(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Keys without a timestamp fall back to the backup-timestamp metadata
+	now := time.Now()
+	mockStorage := &mockStorage{
+		listResult: []storage.ObjectInfo{
+			{
+				Key:          "imported/old.sql.gz",
+				LastModified: now,
+				Metadata:     map[string]string{"backup-timestamp": now.AddDate(0, 0, -10).Format(time.RFC3339)},
+			},
+			{
+				Key:          "imported/recent.sql.gz",
+				LastModified: now,
+				Metadata:     map[string]string{"backup-timestamp": now.AddDate(0, 0, -1).Format(time.RFC3339)},
+			},
+			{Key: "imported/unknown.sql.gz", LastModified: now},
+			{Key: storage.StateKey, LastModified: now.AddDate(0, 0, -30)},
+		},
+	}
+
+	cfg := &config.Config{
+		StorageProvider: "s3",
+		RetentionDays:   7,
+	}
+
+	orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{}, logger)
+	if _, err := orchestrator.cleanupOldBackups(context.Background(), false); err != nil {
+		t.Fatalf("cleanupOldBackups() error = %v", err)
+	}
+
+	if !slices.Equal(mockStorage.deleteCalls, []string{"imported/old.sql.gz"}) {
+		t.Errorf("deleted %v, want [imported/old.sql.gz]", mockStorage.deleteCalls)
+	}
+}
+
+func TestOrchestrator_CleanupPinned(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// The pinned backup's sidecar is listed before it and is kept with it
+	old := time.Now().AddDate(0, 0, -30).UTC()
+	pinnedKey := "test-" + old.Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+	expiredKey := "test-" + old.Add(time.Hour).Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+	mockStorage := &mockStorage{
+		listResult: []storage.ObjectInfo{
+			{Key: pinnedKey + utils.SettingsSuffix, LastModified: old},
+			{
+				Key:          pinnedKey,
+				LastModified: old,
+				Metadata:     map[string]string{"backup-timestamp": old.Format(time.RFC3339), MetadataKeyKeep: "true"},
+			},
+			{Key: expiredKey, LastModified: old},
+		},
+	}
+
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		RetentionDays:    7,
+	}
+
+	orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{}, logger)
+	deleted, err := orchestrator.cleanupOldBackups(context.Background(), true)
+	if err != nil {
+		t.Fatalf("cleanupOldBackups() error = %v", err)
+	}
+	if !slices.Equal(deleted, []string{expiredKey}) {
+		t.Errorf("dry run would delete %v, want [%s]", deleted, expiredKey)
+	}
+
+	if _, err := orchestrator.cleanupOldBackups(context.Background(), false); err != nil {
+		t.Fatalf("cleanupOldBackups() error = %v", err)
+	}
+	if !slices.Equal(mockStorage.deleteCalls, []string{expiredKey}) {
+		t.Errorf("deleted %v, want [%s]", mockStorage.deleteCalls, expiredKey)
+	}
+}
+
+func TestOrchestrator_CleanupMetadataUnreadable(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// The listing carries no metadata, as on S3 and GCS, and one backup's HEAD fails
+	old := time.Now().AddDate(0, 0, -30).UTC()
+	unreadableKey := "test-" + old.Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+	expiredKey := "test-" + old.Add(time.Hour).Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+	mock := &mockStorage{
+		listResult: []storage.ObjectInfo{
+			{Key: unreadableKey + utils.ChecksumSuffix, LastModified: old},
+			{Key: unreadableKey, LastModified: old},
+			{Key: expiredKey, LastModified: old},
+		},
+	}
+	store := &statFailingStorage{mockStorage: mock, key: unreadableKey}
+
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		RetentionDays:    7,
+	}
+
+	if _, err := NewOrchestrator(cfg, store, &mockBackup{}, logger).cleanupOldBackups(context.Background(), false); err != nil {
+		t.Fatalf("cleanupOldBackups() error = %v", err)
+	}
+	if !slices.Equal(mock.deleteCalls, []string{expiredKey}) {
+		t.Errorf("deleted %v, want only [%s] while the other backup's metadata is unreadable", mock.deleteCalls, expiredKey)
+	}
+}
+
+func TestOrchestrator_CleanupMinKeep(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Every backup expired while backups were paused
+	var listing []storage.ObjectInfo
+	var keys []string
+	for days := 40; days <= 42; days++ {
+		taken := time.Now().AddDate(0, 0, -days).UTC()
+		key := "test-" + taken.Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+		keys = append(keys, key)
+		listing = append(listing,
+			storage.ObjectInfo{Key: key + utils.ChecksumSuffix, LastModified: taken},
+			storage.ObjectInfo{Key: key, LastModified: taken})
+	}
+
+	tests := []struct {
+		name    string
+		minKeep int
+		want    []string
+	}{
+		{"keeps the newest", 1, []string{keys[1] + utils.ChecksumSuffix, keys[1], keys[2] + utils.ChecksumSuffix, keys[2]}},
+		{"keeps the newest two", 2, []string{keys[2] + utils.ChecksumSuffix, keys[2]}},
+		{"keeps more than are stored", 5, nil},
+		{"disabled", 0, []string{keys[0] + utils.ChecksumSuffix, keys[0], keys[1] + utils.ChecksumSuffix, keys[1], keys[2] + utils.ChecksumSuffix, keys[2]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mockStorage{listResult: listing}
+			cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "test", RetentionDays: 7, RetentionMinKeep: tt.minKeep}
+			if _, err := NewOrchestrator(cfg, mockStorage, &mockBackup{}, logger).cleanupOldBackups(context.Background(), false); err != nil {
+				t.Fatalf("cleanupOldBackups() error = %v", err)
+			}
+			if !slices.Equal(mockStorage.deleteCalls, tt.want) {
+				t.Errorf("deleted %v, want %v", mockStorage.deleteCalls, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_QuotaExceeded(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var listing []storage.ObjectInfo
+	var keys []string
+	for _, days := range []int{2, 5} {
+		taken := time.Now().AddDate(0, 0, -days).UTC()
+		key := "test-" + taken.Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+		keys = append(keys, key)
+		listing = append(listing, storage.ObjectInfo{Key: key, LastModified: taken})
+	}
+	full := fmt.Errorf("%w: XMinioStorageFull", storage.ErrQuotaExceeded)
+
+	tests := []struct {
+		name          string
+		emergencyDays int
+		wantDeleted   []string
+		wantMessage   string
+	}{
+		{"alerts without pruning", 0, nil, "set QUOTA_EMERGENCY_RETENTION_DAYS"},
+		{"emergency retention", 3, []string{keys[1]}, "emergency retention deleted 1 objects older than 3 days"},
+		{"nothing old enough", 30, nil, "freed nothing older than 30 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mockStorage{uploadErr: full, listResult: listing}
+			cfg := &config.Config{
+				StorageProvider:             "s3",
+				BackupFilePrefix:            "test",
+				RetentionDays:               30,
+				RetentionMinKeep:            1,
+				QuotaEmergencyRetentionDays: tt.emergencyDays,
+			}
+
+			err := NewOrchestrator(cfg, mockStorage, &mockBackup{dumpData: "backup data"}, logger).Run(context.Background())
+			if !errors.Is(err, storage.ErrQuotaExceeded) {
+				t.Fatalf("Run() error = %v, want ErrQuotaExceeded", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("Run() error = %q, want it to contain %q", err, tt.wantMessage)
+			}
+			if !slices.Equal(mockStorage.deleteCalls, tt.wantDeleted) {
+				t.Errorf("deleted %v, want %v", mockStorage.deleteCalls, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_QuotaExceededDestinations(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var listing []storage.ObjectInfo
+	var keys []string
+	for _, days := range []int{2, 5, 10} {
+		taken := time.Now().AddDate(0, 0, -days).UTC()
+		key := "test-" + taken.Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+		keys = append(keys, key)
+		listing = append(listing, storage.ObjectInfo{Key: key, LastModified: taken})
+	}
+	full := fmt.Errorf("%w: XMinioStorageFull", storage.ErrQuotaExceeded)
+
+	// The emergency period only shortens the retention of the full destinations
+	// that have one: the period configured for r2 is already shorter
+	s3 := &mockStorage{uploadErr: full, listResult: listing}
+	r2 := &mockStorage{uploadErr: full, listResult: listing}
+	filesystem := &mockStorage{uploadErr: full, listResult: listing}
+	gcs := &mockStorage{listResult: listing}
+	store := storage.NewMultiStorage([]storage.Destination{
+		{Name: "s3", Storage: s3, RetentionDays: 30},
+		{Name: "r2", Storage: r2, RetentionDays: 4},
+		{Name: "filesystem", Storage: filesystem},
+		{Name: "gcs", Storage: gcs, RetentionDays: 30},
+	}, true, logger)
+	cfg := &config.Config{
+		StorageProvider:             "s3",
+		BackupFilePrefix:            "test",
+		RetentionDays:               30,
+		QuotaEmergencyRetentionDays: 8,
+	}
+
+	err := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger).Run(context.Background())
+	if !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Fatalf("Run() error = %v, want ErrQuotaExceeded", err)
+	}
+	for _, tt := range []struct {
+		name string
+		mock *mockStorage
+		want []string
+	}{
+		{"s3", s3, []string{keys[2]}},
+		{"r2", r2, []string{keys[1], keys[2]}},
+		{"filesystem", filesystem, nil},
+		{"gcs", gcs, nil},
+	} {
+		slices.Sort(tt.mock.deleteCalls)
+		want := slices.Sorted(slices.Values(tt.want))
+		if !slices.Equal(tt.mock.deleteCalls, want) {
+			t.Errorf("%s: deleted %v, want %v", tt.name, tt.mock.deleteCalls, want)
+		}
+	}
+}
+
+func TestOrchestrator_SpoolDiskSpace(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	if available, _ := utils.AvailableDiskSpace(dir); available == math.MaxInt64 {
+		t.Skip("disk space is not reported on this platform")
+	}
+
+	// Uploads to endpoints without multipart uploads are spooled to
+	// BACKUP_TMPDIR; reads go to the first destination, so S3 is never reached
+	s3, err := storage.NewS3Storage(context.Background(), storage.S3Config{
+		Bucket: "backups", Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "secret",
+		SinglePut: true, TempDir: dir,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage() error = %v", err)
+	}
+	replicated := storage.NewMultiStorage([]storage.Destination{
+		{Name: "primary", Storage: &mockStorage{}},
+		{Name: "s3", Storage: s3},
+	}, true, logger)
+
+	tests := []struct {
+		name    string
+		store   storage.Storage
+		wantErr bool
+	}{
+		{"spooled uploads", replicated, true},
+		{"streamed uploads", &mockStorage{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "test", Compression: "none", TempDir: dir}
+			backup := &mockBackup{dumpData: "backup data", info: &DatabaseInfo{Name: "testdb", Size: math.MaxInt64, Version: "16.2"}}
+
+			_, err := NewOrchestrator(cfg, tt.store, backup, logger).Execute(context.Background())
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Execute() error = %v, want the spool directory unchecked", err)
+				}
+				return
+			}
+			if !errors.Is(err, utils.ErrInsufficientDiskSpace) || !strings.Contains(err.Error(), "set BACKUP_TMPDIR") {
+				t.Errorf("Execute() error = %v, want ErrInsufficientDiskSpace naming BACKUP_TMPDIR", err)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_RetentionDryRun(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	old := time.Now().AddDate(0, 0, -30).UTC().Truncate(time.Second)
+	oldKey := "test-" + old.Format("2006-01-02T15-04-05-000Z") + ".tar.gz"
+	mockStorage := &mockStorage{
+		objects: make(map[string][]byte),
+		listResult: []storage.ObjectInfo{
+			{Key: oldKey, LastModified: old},
+			{Key: oldKey + utils.ChecksumSuffix, LastModified: old},
+		},
+	}
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		RetentionDays:    7,
+		RetentionDryRun:  true,
+	}
+
+	orchestrator := NewOrchestrator(cfg, mockStorage, &mockBackup{dumpData: "backup data"}, logger)
+	var retention []hooks.Event
+	orchestrator.RegisterHook(hooks.Func(func(ctx context.Context, e hooks.Event) error {
+		if e.Type == hooks.EventRetention {
+			retention = append(retention, e)
+		}
+		return nil
+	}))
+
+	result, err := orchestrator.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(mockStorage.deleteCalls) != 0 || len(result.Pruned) != 0 {
+		t.Errorf("deleted %v, pruned %v; want nothing deleted", mockStorage.deleteCalls, result.Pruned)
+	}
+
+	var report storage.RetentionReport
+	if err := json.Unmarshal(mockStorage.objects[storage.RetentionReportKey], &report); err != nil {
+		t.Fatalf("retention report: %v", err)
+	}
+	want := []hooks.Deletion{
+		{Key: oldKey, BackupTime: old, Reason: "older than 7 days"},
+		{Key: oldKey + utils.ChecksumSuffix, BackupTime: old, Reason: "sidecar of " + oldKey + ", older than 7 days"},
+	}
+	if report.Destination != "s3" || report.RetentionDays != 7 || !slices.EqualFunc(report.WouldDelete, want, equalDeletion) {
+		t.Errorf("retention report = %+v, want %+v", report, want)
+	}
+
+	if len(retention) != 1 || !retention[0].DryRun || !slices.EqualFunc(retention[0].Deletions, want, equalDeletion) {
+		t.Errorf("retention events = %+v, want one dry run event with the report", retention)
+	}
+}
+
+func equalDeletion(a, b hooks.Deletion) bool {
+	return a.Key == b.Key && a.BackupTime.Equal(b.BackupTime) && a.Reason == b.Reason
+}
+
+func TestOrchestrator_CleanupPerDestination(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	fiveDaysAgo := time.Now().AddDate(0, 0, -5)
+	listing := []storage.ObjectInfo{
+		{
+			Key:          "test-" + fiveDaysAgo.UTC().Format("2006-01-02T15-04-05") + "-000Z.tar.gz",
+			LastModified: fiveDaysAgo,
+		},
+	}
+
+	shortRetention := &mockStorage{listResult: listing}
+	longRetention := &mockStorage{listResult: listing}
+	multi := storage.NewMultiStorage([]storage.Destination{
+		{Name: "filesystem", Storage: shortRetention, RetentionDays: 2},
+		{Name: "s3", Storage: longRetention, RetentionDays: 30},
+	}, true, logger)
+
+	cfg := &config.Config{
+		StorageProvider:  "filesystem,s3",
+		BackupFilePrefix: "test",
+	}
+
+	orchestrator := NewOrchestrator(cfg, multi, &mockBackup{}, logger)
+	if _, err := orchestrator.cleanupOldBackups(context.Background(), false); err != nil {
+		t.Fatalf("cleanupOldBackups() error = %v", err)
+	}
+
+	if len(shortRetention.deleteCalls) != 1 {
+		t.Errorf("short retention destination deletions = %d, want 1", len(shortRetention.deleteCalls))
+	}
+	if len(longRetention.deleteCalls) != 0 {
+		t.Errorf("long retention destination deletions = %d, want 0", len(longRetention.deleteCalls))
+	}
+}
+
 func TestNewOrchestrator(t *testing.T) {
 	cfg := &config.Config{
 		StorageProvider:        "s3",
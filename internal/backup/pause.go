@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// pauseReason returns why backups are paused, or "" when they are not:
+// BACKUP_PAUSED holds this instance, a pause object set through the API holds
+// every instance sharing the bucket. A pause object that cannot be read is
+// logged and ignored, as missing a backup is worse than running one.
+func (o *Orchestrator) pauseReason(ctx context.Context) string {
+	if o.config.BackupPaused {
+		return "backups are paused by BACKUP_PAUSED"
+	}
+
+	pause, err := storage.ReadPause(ctx, o.storage)
+	if err != nil {
+		o.logger.Warn("Failed to read pause state, proceeding", "error", err)
+		return ""
+	}
+	if pause == nil {
+		return ""
+	}
+	reason := fmt.Sprintf("backups are paused since %s", pause.PausedAt.UTC().Format("2006-01-02T15:04:05Z"))
+	if pause.Reason != "" {
+		reason += ": " + pause.Reason
+	}
+	return reason
+}
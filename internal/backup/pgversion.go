@@ -164,7 +164,7 @@ func getServerVersionWithBinary(ctx context.Context, connectionURL string, psqlB
 			delay = time.Duration(math.Min(nextDelay, float64(retryConfig.MaxDelay)))
 		}
 
-		cmd := exec.CommandContext(ctx, psqlBin,
+		cmd := command(ctx, psqlBin,
 			"--no-password",
 			"--tuples-only",
 			"--no-align",
@@ -195,17 +195,17 @@ func getServerVersionWithBinary(ctx context.Context, connectionURL string, psqlB
 		}
 
 		// Record the error for this attempt
-		attemptErrors = append(attemptErrors, fmt.Sprintf("attempt %d: %v (stderr: %s)", attempt+1, err, stderr.String()))
+		attemptErrors = append(attemptErrors, fmt.Sprintf("attempt %d: %v (stderr: %s)", attempt+1, err, stderrText(&stderr)))
 
 		// Check if this is a connection error that we should retry
 		if isRetryableError(err) {
 			logger.Warn("Retryable error encountered",
 				"attempt", attempt+1,
 				"error", err,
-				"stderr", stderr.String())
+				"stderr", stderrText(&stderr))
 		} else {
 			// If it's not retryable, return immediately
-			return nil, fmt.Errorf("non-retryable error: %w (stderr: %s)", err, stderr.String())
+			return nil, fmt.Errorf("non-retryable error: %w (stderr: %s)", err, stderrText(&stderr))
 		}
 	}
 
@@ -298,3 +298,18 @@ func FindBestPSQL(serverVersion *PGVersion) (string, error) {
 
 	return "", fmt.Errorf("no suitable psql found for PostgreSQL %d", serverVersion.Major)
 }
+
+// FindNewestPGRestore finds the newest pg_restore binary, which can read the
+// archives of every older pg_dump.
+func FindNewestPGRestore() (string, error) {
+	for _, v := range []int{17, 16, 15} {
+		bin := fmt.Sprintf("pg_restore%d", v)
+		if _, err := exec.LookPath(bin); err == nil {
+			return bin, nil
+		}
+	}
+	if _, err := exec.LookPath("pg_restore"); err == nil {
+		return "pg_restore", nil
+	}
+	return "", fmt.Errorf("no pg_restore binary found")
+}
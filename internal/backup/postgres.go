@@ -2,9 +2,10 @@ package backup
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
-	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,25 +13,73 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
 )
 
+// customFormatMagic is the header of a pg_dump --format=custom archive.
+const customFormatMagic = "PGDMP"
+
+// ErrDumpStalled is returned when pg_dump stops producing output for longer than the stall timeout.
+var ErrDumpStalled = errors.New("pg_dump stalled")
+
 // PostgresBackup implements the Backup interface for PostgreSQL databases.
 type PostgresBackup struct {
-	connectionURL string
-	pgDumpOptions []string
-	pgDumpBin     string
-	psqlBin       string
-	logger        *slog.Logger
+	connectionURL  string
+	pgDumpOptions  []string
+	pgDumpBin      string
+	psqlBin        string
+	stallTimeout   time.Duration
+	lockDiag       bool
+	compressor     compression.Compressor
+	tableFilters   []config.TableFilter
+	dumpFilter     *config.DumpFilter
+	tempDir        string
+	limits         utils.ProcessLimits
+	cgroupAware    bool
+	exportFallback bool
+	dataOnly       bool
+	logger         *slog.Logger
+}
+
+// PostgresConfig holds configuration for PostgreSQL backups.
+type PostgresConfig struct {
+	ConnectionURL  string
+	PGDumpOptions  string
+	StallTimeout   time.Duration          // Abort the dump when no output is produced for this long (0 disables)
+	LockDiagnosis  bool                   // Report sessions holding blocking locks when a dump stalls or fails
+	Compressor     compression.Compressor // Codec applied to pg_dump output (defaults to gzip)
+	TableFilters   []config.TableFilter   // Per-table row filters (plain format only)
+	DumpFilter     *config.DumpFilter     // Object selection written to a --filter file (pg_dump 17+)
+	TempDir        string                 // Where filter files and parallel-restore archives are spooled (defaults to TMPDIR)
+	ProcessLimits  utils.ProcessLimits    // CPU and I/O priority applied to pg_dump and restore processes
+	CgroupAware    bool                   // Cap parallel restore workers at the container's CPU quota
+	ExportFallback bool                   // Export with the built-in exporter when no pg_dump binary can be run
+	DataOnly       bool                   // Dump data only; SCHEMA_DEDUP stores the schema apart
 }
 
 // NewPostgresBackup creates a new PostgreSQL backup instance.
 func NewPostgresBackup(connectionURL string, pgDumpOptions string) *PostgresBackup {
+	return NewPostgresBackupWithConfig(PostgresConfig{
+		ConnectionURL: connectionURL,
+		PGDumpOptions: pgDumpOptions,
+	})
+}
+
+// NewPostgresBackupWithConfig creates a new PostgreSQL backup instance from a full configuration.
+func NewPostgresBackupWithConfig(cfg PostgresConfig) *PostgresBackup {
+	connectionURL := cfg.ConnectionURL
+
 	// Parse pg_dump options from string
 	var options []string
-	if pgDumpOptions != "" {
+	if cfg.PGDumpOptions != "" {
 		// Simple parsing - could be improved to handle quoted arguments
-		options = strings.Fields(pgDumpOptions)
+		options = strings.Fields(cfg.PGDumpOptions)
 	}
 
 	logger := slog.Default().With("component", "postgres-backup")
@@ -39,10 +88,23 @@ func NewPostgresBackup(connectionURL string, pgDumpOptions string) *PostgresBack
 	availablePSQL := findAvailablePSQL()
 
 	pb := &PostgresBackup{
-		connectionURL: connectionURL,
-		pgDumpOptions: options,
-		logger:        logger,
-		psqlBin:       availablePSQL, // Set initial psql binary
+		connectionURL:  connectionURL,
+		pgDumpOptions:  options,
+		stallTimeout:   cfg.StallTimeout,
+		lockDiag:       cfg.LockDiagnosis,
+		compressor:     cfg.Compressor,
+		tableFilters:   cfg.TableFilters,
+		tempDir:        cfg.TempDir,
+		limits:         cfg.ProcessLimits,
+		cgroupAware:    cfg.CgroupAware,
+		exportFallback: cfg.ExportFallback,
+		dataOnly:       cfg.DataOnly,
+		logger:         logger,
+		psqlBin:        availablePSQL, // Set initial psql binary
+	}
+
+	if !cfg.DumpFilter.IsEmpty() {
+		pb.dumpFilter = cfg.DumpFilter
 	}
 
 	// Try to detect PostgreSQL version and find appropriate binaries
@@ -52,7 +114,13 @@ func NewPostgresBackup(connectionURL string, pgDumpOptions string) *PostgresBack
 	if version, err := GetServerVersion(ctx, connectionURL); err == nil {
 		logger.Info("Detected PostgreSQL version", "version", version.Full, "major", version.Major)
 
-		if pgDumpBin, err := FindBestPGDump(version); err == nil {
+		// A newer pg_dump can dump older servers, so filters select at least pg_dump 17
+		dumpVersion := version
+		if pb.dumpFilter != nil && version.Major < minFilterFileVersion {
+			dumpVersion = &PGVersion{Major: minFilterFileVersion}
+		}
+
+		if pgDumpBin, err := FindBestPGDump(dumpVersion); err == nil {
 			pb.pgDumpBin = pgDumpBin
 			logger.Info("Selected pg_dump binary", "binary", pgDumpBin)
 		}
@@ -77,21 +145,51 @@ func NewPostgresBackup(connectionURL string, pgDumpOptions string) *PostgresBack
 
 // Dump creates a backup of the PostgreSQL database.
 func (p *PostgresBackup) Dump(ctx context.Context) (io.ReadCloser, error) {
+	if p.exportFallback && p.pgDumpMissing() {
+		return p.exportDump(ctx)
+	}
+
 	// Build pg_dump command
 	args := []string{
 		"--format=tar",
 		"--verbose",
 		"--no-password",
 	}
+	if p.dataOnly {
+		args = append(args, "--data-only")
+	}
 
 	// Add custom options
 	args = append(args, p.pgDumpOptions...)
 
+	// Filtered tables are dumped without data; their rows are appended afterwards
+	args = append(args, tableFilterArgs(p.tableFilters)...)
+
+	// Object selection is passed as a generated --filter file
+	var filterFile *utils.SpoolFile
+	if p.dumpFilter != nil {
+		var err error
+		if filterFile, err = p.writeFilterFile(ctx); err != nil {
+			return nil, err
+		}
+		args = append(args, "--filter="+filterFile.Name())
+	}
+
 	// Add connection URL last
 	args = append(args, p.connectionURL)
 
+	// The dump gets its own context so the stall watchdog can kill it;
+	// the filter file is removed whenever the dump ends
+	dumpCtx, cancelCtx := context.WithCancel(ctx)
+	cancelDump := func() {
+		cancelCtx()
+		if filterFile != nil {
+			_ = filterFile.Cleanup()
+		}
+	}
+
 	// Create command with the appropriate pg_dump binary
-	cmd := exec.CommandContext(ctx, p.pgDumpBin, args...)
+	cmd := command(dumpCtx, p.pgDumpBin, args...)
 
 	// Set environment to avoid password prompts
 	cmd.Env = append(os.Environ(), "PGPASSWORD=")
@@ -99,6 +197,7 @@ func (p *PostgresBackup) Dump(ctx context.Context) (io.ReadCloser, error) {
 	// Get stdout pipe
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
+		cancelDump()
 		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
@@ -107,35 +206,85 @@ func (p *PostgresBackup) Dump(ctx context.Context) (io.ReadCloser, error) {
 	cmd.Stderr = &stderr
 
 	// Start the command
-	if err := cmd.Start(); err != nil {
+	if err := p.startLimited(cmd); err != nil {
+		cancelDump()
 		return nil, fmt.Errorf("failed to start pg_dump: %w", err)
 	}
 
-	// Create a pipe for gzip compression
+	// Track read activity so a silent pg_dump can be detected
+	output := &activityReader{reader: stdout}
+	var stalled atomic.Bool
+
+	// Create a pipe for compression
 	pr, pw := io.Pipe()
 
+	var blockers []utils.BlockingSession
+	if p.stallTimeout > 0 {
+		go watchStall(dumpCtx, output, p.stallTimeout, func() {
+			// Capture lock holders while pg_dump is still waiting on them
+			blockers = p.findBlockingSessions(ctx)
+			stalled.Store(true)
+			p.logger.Error("pg_dump produced no output within stall timeout, aborting",
+				"stall_timeout", p.stallTimeout)
+			metrics.DumpStalls.Inc()
+			cancelDump()
+		})
+	}
+
 	// Start a goroutine to compress the output
+	codec := p.codec()
 	go func() {
-		// Create gzip writer
-		gw := gzip.NewWriter(pw)
-
-		// Copy from pg_dump to gzip
-		_, copyErr := io.Copy(gw, stdout)
+		defer cancelDump()
 
-		// Close gzip writer
-		if closeErr := gw.Close(); closeErr != nil {
-			_ = pw.CloseWithError(fmt.Errorf("failed to close gzip writer: %w", closeErr))
+		cw, err := codec.NewWriter(pw)
+		if err != nil {
+			_ = pw.CloseWithError(fmt.Errorf("failed to create %s writer: %w", codec.Name(), err))
+			_ = cmd.Wait()
 			return
 		}
 
+		// Copy from pg_dump to the compressor, with the filtered table rows
+		// in the data section of the dump
+		var copyErr, filterErr error
+		if len(p.tableFilters) > 0 {
+			copyErr = spliceFilteredRows(cw, output, func(w io.Writer) error {
+				filterErr = p.dumpFilteredRows(dumpCtx, w)
+				return filterErr
+			})
+			if filterErr != nil {
+				// pg_dump is left blocked on the rest of its output
+				cancelDump()
+			}
+		} else {
+			_, copyErr = io.Copy(cw, output)
+		}
+
 		// Wait for pg_dump to finish
 		waitErr := cmd.Wait()
+		if filterFile != nil {
+			_ = filterFile.Cleanup()
+		}
+
+		// Close compressor to flush remaining data
+		if closeErr := cw.Close(); closeErr != nil {
+			_ = pw.CloseWithError(fmt.Errorf("failed to close %s writer: %w", codec.Name(), closeErr))
+			return
+		}
 
 		// Close the pipe writer with appropriate error
-		if copyErr != nil {
+		if stalled.Load() {
+			err := fmt.Errorf("%w: no output for %s, stderr: %s", ErrDumpStalled, p.stallTimeout, stderrText(&stderr))
+			_ = pw.CloseWithError(withBlockingSessions(err, blockers))
+		} else if filterErr != nil {
+			_ = pw.CloseWithError(fmt.Errorf("failed to dump filtered rows: %w", filterErr))
+		} else if copyErr != nil {
 			_ = pw.CloseWithError(fmt.Errorf("failed to compress backup: %w", copyErr))
 		} else if waitErr != nil {
-			_ = pw.CloseWithError(fmt.Errorf("pg_dump failed: %w, stderr: %s", waitErr, stderr.String()))
+			err := fmt.Errorf("pg_dump failed: %w, stderr: %s", waitErr, stderrText(&stderr))
+			if isLockError(stderr.String()) {
+				err = withBlockingSessions(err, p.findBlockingSessions(ctx))
+			}
+			_ = pw.CloseWithError(err)
 		} else {
 			_ = pw.Close()
 		}
@@ -144,19 +293,87 @@ func (p *PostgresBackup) Dump(ctx context.Context) (io.ReadCloser, error) {
 	return pr, nil
 }
 
+// codec returns the configured compressor, defaulting to gzip.
+func (p *PostgresBackup) codec() compression.Compressor {
+	if p.compressor != nil {
+		return p.compressor
+	}
+	// gzip at the default level is always registered and cannot fail
+	c, _ := compression.Get("gzip", compression.DefaultLevel)
+	return c
+}
+
+// activityReader records when a blocking read started so stalls can be detected.
+type activityReader struct {
+	reader      io.Reader
+	readStarted atomic.Int64 // Unix nanoseconds of the in-flight read, 0 when idle
+}
+
+// Read implements io.Reader.
+func (a *activityReader) Read(p []byte) (int, error) {
+	a.readStarted.Store(time.Now().UnixNano())
+	n, err := a.reader.Read(p)
+	a.readStarted.Store(0)
+	return n, err
+}
+
+// waitingSince returns how long the in-flight read has been blocked.
+func (a *activityReader) waitingSince() time.Duration {
+	started := a.readStarted.Load()
+	if started == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, started))
+}
+
+// watchStall calls onStall once if a read on r stays blocked longer than timeout.
+// Time spent waiting on the downstream consumer does not count as a stall.
+func watchStall(ctx context.Context, r *activityReader, timeout time.Duration, onStall func()) {
+	interval := timeout / 4
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if r.waitingSince() > timeout {
+				onStall()
+				return
+			}
+		}
+	}
+}
+
 // Validate checks if a backup file is valid.
 func (p *PostgresBackup) Validate(ctx context.Context, reader io.Reader) error {
-	// Create gzip reader
-	gr, err := gzip.NewReader(reader)
+	// Create decompressing reader
+	codec := p.codec()
+	cr, err := codec.NewReader(reader)
 	if err != nil {
-		return fmt.Errorf("invalid gzip format: %w", err)
+		return fmt.Errorf("invalid %s format: %w", codec.Name(), err)
 	}
 	defer func() {
-		_ = gr.Close()
+		_ = cr.Close()
 	}()
 
+	// Custom-format archives carry their own header and are not tar files,
+	// and plain SQL dumps and fallback exports open with a comment
+	br := bufio.NewReader(cr)
+	if magic, err := br.Peek(len(customFormatMagic)); err == nil && string(magic) == customFormatMagic {
+		return nil
+	}
+	if magic, err := br.Peek(len("--")); err == nil && string(magic) == "--" {
+		return nil
+	}
+
 	// Create tar reader
-	tr := tar.NewReader(gr)
+	tr := tar.NewReader(br)
 
 	// Check if we can read at least one entry
 	_, err = tr.Next()
@@ -177,6 +394,11 @@ func (p *PostgresBackup) Validate(ctx context.Context, reader io.Reader) error {
 
 // GetInfo returns information about the database with retry logic.
 func (p *PostgresBackup) GetInfo(ctx context.Context) (*DatabaseInfo, error) {
+	if p.exportFallback {
+		if _, err := exec.LookPath(p.psqlBin); err != nil {
+			return p.exportInfo(ctx)
+		}
+	}
 	return p.GetInfoWithRetry(ctx, defaultPSQLRetryConfig())
 }
 
@@ -187,6 +409,7 @@ func (p *PostgresBackup) GetInfoWithRetry(ctx context.Context, retryConfig Retry
 		SELECT 
 			current_database() as name,
 			pg_database_size(current_database()) as size,
+			(SELECT coalesce(string_agg(extname, ',' ORDER BY extname), '') FROM pg_extension) as extensions,
 			version() as version
 	`
 
@@ -214,7 +437,7 @@ func (p *PostgresBackup) GetInfoWithRetry(ctx context.Context, retryConfig Retry
 		}
 
 		// Use psql to execute the query
-		cmd := exec.CommandContext(ctx, p.psqlBin,
+		cmd := command(ctx, p.psqlBin,
 			"--no-password",
 			"--tuples-only",
 			"--no-align",
@@ -234,8 +457,8 @@ func (p *PostgresBackup) GetInfoWithRetry(ctx context.Context, retryConfig Retry
 		output, err := cmd.Output()
 		if err == nil {
 			// Parse output
-			parts := strings.Split(strings.TrimSpace(string(output)), "|")
-			if len(parts) != 3 {
+			parts := strings.SplitN(strings.TrimSpace(string(output)), "|", 4)
+			if len(parts) != 4 {
 				err = fmt.Errorf("unexpected output format from psql: %s", string(output))
 			} else {
 				// Parse size
@@ -248,9 +471,10 @@ func (p *PostgresBackup) GetInfoWithRetry(ctx context.Context, retryConfig Retry
 				}
 
 				return &DatabaseInfo{
-					Name:    strings.TrimSpace(parts[0]),
-					Size:    size,
-					Version: strings.TrimSpace(parts[2]),
+					Name:       strings.TrimSpace(parts[0]),
+					Size:       size,
+					Version:    strings.TrimSpace(parts[3]),
+					Extensions: ParseExtensions(parts[2]),
 				}, nil
 			}
 		} else if exitErr, ok := err.(*exec.ExitError); ok {
@@ -259,20 +483,39 @@ func (p *PostgresBackup) GetInfoWithRetry(ctx context.Context, retryConfig Retry
 		}
 
 		// Record the error for this attempt
-		attemptErrors = append(attemptErrors, fmt.Sprintf("attempt %d: %v (stderr: %s)", attempt+1, err, stderr.String()))
+		attemptErrors = append(attemptErrors, fmt.Sprintf("attempt %d: %v (stderr: %s)", attempt+1, err, stderrText(&stderr)))
 
 		// Check if this is a connection error that we should retry
 		if isRetryableError(err) {
 			p.logger.Warn("Retryable error encountered",
 				"attempt", attempt+1,
 				"error", err,
-				"stderr", stderr.String())
+				"stderr", stderrText(&stderr))
 		} else {
 			// If it's not retryable, return immediately
-			return nil, fmt.Errorf("non-retryable error: %w (stderr: %s)", err, stderr.String())
+			return nil, fmt.Errorf("non-retryable error: %w (stderr: %s)", err, stderrText(&stderr))
 		}
 	}
 
 	return nil, fmt.Errorf("failed to get database info after %d retries (errors: %v)",
 		retryConfig.MaxRetries, attemptErrors)
 }
+
+// Binaries returns the pg_dump and psql binaries selected for the server version.
+func (p *PostgresBackup) Binaries() map[string]string {
+	return map[string]string{"pg_dump": p.pgDumpBin, "psql": p.psqlBin}
+}
+
+// startLimited starts cmd and lowers its priority according to the configured
+// process limits. Failing to apply the limits is logged, not fatal.
+func (p *PostgresBackup) startLimited(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if !p.limits.IsZero() {
+		if err := p.limits.Apply(cmd.Process.Pid); err != nil {
+			p.logger.Warn("Failed to apply process limits", "binary", cmd.Path, "error", err)
+		}
+	}
+	return nil
+}
@@ -5,9 +5,17 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
 )
 
 func TestNewPostgresBackup(t *testing.T) {
@@ -133,6 +141,17 @@ func TestPostgresBackup_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "gzip",
 		},
+		{
+			name: "custom format archive",
+			data: func() io.Reader {
+				var buf bytes.Buffer
+				gw := gzip.NewWriter(&buf)
+				_, _ = gw.Write([]byte("PGDMP\x01\x10\x00"))
+				_ = gw.Close()
+				return &buf
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid tar",
 			data: func() io.Reader {
@@ -165,6 +184,22 @@ func TestPostgresBackup_Validate(t *testing.T) {
 	}
 }
 
+func TestPostgresBackup_ValidateUncompressed(t *testing.T) {
+	none, err := compression.Get("none", compression.DefaultLevel)
+	if err != nil {
+		t.Fatalf("Get(none) error = %v", err)
+	}
+	pb := &PostgresBackup{compressor: none}
+
+	if err := pb.Validate(context.Background(), strings.NewReader("PGDMP\x01\x10\x00")); err != nil {
+		t.Errorf("Validate() raw custom archive error = %v", err)
+	}
+
+	if err := pb.Validate(context.Background(), strings.NewReader("")); err == nil {
+		t.Error("Validate() empty input expected error, got nil")
+	}
+}
+
 // Integration tests would require a real PostgreSQL instance
 func TestPostgresBackup_Integration(t *testing.T) {
 	if testing.Short() {
@@ -192,3 +227,76 @@ func TestPostgresBackup_Integration(t *testing.T) {
 	//     t.Fatal(err)
 	// }
 }
+
+func TestWatchStall(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer func() {
+		_ = pw.Close()
+	}()
+
+	r := &activityReader{reader: pr}
+	go func() {
+		_, _ = io.Copy(io.Discard, r)
+	}()
+
+	stalled := make(chan struct{})
+	go watchStall(context.Background(), r, 50*time.Millisecond, func() {
+		close(stalled)
+	})
+
+	select {
+	case <-stalled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchStall() did not detect a blocked reader")
+	}
+}
+
+func TestWatchStall_ActiveReader(t *testing.T) {
+	// A reader that is not blocked in Read is never considered stalled,
+	// even when the downstream consumer is slow
+	r := &activityReader{reader: strings.NewReader("data")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	called := false
+	watchStall(ctx, r, 20*time.Millisecond, func() {
+		called = true
+	})
+
+	if called {
+		t.Error("watchStall() reported a stall for an idle reader")
+	}
+}
+
+func TestPostgresBackup_DumpStalled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// Fake pg_dump that hangs without producing output
+	script := filepath.Join(t.TempDir(), "pg_dump")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pb := &PostgresBackup{
+		connectionURL: "postgres://localhost/test",
+		pgDumpBin:     script,
+		stallTimeout:  100 * time.Millisecond,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	reader, err := pb.Dump(context.Background())
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	_, err = io.ReadAll(reader)
+	if !errors.Is(err, ErrDumpStalled) {
+		t.Errorf("reading stalled dump error = %v, want ErrDumpStalled", err)
+	}
+}
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+)
+
+// preconditionQueries implements the built-in config.PreconditionChecks.
+var preconditionQueries = map[string]string{
+	"replication_lag_seconds": `SELECT CASE WHEN pg_is_in_recovery()
+		THEN coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)
+		ELSE 0 END`,
+	"long_transaction_seconds": `SELECT coalesce(max(extract(epoch FROM now() - xact_start)), 0)
+		FROM pg_stat_activity
+		WHERE xact_start IS NOT NULL AND pid <> pg_backend_pid()`,
+	"dead_tuple_ratio": `SELECT coalesce(sum(n_dead_tup)::float8 / nullif(sum(n_live_tup + n_dead_tup), 0), 0)
+		FROM pg_stat_user_tables`,
+}
+
+// QueryValue implements Querier.
+func (p *PostgresBackup) QueryValue(ctx context.Context, query string) (float64, error) {
+	cmd := command(ctx, p.psqlBinary(),
+		"--no-psqlrc", "--no-password", "--tuples-only", "--no-align",
+		"--command", query,
+		p.connectionURL)
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w, stderr: %s", err, stderrText(&stderr))
+	}
+
+	value := strings.TrimSpace(string(output))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("query returned %q, want a single number", value)
+	}
+	return f, nil
+}
+
+// checkPreconditions evaluates the configured database health checks and
+// returns the reason to skip the run, or "" to proceed. Checks that cannot be
+// evaluated are logged and do not block the backup.
+func (o *Orchestrator) checkPreconditions(ctx context.Context) string {
+	if len(o.config.Preconditions) == 0 {
+		return ""
+	}
+
+	querier, ok := o.backup.(Querier)
+	if !ok {
+		o.logger.Warn("Backup provider cannot evaluate preconditions, skipping checks")
+		return ""
+	}
+
+	for _, pc := range o.config.Preconditions {
+		query := pc.Query
+		if pc.Check != "" {
+			query = preconditionQueries[pc.Check]
+		}
+
+		value, err := querier.QueryValue(ctx, query)
+		if err != nil {
+			o.logger.Warn("Failed to evaluate precondition", "precondition", pc.Label(), "error", err)
+			continue
+		}
+		if value <= pc.Max {
+			o.logger.Debug("Precondition passed", "precondition", pc.Label(), "value", value, "max", pc.Max)
+			continue
+		}
+
+		action := pc.GetAction()
+		metrics.PreconditionFailures.WithLabelValues(pc.Label(), action).Inc()
+		reason := fmt.Sprintf("precondition %s failed: %g exceeds %g", pc.Label(), value, pc.Max)
+		if action == config.PreconditionSkip {
+			return reason
+		}
+		o.logger.Warn("Precondition failed, continuing with backup", "precondition", pc.Label(), "value", value, "max", pc.Max)
+	}
+
+	return ""
+}
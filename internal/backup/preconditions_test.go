@@ -0,0 +1,18 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+func TestPreconditionQueries(t *testing.T) {
+	for _, check := range config.PreconditionChecks {
+		if preconditionQueries[check] == "" {
+			t.Errorf("built-in precondition %s has no query", check)
+		}
+	}
+	if len(preconditionQueries) != len(config.PreconditionChecks) {
+		t.Errorf("preconditionQueries has %d entries, want %d", len(preconditionQueries), len(config.PreconditionChecks))
+	}
+}
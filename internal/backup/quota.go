@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// quotaExceeded handles an upload refused because a destination is full. With
+// QUOTA_EMERGENCY_RETENTION_DAYS set, backups older than that are pruned so
+// the next run fits; pinned backups and RETENTION_MIN_KEEP still apply. The
+// returned error says what to do, for the failure notification.
+func (o *Orchestrator) quotaExceeded(ctx context.Context, uploadErr error) error {
+	days := o.config.QuotaEmergencyRetentionDays
+	if days <= 0 {
+		o.logger.Error("STORAGE FULL: the upload was refused because the bucket quota or disk is exhausted", "error", uploadErr)
+		return fmt.Errorf("backup storage is full; free space, raise the bucket quota or lower RETENTION_DAYS, "+
+			"or set QUOTA_EMERGENCY_RETENTION_DAYS to prune automatically: %w", uploadErr)
+	}
+
+	// Only the destinations that refused the upload are pruned
+	full := storage.QuotaExceededDestinations(uploadErr)
+	o.logger.Error("STORAGE FULL: the upload was refused because the bucket quota or disk is exhausted, running emergency retention",
+		"error", uploadErr, "retention_days", days, "destinations", full)
+	pruned, err := o.pruneDestinations(context.WithoutCancel(ctx), o.config.RetentionDryRun, days, full)
+	if err != nil {
+		o.logger.Warn("Emergency retention failed", "error", err)
+	}
+	if len(pruned) == 0 || o.config.RetentionDryRun {
+		return fmt.Errorf("backup storage is full and emergency retention freed nothing older than %d days; "+
+			"free space, raise the bucket quota or lower RETENTION_DAYS: %w", days, uploadErr)
+	}
+	return fmt.Errorf("backup storage is full; emergency retention deleted %d objects older than %d days, "+
+		"the next run should fit, but raise the bucket quota or lower RETENTION_DAYS to keep it from recurring: %w",
+		len(pruned), days, uploadErr)
+}
@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// tarMagic is the ustar signature found at offset 257 of a tar header block.
+const (
+	tarMagic       = "ustar"
+	tarMagicOffset = 257
+)
+
+// RestoreOptions configures how a backup is loaded into the target database.
+type RestoreOptions struct {
+	Clean             bool // Drop existing objects before recreating them (archive formats only)
+	SingleTransaction bool // Restore atomically so a failure leaves the database untouched
+
+	// Load tuning for large datasets
+	Jobs               int    // Parallel pg_restore workers; custom archives are spooled to disk first
+	SizeBytes          int64  // Size of the stored backup, checked against the free space of the spool directory
+	DisableTriggers    bool   // Load with session_replication_role=replica (requires superuser)
+	MaintenanceWorkMem string // Session maintenance_work_mem for index builds, e.g. "1GB"
+	Analyze            bool   // Run ANALYZE once the restore completes
+
+	// Schema is the plain SQL schema a data-only SCHEMA_DEDUP backup is
+	// restored onto. It drops and recreates the objects it holds, so the
+	// backup itself is restored without Clean.
+	Schema io.Reader
+}
+
+// Restore loads a decompressed backup stream into the database. The archive
+// format is detected from the stream: custom and tar archives are restored with
+// pg_restore, plain SQL dumps are replayed with psql.
+func (p *PostgresBackup) Restore(ctx context.Context, reader io.Reader, opts RestoreOptions) error {
+	if opts.Schema != nil {
+		if err := p.restoreSchema(ctx, opts.Schema, opts); err != nil {
+			return err
+		}
+		opts.Clean = false
+	}
+
+	br := bufio.NewReaderSize(reader, 4096)
+	format := DetectArchiveFormat(br)
+
+	if p.cgroupAware && opts.Jobs > 1 {
+		if cpus, ok := utils.CgroupCPULimit(); ok && opts.Jobs > cpus {
+			p.logger.Warn("Reducing parallel restore jobs to the container CPU quota", "jobs", opts.Jobs, "cpus", cpus)
+			opts.Jobs = cpus
+		}
+	}
+
+	bin, args, err := p.restoreCommand(format, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Jobs > 1 && !parallelRestore(format, opts) {
+		p.logger.Warn("Parallel restore needs a custom archive, restoring serially", "format", format)
+	}
+
+	p.logger.Info("Starting restore", "format", format, "binary", bin,
+		"clean", opts.Clean, "single_transaction", opts.SingleTransaction,
+		"jobs", max(opts.Jobs, 1), "disable_triggers", opts.DisableTriggers)
+
+	cmd := command(ctx, bin, args...)
+	cmd.Env = restoreEnv(opts)
+	cmd.Stdin = br
+
+	// pg_restore can only run workers in parallel against a seekable archive file
+	if parallelRestore(format, opts) {
+		spool, err := utils.NewSpoolFile(utils.TempDir(p.tempDir), "pg_restore-*.dump", opts.SizeBytes)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = spool.Cleanup()
+		}()
+
+		if _, err := io.Copy(spool, br); err != nil {
+			return fmt.Errorf("failed to spool archive for parallel restore: %w", err)
+		}
+		if err := spool.Sync(); err != nil {
+			return fmt.Errorf("failed to spool archive for parallel restore: %w", err)
+		}
+
+		cmd.Args = append(cmd.Args, spool.Name())
+		cmd.Stdin = nil
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := p.startLimited(cmd); err != nil {
+		return fmt.Errorf("failed to start %s: %w", bin, err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %w, stderr: %s", bin, err, stderrText(&stderr))
+	}
+
+	if opts.Analyze {
+		return p.analyze(ctx)
+	}
+	return nil
+}
+
+// restoreSchema replays the stored schema of a data-only backup with psql.
+func (p *PostgresBackup) restoreSchema(ctx context.Context, schema io.Reader, opts RestoreOptions) error {
+	p.logger.Info("Restoring stored schema before the data-only backup")
+
+	args := []string{"--no-psqlrc", "--quiet", "--no-password", "-v", "ON_ERROR_STOP=1"}
+	if opts.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	cmd := command(ctx, p.psqlBinary(), append(args, "--dbname="+p.connectionURL)...)
+	cmd.Env = restoreEnv(opts)
+	cmd.Stdin = schema
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := p.startLimited(cmd); err != nil {
+		return fmt.Errorf("failed to start %s: %w", p.psqlBinary(), err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("schema restore failed: %w, stderr: %s", err, stderrText(&stderr))
+	}
+	return nil
+}
+
+// parallelRestore reports whether pg_restore will run with several workers.
+func parallelRestore(format string, opts RestoreOptions) bool {
+	return opts.Jobs > 1 && format == FormatCustom
+}
+
+// restoreEnv returns the environment for restore commands, passing session
+// settings to every connection (including parallel workers) through PGOPTIONS.
+func restoreEnv(opts RestoreOptions) []string {
+	env := append(os.Environ(), "PGPASSWORD=")
+
+	var settings []string
+	if pgOptions := os.Getenv("PGOPTIONS"); pgOptions != "" {
+		settings = append(settings, pgOptions)
+	}
+	if opts.DisableTriggers {
+		// Skips triggers and foreign key checks while rows are loaded
+		settings = append(settings, "-c session_replication_role=replica")
+	}
+	if opts.MaintenanceWorkMem != "" {
+		settings = append(settings, "-c maintenance_work_mem="+opts.MaintenanceWorkMem)
+	}
+	if len(settings) > 0 {
+		env = append(env, "PGOPTIONS="+strings.Join(settings, " "))
+	}
+	return env
+}
+
+// analyze refreshes planner statistics, which a restore leaves empty.
+func (p *PostgresBackup) analyze(ctx context.Context) error {
+	p.logger.Info("Running ANALYZE on restored database")
+
+	cmd := command(ctx, p.psqlBinary(),
+		"--no-psqlrc", "--quiet", "--no-password",
+		"--dbname="+p.connectionURL,
+		"--command=ANALYZE")
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ANALYZE after restore failed: %w, stderr: %s", err, stderrText(&stderr))
+	}
+	return nil
+}
+
+// restoreCommand returns the binary and arguments used to restore the given format.
+func (p *PostgresBackup) restoreCommand(format string, opts RestoreOptions) (string, []string, error) {
+	if format == FormatPlain {
+		if opts.Clean {
+			return "", nil, fmt.Errorf("clean restore is not supported for plain SQL backups; dump with --clean instead")
+		}
+		args := []string{"--no-psqlrc", "--quiet", "--no-password", "-v", "ON_ERROR_STOP=1"}
+		if opts.SingleTransaction {
+			args = append(args, "--single-transaction")
+		}
+		return p.psqlBinary(), append(args, "--dbname="+p.connectionURL), nil
+	}
+
+	args := []string{"--no-password", "--exit-on-error"}
+	if opts.Clean {
+		args = append(args, "--clean", "--if-exists")
+	}
+	if parallelRestore(format, opts) {
+		if opts.SingleTransaction {
+			return "", nil, fmt.Errorf("parallel restore (%d jobs) cannot run in a single transaction", opts.Jobs)
+		}
+		args = append(args, fmt.Sprintf("--jobs=%d", opts.Jobs))
+	}
+	if opts.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	return p.pgRestoreBinary(), append(args, "--dbname="+p.connectionURL), nil
+}
+
+// pgRestoreBinary returns the pg_restore matching the selected pg_dump version.
+func (p *PostgresBackup) pgRestoreBinary() string {
+	if p.pgDumpBin == "" {
+		return "pg_restore"
+	}
+	return strings.Replace(p.pgDumpBin, "pg_dump", "pg_restore", 1)
+}
+
+// psqlBinary returns the selected psql binary, defaulting to psql.
+func (p *PostgresBackup) psqlBinary() string {
+	if p.psqlBin == "" {
+		return "psql"
+	}
+	return p.psqlBin
+}
+
+// DetectArchiveFormat inspects the start of a decompressed backup stream without
+// consuming it and reports whether it is a custom archive, a tar archive or plain SQL.
+func DetectArchiveFormat(br *bufio.Reader) string {
+	header, _ := br.Peek(tarMagicOffset + len(tarMagic))
+
+	if bytes.HasPrefix(header, []byte(customFormatMagic)) {
+		return FormatCustom
+	}
+	if len(header) == tarMagicOffset+len(tarMagic) && string(header[tarMagicOffset:]) == tarMagic {
+		return FormatTar
+	}
+	return FormatPlain
+}
+
+// DatabaseName returns the database named in a PostgreSQL connection URL.
+func DatabaseName(connectionURL string) (string, error) {
+	u, err := url.Parse(connectionURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+
+	name := strings.TrimPrefix(u.Path, "/")
+	if name == "" {
+		return "", fmt.Errorf("database URL does not name a database")
+	}
+	return name, nil
+}
@@ -0,0 +1,383 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// ErrRestoreCheckFailed is returned when a backup could not be restored into a scratch database.
+var ErrRestoreCheckFailed = errors.New("restore verification failed")
+
+// Restore check outcomes.
+const (
+	RestoreCheckPassed = "passed"
+	RestoreCheckFailed = "failed"
+)
+
+// userTablesQuery counts the user tables of a database.
+const userTablesQuery = `SELECT count(*) FROM pg_catalog.pg_tables
+	WHERE schemaname NOT IN ('pg_catalog', 'information_schema')`
+
+// userRowsQuery counts the rows of every user table exactly, in a single statement.
+const userRowsQuery = `SELECT coalesce(sum((xpath('/row/n/text()',
+	query_to_xml(format('SELECT count(*) AS n FROM %I.%I', schemaname, tablename), false, true, '')))[1]::text::bigint), 0)
+	FROM pg_catalog.pg_tables
+	WHERE schemaname NOT IN ('pg_catalog', 'information_schema')`
+
+// scratchDropTimeout bounds dropping a managed scratch database after a check.
+const scratchDropTimeout = time.Minute
+
+// RestoreCheck is the outcome of restoring a backup into a scratch database,
+// stored next to the backup.
+type RestoreCheck struct {
+	Status          string    `json:"status"`
+	CheckedAt       time.Time `json:"checked_at"`
+	Database        string    `json:"database"`
+	Tables          int64     `json:"tables"`
+	Rows            int64     `json:"rows"`
+	SourceTables    int64     `json:"source_tables,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Error           string    `json:"error,omitempty"`
+
+	// Recovery splits a passed restore into stages, an estimate of the
+	// time to recover from this backup
+	Recovery *storage.RecoveryTime `json:"recovery,omitempty"`
+
+	// RestoreTime is how long the restore itself took, counting the restored
+	// rows excluded
+	RestoreTime time.Duration `json:"-"`
+}
+
+// RestoreScratch implements ScratchRestorer. Archives restored into targetURL
+// replace existing objects; plain SQL backups need an empty database.
+func (p *PostgresBackup) RestoreScratch(ctx context.Context, reader, schema io.Reader, targetURL string) (*RestoreCheck, error) {
+	if targetURL == "" {
+		name := scratchDatabaseName(time.Now())
+		scratchURL, err := p.createDatabase(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			// Drop even when the check was cancelled, so scratch databases do not pile up
+			dropCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), scratchDropTimeout)
+			defer cancel()
+			if err := p.runSQL(dropCtx, p.connectionURL, "DROP DATABASE IF EXISTS "+quoteIdentifier(name)); err != nil {
+				p.logger.Warn("Failed to drop scratch database", "database", name, "error", err)
+			}
+		}()
+		targetURL = scratchURL
+	}
+
+	database, err := DatabaseName(targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	scratch := *p
+	scratch.connectionURL = targetURL
+
+	br := bufio.NewReaderSize(reader, 4096)
+	opts := RestoreOptions{SingleTransaction: true, Clean: DetectArchiveFormat(br) != FormatPlain, Schema: schema}
+	start := time.Now()
+	if err := scratch.Restore(ctx, br, opts); err != nil {
+		return nil, err
+	}
+
+	check := &RestoreCheck{Database: database, RestoreTime: time.Since(start)}
+	tables, err := scratch.QueryValue(ctx, userTablesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count restored tables: %w", err)
+	}
+	rows, err := scratch.QueryValue(ctx, userRowsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count restored rows: %w", err)
+	}
+	check.Tables, check.Rows = int64(tables), int64(rows)
+	return check, nil
+}
+
+// createDatabase creates a database on the source server and returns its URL.
+func (p *PostgresBackup) createDatabase(ctx context.Context, name string) (string, error) {
+	u, err := url.Parse(p.connectionURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+	if err := p.runSQL(ctx, p.connectionURL, "CREATE DATABASE "+quoteIdentifier(name)); err != nil {
+		return "", fmt.Errorf("failed to create scratch database (the user needs CREATEDB): %w", err)
+	}
+
+	u.Path = "/" + name
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// runSQL runs a single statement outside a transaction.
+func (p *PostgresBackup) runSQL(ctx context.Context, connectionURL, statement string) error {
+	cmd := command(ctx, p.psqlBinary(),
+		"--no-psqlrc", "--quiet", "--no-password",
+		"--dbname="+connectionURL,
+		"--command="+statement)
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w, stderr: %s", err, stderrText(&stderr))
+	}
+	return nil
+}
+
+// scratchDatabaseName names a managed scratch database after the time it was created.
+func scratchDatabaseName(t time.Time) string {
+	return "backup_verify_" + t.UTC().Format("20060102t150405")
+}
+
+// quoteIdentifier quotes a PostgreSQL identifier.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sourceTables counts the source database's tables before the dump, or returns
+// 0 when the restored table count cannot be compared with it.
+func (o *Orchestrator) sourceTables(ctx context.Context) int64 {
+	querier, ok := o.backup.(Querier)
+	if !ok || o.selectsObjects() {
+		return 0
+	}
+	tables, err := querier.QueryValue(ctx, userTablesQuery)
+	if err != nil {
+		o.logger.Warn("Failed to count source tables for restore verification", "error", err)
+		return 0
+	}
+	return int64(tables)
+}
+
+// selectsObjects reports whether the dump leaves out some of the database's tables.
+func (o *Orchestrator) selectsObjects() bool {
+	if !o.config.DumpFilter.IsEmpty() {
+		return true
+	}
+	for _, opt := range strings.Fields(o.config.PGDumpOptions) {
+		for _, flag := range []string{"-t", "-T", "-n", "-N", "--table", "--exclude-table", "--schema", "--exclude-schema"} {
+			if opt == flag || strings.HasPrefix(opt, flag+"=") || (len(flag) == 2 && strings.HasPrefix(opt, flag)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyRestore restores the backup at key into a scratch database, records
+// the outcome in metrics and next to the backup, and returns the measured
+// recovery time, or an error when the backup did not restore or lost tables.
+func (o *Orchestrator) verifyRestore(ctx context.Context, key string, sourceTables int64) (*storage.RecoveryTime, error) {
+	restorer, ok := o.backup.(ScratchRestorer)
+	if !ok {
+		o.logger.Warn("Restore verification is not supported by the backup provider")
+		return nil, nil
+	}
+
+	o.logger.Info("Verifying backup restores into a scratch database", "key", key)
+	start := time.Now()
+	check, err := o.restoreScratch(ctx, restorer, key)
+	if err == nil && sourceTables > check.Tables {
+		err = fmt.Errorf("restored %d tables, the source database had %d", check.Tables, sourceTables)
+	}
+	if check == nil {
+		check = &RestoreCheck{}
+	}
+	check.CheckedAt = time.Now().UTC()
+	check.SourceTables = sourceTables
+	check.DurationSeconds = time.Since(start).Seconds()
+	check.Status = RestoreCheckPassed
+	if err != nil {
+		check.Status = RestoreCheckFailed
+		check.Error = err.Error()
+		check.Recovery = nil
+	}
+
+	metrics.RestoreChecks.WithLabelValues(check.Status).Inc()
+	metrics.RestoreCheckDuration.WithLabelValues(o.config.BackupFilePrefix).Set(check.DurationSeconds)
+	o.storeRestoreCheck(ctx, key, check)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s: %w", ErrRestoreCheckFailed, key, err)
+	}
+
+	metrics.RestoreCheckRows.WithLabelValues(o.config.BackupFilePrefix).Set(float64(check.Rows))
+	metrics.LastRestoreCheckTimestamp.WithLabelValues(o.config.BackupFilePrefix).Set(float64(check.CheckedAt.Unix()))
+	o.recordRecovery(check.Recovery)
+	o.logger.Info("Backup restored into scratch database",
+		"key", key, "database", check.Database, "tables", check.Tables, "rows", check.Rows,
+		"duration", time.Since(start), "rto", time.Duration(check.Recovery.TotalSeconds*float64(time.Second)))
+	return check.Recovery, nil
+}
+
+// restoreScratch streams the stored backup through its codec into a scratch
+// database and measures how long each stage of the recovery took.
+func (o *Orchestrator) restoreScratch(ctx context.Context, restorer ScratchRestorer, key string) (*RestoreCheck, error) {
+	start := time.Now()
+	schema, err := o.openBackupSchema(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if schema != nil {
+		defer func() {
+			_ = schema.Close()
+		}()
+	}
+	reader, err := o.openBackup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	opened := time.Since(start)
+
+	restoreStart := time.Now()
+	check, err := restorer.RestoreScratch(ctx, reader, schema, o.config.VerifyDatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if check.RestoreTime == 0 {
+		check.RestoreTime = time.Since(restoreStart)
+	}
+	check.Recovery = reader.recoveryTime(opened + check.RestoreTime)
+	return check, nil
+}
+
+// recordRecovery exports the stages of a measured recovery.
+func (o *Orchestrator) recordRecovery(recovery *storage.RecoveryTime) {
+	prefix := o.config.BackupFilePrefix
+	metrics.RecoveryStageDuration.WithLabelValues(prefix, "download").Set(recovery.DownloadSeconds)
+	metrics.RecoveryStageDuration.WithLabelValues(prefix, "decompress").Set(recovery.DecompressSeconds)
+	metrics.RecoveryStageDuration.WithLabelValues(prefix, "restore").Set(recovery.RestoreSeconds)
+	metrics.RecoveryTime.WithLabelValues(prefix).Set(recovery.TotalSeconds)
+}
+
+// openBackupSchema opens the stored schema the backup at key references, or
+// returns nil when it is a complete backup. Verify mode drills backups taken
+// elsewhere, so the reference is looked up whatever SCHEMA_DEDUP is set to.
+func (o *Orchestrator) openBackupSchema(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := o.storage.Stat(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	return OpenSchema(ctx, o.storage, obj)
+}
+
+// verificationStorage returns the storage backups are verified from: the local
+// cache serves its verified copy unless the remote one is to be tested.
+func (o *Orchestrator) verificationStorage() storage.Storage {
+	if o.config.RestoreForceRemote {
+		return storage.Uncached(o.storage)
+	}
+	return o.storage
+}
+
+// openBackup opens the stored backup at key for verification, decompressed
+// with the codec named by its extension.
+func (o *Orchestrator) openBackup(ctx context.Context, key string) (*decompressedReader, error) {
+	store := o.verificationStorage()
+	codec, ok := compression.ForFilename(key)
+	if !ok {
+		return nil, fmt.Errorf("no codec available for %s", key)
+	}
+	reader, verified, err := storage.OpenVerified(ctx, store, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	if !verified {
+		o.logger.Warn("Backup has no checksum, reading it unverified", "key", key)
+	}
+	download := &readTimer{reader: reader}
+	dr, err := codec.NewReader(download)
+	if err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("invalid %s backup: %w", codec.Name(), err)
+	}
+	return &decompressedReader{decompressor: dr, object: reader, download: download, read: &readTimer{reader: dr}}, nil
+}
+
+// decompressedReader closes the stored object along with its decompressor and
+// times the reads of both.
+type decompressedReader struct {
+	decompressor io.ReadCloser
+	object       io.Closer
+	download     *readTimer // Reads of the stored object
+	read         *readTimer // Reads of the decompressed backup, downloads included
+}
+
+// Read implements io.Reader.
+func (d *decompressedReader) Read(p []byte) (int, error) {
+	return d.read.Read(p)
+}
+
+// Close implements io.Closer.
+func (d *decompressedReader) Close() error {
+	return errors.Join(d.decompressor.Close(), d.object.Close())
+}
+
+// recoveryTime splits a recovery that took total, from opening the backup
+// until it was restored, into stages. Downloading, decompressing and restoring
+// overlap as the backup streams through them, so each stage is the time spent
+// waiting on it: downloading is the time spent reading the stored object,
+// decompressing the rest of the time spent reading the backup, and restoring
+// whatever remains.
+func (d *decompressedReader) recoveryTime(total time.Duration) *storage.RecoveryTime {
+	download := d.download.elapsed
+	decompress := max(d.read.elapsed-download, 0)
+	restore := max(total-download-decompress, 0)
+	return &storage.RecoveryTime{
+		DownloadSeconds:   download.Seconds(),
+		DecompressSeconds: decompress.Seconds(),
+		RestoreSeconds:    restore.Seconds(),
+		TotalSeconds:      (download + decompress + restore).Seconds(),
+	}
+}
+
+// readTimer sums the time spent in the reads of reader.
+type readTimer struct {
+	reader  io.Reader
+	elapsed time.Duration
+}
+
+// Read implements io.Reader.
+func (r *readTimer) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.reader.Read(p)
+	r.elapsed += time.Since(start)
+	return n, err
+}
+
+// storeRestoreCheck stores the outcome of a restore check next to the backup.
+// Failures are logged; the metrics still record the outcome.
+func (o *Orchestrator) storeRestoreCheck(ctx context.Context, key string, check *RestoreCheck) {
+	data, err := json.MarshalIndent(check, "", "  ")
+	if err != nil {
+		o.logger.Warn("Failed to encode restore check", "error", err)
+		return
+	}
+	if err := o.storage.Upload(ctx, key+utils.RestoreCheckSuffix, bytes.NewReader(data), map[string]string{
+		"backup-tool":   "railway-postgres-backup",
+		"restore-check": check.Status,
+	}); err != nil {
+		o.logger.Warn("Failed to store restore check", "error", err)
+	}
+}
@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// scratchBackup adds restore verification to a mock backup.
+type scratchBackup struct {
+	*mockBackup
+	check    *RestoreCheck
+	err      error
+	restored string
+}
+
+func (s *scratchBackup) RestoreScratch(ctx context.Context, reader, schema io.Reader, targetURL string) (*RestoreCheck, error) {
+	data, _ := io.ReadAll(reader)
+	s.restored = string(data)
+	return s.check, s.err
+}
+
+func TestOrchestrator_VerifyRestore(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	old := time.Now().AddDate(0, 0, -10)
+
+	tests := []struct {
+		name         string
+		check        *RestoreCheck
+		restoreErr   error
+		sourceTables float64
+		wantStatus   string
+	}{
+		{
+			name:         "restored backup passes",
+			check:        &RestoreCheck{Database: "scratch", Tables: 3, Rows: 42},
+			sourceTables: 3,
+			wantStatus:   RestoreCheckPassed,
+		},
+		{
+			name:       "failed restore",
+			restoreErr: errors.New("pg_restore failed"),
+			wantStatus: RestoreCheckFailed,
+		},
+		{
+			name:         "restored backup missing tables",
+			check:        &RestoreCheck{Database: "scratch", Tables: 2},
+			sourceTables: 3,
+			wantStatus:   RestoreCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				StorageProvider:  "s3",
+				BackupFilePrefix: "test",
+				Compression:      "none",
+				RetentionDays:    7,
+				VerifyRestore:    true,
+			}
+			store := &mockStorage{
+				objects: map[string][]byte{},
+				listResult: []storage.ObjectInfo{
+					{Key: "test-" + old.Format("2006-01-02T15-04-05-000Z") + ".tar", LastModified: old},
+				},
+			}
+			backup := &scratchBackup{
+				mockBackup: &mockBackup{
+					dumpData:    "backup data",
+					queryValues: map[string]float64{userTablesQuery: tt.sourceTables},
+				},
+				check: tt.check,
+				err:   tt.restoreErr,
+			}
+
+			result, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+			if tt.wantStatus == RestoreCheckPassed && err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if tt.wantStatus == RestoreCheckFailed && !errors.Is(err, ErrRestoreCheckFailed) {
+				t.Fatalf("Execute() error = %v, want ErrRestoreCheckFailed", err)
+			}
+			if backup.restored != "backup data" {
+				t.Errorf("restored %q, want the uploaded backup", backup.restored)
+			}
+
+			var check RestoreCheck
+			if err := json.Unmarshal(store.objects[result.Key+utils.RestoreCheckSuffix], &check); err != nil {
+				t.Fatalf("restore check not stored: %v", err)
+			}
+			if check.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", check.Status, tt.wantStatus)
+			}
+			if passed := tt.wantStatus == RestoreCheckPassed; (check.Recovery != nil) != passed || (result.Recovery != nil) != passed {
+				t.Errorf("recovery time = %+v, result %+v, want one only for a passed check", check.Recovery, result.Recovery)
+			}
+
+			// Retention only runs once the new backup has restored
+			if deleted := len(store.deleteCalls) > 0; deleted != (tt.wantStatus == RestoreCheckPassed) {
+				t.Errorf("retention deleted %v, want deletions only after a passed check", store.deleteCalls)
+			}
+		})
+	}
+}
+
+func TestDecompressedReader_RecoveryTime(t *testing.T) {
+	tests := []struct {
+		name           string
+		download, read time.Duration
+		total          time.Duration
+		wantDownload   float64
+		wantDecompress float64
+		wantRestore    float64
+		wantTotal      float64
+	}{
+		{
+			name:     "stages add up to the total",
+			download: 2 * time.Second, read: 5 * time.Second, total: 10 * time.Second,
+			wantDownload: 2, wantDecompress: 3, wantRestore: 5, wantTotal: 10,
+		},
+		{
+			name:     "uncompressed backup",
+			download: 4 * time.Second, read: 4 * time.Second, total: 6 * time.Second,
+			wantDownload: 4, wantDecompress: 0, wantRestore: 2, wantTotal: 6,
+		},
+		{
+			name:     "reads outlast the measured total",
+			download: 3 * time.Second, read: 4 * time.Second, total: 2 * time.Second,
+			wantDownload: 3, wantDecompress: 1, wantRestore: 0, wantTotal: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &decompressedReader{download: &readTimer{elapsed: tt.download}, read: &readTimer{elapsed: tt.read}}
+			got := d.recoveryTime(tt.total)
+			want := storage.RecoveryTime{
+				DownloadSeconds:   tt.wantDownload,
+				DecompressSeconds: tt.wantDecompress,
+				RestoreSeconds:    tt.wantRestore,
+				TotalSeconds:      tt.wantTotal,
+			}
+			if *got != want {
+				t.Errorf("recoveryTime() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_SelectsObjects(t *testing.T) {
+	tests := []struct {
+		options string
+		want    bool
+	}{
+		{options: "", want: false},
+		{options: "--no-owner --format=custom", want: false},
+		{options: "-t orders", want: true},
+		{options: "-torders", want: true},
+		{options: "--exclude-table=audit_log", want: true},
+		{options: "--schema app", want: true},
+		{options: "--exclude-table-data=audit_log", want: false},
+	}
+
+	for _, tt := range tests {
+		o := &Orchestrator{config: &config.Config{PGDumpOptions: tt.options}}
+		if got := o.selectsObjects(); got != tt.want {
+			t.Errorf("selectsObjects() with %q = %v, want %v", tt.options, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+// pg_dump has no row-level filter, so filtered tables are dumped without data
+// and their matching rows are spliced into the plain SQL output as COPY blocks,
+// ahead of the post-data objects.
+
+// postDataTypes are the object types pg_dump creates after loading the data,
+// as named in the comment heading each object of a plain dump.
+var postDataTypes = map[string]bool{
+	"CONSTRAINT":                   true,
+	"CHECK CONSTRAINT":             true,
+	"FK CONSTRAINT":                true,
+	"INDEX":                        true,
+	"INDEX ATTACH":                 true,
+	"STATISTICS":                   true,
+	"RULE":                         true,
+	"TRIGGER":                      true,
+	"EVENT TRIGGER":                true,
+	"POLICY":                       true,
+	"ROW SECURITY":                 true,
+	"MATERIALIZED VIEW DATA":       true,
+	"PUBLICATION":                  true,
+	"PUBLICATION TABLE":            true,
+	"PUBLICATION TABLES IN SCHEMA": true,
+	"SUBSCRIPTION":                 true,
+	"SUBSCRIPTION TABLE":           true,
+}
+
+// CheckTableFilters verifies that row filters can be applied with the given pg_dump options.
+func CheckTableFilters(pgDumpOptions string, filters []config.TableFilter) error {
+	if len(filters) == 0 {
+		return nil
+	}
+	if format := DumpFormat(pgDumpOptions); format != FormatPlain {
+		return fmt.Errorf("table filters require --format=plain, got %s", format)
+	}
+	if DumpCompressed(pgDumpOptions) {
+		return fmt.Errorf("table filters cannot be combined with pg_dump compression (-Z)")
+	}
+	return nil
+}
+
+// CheckFilterConditions plans the query selecting the rows of each table filter
+// with EXPLAIN in a read-only transaction, so a condition that is not valid SQL
+// for its table fails before the dump. The query is a prepared statement, which
+// holds a single command, so a condition that ends the query is rejected too.
+func (p *PostgresBackup) CheckFilterConditions(ctx context.Context) error {
+	if len(p.tableFilters) == 0 {
+		return nil
+	}
+
+	db, err := sql.Open("postgres", p.connectionURL)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to start read-only transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, f := range p.tableFilters {
+		if err := explainFilter(ctx, tx, f); err != nil {
+			return fmt.Errorf("where for %s: %w", f.Table, err)
+		}
+	}
+	return nil
+}
+
+// explainFilter plans the query selecting the rows of f without running it.
+func explainFilter(ctx context.Context, tx *sql.Tx, f config.TableFilter) error {
+	stmt, err := tx.PrepareContext(ctx, explainQuery(f))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// tableFilterArgs excludes the data of filtered tables from the main dump.
+func tableFilterArgs(filters []config.TableFilter) []string {
+	args := make([]string, 0, len(filters))
+	for _, f := range filters {
+		args = append(args, "--exclude-table-data="+qualifiedName(f))
+	}
+	return args
+}
+
+// spliceFilteredRows copies the plain SQL dump read from r to w, and has
+// writeRows write the filtered table rows ahead of the first post-data object,
+// so they load before indexes, constraints and triggers are created. A dump
+// without post-data objects gets them at its end. COPY data is never mistaken
+// for an object heading.
+func spliceFilteredRows(w io.Writer, r io.Reader, writeRows func(io.Writer) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var held, partial, inCopy bool // held: a "--" line waits for the heading it may open
+	for {
+		line, err := br.ReadSlice('\n')
+		start := !partial
+		partial = errors.Is(err, bufio.ErrBufferFull)
+
+		switch {
+		case start && inCopy:
+			inCopy = string(line) != "\\.\n"
+		case start && held && postDataHeading(line):
+			if err := writeRows(w); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "--\n"); err != nil {
+				return err
+			}
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+			_, err := io.Copy(w, br)
+			return err
+		case start:
+			if held {
+				if _, err := io.WriteString(w, "--\n"); err != nil {
+					return err
+				}
+			}
+			held = string(line) == "--\n"
+			inCopy = bytes.HasPrefix(line, []byte("COPY ")) && bytes.HasSuffix(line, []byte(" FROM stdin;\n"))
+		}
+		if !held || !start {
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case err == io.EOF:
+			if held {
+				if _, err := io.WriteString(w, "--\n"); err != nil {
+					return err
+				}
+			}
+			return writeRows(w)
+		case err != nil && !partial:
+			return err
+		}
+	}
+}
+
+// postDataHeading reports whether line is the comment naming a post-data
+// object, such as "-- Name: events_pkey; Type: CONSTRAINT; Schema: public; Owner: app".
+func postDataHeading(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte("-- Name: "))
+	if !ok {
+		return false
+	}
+	_, rest, ok = bytes.Cut(rest, []byte("; Type: "))
+	if !ok {
+		return false
+	}
+	objectType, _, _ := bytes.Cut(rest, []byte(";"))
+	return postDataTypes[string(objectType)]
+}
+
+// dumpFilteredRows writes the rows matching each table filter to w as COPY blocks.
+func (p *PostgresBackup) dumpFilteredRows(ctx context.Context, w io.Writer) error {
+	for _, f := range p.tableFilters {
+		p.logger.Info("Dumping filtered table rows", "table", f.Table, "where", f.Where)
+
+		if _, err := io.WriteString(w, copyHeader(f)); err != nil {
+			return err
+		}
+
+		cmd := command(ctx, p.psqlBinary(),
+			"--no-psqlrc", "--quiet", "--no-password",
+			"--dbname="+p.connectionURL,
+			"--command="+copyQuery(f))
+		cmd.Env = append(os.Environ(), "PGPASSWORD=")
+		cmd.Stdout = w
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		if err := p.startLimited(cmd); err != nil {
+			return fmt.Errorf("failed to copy rows of %s: %w", f.Table, err)
+		}
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("failed to copy rows of %s: %w, stderr: %s", f.Table, err, stderrText(&stderr))
+		}
+
+		if _, err := io.WriteString(w, "\\.\n\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyHeader is the statement that loads the rows that follow it on restore.
+func copyHeader(f config.TableFilter) string {
+	return fmt.Sprintf("\n--\n-- Filtered data for %s WHERE %s\n--\n\nCOPY %s FROM stdin;\n",
+		qualifiedName(f), strings.ReplaceAll(f.Where, "\n", " "), qualifiedName(f))
+}
+
+// explainQuery plans the query copyQuery runs.
+func explainQuery(f config.TableFilter) string {
+	return fmt.Sprintf("EXPLAIN SELECT * FROM %s WHERE %s", qualifiedName(f), f.Where)
+}
+
+// copyQuery selects the filtered rows in COPY text format.
+func copyQuery(f config.TableFilter) string {
+	return fmt.Sprintf("COPY (SELECT * FROM %s WHERE %s) TO STDOUT", qualifiedName(f), f.Where)
+}
+
+// qualifiedName returns the quoted schema-qualified table name. The dump resets
+// search_path, so unqualified names would not resolve on restore.
+func qualifiedName(f config.TableFilter) string {
+	return quoteIdent(f.Schema()) + "." + quoteIdent(f.Name())
+}
+
+// quoteIdent quotes a PostgreSQL identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+)
+
+func TestCheckTableFilters(t *testing.T) {
+	filters := []config.TableFilter{{Table: "events", Where: "id > 10"}}
+
+	tests := []struct {
+		name    string
+		options string
+		filters []config.TableFilter
+		wantErr bool
+	}{
+		{name: "no filters", options: "", filters: nil},
+		{name: "plain format", options: "--format=plain", filters: filters},
+		{name: "default tar format", options: "", filters: filters, wantErr: true},
+		{name: "custom format", options: "-Fc", filters: filters, wantErr: true},
+		{name: "compressed plain", options: "-Fp -Z 6", filters: filters, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTableFilters(tt.options, tt.filters)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckTableFilters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTableFilterStatements(t *testing.T) {
+	f := config.TableFilter{Table: `audit.My"Events`, Where: "created_at > now() - interval '30 days'"}
+
+	if got, want := qualifiedName(f), `"audit"."My""Events"`; got != want {
+		t.Errorf("qualifiedName() = %v, want %v", got, want)
+	}
+
+	args := tableFilterArgs([]config.TableFilter{f})
+	if len(args) != 1 || args[0] != `--exclude-table-data="audit"."My""Events"` {
+		t.Errorf("tableFilterArgs() = %v", args)
+	}
+
+	if got := copyQuery(f); got != `COPY (SELECT * FROM "audit"."My""Events" WHERE created_at > now() - interval '30 days') TO STDOUT` {
+		t.Errorf("copyQuery() = %v", got)
+	}
+
+	if got := copyHeader(f); !strings.HasSuffix(got, "COPY \"audit\".\"My\"\"Events\" FROM stdin;\n") {
+		t.Errorf("copyHeader() = %q, want a COPY FROM stdin statement", got)
+	}
+}
+
+func TestSpliceFilteredRows(t *testing.T) {
+	const rows = "\n--\n-- Filtered data for \"public\".\"events\" WHERE true\n--\n\nCOPY \"public\".\"events\" FROM stdin;\n1\n\\.\n\n"
+	writeRows := func(w io.Writer) error {
+		_, err := io.WriteString(w, rows)
+		return err
+	}
+
+	// A row that looks like an object heading stays in its COPY block
+	preData := "--\n-- Name: events; Type: TABLE; Schema: public; Owner: app\n--\n\nCREATE TABLE public.events (id integer, note text);\n\n"
+	data := "--\n-- Data for Name: notes; Type: TABLE DATA; Schema: public; Owner: app\n--\n\nCOPY public.notes (note) FROM stdin;\n--\n-- Name: x; Type: INDEX; Schema: public; Owner: app\n" +
+		strings.Repeat("x", 100*1024) + "\n\\.\n\n\n"
+	postData := "--\n-- Name: events_pkey; Type: CONSTRAINT; Schema: public; Owner: app\n--\n\nALTER TABLE ONLY public.events ADD CONSTRAINT events_pkey PRIMARY KEY (id);\n\n"
+	trailer := "--\n-- PostgreSQL database dump complete\n--\n\n"
+
+	tests := []struct {
+		name string
+		dump string
+		want string
+	}{
+		{name: "before post-data", dump: preData + data + postData + trailer, want: preData + data + rows + postData + trailer},
+		{name: "without post-data", dump: preData + data + trailer, want: preData + data + trailer + rows},
+		{name: "without trailing newline", dump: preData + "SELECT 1;", want: preData + "SELECT 1;" + rows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := spliceFilteredRows(&out, strings.NewReader(tt.dump), writeRows); err != nil {
+				t.Fatalf("spliceFilteredRows() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("spliceFilteredRows() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	failed := errors.New("psql failed")
+	err := spliceFilteredRows(io.Discard, strings.NewReader(preData+postData), func(io.Writer) error { return failed })
+	if !errors.Is(err, failed) {
+		t.Errorf("spliceFilteredRows() error = %v, want the rows error", err)
+	}
+}
+
+// TestCheckFilterConditions plans filter conditions against the database at
+// TEST_DATABASE_URL.
+func TestCheckFilterConditions(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	tests := []struct {
+		name    string
+		where   string
+		wantErr bool
+	}{
+		{name: "condition", where: "relname <> ';'"},
+		{name: "unknown column", where: "missing > 0", wantErr: true},
+		{name: "second statement", where: "true; DROP TABLE pg_catalog.pg_class", wantErr: true},
+		{name: "query closed early", where: "true) TO STDOUT; SELECT (1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PostgresBackup{connectionURL: url, tableFilters: []config.TableFilter{{Table: "pg_catalog.pg_class", Where: tt.where}}}
+			if err := p.CheckFilterConditions(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("CheckFilterConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// runbookTemplate renders the restore instructions stored next to each backup.
+// It never contains credentials: connection strings and keys are left to the
+// environment of whoever runs the commands.
+var runbookTemplate = template.Must(template.New("runbook").Parse(`# Restoring {{.File}}
+
+Generated by railway-postgres-backup when this backup was taken. Every value
+below comes from the configuration of that run.
+
+| | |
+|---|---|
+| Database | {{.Database}} |
+| Server version | {{.Version}} |
+| Taken at | {{.TakenAt}} |
+| Size | {{.Size}} |
+| SHA-256 | {{.SHA256}} |
+| Format | pg_dump {{.Format}}, {{.Compression}} compression |
+| Storage key | {{.Key}} |
+
+## Option 1: restore with this service
+
+From a shell with the service's variables (for example ` + "`railway run`" + `), restore into
+the database named by DATABASE_URL. The command checks the database name,
+the backup and its extensions before touching anything:
+
+` + "```bash" + `
+backup rollback --key '{{.Key}}' --confirm '{{.Database}}'
+` + "```" + `
+
+Add ` + "`--target-url \"$TARGET_URL\"`" + ` to restore into another database, and
+` + "`--dry-run`" + ` to run the checks only.
+
+## Option 2: restore by hand
+
+### 1. Download the backup and its checksum
+{{range .Locations}}
+From {{.Provider}}:
+
+` + "```bash" + `
+{{.Download}}
+` + "```" + `
+{{end}}
+### 2. Verify it
+
+` + "```bash" + `
+sha256sum -c '{{.File}}.sha256'
+` + "```" + `
+
+### 3. Restore it
+
+Use PostgreSQL {{.Major}} client tools or newer. {{.RestoreNote}}
+
+` + "```bash" + `
+{{.Restore}}
+` + "```" + `
+`))
+
+// runbookData is the content of a rendered runbook.
+type runbookData struct {
+	Key         string
+	File        string
+	Database    string
+	Version     string
+	Major       string
+	TakenAt     string
+	Size        string
+	SHA256      string
+	Format      string
+	Compression string
+	Locations   []runbookLocation
+	Restore     string
+	RestoreNote string
+}
+
+// runbookLocation tells how to download the backup from one destination.
+type runbookLocation struct {
+	Provider string
+	Download string
+}
+
+// renderRunbook renders the restore instructions for the backup of a run.
+func (o *Orchestrator) renderRunbook(result *Result, info *DatabaseInfo, major, format, compression string) ([]byte, error) {
+	key := result.Key
+	file := path.Base(key)
+	data := runbookData{
+		Key:         key,
+		File:        file,
+		Database:    info.Name,
+		Version:     info.Version,
+		Major:       major,
+		TakenAt:     result.Timestamp.UTC().Format(time.RFC3339),
+		Size:        utils.FormatBytes(result.Size),
+		SHA256:      result.SHA256,
+		Format:      format,
+		Compression: compression,
+	}
+	for _, provider := range o.config.StorageProviders() {
+		data.Locations = append(data.Locations, runbookLocation{Provider: provider, Download: o.downloadCommand(provider, key)})
+	}
+	data.Restore, data.RestoreNote = restoreCommand(file, data.Format, compression)
+
+	var buf bytes.Buffer
+	if err := runbookTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downloadCommand returns the shell commands that download the backup at key
+// and its checksum sidecar from a destination.
+func (o *Orchestrator) downloadCommand(provider, key string) string {
+	object := path.Join(o.config.BackupFilePrefix, key)
+	file := path.Base(key)
+	var cmds []string
+	for _, suffix := range []string{"", utils.ChecksumSuffix} {
+		switch provider {
+		case "s3", "r2", "spaces", "wasabi":
+			cmd := fmt.Sprintf("aws s3 cp 's3://%s/%s%s' .", o.config.S3Bucket, object, suffix)
+			if endpoint := o.config.GetS3Endpoint(provider); endpoint != "" {
+				cmd += " --endpoint-url " + endpoint
+			}
+			cmds = append(cmds, cmd)
+		case "gcs":
+			cmds = append(cmds, fmt.Sprintf("gcloud storage cp 'gs://%s/%s%s' .", o.config.GCSBucket, object, suffix))
+		case "filesystem":
+			cmds = append(cmds, fmt.Sprintf("cp '%s%s' .", path.Join(o.config.FilesystemPath, object), suffix))
+		case "rclone":
+			remote := strings.TrimSuffix(o.config.RcloneRemote, "/")
+			if !strings.HasSuffix(remote, ":") {
+				remote += "/"
+			}
+			cmds = append(cmds, fmt.Sprintf("rclone copyto '%s%s%s' './%s%s'", remote, object, suffix, file, suffix))
+		}
+	}
+	return strings.Join(cmds, "\n")
+}
+
+// restoreCommand returns the shell command restoring a downloaded backup into
+// DATABASE_URL, and a note on what it does.
+func restoreCommand(file, format, compression string) (string, string) {
+	var decompress string
+	switch compression {
+	case "gzip", "pgzip":
+		decompress = fmt.Sprintf("gunzip -c '%s'", file)
+	case "zstd":
+		decompress = fmt.Sprintf("zstd -dc '%s'", file)
+	default:
+		decompress = fmt.Sprintf("cat '%s'", file)
+	}
+
+	if format == FormatPlain {
+		return decompress + ` | psql --set ON_ERROR_STOP=1 --single-transaction "$DATABASE_URL"`,
+			"Plain SQL dumps are replayed with psql and need an empty database."
+	}
+	return decompress + ` | pg_restore --clean --if-exists --no-owner --single-transaction --dbname "$DATABASE_URL"`,
+		"Existing objects in the target database are dropped and replaced."
+}
+
+// storeRunbook stores the restore instructions next to the backup of a run.
+// Failures are logged and never fail the backup.
+func (o *Orchestrator) storeRunbook(ctx context.Context, result *Result, info *DatabaseInfo, major, format, compression string) {
+	runbook, err := o.renderRunbook(result, info, major, format, compression)
+	if err != nil {
+		o.logger.Warn("Failed to render restore runbook", "error", err)
+		return
+	}
+	key := result.Key
+	if err := o.storage.Upload(ctx, key+utils.RunbookSuffix, bytes.NewReader(runbook), map[string]string{
+		"backup-tool": "railway-postgres-backup",
+	}); err != nil {
+		o.logger.Warn("Failed to store restore runbook", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+func TestOrchestrator_StoresRunbook(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	backup := &mockBackup{
+		dumpData: "backup data",
+		info:     &DatabaseInfo{Name: "app", Version: "PostgreSQL 16.2", Size: 4096},
+	}
+	cfg := &config.Config{
+		StorageProvider:    "s3",
+		S3Bucket:           "backups",
+		AWSAccessKeyID:     "AKIDEXAMPLE",
+		AWSSecretAccessKey: "super-secret-key",
+		BackupFilePrefix:   "test",
+		Compression:        "gzip",
+	}
+	store := &mockStorage{objects: map[string][]byte{}}
+
+	result, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	runbook, ok := store.objects[result.Key+utils.RunbookSuffix]
+	if !ok {
+		t.Fatalf("runbook for %s not stored", result.Key)
+	}
+
+	for _, want := range []string{
+		"backup rollback --key '" + result.Key + "' --confirm 'app'",
+		"aws s3 cp 's3://backups/test/" + result.Key + "' .",
+		"sha256sum -c",
+		result.SHA256,
+		"gunzip -c",
+		"pg_restore --clean",
+	} {
+		if !strings.Contains(string(runbook), want) {
+			t.Errorf("runbook does not contain %q:\n%s", want, runbook)
+		}
+	}
+	for _, secret := range []string{cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey} {
+		if strings.Contains(string(runbook), secret) {
+			t.Errorf("runbook contains credential %q", secret)
+		}
+	}
+}
+
+// exportingBackup is a backup written by the plain SQL fallback exporter.
+type exportingBackup struct {
+	mockBackup
+}
+
+func (b *exportingBackup) OutputFormat() string {
+	return FormatPlain
+}
+
+func TestOrchestrator_FallbackExportIsPlainSQL(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	backup := &exportingBackup{mockBackup{
+		dumpData: "-- PostgreSQL database export\n",
+		info:     &DatabaseInfo{Name: "app", Version: "PostgreSQL 16.2", Size: 4096},
+	}}
+	cfg := &config.Config{
+		StorageProvider:  "filesystem",
+		FilesystemPath:   t.TempDir(),
+		BackupFilePrefix: "test",
+		Compression:      "gzip",
+	}
+	store := &mockStorage{objects: map[string][]byte{}}
+
+	result, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !strings.HasSuffix(result.Key, ".sql.gz") {
+		t.Errorf("Key = %s, want a .sql.gz extension", result.Key)
+	}
+	if got := store.metadata[MetadataKeyFormat]; got != FormatPlain {
+		t.Errorf("metadata %s = %q, want %q", MetadataKeyFormat, got, FormatPlain)
+	}
+	runbook := string(store.objects[result.Key+utils.RunbookSuffix])
+	if !strings.Contains(runbook, "| psql") || strings.Contains(runbook, "pg_restore") {
+		t.Errorf("runbook does not restore the export with psql:\n%s", runbook)
+	}
+}
+
+func TestRunbook_RestoreCommand(t *testing.T) {
+	tests := []struct {
+		format, compression, want string
+	}{
+		{FormatTar, "zstd", "zstd -dc 'b.tar.zst' | pg_restore"},
+		{FormatCustom, "none", "cat 'b.tar.zst' | pg_restore"},
+		{FormatPlain, "gzip", "gunzip -c 'b.tar.zst' | psql"},
+	}
+	for _, tt := range tests {
+		got, _ := restoreCommand("b.tar.zst", tt.format, tt.compression)
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("restoreCommand(%s, %s) = %s, want prefix %s", tt.format, tt.compression, got, tt.want)
+		}
+	}
+}
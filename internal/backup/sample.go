@@ -0,0 +1,254 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// ErrSampleCheckFailed is returned when a sampled verification finds a damaged backup.
+var ErrSampleCheckFailed = errors.New("sampled verification failed")
+
+// Sampled verification outcomes. A partial check found no damage before its
+// time budget ran out.
+const (
+	SampleCheckPassed  = "passed"
+	SampleCheckPartial = "partial"
+	SampleCheckFailed  = "failed"
+)
+
+// copyTerminator ends the COPY data of each table member of a pg_dump tar
+// archive. pg_dump follows it with blank lines ("\\.\n\n\n").
+const copyTerminator = "\\."
+
+// tarTrailer is the size of the zero blocks that end a tar archive.
+const tarTrailer = 2 * 512
+
+// tailSize is the number of bytes read from the end of a stored backup whose
+// sampled check ran out of time before reaching it.
+const tailSize = 64 * 1024
+
+// SampleOptions bounds a sampled verification.
+type SampleOptions struct {
+	Budget    time.Duration // Time allowed for the check (0 for no limit)
+	HeadBytes int64         // Every tar member starting within this many bytes is checked
+	Percent   int           // Share of later members checked, chosen at random
+	Rand      *rand.Rand    // Member selection; nil uses the global source
+}
+
+// SampleCheck is the outcome of a sampled verification.
+type SampleCheck struct {
+	Status         string
+	Format         string
+	Bytes          int64 // Decompressed bytes read
+	Members        int   // Tar members seen
+	MembersChecked int   // Tar members read and checked
+	Duration       time.Duration
+}
+
+// SampleArchive checks the structure of a decompressed backup stream within a
+// time budget. Tar archives are walked member by member, which validates every
+// header checksum; the members near the start and a random share of the rest
+// are read in full and checked for pg_dump's structure. Reaching the end of
+// the stream also validates the compression trailer.
+func SampleArchive(ctx context.Context, reader io.Reader, opts SampleOptions) (*SampleCheck, error) {
+	start := time.Now()
+	if opts.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Budget)
+		defer cancel()
+	}
+
+	counter := &countingReader{reader: &deadlineReader{ctx: ctx, reader: reader}}
+	br := bufio.NewReaderSize(counter, 4096)
+	check := &SampleCheck{Format: DetectArchiveFormat(br)}
+
+	err := check.walk(br, counter, opts)
+	if err == nil {
+		// Drain padding and reach the compression trailer
+		_, err = io.Copy(io.Discard, br)
+	}
+
+	check.Bytes = counter.count
+	check.Duration = time.Since(start)
+	switch {
+	case err == nil:
+		check.Status = SampleCheckPassed
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		check.Status = SampleCheckPartial
+	default:
+		check.Status = SampleCheckFailed
+		return check, err
+	}
+	return check, nil
+}
+
+// walk checks the members of a tar archive. Other formats carry no member
+// structure, so only their stream is read.
+func (s *SampleCheck) walk(br *bufio.Reader, counter *countingReader, opts SampleOptions) error {
+	if s.Format != FormatTar {
+		return nil
+	}
+
+	intN := rand.IntN
+	if opts.Rand != nil {
+		intN = opts.Rand.IntN
+	}
+
+	tr := tar.NewReader(br)
+	for {
+		// Bytes read ahead into the buffer have not reached the archive yet
+		offset := counter.count - int64(br.Buffered())
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			if s.Members == 0 {
+				return fmt.Errorf("backup archive is empty")
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar member after %d members: %w", s.Members, err)
+		}
+		s.Members++
+
+		if offset >= opts.HeadBytes && intN(100) >= opts.Percent {
+			continue
+		}
+		if err := checkMember(hdr, tr); err != nil {
+			return err
+		}
+		s.MembersChecked++
+	}
+}
+
+// checkMember reads a tar member in full and checks the pg_dump structure of
+// its content: the table of contents starts with the archive header and table
+// data ends with the COPY terminator. Large objects (blob_<oid>.dat) are raw
+// bytes without a terminator.
+func checkMember(hdr *tar.Header, r io.Reader) error {
+	content := &edgeWriter{}
+	if _, err := io.Copy(content, r); err != nil {
+		return fmt.Errorf("failed to read tar member %s: %w", hdr.Name, err)
+	}
+
+	name := path.Base(hdr.Name)
+	tableData := strings.HasSuffix(name, ".dat") && name != "toc.dat" && !strings.HasPrefix(name, "blob_")
+	switch {
+	case name == "toc.dat" && !bytes.HasPrefix(content.head, []byte(customFormatMagic)):
+		return fmt.Errorf("tar member %s does not start with the pg_dump header", hdr.Name)
+	case tableData && hdr.Size > 0 && !bytes.HasSuffix(bytes.TrimRight(content.tail, "\n"), []byte(copyTerminator)):
+		return fmt.Errorf("tar member %s is truncated: table data does not end with the COPY terminator", hdr.Name)
+	}
+	return nil
+}
+
+// edgeWriter keeps the first and last bytes written to it.
+type edgeWriter struct {
+	head []byte
+	tail []byte
+}
+
+// edgeSize is the number of bytes kept at each end.
+const edgeSize = 16
+
+func (w *edgeWriter) Write(p []byte) (int, error) {
+	if n := edgeSize - len(w.head); n > 0 {
+		w.head = append(w.head, p[:min(n, len(p))]...)
+	}
+	w.tail = append(w.tail, p[max(len(p)-edgeSize, 0):]...)
+	if len(w.tail) > edgeSize {
+		w.tail = w.tail[len(w.tail)-edgeSize:]
+	}
+	return len(p), nil
+}
+
+// deadlineReader stops reading once the context is done.
+type deadlineReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read implements io.Reader.
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return d.reader.Read(p)
+}
+
+// checkTail reads the end of the stored backup at key with a ranged read. The
+// object must hold the size bytes uploaded, and an uncompressed tar archive
+// must end with its zero blocks.
+func checkTail(ctx context.Context, store storage.Storage, key string, size int64, format string) error {
+	n := min(size, tailSize)
+	reader, err := store.OpenRange(ctx, key, size-n, n)
+	if err != nil {
+		return fmt.Errorf("failed to read the end of the backup: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	tail, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read the end of the backup: %w", err)
+	}
+	if int64(len(tail)) != n {
+		return fmt.Errorf("backup is truncated: read %d of its last %d bytes", len(tail), n)
+	}
+
+	codec, ok := compression.ForFilename(key)
+	if ok && codec.Name() == "none" && format == FormatTar && !bytes.Equal(tail[max(len(tail)-tarTrailer, 0):], make([]byte, tarTrailer)) {
+		return fmt.Errorf("backup is truncated: the tar archive does not end with its end-of-archive blocks")
+	}
+	return nil
+}
+
+// sampleVerify runs a sampled verification of the stored backup at key, which
+// was uploaded with size bytes, and returns an error when it finds damage.
+// Running out of time is not a failure, but the end of the backup the sample
+// did not reach is then read on its own.
+func (o *Orchestrator) sampleVerify(ctx context.Context, key string, size int64) error {
+	o.logger.Info("Sampling backup structure", "key", key, "budget", o.config.GetVerifySampleBudget())
+
+	reader, err := o.openBackup(ctx, key)
+	if err != nil {
+		metrics.SampleChecks.WithLabelValues(SampleCheckFailed).Inc()
+		return fmt.Errorf("%w for %s: %w", ErrSampleCheckFailed, key, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	check, err := SampleArchive(ctx, reader, SampleOptions{
+		Budget:    o.config.GetVerifySampleBudget(),
+		HeadBytes: int64(o.config.VerifySampleHeadMB) * 1024 * 1024,
+		Percent:   o.config.VerifySamplePercent,
+	})
+	if err == nil && check.Status == SampleCheckPartial {
+		if err = checkTail(ctx, o.verificationStorage(), key, size, check.Format); err != nil {
+			check.Status = SampleCheckFailed
+		}
+	}
+	metrics.SampleChecks.WithLabelValues(check.Status).Inc()
+	metrics.SampleCheckDuration.WithLabelValues(o.config.BackupFilePrefix).Set(check.Duration.Seconds())
+	if err != nil {
+		return fmt.Errorf("%w for %s: %w", ErrSampleCheckFailed, key, err)
+	}
+
+	o.logger.Info("Sampled backup structure",
+		"key", key, "status", check.Status, "format", check.Format, "bytes", check.Bytes,
+		"members", check.Members, "members_checked", check.MembersChecked, "duration", check.Duration)
+	return nil
+}
@@ -0,0 +1,250 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// dumpArchive builds a pg_dump-like tar archive from member names and contents.
+func dumpArchive(t *testing.T, members ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < len(members); i += 2 {
+		if err := tw.WriteHeader(&tar.Header{Name: members[i], Mode: 0o600, Size: int64(len(members[i+1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(members[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pgDumpTarArchive builds an archive laid out as pg_dump -Ft writes it: COPY
+// data followed by blank lines, an empty table holding only its terminator,
+// and a large object stored as raw bytes with its blobs.toc.
+func pgDumpTarArchive(t *testing.T) []byte {
+	t.Helper()
+	return dumpArchive(t,
+		"toc.dat", "PGDMP\x01\x0f\x00\x04\x08\x01\x01\x01",
+		"3371.dat", "1\talice\n2\tbob\n\\.\n\n\n",
+		"3372.dat", "\\.\n\n\n",
+		"blob_16385.dat", "\x89PNG\r\n\x1a\n raw large object bytes",
+		"blobs.toc", "16385 blob_16385.dat\n",
+		"restore.sql", "--\n-- NOTE:\n--\n-- File paths need to be edited.\n",
+	)
+}
+
+// slowReader delays each read to exhaust a time budget.
+type slowReader struct {
+	reader io.Reader
+	delay  time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.reader.Read(p[:min(len(p), 512)])
+}
+
+func TestSampleArchive(t *testing.T) {
+	ctx := context.Background()
+	valid := dumpArchive(t,
+		"toc.dat", "PGDMP\x01\x0f\x00",
+		"3001.dat", "1\talice\n2\tbob\n\\.\n",
+		"3002.dat", "",
+		"restore.sql", "-- restore script\n",
+	)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		opts        SampleOptions
+		wantStatus  string
+		wantChecked int
+	}{
+		{
+			name:        "every member in the head is checked",
+			data:        valid,
+			opts:        SampleOptions{HeadBytes: 1 << 20},
+			wantStatus:  SampleCheckPassed,
+			wantChecked: 4,
+		},
+		{
+			name:        "members past the head are sampled",
+			data:        valid,
+			opts:        SampleOptions{Percent: 0},
+			wantStatus:  SampleCheckPassed,
+			wantChecked: 0,
+		},
+		{
+			name:        "pg_dump -Ft layout",
+			data:        pgDumpTarArchive(t),
+			opts:        SampleOptions{Percent: 100},
+			wantStatus:  SampleCheckPassed,
+			wantChecked: 6,
+		},
+		{
+			name:       "table data cut before the blank lines",
+			data:       dumpArchive(t, "toc.dat", "PGDMP", "3371.dat", "1\talice\n\n\n"),
+			opts:       SampleOptions{Percent: 100},
+			wantStatus: SampleCheckFailed,
+		},
+		{
+			name:       "truncated table data",
+			data:       dumpArchive(t, "toc.dat", "PGDMP", "3001.dat", "1\talice\n2\tb"),
+			opts:       SampleOptions{HeadBytes: 1 << 20},
+			wantStatus: SampleCheckFailed,
+		},
+		{
+			name:       "table of contents without header",
+			data:       dumpArchive(t, "toc.dat", "garbage"),
+			opts:       SampleOptions{Percent: 100},
+			wantStatus: SampleCheckFailed,
+		},
+		{
+			name:       "truncated archive",
+			data:       valid[:1024+100],
+			opts:       SampleOptions{},
+			wantStatus: SampleCheckFailed,
+		},
+		{
+			name:       "custom archive stream",
+			data:       []byte("PGDMP\x01\x0f\x00 archive data"),
+			wantStatus: SampleCheckPassed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Rand = rand.New(rand.NewPCG(1, 2))
+			check, err := SampleArchive(ctx, bytes.NewReader(tt.data), tt.opts)
+			if check.Status != tt.wantStatus {
+				t.Fatalf("SampleArchive() status = %s (error %v), want %s", check.Status, err, tt.wantStatus)
+			}
+			if (err != nil) != (tt.wantStatus == SampleCheckFailed) {
+				t.Errorf("SampleArchive() error = %v", err)
+			}
+			if tt.wantStatus == SampleCheckPassed && check.MembersChecked != tt.wantChecked {
+				t.Errorf("MembersChecked = %d, want %d", check.MembersChecked, tt.wantChecked)
+			}
+		})
+	}
+
+	t.Run("budget exhausted before the end", func(t *testing.T) {
+		reader := &slowReader{reader: bytes.NewReader(valid), delay: 20 * time.Millisecond}
+		check, err := SampleArchive(ctx, reader, SampleOptions{Budget: 30 * time.Millisecond})
+		if err != nil || check.Status != SampleCheckPartial {
+			t.Errorf("SampleArchive() = %s, %v; want partial without error", check.Status, err)
+		}
+	})
+}
+
+func TestOrchestrator_SampleVerify(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:     "s3",
+		BackupFilePrefix:    "test",
+		Compression:         "none",
+		VerifySample:        true,
+		VerifySampleHeadMB:  1,
+		VerifySamplePercent: 10,
+	}
+
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "valid archive", data: dumpArchive(t, "toc.dat", "PGDMP", "3001.dat", "1\n\\.\n")},
+		{name: "damaged archive", data: dumpArchive(t, "toc.dat", "PGDMP", "3001.dat", "1\n"), wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStorage{objects: map[string][]byte{}}
+			backup := &mockBackup{dumpData: string(tt.data)}
+			_, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+			if tt.wantErr != errors.Is(err, ErrSampleCheckFailed) {
+				t.Errorf("Execute() error = %v, want sample failure %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "3001.dat") {
+				t.Errorf("Execute() error = %v, want the damaged member named", err)
+			}
+		})
+	}
+}
+
+// TestSampleArchive_PGDump samples an archive written by a real pg_dump -Ft of
+// the database at TEST_DATABASE_URL.
+func TestSampleArchive_PGDump(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	if _, err := exec.LookPath("pg_dump"); err != nil {
+		t.Skip("pg_dump is not installed")
+	}
+
+	ctx := context.Background()
+	archive, err := exec.CommandContext(ctx, "pg_dump", "--format=tar", "--blobs", "--dbname", url).Output()
+	if err != nil {
+		t.Fatalf("pg_dump error = %v", err)
+	}
+	check, err := SampleArchive(ctx, bytes.NewReader(archive), SampleOptions{Percent: 100})
+	if err != nil || check.Status != SampleCheckPassed {
+		t.Fatalf("SampleArchive() = %s, %v; want a genuine archive to pass", check.Status, err)
+	}
+	if check.MembersChecked != check.Members {
+		t.Errorf("MembersChecked = %d, want all %d members", check.MembersChecked, check.Members)
+	}
+}
+
+func TestCheckTail(t *testing.T) {
+	ctx := context.Background()
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := pgDumpTarArchive(t)
+
+	tests := []struct {
+		name    string
+		key     string
+		stored  []byte
+		size    int64
+		format  string
+		wantErr bool
+	}{
+		{name: "complete tar archive", key: "db.tar", stored: archive, size: int64(len(archive)), format: FormatTar},
+		{name: "object shorter than uploaded", key: "db.tar", stored: archive[:len(archive)-512], size: int64(len(archive)), format: FormatTar, wantErr: true},
+		{name: "tar archive without its end blocks", key: "db.tar", stored: archive[:len(archive)-1024], size: int64(len(archive) - 1024), format: FormatTar, wantErr: true},
+		{name: "compressed stream", key: "db.tar.gz", stored: []byte("compressed"), size: 10, format: FormatTar},
+		{name: "compressed stream cut short", key: "db.tar.gz", stored: []byte("compre"), size: 10, format: FormatTar, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := fs.Upload(ctx, tt.key, bytes.NewReader(tt.stored), nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := checkTail(ctx, fs, tt.key, tt.size, tt.format); (err != nil) != tt.wantErr {
+				t.Errorf("checkTail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
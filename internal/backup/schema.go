@@ -0,0 +1,221 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// MetadataKeySchema names the stored schema a data-only backup taken with
+// SCHEMA_DEDUP must be restored onto.
+const MetadataKeySchema = "backup-schema"
+
+// DumpSchema implements SchemaDumper. The schema is dumped as plain SQL that
+// drops the objects it recreates, with the same object selection as the
+// backup.
+func (p *PostgresBackup) DumpSchema(ctx context.Context) ([]byte, error) {
+	args := []string{"--schema-only", "--clean", "--if-exists", "--no-password"}
+	args = append(args, p.pgDumpOptions...)
+
+	if p.dumpFilter != nil {
+		filterFile, err := p.writeFilterFile(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = filterFile.Cleanup()
+		}()
+		args = append(args, "--filter="+filterFile.Name())
+	}
+
+	// The format options of PG_DUMP_OPTIONS apply to the data backup only
+	args = append(args, "--format=plain", "--compress=0", p.connectionURL)
+
+	cmd := command(ctx, p.pgDumpBin, args...)
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("schema dump failed: %w, stderr: %s", err, stderrText(&stderr))
+	}
+	return output, nil
+}
+
+// SchemaHash returns the hex SHA-256 of a plain SQL schema dump, ignoring
+// comments and the random \restrict keys of recent pg_dump releases, so the
+// same DDL always hashes the same.
+func SchemaHash(schema []byte) string {
+	h := sha256.New()
+	scanner := bufio.NewScanner(bytes.NewReader(schema))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(schema)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte("--")) || bytes.HasPrefix(line, []byte(`\restrict `)) || bytes.HasPrefix(line, []byte(`\unrestrict `)) {
+			continue
+		}
+		h.Write(line)
+		h.Write([]byte("\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// storeSchema dumps the database's schema and stores it, compressed with
+// compressor, in every destination that does not hold an identical schema
+// yet. It returns the key the data backup references.
+func (o *Orchestrator) storeSchema(ctx context.Context, dumper SchemaDumper, compressor compression.Compressor) (string, error) {
+	schema, err := dumper.DumpSchema(ctx)
+	if err != nil {
+		return "", err
+	}
+	key := storage.SchemaKey(o.config.BackupFilePrefix, SchemaHash(schema), compressor.Extension())
+
+	var compressed bytes.Buffer
+	cw, err := compressor.NewWriter(&compressed)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s writer: %w", compressor.Name(), err)
+	}
+	if _, err := cw.Write(schema); err != nil {
+		return "", fmt.Errorf("failed to compress schema: %w", err)
+	}
+	if err := cw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress schema: %w", err)
+	}
+
+	// Each destination must hold the schema its own backups reference
+	destinations := []storage.Destination{{Name: o.config.StorageProvider, Storage: o.storage}}
+	if multi, ok := storage.Uncached(o.storage).(*storage.MultiStorage); ok {
+		destinations = multi.Destinations()
+	}
+	for _, dest := range destinations {
+		_, err := dest.Storage.Stat(ctx, key)
+		if err == nil {
+			o.logger.Info("Schema unchanged, referencing the stored copy", "destination", dest.Name, "key", key)
+			metrics.SchemaDedup.WithLabelValues("reused").Inc()
+			continue
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return "", fmt.Errorf("failed to look up stored schema in %s: %w", dest.Name, err)
+		}
+		if err := dest.Storage.Upload(ctx, key, bytes.NewReader(compressed.Bytes()), map[string]string{
+			"backup-timestamp": time.Now().UTC().Format(time.RFC3339),
+			"backup-tool":      "railway-postgres-backup",
+			"compression":      compressor.Name(),
+		}); err != nil {
+			return "", fmt.Errorf("failed to upload schema to %s: %w", dest.Name, err)
+		}
+		o.logger.Info("Stored changed schema", "destination", dest.Name, "key", key,
+			"size", utils.FormatBytes(int64(compressed.Len())))
+		metrics.SchemaDedup.WithLabelValues("uploaded").Inc()
+	}
+	return key, nil
+}
+
+// OpenSchema opens the schema a data-only backup references, decompressed, or
+// returns nil when obj is a complete backup.
+func OpenSchema(ctx context.Context, store storage.Storage, obj *storage.ObjectInfo) (io.ReadCloser, error) {
+	key := obj.Metadata[MetadataKeySchema]
+	if key == "" {
+		return nil, nil
+	}
+	if !storage.IsSchemaKey(key) {
+		return nil, fmt.Errorf("invalid schema reference %q", key)
+	}
+
+	codec, ok := compression.ForFilename(key)
+	if !ok {
+		return nil, fmt.Errorf("no codec available for %s", key)
+	}
+	reader, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schema %s: %w", key, err)
+	}
+	dr, err := codec.NewReader(reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("invalid %s schema: %w", codec.Name(), err)
+	}
+	return &schemaReader{ReadCloser: dr, object: reader}, nil
+}
+
+// schemaReader closes the stored schema along with its decompressor.
+type schemaReader struct {
+	io.ReadCloser
+	object io.Closer
+}
+
+// Close implements io.Closer.
+func (s *schemaReader) Close() error {
+	return errors.Join(s.ReadCloser.Close(), s.object.Close())
+}
+
+// pruneSchemas deletes the stored schemas of the configured prefix that no
+// remaining backup references, once they are older than cutoff, and returns
+// their keys. The age check keeps a schema a concurrent run has just stored
+// for the backup it is still uploading. A backup whose metadata cannot be read
+// may reference any schema, so nothing is pruned then.
+func (o *Orchestrator) pruneSchemas(ctx context.Context, store storage.Storage, provider string, cutoff time.Time) []string {
+	var schemas []storage.ObjectInfo
+	err := storage.ListIter(ctx, store, storage.SchemaPrefix+o.config.BackupFilePrefix+"-", func(obj storage.ObjectInfo) error {
+		if storage.IsSchemaKey(obj.Key) {
+			schemas = append(schemas, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		o.logger.Warn("Failed to list stored schemas", "destination", provider, "error", err)
+		return nil
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	referenced := make(map[string]bool)
+	err = storage.ListIter(ctx, store, o.config.BackupFilePrefix, func(obj storage.ObjectInfo) error {
+		if storage.IsReservedKey(obj.Key) || utils.IsSidecar(obj.Key) {
+			return nil
+		}
+		metadata, err := objectMetadata(ctx, store, obj)
+		if err != nil {
+			return err
+		}
+		if key := metadata[MetadataKeySchema]; key != "" {
+			referenced[key] = true
+		}
+		return nil
+	})
+	if err != nil {
+		o.logger.Warn("Failed to find the backups referencing stored schemas, keeping them all", "destination", provider, "error", err)
+		return nil
+	}
+
+	var deleted []string
+	for _, obj := range schemas {
+		if referenced[obj.Key] || !obj.LastModified.Before(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, obj.Key); err != nil {
+			o.logger.Error("Failed to delete unreferenced schema", "filename", obj.Key, "error", err)
+			metrics.RecordStorageOperation("delete", provider, false)
+			continue
+		}
+		o.logger.Info("Deleted unreferenced schema", "filename", obj.Key)
+		metrics.RecordStorageOperation("delete", provider, true)
+		deleted = append(deleted, obj.Key)
+	}
+	return deleted
+}
@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// schemaBackup adds schema dumps to a mock backup.
+type schemaBackup struct {
+	*mockBackup
+	schema string
+}
+
+func (s *schemaBackup) DumpSchema(ctx context.Context) ([]byte, error) {
+	return []byte(s.schema), nil
+}
+
+func TestSchemaHash(t *testing.T) {
+	base := "--\n-- PostgreSQL database dump\n--\n\\restrict abc123\nCREATE TABLE public.users (id integer);\n\\unrestrict abc123\n"
+
+	tests := []struct {
+		name   string
+		schema string
+		same   bool
+	}{
+		{"identical dump", base, true},
+		{"other comments and restrict key", "-- Dumped by pg_dump version 17.6\n\\restrict xyz789\nCREATE TABLE public.users (id integer);\n\\unrestrict xyz789\n", true},
+		{"changed DDL", "CREATE TABLE public.users (id bigint);\n", false},
+		{"added table", base + "CREATE TABLE public.orders (id integer);\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := SchemaHash([]byte(tt.schema)) == SchemaHash([]byte(base)); same != tt.same {
+				t.Errorf("hash matches the base schema = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_SchemaDedup(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		Compression:      "none",
+		SchemaDedup:      true,
+	}
+	store := &mockStorage{objects: map[string][]byte{}}
+	backup := &schemaBackup{
+		mockBackup: &mockBackup{dumpData: "data only"},
+		schema:     "CREATE TABLE public.users (id integer);\n",
+	}
+	orchestrator := NewOrchestrator(cfg, store, backup, logger)
+
+	if _, err := orchestrator.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	key := store.metadata[MetadataKeySchema]
+	if want := storage.SchemaKey("test", SchemaHash([]byte(backup.schema)), ""); key != want {
+		t.Fatalf("backup references schema %q, want %q", key, want)
+	}
+	if string(store.objects[key]) != backup.schema {
+		t.Fatalf("stored schema = %q, want the dumped schema", store.objects[key])
+	}
+
+	// An unchanged schema is referenced, not uploaded again
+	store.objects[key] = []byte("stored copy")
+	if _, err := orchestrator.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if store.metadata[MetadataKeySchema] != key {
+		t.Errorf("second backup references %q, want %q", store.metadata[MetadataKeySchema], key)
+	}
+	if string(store.objects[key]) != "stored copy" {
+		t.Error("unchanged schema was uploaded again")
+	}
+
+	// A changed schema is stored under its own key
+	backup.schema = "CREATE TABLE public.users (id bigint);\n"
+	if _, err := orchestrator.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	changed := store.metadata[MetadataKeySchema]
+	if changed == key || string(store.objects[changed]) != backup.schema {
+		t.Errorf("changed schema stored as %q = %q, want a new key holding it", changed, store.objects[changed])
+	}
+}
+
+func TestOrchestrator_PruneSchemas(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cutoff := time.Now().AddDate(0, 0, -7)
+	old := cutoff.Add(-time.Hour)
+
+	referenced := storage.SchemaKey("test", "aaaa", ".gz")
+	unreferenced := storage.SchemaKey("test", "bbbb", ".gz")
+	recent := storage.SchemaKey("test", "cccc", ".gz")
+	store := &mockStorage{listResult: []storage.ObjectInfo{
+		{Key: referenced, LastModified: old},
+		{Key: unreferenced, LastModified: old},
+		{Key: recent, LastModified: time.Now()},
+		{Key: "test-2025-01-10T03-00-00-000Z.tar.gz", LastModified: old, Metadata: map[string]string{
+			"backup-timestamp": old.Format(time.RFC3339),
+			MetadataKeySchema:  referenced,
+		}},
+	}}
+
+	cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "test", SchemaDedup: true}
+	deleted := NewOrchestrator(cfg, store, nil, logger).pruneSchemas(context.Background(), store, "s3", cutoff)
+	if !slices.Equal(deleted, []string{unreferenced}) || !slices.Equal(store.deleteCalls, []string{unreferenced}) {
+		t.Errorf("deleted %v (calls %v), want only the old unreferenced schema %s", deleted, store.deleteCalls, unreferenced)
+	}
+}
+
+// statFailingStorage fails every Stat of key, as a transient HEAD failure does.
+type statFailingStorage struct {
+	*mockStorage
+	key string
+}
+
+func (s *statFailingStorage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	if key == s.key {
+		return nil, errors.New("connection reset by peer")
+	}
+	return s.mockStorage.Stat(ctx, key)
+}
+
+func TestOrchestrator_PruneSchemasMetadataUnreadable(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cutoff := time.Now().AddDate(0, 0, -7)
+	old := cutoff.Add(-time.Hour)
+
+	// The listing carries no metadata, as on S3 and GCS, and the backup's HEAD fails
+	schema := storage.SchemaKey("test", "aaaa", ".gz")
+	backup := "test-2025-01-10T03-00-00-000Z.tar.gz"
+	mock := &mockStorage{listResult: []storage.ObjectInfo{
+		{Key: schema, LastModified: old},
+		{Key: backup, LastModified: old},
+	}}
+	store := &statFailingStorage{mockStorage: mock, key: backup}
+
+	cfg := &config.Config{StorageProvider: "s3", BackupFilePrefix: "test", SchemaDedup: true}
+	deleted := NewOrchestrator(cfg, store, nil, logger).pruneSchemas(context.Background(), store, "s3", cutoff)
+	if len(deleted) != 0 || len(mock.deleteCalls) != 0 {
+		t.Errorf("deleted %v (calls %v), want no schema pruned while a backup's metadata is unreadable", deleted, mock.deleteCalls)
+	}
+}
@@ -0,0 +1,51 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, compression := range []string{"gzip", "zstd", "none"} {
+		t.Run(compression, func(t *testing.T) {
+			fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.Config{StorageProvider: "filesystem", Compression: compression}
+
+			report, err := SelfTest(ctx, cfg, storage.NewPrefixedStorage(fs, "db/"), logger)
+			if err != nil || !report.Passed {
+				t.Fatalf("SelfTest() = %+v, %v; want passed", report, err)
+			}
+			if !strings.Contains(report.Key, "selftest-") {
+				t.Errorf("SelfTest() key = %s, want the self-test prefix", report.Key)
+			}
+			var names []string
+			for _, s := range report.Steps {
+				names = append(names, s.Name)
+			}
+			if got := strings.Join(names, ","); got != "compress,upload,checksum,list,download,delete" {
+				t.Errorf("SelfTest() steps = %s", got)
+			}
+
+			// Nothing is left behind
+			objects, err := fs.List(ctx, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != 0 {
+				t.Errorf("objects left after self-test: %v", objects)
+			}
+		})
+	}
+}
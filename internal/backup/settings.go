@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// settingsQuery lists settings changed from their defaults by the server
+// configuration, leaving out values set by this client's own connection.
+const settingsQuery = `SELECT name, setting || coalesce(unit, '') FROM pg_settings
+	WHERE source NOT IN ('default', 'override', 'client', 'session')
+	ORDER BY name`
+
+// SettingsSnapshot is the pg_settings snapshot stored next to a backup.
+type SettingsSnapshot struct {
+	CapturedAt time.Time         `json:"captured_at"`
+	Settings   map[string]string `json:"settings"`
+}
+
+// SettingChange is a setting whose value differs between two snapshots.
+// An empty Old or New value means the setting was added or returned to its default.
+type SettingChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Settings implements SettingsReader.
+func (p *PostgresBackup) Settings(ctx context.Context) (map[string]string, error) {
+	cmd := command(ctx, p.psqlBinary(),
+		"--no-psqlrc", "--no-password", "--tuples-only", "--no-align",
+		"--field-separator=\t",
+		"--command", settingsQuery,
+		p.connectionURL)
+	cmd.Env = append(os.Environ(), "PGPASSWORD=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pg_settings: %w, stderr: %s", err, stderrText(&stderr))
+	}
+	return parseSettings(string(output)), nil
+}
+
+// parseSettings parses tab-separated name/value rows.
+func parseSettings(output string) map[string]string {
+	settings := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		name, value, ok := strings.Cut(line, "\t")
+		if ok && name != "" {
+			settings[name] = value
+		}
+	}
+	return settings
+}
+
+// DiffSettings returns the settings that changed from prev to cur, sorted by name.
+func DiffSettings(prev, cur map[string]string) []SettingChange {
+	var changes []SettingChange
+	for name, value := range cur {
+		if old, ok := prev[name]; !ok || old != value {
+			changes = append(changes, SettingChange{Name: name, Old: prev[name], New: value})
+		}
+	}
+	for name, old := range prev {
+		if _, ok := cur[name]; !ok {
+			changes = append(changes, SettingChange{Name: name, Old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// snapshotSettings stores the server's settings next to the backup at key and
+// returns how they changed since the previous snapshot. Failures are logged and
+// never fail the backup.
+func (o *Orchestrator) snapshotSettings(ctx context.Context, key string) []SettingChange {
+	reader, ok := o.backup.(SettingsReader)
+	if !ok {
+		return nil
+	}
+
+	settings, err := reader.Settings(ctx)
+	if err != nil {
+		o.logger.Warn("Failed to capture settings snapshot", "error", err)
+		return nil
+	}
+
+	// Look up the previous snapshot before this run's is stored
+	previous, err := o.latestSettings(ctx)
+	if err != nil {
+		o.logger.Warn("Failed to load previous settings snapshot", "error", err)
+	}
+
+	data, err := json.MarshalIndent(SettingsSnapshot{CapturedAt: time.Now().UTC(), Settings: settings}, "", "  ")
+	if err != nil {
+		o.logger.Warn("Failed to encode settings snapshot", "error", err)
+		return nil
+	}
+	if err := o.storage.Upload(ctx, key+utils.SettingsSuffix, bytes.NewReader(data), map[string]string{
+		"backup-tool": "railway-postgres-backup",
+	}); err != nil {
+		o.logger.Warn("Failed to store settings snapshot", "error", err)
+	}
+
+	if previous == nil {
+		o.logger.Info("Captured first settings snapshot", "settings", len(settings))
+		return nil
+	}
+
+	changes := DiffSettings(previous.Settings, settings)
+	metrics.SettingsDrift.WithLabelValues(o.config.BackupFilePrefix).Set(float64(len(changes)))
+	for _, c := range changes {
+		o.logger.Warn("Server setting changed since previous backup",
+			"setting", c.Name, "old", c.Old, "new", c.New, "previous_snapshot", previous.CapturedAt)
+	}
+	return changes
+}
+
+// latestSettings loads the most recent settings snapshot, or nil if there is none.
+func (o *Orchestrator) latestSettings(ctx context.Context) (*SettingsSnapshot, error) {
+	keyTemplate, err := o.config.GetKeyTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := o.storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var latestKey string
+	var latestTime time.Time
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, utils.SettingsSuffix) {
+			continue
+		}
+		t, err := parseBackupTime(keyTemplate, obj.Key)
+		if err != nil {
+			t = obj.LastModified
+		}
+		if latestKey == "" || t.After(latestTime) {
+			latestKey, latestTime = obj.Key, t
+		}
+	}
+	if latestKey == "" {
+		return nil, nil
+	}
+
+	r, err := o.storage.Open(ctx, latestKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var snapshot SettingsSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("invalid settings snapshot %s: %w", latestKey, err)
+	}
+	return &snapshot, nil
+}
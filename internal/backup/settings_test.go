@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// settingsBackup is a mockBackup that also reports server settings.
+type settingsBackup struct {
+	mockBackup
+	settings map[string]string
+}
+
+func (m *settingsBackup) Settings(ctx context.Context) (map[string]string, error) {
+	return m.settings, nil
+}
+
+func TestParseSettings(t *testing.T) {
+	got := parseSettings("shared_buffers\t16384 8kB\nwork_mem\t4096kB\n\nsearch_path\t\"$user\", public\n")
+	want := map[string]string{
+		"shared_buffers": "16384 8kB",
+		"work_mem":       "4096kB",
+		"search_path":    `"$user", public`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSettings() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffSettings(t *testing.T) {
+	prev := map[string]string{"work_mem": "4096kB", "shared_buffers": "128MB", "jit": "off"}
+	cur := map[string]string{"work_mem": "65536kB", "shared_buffers": "128MB", "max_connections": "200"}
+
+	want := []SettingChange{
+		{Name: "jit", Old: "off"},
+		{Name: "max_connections", New: "200"},
+		{Name: "work_mem", Old: "4096kB", New: "65536kB"},
+	}
+	if got := DiffSettings(prev, cur); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffSettings() = %v, want %v", got, want)
+	}
+}
+
+func TestOrchestrator_SettingsDrift(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	previousKey := "2025/01/test-pg16-2025-01-01T00-00-00-000Z.tar.gz" + utils.SettingsSuffix
+	previous, err := json.Marshal(SettingsSnapshot{
+		CapturedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Settings:   map[string]string{"work_mem": "4096kB"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &mockStorage{
+		objects:    map[string][]byte{previousKey: previous},
+		listResult: []storage.ObjectInfo{{Key: previousKey}},
+	}
+	backup := &settingsBackup{
+		mockBackup: mockBackup{dumpData: "backup data"},
+		settings:   map[string]string{"work_mem": "65536kB"},
+	}
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		SettingsSnapshot: true,
+	}
+
+	result, err := NewOrchestrator(cfg, store, backup, logger).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := []SettingChange{{Name: "work_mem", Old: "4096kB", New: "65536kB"}}
+	if !reflect.DeepEqual(result.SettingsDrift, want) {
+		t.Errorf("SettingsDrift = %v, want %v", result.SettingsDrift, want)
+	}
+
+	var stored SettingsSnapshot
+	if err := json.Unmarshal(store.objects[result.Key+utils.SettingsSuffix], &stored); err != nil {
+		t.Fatalf("snapshot for %s not stored: %v", result.Key, err)
+	}
+	if stored.Settings["work_mem"] != "65536kB" {
+		t.Errorf("stored snapshot = %v, want work_mem=65536kB", stored.Settings)
+	}
+}
@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// Verify mode checks, as labelled in postgres_backup_verifier_checks_total.
+const (
+	VerifierCheckFreshness = "freshness"
+	VerifierCheckIntegrity = "integrity"
+	VerifierCheckRestore   = "restore"
+)
+
+// Verifier checks backups written by another deployment, for verify mode. It
+// reads storage through a ReadOnlyStorage, so it runs with read-only
+// credentials: the newest backup must be recent, must pass the same checks as
+// "backup verify", and with VERIFY_RESTORE must restore into
+// VERIFY_DATABASE_URL. Each backup is downloaded once; the freshness check
+// runs on every pass. Failures fire the failure hook.
+type Verifier struct {
+	orchestrator *Orchestrator
+	opts         VerifyOptions
+	verified     string // Newest backup already checked
+	now          func() time.Time
+}
+
+// NewVerifier creates a verifier reading store. restorer runs restore drills
+// and may be nil when VERIFY_RESTORE is off.
+func NewVerifier(cfg *config.Config, store storage.Storage, restorer Backup, logger *slog.Logger) *Verifier {
+	// Verification reads the remote copy; the local cache would be a write
+	o := NewOrchestrator(cfg, storage.NewReadOnlyStorage(storage.Uncached(store)), restorer, logger)
+
+	opts := VerifyOptions{}
+	var err error
+	if opts.PGRestore, err = FindNewestPGRestore(); err != nil {
+		logger.Warn("pg_restore not found, archive tables of contents are not listed", "error", err)
+	}
+
+	return &Verifier{orchestrator: o, opts: opts, now: time.Now}
+}
+
+// RegisterHook adds a hook that receives the failures found by the verifier.
+func (v *Verifier) RegisterHook(h hooks.Hook) {
+	v.orchestrator.RegisterHook(h)
+}
+
+// Run checks immediately and then on every VERIFY_INTERVAL_MINUTES until the
+// context is cancelled.
+func (v *Verifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(v.orchestrator.config.GetVerifyInterval())
+	defer ticker.Stop()
+
+	for {
+		if err := v.Check(ctx); err != nil && ctx.Err() == nil {
+			v.orchestrator.logger.Error("Backup verification failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check runs one pass: the freshness check, then the integrity check and
+// restore drill of the newest backup unless it was checked before.
+func (v *Verifier) Check(ctx context.Context) error {
+	o := v.orchestrator
+	newest, taken, err := storage.LatestBackup(ctx, o.storage, o.config.BackupFilePrefix)
+	if errors.Is(err, storage.ErrNotFound) {
+		return v.fail(ctx, VerifierCheckFreshness, "", errors.New("no backups found"))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find the newest backup: %w", err)
+	}
+
+	var errs []error
+	age := v.now().Sub(taken)
+	metrics.VerifierNewestAge.WithLabelValues(o.config.BackupFilePrefix).Set(age.Seconds())
+	if limit := o.config.GetMaxBackupAge(); limit > 0 {
+		if age > limit {
+			errs = append(errs, v.fail(ctx, VerifierCheckFreshness, newest.Key,
+				fmt.Errorf("the newest backup is %s old, more than MAX_BACKUP_AGE_HOURS=%d", age.Round(time.Minute), o.config.MaxBackupAgeHours)))
+		} else {
+			v.pass(VerifierCheckFreshness, newest.Key, "age", age.Round(time.Second))
+		}
+	}
+
+	if newest.Key == v.verified {
+		return errors.Join(errs...)
+	}
+	v.verified = newest.Key
+
+	o.logger.Info("Verifying backup", "key", newest.Key, "size", newest.Size)
+	check := VerifyBackup(ctx, o.storage, *newest, v.opts)
+	if !check.Passed {
+		return errors.Join(append(errs, v.fail(ctx, VerifierCheckIntegrity, newest.Key, errors.New(check.Error)))...)
+	}
+	v.pass(VerifierCheckIntegrity, newest.Key, "format", check.Format, "checksum", check.Checksum)
+
+	if o.config.VerifyRestore {
+		if err := v.drill(ctx, newest.Key); err != nil {
+			errs = append(errs, v.fail(ctx, VerifierCheckRestore, newest.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// drill restores the backup at key into VERIFY_DATABASE_URL. The outcome is
+// recorded in the restore check metrics but, unlike restore verification of a
+// new backup, is not stored next to the backup.
+func (v *Verifier) drill(ctx context.Context, key string) error {
+	o := v.orchestrator
+	restorer, ok := o.backup.(ScratchRestorer)
+	if !ok {
+		return errors.New("restore drills are not supported by the backup provider")
+	}
+
+	o.logger.Info("Restoring backup into the scratch database", "key", key)
+	start := time.Now()
+	check, err := o.restoreScratch(ctx, restorer, key)
+	duration := time.Since(start)
+	metrics.RestoreCheckDuration.WithLabelValues(o.config.BackupFilePrefix).Set(duration.Seconds())
+	if err != nil {
+		metrics.RestoreChecks.WithLabelValues(RestoreCheckFailed).Inc()
+		return err
+	}
+
+	metrics.RestoreChecks.WithLabelValues(RestoreCheckPassed).Inc()
+	metrics.RestoreCheckRows.WithLabelValues(o.config.BackupFilePrefix).Set(float64(check.Rows))
+	metrics.LastRestoreCheckTimestamp.WithLabelValues(o.config.BackupFilePrefix).Set(float64(time.Now().Unix()))
+	o.recordRecovery(check.Recovery)
+	v.pass(VerifierCheckRestore, key, "database", check.Database, "tables", check.Tables, "rows", check.Rows, "duration", duration)
+	return nil
+}
+
+// pass records and logs a passed check.
+func (v *Verifier) pass(check, key string, attrs ...any) {
+	metrics.VerifierChecks.WithLabelValues(check, VerificationPassed).Inc()
+	v.orchestrator.logger.Info("Backup check passed", append([]any{"check", check, "key", key}, attrs...)...)
+}
+
+// fail records a failed check, fires the failure hook and returns the error
+// describing it.
+func (v *Verifier) fail(ctx context.Context, check, key string, err error) error {
+	metrics.VerifierChecks.WithLabelValues(check, VerificationFailed).Inc()
+	err = fmt.Errorf("%s check failed: %w", check, err)
+	v.orchestrator.hooks.Fire(context.WithoutCancel(ctx), hooks.Event{Type: hooks.EventFailure, Key: key, Error: err.Error(), Phase: storage.PhaseVerifying})
+	return err
+}
@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+func TestVerifier_Check(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := selfTestArchive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := compression.Get("gzip", compression.DefaultLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	w, err := codec.NewWriter(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write(archive)
+	_ = w.Close()
+
+	// store uploads a backup taken at taken, as the backup deployment would
+	store := func(taken time.Time, data []byte) string {
+		t.Helper()
+		key := utils.GenerateBackupFilename("backup", taken, "16")
+		if err := fs.Upload(ctx, key, bytes.NewReader(data), nil); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		if err := storage.WriteChecksum(ctx, fs, key, sum[:]); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	good := store(time.Now().Add(-time.Hour), compressed.Bytes())
+	stored, _ := fs.List(ctx, "")
+
+	cfg := &config.Config{
+		Mode:                  config.ModeVerify,
+		StorageProvider:       "filesystem",
+		BackupFilePrefix:      "backup",
+		VerifyIntervalMinutes: 60,
+		MaxBackupAgeHours:     26,
+		VerifyRestore:         true,
+		VerifyDatabaseURL:     "postgres://localhost/scratch",
+	}
+	restorer := &scratchBackup{mockBackup: &mockBackup{}, check: &RestoreCheck{Database: "scratch", Tables: 3, Rows: 2}}
+	verifier := NewVerifier(cfg, fs, restorer, logger)
+	var failures []hooks.Event
+	verifier.RegisterHook(hooks.Func(func(ctx context.Context, e hooks.Event) error {
+		if e.Type == hooks.EventFailure {
+			failures = append(failures, e)
+		}
+		return nil
+	}))
+
+	if err := verifier.Check(ctx); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if restorer.restored != string(archive) {
+		t.Errorf("restore drill read %d bytes, want the %d-byte archive", len(restorer.restored), len(archive))
+	}
+	if objects, _ := fs.List(ctx, ""); len(objects) != len(stored) {
+		t.Errorf("storage holds %d objects after verification, want the %d it had", len(objects), len(stored))
+	}
+
+	// A backup already checked is not downloaded again
+	restorer.restored = ""
+	if err := verifier.Check(ctx); err != nil || restorer.restored != "" {
+		t.Errorf("second Check() = %v, restored %d bytes; want no error and no new drill", err, len(restorer.restored))
+	}
+
+	// The newest backup grows stale
+	verifier.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	if err := verifier.Check(ctx); err == nil || !strings.Contains(err.Error(), "freshness check failed") {
+		t.Errorf("stale Check() error = %v, want a freshness failure", err)
+	}
+	verifier.now = time.Now
+
+	// A newer backup that is damaged
+	damaged := store(time.Now(), compressed.Bytes()[:compressed.Len()-10])
+	if err := verifier.Check(ctx); err == nil || !strings.Contains(err.Error(), "integrity check failed") {
+		t.Errorf("damaged Check() error = %v, want an integrity failure", err)
+	}
+
+	if len(failures) != 2 || failures[0].Key != good || failures[1].Key != damaged {
+		t.Errorf("failure hooks = %+v, want the stale and the damaged backup", failures)
+	}
+}
+
+func TestVerifier_NoBackups(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Mode: config.ModeVerify, StorageProvider: "filesystem", BackupFilePrefix: "backup", VerifyIntervalMinutes: 60}
+
+	err = NewVerifier(cfg, fs, nil, logger).Check(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no backups found") {
+		t.Errorf("Check() error = %v, want no backups found", err)
+	}
+}
@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// ErrVerificationFailed is returned when an uploaded backup cannot be confirmed in storage.
+var ErrVerificationFailed = errors.New("backup verification failed")
+
+// Post-upload verification outcomes.
+const (
+	VerificationPassed = "passed"
+	VerificationFailed = "failed"
+)
+
+// verifyPollInterval is how often storage is checked again while waiting for a backup to appear.
+const verifyPollInterval = 2 * time.Second
+
+// VerifyUpload waits until the backup described by result is visible in every
+// destination with the expected size, or until timeout elapses.
+func (o *Orchestrator) VerifyUpload(ctx context.Context, result *Result, timeout time.Duration) error {
+	if result == nil || result.Key == "" {
+		return fmt.Errorf("%w: no backup was uploaded", ErrVerificationFailed)
+	}
+	if result.Size == 0 {
+		return fmt.Errorf("%w: backup %s is empty", ErrVerificationFailed, result.Key)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	destinations := []storage.Destination{{Name: o.config.StorageProvider, Storage: o.storage}}
+	if multi, ok := o.storage.(*storage.MultiStorage); ok {
+		destinations = multi.Destinations()
+	}
+
+	var errs []error
+	verified := 0
+	for _, dest := range destinations {
+		if err := o.waitForObject(ctx, dest.Storage, result); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", dest.Name, err))
+			continue
+		}
+		verified++
+		o.logger.Info("Backup verified", "destination", dest.Name, "key", result.Key, "size", result.Size)
+	}
+
+	// Mirror the upload policy: with REQUIRE_ALL_DESTINATIONS=false one copy is enough
+	if len(errs) > 0 && (o.config.RequireAllDestinations || verified == 0) {
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, errors.Join(errs...))
+	}
+	return nil
+}
+
+// waitForObject polls storage until the backup appears with the expected size.
+func (o *Orchestrator) waitForObject(ctx context.Context, store storage.Storage, result *Result) error {
+	ticker := time.NewTicker(verifyPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		lastErr = checkObject(ctx, store, result)
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for backup: %w", lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkObject confirms that storage holds the backup with the expected size.
+func checkObject(ctx context.Context, store storage.Storage, result *Result) error {
+	obj, err := store.Stat(ctx, result.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("backup %s not found", result.Key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	if obj.Size != result.Size {
+		return fmt.Errorf("size mismatch for %s: stored %d bytes, uploaded %d", result.Key, obj.Size, result.Size)
+	}
+	return nil
+}
+
+// VerifyChecksum confirms that the checksum sidecar stored next to the backup
+// described by result records the SHA-256 streamed during its upload, which
+// verifyAfterUpload compared the re-downloaded backup with.
+func (o *Orchestrator) VerifyChecksum(ctx context.Context, result *Result) error {
+	if result == nil || result.Key == "" {
+		return fmt.Errorf("%w: no backup was uploaded", ErrVerificationFailed)
+	}
+	recorded, err := storage.ReadChecksum(ctx, storage.Uncached(o.storage), result.Key)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read checksum of %s: %w", ErrVerificationFailed, result.Key, err)
+	}
+	if sum := hex.EncodeToString(recorded); sum != result.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch for %s: sidecar records sha256 %s, uploaded %s",
+			ErrVerificationFailed, result.Key, sum, result.SHA256)
+	}
+	o.logger.Info("Backup checksum verified", "key", result.Key, "sha256", result.SHA256)
+	return nil
+}
+
+// verifyAfterUpload re-downloads the stored backup at key from remote storage,
+// validates it, and compares its size and SHA-256 with the data streamed
+// during the upload.
+func (o *Orchestrator) verifyAfterUpload(ctx context.Context, key string, size int64, checksum []byte) error {
+	start := time.Now()
+	o.logger.Info("Verifying uploaded backup", "key", key)
+
+	err := o.redownload(ctx, key, size, checksum)
+	if err != nil {
+		metrics.Verifications.WithLabelValues(VerificationFailed).Inc()
+		return fmt.Errorf("%w for %s: %w", ErrVerificationFailed, key, err)
+	}
+
+	metrics.Verifications.WithLabelValues(VerificationPassed).Inc()
+	o.logger.Info("Uploaded backup verified", "key", key, "size", size,
+		"sha256", hex.EncodeToString(checksum), "duration", time.Since(start))
+	return nil
+}
+
+// redownload reads the stored backup in full, validating its archive
+// structure along the way.
+func (o *Orchestrator) redownload(ctx context.Context, key string, size int64, checksum []byte) error {
+	// The local cache holds its own copy; the remote one is what needs checking
+	reader, err := storage.Uncached(o.storage).Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	hash := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(reader, hash)}
+	if err := o.backup.Validate(ctx, counter); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if counter.count != size {
+		return fmt.Errorf("size mismatch: stored %d bytes, uploaded %d", counter.count, size)
+	}
+	if sum := hash.Sum(nil); !bytes.Equal(sum, checksum) {
+		return fmt.Errorf("checksum mismatch: stored sha256 %x, uploaded %x", sum, checksum)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+func TestOrchestrator_VerifyUpload(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	result := &Result{Key: "2025/01/test-pg16-2025-01-21T10-30-45-123Z.tar.gz", Size: 11}
+
+	tests := []struct {
+		name    string
+		result  *Result
+		listing []storage.ObjectInfo
+		wantErr bool
+	}{
+		{
+			name:    "backup present with matching size",
+			result:  result,
+			listing: []storage.ObjectInfo{{Key: result.Key, Size: 11}},
+			wantErr: false,
+		},
+		{
+			name:    "backup missing",
+			result:  result,
+			listing: nil,
+			wantErr: true,
+		},
+		{
+			name:    "size mismatch",
+			result:  result,
+			listing: []storage.ObjectInfo{{Key: result.Key, Size: 5}},
+			wantErr: true,
+		},
+		{
+			name:    "skipped run",
+			result:  &Result{Skipped: true, Reason: "too recent"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{StorageProvider: "s3"}
+			o := NewOrchestrator(cfg, &mockStorage{listResult: tt.listing}, &mockBackup{}, logger)
+
+			err := o.VerifyUpload(context.Background(), tt.result, 50*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyUpload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrVerificationFailed) {
+				t.Errorf("VerifyUpload() error = %v, want ErrVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteReportsResult(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		ForceBackup:      true,
+	}
+	store := &mockStorage{objects: map[string][]byte{}}
+
+	o := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger)
+	result, err := o.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if result.Skipped {
+		t.Fatal("Execute() skipped a forced backup")
+	}
+	if result.Key != store.uploadKey {
+		t.Errorf("Result.Key = %v, want uploaded key %v", result.Key, store.uploadKey)
+	}
+	if result.Size != int64(len("backup data")) {
+		t.Errorf("Result.Size = %v, want %v", result.Size, len("backup data"))
+	}
+
+	// The checksum sidecar verifies the backup when it is read back
+	if want := "d9c38b4a49e99d9a64a34bfec2d42ee152283003487e13460a1d6de6fb853473"; result.SHA256 != want {
+		t.Errorf("Result.SHA256 = %s, want %s", result.SHA256, want)
+	}
+	if sidecar := string(store.objects[result.Key+utils.ChecksumSuffix]); !strings.HasPrefix(sidecar, result.SHA256+"  ") {
+		t.Errorf("checksum sidecar = %q, want it to start with %s", sidecar, result.SHA256)
+	}
+	r, verified, err := storage.OpenVerified(context.Background(), store, result.Key)
+	if err != nil || !verified {
+		t.Fatalf("OpenVerified() = %v, %v; want a verified reader", verified, err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Errorf("reading the backup back error = %v", err)
+	}
+}
+
+// corruptingStorage stores a damaged copy of each backup.
+type corruptingStorage struct {
+	*mockStorage
+}
+
+func (c *corruptingStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	if key == storage.StateKey || utils.IsSidecar(key) {
+		return c.mockStorage.Upload(ctx, key, reader, metadata)
+	}
+	data, _ := io.ReadAll(reader)
+	data[0] ^= 0xff
+	return c.mockStorage.Upload(ctx, key, bytes.NewReader(data), metadata)
+}
+
+func TestOrchestrator_VerifyAfterUpload(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:   "s3",
+		BackupFilePrefix:  "test",
+		Compression:       "none",
+		VerifyAfterUpload: true,
+	}
+
+	tests := []struct {
+		name    string
+		store   storage.Storage
+		wantErr bool
+	}{
+		{name: "stored copy matches", store: &mockStorage{objects: map[string][]byte{}}},
+		{name: "stored copy differs", store: &corruptingStorage{&mockStorage{objects: map[string][]byte{}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := &mockBackup{dumpData: "backup data"}
+			_, err := NewOrchestrator(cfg, tt.store, backup, logger).Execute(context.Background())
+			if tt.wantErr != errors.Is(err, ErrVerificationFailed) {
+				t.Fatalf("Execute() error = %v, want verification failure %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "checksum mismatch") {
+				t.Errorf("Execute() error = %v, want a checksum mismatch", err)
+			}
+			if !backup.validated {
+				t.Error("Validate was not called on the re-downloaded backup")
+			}
+		})
+	}
+}
+
+func TestOrchestrator_VerifyChecksum(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		StorageProvider:  "s3",
+		BackupFilePrefix: "test",
+		Compression:      "none",
+		ForceBackup:      true,
+	}
+
+	tests := []struct {
+		name    string
+		sidecar func(line []byte) []byte // Replaces the stored sidecar; nil deletes it
+		wantErr bool
+	}{
+		{name: "sidecar matches", sidecar: func(line []byte) []byte { return line }},
+		{name: "sidecar differs", sidecar: func([]byte) []byte { return []byte(strings.Repeat("0", 64) + "  backup\n") }, wantErr: true},
+		{name: "sidecar missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStorage{objects: map[string][]byte{}}
+			o := NewOrchestrator(cfg, store, &mockBackup{dumpData: "backup data"}, logger)
+			result, err := o.Execute(context.Background())
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			sidecar := result.Key + utils.ChecksumSuffix
+			if tt.sidecar == nil {
+				delete(store.objects, sidecar)
+			} else {
+				store.objects[sidecar] = tt.sidecar(store.objects[sidecar])
+			}
+
+			err = o.VerifyChecksum(context.Background(), result)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrVerificationFailed) {
+				t.Errorf("VerifyChecksum() error = %v, want ErrVerificationFailed", err)
+			}
+		})
+	}
+}
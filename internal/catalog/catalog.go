@@ -0,0 +1,210 @@
+// Package catalog summarizes stored backups and exports the result as metrics.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/health"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Stats summarizes the backups held by a single destination.
+type Stats struct {
+	TotalBackups int
+	TotalBytes   int64
+	Oldest       time.Time
+	Newest       time.Time
+	PrefixCounts map[string]int // Backups per directory, e.g. "2025/01"
+}
+
+// Summarize computes catalog statistics from a storage listing.
+// Backup times come from the filename, falling back to the object's last modified time.
+func Summarize(objects []storage.ObjectInfo) Stats {
+	stats := Stats{PrefixCounts: make(map[string]int)}
+
+	for _, obj := range objects {
+		if utils.IsSidecar(obj.Key) || storage.IsReservedKey(obj.Key) {
+			continue
+		}
+
+		backupTime, err := utils.ParseBackupFilename(path.Base(obj.Key))
+		if err != nil {
+			backupTime = obj.LastModified
+		}
+
+		stats.TotalBackups++
+		stats.TotalBytes += obj.Size
+		stats.PrefixCounts[path.Dir(obj.Key)]++
+
+		if stats.Oldest.IsZero() || backupTime.Before(stats.Oldest) {
+			stats.Oldest = backupTime
+		}
+		if backupTime.After(stats.Newest) {
+			stats.Newest = backupTime
+		}
+	}
+
+	return stats
+}
+
+// Summary is the latest scan of one destination.
+type Summary struct {
+	Destination string    `json:"destination"`
+	Backups     int       `json:"backups"`
+	Bytes       int64     `json:"bytes"`
+	Oldest      time.Time `json:"oldest"`
+	Newest      time.Time `json:"newest"`
+	ScannedAt   time.Time `json:"scanned_at"`
+}
+
+// Scanner periodically lists destinations and publishes catalog gauges.
+type Scanner struct {
+	destinations []storage.Destination
+	interval     time.Duration
+	logger       *slog.Logger
+
+	mu        sync.Mutex
+	summaries map[string]Summary
+}
+
+// NewScanner creates a scanner for the given storage. A MultiStorage is
+// scanned per destination; any other storage is reported under name.
+func NewScanner(store storage.Storage, name string, interval time.Duration, logger *slog.Logger) *Scanner {
+	destinations := []storage.Destination{{Name: name, Storage: store}}
+	if multi, ok := store.(*storage.MultiStorage); ok {
+		destinations = multi.Destinations()
+	}
+
+	return &Scanner{
+		destinations: destinations,
+		interval:     interval,
+		logger:       logger,
+		summaries:    make(map[string]Summary),
+	}
+}
+
+// Run scans immediately and then on every interval until the context is cancelled.
+func (s *Scanner) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Scan(ctx); err != nil {
+			s.logger.Warn("Catalog scan failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan lists every destination once and updates the catalog gauges.
+func (s *Scanner) Scan(ctx context.Context) error {
+	var errs []error
+	now := time.Now()
+
+	for _, dest := range s.destinations {
+		objects, err := dest.Storage.List(ctx, "")
+		if err != nil {
+			metrics.CatalogScanErrors.WithLabelValues(dest.Name).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", dest.Name, err))
+			continue
+		}
+
+		stats := Summarize(objects)
+		publish(dest.Name, stats, now)
+
+		s.mu.Lock()
+		s.summaries[dest.Name] = Summary{
+			Destination: dest.Name,
+			Backups:     stats.TotalBackups,
+			Bytes:       stats.TotalBytes,
+			Oldest:      stats.Oldest,
+			Newest:      stats.Newest,
+			ScannedAt:   now,
+		}
+		s.mu.Unlock()
+
+		s.logger.Info("Catalog scanned",
+			"destination", dest.Name,
+			"backups", stats.TotalBackups,
+			"total_bytes", stats.TotalBytes,
+			"newest", stats.Newest,
+			"oldest", stats.Oldest,
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Summaries returns the latest scan of every destination that has been
+// scanned, in configuration order.
+func (s *Scanner) Summaries() []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(s.summaries))
+	for _, dest := range s.destinations {
+		if summary, ok := s.summaries[dest.Name]; ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries
+}
+
+// Health reports the recovery point of every destination: when its newest
+// backup was taken and how many seconds of data a restore would lose. A
+// destination that has not been scanned, or holds no backups, is left out.
+func (s *Scanner) Health(ctx context.Context) health.Check {
+	now := time.Now()
+	details := make(map[string]interface{})
+	for _, dest := range s.destinations {
+		newest, ok := metrics.RecoveryPoint.Get(dest.Name)
+		if !ok {
+			continue
+		}
+		details[dest.Name] = map[string]interface{}{
+			"last_backup":            newest.UTC(),
+			"recovery_point_seconds": int64(now.Sub(newest).Seconds()),
+		}
+	}
+	return health.Check{
+		Status:    health.StatusHealthy,
+		Timestamp: now,
+		Details:   details,
+	}
+}
+
+// publish sets the catalog gauges for a destination.
+func publish(destination string, stats Stats, now time.Time) {
+	metrics.CatalogBackups.WithLabelValues(destination).Set(float64(stats.TotalBackups))
+	metrics.CatalogBytes.WithLabelValues(destination).Set(float64(stats.TotalBytes))
+
+	// Ages are only meaningful when backups exist; drop stale series otherwise
+	if stats.TotalBackups > 0 {
+		metrics.CatalogOldestAge.WithLabelValues(destination).Set(now.Sub(stats.Oldest).Seconds())
+		metrics.CatalogNewestAge.WithLabelValues(destination).Set(now.Sub(stats.Newest).Seconds())
+		metrics.RecoveryPoint.Set(destination, stats.Newest)
+	} else {
+		metrics.CatalogOldestAge.DeleteLabelValues(destination)
+		metrics.CatalogNewestAge.DeleteLabelValues(destination)
+		metrics.RecoveryPoint.Delete(destination)
+	}
+
+	// Prefixes come and go with retention, so rebuild this destination's series
+	metrics.CatalogPrefixBackups.DeletePartialMatch(map[string]string{"destination": destination})
+	for prefix, count := range stats.PrefixCounts {
+		metrics.CatalogPrefixBackups.WithLabelValues(destination, prefix).Set(float64(count))
+	}
+}
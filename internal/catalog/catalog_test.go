@@ -0,0 +1,135 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// listStorage is a storage stub that only supports List.
+type listStorage struct {
+	objects []storage.ObjectInfo
+	err     error
+}
+
+func (l *listStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	return errors.New("not implemented")
+}
+
+func (l *listStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (l *listStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (l *listStorage) Delete(ctx context.Context, key string) error {
+	return errors.New("not implemented")
+}
+
+func (l *listStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return errors.New("not implemented")
+}
+
+func (l *listStorage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (l *listStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	return l.objects, l.err
+}
+
+func (l *listStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func TestSummarize(t *testing.T) {
+	objects := []storage.ObjectInfo{
+		{Key: "2025/01/backup-pg16-2025-01-10T03-00-00-000Z.tar.gz", Size: 100},
+		{Key: "2025/01/backup-pg16-2025-01-20T03-00-00-000Z.tar.gz", Size: 200},
+		{Key: "2025/01/backup-pg16-2025-01-20T03-00-00-000Z.tar.gz.settings.json", Size: 10},
+		{Key: "2025/02/backup-pg16-2025-02-01T03-00-00-000Z.tar.zst", Size: 300},
+		{Key: "2025/02/manual.sql", Size: 50, LastModified: time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{Key: storage.StateKey, Size: 120, LastModified: time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)},
+	}
+
+	stats := Summarize(objects)
+
+	if stats.TotalBackups != 4 {
+		t.Errorf("TotalBackups = %v, want 4", stats.TotalBackups)
+	}
+	if stats.TotalBytes != 650 {
+		t.Errorf("TotalBytes = %v, want 650", stats.TotalBytes)
+	}
+	if want := time.Date(2025, 1, 10, 3, 0, 0, 0, time.UTC); !stats.Oldest.Equal(want) {
+		t.Errorf("Oldest = %v, want %v", stats.Oldest, want)
+	}
+	if want := time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC); !stats.Newest.Equal(want) {
+		t.Errorf("Newest = %v, want %v (falls back to LastModified)", stats.Newest, want)
+	}
+	if stats.PrefixCounts["2025/01"] != 2 || stats.PrefixCounts["2025/02"] != 2 {
+		t.Errorf("PrefixCounts = %v, want 2 per month", stats.PrefixCounts)
+	}
+}
+
+func TestScanner_Scan(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	good := &listStorage{objects: []storage.ObjectInfo{
+		{Key: "2025/01/backup-pg16-2025-01-10T03-00-00-000Z.tar.gz", Size: 100},
+	}}
+	bad := &listStorage{err: errors.New("access denied")}
+
+	multi := storage.NewMultiStorage([]storage.Destination{
+		{Name: "catalog-good", Storage: good},
+		{Name: "catalog-bad", Storage: bad},
+	}, true, logger)
+
+	scanner := NewScanner(multi, "unused", time.Minute, logger)
+	if err := scanner.Scan(context.Background()); err == nil {
+		t.Fatal("Scan() expected error from failing destination, got nil")
+	}
+
+	if got := testutil.ToFloat64(metrics.CatalogBackups.WithLabelValues("catalog-good")); got != 1 {
+		t.Errorf("catalog backups = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.CatalogBytes.WithLabelValues("catalog-good")); got != 100 {
+		t.Errorf("catalog bytes = %v, want 100", got)
+	}
+	if got := testutil.ToFloat64(metrics.CatalogScanErrors.WithLabelValues("catalog-bad")); got != 1 {
+		t.Errorf("scan errors = %v, want 1", got)
+	}
+
+	newest := time.Date(2025, 1, 10, 3, 0, 0, 0, time.UTC)
+	if got, _ := metrics.RecoveryPoint.Get("catalog-good"); !got.Equal(newest) {
+		t.Errorf("recovery point = %v, want %v", got, newest)
+	}
+	if _, ok := metrics.RecoveryPoint.Get("catalog-bad"); ok {
+		t.Error("recovery point recorded for a destination that failed to scan")
+	}
+
+	check := scanner.Health(context.Background())
+	if len(check.Details) != 1 {
+		t.Fatalf("health details = %v, want only catalog-good", check.Details)
+	}
+	detail := check.Details["catalog-good"].(map[string]interface{})
+	if want := int64(time.Since(newest).Seconds()); detail["recovery_point_seconds"].(int64) < want-1 {
+		t.Errorf("recovery point seconds = %v, want about %d", detail["recovery_point_seconds"], want)
+	}
+
+	summaries := scanner.Summaries()
+	if len(summaries) != 1 || summaries[0].Destination != "catalog-good" {
+		t.Fatalf("Summaries() = %+v, want only catalog-good", summaries)
+	}
+	if got := summaries[0]; got.Backups != 1 || got.Bytes != 100 || !got.Newest.Equal(newest) {
+		t.Errorf("Summaries()[0] = %+v, want 1 backup of 100 bytes taken at %v", got, newest)
+	}
+}
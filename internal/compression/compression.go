@@ -0,0 +1,111 @@
+// Package compression provides pluggable codecs for backup streams.
+package compression
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLevel selects the codec's own default compression level.
+const DefaultLevel = 0
+
+// Compressor wraps a backup stream with a compression codec.
+type Compressor interface {
+	// Name returns the codec name used in configuration, e.g. "gzip".
+	Name() string
+
+	// Extension returns the filename suffix for compressed output, e.g. ".gz".
+	Extension() string
+
+	// NewWriter returns a writer that compresses into w. Close must be called to flush.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader that decompresses r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Factory creates a compressor for the given level. DefaultLevel selects the codec default.
+type Factory func(level int) (Compressor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a codec available by name. It panics if the name is already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = strings.ToLower(name)
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("compression: codec %q already registered", name))
+	}
+	registry[name] = factory
+}
+
+// Get returns the named codec configured with the given level.
+func Get(name string, level int) (Compressor, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression: %s (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+
+	c, err := factory(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s compression level: %w", name, err)
+	}
+	return c, nil
+}
+
+// Names returns the registered codec names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForFilename returns a default-level codec whose extension matches the filename.
+// Codecs sharing an extension (gzip and pgzip) produce compatible streams, so the
+// first registered name in sorted order is used. Filenames without a compression
+// extension (.dump, .sql, .tar) map to the "none" passthrough codec.
+func ForFilename(filename string) (Compressor, bool) {
+	for _, name := range Names() {
+		c, err := Get(name, DefaultLevel)
+		if err != nil {
+			continue
+		}
+		if ext := c.Extension(); ext != "" && strings.HasSuffix(filename, ext) {
+			return c, true
+		}
+	}
+
+	c, err := Get("none", DefaultLevel)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// checkLevel validates a level against an inclusive range, allowing DefaultLevel.
+func checkLevel(level, min, max int) error {
+	if level == DefaultLevel {
+		return nil
+	}
+	if level < min || level > max {
+		return fmt.Errorf("level %d out of range [%d, %d]", level, min, max)
+	}
+	return nil
+}
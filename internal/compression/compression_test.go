@@ -0,0 +1,109 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompressors_RoundTrip(t *testing.T) {
+	input := []byte(strings.Repeat("CREATE TABLE users (id serial primary key);\n", 1000))
+
+	for _, name := range Names() {
+		for _, level := range []int{DefaultLevel, 1} {
+			if name == "none" && level != DefaultLevel {
+				continue
+			}
+			c, err := Get(name, level)
+			if err != nil {
+				t.Fatalf("Get(%q, %d) error = %v", name, level, err)
+			}
+
+			var buf bytes.Buffer
+			w, err := c.NewWriter(&buf)
+			if err != nil {
+				t.Fatalf("%s: NewWriter() error = %v", name, err)
+			}
+			if _, err := w.Write(input); err != nil {
+				t.Fatalf("%s: Write() error = %v", name, err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("%s: Close() error = %v", name, err)
+			}
+
+			if name != "none" && buf.Len() >= len(input) {
+				t.Errorf("%s: compressed size %d not smaller than input %d", name, buf.Len(), len(input))
+			}
+
+			r, err := c.NewReader(&buf)
+			if err != nil {
+				t.Fatalf("%s: NewReader() error = %v", name, err)
+			}
+			got, err := io.ReadAll(r)
+			_ = r.Close()
+			if err != nil {
+				t.Fatalf("%s: ReadAll() error = %v", name, err)
+			}
+			if !bytes.Equal(got, input) {
+				t.Errorf("%s: round trip mismatch", name)
+			}
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		codec   string
+		level   int
+		wantExt string
+		wantErr bool
+	}{
+		{name: "gzip default", codec: "gzip", wantExt: ".gz"},
+		{name: "pgzip", codec: "pgzip", level: 6, wantExt: ".gz"},
+		{name: "zstd max", codec: "zstd", level: 22, wantExt: ".zst"},
+		{name: "case insensitive", codec: "ZSTD", wantExt: ".zst"},
+		{name: "unknown codec", codec: "brotli", wantErr: true},
+		{name: "gzip level too high", codec: "gzip", level: 10, wantErr: true},
+		{name: "zstd negative level", codec: "zstd", level: -1, wantErr: true},
+		{name: "none", codec: "none", wantExt: ""},
+		{name: "none with level", codec: "none", level: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Get(tt.codec, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && c.Extension() != tt.wantExt {
+				t.Errorf("Extension() = %v, want %v", c.Extension(), tt.wantExt)
+			}
+		})
+	}
+}
+
+func TestForFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		wantOK   bool
+		wantExt  string
+	}{
+		{filename: "backup-pg16-2025-01-21T10-30-45-123Z.tar.gz", wantOK: true, wantExt: ".gz"},
+		{filename: "backup-pg16-2025-01-21T10-30-45-123Z.tar.zst", wantOK: true, wantExt: ".zst"},
+		{filename: "backup-pg16-2025-01-21T10-30-45-123Z.dump", wantOK: true, wantExt: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			c, ok := ForFilename(tt.filename)
+			if ok != tt.wantOK {
+				t.Fatalf("ForFilename() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && c.Extension() != tt.wantExt {
+				t.Errorf("Extension() = %v, want %v", c.Extension(), tt.wantExt)
+			}
+		})
+	}
+}
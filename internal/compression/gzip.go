@@ -0,0 +1,75 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/pgzip"
+)
+
+func init() {
+	Register("gzip", newGzip)
+	Register("pgzip", newPgzip)
+}
+
+// gzipCompressor uses the standard library gzip implementation.
+type gzipCompressor struct {
+	level int
+}
+
+func newGzip(level int) (Compressor, error) {
+	if err := checkLevel(level, gzip.BestSpeed, gzip.BestCompression); err != nil {
+		return nil, err
+	}
+	if level == DefaultLevel {
+		level = gzip.DefaultCompression
+	}
+	return &gzipCompressor{level: level}, nil
+}
+
+// Name implements Compressor.Name.
+func (g *gzipCompressor) Name() string { return "gzip" }
+
+// Extension implements Compressor.Extension.
+func (g *gzipCompressor) Extension() string { return ".gz" }
+
+// NewWriter implements Compressor.NewWriter.
+func (g *gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, g.level)
+}
+
+// NewReader implements Compressor.NewReader.
+func (g *gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// pgzipCompressor compresses blocks in parallel and produces standard gzip output.
+type pgzipCompressor struct {
+	level int
+}
+
+func newPgzip(level int) (Compressor, error) {
+	if err := checkLevel(level, pgzip.BestSpeed, pgzip.BestCompression); err != nil {
+		return nil, err
+	}
+	if level == DefaultLevel {
+		level = pgzip.DefaultCompression
+	}
+	return &pgzipCompressor{level: level}, nil
+}
+
+// Name implements Compressor.Name.
+func (p *pgzipCompressor) Name() string { return "pgzip" }
+
+// Extension implements Compressor.Extension.
+func (p *pgzipCompressor) Extension() string { return ".gz" }
+
+// NewWriter implements Compressor.NewWriter.
+func (p *pgzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return pgzip.NewWriterLevel(w, p.level)
+}
+
+// NewReader implements Compressor.NewReader.
+func (p *pgzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return pgzip.NewReader(r)
+}
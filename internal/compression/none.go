@@ -0,0 +1,42 @@
+package compression
+
+import "io"
+
+func init() {
+	Register("none", newNone)
+}
+
+// noneCompressor passes data through unchanged, for dumps that pg_dump
+// already compresses itself (--format=custom).
+type noneCompressor struct{}
+
+func newNone(level int) (Compressor, error) {
+	if err := checkLevel(level, DefaultLevel, DefaultLevel); err != nil {
+		return nil, err
+	}
+	return noneCompressor{}, nil
+}
+
+// Name implements Compressor.Name.
+func (noneCompressor) Name() string { return "none" }
+
+// Extension implements Compressor.Extension.
+func (noneCompressor) Extension() string { return "" }
+
+// NewWriter implements Compressor.NewWriter.
+func (noneCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+// NewReader implements Compressor.NewReader.
+func (noneCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+// nopWriteCloser adds a no-op Close so the underlying writer stays open.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer.
+func (nopWriteCloser) Close() error { return nil }
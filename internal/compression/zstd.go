@@ -0,0 +1,47 @@
+package compression
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	Register("zstd", newZstd)
+}
+
+// zstdCompressor uses Zstandard, which is faster than gzip at a better ratio.
+type zstdCompressor struct {
+	level zstd.EncoderLevel
+}
+
+// newZstd maps levels 1-22 onto the encoder's speed presets.
+func newZstd(level int) (Compressor, error) {
+	if err := checkLevel(level, 1, 22); err != nil {
+		return nil, err
+	}
+	if level == DefaultLevel {
+		return &zstdCompressor{level: zstd.SpeedDefault}, nil
+	}
+	return &zstdCompressor{level: zstd.EncoderLevelFromZstd(level)}, nil
+}
+
+// Name implements Compressor.Name.
+func (z *zstdCompressor) Name() string { return "zstd" }
+
+// Extension implements Compressor.Extension.
+func (z *zstdCompressor) Extension() string { return ".zst" }
+
+// NewWriter implements Compressor.NewWriter.
+func (z *zstdCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(z.level))
+}
+
+// NewReader implements Compressor.NewReader.
+func (z *zstdCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
@@ -2,47 +2,336 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
+	"github.com/imedwei/railway-postgres-backup/internal/notify"
+	"github.com/imedwei/railway-postgres-backup/internal/schedule"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Run modes.
+const (
+	ModeBackup = "backup" // Run a single backup and exit
+	ModeServe  = "serve"  // Serve metrics and periodically scan the backup catalog
+	ModeFleet  = "fleet"  // Back up every Postgres service in a Railway project on a schedule
+	ModeDaemon = "daemon" // Stay running and back up on BACKUP_SCHEDULE or BACKUP_INTERVAL
+	ModeVerify = "verify" // Check backups written elsewhere on an interval, never writing to storage
+)
+
+// Log formats.
+const (
+	LogFormatText = "text" // logfmt-style key=value lines (default)
+	LogFormatJSON = "json" // One JSON object per line, for log drains and aggregation pipelines
 )
 
 // Config holds all application configuration.
 type Config struct {
+	// Mode selects what the process does: "backup" (default), "serve", "fleet", "daemon" or "verify"
+	Mode string
+
+	// CatalogScanIntervalMinutes is how often serve mode rescans storage
+	CatalogScanIntervalMinutes int
+
+	// Verify mode checks the newest backup every VerifyIntervalMinutes and
+	// alerts when it is older than MaxBackupAgeHours (0 disables the check)
+	VerifyIntervalMinutes int
+	MaxBackupAgeHours     int
+
+	// BackupSchedule is the cron expression daemon mode backs up on
+	BackupSchedule string
+
+	// BackupInterval is the simpler alternative to BackupSchedule, e.g. "6h".
+	// It is also the respawn protection interval, so the two cannot disagree.
+	BackupInterval string
+
+	// BackupJitter delays each run by a random time within a range, e.g. "0-15m",
+	// so services sharing a schedule do not all start at once
+	BackupJitter string
+
+	// Fleet mode: the Railway project whose Postgres services are backed up.
+	// Railway sets RAILWAY_PROJECT_ID and RAILWAY_ENVIRONMENT_ID in every deployment.
+	RailwayAPIToken               string
+	RailwayProjectID              string
+	RailwayEnvironmentID          string
+	RailwayEnvironmentName        string
+	FleetBackupIntervalHours      int // Default schedule; a service's BACKUP_INTERVAL_HOURS variable overrides it
+	FleetDiscoveryIntervalMinutes int // How often the project is rescanned for services
+	FleetConcurrency              int // Backups allowed to run at the same time
+
 	// Database configuration
 	DatabaseURL string
 
 	// Storage provider configuration
-	StorageProvider string // "s3" or "gcs"
+	StorageProvider string // "s3", "r2", "spaces", "wasabi", "gcs", "filesystem" or "rclone"; comma-separated for multiple destinations
+
+	// Multi-destination configuration
+	RequireAllDestinations   bool           // Fail the run if any destination fails
+	DestinationRetentionDays map[string]int // Per-destination retention overrides
 
 	// S3 configuration
-	AWSAccessKeyID     string
+	AWSAccessKeyID     string // Optional; falls back to the default credential chain
 	AWSSecretAccessKey string
 	S3Bucket           string
 	S3Region           string
 	S3Endpoint         string // Optional custom endpoint
 
+	// AWSDeleteAccessKeyID and AWSDeleteSecretAccessKey are used only for
+	// deletes, so the main keys can be limited to writing and reading
+	AWSDeleteAccessKeyID     string
+	AWSDeleteSecretAccessKey string
+
+	// R2AccountID derives the Cloudflare R2 endpoint for the "r2" provider
+	R2AccountID string
+
+	// S3 upload tuning (0 keeps the SDK defaults of 5 MiB parts and 5 concurrent parts)
+	S3UploadPartSizeMB  int
+	S3UploadConcurrency int
+
+	// S3MultipartThresholdMB is the size above which uploads stream as multipart (0 uses 64 MiB)
+	S3MultipartThresholdMB int
+
+	// S3ProbeCapabilities probes S3-compatible endpoints at startup and turns
+	// off the options they do not support
+	S3ProbeCapabilities bool
+
 	// GCS configuration
 	GCSBucket                string
 	GoogleProjectID          string
-	GoogleServiceAccountJSON string
+	GoogleServiceAccountJSON string // Optional; falls back to Application Default Credentials
+	GCSLocation              string // Location for a bucket created on first run
+
+	// GoogleDeleteServiceAccountJSON is used only for deletes, so the main
+	// service account can be limited to creating and reading objects
+	GoogleDeleteServiceAccountJSON string
+
+	// CreateBucketIfMissing creates the S3/GCS bucket on startup when it does not exist
+	CreateBucketIfMissing bool
+
+	// Filesystem configuration
+	FilesystemPath string // Directory or mounted volume for backups
+
+	// LocalCachePath mirrors each backup to a local directory, such as a mounted
+	// volume, for fast emergency restores. The cache keeps the LocalCacheKeep most
+	// recent backups within LocalCacheMaxSizeMB (0 leaves either unlimited)
+	LocalCachePath      string
+	LocalCacheKeep      int
+	LocalCacheMaxSizeMB int
+
+	// Rclone configuration; the remote is defined in rclone.conf (RCLONE_CONFIG)
+	// or through rclone's RCLONE_CONFIG_<NAME>_* environment variables
+	RcloneRemote string // Remote and optional path, e.g. "b2:my-bucket/backups"
+	RcloneBinary string // rclone executable (defaults to "rclone" on PATH)
 
 	// Respawn protection
 	RespawnProtectionHours int
 	ForceBackup            bool
 
+	// ClockSkewWarnSeconds is how far the local clock may drift from the
+	// storage provider's before runs warn; 0 disables the check
+	ClockSkewWarnSeconds int
+
+	// DryRun reports what a run would do (key, rate limiter decision, retention
+	// deletions) without dumping, uploading or deleting anything
+	DryRun bool
+
+	// BackupPaused skips every run, for maintenance windows; backups can also be
+	// paused through the /pause endpoint, which stores a pause object
+	BackupPaused bool
+
+	// BackupLabel tags the backups of a run, e.g. "pre-migration-2025-06", so
+	// special backups can be found later; --label overrides it
+	BackupLabel string
+
+	// BackupKeep pins the backups of a run so retention never deletes them;
+	// --keep sets it for a single run
+	BackupKeep bool
+
+	// RunLock holds a lease object in storage during each run so instances sharing
+	// a bucket never back up concurrently; it expires after RunLockTTLSeconds
+	// unless renewed by its holder
+	RunLock           bool
+	RunLockTTLSeconds int
+
 	// Backup options
 	BackupFilePrefix string
 	PGDumpOptions    string
 	RetentionDays    int
+	RetentionMinKeep int  // Backups retention never deletes below, however old
+	RetentionDryRun  bool // Report what retention would delete instead of deleting
+
+	// QuotaEmergencyRetentionDays prunes backups older than this many days when
+	// an upload fails because storage is full (0 disables it)
+	QuotaEmergencyRetentionDays int
+
+	// StorageKeyTemplate lays out backup keys, e.g. "{{.Year}}/{{.Month}}/{{.Day}}/{{.Filename}}"
+	// (empty uses utils.DefaultKeyTemplate)
+	StorageKeyTemplate string
+
+	// BackupTags are object tags applied to S3 uploads, e.g. "env=prod,app=myservice"
+	BackupTags string
+
+	// Compression codec for backup streams ("gzip", "pgzip", "zstd", "none") and its level (0 = codec default)
+	Compression      string
+	CompressionLevel int
+
+	// DumpStallTimeoutMinutes aborts pg_dump when it produces no output for this long (0 disables)
+	DumpStallTimeoutMinutes int
+
+	// DumpLockDiagnostics reports sessions holding blocking locks when a dump stalls or fails
+	DumpLockDiagnostics bool
+
+	// DumpFallbackExport exports with the built-in SQL exporter when no pg_dump binary can be run
+	DumpFallbackExport bool
+
+	// SchemaDedup dumps data only and stores the schema apart, once per distinct schema
+	SchemaDedup bool
+
+	// SettingsSnapshot stores non-default pg_settings next to each backup and reports drift
+	SettingsSnapshot bool
+
+	// MetricsHistory appends a record of each run to metrics/history.jsonl in storage
+	MetricsHistory bool
+
+	// BackupCatalog indexes every backup in catalog.json in storage
+	BackupCatalog bool
+
+	// VerifyAfterUpload re-downloads each new backup, validates it and compares
+	// its checksum with the streamed data
+	VerifyAfterUpload bool
+
+	// UploadIntegrityCheck compares the ETag or CRC32C the provider returns for
+	// each upload with the data sent and fails the upload on a mismatch
+	UploadIntegrityCheck bool
+
+	// VerifyRestore restores each new backup into a scratch database: VerifyDatabaseURL
+	// when set, otherwise a database created and dropped on the source server
+	VerifyRestore     bool
+	VerifyDatabaseURL string
+
+	// VerifySample checks the structure of each new backup within a time budget,
+	// reading every tar member in the first VerifySampleHeadMB and a random
+	// VerifySamplePercent of the rest, for archives too large to restore
+	VerifySample              bool
+	VerifySampleBudgetSeconds int
+	VerifySampleHeadMB        int
+	VerifySamplePercent       int
+
+	// Lifecycle hooks: a shell command and/or an HTTP endpoint receiving run events as JSON
+	HookCommand        string
+	HookURL            string
+	HookEvents         string // Comma-separated event types (empty for all)
+	HookTimeoutSeconds int
+
+	// OnFailureCommand and OnFailureURL receive only failure events, with the
+	// failed phase and the count of consecutive failed runs
+	OnFailureCommand string
+	OnFailureURL     string
+
+	// NotifyWebhookURL receives a JSON summary of every run, delivered up to
+	// NotifyMaxAttempts times with each attempt bounded by NotifyTimeoutSeconds
+	NotifyWebhookURL     string
+	NotifyTimeoutSeconds int
+	NotifyMaxAttempts    int
+
+	// DiscordWebhookURL receives the run summary as a Discord embed
+	DiscordWebhookURL string
+
+	// TelegramBotToken and TelegramChatID send the run summary as a message
+	// from a Telegram bot to a chat
+	TelegramBotToken string
+	TelegramChatID   string
+
+	// NotifyOn is "always" to notify after every run or "failure" for failed runs only
+	NotifyOn string
+
+	// HealthcheckURL is a dead man's switch pinged at /start when a run begins
+	// and at the URL itself, or /fail, when it ends, whatever NotifyOn says
+	HealthcheckURL string
+
+	// TempDir is where backup data is spooled to disk when needed
+	// (falls back to TMPDIR when empty)
+	TempDir string
+
+	// LogLevel is the minimum level logged: debug, info (when empty), warn or error
+	LogLevel string
+
+	// LogFormat is the format of log lines: "text" (when empty) or "json"
+	LogFormat string
+
+	// LogAttrs are static attributes added to every log line, e.g. "service=backup,env=prod"
+	LogAttrs string
+
+	// LogRetryEvery keeps the logs of the first and every Nth retry attempt only (1 logs all)
+	LogRetryEvery int
+
+	// ConfigFile is the optional JSON file holding structured settings
+	ConfigFile string
+
+	// TableFilters restrict the rows backed up for individual tables (from ConfigFile)
+	TableFilters []TableFilter
+
+	// DumpFilter selects the objects included in the dump (from ConfigFile)
+	DumpFilter *DumpFilter
+
+	// Preconditions are database health checks run before each dump (from ConfigFile)
+	Preconditions []Precondition
+
+	// Priority of pg_dump, pg_restore and psql child processes
+	ChildNice        int    // CPU niceness 0-19 (0 leaves priority unchanged)
+	ChildIOClass     string // I/O scheduling class: "best-effort" or "idle" (empty leaves it unchanged)
+	ChildIOLevel     int    // Best-effort I/O priority 0-7
+	ChildCgroupAware bool   // Cap parallel restore workers at the container's CPU quota
+
+	// Restore tuning used by the rollback command
+	RestoreJobs               int    // Parallel pg_restore workers (custom archives only)
+	RestoreDisableTriggers    bool   // Skip triggers and FK checks while loading (requires superuser)
+	RestoreMaintenanceWorkMem string // Session maintenance_work_mem, e.g. "1GB"
+	RestoreAnalyze            bool   // Run ANALYZE after the restore
+	RestoreLatest             bool   // Restore the most recent backup when no key is given
+	RestoreForceRemote        bool   // Read restored and verified backups from remote storage, bypassing the local cache
+
+	// Sharing backups through signed download URLs
+	ShareExpiryMinutes int    // Default validity of a shared URL
+	ShareToken         string // Bearer token for the /share endpoint (disabled when empty)
+
+	// APIToken is the bearer token of the control endpoints such as /pause
+	// (disabled when empty)
+	APIToken string
+
+	// Storage fault injection for hardening tests, refused in production
+	FaultReadPercent   int // Share of reads failed on purpose
+	FaultUploadPercent int // Share of uploads and copies failed on purpose
+	FaultDeletePercent int // Share of deletes failed on purpose
+	FaultSeed          int // Seeds the fault sequence (0 picks a random seed)
 }
 
 // Load reads configuration from environment variables.
 func Load() (*Config, error) {
 	cfg := &Config{
+		Mode:            getEnvString("MODE", ModeBackup),
 		DatabaseURL:     os.Getenv("DATABASE_URL"),
 		StorageProvider: os.Getenv("STORAGE_PROVIDER"),
+		BackupSchedule:  os.Getenv("BACKUP_SCHEDULE"),
+		BackupInterval:  os.Getenv("BACKUP_INTERVAL"),
+		BackupJitter:    os.Getenv("BACKUP_JITTER"),
+
+		// Fleet
+		RailwayAPIToken:        os.Getenv("RAILWAY_API_TOKEN"),
+		RailwayProjectID:       os.Getenv("RAILWAY_PROJECT_ID"),
+		RailwayEnvironmentID:   os.Getenv("RAILWAY_ENVIRONMENT_ID"),
+		RailwayEnvironmentName: os.Getenv("RAILWAY_ENVIRONMENT_NAME"),
 
 		// S3
 		AWSAccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
@@ -50,21 +339,137 @@ func Load() (*Config, error) {
 		S3Bucket:           os.Getenv("S3_BUCKET"),
 		S3Region:           os.Getenv("S3_REGION"),
 		S3Endpoint:         os.Getenv("S3_ENDPOINT"),
+		R2AccountID:        os.Getenv("R2_ACCOUNT_ID"),
+
+		AWSDeleteAccessKeyID:     os.Getenv("AWS_DELETE_ACCESS_KEY_ID"),
+		AWSDeleteSecretAccessKey: os.Getenv("AWS_DELETE_SECRET_ACCESS_KEY"),
 
 		// GCS
 		GCSBucket:                os.Getenv("GCS_BUCKET"),
 		GoogleProjectID:          os.Getenv("GOOGLE_PROJECT_ID"),
 		GoogleServiceAccountJSON: os.Getenv("GOOGLE_SERVICE_ACCOUNT_JSON"),
+		GCSLocation:              os.Getenv("GCS_LOCATION"),
+
+		GoogleDeleteServiceAccountJSON: os.Getenv("GOOGLE_DELETE_SERVICE_ACCOUNT_JSON"),
+
+		// Filesystem
+		FilesystemPath: os.Getenv("FILESYSTEM_PATH"),
+
+		// Local cache
+		LocalCachePath: os.Getenv("LOCAL_CACHE_PATH"),
+
+		// Rclone
+		RcloneRemote: os.Getenv("RCLONE_REMOTE"),
+		RcloneBinary: os.Getenv("RCLONE_BINARY"),
 
 		// Options
-		BackupFilePrefix: os.Getenv("BACKUP_FILE_PREFIX"),
-		PGDumpOptions:    os.Getenv("PG_DUMP_OPTIONS"),
+		BackupFilePrefix:   os.Getenv("BACKUP_FILE_PREFIX"),
+		PGDumpOptions:      os.Getenv("PG_DUMP_OPTIONS"),
+		StorageKeyTemplate: os.Getenv("STORAGE_KEY_TEMPLATE"),
+		TempDir:            os.Getenv("BACKUP_TMPDIR"),
+		LogLevel:           os.Getenv("LOG_LEVEL"),
+		LogFormat:          os.Getenv("LOG_FORMAT"),
+		LogAttrs:           os.Getenv("LOG_ATTRS"),
+		Compression:        os.Getenv("COMPRESSION"), // Empty selects gzip unless pg_dump compresses
+		BackupTags:         os.Getenv("BACKUP_TAGS"),
+		ConfigFile:         os.Getenv("CONFIG_FILE"),
+		HookCommand:        os.Getenv("HOOK_COMMAND"),
+		HookURL:            os.Getenv("HOOK_URL"),
+		HookEvents:         os.Getenv("HOOK_EVENTS"),
+		OnFailureCommand:   os.Getenv("ON_FAILURE_COMMAND"),
+		OnFailureURL:       os.Getenv("ON_FAILURE_URL"),
+		NotifyWebhookURL:   os.Getenv("NOTIFY_WEBHOOK_URL"),
+		DiscordWebhookURL:  os.Getenv("DISCORD_WEBHOOK_URL"),
+		TelegramBotToken:   os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TelegramChatID:     os.Getenv("TELEGRAM_CHAT_ID"),
+		NotifyOn:           getEnvString("NOTIFY_ON", notify.OnAlways),
+		HealthcheckURL:     os.Getenv("HEALTHCHECK_URL"),
+
+		VerifyDatabaseURL: os.Getenv("VERIFY_DATABASE_URL"),
+
+		RestoreMaintenanceWorkMem: os.Getenv("RESTORE_MAINTENANCE_WORK_MEM"),
+		ChildIOClass:              os.Getenv("CHILD_IONICE_CLASS"),
+	}
+
+	// Structured settings come from the optional config file
+	if cfg.ConfigFile != "" {
+		fc, err := loadFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TableFilters = fc.TableFilters
+		cfg.DumpFilter = fc.DumpFilter
+		cfg.Preconditions = fc.Preconditions
 	}
 
 	// Parse numeric values with defaults
 	cfg.RespawnProtectionHours = getEnvInt("RESPAWN_PROTECTION_HOURS", 6)
 	cfg.RetentionDays = getEnvInt("RETENTION_DAYS", 0) // 0 means no retention policy
+	cfg.RetentionMinKeep = getEnvInt("RETENTION_MIN_KEEP", 1)
+	cfg.RetentionDryRun = getEnvBool("RETENTION_DRY_RUN", false)
+	cfg.QuotaEmergencyRetentionDays = getEnvInt("QUOTA_EMERGENCY_RETENTION_DAYS", 0)
 	cfg.ForceBackup = getEnvBool("FORCE_BACKUP", false)
+	cfg.ClockSkewWarnSeconds = getEnvInt("CLOCK_SKEW_WARN_SECONDS", 60)
+	cfg.DryRun = getEnvBool("DRY_RUN", false)
+	cfg.BackupPaused = getEnvBool("BACKUP_PAUSED", false)
+	cfg.BackupLabel = os.Getenv("BACKUP_LABEL")
+	cfg.BackupKeep = getEnvBool("BACKUP_KEEP", false)
+	cfg.LocalCacheKeep = getEnvInt("LOCAL_CACHE_KEEP", 3)
+	cfg.LocalCacheMaxSizeMB = getEnvInt("LOCAL_CACHE_MAX_SIZE_MB", 0)
+	cfg.RunLock = getEnvBool("RUN_LOCK", false)
+	cfg.RunLockTTLSeconds = getEnvInt("RUN_LOCK_TTL_SECONDS", 300)
+	cfg.CatalogScanIntervalMinutes = getEnvInt("CATALOG_SCAN_INTERVAL_MINUTES", 15)
+	cfg.VerifyIntervalMinutes = getEnvInt("VERIFY_INTERVAL_MINUTES", 60)
+	cfg.MaxBackupAgeHours = getEnvInt("MAX_BACKUP_AGE_HOURS", 26)
+	cfg.FleetBackupIntervalHours = getEnvInt("FLEET_BACKUP_INTERVAL_HOURS", 24)
+	cfg.FleetDiscoveryIntervalMinutes = getEnvInt("FLEET_DISCOVERY_INTERVAL_MINUTES", 15)
+	cfg.FleetConcurrency = getEnvInt("FLEET_CONCURRENCY", 1)
+	cfg.DumpStallTimeoutMinutes = getEnvInt("DUMP_STALL_TIMEOUT_MINUTES", 10)
+	cfg.DumpLockDiagnostics = getEnvBool("DUMP_LOCK_DIAGNOSTICS", true)
+	cfg.DumpFallbackExport = getEnvBool("DUMP_FALLBACK_EXPORT", false)
+	cfg.SchemaDedup = getEnvBool("SCHEMA_DEDUP", false)
+	cfg.SettingsSnapshot = getEnvBool("SETTINGS_SNAPSHOT", false)
+	cfg.MetricsHistory = getEnvBool("METRICS_HISTORY", false)
+	cfg.BackupCatalog = getEnvBool("BACKUP_CATALOG", false)
+	cfg.VerifyAfterUpload = getEnvBool("VERIFY_AFTER_UPLOAD", false)
+	cfg.UploadIntegrityCheck = getEnvBool("UPLOAD_INTEGRITY_CHECK", true)
+	cfg.VerifyRestore = getEnvBool("VERIFY_RESTORE", false)
+	cfg.VerifySample = getEnvBool("VERIFY_SAMPLE", false)
+	cfg.VerifySampleBudgetSeconds = getEnvInt("VERIFY_SAMPLE_BUDGET_SECONDS", 300)
+	cfg.VerifySampleHeadMB = getEnvInt("VERIFY_SAMPLE_HEAD_MB", 64)
+	cfg.VerifySamplePercent = getEnvInt("VERIFY_SAMPLE_PERCENT", 10)
+	cfg.HookTimeoutSeconds = getEnvInt("HOOK_TIMEOUT_SECONDS", 30)
+	cfg.LogRetryEvery = getEnvInt("LOG_RETRY_EVERY", 1)
+	cfg.NotifyTimeoutSeconds = getEnvInt("NOTIFY_TIMEOUT_SECONDS", 10)
+	cfg.NotifyMaxAttempts = getEnvInt("NOTIFY_MAX_ATTEMPTS", 3)
+	cfg.CompressionLevel = getEnvInt("COMPRESSION_LEVEL", compression.DefaultLevel)
+	cfg.RequireAllDestinations = getEnvBool("REQUIRE_ALL_DESTINATIONS", true)
+	cfg.CreateBucketIfMissing = getEnvBool("CREATE_BUCKET_IF_MISSING", false)
+	cfg.S3UploadPartSizeMB = getEnvInt("S3_UPLOAD_PART_SIZE_MB", 0)
+	cfg.S3UploadConcurrency = getEnvInt("S3_UPLOAD_CONCURRENCY", 0)
+	cfg.S3MultipartThresholdMB = getEnvInt("S3_MULTIPART_THRESHOLD_MB", 0)
+	cfg.S3ProbeCapabilities = getEnvBool("S3_PROBE_CAPABILITIES", false)
+	cfg.RestoreJobs = getEnvInt("RESTORE_JOBS", 1)
+	cfg.RestoreDisableTriggers = getEnvBool("RESTORE_DISABLE_TRIGGERS", false)
+	cfg.RestoreAnalyze = getEnvBool("RESTORE_ANALYZE", true)
+	cfg.RestoreLatest = getEnvBool("RESTORE_LATEST", false)
+	cfg.RestoreForceRemote = getEnvBool("RESTORE_FORCE_REMOTE", false)
+	cfg.ShareExpiryMinutes = getEnvInt("SHARE_EXPIRY_MINUTES", 60)
+	cfg.ShareToken = os.Getenv("SHARE_TOKEN")
+	cfg.APIToken = os.Getenv("API_TOKEN")
+	cfg.FaultReadPercent = getEnvInt("FAULT_READ_PERCENT", 0)
+	cfg.FaultUploadPercent = getEnvInt("FAULT_UPLOAD_PERCENT", 0)
+	cfg.FaultDeletePercent = getEnvInt("FAULT_DELETE_PERCENT", 0)
+	cfg.FaultSeed = getEnvInt("FAULT_SEED", 0)
+	cfg.ChildNice = getEnvInt("CHILD_NICE", 0)
+	cfg.ChildIOLevel = getEnvInt("CHILD_IONICE_LEVEL", 4)
+	cfg.ChildCgroupAware = getEnvBool("CHILD_CGROUP_AWARE", true)
+
+	// Per-destination retention, e.g. RETENTION_DAYS_GCS=30
+	cfg.DestinationRetentionDays = make(map[string]int)
+	for _, provider := range cfg.StorageProviders() {
+		cfg.DestinationRetentionDays[provider] = getEnvInt("RETENTION_DAYS_"+strings.ToUpper(provider), cfg.RetentionDays)
+	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
@@ -76,44 +481,258 @@ func Load() (*Config, error) {
 
 // Validate checks if the configuration is valid.
 func (c *Config) Validate() error {
-	if c.DatabaseURL == "" {
-		return fmt.Errorf("DATABASE_URL is required")
+	switch c.Mode {
+	case "", ModeBackup:
+		if c.DatabaseURL == "" {
+			return fmt.Errorf("DATABASE_URL is required")
+		}
+	case ModeServe:
+		if c.CatalogScanIntervalMinutes <= 0 {
+			return fmt.Errorf("CATALOG_SCAN_INTERVAL_MINUTES must be positive")
+		}
+	case ModeFleet:
+		if err := c.validateFleet(); err != nil {
+			return err
+		}
+	case ModeDaemon:
+		if c.DatabaseURL == "" {
+			return fmt.Errorf("DATABASE_URL is required")
+		}
+		if err := c.validateDaemon(); err != nil {
+			return err
+		}
+	case ModeVerify:
+		if err := c.validateVerify(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid MODE: %s (must be '%s', '%s', '%s', '%s' or '%s')", c.Mode, ModeBackup, ModeServe, ModeFleet, ModeDaemon, ModeVerify)
 	}
 
-	if c.StorageProvider == "" {
+	if _, _, err := parseJitter(c.BackupJitter); err != nil {
+		return fmt.Errorf("invalid BACKUP_JITTER: %w", err)
+	}
+
+	if _, err := ParseLogLevel(c.LogLevel); err != nil {
+		return err
+	}
+	switch c.LogFormat {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT: %s (must be '%s' or '%s')", c.LogFormat, LogFormatText, LogFormatJSON)
+	}
+	if _, err := ParseLogAttrs(c.LogAttrs); err != nil {
+		return fmt.Errorf("invalid LOG_ATTRS: %w", err)
+	}
+	if c.LogRetryEvery < 0 {
+		return fmt.Errorf("LOG_RETRY_EVERY must not be negative")
+	}
+
+	if c.SchemaDedup && c.DumpFallbackExport {
+		return fmt.Errorf("SCHEMA_DEDUP cannot be combined with DUMP_FALLBACK_EXPORT: the built-in exporter cannot dump data only")
+	}
+
+	if len(c.StorageProviders()) == 0 {
 		return fmt.Errorf("STORAGE_PROVIDER is required")
 	}
 
-	switch c.StorageProvider {
-	case "s3":
-		if err := c.validateS3(); err != nil {
-			return err
+	seen := make(map[string]bool)
+	for _, provider := range c.StorageProviders() {
+		if seen[provider] {
+			return fmt.Errorf("duplicate storage provider in STORAGE_PROVIDER: %s", provider)
 		}
-	case "gcs":
-		if err := c.validateGCS(); err != nil {
-			return err
+		seen[provider] = true
+
+		switch provider {
+		case "s3":
+			if err := c.validateS3(); err != nil {
+				return err
+			}
+		case "r2":
+			if err := c.validateR2(); err != nil {
+				return err
+			}
+		case "spaces", "wasabi":
+			if err := c.validateRegionalPreset(provider); err != nil {
+				return err
+			}
+		case "gcs":
+			if err := c.validateGCS(); err != nil {
+				return err
+			}
+		case "filesystem":
+			if err := c.validateFilesystem(); err != nil {
+				return err
+			}
+		case "rclone":
+			if err := c.validateRclone(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid STORAGE_PROVIDER: %s (must be 's3', 'r2', 'spaces', 'wasabi', 'gcs', 'filesystem' or 'rclone')", provider)
 		}
-	default:
-		return fmt.Errorf("invalid STORAGE_PROVIDER: %s (must be 's3' or 'gcs')", c.StorageProvider)
 	}
 
 	if c.RespawnProtectionHours < 0 {
 		return fmt.Errorf("RESPAWN_PROTECTION_HOURS must be non-negative")
 	}
 
+	if c.ClockSkewWarnSeconds < 0 {
+		return fmt.Errorf("CLOCK_SKEW_WARN_SECONDS must be non-negative")
+	}
+
+	if c.BackupLabel != "" {
+		if err := ValidateLabel(c.BackupLabel); err != nil {
+			return fmt.Errorf("invalid BACKUP_LABEL: %w", err)
+		}
+	}
+
+	// Pinning every scheduled backup would disable retention
+	if c.BackupKeep && (c.Mode == ModeDaemon || c.Mode == ModeFleet) {
+		return fmt.Errorf("BACKUP_KEEP cannot be used in %s mode; pin one-off runs with --keep", c.Mode)
+	}
+
+	if err := c.validateLocalCache(); err != nil {
+		return err
+	}
+
+	if c.RunLock && c.RunLockTTLSeconds < minRunLockTTLSeconds {
+		return fmt.Errorf("RUN_LOCK_TTL_SECONDS must be at least %d", minRunLockTTLSeconds)
+	}
+
 	if c.RetentionDays < 0 {
 		return fmt.Errorf("RETENTION_DAYS must be non-negative")
 	}
 
+	if c.RetentionMinKeep < 0 {
+		return fmt.Errorf("RETENTION_MIN_KEEP must be non-negative")
+	}
+
+	if c.QuotaEmergencyRetentionDays < 0 {
+		return fmt.Errorf("QUOTA_EMERGENCY_RETENTION_DAYS must be non-negative")
+	}
+
+	if (c.AWSDeleteAccessKeyID == "") != (c.AWSDeleteSecretAccessKey == "") {
+		return fmt.Errorf("AWS_DELETE_ACCESS_KEY_ID and AWS_DELETE_SECRET_ACCESS_KEY must be set together")
+	}
+
+	for provider, days := range c.DestinationRetentionDays {
+		if days < 0 {
+			return fmt.Errorf("RETENTION_DAYS_%s must be non-negative", strings.ToUpper(provider))
+		}
+	}
+
+	if c.DumpStallTimeoutMinutes < 0 {
+		return fmt.Errorf("DUMP_STALL_TIMEOUT_MINUTES must be non-negative")
+	}
+
+	if c.ChildNice < 0 || c.ChildNice > 19 {
+		return fmt.Errorf("CHILD_NICE must be between 0 and 19")
+	}
+
+	switch c.ChildIOClass {
+	case "", utils.IOClassBestEffort, utils.IOClassIdle:
+	default:
+		return fmt.Errorf("invalid CHILD_IONICE_CLASS: %s (must be '%s' or '%s')", c.ChildIOClass, utils.IOClassBestEffort, utils.IOClassIdle)
+	}
+
+	if c.ChildIOLevel < 0 || c.ChildIOLevel > 7 {
+		return fmt.Errorf("CHILD_IONICE_LEVEL must be between 0 and 7")
+	}
+
+	if c.VerifyRestore && c.VerifyDatabaseURL != "" && c.VerifyDatabaseURL == c.DatabaseURL {
+		return fmt.Errorf("VERIFY_DATABASE_URL must not be DATABASE_URL, as restore verification overwrites it")
+	}
+
+	if c.VerifySample {
+		if c.VerifySampleBudgetSeconds < 0 || c.VerifySampleHeadMB < 0 {
+			return fmt.Errorf("VERIFY_SAMPLE_BUDGET_SECONDS and VERIFY_SAMPLE_HEAD_MB must be non-negative")
+		}
+		if c.VerifySamplePercent < 0 || c.VerifySamplePercent > 100 {
+			return fmt.Errorf("VERIFY_SAMPLE_PERCENT must be between 0 and 100")
+		}
+	}
+
+	if c.ShareExpiryMinutes < 0 || c.ShareExpiryMinutes > 7*24*60 {
+		return fmt.Errorf("SHARE_EXPIRY_MINUTES must be at most 10080 (7 days), the longest signed URL providers accept")
+	}
+
+	if c.RestoreJobs < 0 {
+		return fmt.Errorf("RESTORE_JOBS must be non-negative")
+	}
+
+	for _, percent := range []int{c.FaultReadPercent, c.FaultUploadPercent, c.FaultDeletePercent} {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("FAULT_READ_PERCENT, FAULT_UPLOAD_PERCENT and FAULT_DELETE_PERCENT must be between 0 and 100")
+		}
+	}
+	if c.FaultInjection() && strings.EqualFold(c.RailwayEnvironmentName, "production") {
+		return fmt.Errorf("storage fault injection (FAULT_*_PERCENT) must not be enabled in the production environment")
+	}
+
+	if c.RestoreMaintenanceWorkMem != "" && !memorySettingPattern.MatchString(c.RestoreMaintenanceWorkMem) {
+		return fmt.Errorf("RESTORE_MAINTENANCE_WORK_MEM must be a size such as 512MB or 2GB")
+	}
+
+	if _, err := c.GetBackupTags(); err != nil {
+		return fmt.Errorf("invalid BACKUP_TAGS: %w", err)
+	}
+
+	if _, err := c.GetCompressor(); err != nil {
+		return fmt.Errorf("invalid COMPRESSION/COMPRESSION_LEVEL: %w", err)
+	}
+
+	if err := c.validateHooks(); err != nil {
+		return err
+	}
+
+	if err := c.validateNotify(); err != nil {
+		return err
+	}
+
+	if _, err := c.GetKeyTemplate(); err != nil {
+		return fmt.Errorf("invalid STORAGE_KEY_TEMPLATE: %w", err)
+	}
+
+	if err := validateTableFilters(c.TableFilters); err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+
+	if err := c.DumpFilter.Validate(); err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+
+	if err := validatePreconditions(c.Preconditions); err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+
 	return nil
 }
 
-func (c *Config) validateS3() error {
-	if c.AWSAccessKeyID == "" {
-		return fmt.Errorf("AWS_ACCESS_KEY_ID is required for S3 storage")
+func (c *Config) validateFleet() error {
+	if c.RailwayAPIToken == "" {
+		return fmt.Errorf("RAILWAY_API_TOKEN is required in fleet mode")
 	}
-	if c.AWSSecretAccessKey == "" {
-		return fmt.Errorf("AWS_SECRET_ACCESS_KEY is required for S3 storage")
+	if c.RailwayProjectID == "" || c.RailwayEnvironmentID == "" {
+		return fmt.Errorf("RAILWAY_PROJECT_ID and RAILWAY_ENVIRONMENT_ID are required in fleet mode")
+	}
+	if c.FleetBackupIntervalHours <= 0 {
+		return fmt.Errorf("FLEET_BACKUP_INTERVAL_HOURS must be positive")
+	}
+	if c.FleetDiscoveryIntervalMinutes <= 0 {
+		return fmt.Errorf("FLEET_DISCOVERY_INTERVAL_MINUTES must be positive")
+	}
+	if c.FleetConcurrency <= 0 {
+		return fmt.Errorf("FLEET_CONCURRENCY must be positive")
+	}
+	return nil
+}
+
+func (c *Config) validateS3() error {
+	// Static keys are optional; without them the default AWS credential chain is used
+	// (environment, shared config, web identity / IRSA, instance profile)
+	if (c.AWSAccessKeyID == "") != (c.AWSSecretAccessKey == "") {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
 	}
 	if c.S3Bucket == "" {
 		return fmt.Errorf("S3_BUCKET is required for S3 storage")
@@ -121,9 +740,65 @@ func (c *Config) validateS3() error {
 	if c.S3Region == "" && c.S3Endpoint == "" {
 		return fmt.Errorf("S3_REGION is required for S3 storage (unless S3_ENDPOINT is set)")
 	}
+	return c.validateS3Upload()
+}
+
+// validateS3Upload checks the upload tuning shared by S3-compatible providers.
+func (c *Config) validateS3Upload() error {
+	if c.S3UploadPartSizeMB != 0 && (c.S3UploadPartSizeMB < minS3PartSizeMB || c.S3UploadPartSizeMB > maxS3PartSizeMB) {
+		return fmt.Errorf("S3_UPLOAD_PART_SIZE_MB must be between %d and %d", minS3PartSizeMB, maxS3PartSizeMB)
+	}
+	if c.S3UploadConcurrency < 0 {
+		return fmt.Errorf("S3_UPLOAD_CONCURRENCY must be non-negative")
+	}
+	if c.S3MultipartThresholdMB < 0 {
+		return fmt.Errorf("S3_MULTIPART_THRESHOLD_MB must be non-negative")
+	}
 	return nil
 }
 
+func (c *Config) validateR2() error {
+	// R2 only accepts its own API tokens, so the default AWS credential chain does not apply
+	if c.AWSAccessKeyID == "" || c.AWSSecretAccessKey == "" {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for R2 storage")
+	}
+	if c.S3Bucket == "" {
+		return fmt.Errorf("S3_BUCKET is required for R2 storage")
+	}
+	if c.S3Endpoint == "" {
+		if c.R2AccountID == "" {
+			return fmt.Errorf("R2_ACCOUNT_ID is required for R2 storage (unless S3_ENDPOINT is set)")
+		}
+		if !r2AccountIDPattern.MatchString(c.R2AccountID) {
+			return fmt.Errorf("R2_ACCOUNT_ID must be the hexadecimal account ID from the Cloudflare dashboard")
+		}
+	}
+	if c.BackupTags != "" {
+		return fmt.Errorf("BACKUP_TAGS is not supported by R2 storage")
+	}
+	return c.validateS3Upload()
+}
+
+// validateRegionalPreset checks an S3-compatible preset whose endpoint is derived from S3_REGION.
+func (c *Config) validateRegionalPreset(provider string) error {
+	name := regionalEndpoints[provider].name
+	if c.AWSAccessKeyID == "" || c.AWSSecretAccessKey == "" {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for %s storage", name)
+	}
+	if c.S3Bucket == "" {
+		return fmt.Errorf("S3_BUCKET is required for %s storage", name)
+	}
+	if c.S3Endpoint == "" {
+		if c.S3Region == "" {
+			return fmt.Errorf("S3_REGION is required for %s storage (unless S3_ENDPOINT is set)", name)
+		}
+		if !regionPattern.MatchString(c.S3Region) {
+			return fmt.Errorf("S3_REGION %q is not a valid %s region", c.S3Region, name)
+		}
+	}
+	return c.validateS3Upload()
+}
+
 func (c *Config) validateGCS() error {
 	if c.GCSBucket == "" {
 		return fmt.Errorf("GCS_BUCKET is required for GCS storage")
@@ -131,17 +806,530 @@ func (c *Config) validateGCS() error {
 	if c.GoogleProjectID == "" {
 		return fmt.Errorf("GOOGLE_PROJECT_ID is required for GCS storage")
 	}
-	if c.GoogleServiceAccountJSON == "" {
-		return fmt.Errorf("GOOGLE_SERVICE_ACCOUNT_JSON is required for GCS storage")
+	// GOOGLE_SERVICE_ACCOUNT_JSON is optional; without it Application Default
+	// Credentials are used (metadata server, workload identity, GOOGLE_APPLICATION_CREDENTIALS)
+	return nil
+}
+
+func (c *Config) validateFilesystem() error {
+	if c.FilesystemPath == "" {
+		return fmt.Errorf("FILESYSTEM_PATH is required for filesystem storage")
+	}
+	return nil
+}
+
+func (c *Config) validateLocalCache() error {
+	if c.LocalCachePath == "" {
+		return nil
+	}
+	if c.LocalCacheKeep < 0 || c.LocalCacheMaxSizeMB < 0 {
+		return fmt.Errorf("LOCAL_CACHE_KEEP and LOCAL_CACHE_MAX_SIZE_MB must be non-negative")
+	}
+	if c.LocalCacheKeep == 0 && c.LocalCacheMaxSizeMB == 0 {
+		return fmt.Errorf("LOCAL_CACHE_KEEP or LOCAL_CACHE_MAX_SIZE_MB must be set to bound the local cache")
+	}
+	if c.HasStorageProvider("filesystem") && filepath.Clean(c.LocalCachePath) == filepath.Clean(c.FilesystemPath) {
+		return fmt.Errorf("LOCAL_CACHE_PATH must differ from FILESYSTEM_PATH")
+	}
+	return nil
+}
+
+func (c *Config) validateRclone() error {
+	if c.RcloneRemote == "" {
+		return fmt.Errorf("RCLONE_REMOTE is required for rclone storage")
+	}
+	if name, _, ok := strings.Cut(c.RcloneRemote, ":"); !ok || name == "" {
+		return fmt.Errorf("RCLONE_REMOTE must be of the form name:path, got %q", c.RcloneRemote)
+	}
+	return nil
+}
+
+// StorageProviders returns the configured storage destinations in order.
+func (c *Config) StorageProviders() []string {
+	var providers []string
+	for _, p := range strings.Split(c.StorageProvider, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// GetHookEvents returns the event types delivered to external hooks (empty for all).
+func (c *Config) GetHookEvents() []string {
+	var events []string
+	for _, e := range strings.Split(c.HookEvents, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// GetHookTimeout returns the time allowed for each hook call.
+func (c *Config) GetHookTimeout() time.Duration {
+	return time.Duration(c.HookTimeoutSeconds) * time.Second
+}
+
+func (c *Config) validateHooks() error {
+	if c.HookCommand == "" && c.HookURL == "" && c.OnFailureCommand == "" && c.OnFailureURL == "" {
+		return nil
+	}
+	for _, hook := range []struct{ name, url string }{{"HOOK_URL", c.HookURL}, {"ON_FAILURE_URL", c.OnFailureURL}} {
+		if hook.url == "" {
+			continue
+		}
+		u, err := url.Parse(hook.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s: must be an http or https URL", hook.name)
+		}
+	}
+	for _, e := range c.GetHookEvents() {
+		if !slices.Contains(hooks.Events, e) {
+			return fmt.Errorf("invalid HOOK_EVENTS: unknown event %q (must be one of %s)", e, strings.Join(hooks.Events, ", "))
+		}
+	}
+	if c.HookTimeoutSeconds <= 0 {
+		return fmt.Errorf("HOOK_TIMEOUT_SECONDS must be positive")
+	}
+	return nil
+}
+
+// GetNotifyTimeout returns the time allowed for each notification request.
+func (c *Config) GetNotifyTimeout() time.Duration {
+	return time.Duration(c.NotifyTimeoutSeconds) * time.Second
+}
+
+// ParseLogLevel returns the level named by LOG_LEVEL. Empty selects info.
+func ParseLogLevel(s string) (slog.Level, error) {
+	level := slog.LevelInfo
+	if s == "" {
+		return level, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid LOG_LEVEL: %q (must be debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// ParseLogAttrs parses LOG_ATTRS ("key=value,key2=value2") into attributes,
+// in the order given.
+func ParseLogAttrs(s string) ([]slog.Attr, error) {
+	var attrs []slog.Attr
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("attribute %q must be in key=value form", pair)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate attribute key %q", key)
+		}
+		seen[key] = true
+		attrs = append(attrs, slog.String(key, strings.TrimSpace(value)))
+	}
+	return attrs, nil
+}
+
+// Secrets returns the configured credentials to keep out of logs and error
+// messages: database passwords, storage keys, API tokens and the webhook URLs
+// that embed a token.
+func (c *Config) Secrets() []string {
+	secrets := []string{
+		c.AWSSecretAccessKey,
+		c.AWSDeleteSecretAccessKey,
+		c.RailwayAPIToken,
+		c.ShareToken,
+		c.APIToken,
+		c.TelegramBotToken,
+		c.DiscordWebhookURL,
+		c.HealthcheckURL,
+		c.NotifyWebhookURL,
+		c.HookURL,
+		c.OnFailureURL,
+	}
+	for _, dsn := range []string{c.DatabaseURL, c.VerifyDatabaseURL} {
+		secrets = append(secrets, URLPassword(dsn))
+	}
+	for _, account := range []string{c.GoogleServiceAccountJSON, c.GoogleDeleteServiceAccountJSON} {
+		var key struct {
+			PrivateKey string `json:"private_key"`
+		}
+		if json.Unmarshal([]byte(account), &key) == nil {
+			secrets = append(secrets, key.PrivateKey)
+		}
+	}
+	return slices.DeleteFunc(secrets, func(s string) bool { return s == "" })
+}
+
+// URLPassword returns the password of a connection URL, or "" when it has
+// none or does not parse.
+func URLPassword(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// NotifyEnabled reports whether any notification integration is configured.
+func (c *Config) NotifyEnabled() bool {
+	return c.NotifyWebhookURL != "" || c.DiscordWebhookURL != "" || c.TelegramBotToken != "" || c.HealthcheckURL != ""
+}
+
+func (c *Config) validateNotify() error {
+	if !c.NotifyEnabled() {
+		return nil
+	}
+	for _, n := range []struct{ name, url string }{
+		{"NOTIFY_WEBHOOK_URL", c.NotifyWebhookURL},
+		{"DISCORD_WEBHOOK_URL", c.DiscordWebhookURL},
+		{"HEALTHCHECK_URL", c.HealthcheckURL},
+	} {
+		if n.url == "" {
+			continue
+		}
+		u, err := url.Parse(n.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s: must be an http or https URL", n.name)
+		}
+	}
+	if (c.TelegramBotToken == "") != (c.TelegramChatID == "") {
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set together")
+	}
+	if c.NotifyOn != notify.OnAlways && c.NotifyOn != notify.OnFailure {
+		return fmt.Errorf("invalid NOTIFY_ON: %q (must be %s or %s)", c.NotifyOn, notify.OnAlways, notify.OnFailure)
+	}
+	if c.NotifyTimeoutSeconds <= 0 {
+		return fmt.Errorf("NOTIFY_TIMEOUT_SECONDS must be positive")
+	}
+	if c.NotifyMaxAttempts < 1 {
+		return fmt.Errorf("NOTIFY_MAX_ATTEMPTS must be at least 1")
 	}
 	return nil
 }
 
+// minRunLockTTLSeconds leaves room for a few renewals to fail before the lock expires.
+const minRunLockTTLSeconds = 30
+
+// minBackupInterval keeps an interval schedule from hammering the database.
+const minBackupInterval = time.Minute
+
+// validateDaemon checks that daemon mode has exactly one valid schedule.
+func (c *Config) validateDaemon() error {
+	switch {
+	case c.BackupSchedule == "" && c.BackupInterval == "":
+		return fmt.Errorf("BACKUP_SCHEDULE or BACKUP_INTERVAL is required in daemon mode")
+	case c.BackupSchedule != "" && c.BackupInterval != "":
+		return fmt.Errorf("BACKUP_SCHEDULE and BACKUP_INTERVAL cannot both be set")
+	case c.BackupSchedule != "":
+		if _, err := schedule.Parse(c.BackupSchedule); err != nil {
+			return fmt.Errorf("invalid BACKUP_SCHEDULE: %w", err)
+		}
+		return nil
+	}
+
+	interval, err := time.ParseDuration(c.BackupInterval)
+	if err != nil {
+		return fmt.Errorf("invalid BACKUP_INTERVAL: %w", err)
+	}
+	if interval < minBackupInterval {
+		return fmt.Errorf("BACKUP_INTERVAL must be at least %s", minBackupInterval)
+	}
+	if c.ForceBackup {
+		return fmt.Errorf("FORCE_BACKUP cannot be combined with BACKUP_INTERVAL, which is the respawn protection interval")
+	}
+	return nil
+}
+
+// validateVerify checks the settings of verify mode, which has no source
+// database: restore drills need a scratch database of their own.
+func (c *Config) validateVerify() error {
+	if c.VerifyIntervalMinutes <= 0 {
+		return fmt.Errorf("VERIFY_INTERVAL_MINUTES must be positive")
+	}
+	if c.MaxBackupAgeHours < 0 {
+		return fmt.Errorf("MAX_BACKUP_AGE_HOURS must be non-negative")
+	}
+	if c.VerifyRestore && c.VerifyDatabaseURL == "" {
+		return fmt.Errorf("VERIFY_DATABASE_URL is required for VERIFY_RESTORE in verify mode")
+	}
+	if c.CreateBucketIfMissing {
+		return fmt.Errorf("CREATE_BUCKET_IF_MISSING cannot be used in verify mode, which never writes to storage")
+	}
+	return nil
+}
+
+// labelPattern matches a backup label: letters, digits, dots, underscores and
+// hyphens, safe in object metadata and file names.
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// ValidateLabel checks that label can tag a backup.
+func ValidateLabel(label string) error {
+	if !labelPattern.MatchString(label) {
+		return fmt.Errorf("label %q must be 1-63 letters, digits, dots, underscores or hyphens, starting with a letter or digit", label)
+	}
+	return nil
+}
+
+// memorySettingPattern matches a PostgreSQL memory setting such as "64MB".
+var memorySettingPattern = regexp.MustCompile(`^[0-9]+(kB|MB|GB|TB)?$`)
+
+// r2AccountIDPattern matches a Cloudflare account ID.
+var r2AccountIDPattern = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// regionPattern matches a region name that is safe to place in a hostname.
+var regionPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// regionalEndpoints are the S3-compatible presets whose endpoint is derived from S3_REGION.
+var regionalEndpoints = map[string]struct {
+	name     string
+	endpoint string // fmt template taking the region
+}{
+	"spaces": {name: "DigitalOcean Spaces", endpoint: "https://%s.digitaloceanspaces.com"},
+	"wasabi": {name: "Wasabi", endpoint: "https://s3.%s.wasabisys.com"},
+}
+
+// GetS3Endpoint returns the endpoint for an S3-compatible provider. S3_ENDPOINT
+// always wins; otherwise presets derive it from R2_ACCOUNT_ID or S3_REGION and
+// AWS uses its default.
+func (c *Config) GetS3Endpoint(provider string) string {
+	if c.S3Endpoint != "" {
+		return c.S3Endpoint
+	}
+	if provider == "r2" {
+		return fmt.Sprintf("https://%s.r2.cloudflarestorage.com", strings.ToLower(c.R2AccountID))
+	}
+	if preset, ok := regionalEndpoints[provider]; ok {
+		return fmt.Sprintf(preset.endpoint, c.S3Region)
+	}
+	return ""
+}
+
+// GetS3Region returns the signing region for an S3-compatible provider.
+// R2 ignores the region but requires "auto" when none is given.
+func (c *Config) GetS3Region(provider string) string {
+	if c.S3Region == "" && provider == "r2" {
+		return "auto"
+	}
+	return c.S3Region
+}
+
+// HasStorageProvider reports whether the given provider is one of the destinations.
+func (c *Config) HasStorageProvider(provider string) bool {
+	for _, p := range c.StorageProviders() {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRetentionDays returns the retention period for a destination,
+// falling back to RETENTION_DAYS when no override is configured.
+func (c *Config) GetRetentionDays(provider string) int {
+	if days, ok := c.DestinationRetentionDays[provider]; ok {
+		return days
+	}
+	return c.RetentionDays
+}
+
+// RetentionEnabled reports whether any destination has a retention policy.
+func (c *Config) RetentionEnabled() bool {
+	for _, provider := range c.StorageProviders() {
+		if c.GetRetentionDays(provider) > 0 {
+			return true
+		}
+	}
+	return c.RetentionDays > 0
+}
+
+// GetRunLockTTL returns how long the run lock lasts without renewal.
+func (c *Config) GetRunLockTTL() time.Duration {
+	return time.Duration(c.RunLockTTLSeconds) * time.Second
+}
+
+// GetVerifySampleBudget returns the time allowed for a sampled verification (0 for no limit).
+func (c *Config) GetVerifySampleBudget() time.Duration {
+	return time.Duration(c.VerifySampleBudgetSeconds) * time.Second
+}
+
+// GetShareExpiry returns the default validity of a shared download URL.
+func (c *Config) GetShareExpiry() time.Duration {
+	return time.Duration(c.ShareExpiryMinutes) * time.Minute
+}
+
 // GetRespawnProtectionDuration returns the respawn protection as a Duration.
 func (c *Config) GetRespawnProtectionDuration() time.Duration {
+	if interval := c.GetBackupInterval(); interval > 0 {
+		return interval
+	}
 	return time.Duration(c.RespawnProtectionHours) * time.Hour
 }
 
+// GetBackupInterval returns BACKUP_INTERVAL in daemon mode, or 0 when backups
+// follow a cron schedule or run once.
+func (c *Config) GetBackupInterval() time.Duration {
+	if c.Mode != ModeDaemon {
+		return 0
+	}
+	interval, err := time.ParseDuration(c.BackupInterval)
+	if err != nil {
+		return 0
+	}
+	return interval
+}
+
+// GetBackupJitter returns the range BACKUP_JITTER delays runs by, or zeros
+// when it is unset or invalid.
+func (c *Config) GetBackupJitter() (lo, hi time.Duration) {
+	lo, hi, err := parseJitter(c.BackupJitter)
+	if err != nil {
+		return 0, 0
+	}
+	return lo, hi
+}
+
+// parseJitter parses a jitter range such as "0-15m" or "30s-2m"; a single
+// duration such as "15m" ranges from zero.
+func parseJitter(spec string) (lo, hi time.Duration, err error) {
+	if spec == "" {
+		return 0, 0, nil
+	}
+	loSpec, hiSpec, isRange := strings.Cut(spec, "-")
+	if !isRange {
+		loSpec, hiSpec = "0", spec
+	}
+	if lo, err = time.ParseDuration(strings.TrimSpace(loSpec)); err != nil {
+		return 0, 0, err
+	}
+	if hi, err = time.ParseDuration(strings.TrimSpace(hiSpec)); err != nil {
+		return 0, 0, err
+	}
+	if lo < 0 || hi < lo {
+		return 0, 0, fmt.Errorf("range %q must run from a non-negative duration to a longer one", spec)
+	}
+	return lo, hi, nil
+}
+
+// GetVerifyInterval returns how often verify mode checks backups.
+func (c *Config) GetVerifyInterval() time.Duration {
+	return time.Duration(c.VerifyIntervalMinutes) * time.Minute
+}
+
+// GetMaxBackupAge returns the newest backup age above which verify mode
+// alerts, or 0 when the check is disabled.
+func (c *Config) GetMaxBackupAge() time.Duration {
+	return time.Duration(c.MaxBackupAgeHours) * time.Hour
+}
+
+// GetDumpStallTimeout returns the dump stall timeout as a Duration.
+func (c *Config) GetDumpStallTimeout() time.Duration {
+	return time.Duration(c.DumpStallTimeoutMinutes) * time.Minute
+}
+
+// GetKeyTemplate returns the template used to build storage keys.
+func (c *Config) GetKeyTemplate() (*utils.KeyTemplate, error) {
+	if c.StorageKeyTemplate == "" {
+		return utils.NewKeyTemplate(utils.DefaultKeyTemplate)
+	}
+	return utils.NewKeyTemplate(c.StorageKeyTemplate)
+}
+
+// GetCompressor returns the configured compression codec, defaulting to gzip.
+func (c *Config) GetCompressor() (compression.Compressor, error) {
+	name := c.Compression
+	if name == "" {
+		name = "gzip"
+	}
+	return compression.Get(name, c.CompressionLevel)
+}
+
+// S3 multipart part size limits in MiB.
+const (
+	minS3PartSizeMB = 5
+	maxS3PartSizeMB = 5 * 1024
+)
+
+// S3 object tagging limits.
+const (
+	maxBackupTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// GetBackupTags parses BACKUP_TAGS ("key=value,key2=value2") into a map.
+func (c *Config) GetBackupTags() (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(c.BackupTags, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag %q must be in key=value form", pair)
+		}
+		if _, exists := tags[key]; exists {
+			return nil, fmt.Errorf("duplicate tag key %q", key)
+		}
+		if len(key) > maxTagKeyLength {
+			return nil, fmt.Errorf("tag key %q exceeds %d characters", key, maxTagKeyLength)
+		}
+		if len(value) > maxTagValueLength {
+			return nil, fmt.Errorf("tag value for %q exceeds %d characters", key, maxTagValueLength)
+		}
+		tags[key] = value
+	}
+
+	if len(tags) > maxBackupTags {
+		return nil, fmt.Errorf("at most %d tags are allowed, got %d", maxBackupTags, len(tags))
+	}
+	return tags, nil
+}
+
+// GetProcessLimits returns the priority applied to child processes.
+func (c *Config) GetProcessLimits() utils.ProcessLimits {
+	return utils.ProcessLimits{
+		Nice:    c.ChildNice,
+		IOClass: c.ChildIOClass,
+		IOLevel: c.ChildIOLevel,
+	}
+}
+
+// GetCatalogScanInterval returns the catalog scan interval as a Duration.
+func (c *Config) GetCatalogScanInterval() time.Duration {
+	return time.Duration(c.CatalogScanIntervalMinutes) * time.Minute
+}
+
+// GetFleetDiscoveryInterval returns the fleet discovery interval as a Duration.
+func (c *Config) GetFleetDiscoveryInterval() time.Duration {
+	return time.Duration(c.FleetDiscoveryIntervalMinutes) * time.Minute
+}
+
+// FaultInjection reports whether storage operations are failed on purpose.
+func (c *Config) FaultInjection() bool {
+	return c.FaultReadPercent > 0 || c.FaultUploadPercent > 0 || c.FaultDeletePercent > 0
+}
+
+// getEnvString gets a string from environment variable with a default value.
+func getEnvString(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 // getEnvInt gets an integer from environment variable with a default value.
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
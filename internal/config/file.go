@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// FileConfig holds settings that are too structured for environment variables.
+// It is read from the JSON file named by CONFIG_FILE.
+type FileConfig struct {
+	// TableFilters restrict the rows backed up for individual tables
+	TableFilters []TableFilter `json:"table_filters"`
+
+	// DumpFilter selects the objects included in the dump
+	DumpFilter *DumpFilter `json:"dump_filter"`
+
+	// Preconditions are database health checks evaluated before each dump
+	Preconditions []Precondition `json:"preconditions"`
+}
+
+// Precondition actions.
+const (
+	PreconditionWarn = "warn" // Log a warning and back up anyway
+	PreconditionSkip = "skip" // Skip this run
+)
+
+// PreconditionChecks are the built-in checks available by name.
+var PreconditionChecks = []string{
+	"replication_lag_seconds",  // How far a replica is behind its primary (0 on a primary)
+	"long_transaction_seconds", // Age of the oldest open transaction
+	"dead_tuple_ratio",         // Dead tuples as a fraction of all tuples in user tables
+}
+
+// Precondition compares a scalar SQL value against a threshold before a dump.
+type Precondition struct {
+	Name   string  `json:"name"`   // Label used in logs; defaults to the check name
+	Check  string  `json:"check"`  // One of PreconditionChecks
+	Query  string  `json:"query"`  // Custom query returning a single number, instead of Check
+	Max    float64 `json:"max"`    // The precondition fails when the value exceeds this
+	Action string  `json:"action"` // "warn" (default) or "skip"
+}
+
+// Label returns the name shown in logs and metrics.
+func (p Precondition) Label() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Check
+}
+
+// GetAction returns the action taken when the precondition fails.
+func (p Precondition) GetAction() string {
+	if p.Action == "" {
+		return PreconditionWarn
+	}
+	return p.Action
+}
+
+// DumpFilter selects the objects pg_dump includes or excludes. Entries use
+// pg_dump's pattern syntax (e.g. "public.*") and are written to a --filter
+// file, which requires pg_dump 17 or newer.
+type DumpFilter struct {
+	IncludeSchemas     []string `json:"include_schemas"`
+	ExcludeSchemas     []string `json:"exclude_schemas"`
+	IncludeTables      []string `json:"include_tables"`
+	ExcludeTables      []string `json:"exclude_tables"`
+	ExcludeTableData   []string `json:"exclude_table_data"` // Keep the table definition, skip its rows
+	IncludeExtensions  []string `json:"include_extensions"`
+	ExcludeExtensions  []string `json:"exclude_extensions"`
+	IncludeForeignData []string `json:"include_foreign_data"` // Foreign servers whose table data is dumped
+}
+
+// IsEmpty reports whether the filter selects nothing, leaving pg_dump's defaults.
+func (f *DumpFilter) IsEmpty() bool {
+	return f == nil || len(f.IncludeSchemas)+len(f.ExcludeSchemas)+len(f.IncludeTables)+len(f.ExcludeTables)+
+		len(f.ExcludeTableData)+len(f.IncludeExtensions)+len(f.ExcludeExtensions)+len(f.IncludeForeignData) == 0
+}
+
+// Validate checks that every pattern fits on one filter line and that no
+// object is both included and excluded.
+func (f *DumpFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+
+	lists := []struct {
+		field    string
+		patterns []string
+	}{
+		{"include_schemas", f.IncludeSchemas},
+		{"exclude_schemas", f.ExcludeSchemas},
+		{"include_tables", f.IncludeTables},
+		{"exclude_tables", f.ExcludeTables},
+		{"exclude_table_data", f.ExcludeTableData},
+		{"include_extensions", f.IncludeExtensions},
+		{"exclude_extensions", f.ExcludeExtensions},
+		{"include_foreign_data", f.IncludeForeignData},
+	}
+	for _, list := range lists {
+		for i, pattern := range list.patterns {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("dump_filter.%s[%d]: pattern is empty", list.field, i)
+			}
+			if strings.ContainsAny(pattern, "\r\n") {
+				return fmt.Errorf("dump_filter.%s[%d]: pattern must be a single line", list.field, i)
+			}
+		}
+	}
+
+	conflicts := []struct {
+		kind             string
+		include, exclude []string
+	}{
+		{"schema", f.IncludeSchemas, f.ExcludeSchemas},
+		{"table", f.IncludeTables, f.ExcludeTables},
+		{"extension", f.IncludeExtensions, f.ExcludeExtensions},
+	}
+	for _, c := range conflicts {
+		included := make(map[string]bool)
+		for _, pattern := range c.include {
+			included[pattern] = true
+		}
+		for _, pattern := range c.exclude {
+			if included[pattern] {
+				return fmt.Errorf("dump_filter: %s %q is both included and excluded", c.kind, pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// TableFilter backs up only the rows of Table matching the Where condition.
+type TableFilter struct {
+	Table string `json:"table"` // "schema.table" or "table" (public schema); case-sensitive
+	Where string `json:"where"` // SQL condition, e.g. "created_at > now() - interval '30 days'"
+}
+
+// Schema returns the schema part of the table name, defaulting to public.
+func (f TableFilter) Schema() string {
+	if schema, _, ok := strings.Cut(f.Table, "."); ok {
+		return schema
+	}
+	return "public"
+}
+
+// Name returns the table name without its schema.
+func (f TableFilter) Name() string {
+	if _, name, ok := strings.Cut(f.Table, "."); ok {
+		return name
+	}
+	return f.Table
+}
+
+// loadFile reads and parses the config file at path. It is checked against
+// FileSchema first, so unknown keys and mistyped values are reported with
+// their line and column; typos never silently disable a setting.
+func loadFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CONFIG_FILE: %w", err)
+	}
+	if err := validateFileData(path, data); err != nil {
+		return nil, fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+
+	var fc FileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return nil, fmt.Errorf("failed to parse CONFIG_FILE %s: %w", path, err)
+	}
+
+	return &fc, nil
+}
+
+// ValidateFile checks the config file at path on its own, without the
+// environment, for CI and editors.
+func ValidateFile(path string) error {
+	fc, err := loadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := validateTableFilters(fc.TableFilters); err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+	if err := fc.DumpFilter.Validate(); err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+	if err := validatePreconditions(fc.Preconditions); err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
+	return nil
+}
+
+// validatePreconditions checks that every precondition has one valid source and action.
+func validatePreconditions(preconditions []Precondition) error {
+	seen := make(map[string]bool)
+	for i, p := range preconditions {
+		switch {
+		case p.Check != "" && p.Query != "":
+			return fmt.Errorf("preconditions[%d]: set either check or query, not both", i)
+		case p.Check != "":
+			if !slices.Contains(PreconditionChecks, p.Check) {
+				return fmt.Errorf("preconditions[%d]: unknown check %q (must be one of %s)", i, p.Check, strings.Join(PreconditionChecks, ", "))
+			}
+		case strings.TrimSpace(p.Query) != "":
+			if p.Name == "" {
+				return fmt.Errorf("preconditions[%d]: name is required for a custom query", i)
+			}
+			// A precondition is a single read-only query, not a script
+			if strings.Contains(p.Query, ";") {
+				return fmt.Errorf("preconditions[%d]: query must not contain ';'", i)
+			}
+		default:
+			return fmt.Errorf("preconditions[%d]: check or query is required", i)
+		}
+
+		if action := p.GetAction(); action != PreconditionWarn && action != PreconditionSkip {
+			return fmt.Errorf("preconditions[%d]: invalid action %q (must be '%s' or '%s')", i, p.Action, PreconditionWarn, PreconditionSkip)
+		}
+
+		if seen[p.Label()] {
+			return fmt.Errorf("preconditions[%d]: duplicate name %s", i, p.Label())
+		}
+		seen[p.Label()] = true
+	}
+	return nil
+}
+
+// validateTableFilters checks that every filter names a distinct table and a condition.
+func validateTableFilters(filters []TableFilter) error {
+	seen := make(map[string]bool)
+	for i, f := range filters {
+		if f.Schema() == "" || f.Name() == "" {
+			return fmt.Errorf("table_filters[%d]: table must be \"table\" or \"schema.table\"", i)
+		}
+		if strings.TrimSpace(f.Where) == "" {
+			return fmt.Errorf("table_filters[%d]: where is required for %s", i, f.Table)
+		}
+
+		key := f.Schema() + "." + f.Name()
+		if seen[key] {
+			return fmt.Errorf("table_filters[%d]: duplicate filter for %s", i, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantFilters int
+		wantErr     bool
+	}{
+		{
+			name:        "table filters",
+			content:     `{"table_filters": [{"table": "public.events", "where": "created_at > now() - interval '30 days'"}]}`,
+			wantFilters: 1,
+		},
+		{
+			name:    "empty object",
+			content: `{}`,
+		},
+		{
+			name:    "dump filter",
+			content: `{"dump_filter": {"include_schemas": ["public"], "exclude_table_data": ["public.events"]}}`,
+		},
+		{
+			name:    "unknown key",
+			content: `{"table_filter": []}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			content: `{"table_filters": [`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			fc, err := loadFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(fc.TableFilters) != tt.wantFilters {
+				t.Errorf("loadFile() filters = %d, want %d", len(fc.TableFilters), tt.wantFilters)
+			}
+		})
+	}
+
+	if _, err := loadFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loadFile() of missing file expected error, got nil")
+	}
+}
+
+func TestTableFilter_SchemaAndName(t *testing.T) {
+	tests := []struct {
+		table      string
+		wantSchema string
+		wantName   string
+	}{
+		{table: "events", wantSchema: "public", wantName: "events"},
+		{table: "audit.events", wantSchema: "audit", wantName: "events"},
+		{table: "audit.Events.v2", wantSchema: "audit", wantName: "Events.v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			f := TableFilter{Table: tt.table}
+			if f.Schema() != tt.wantSchema || f.Name() != tt.wantName {
+				t.Errorf("Schema(), Name() = %v, %v, want %v, %v", f.Schema(), f.Name(), tt.wantSchema, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestValidateTableFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []TableFilter
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			filters: []TableFilter{{Table: "events", Where: "id > 10"}, {Table: "audit.log", Where: "true"}},
+		},
+		{
+			name:    "missing where",
+			filters: []TableFilter{{Table: "events"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing table",
+			filters: []TableFilter{{Table: ".events", Where: "id > 10"}},
+			wantErr: true,
+		},
+		{
+			// Conditions are checked against the database with EXPLAIN before each dump
+			name:    "semicolon in a literal",
+			filters: []TableFilter{{Table: "events", Where: "note <> ';'"}},
+		},
+		{
+			name:    "duplicate with implicit schema",
+			filters: []TableFilter{{Table: "events", Where: "true"}, {Table: "public.events", Where: "false"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTableFilters(tt.filters)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTableFilters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDumpFilter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *DumpFilter
+		wantErr bool
+	}{
+		{name: "nil filter", filter: nil},
+		{
+			name: "valid filter",
+			filter: &DumpFilter{
+				IncludeSchemas:   []string{"public", "app"},
+				ExcludeTables:    []string{"public.audit_*"},
+				ExcludeTableData: []string{"public.events"},
+			},
+		},
+		{
+			name:    "empty pattern",
+			filter:  &DumpFilter{ExcludeTables: []string{" "}},
+			wantErr: true,
+		},
+		{
+			name:    "multi-line pattern",
+			filter:  &DumpFilter{IncludeTables: []string{"public.a\ninclude table secret"}},
+			wantErr: true,
+		},
+		{
+			name:    "schema included and excluded",
+			filter:  &DumpFilter{IncludeSchemas: []string{"app"}, ExcludeSchemas: []string{"app"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDumpFilter_IsEmpty(t *testing.T) {
+	var nilFilter *DumpFilter
+	if !nilFilter.IsEmpty() {
+		t.Error("nil filter should be empty")
+	}
+	if !(&DumpFilter{}).IsEmpty() {
+		t.Error("zero filter should be empty")
+	}
+	if (&DumpFilter{ExcludeExtensions: []string{"postgis"}}).IsEmpty() {
+		t.Error("filter with an exclusion should not be empty")
+	}
+}
+
+func TestValidatePreconditions(t *testing.T) {
+	tests := []struct {
+		name          string
+		preconditions []Precondition
+		wantErr       bool
+	}{
+		{
+			name: "built-in checks",
+			preconditions: []Precondition{
+				{Check: "replication_lag_seconds", Max: 300, Action: "skip"},
+				{Check: "long_transaction_seconds", Max: 3600},
+			},
+		},
+		{
+			name:          "custom query",
+			preconditions: []Precondition{{Name: "queue depth", Query: "SELECT count(*) FROM jobs", Max: 1000}},
+		},
+		{
+			name:          "unknown check",
+			preconditions: []Precondition{{Check: "table_bloat"}},
+			wantErr:       true,
+		},
+		{
+			name:          "check and query",
+			preconditions: []Precondition{{Name: "x", Check: "dead_tuple_ratio", Query: "SELECT 1"}},
+			wantErr:       true,
+		},
+		{
+			name:          "custom query without name",
+			preconditions: []Precondition{{Query: "SELECT 1"}},
+			wantErr:       true,
+		},
+		{
+			name:          "multiple statements",
+			preconditions: []Precondition{{Name: "x", Query: "SELECT 1; DROP TABLE jobs"}},
+			wantErr:       true,
+		},
+		{
+			name:          "invalid action",
+			preconditions: []Precondition{{Check: "dead_tuple_ratio", Action: "fail"}},
+			wantErr:       true,
+		},
+		{
+			name: "duplicate name",
+			preconditions: []Precondition{
+				{Check: "dead_tuple_ratio", Max: 0.2},
+				{Check: "dead_tuple_ratio", Max: 0.5, Action: "skip"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePreconditions(tt.preconditions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePreconditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
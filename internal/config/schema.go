@@ -0,0 +1,455 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/compression"
+	"github.com/imedwei/railway-postgres-backup/internal/notify"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Schema is the subset of JSON Schema used to describe CONFIG_FILE and the
+// environment. It is printed by the "config schema" command and enforced when
+// the config file is loaded.
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// EnvVar describes an environment variable read by Load.
+type EnvVar struct {
+	Name        string
+	Type        string // "string", "integer" or "boolean"
+	Default     any    // nil when unset by default
+	Description string
+	Enum        []string
+}
+
+// EnvVars lists the environment variables read by Load. Per-destination
+// retention (RETENTION_DAYS_<PROVIDER>) is matched by pattern instead.
+var EnvVars = []EnvVar{
+	{Name: "MODE", Type: "string", Default: ModeBackup, Description: "backup runs one backup; serve exports catalog metrics; fleet backs up every Postgres service in the project; daemon backs up on BACKUP_SCHEDULE or BACKUP_INTERVAL; verify checks backups written elsewhere without writing to storage", Enum: []string{ModeBackup, ModeServe, ModeFleet, ModeDaemon, ModeVerify}},
+	{Name: "DATABASE_URL", Type: "string", Description: "PostgreSQL connection string"},
+	{Name: "STORAGE_PROVIDER", Type: "string", Description: "s3, r2, spaces, wasabi, gcs, filesystem or rclone; several separated by commas"},
+	{Name: "RAILWAY_API_TOKEN", Type: "string", Description: "Railway API token for fleet mode"},
+	{Name: "RAILWAY_PROJECT_ID", Type: "string", Description: "Railway project backed up in fleet mode"},
+	{Name: "RAILWAY_ENVIRONMENT_ID", Type: "string", Description: "Railway environment backed up in fleet mode"},
+	{Name: "RAILWAY_ENVIRONMENT_NAME", Type: "string", Description: "Railway environment name; fault injection is refused in \"production\""},
+	{Name: "AWS_ACCESS_KEY_ID", Type: "string", Description: "S3 access key; the default credential chain is used when unset"},
+	{Name: "AWS_SECRET_ACCESS_KEY", Type: "string", Description: "S3 secret key"},
+	{Name: "AWS_DELETE_ACCESS_KEY_ID", Type: "string", Description: "S3 access key used only for deletes, so AWS_ACCESS_KEY_ID can be write-only"},
+	{Name: "AWS_DELETE_SECRET_ACCESS_KEY", Type: "string", Description: "S3 secret key used only for deletes"},
+	{Name: "S3_BUCKET", Type: "string", Description: "S3 bucket name"},
+	{Name: "S3_REGION", Type: "string", Description: "S3 region"},
+	{Name: "S3_ENDPOINT", Type: "string", Description: "Custom S3-compatible endpoint"},
+	{Name: "R2_ACCOUNT_ID", Type: "string", Description: "Cloudflare account ID for the r2 provider"},
+	{Name: "GCS_BUCKET", Type: "string", Description: "GCS bucket name"},
+	{Name: "GOOGLE_PROJECT_ID", Type: "string", Description: "Google Cloud project ID"},
+	{Name: "GOOGLE_SERVICE_ACCOUNT_JSON", Type: "string", Description: "Service account key JSON; Application Default Credentials are used when unset"},
+	{Name: "GOOGLE_DELETE_SERVICE_ACCOUNT_JSON", Type: "string", Description: "Service account key JSON used only for deletes, so GOOGLE_SERVICE_ACCOUNT_JSON can be write-only"},
+	{Name: "GCS_LOCATION", Type: "string", Description: "Location of a bucket created by CREATE_BUCKET_IF_MISSING"},
+	{Name: "FILESYSTEM_PATH", Type: "string", Description: "Directory for the filesystem provider"},
+	{Name: "LOCAL_CACHE_PATH", Type: "string", Description: "Directory mirroring the most recent backups"},
+	{Name: "LOCAL_CACHE_KEEP", Type: "integer", Default: 3, Description: "Backups kept in the local cache"},
+	{Name: "LOCAL_CACHE_MAX_SIZE_MB", Type: "integer", Default: 0, Description: "Total size of the local cache (0 for no limit)"},
+	{Name: "RCLONE_REMOTE", Type: "string", Description: "rclone remote and path, e.g. b2:bucket/backups"},
+	{Name: "RCLONE_BINARY", Type: "string", Description: "Path of the rclone binary"},
+	{Name: "BACKUP_FILE_PREFIX", Type: "string", Description: "Prefix of backup filenames"},
+	{Name: "PG_DUMP_OPTIONS", Type: "string", Description: "Additional pg_dump options"},
+	{Name: "STORAGE_KEY_TEMPLATE", Type: "string", Description: "Go template for storage keys"},
+	{Name: "BACKUP_TMPDIR", Type: "string", Description: "Directory for spooling backup data to disk"},
+	{Name: "LOG_FORMAT", Type: "string", Default: "text", Description: "Format of log lines; json writes one JSON object per line for log drains", Enum: []string{"text", "json"}},
+	{Name: "LOG_ATTRS", Type: "string", Description: "Static key=value attributes added to every log line, e.g. service=backup,env=prod"},
+	{Name: "LOG_RETRY_EVERY", Type: "integer", Default: 1, Description: "Log the first and every Nth attempt of a retry loop only (1 logs every attempt)"},
+	{Name: "LOG_LEVEL", Type: "string", Default: "info", Description: "Minimum level logged; debug also logs every pg_dump, psql and pg_restore command line", Enum: []string{"debug", "info", "warn", "error"}},
+	{Name: "COMPRESSION", Type: "string", Description: "Compression codec (gzip unless pg_dump compresses)", Enum: compression.Names()},
+	{Name: "COMPRESSION_LEVEL", Type: "integer", Default: compression.DefaultLevel, Description: "Codec level; 0 uses the codec default"},
+	{Name: "BACKUP_TAGS", Type: "string", Description: "S3 object tags as key=value pairs separated by commas"},
+	{Name: "CONFIG_FILE", Type: "string", Description: "JSON file with structured settings, described by this schema"},
+	{Name: "RESPAWN_PROTECTION_HOURS", Type: "integer", Default: 6, Description: "Minimum hours between backups"},
+	{Name: "FORCE_BACKUP", Type: "boolean", Default: false, Description: "Skip respawn protection"},
+	{Name: "CLOCK_SKEW_WARN_SECONDS", Type: "integer", Default: 60, Description: "Warn when the local clock differs from the storage provider's by more than this (0 disables)"},
+	{Name: "BACKUP_PAUSED", Type: "boolean", Default: false, Description: "Skip every run, for maintenance windows"},
+	{Name: "BACKUP_KEEP", Type: "boolean", Default: false, Description: "Pin the backup so retention never deletes it"},
+	{Name: "BACKUP_LABEL", Type: "string", Description: "Label stored in the metadata, catalog entry and history record of each backup"},
+	{Name: "DRY_RUN", Type: "boolean", Default: false, Description: "Report what a run would do without dumping, uploading or deleting"},
+	{Name: "RETENTION_DAYS", Type: "integer", Default: 0, Description: "Days to keep old backups (0 disables retention)"},
+	{Name: "RETENTION_DRY_RUN", Type: "boolean", Default: false, Description: "Log and report what retention would delete instead of deleting"},
+	{Name: "RETENTION_MIN_KEEP", Type: "integer", Default: 1, Description: "Backups retention never deletes below in each destination, however old"},
+	{Name: "QUOTA_EMERGENCY_RETENTION_DAYS", Type: "integer", Default: 0, Description: "When an upload fails because storage is full, prune backups older than this many days (0 disables)"},
+	{Name: "RUN_LOCK", Type: "boolean", Default: false, Description: "Hold a lock object in storage during each run"},
+	{Name: "RUN_LOCK_TTL_SECONDS", Type: "integer", Default: 300, Description: "How long the run lock lasts without renewal"},
+	{Name: "BACKUP_SCHEDULE", Type: "string", Description: "Cron expression daemon mode backs up on, e.g. 0 3 * * *"},
+	{Name: "BACKUP_INTERVAL", Type: "string", Description: "Interval daemon mode backs up at, e.g. 6h; also the respawn protection interval"},
+	{Name: "BACKUP_JITTER", Type: "string", Description: "Random delay range before each run's dump, e.g. 0-15m"},
+	{Name: "CATALOG_SCAN_INTERVAL_MINUTES", Type: "integer", Default: 15, Description: "How often serve mode rescans storage"},
+	{Name: "VERIFY_INTERVAL_MINUTES", Type: "integer", Default: 60, Description: "How often verify mode checks the newest backup"},
+	{Name: "MAX_BACKUP_AGE_HOURS", Type: "integer", Default: 26, Description: "Newest backup age above which verify mode alerts (0 disables)"},
+	{Name: "FLEET_BACKUP_INTERVAL_HOURS", Type: "integer", Default: 24, Description: "Hours between backups of each fleet service"},
+	{Name: "FLEET_DISCOVERY_INTERVAL_MINUTES", Type: "integer", Default: 15, Description: "How often fleet mode discovers services"},
+	{Name: "FLEET_CONCURRENCY", Type: "integer", Default: 1, Description: "Fleet backups run at the same time"},
+	{Name: "DUMP_STALL_TIMEOUT_MINUTES", Type: "integer", Default: 10, Description: "Abort pg_dump after this long without output (0 disables)"},
+	{Name: "DUMP_LOCK_DIAGNOSTICS", Type: "boolean", Default: true, Description: "Log sessions holding blocking locks when a dump stalls or fails"},
+	{Name: "SCHEMA_DEDUP", Type: "boolean", Default: false, Description: "Dump data only and store the schema apart, uploading it only when it changed"},
+	{Name: "DUMP_FALLBACK_EXPORT", Type: "boolean", Default: false, Description: "Export with the built-in SQL exporter when no pg_dump binary can be run"},
+	{Name: "SETTINGS_SNAPSHOT", Type: "boolean", Default: false, Description: "Store non-default pg_settings with each backup and report drift"},
+	{Name: "METRICS_HISTORY", Type: "boolean", Default: false, Description: "Append a record of each run to metrics/history.jsonl"},
+	{Name: "BACKUP_CATALOG", Type: "boolean", Default: false, Description: "Index every backup in catalog.json and find the latest backup from it"},
+	{Name: "VERIFY_AFTER_UPLOAD", Type: "boolean", Default: false, Description: "Re-download each backup and compare its checksum"},
+	{Name: "UPLOAD_INTEGRITY_CHECK", Type: "boolean", Default: true, Description: "Compare the ETag or CRC32C returned for each upload with the data sent"},
+	{Name: "VERIFY_RESTORE", Type: "boolean", Default: false, Description: "Restore each backup into a scratch database"},
+	{Name: "VERIFY_DATABASE_URL", Type: "string", Description: "Scratch database for VERIFY_RESTORE"},
+	{Name: "VERIFY_SAMPLE", Type: "boolean", Default: false, Description: "Check the structure of each backup within a time budget"},
+	{Name: "VERIFY_SAMPLE_BUDGET_SECONDS", Type: "integer", Default: 300, Description: "Time allowed for the sampled check (0 for no limit)"},
+	{Name: "VERIFY_SAMPLE_HEAD_MB", Type: "integer", Default: 64, Description: "Every tar member in the first this many MB is checked"},
+	{Name: "VERIFY_SAMPLE_PERCENT", Type: "integer", Default: 10, Description: "Share of the later tar members checked"},
+	{Name: "HOOK_COMMAND", Type: "string", Description: "Shell command receiving run events as JSON on stdin"},
+	{Name: "HOOK_URL", Type: "string", Description: "HTTP endpoint receiving run events as JSON"},
+	{Name: "HOOK_EVENTS", Type: "string", Description: "Events delivered to hooks, separated by commas (all when unset)"},
+	{Name: "ON_FAILURE_COMMAND", Type: "string", Description: "Shell command run when a run fails, with the failed phase, error and attempts"},
+	{Name: "ON_FAILURE_URL", Type: "string", Description: "HTTP endpoint receiving failure events as JSON"},
+	{Name: "HOOK_TIMEOUT_SECONDS", Type: "integer", Default: 30, Description: "Timeout of each hook delivery"},
+	{Name: "NOTIFY_WEBHOOK_URL", Type: "string", Description: "HTTP endpoint receiving a JSON summary of every run"},
+	{Name: "DISCORD_WEBHOOK_URL", Type: "string", Description: "Discord webhook receiving the run summary as an embed"},
+	{Name: "TELEGRAM_BOT_TOKEN", Type: "string", Description: "Telegram bot token sending the run summary as a message"},
+	{Name: "TELEGRAM_CHAT_ID", Type: "string", Description: "Telegram chat the bot sends run summaries to"},
+	{Name: "NOTIFY_ON", Type: "string", Default: notify.OnAlways, Description: "Notify after every run, or only after failed runs", Enum: []string{notify.OnAlways, notify.OnFailure}},
+	{Name: "HEALTHCHECK_URL", Type: "string", Description: "Dead man's switch (e.g. healthchecks.io) pinged when each run starts and ends"},
+	{Name: "NOTIFY_TIMEOUT_SECONDS", Type: "integer", Default: 10, Description: "Timeout of each notification request"},
+	{Name: "NOTIFY_MAX_ATTEMPTS", Type: "integer", Default: 3, Description: "Attempts made to deliver each notification"},
+	{Name: "REQUIRE_ALL_DESTINATIONS", Type: "boolean", Default: true, Description: "Fail the run unless every destination receives the backup"},
+	{Name: "CREATE_BUCKET_IF_MISSING", Type: "boolean", Default: false, Description: "Create the S3/GCS bucket on startup"},
+	{Name: "S3_UPLOAD_PART_SIZE_MB", Type: "integer", Default: 0, Description: "S3 multipart part size (0 for the SDK default)"},
+	{Name: "S3_UPLOAD_CONCURRENCY", Type: "integer", Default: 0, Description: "S3 parts uploaded in parallel (0 for the SDK default)"},
+	{Name: "S3_MULTIPART_THRESHOLD_MB", Type: "integer", Default: 0, Description: "Stream multipart uploads above this size (0 disables)"},
+	{Name: "S3_PROBE_CAPABILITIES", Type: "boolean", Default: false, Description: "Probe the S3 endpoint at startup and turn off unsupported options"},
+	{Name: "RESTORE_JOBS", Type: "integer", Default: 1, Description: "Parallel pg_restore workers"},
+	{Name: "RESTORE_DISABLE_TRIGGERS", Type: "boolean", Default: false, Description: "Skip triggers and foreign key checks while restoring"},
+	{Name: "RESTORE_MAINTENANCE_WORK_MEM", Type: "string", Description: "Session maintenance_work_mem for restores, e.g. 2GB"},
+	{Name: "RESTORE_ANALYZE", Type: "boolean", Default: true, Description: "Run ANALYZE after a restore"},
+	{Name: "RESTORE_LATEST", Type: "boolean", Default: false, Description: "Restore the most recent backup when no key is given"},
+	{Name: "RESTORE_FORCE_REMOTE", Type: "boolean", Default: false, Description: "Read restored and verified backups from remote storage"},
+	{Name: "SHARE_EXPIRY_MINUTES", Type: "integer", Default: 60, Description: "Default validity of a shared URL"},
+	{Name: "SHARE_TOKEN", Type: "string", Description: "Bearer token enabling the /share endpoint"},
+	{Name: "API_TOKEN", Type: "string", Description: "Bearer token enabling the control endpoints such as /pause"},
+	{Name: "FAULT_READ_PERCENT", Type: "integer", Default: 0, Description: "Share of storage reads failed on purpose (non-production only)"},
+	{Name: "FAULT_UPLOAD_PERCENT", Type: "integer", Default: 0, Description: "Share of storage uploads and copies failed on purpose (non-production only)"},
+	{Name: "FAULT_DELETE_PERCENT", Type: "integer", Default: 0, Description: "Share of storage deletes failed on purpose (non-production only)"},
+	{Name: "FAULT_SEED", Type: "integer", Default: 0, Description: "Seed of the fault sequence, to reproduce a run (0 picks one at random)"},
+	{Name: "CHILD_NICE", Type: "integer", Default: 0, Description: "Niceness of pg_dump, pg_restore and psql"},
+	{Name: "CHILD_IONICE_CLASS", Type: "string", Description: "I/O scheduling class of child processes", Enum: []string{utils.IOClassBestEffort, utils.IOClassIdle}},
+	{Name: "CHILD_IONICE_LEVEL", Type: "integer", Default: 4, Description: "I/O priority level within the best-effort class"},
+	{Name: "CHILD_CGROUP_AWARE", Type: "boolean", Default: true, Description: "Size restore workers to the cgroup CPU quota"},
+}
+
+// Patterns accepted for environment values, which are always strings.
+const (
+	integerPattern = `^-?[0-9]+$`
+	booleanPattern = `^(1|t|T|TRUE|true|True|0|f|F|FALSE|false|False)$`
+)
+
+// FileSchema returns the schema of the CONFIG_FILE document.
+func FileSchema() *Schema {
+	patterns := func(description string) *Schema {
+		return &Schema{Type: "array", Description: description, Items: &Schema{Type: "string", MinLength: 1}}
+	}
+	checks := make([]any, len(PreconditionChecks))
+	for i, check := range PreconditionChecks {
+		checks[i] = check
+	}
+
+	return &Schema{
+		Type:                 "object",
+		AdditionalProperties: new(bool),
+		Properties: map[string]*Schema{
+			"table_filters": {
+				Type:        "array",
+				Description: "Back up only the rows of a table matching a condition",
+				Items: &Schema{
+					Type:                 "object",
+					AdditionalProperties: new(bool),
+					Required:             []string{"table", "where"},
+					Properties: map[string]*Schema{
+						"table": {Type: "string", MinLength: 1, Description: `"schema.table" or "table" (public schema)`},
+						"where": {Type: "string", MinLength: 1, Description: "SQL condition selecting the rows to keep"},
+					},
+				},
+			},
+			"dump_filter": {
+				Type:                 "object",
+				Description:          "Objects included in the dump, written to a pg_dump 17 --filter file",
+				AdditionalProperties: new(bool),
+				Properties: map[string]*Schema{
+					"include_schemas":      patterns("Schemas to dump"),
+					"exclude_schemas":      patterns("Schemas to skip"),
+					"include_tables":       patterns("Tables to dump"),
+					"exclude_tables":       patterns("Tables to skip"),
+					"exclude_table_data":   patterns("Tables dumped without their rows"),
+					"include_extensions":   patterns("Extensions to dump"),
+					"exclude_extensions":   patterns("Extensions to skip"),
+					"include_foreign_data": patterns("Foreign servers whose table data is dumped"),
+				},
+			},
+			"preconditions": {
+				Type:        "array",
+				Description: "Database health checks evaluated before each dump",
+				Items: &Schema{
+					Type:                 "object",
+					AdditionalProperties: new(bool),
+					Properties: map[string]*Schema{
+						"name":   {Type: "string", Description: "Label used in logs; defaults to the check name"},
+						"check":  {Type: "string", Enum: checks, Description: "Built-in check"},
+						"query":  {Type: "string", Description: "Custom query returning a single number, instead of check"},
+						"max":    {Type: "number", Description: "The precondition fails when the value exceeds this"},
+						"action": {Type: "string", Enum: []any{PreconditionWarn, PreconditionSkip}, Default: PreconditionWarn},
+					},
+				},
+			},
+		},
+	}
+}
+
+// EnvSchema returns the schema of the environment as an object of strings.
+func EnvSchema() *Schema {
+	props := make(map[string]*Schema, len(EnvVars)+1)
+	for _, v := range EnvVars {
+		s := &Schema{Type: "string", Description: v.Description}
+		switch v.Type {
+		case "integer":
+			s.Pattern = integerPattern
+		case "boolean":
+			s.Pattern = booleanPattern
+		}
+		if v.Default != nil {
+			s.Default = fmt.Sprint(v.Default)
+		}
+		for _, value := range v.Enum {
+			s.Enum = append(s.Enum, value)
+		}
+		props[v.Name] = s
+	}
+	return &Schema{
+		Type:        "object",
+		Description: "Environment variables; RETENTION_DAYS_<PROVIDER> overrides RETENTION_DAYS per destination",
+		Properties:  props,
+	}
+}
+
+// JSONSchema returns the document printed by "config schema": the CONFIG_FILE
+// schema, with the environment mapping under $defs.environment.
+func JSONSchema() *Schema {
+	s := FileSchema()
+	s.SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+	s.ID = "https://github.com/imedwei/railway-postgres-backup/config.schema.json"
+	s.Title = "railway-postgres-backup CONFIG_FILE"
+	s.Defs = map[string]*Schema{"environment": EnvSchema()}
+	return s
+}
+
+// validateFileData checks a CONFIG_FILE document against FileSchema. Every
+// violation is reported with its line and column.
+func validateFileData(name string, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			line, col := position(data, syntax.Offset)
+			return fmt.Errorf("%s:%d:%d: %w", name, line, col, err)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	var violations []violation
+	FileSchema().check(doc, "", &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	offsets := valueOffsets(data)
+	errs := make([]error, len(violations))
+	for i, v := range violations {
+		line, col := position(data, offsets[v.path])
+		path := v.path
+		if path == "" {
+			path = "(root)"
+		}
+		errs[i] = fmt.Errorf("%s:%d:%d: %s: %s", name, line, col, path, v.message)
+	}
+	return errors.Join(errs...)
+}
+
+// violation is a schema check failure at a path such as "preconditions[0].max".
+type violation struct {
+	path    string
+	message string
+}
+
+// check validates value against s, appending failures to violations.
+func (s *Schema) check(value any, path string, violations *[]violation) {
+	fail := func(format string, args ...any) {
+		*violations = append(*violations, violation{path: path, message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("%s is required", name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := joinPath(path, name)
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*violations = append(*violations, violation{path: child, message: fmt.Sprintf("unknown key %q (must be one of %s)", name, strings.Join(s.propertyNames(), ", "))})
+				}
+				continue
+			}
+			prop.check(obj[name], child, violations)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			s.Items.check(item, path+"["+strconv.Itoa(i)+"]", violations)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if len(str) < s.MinLength {
+			fail("must not be empty")
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, any(str)) {
+			fail("%q is not one of %s", str, s.enumList())
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			fail("must be a number")
+		}
+	}
+}
+
+// propertyNames returns the declared properties in sorted order.
+func (s *Schema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Schema) enumList() string {
+	values := make([]string, len(s.Enum))
+	for i, v := range s.Enum {
+		values[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(values, ", ")
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// valueOffsets maps the path of every value in a JSON document to the offset
+// where it starts.
+func valueOffsets(data []byte) map[string]int64 {
+	offsets := make(map[string]int64)
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// start returns the offset of the next token, past separators
+	start := func() int64 {
+		off := dec.InputOffset()
+		for off < int64(len(data)) && strings.ContainsRune(" \t\r\n,:", rune(data[off])) {
+			off++
+		}
+		return off
+	}
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		offsets[path] = start()
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyOffset := start()
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child := joinPath(path, fmt.Sprint(key))
+				if err := walk(child); err != nil {
+					return err
+				}
+				// Members are reported where their key is written
+				offsets[child] = keyOffset
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(path + "[" + strconv.Itoa(i) + "]"); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	// A document that stops parsing keeps the offsets found so far
+	_ = walk("")
+	return offsets
+}
+
+// position converts a byte offset to a 1-based line and column.
+func position(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnvVars_MatchLoad(t *testing.T) {
+	source, err := os.ReadFile("config.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var read []string
+	for _, m := range regexp.MustCompile(`(?:getEnv\w*|os\.Getenv)\("([A-Z0-9_]+)"`).FindAllSubmatch(source, -1) {
+		if name := string(m[1]); !strings.HasSuffix(name, "_") && !slices.Contains(read, name) {
+			read = append(read, name)
+		}
+	}
+
+	var documented []string
+	for _, v := range EnvVars {
+		documented = append(documented, v.Name)
+	}
+	slices.Sort(read)
+	slices.Sort(documented)
+	if !slices.Equal(read, documented) {
+		t.Errorf("EnvVars = %v, want the variables read by Load %v", documented, read)
+	}
+}
+
+func TestFileSchema_MatchesFileConfig(t *testing.T) {
+	var compare func(path string, typ reflect.Type, s *Schema)
+	compare = func(path string, typ reflect.Type, s *Schema) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+			if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Struct {
+				s = s.Items
+			}
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return
+		}
+
+		var fields []string
+		for i := range typ.NumField() {
+			name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			fields = append(fields, name)
+			if prop, ok := s.Properties[name]; ok {
+				compare(path+"."+name, typ.Field(i).Type, prop)
+			}
+		}
+		slices.Sort(fields)
+		if got := s.propertyNames(); !slices.Equal(got, fields) {
+			t.Errorf("schema of %s has %v, want %v", path, got, fields)
+		}
+	}
+	compare("(root)", reflect.TypeOf(FileConfig{}), FileSchema())
+}
+
+func TestLoadFile_SchemaErrors(t *testing.T) {
+	content := `{
+  "preconditions": [
+    {"check": "replication_lag_seconds", "max": "30"},
+    {"check": "long_transaction_seconds", "max": 600, "action": "block"}
+  ],
+  "dump_filters": {}
+}`
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadFile(path)
+	if err == nil {
+		t.Fatal("loadFile() error = nil")
+	}
+	for _, want := range []string{
+		path + `:6:3: dump_filters: unknown key "dump_filters"`,
+		path + `:3:42: preconditions[0].max: must be a number`,
+		path + `:4:55: preconditions[1].action: "block" is not one of "warn", "skip"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("loadFile() error = %v\nwant it to contain %s", err, want)
+		}
+	}
+}
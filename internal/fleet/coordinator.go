@@ -0,0 +1,220 @@
+package fleet
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// retryDelay is how long a service waits after a failed or skipped run.
+const retryDelay = 15 * time.Minute
+
+// BackupFactory creates the backup provider for a service's configuration.
+type BackupFactory func(cfg *config.Config) (backup.Backup, error)
+
+// Coordinator backs up every discovered service on its own schedule. Each
+// service's backups are stored under its prefix in the shared storage.
+type Coordinator struct {
+	config     *config.Config
+	discoverer Discoverer
+	storage    storage.Storage
+	newBackup  BackupFactory
+	logger     *slog.Logger
+
+	slots   chan struct{} // Bounds concurrent backups
+	workers map[string]*worker
+	wg      sync.WaitGroup
+}
+
+// worker runs the schedule of one service.
+type worker struct {
+	service Service
+	cancel  context.CancelFunc
+}
+
+// NewCoordinator creates a fleet coordinator.
+func NewCoordinator(cfg *config.Config, discoverer Discoverer, store storage.Storage, newBackup BackupFactory, logger *slog.Logger) *Coordinator {
+	return &Coordinator{
+		config:     cfg,
+		discoverer: discoverer,
+		storage:    store,
+		newBackup:  newBackup,
+		logger:     logger,
+		slots:      make(chan struct{}, max(cfg.FleetConcurrency, 1)),
+		workers:    make(map[string]*worker),
+	}
+}
+
+// Run discovers services immediately and then on every discovery interval
+// until the context is cancelled, and waits for running backups to stop.
+func (c *Coordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.GetFleetDiscoveryInterval())
+	defer ticker.Stop()
+
+	for {
+		services, err := c.discoverer.Discover(ctx)
+		if err != nil {
+			metrics.FleetDiscoveryErrors.Inc()
+			c.logger.Warn("Service discovery failed, keeping current services", "error", err)
+		} else {
+			c.sync(ctx, services)
+		}
+
+		select {
+		case <-ctx.Done():
+			c.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync starts workers for new services, restarts those whose settings changed
+// and stops those that disappeared.
+func (c *Coordinator) sync(ctx context.Context, services []Service) {
+	metrics.FleetServices.Set(float64(len(services)))
+
+	current := make(map[string]bool)
+	for _, svc := range services {
+		current[svc.ID] = true
+		if w, ok := c.workers[svc.ID]; ok {
+			if w.service == svc {
+				continue
+			}
+			c.logger.Info("Service changed, restarting its schedule", "service", svc.Name)
+			w.cancel()
+		} else {
+			c.logger.Info("Discovered Postgres service", "service", svc.Name, "prefix", svc.Prefix)
+		}
+
+		workerCtx, cancel := context.WithCancel(ctx)
+		c.workers[svc.ID] = &worker{service: svc, cancel: cancel}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.runService(workerCtx, svc)
+		}()
+	}
+
+	for id, w := range c.workers {
+		if !current[id] {
+			c.logger.Info("Service removed, stopping its schedule", "service", w.service.Name)
+			w.cancel()
+			delete(c.workers, id)
+		}
+	}
+}
+
+// serviceConfig returns the configuration used to back up a service.
+func (c *Coordinator) serviceConfig(svc Service) *config.Config {
+	cfg := *c.config
+	cfg.Mode = config.ModeBackup
+	cfg.DatabaseURL = svc.DatabaseURL
+	utils.RegisterSecrets(config.URLPassword(svc.DatabaseURL))
+	cfg.BackupFilePrefix = svc.Prefix
+	cfg.ForceBackup = false
+	cfg.RespawnProtectionHours = c.config.FleetBackupIntervalHours
+	if svc.IntervalHours > 0 {
+		cfg.RespawnProtectionHours = svc.IntervalHours
+	}
+	return &cfg
+}
+
+// runService backs up a service whenever its interval has elapsed since its last backup.
+func (c *Coordinator) runService(ctx context.Context, svc Service) {
+	logger := c.logger.With("service", svc.Name)
+	cfg := c.serviceConfig(svc)
+	store := storage.NewPrefixedStorage(c.storage, svc.Prefix)
+
+	provider, err := c.newBackup(cfg)
+	if err != nil {
+		logger.Error("Failed to create backup provider", "error", err)
+		return
+	}
+	orchestrator := backup.NewOrchestrator(cfg, store, provider, logger)
+	interval := cfg.GetRespawnProtectionDuration()
+
+	for {
+		wait := time.Duration(0)
+		if last, err := storage.LastBackupTime(ctx, store); err != nil {
+			logger.Warn("Failed to get last backup time, backing up now", "error", err)
+		} else if !last.IsZero() {
+			wait = time.Until(last.Add(interval))
+		}
+		if !sleep(ctx, wait) {
+			return
+		}
+
+		if !c.backup(ctx, svc, orchestrator, logger) {
+			if !sleep(ctx, retryDelay) {
+				return
+			}
+		}
+	}
+}
+
+// backup runs one backup of a service, waiting for a free slot first, and
+// reports whether a backup was stored.
+func (c *Coordinator) backup(ctx context.Context, svc Service, orchestrator *backup.Orchestrator, logger *slog.Logger) bool {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	defer func() { <-c.slots }()
+
+	// A pause at the bucket root holds every service; per-service pauses are
+	// read by the orchestrator under the service prefix
+	if pause, err := storage.ReadPause(ctx, c.storage); err != nil {
+		logger.Warn("Failed to read the fleet pause, backing up anyway", "error", err)
+	} else if pause != nil {
+		logger.Info("Backup paused", "reason", pause.Reason, "paused_at", pause.PausedAt)
+		metrics.FleetBackupAttempts.WithLabelValues(svc.Prefix, "paused").Inc()
+		return false
+	}
+
+	result, err := orchestrator.Execute(ctx)
+	switch {
+	case err != nil:
+		if ctx.Err() == nil {
+			logger.Error("Backup failed", "error", err)
+			metrics.FleetBackupAttempts.WithLabelValues(svc.Prefix, "failure").Inc()
+		}
+		return false
+	case result.Paused:
+		logger.Info("Backup paused", "reason", result.Reason)
+		metrics.FleetBackupAttempts.WithLabelValues(svc.Prefix, "paused").Inc()
+		return false
+	case result.Skipped:
+		logger.Info("Backup skipped", "reason", result.Reason)
+		metrics.FleetBackupAttempts.WithLabelValues(svc.Prefix, "skipped").Inc()
+		return false
+	}
+
+	metrics.FleetBackupAttempts.WithLabelValues(svc.Prefix, "success").Inc()
+	metrics.FleetLastBackupTimestamp.WithLabelValues(svc.Prefix).Set(float64(result.Timestamp.Unix()))
+	metrics.FleetBackupSize.WithLabelValues(svc.Prefix).Set(float64(result.Size))
+	return true
+}
+
+// sleep waits for d and reports false if the context was cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,142 @@
+package fleet
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/backup"
+	"github.com/imedwei/railway-postgres-backup/internal/config"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+type staticDiscoverer []Service
+
+func (d staticDiscoverer) Discover(ctx context.Context) ([]Service, error) {
+	return d, nil
+}
+
+// fakeBackup dumps a fixed payload and counts dumps per database.
+type fakeBackup struct {
+	url   string
+	mu    *sync.Mutex
+	dumps map[string]int
+}
+
+func (f *fakeBackup) Dump(ctx context.Context) (io.ReadCloser, error) {
+	f.mu.Lock()
+	f.dumps[f.url]++
+	f.mu.Unlock()
+	return io.NopCloser(strings.NewReader("-- dump of " + f.url)), nil
+}
+
+func (f *fakeBackup) Validate(ctx context.Context, reader io.Reader) error {
+	return nil
+}
+
+func (f *fakeBackup) GetInfo(ctx context.Context) (*backup.DatabaseInfo, error) {
+	return &backup.DatabaseInfo{Name: "railway", Version: "PostgreSQL 16.2"}, nil
+}
+
+func TestCoordinator_BacksUpEachService(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	// users was backed up recently, so only orders is due
+	ctx := context.Background()
+	users := storage.NewPrefixedStorage(store, "users")
+	if err := users.Upload(ctx, "users.tar.gz", strings.NewReader("old"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	cfg := &config.Config{
+		Mode:                          config.ModeFleet,
+		StorageProvider:               "filesystem",
+		FleetBackupIntervalHours:      24,
+		FleetDiscoveryIntervalMinutes: 15,
+		FleetConcurrency:              1,
+	}
+	discoverer := staticDiscoverer{
+		{ID: "1", Name: "Orders", Prefix: "orders", DatabaseURL: "postgres://orders"},
+		{ID: "2", Name: "Users", Prefix: "users", DatabaseURL: "postgres://users"},
+	}
+
+	var mu sync.Mutex
+	dumps := make(map[string]int)
+	coordinator := NewCoordinator(cfg, discoverer, store, func(cfg *config.Config) (backup.Backup, error) {
+		return &fakeBackup{url: cfg.DatabaseURL, mu: &mu, dumps: dumps}, nil
+	}, logger)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		coordinator.Run(runCtx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		objects, err := storage.NewPrefixedStorage(store, "orders").List(ctx, "")
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(objects) > 0 {
+			if !strings.Contains(objects[0].Key, "/orders-pg16-") {
+				t.Errorf("orders backup key = %s, want the service prefix in the filename", objects[0].Key)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the orders backup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dumps["postgres://orders"] != 1 {
+		t.Errorf("orders dumps = %d, want 1", dumps["postgres://orders"])
+	}
+	if dumps["postgres://users"] != 0 {
+		t.Errorf("users dumps = %d, want 0 (backed up within the interval)", dumps["postgres://users"])
+	}
+}
+
+func TestCoordinator_ServiceConfig(t *testing.T) {
+	cfg := &config.Config{
+		Mode:                     config.ModeFleet,
+		DatabaseURL:              "postgres://coordinator",
+		ForceBackup:              true,
+		FleetBackupIntervalHours: 24,
+	}
+	c := NewCoordinator(cfg, staticDiscoverer{}, nil, nil, slog.Default())
+
+	got := c.serviceConfig(Service{Prefix: "orders", DatabaseURL: "postgres://orders", IntervalHours: 6})
+	if got.DatabaseURL != "postgres://orders" || got.BackupFilePrefix != "orders" {
+		t.Errorf("serviceConfig() = %s/%s, want postgres://orders/orders", got.DatabaseURL, got.BackupFilePrefix)
+	}
+	if got.RespawnProtectionHours != 6 {
+		t.Errorf("RespawnProtectionHours = %d, want the service override 6", got.RespawnProtectionHours)
+	}
+	if got.ForceBackup || got.Mode != config.ModeBackup {
+		t.Errorf("serviceConfig() ForceBackup = %v, Mode = %s; want false, backup", got.ForceBackup, got.Mode)
+	}
+
+	got = c.serviceConfig(Service{Prefix: "users"})
+	if got.RespawnProtectionHours != 24 {
+		t.Errorf("RespawnProtectionHours = %d, want the default 24", got.RespawnProtectionHours)
+	}
+	if cfg.DatabaseURL != "postgres://coordinator" {
+		t.Errorf("serviceConfig() modified the coordinator configuration")
+	}
+}
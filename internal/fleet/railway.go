@@ -0,0 +1,243 @@
+// Package fleet backs up every Postgres service in a Railway project from a
+// single coordinator deployment.
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRailwayEndpoint is Railway's public GraphQL API.
+const DefaultRailwayEndpoint = "https://backboard.railway.com/graphql/v2"
+
+// IntervalVariable is the service variable that overrides a service's backup schedule.
+const IntervalVariable = "BACKUP_INTERVAL_HOURS"
+
+// Service is a Postgres service discovered in the Railway project.
+type Service struct {
+	ID            string
+	Name          string
+	Prefix        string // Storage prefix derived from the service name
+	DatabaseURL   string
+	IntervalHours int // 0 uses the coordinator's default schedule
+}
+
+// Discoverer finds the Postgres services to back up.
+type Discoverer interface {
+	Discover(ctx context.Context) ([]Service, error)
+}
+
+// RailwayClient discovers Postgres services through the Railway API.
+type RailwayClient struct {
+	endpoint      string
+	token         string
+	projectID     string
+	environmentID string
+	httpClient    *http.Client
+}
+
+// NewRailwayClient creates a client for the services of one project environment.
+func NewRailwayClient(token, projectID, environmentID string) *RailwayClient {
+	return &RailwayClient{
+		endpoint:      DefaultRailwayEndpoint,
+		token:         token,
+		projectID:     projectID,
+		environmentID: environmentID,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+const projectServicesQuery = `query project($id: String!) {
+  project(id: $id) {
+    services {
+      edges {
+        node {
+          id
+          name
+          serviceInstances {
+            edges { node { environmentId source { image } } }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const serviceVariablesQuery = `query variables($projectId: String!, $environmentId: String!, $serviceId: String) {
+  variables(projectId: $projectId, environmentId: $environmentId, serviceId: $serviceId)
+}`
+
+type projectServicesResponse struct {
+	Project struct {
+		Services struct {
+			Edges []struct {
+				Node railwayService `json:"node"`
+			} `json:"edges"`
+		} `json:"services"`
+	} `json:"project"`
+}
+
+type railwayService struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	ServiceInstances struct {
+		Edges []struct {
+			Node serviceInstance `json:"node"`
+		} `json:"edges"`
+	} `json:"serviceInstances"`
+}
+
+type serviceInstance struct {
+	EnvironmentID string `json:"environmentId"`
+	Source        *struct {
+		Image string `json:"image"`
+	} `json:"source"`
+}
+
+// Discover implements Discoverer. A service is a Postgres service when its
+// deployment in the environment runs a Postgres image and it exposes DATABASE_URL.
+func (c *RailwayClient) Discover(ctx context.Context) ([]Service, error) {
+	var project projectServicesResponse
+	if err := c.query(ctx, projectServicesQuery, map[string]any{"id": c.projectID}, &project); err != nil {
+		return nil, fmt.Errorf("failed to list project services: %w", err)
+	}
+
+	var services []Service
+	for _, edge := range project.Project.Services.Edges {
+		node := edge.Node
+		if !c.runsPostgres(node) {
+			continue
+		}
+
+		var variables struct {
+			Variables map[string]string `json:"variables"`
+		}
+		err := c.query(ctx, serviceVariablesQuery, map[string]any{
+			"projectId":     c.projectID,
+			"environmentId": c.environmentID,
+			"serviceId":     node.ID,
+		}, &variables)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read variables of service %s: %w", node.Name, err)
+		}
+
+		databaseURL := variables.Variables["DATABASE_URL"]
+		if databaseURL == "" {
+			continue
+		}
+
+		service := Service{ID: node.ID, Name: node.Name, DatabaseURL: databaseURL}
+		if hours, err := strconv.Atoi(variables.Variables[IntervalVariable]); err == nil && hours > 0 {
+			service.IntervalHours = hours
+		}
+		services = append(services, service)
+	}
+
+	assignPrefixes(services)
+	return services, nil
+}
+
+// runsPostgres reports whether the service's instance in this environment runs a Postgres image.
+func (c *RailwayClient) runsPostgres(service railwayService) bool {
+	for _, instance := range service.ServiceInstances.Edges {
+		if instance.Node.EnvironmentID != c.environmentID || instance.Node.Source == nil {
+			continue
+		}
+		if strings.Contains(strings.ToLower(instance.Node.Source.Image), "postgres") {
+			return true
+		}
+	}
+	return false
+}
+
+// query sends a GraphQL request and decodes its data into out.
+func (c *RailwayClient) query(ctx context.Context, query string, variables map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("railway API returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid railway API response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			messages[i] = e.Message
+		}
+		return fmt.Errorf("railway API error: %s", strings.Join(messages, "; "))
+	}
+
+	return json.Unmarshal(result.Data, out)
+}
+
+var nonPrefixChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ServicePrefix derives a storage prefix from a service name,
+// e.g. "Postgres Orders" -> "postgres-orders".
+func ServicePrefix(name string) string {
+	return strings.Trim(nonPrefixChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// assignPrefixes sets each service's prefix. Services whose names map to the same
+// prefix are told apart by their IDs, so no two services share a prefix.
+func assignPrefixes(services []Service) {
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+
+	counts := make(map[string]int)
+	for _, s := range services {
+		counts[ServicePrefix(s.Name)]++
+	}
+	for i := range services {
+		prefix := ServicePrefix(services[i].Name)
+		if prefix == "" || counts[prefix] > 1 {
+			prefix = strings.TrimPrefix(prefix+"-"+shortID(services[i].ID), "-")
+		}
+		services[i].Prefix = prefix
+	}
+}
+
+// shortID returns the first block of a service ID.
+func shortID(id string) string {
+	if head, _, ok := strings.Cut(id, "-"); ok {
+		return head
+	}
+	return id
+}
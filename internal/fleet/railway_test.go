@@ -0,0 +1,135 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRailwayClient_Discover(t *testing.T) {
+	variables := map[string]map[string]string{
+		"svc-orders": {"DATABASE_URL": "postgres://orders.railway.internal/railway", IntervalVariable: "6"},
+		"svc-users":  {"DATABASE_URL": "postgres://users.railway.internal/railway", IntervalVariable: "soon"},
+		"svc-nourl":  {},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q, want Bearer token", got)
+		}
+
+		var req struct {
+			Query     string            `json:"query"`
+			Variables map[string]string `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("invalid request: %v", err)
+		}
+
+		if strings.HasPrefix(req.Query, "query project") {
+			_, _ = w.Write([]byte(`{"data":{"project":{"services":{"edges":[
+				{"node":{"id":"svc-orders","name":"Orders DB","serviceInstances":{"edges":[
+					{"node":{"environmentId":"env-prod","source":{"image":"ghcr.io/railwayapp-templates/postgres-ssl:16"}}}]}}},
+				{"node":{"id":"svc-users","name":"Users","serviceInstances":{"edges":[
+					{"node":{"environmentId":"env-prod","source":{"image":"postgres:17"}}}]}}},
+				{"node":{"id":"svc-nourl","name":"Postgres Replica","serviceInstances":{"edges":[
+					{"node":{"environmentId":"env-prod","source":{"image":"postgres:17"}}}]}}},
+				{"node":{"id":"svc-staging","name":"Staging DB","serviceInstances":{"edges":[
+					{"node":{"environmentId":"env-staging","source":{"image":"postgres:17"}}}]}}},
+				{"node":{"id":"svc-api","name":"API","serviceInstances":{"edges":[
+					{"node":{"environmentId":"env-prod","source":null}}]}}}
+			]}}}}`))
+			return
+		}
+
+		if req.Variables["environmentId"] != "env-prod" {
+			t.Errorf("environmentId = %q, want env-prod", req.Variables["environmentId"])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"variables": variables[req.Variables["serviceId"]]},
+		})
+	}))
+	defer server.Close()
+
+	client := NewRailwayClient("token", "project", "env-prod")
+	client.endpoint = server.URL
+
+	services, err := client.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	want := []Service{
+		{ID: "svc-orders", Name: "Orders DB", Prefix: "orders-db", DatabaseURL: "postgres://orders.railway.internal/railway", IntervalHours: 6},
+		{ID: "svc-users", Name: "Users", Prefix: "users", DatabaseURL: "postgres://users.railway.internal/railway"},
+	}
+	if len(services) != len(want) {
+		t.Fatalf("Discover() = %+v, want %+v", services, want)
+	}
+	for i := range want {
+		if services[i] != want[i] {
+			t.Errorf("services[%d] = %+v, want %+v", i, services[i], want[i])
+		}
+	}
+}
+
+func TestRailwayClient_DiscoverError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errors":[{"message":"Not Authorized"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewRailwayClient("bad", "project", "env")
+	client.endpoint = server.URL
+
+	_, err := client.Discover(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Not Authorized") {
+		t.Errorf("Discover() error = %v, want Not Authorized", err)
+	}
+}
+
+func TestServicePrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Postgres", "postgres"},
+		{"Orders DB", "orders-db"},
+		{"  users_db (primary) ", "users-db-primary"},
+		{"Ünïcode", "n-code"},
+		{"---", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServicePrefix(tt.name); got != tt.want {
+				t.Errorf("ServicePrefix(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignPrefixes(t *testing.T) {
+	services := []Service{
+		{ID: "b2c3-1111", Name: "postgres"},
+		{ID: "a1b2-2222", Name: "Postgres"},
+		{ID: "c3d4-3333", Name: "Orders"},
+		{ID: "d4e5-4444", Name: "!!!"},
+	}
+	assignPrefixes(services)
+
+	want := map[string]string{
+		"a1b2-2222": "postgres-a1b2",
+		"b2c3-1111": "postgres-b2c3",
+		"c3d4-3333": "orders",
+		"d4e5-4444": "d4e5",
+	}
+	for _, s := range services {
+		if s.Prefix != want[s.ID] {
+			t.Errorf("prefix of %s = %q, want %q", s.ID, s.Prefix, want[s.ID])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewExec returns a hook that runs command with sh for every event. The event
+// is written to the command's stdin as JSON and its type is in BACKUP_HOOK_EVENT;
+// failures also set BACKUP_HOOK_PHASE, BACKUP_HOOK_ERROR and BACKUP_HOOK_ATTEMPTS.
+func NewExec(command string, timeout time.Duration) Func {
+	return func(ctx context.Context, e Event) error {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		cmd.Env = append(os.Environ(), "BACKUP_HOOK_EVENT="+e.Type)
+		if e.Type == EventFailure {
+			cmd.Env = append(cmd.Env,
+				"BACKUP_HOOK_PHASE="+e.Phase,
+				"BACKUP_HOOK_ERROR="+e.Error,
+				"BACKUP_HOOK_ATTEMPTS="+strconv.Itoa(e.Attempts))
+		}
+		cmd.Stdin = bytes.NewReader(payload)
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("hook command failed: %w, output: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
+
+// NewHTTP returns a hook that POSTs every event as JSON to url. Any status
+// other than 2xx is an error.
+func NewHTTP(url string, timeout time.Duration) Func {
+	client := &http.Client{Timeout: timeout}
+	return func(ctx context.Context, e Event) error {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Backup-Hook-Event", e.Type)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("hook request failed: %w", err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("hook endpoint returned %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "event")
+	hook := NewExec(`printf '%s ' "$BACKUP_HOOK_EVENT" > `+out+` && cat >> `+out, 5*time.Second)
+
+	err := hook(context.Background(), Event{Type: EventUploadComplete, Key: "2024/01/backup.tar.gz", Bytes: 42})
+	if err != nil {
+		t.Fatalf("hook error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook output missing: %v", err)
+	}
+	eventType, payload, _ := strings.Cut(string(data), " ")
+	if eventType != EventUploadComplete {
+		t.Errorf("BACKUP_HOOK_EVENT = %q, want %s", eventType, EventUploadComplete)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		t.Fatalf("stdin is not an event: %v", err)
+	}
+	if e.Key != "2024/01/backup.tar.gz" || e.Bytes != 42 {
+		t.Errorf("event = %+v", e)
+	}
+
+	if err := NewExec("echo broken >&2; exit 3", 5*time.Second)(context.Background(), Event{Type: EventFailure}); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing command error = %v, want its output", err)
+	}
+}
+
+func TestNewExec_Failure(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "failure")
+	hook := NewExec(`printf '%s|%s|%s' "$BACKUP_HOOK_PHASE" "$BACKUP_HOOK_ERROR" "$BACKUP_HOOK_ATTEMPTS" > `+out, 5*time.Second)
+
+	err := hook(context.Background(), Event{Type: EventFailure, Phase: "uploading", Error: "bucket not found", Attempts: 3})
+	if err != nil {
+		t.Fatalf("hook error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook output missing: %v", err)
+	}
+	if want := "uploading|bucket not found|3"; string(data) != want {
+		t.Errorf("failure environment = %q, want %q", data, want)
+	}
+}
+
+func TestNewHTTP(t *testing.T) {
+	var got Event
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Backup-Hook-Event") != EventRetention {
+			t.Errorf("X-Backup-Hook-Event = %q", r.Header.Get("X-Backup-Hook-Event"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	hook := NewHTTP(server.URL, 5*time.Second)
+	if err := hook(context.Background(), Event{Type: EventRetention, Destination: "s3", Deleted: 3}); err != nil {
+		t.Fatalf("hook error = %v", err)
+	}
+	if got.Destination != "s3" || got.Deleted != 3 {
+		t.Errorf("received %+v", got)
+	}
+
+	status = http.StatusInternalServerError
+	if err := hook(context.Background(), Event{Type: EventRetention}); err == nil {
+		t.Error("hook expected error for a 500 response")
+	}
+}
@@ -0,0 +1,132 @@
+// Package hooks exposes backup run events to integrations without changes to
+// the orchestrator.
+package hooks
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Event types.
+const (
+	EventRunStart       = "run_start"       // A backup run passed its checks and is starting
+	EventDumpComplete   = "dump_complete"   // pg_dump finished writing the backup
+	EventUploadComplete = "upload_complete" // The backup is stored
+	EventFailure        = "failure"         // The run failed
+	EventRetention      = "retention"       // Old backups were cleaned up in a destination, or would be on a retention dry run
+)
+
+// Events lists every event type.
+var Events = []string{EventRunStart, EventDumpComplete, EventUploadComplete, EventFailure, EventRetention}
+
+// Event describes a point in a backup run. Fields not relevant to the event are empty.
+type Event struct {
+	Type        string     `json:"event"`
+	Time        time.Time  `json:"time"`
+	Database    string     `json:"database,omitempty"`
+	Key         string     `json:"key,omitempty"`
+	Bytes       int64      `json:"bytes,omitempty"`
+	Seconds     float64    `json:"duration_seconds,omitempty"`
+	Destination string     `json:"destination,omitempty"`
+	Deleted     int        `json:"deleted,omitempty"`
+	Deletions   []Deletion `json:"deletions,omitempty"`
+	DryRun      bool       `json:"dry_run,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Phase       string     `json:"phase,omitempty"`    // Phase a failed run failed in
+	Attempts    int        `json:"attempts,omitempty"` // Consecutive failed runs, this one included
+}
+
+// Deletion is a key retention deleted, or would delete on a dry run, and why.
+type Deletion struct {
+	Key        string    `json:"key"`
+	BackupTime time.Time `json:"backup_time"`
+	Reason     string    `json:"reason"`
+}
+
+// Hook receives the events of a backup run. Errors are logged and never fail
+// the backup. Embed Base to implement only some of the methods.
+type Hook interface {
+	OnRunStart(ctx context.Context, e Event) error
+	OnDumpComplete(ctx context.Context, e Event) error
+	OnUploadComplete(ctx context.Context, e Event) error
+	OnFailure(ctx context.Context, e Event) error
+	OnRetention(ctx context.Context, e Event) error
+}
+
+// Base implements Hook with methods that do nothing.
+type Base struct{}
+
+func (Base) OnRunStart(ctx context.Context, e Event) error       { return nil }
+func (Base) OnDumpComplete(ctx context.Context, e Event) error   { return nil }
+func (Base) OnUploadComplete(ctx context.Context, e Event) error { return nil }
+func (Base) OnFailure(ctx context.Context, e Event) error        { return nil }
+func (Base) OnRetention(ctx context.Context, e Event) error      { return nil }
+
+// Func is a Hook that handles every event with one function, as external hooks do.
+type Func func(ctx context.Context, e Event) error
+
+func (f Func) OnRunStart(ctx context.Context, e Event) error       { return f(ctx, e) }
+func (f Func) OnDumpComplete(ctx context.Context, e Event) error   { return f(ctx, e) }
+func (f Func) OnUploadComplete(ctx context.Context, e Event) error { return f(ctx, e) }
+func (f Func) OnFailure(ctx context.Context, e Event) error        { return f(ctx, e) }
+func (f Func) OnRetention(ctx context.Context, e Event) error      { return f(ctx, e) }
+
+// Only restricts f to the given event types; an empty list keeps every event.
+func Only(f Func, events []string) Func {
+	if len(events) == 0 {
+		return f
+	}
+	wanted := make(map[string]bool, len(events))
+	for _, e := range events {
+		wanted[e] = true
+	}
+	return func(ctx context.Context, e Event) error {
+		if !wanted[e.Type] {
+			return nil
+		}
+		return f(ctx, e)
+	}
+}
+
+// Registry dispatches events to the registered hooks in registration order.
+type Registry struct {
+	hooks  []Hook
+	logger *slog.Logger
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry(logger *slog.Logger) *Registry {
+	return &Registry{logger: logger}
+}
+
+// Register adds a hook.
+func (r *Registry) Register(h Hook) {
+	r.hooks = append(r.hooks, h)
+}
+
+// Fire delivers e to every hook, logging hooks that fail.
+func (r *Registry) Fire(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	for _, h := range r.hooks {
+		var err error
+		switch e.Type {
+		case EventRunStart:
+			err = h.OnRunStart(ctx, e)
+		case EventDumpComplete:
+			err = h.OnDumpComplete(ctx, e)
+		case EventUploadComplete:
+			err = h.OnUploadComplete(ctx, e)
+		case EventFailure:
+			err = h.OnFailure(ctx, e)
+		case EventRetention:
+			err = h.OnRetention(ctx, e)
+		}
+		if err != nil {
+			r.logger.Warn("Hook failed", "event", e.Type, "error", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+// recordingHook records the methods called and implements only some of them.
+type recordingHook struct {
+	Base
+	calls []string
+}
+
+func (h *recordingHook) OnRunStart(ctx context.Context, e Event) error {
+	h.calls = append(h.calls, "OnRunStart:"+e.Type)
+	return nil
+}
+
+func (h *recordingHook) OnFailure(ctx context.Context, e Event) error {
+	h.calls = append(h.calls, "OnFailure:"+e.Error)
+	return errors.New("hook is broken")
+}
+
+func TestRegistry_Fire(t *testing.T) {
+	registry := NewRegistry(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	first := &recordingHook{}
+	var received []Event
+	registry.Register(first)
+	registry.Register(Func(func(ctx context.Context, e Event) error {
+		received = append(received, e)
+		return nil
+	}))
+
+	ctx := context.Background()
+	for _, eventType := range Events {
+		e := Event{Type: eventType}
+		if eventType == EventFailure {
+			e.Error = "dump failed"
+		}
+		registry.Fire(ctx, e)
+	}
+
+	// A failing hook does not stop later hooks
+	if want := []string{"OnRunStart:run_start", "OnFailure:dump failed"}; !slices.Equal(first.calls, want) {
+		t.Errorf("first hook calls = %v, want %v", first.calls, want)
+	}
+	if len(received) != len(Events) {
+		t.Fatalf("func hook received %d events, want %d", len(received), len(Events))
+	}
+	for i, e := range received {
+		if e.Type != Events[i] {
+			t.Errorf("event %d = %s, want %s", i, e.Type, Events[i])
+		}
+		if e.Time.IsZero() {
+			t.Errorf("event %s has no time", e.Type)
+		}
+	}
+}
+
+func TestOnly(t *testing.T) {
+	var received []string
+	f := Func(func(ctx context.Context, e Event) error {
+		received = append(received, e.Type)
+		return nil
+	})
+
+	filtered := Only(f, []string{EventFailure, EventUploadComplete})
+	for _, eventType := range Events {
+		_ = filtered(context.Background(), Event{Type: eventType})
+	}
+	if want := []string{EventUploadComplete, EventFailure}; !slices.Equal(received, want) {
+		t.Errorf("received %v, want %v", received, want)
+	}
+
+	received = nil
+	all := Only(f, nil)
+	for _, eventType := range Events {
+		_ = all(context.Background(), Event{Type: eventType})
+	}
+	if !slices.Equal(received, Events) {
+		t.Errorf("received %v, want every event", received)
+	}
+}
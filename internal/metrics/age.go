@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AgeVec is a gauge of the seconds elapsed since a time recorded per label
+// value. Unlike a gauge set when the time is recorded, the age is computed
+// whenever it is scraped, so it keeps growing between updates.
+type AgeVec struct {
+	desc  *prometheus.Desc
+	mu    sync.RWMutex
+	times map[string]time.Time
+}
+
+// NewAgeVec creates an AgeVec with a single label and registers it with reg,
+// as promauto.With does; a nil reg leaves it unregistered.
+func NewAgeVec(reg prometheus.Registerer, name, help, label string) *AgeVec {
+	a := &AgeVec{
+		desc:  prometheus.NewDesc(name, help, []string{label}, nil),
+		times: make(map[string]time.Time),
+	}
+	if reg != nil {
+		reg.MustRegister(a)
+	}
+	return a
+}
+
+// Set records the time the age of value is measured from.
+func (a *AgeVec) Set(value string, t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.times[value] = t
+}
+
+// Delete drops the series of value.
+func (a *AgeVec) Delete(value string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.times, value)
+}
+
+// Get returns the time recorded for value.
+func (a *AgeVec) Get(value string) (time.Time, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	t, ok := a.times[value]
+	return t, ok
+}
+
+// Describe implements prometheus.Collector.
+func (a *AgeVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.desc
+}
+
+// Collect implements prometheus.Collector.
+func (a *AgeVec) Collect(ch chan<- prometheus.Metric) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	now := time.Now()
+	for value, t := range a.times {
+		ch <- prometheus.MustNewConstMetric(a.desc, prometheus.GaugeValue, now.Sub(t).Seconds(), value)
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAgeVec(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	age := NewAgeVec(reg, "test_age_seconds", "Seconds since a test time", "destination")
+
+	if n := testutil.CollectAndCount(age); n != 0 {
+		t.Fatalf("series before Set = %d, want 0", n)
+	}
+
+	age.Set("s3", time.Now().Add(-time.Hour))
+	age.Set("gcs", time.Now())
+	if n, err := testutil.GatherAndCount(reg, "test_age_seconds"); err != nil || n != 2 {
+		t.Fatalf("GatherAndCount() = %d, %v; want 2 series", n, err)
+	}
+	if problems, err := testutil.CollectAndLint(age); err != nil || len(problems) > 0 {
+		t.Errorf("CollectAndLint() = %v, %v", problems, err)
+	}
+
+	// The age is computed when scraped, so it grows between scrapes
+	age.Delete("gcs")
+	first := testutil.ToFloat64(age)
+	if first < time.Hour.Seconds() {
+		t.Errorf("age = %v, want at least %v", first, time.Hour.Seconds())
+	}
+	time.Sleep(10 * time.Millisecond)
+	if second := testutil.ToFloat64(age); second <= first {
+		t.Errorf("age after a later scrape = %v, want more than %v", second, first)
+	}
+
+	if _, ok := age.Get("gcs"); ok {
+		t.Error("Get() found a deleted series")
+	}
+	age.Delete("s3")
+	if n, err := testutil.GatherAndCount(reg, "test_age_seconds"); err != nil || n != 0 {
+		t.Errorf("GatherAndCount() after Delete = %d, %v; want 0 series", n, err)
+	}
+}
+
+func TestNewAgeVec_Registerer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	NewAgeVec(reg, "test_age_seconds", "Seconds since a test time", "destination")
+
+	// Each registry takes its own collector; the same one twice panics
+	NewAgeVec(prometheus.NewRegistry(), "test_age_seconds", "Seconds since a test time", "destination")
+	NewAgeVec(nil, "test_age_seconds", "Seconds since a test time", "destination")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate collector did not panic")
+		}
+	}()
+	NewAgeVec(reg, "test_age_seconds", "Seconds since a test time", "destination")
+}
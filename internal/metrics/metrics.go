@@ -20,17 +20,17 @@ var (
 		Buckets: prometheus.ExponentialBuckets(1, 2, 10), // 1s to ~17min
 	}, []string{"phase"})
 
-	// BackupSize tracks the size of backups.
-	BackupSize = promauto.NewGauge(prometheus.GaugeOpts{
+	// BackupSize tracks the size of the last backup of each backup prefix.
+	BackupSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "postgres_backup_size_bytes",
 		Help: "Size of the last backup in bytes",
-	})
+	}, []string{"backup_prefix"})
 
-	// DatabaseSize tracks the size of the database.
-	DatabaseSize = promauto.NewGauge(prometheus.GaugeOpts{
+	// DatabaseSize tracks the size of the database behind each backup prefix.
+	DatabaseSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "postgres_database_size_bytes",
 		Help: "Size of the database in bytes",
-	})
+	}, []string{"backup_prefix"})
 
 	// StorageOperations tracks storage operations.
 	StorageOperations = promauto.NewCounterVec(prometheus.CounterOpts{
@@ -38,17 +38,47 @@ var (
 		Help: "Total number of storage operations",
 	}, []string{"operation", "provider", "status"})
 
-	// RateLimitBlocked tracks rate limit blocks.
-	RateLimitBlocked = promauto.NewCounter(prometheus.CounterOpts{
+	// RateLimitBlocked tracks rate limit blocks by their cause.
+	RateLimitBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "postgres_backup_rate_limit_blocked_total",
 		Help: "Total number of backups blocked by rate limiting",
-	})
+	}, []string{"reason"})
+
+	// NextBackupAllowed tracks when the rate limiter next allows a backup of each backup prefix.
+	NextBackupAllowed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_next_allowed_timestamp",
+		Help: "Unix timestamp from which the rate limiter allows the next backup",
+	}, []string{"backup_prefix"})
+
+	// ClockSkew tracks how far the local clock is ahead of the storage provider's for each backup prefix.
+	ClockSkew = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_clock_skew_seconds",
+		Help: "Seconds the local clock is ahead of the storage provider's clock, negative when behind",
+	}, []string{"backup_prefix"})
+
+	// NextScheduledRun tracks when daemon mode next runs a backup of each backup prefix.
+	NextScheduledRun = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_next_scheduled_timestamp",
+		Help: "Unix timestamp of the next scheduled backup in daemon mode",
+	}, []string{"backup_prefix"})
+
+	// PreconditionFailures tracks database health preconditions that did not hold.
+	PreconditionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_precondition_failures_total",
+		Help: "Total number of failed pre-backup database health checks",
+	}, []string{"precondition", "action"})
 
-	// LastBackupTimestamp tracks when the last successful backup occurred.
-	LastBackupTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+	// SettingsDrift tracks server settings changed since the previous backup of each backup prefix.
+	SettingsDrift = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_settings_drift",
+		Help: "Number of server settings changed since the previous backup's snapshot",
+	}, []string{"backup_prefix"})
+
+	// LastBackupTimestamp tracks when the last successful backup of each backup prefix occurred.
+	LastBackupTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "postgres_backup_last_success_timestamp",
 		Help: "Unix timestamp of the last successful backup",
-	})
+	}, []string{"backup_prefix"})
 
 	// BackupsDeleted tracks the number of old backups deleted.
 	BackupsDeleted = promauto.NewCounter(prometheus.CounterOpts{
@@ -56,11 +86,191 @@ var (
 		Help: "Total number of old backups deleted",
 	})
 
-	// Info provides static information about the service.
+	// InterruptedRuns tracks runs found killed midway by the next run.
+	InterruptedRuns = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "postgres_backup_interrupted_runs_total",
+		Help: "Total number of previous runs found interrupted before recording an outcome",
+	})
+
+	// DumpStalls tracks dumps aborted because pg_dump stopped producing output.
+	DumpStalls = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "postgres_backup_dump_stalls_total",
+		Help: "Total number of dumps aborted by the stall watchdog",
+	})
+
+	// CatalogBackups tracks the number of backups held by each destination.
+	CatalogBackups = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_catalog_backups",
+		Help: "Number of backups stored in the destination",
+	}, []string{"destination"})
+
+	// CatalogBytes tracks the total size of stored backups.
+	CatalogBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_catalog_bytes",
+		Help: "Total size of backups stored in the destination in bytes",
+	}, []string{"destination"})
+
+	// CatalogOldestAge tracks the age of the oldest stored backup.
+	CatalogOldestAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_catalog_oldest_age_seconds",
+		Help: "Age of the oldest stored backup in seconds",
+	}, []string{"destination"})
+
+	// CatalogNewestAge tracks the age of the newest stored backup.
+	CatalogNewestAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_catalog_newest_age_seconds",
+		Help: "Age of the newest stored backup in seconds",
+	}, []string{"destination"})
+
+	// RecoveryPoint tracks the seconds since the newest stored backup of each
+	// destination, the data a restore would lose, computed when scraped.
+	RecoveryPoint = NewAgeVec(prometheus.DefaultRegisterer, "postgres_backup_recovery_point_seconds",
+		"Seconds since the last successful backup in the destination, computed when scraped", "destination")
+
+	// CatalogPrefixBackups tracks backup counts per key prefix (year/month directory).
+	CatalogPrefixBackups = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_catalog_prefix_backups",
+		Help: "Number of stored backups per key prefix",
+	}, []string{"destination", "prefix"})
+
+	// CatalogScanErrors tracks failed catalog scans.
+	CatalogScanErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_catalog_scan_errors_total",
+		Help: "Total number of failed catalog scans",
+	}, []string{"destination"})
+
+	// RestoreChecks tracks restores of new backups into a scratch database by status.
+	RestoreChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_restore_checks_total",
+		Help: "Total number of restore verifications into a scratch database",
+	}, []string{"status"})
+
+	// RestoreCheckDuration tracks how long the last restore verification of each backup prefix took.
+	RestoreCheckDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_restore_check_duration_seconds",
+		Help: "Duration of the last restore verification in seconds",
+	}, []string{"backup_prefix"})
+
+	// RestoreCheckRows tracks the rows loaded by the last successful restore verification of each backup prefix.
+	RestoreCheckRows = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_restore_check_rows",
+		Help: "Rows restored by the last successful restore verification",
+	}, []string{"backup_prefix"})
+
+	// LastRestoreCheckTimestamp tracks when a backup of each backup prefix last restored successfully.
+	LastRestoreCheckTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_restore_check_last_success_timestamp",
+		Help: "Unix timestamp of the last successful restore verification",
+	}, []string{"backup_prefix"})
+
+	// SchemaDedup tracks SCHEMA_DEDUP schemas by result (uploaded when changed, reused when a stored copy matched).
+	SchemaDedup = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_schema_dedup_total",
+		Help: "Total number of schema-only dumps by whether they were uploaded or matched a stored copy",
+	}, []string{"result"})
+
+	// RecoveryStageDuration tracks how long each stage (download, decompress, restore) of the last restore verification of each backup prefix took.
+	RecoveryStageDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_recovery_stage_seconds",
+		Help: "Duration of each stage of the last successful restore verification in seconds",
+	}, []string{"backup_prefix", "stage"})
+
+	// RecoveryTime tracks the recovery time measured by the last successful restore verification of each backup prefix.
+	RecoveryTime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_recovery_time_seconds",
+		Help: "Estimated time to recover from the latest backup, measured by the last successful restore verification",
+	}, []string{"backup_prefix"})
+
+	// VerifierChecks tracks the checks verify mode runs by check (freshness, integrity, restore) and status.
+	VerifierChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_verifier_checks_total",
+		Help: "Total number of verify mode checks by check and status",
+	}, []string{"check", "status"})
+
+	// VerifierNewestAge tracks the age of the newest backup verify mode found for each backup prefix.
+	VerifierNewestAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_verifier_newest_age_seconds",
+		Help: "Age of the newest backup found by verify mode in seconds",
+	}, []string{"backup_prefix"})
+
+	// Verifications tracks re-downloads of new backups by status.
+	Verifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_verification_total",
+		Help: "Total number of post-upload verifications by re-download",
+	}, []string{"status"})
+
+	// SampleChecks tracks sampled verifications of new backups by status.
+	SampleChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_sample_checks_total",
+		Help: "Total number of sampled backup verifications",
+	}, []string{"status"})
+
+	// SampleCheckDuration tracks how long the last sampled verification of each backup prefix took.
+	SampleCheckDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_sample_check_duration_seconds",
+		Help: "Duration of the last sampled verification in seconds",
+	}, []string{"backup_prefix"})
+
+	// LocalCacheBackups tracks the backups kept in the local cache.
+	LocalCacheBackups = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "postgres_backup_local_cache_backups",
+		Help: "Number of backups kept in the local cache",
+	})
+
+	// LocalCacheBytes tracks the size of the local cache.
+	LocalCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "postgres_backup_local_cache_bytes",
+		Help: "Total size of the backups kept in the local cache in bytes",
+	})
+
+	// LocalCacheEvictions tracks backups removed from the local cache by its retention.
+	LocalCacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "postgres_backup_local_cache_evictions_total",
+		Help: "Total number of backups evicted from the local cache",
+	})
+
+	// LocalCacheReads tracks reads, such as restores, served by the local cache ("hit")
+	// or remote storage ("miss").
+	LocalCacheReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_local_cache_reads_total",
+		Help: "Total number of backup reads by whether the local cache held the object",
+	}, []string{"result"})
+
+	// FleetServices tracks the Postgres services discovered in fleet mode.
+	FleetServices = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "postgres_backup_fleet_services",
+		Help: "Number of Postgres services discovered in the Railway project",
+	})
+
+	// FleetDiscoveryErrors tracks failed service discovery requests.
+	FleetDiscoveryErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "postgres_backup_fleet_discovery_errors_total",
+		Help: "Total number of failed Railway service discoveries",
+	})
+
+	// FleetBackupAttempts tracks backup attempts per fleet service.
+	FleetBackupAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_backup_fleet_attempts_total",
+		Help: "Total number of backup attempts per fleet service",
+	}, []string{"service", "status"})
+
+	// FleetLastBackupTimestamp tracks the last successful backup per fleet service.
+	FleetLastBackupTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_fleet_last_success_timestamp",
+		Help: "Unix timestamp of the last successful backup per fleet service",
+	}, []string{"service"})
+
+	// FleetBackupSize tracks the size of the last backup per fleet service.
+	FleetBackupSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_backup_fleet_size_bytes",
+		Help: "Size of the last backup per fleet service in bytes",
+	}, []string{"service"})
+
+	// Info provides static information about the service and its build.
 	Info = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "postgres_backup_info",
 		Help: "Information about the backup service",
-	}, []string{"version", "storage_provider"})
+	}, []string{"version", "commit", "build_date", "storage_provider"})
 )
 
 // RecordBackupAttempt records a backup attempt with its status.
@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Embed colours of successful and failed runs.
+const (
+	discordGreen = 0x2ecc71
+	discordRed   = 0xe74c3c
+)
+
+// Discord limits the length of embed descriptions and field values.
+const (
+	discordDescriptionLimit = 4096
+	discordFieldLimit       = 1024
+)
+
+// Discord posts each summary as an embed to a Discord webhook.
+type Discord struct {
+	url      string
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewDiscord creates a notifier posting to the Discord webhook url. Each
+// request is bounded by timeout; failed deliveries, including rate limited
+// ones, are retried up to attempts in total.
+func NewDiscord(url string, timeout time.Duration, attempts int) *Discord {
+	return &Discord{
+		url:      url,
+		client:   &http.Client{Timeout: timeout},
+		attempts: max(attempts, 1),
+		backoff:  defaultBackoff,
+	}
+}
+
+// discordMessage is the body of a Discord webhook execution.
+type discordMessage struct {
+	Username string         `json:"username"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Notify implements Notifier.
+func (d *Discord) Notify(ctx context.Context, s Summary) error {
+	payload, err := json.Marshal(discordMessage{
+		Username: "Postgres Backup",
+		Embeds:   []discordEmbed{discordSummary(s)},
+	})
+	if err != nil {
+		return err
+	}
+	return deliver(ctx, d.attempts, d.backoff, func() error {
+		return postJSON(ctx, d.client, d.url, payload)
+	})
+}
+
+// discordSummary renders s as an embed: green with the backup's size and
+// timings on success, red with the error on failure.
+func discordSummary(s Summary) discordEmbed {
+	embed := discordEmbed{Title: "Backup succeeded", Color: discordGreen}
+	if s.Status == StatusFailure {
+		embed.Title = "Backup failed"
+		embed.Color = discordRed
+		embed.Description = truncate(s.Error, discordDescriptionLimit)
+	}
+	if s.Database != "" {
+		embed.Title += ": " + s.Database
+	}
+	if !s.Time.IsZero() {
+		embed.Timestamp = s.Time.Format(time.RFC3339)
+	}
+
+	add := func(name, value string, inline bool) {
+		if value != "" {
+			embed.Fields = append(embed.Fields, discordField{Name: name, Value: truncate(value, discordFieldLimit), Inline: inline})
+		}
+	}
+	add("Key", s.Key, false)
+	if s.Bytes > 0 {
+		add("Size", utils.FormatBytes(s.Bytes), true)
+	}
+	if s.DurationSeconds > 0 {
+		add("Duration", formatSeconds(s.DurationSeconds), true)
+	}
+	add("Phase", s.Phase, true)
+	add("PostgreSQL", s.DatabaseVersion, false)
+	return embed
+}
+
+// formatSeconds formats a duration in seconds, rounded to the second.
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+// truncate shortens s to at most limit bytes, marking the cut with an ellipsis.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit - len("…")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
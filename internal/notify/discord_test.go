@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscord_Notify(t *testing.T) {
+	var got discordMessage
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// Discord rate limits bursts of messages
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := NewDiscord(server.URL, time.Second, 3)
+	d.backoff = time.Millisecond
+	s := Summary{Status: StatusFailure, Database: "app", Phase: "uploading", Error: "access denied", Time: time.Date(2025, 1, 10, 3, 0, 0, 0, time.UTC)}
+	if err := d.Notify(context.Background(), s); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Discord received %d requests, want 2", calls)
+	}
+	if len(got.Embeds) != 1 {
+		t.Fatalf("message = %+v, want one embed", got)
+	}
+	embed := got.Embeds[0]
+	if embed.Title != "Backup failed: app" || embed.Color != discordRed || embed.Description != "access denied" ||
+		embed.Timestamp != "2025-01-10T03:00:00Z" {
+		t.Errorf("embed = %+v", embed)
+	}
+}
+
+func TestDiscordSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		summary    Summary
+		wantTitle  string
+		wantColor  int
+		wantFields []string
+	}{
+		{
+			name:       "success",
+			summary:    Summary{Status: StatusSuccess, Database: "app", DatabaseVersion: "PostgreSQL 16.4", Key: "backup.tar.gz", Bytes: 5 << 20, DurationSeconds: 131.5},
+			wantTitle:  "Backup succeeded: app",
+			wantColor:  discordGreen,
+			wantFields: []string{"Key=backup.tar.gz", "Size=5.0 MB", "Duration=2m12s", "PostgreSQL=PostgreSQL 16.4"},
+		},
+		{
+			name:       "failure before the dump",
+			summary:    Summary{Status: StatusFailure, Phase: "locking", Error: "lock held"},
+			wantTitle:  "Backup failed",
+			wantColor:  discordRed,
+			wantFields: []string{"Phase=locking"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embed := discordSummary(tt.summary)
+			if embed.Title != tt.wantTitle || embed.Color != tt.wantColor {
+				t.Errorf("embed = %q in %#x, want %q in %#x", embed.Title, embed.Color, tt.wantTitle, tt.wantColor)
+			}
+			var fields []string
+			for _, f := range embed.Fields {
+				fields = append(fields, f.Name+"="+f.Value)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate() = %q, want the string unchanged", got)
+	}
+	got := truncate(strings.Repeat("é", 10), 9)
+	if len(got) > 9 || !strings.HasSuffix(got, "…") || !strings.HasPrefix(got, "éé") {
+		t.Errorf("truncate() = %q, want at most 9 bytes cut on a rune boundary", got)
+	}
+}
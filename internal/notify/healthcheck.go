@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Healthcheck pings a dead man's switch such as healthchecks.io, which alerts
+// when a run does not start or finish on schedule. A process that never runs
+// exports no metrics, so only an outside check can notice it.
+type Healthcheck struct {
+	url      string
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewHealthcheck creates a pinger for the check at url. Each request is
+// bounded by timeout; failed pings are retried up to attempts in total.
+func NewHealthcheck(url string, timeout time.Duration, attempts int) *Healthcheck {
+	return &Healthcheck{
+		url:      strings.TrimSuffix(url, "/"),
+		client:   &http.Client{Timeout: timeout},
+		attempts: max(attempts, 1),
+		backoff:  defaultBackoff,
+	}
+}
+
+// Start pings /start when a run begins, so the check measures the run's
+// duration and notices a run that never finishes.
+func (h *Healthcheck) Start(ctx context.Context) error {
+	return h.ping(ctx, h.url+"/start", nil)
+}
+
+// Notify implements Notifier, pinging the check itself after a successful run
+// and /fail after a failed one, with the summary as the ping's body.
+func (h *Healthcheck) Notify(ctx context.Context, s Summary) error {
+	url := h.url
+	if s.Status == StatusFailure {
+		url += "/fail"
+	}
+	return h.ping(ctx, url, []byte(healthcheckBody(s)))
+}
+
+func (h *Healthcheck) ping(ctx context.Context, url string, body []byte) error {
+	return deliver(ctx, h.attempts, h.backoff, func() error {
+		return post(ctx, h.client, url, "text/plain; charset=utf-8", body)
+	})
+}
+
+// healthcheckBody formats s as the plain text shown in the check's log.
+func healthcheckBody(s Summary) string {
+	var duration string
+	if s.DurationSeconds > 0 {
+		duration = formatSeconds(s.DurationSeconds)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "status: %s\n", s.Status)
+	for _, line := range []struct{ name, value string }{
+		{"database", s.Database},
+		{"key", s.Key},
+		{"duration", duration},
+		{"phase", s.Phase},
+		{"error", s.Error},
+	} {
+		if line.value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", line.name, line.value)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthcheck(t *testing.T) {
+	type ping struct{ path, body string }
+	var pings []ping
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pings = append(pings, ping{r.URL.Path, string(body)})
+		if len(pings) == 2 {
+			w.WriteHeader(http.StatusBadGateway) // The first end ping is retried
+		}
+	}))
+	defer server.Close()
+
+	// A trailing slash on the check URL is ignored
+	h := NewHealthcheck(server.URL+"/uuid/", time.Second, 3)
+	h.backoff = time.Millisecond
+	ctx := context.Background()
+
+	if err := h.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := h.Notify(ctx, Summary{Status: StatusSuccess, Key: "backup.tar.gz", DurationSeconds: 12}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if err := h.Notify(ctx, Summary{Status: StatusFailure, Phase: "dumping", Error: "pg_dump failed"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	wantPaths := []string{"/uuid/start", "/uuid", "/uuid", "/uuid/fail"}
+	if len(pings) != len(wantPaths) {
+		t.Fatalf("check received %d pings, want %d: %v", len(pings), len(wantPaths), pings)
+	}
+	for i, want := range wantPaths {
+		if pings[i].path != want {
+			t.Errorf("ping %d path = %q, want %q", i, pings[i].path, want)
+		}
+	}
+	if !strings.Contains(pings[2].body, "duration: 12s") || !strings.Contains(pings[2].body, "key: backup.tar.gz") {
+		t.Errorf("success ping body = %q, want the key and duration", pings[2].body)
+	}
+	if !strings.Contains(pings[3].body, "phase: dumping") || !strings.Contains(pings[3].body, "error: pg_dump failed") {
+		t.Errorf("failure ping body = %q, want the phase and error", pings[3].body)
+	}
+}
+
+func TestHealthcheckBody(t *testing.T) {
+	got := healthcheckBody(Summary{Status: StatusFailure, Database: "app"})
+	if want := "status: failure\ndatabase: app\n"; got != want {
+		t.Errorf("healthcheckBody() = %q, want %q", got, want)
+	}
+}
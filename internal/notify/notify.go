@@ -0,0 +1,89 @@
+// Package notify sends a summary of each backup run to webhooks and chat
+// services. Notifications are best effort and never fail a run.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Run statuses reported in a summary.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Values of NOTIFY_ON: notify after every run, or only after failed ones.
+const (
+	OnAlways  = "always"
+	OnFailure = "failure"
+)
+
+// Summary describes the outcome of a backup run. Fields not known for the
+// run, such as the key of a run that failed before uploading, are empty.
+type Summary struct {
+	Status          string    `json:"status"`
+	Time            time.Time `json:"time"`
+	Database        string    `json:"database,omitempty"`
+	DatabaseVersion string    `json:"database_version,omitempty"`
+	Key             string    `json:"key,omitempty"`
+	Bytes           int64     `json:"bytes,omitempty"`
+	DumpSeconds     float64   `json:"dump_seconds,omitempty"`
+	UploadSeconds   float64   `json:"upload_seconds,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	Phase           string    `json:"phase,omitempty"` // Phase a failed run failed in
+	Error           string    `json:"error,omitempty"`
+}
+
+// Notifier delivers run summaries to one integration.
+type Notifier interface {
+	Notify(ctx context.Context, s Summary) error
+}
+
+// Send delivers s to every notifier, logging those that fail.
+func Send(ctx context.Context, notifiers []Notifier, s Summary, logger *slog.Logger) {
+	if s.Time.IsZero() {
+		s.Time = time.Now().UTC()
+	}
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, s); err != nil {
+			logger.Warn("Failed to send notification", "status", s.Status, "error", err)
+		}
+	}
+}
+
+// permanentError is a delivery failure that retrying cannot fix, such as a
+// rejected request.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// deliver calls send up to attempts times, doubling the delay from backoff
+// between attempts, until it succeeds or fails permanently.
+func deliver(ctx context.Context, attempts int, backoff time.Duration, send func() error) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = send(); err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt == attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("notification failed after %d attempts: %w", attempts, err)
+}
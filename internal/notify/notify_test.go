@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	summaries []Summary
+	err       error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, s Summary) error {
+	r.summaries = append(r.summaries, s)
+	return r.err
+}
+
+func TestSend(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("unreachable")}
+	ok := &recordingNotifier{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	Send(context.Background(), []Notifier{failing, ok}, Summary{Status: StatusSuccess}, logger)
+
+	if len(ok.summaries) != 1 {
+		t.Fatalf("a failing notifier stopped delivery: got %d summaries, want 1", len(ok.summaries))
+	}
+	if ok.summaries[0].Time.IsZero() {
+		t.Error("Send did not stamp the summary time")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first attempt succeeds", []error{nil}, 1, false},
+		{"retries transient failures", []error{errors.New("503"), errors.New("503"), nil}, 3, false},
+		{"gives up after attempts", []error{errors.New("503"), errors.New("503"), errors.New("503")}, 3, true},
+		{"stops on permanent failure", []error{&permanentError{err: errors.New("400")}, nil}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := deliver(context.Background(), 3, time.Millisecond, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deliver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("deliver() made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
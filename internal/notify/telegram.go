@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// telegramAPI is the Bot API endpoint messages are sent through.
+const telegramAPI = "https://api.telegram.org"
+
+// telegramErrorLimit keeps a failure's error well inside Telegram's 4096
+// character message limit.
+const telegramErrorLimit = 3000
+
+// Telegram sends each summary as a message from a Telegram bot to a chat.
+type Telegram struct {
+	api      string
+	token    string
+	chatID   string
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewTelegram creates a notifier sending through the bot with token to the
+// chat chatID. Each request is bounded by timeout; failed deliveries,
+// including rate limited ones, are retried up to attempts in total.
+func NewTelegram(token, chatID string, timeout time.Duration, attempts int) *Telegram {
+	return &Telegram{
+		api:      telegramAPI,
+		token:    token,
+		chatID:   chatID,
+		client:   &http.Client{Timeout: timeout},
+		attempts: max(attempts, 1),
+		backoff:  defaultBackoff,
+	}
+}
+
+// telegramMessage is the body of a sendMessage request.
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+// Notify implements Notifier.
+func (t *Telegram) Notify(ctx context.Context, s Summary) error {
+	payload, err := json.Marshal(telegramMessage{ChatID: t.chatID, Text: telegramSummary(s), ParseMode: "HTML"})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.api, t.token)
+	err = deliver(ctx, t.attempts, t.backoff, func() error {
+		return postJSON(ctx, t.client, url, payload)
+	})
+	if err != nil {
+		// The token is part of the URL quoted by request errors
+		return errors.New(strings.ReplaceAll(err.Error(), t.token, "<token>"))
+	}
+	return nil
+}
+
+// telegramSummary renders s as an HTML message: the backup's size and timings
+// on success, the failed phase and error on failure.
+func telegramSummary(s Summary) string {
+	title := "✅ <b>Backup succeeded"
+	if s.Status == StatusFailure {
+		title = "❌ <b>Backup failed"
+	}
+	if s.Database != "" {
+		title += ": " + html.EscapeString(s.Database)
+	}
+
+	lines := []string{title + "</b>"}
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	if s.Key != "" {
+		add("Key", "<code>"+html.EscapeString(s.Key)+"</code>")
+	}
+	if s.Bytes > 0 {
+		add("Size", utils.FormatBytes(s.Bytes))
+	}
+	if s.DurationSeconds > 0 {
+		add("Duration", formatSeconds(s.DurationSeconds))
+	}
+	add("Phase", html.EscapeString(s.Phase))
+	add("PostgreSQL", html.EscapeString(s.DatabaseVersion))
+	if s.Error != "" {
+		lines = append(lines, "<pre>"+html.EscapeString(truncate(s.Error, telegramErrorLimit))+"</pre>")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegram_Notify(t *testing.T) {
+	var got telegramMessage
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/bot123:secret/sendMessage" {
+			t.Errorf("request path = %q, want the bot's sendMessage method", r.URL.Path)
+		}
+		// Telegram rate limits bursts of messages
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tg := NewTelegram("123:secret", "-1001234", time.Second, 3)
+	tg.api = server.URL
+	tg.backoff = time.Millisecond
+	if err := tg.Notify(context.Background(), Summary{Status: StatusFailure, Database: "app", Error: "access denied"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Telegram received %d requests, want 2", calls)
+	}
+	if got.ChatID != "-1001234" || got.ParseMode != "HTML" || !strings.HasPrefix(got.Text, "❌ <b>Backup failed: app</b>") {
+		t.Errorf("message = %+v", got)
+	}
+}
+
+func TestTelegram_NotifyHidesToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // Every request fails with an error quoting the URL
+
+	tg := NewTelegram("123:secret", "42", time.Second, 1)
+	tg.api = server.URL
+	err := tg.Notify(context.Background(), Summary{Status: StatusSuccess})
+	if err == nil {
+		t.Fatal("Notify() succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), "<token>") {
+		t.Errorf("Notify() error = %q, want the bot token hidden", err)
+	}
+}
+
+func TestTelegramSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary Summary
+		want    string
+	}{
+		{
+			name:    "success",
+			summary: Summary{Status: StatusSuccess, Database: "app", DatabaseVersion: "PostgreSQL 16.4", Key: "2025/01/backup.tar.gz", Bytes: 5 << 20, DurationSeconds: 131.5},
+			want:    "✅ <b>Backup succeeded: app</b>\nKey: <code>2025/01/backup.tar.gz</code>\nSize: 5.0 MB\nDuration: 2m12s\nPostgreSQL: PostgreSQL 16.4",
+		},
+		{
+			name:    "failure escapes the error",
+			summary: Summary{Status: StatusFailure, Phase: "dumping", Error: `relation "a<b>" does not exist`},
+			want:    "❌ <b>Backup failed</b>\nPhase: dumping\n<pre>relation &#34;a&lt;b&gt;&#34; does not exist</pre>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := telegramSummary(tt.summary); got != tt.want {
+				t.Errorf("telegramSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultBackoff is the delay before the first retry of a failed delivery.
+const defaultBackoff = time.Second
+
+// Webhook POSTs each summary as JSON to a URL.
+type Webhook struct {
+	url      string
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewWebhook creates a notifier posting to url. Each request is bounded by
+// timeout; failed deliveries are retried up to attempts in total.
+func NewWebhook(url string, timeout time.Duration, attempts int) *Webhook {
+	return &Webhook{
+		url:      url,
+		client:   &http.Client{Timeout: timeout},
+		attempts: max(attempts, 1),
+		backoff:  defaultBackoff,
+	}
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, s Summary) error {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return deliver(ctx, w.attempts, w.backoff, func() error {
+		return postJSON(ctx, w.client, w.url, payload)
+	})
+}
+
+// postJSON POSTs a JSON payload to url.
+func postJSON(ctx context.Context, client *http.Client, url string, payload []byte) error {
+	return post(ctx, client, url, "application/json", payload)
+}
+
+// post POSTs payload to url. Server errors and rate limiting can be retried;
+// other statuses outside 2xx are permanent.
+func post(ctx context.Context, client *http.Client, url, contentType string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("notification endpoint returned %s", resp.Status)
+	default:
+		return &permanentError{err: fmt.Errorf("notification endpoint returned %s", resp.Status)}
+	}
+}
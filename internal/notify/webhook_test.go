@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"delivered", []int{http.StatusNoContent}, 1, false},
+		{"retries server errors", []int{http.StatusBadGateway, http.StatusOK}, 2, false},
+		{"retries rate limiting", []int{http.StatusTooManyRequests, http.StatusOK}, 2, false},
+		{"does not retry rejected requests", []int{http.StatusBadRequest, http.StatusOK}, 1, true},
+		{"gives up after attempts", []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Summary
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				var s Summary
+				if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+					t.Errorf("invalid payload: %v", err)
+				}
+				got = append(got, s)
+				w.WriteHeader(tt.statuses[len(got)-1])
+			}))
+			defer server.Close()
+
+			w := NewWebhook(server.URL, time.Second, 3)
+			w.backoff = time.Millisecond
+			err := w.Notify(context.Background(), Summary{Status: StatusFailure, Database: "app", Error: "pg_dump failed"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantCalls {
+				t.Fatalf("webhook received %d requests, want %d", len(got), tt.wantCalls)
+			}
+			if got[0].Status != StatusFailure || got[0].Database != "app" || got[0].Error != "pg_dump failed" {
+				t.Errorf("payload = %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestWebhook_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	w := NewWebhook(server.URL, 50*time.Millisecond, 1)
+	if err := w.Notify(context.Background(), Summary{Status: StatusSuccess}); err == nil {
+		t.Error("Notify() succeeded against an endpoint that never responded")
+	}
+}
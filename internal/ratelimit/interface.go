@@ -12,10 +12,39 @@ type RateLimiter interface {
 	// The string return value contains a human-readable reason when backup is skipped.
 	ShouldBackup(lastBackup time.Time) (bool, string)
 
+	// Decide is ShouldBackup with the cause of a skip and the time the next
+	// backup is allowed, for metrics.
+	Decide(lastBackup time.Time) Decision
+
 	// GetMinInterval returns the minimum time interval between backups.
 	GetMinInterval() time.Duration
 }
 
+// Causes of a skipped backup, as reported in Decision.SkipReason.
+const (
+	// SkipTooRecent is reported when the last backup is within the minimum interval.
+	SkipTooRecent = "too-recent"
+
+	// SkipManualHold is reported when backups are paused by an operator.
+	SkipManualHold = "manual-hold"
+)
+
+// Decision is the outcome of a rate limiter check.
+type Decision struct {
+	// Allowed is true when the backup should proceed.
+	Allowed bool
+
+	// Reason explains the decision in human-readable form.
+	Reason string
+
+	// SkipReason is the machine-readable cause of a skip, empty when allowed.
+	SkipReason string
+
+	// NextAllowed is the earliest time the next backup is allowed; zero when
+	// there is no previous backup to count the interval from.
+	NextAllowed time.Time
+}
+
 // Config holds configuration for rate limiting.
 type Config struct {
 	// MinInterval is the minimum time between backups.
@@ -19,25 +19,43 @@ func NewTimeBasedLimiter(config Config) *TimeBasedLimiter {
 
 // ShouldBackup implements RateLimiter.
 func (t *TimeBasedLimiter) ShouldBackup(lastBackup time.Time) (bool, string) {
-	if t.config.ForceBackup {
-		return true, "forced backup requested"
-	}
+	d := t.Decide(lastBackup)
+	return d.Allowed, d.Reason
+}
 
+// Decide implements RateLimiter.
+func (t *TimeBasedLimiter) Decide(lastBackup time.Time) Decision {
 	if lastBackup.IsZero() {
-		return true, "no previous backup found"
+		if t.config.ForceBackup {
+			return Decision{Allowed: true, Reason: "forced backup requested"}
+		}
+		return Decision{Allowed: true, Reason: "no previous backup found"}
+	}
+
+	next := lastBackup.Add(t.config.MinInterval)
+	if t.config.ForceBackup {
+		return Decision{Allowed: true, Reason: "forced backup requested", NextAllowed: next}
 	}
 
 	timeSinceLastBackup := time.Since(lastBackup)
 	if timeSinceLastBackup < t.config.MinInterval {
 		timeUntilNextBackup := t.config.MinInterval - timeSinceLastBackup
-		return false, fmt.Sprintf(
-			"last backup was %s ago, next backup allowed in %s",
-			formatDuration(timeSinceLastBackup),
-			formatDuration(timeUntilNextBackup),
-		)
+		return Decision{
+			Reason: fmt.Sprintf(
+				"last backup was %s ago, next backup allowed in %s",
+				formatDuration(timeSinceLastBackup),
+				formatDuration(timeUntilNextBackup),
+			),
+			SkipReason:  SkipTooRecent,
+			NextAllowed: next,
+		}
 	}
 
-	return true, fmt.Sprintf("last backup was %s ago", formatDuration(timeSinceLastBackup))
+	return Decision{
+		Allowed:     true,
+		Reason:      fmt.Sprintf("last backup was %s ago", formatDuration(timeSinceLastBackup)),
+		NextAllowed: next,
+	}
 }
 
 // GetMinInterval implements RateLimiter.
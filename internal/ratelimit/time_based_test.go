@@ -72,6 +72,34 @@ func TestTimeBasedLimiter_ShouldBackup(t *testing.T) {
 	}
 }
 
+func TestTimeBasedLimiter_Decide(t *testing.T) {
+	last := time.Now().Add(-2 * time.Hour)
+	tests := []struct {
+		name           string
+		config         Config
+		lastBackup     time.Time
+		wantSkipReason string
+		wantNext       time.Time
+	}{
+		{"no previous backup", Config{MinInterval: 6 * time.Hour}, time.Time{}, "", time.Time{}},
+		{"too recent", Config{MinInterval: 6 * time.Hour}, last, SkipTooRecent, last.Add(6 * time.Hour)},
+		{"forced", Config{MinInterval: 6 * time.Hour, ForceBackup: true}, last, "", last.Add(6 * time.Hour)},
+		{"interval elapsed", Config{MinInterval: time.Hour}, last, "", last.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewTimeBasedLimiter(tt.config).Decide(tt.lastBackup)
+			if d.SkipReason != tt.wantSkipReason || d.Allowed != (tt.wantSkipReason == "") {
+				t.Errorf("Decide() = allowed %v, skip reason %q; want %q", d.Allowed, d.SkipReason, tt.wantSkipReason)
+			}
+			if !d.NextAllowed.Equal(tt.wantNext) {
+				t.Errorf("Decide() next allowed = %v, want %v", d.NextAllowed, tt.wantNext)
+			}
+		})
+	}
+}
+
 func TestTimeBasedLimiter_GetMinInterval(t *testing.T) {
 	config := Config{
 		MinInterval: 8 * time.Hour,
@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 3 * *",
+		"0 3 * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"0 0 30 2 *",
+		"@reboot",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{expr: "0 3 * * *", want: time.Date(2025, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{expr: "@daily", want: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{expr: "@hourly", want: time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{expr: "*/15 * * * *", want: time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{expr: "30 10 * * *", want: time.Date(2025, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{expr: "0 9-17/4 * * *", want: time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * sun", want: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * 7", want: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * mon-fri", want: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 1 */3 *", want: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 feb *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 31 * *", want: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{expr: "0 0 1 * fri", want: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		// Seconds are dropped and the current minute is never returned
+		{expr: "* * * * *", from: from.Add(20 * time.Second), want: time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{expr: "0 0 1 1 *", from: time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC), want: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			start := tt.from
+			if start.IsZero() {
+				start = from
+			}
+			if got := s.Next(start); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_NextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	s, err := Parse("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 02:30 does not exist on the spring-forward day; the run happens as the clock skips it
+	got := s.Next(time.Date(2025, 3, 9, 0, 0, 0, 0, loc))
+	if want := time.Date(2025, 3, 9, 3, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+
+	// 01:30 happens twice on the fall-back day; the run happens once
+	s, err = Parse("30 1 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := s.Next(time.Date(2025, 11, 2, 0, 0, 0, 0, loc))
+	if want := time.Date(2025, 11, 2, 1, 30, 0, 0, loc); !first.Equal(want) {
+		t.Errorf("Next() = %v, want %v", first, want)
+	}
+	if got, want := s.Next(first), time.Date(2025, 11, 3, 1, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", first, got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package schedule
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/ratelimit"
+)
+
+// maxRetryDelay bounds how long an interval runner waits to retry a run that
+// stored no backup.
+const maxRetryDelay = 15 * time.Minute
+
+// Runner runs a job every time a schedule fires, until its context is cancelled.
+type Runner struct {
+	next     func(ctx context.Context, now time.Time) time.Time
+	describe string
+	prefix   string
+	logger   *slog.Logger
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+// NewRunner creates a runner for a cron schedule. prefix labels the next-run metric.
+func NewRunner(schedule *Schedule, prefix string, logger *slog.Logger) *Runner {
+	return &Runner{
+		next: func(_ context.Context, now time.Time) time.Time {
+			return schedule.Next(now)
+		},
+		describe: schedule.String(),
+		prefix:   prefix,
+		logger:   logger,
+		now:      time.Now,
+		after:    time.After,
+	}
+}
+
+// NewIntervalRunner creates a runner that runs whenever limiter next allows a
+// backup after the last one, as reported by last, so the schedule and the rate
+// limiter share one interval. The first run happens at once when there is no
+// backup yet; a run that stores none is retried after the interval or 15
+// minutes, whichever is shorter.
+func NewIntervalRunner(limiter ratelimit.RateLimiter, last func(context.Context) (time.Time, error), prefix string, logger *slog.Logger) *Runner {
+	interval := limiter.GetMinInterval()
+	retry := min(interval, maxRetryDelay)
+	var lastRun time.Time
+
+	r := &Runner{
+		describe: "every " + interval.String(),
+		prefix:   prefix,
+		logger:   logger,
+		now:      time.Now,
+		after:    time.After,
+	}
+	r.next = func(ctx context.Context, now time.Time) time.Time {
+		next := now
+		lastBackup, err := last(ctx)
+		if err != nil {
+			logger.Warn("Failed to get last backup time, backing up now", "error", err)
+		} else if allowed := limiter.Decide(lastBackup).NextAllowed; allowed.After(next) {
+			next = allowed
+		}
+		if !lastRun.IsZero() && next.Before(lastRun.Add(retry)) {
+			next = lastRun.Add(retry)
+		}
+		lastRun = next
+		return next
+	}
+	return r
+}
+
+// Run waits for each scheduled time and runs job, until ctx is cancelled.
+// Runs never overlap: a job still running when the schedule next fires skips
+// that time, and the following one is waited for.
+func (r *Runner) Run(ctx context.Context, job func(context.Context)) {
+	for {
+		now := r.now()
+		next := r.next(ctx, now)
+		metrics.NextScheduledRun.WithLabelValues(r.prefix).Set(float64(next.Unix()))
+		r.logger.Info("Next backup scheduled", "schedule", r.describe, "at", next, "in", next.Sub(now).Round(time.Second))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.after(next.Sub(now)):
+		}
+
+		job(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunner_Run(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := Parse("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The clock starts at 10:20 and each job takes 70 minutes
+	now := time.Date(2025, 1, 15, 10, 20, 0, 0, time.UTC)
+	var waits []time.Duration
+	runner := NewRunner(s, "runner", logger)
+	runner.now = func() time.Time { return now }
+	runner.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		now = now.Add(d)
+		ch := make(chan time.Time, 1)
+		ch <- now
+		return ch
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs []time.Time
+	runner.Run(ctx, func(ctx context.Context) {
+		runs = append(runs, now)
+		now = now.Add(70 * time.Minute)
+		if len(runs) == 3 {
+			cancel()
+		}
+	})
+
+	// A job outlasting the next scheduled time skips it
+	wantRuns := []time.Time{
+		time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 15, 15, 0, 0, 0, time.UTC),
+	}
+	if len(runs) != len(wantRuns) {
+		t.Fatalf("runs = %v, want %v", runs, wantRuns)
+	}
+	for i := range runs {
+		if !runs[i].Equal(wantRuns[i]) {
+			t.Errorf("run %d at %v, want %v", i, runs[i], wantRuns[i])
+		}
+	}
+	if len(waits) != 3 || waits[0] != 40*time.Minute || waits[1] != 50*time.Minute {
+		t.Errorf("waits = %v, want 40m then 50m", waits)
+	}
+	if got, want := testutil.ToFloat64(metrics.NextScheduledRun.WithLabelValues("runner")), float64(wantRuns[2].Unix()); got != want {
+		t.Errorf("NextScheduledRun = %v, want %v", got, want)
+	}
+}
+
+func TestIntervalRunner_Run(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := ratelimit.NewTimeBasedLimiter(ratelimit.Config{MinInterval: time.Hour})
+
+	// The last backup was 20 minutes ago; the first run stores a backup, the second fails
+	start := time.Now().Truncate(time.Second)
+	now := start
+	lastBackup := start.Add(-20 * time.Minute)
+	last := func(context.Context) (time.Time, error) { return lastBackup, nil }
+
+	runner := NewIntervalRunner(limiter, last, "interval", logger)
+	runner.now = func() time.Time { return now }
+	var waits []time.Duration
+	runner.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		now = now.Add(d)
+		ch := make(chan time.Time, 1)
+		ch <- now
+		return ch
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	runner.Run(ctx, func(context.Context) {
+		runs++
+		if runs == 1 {
+			lastBackup = now
+		}
+		now = now.Add(5 * time.Minute)
+		if runs == 3 {
+			cancel()
+		}
+	})
+
+	// The rate limiter's next allowed time, then the interval after the stored
+	// backup, then the retry delay after the failed run
+	want := []time.Duration{40 * time.Minute, 55 * time.Minute, 10 * time.Minute}
+	if !slices.Equal(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+}
+
+func TestIntervalRunner_FirstBackup(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := ratelimit.NewTimeBasedLimiter(ratelimit.Config{MinInterval: 6 * time.Hour})
+	runner := NewIntervalRunner(limiter, func(context.Context) (time.Time, error) {
+		return time.Time{}, nil
+	}, "interval", logger)
+
+	// Without a backup in storage the first run happens at once
+	now := time.Now()
+	if next := runner.next(context.Background(), now); !next.Equal(now) {
+		t.Errorf("next = %v, want %v", next, now)
+	}
+}
+
+func TestRunner_StopsWhileWaiting(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := Parse("@daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	NewRunner(s, "runner", logger).Run(ctx, func(context.Context) {
+		t.Error("job ran after the context was cancelled")
+	})
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Page sizes of the backups endpoint.
+const (
+	DefaultBackupsLimit = 100
+	MaxBackupsLimit     = 1000
+)
+
+// BackupInfo is one backup listed by the backups endpoint.
+type BackupInfo struct {
+	Key       string            `json:"key"`
+	SizeBytes int64             `json:"size_bytes"`
+	Time      time.Time         `json:"time"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// BackupsResponse is one page of the backups endpoint.
+type BackupsResponse struct {
+	Backups    []BackupInfo `json:"backups"`
+	Total      int          `json:"total"`
+	Offset     int          `json:"offset"`
+	Limit      int          `json:"limit"`
+	NextOffset *int         `json:"next_offset,omitempty"` // Offset of the next page, when there is one
+}
+
+// BackupsHandler lists the backups whose filename starts with filenamePrefix,
+// newest first, a page at a time: "limit" (default 100, at most 1000) backups
+// from "offset". The bucket is listed with Storage.List on every request, and
+// only the backups of the page are read with Stat when the listing carries no
+// metadata. When token is set, callers must present it as a bearer token. The
+// "prefix" query parameter lists one fleet service.
+func BackupsHandler(store storage.Storage, filenamePrefix, token string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		limit, err := queryInt(query.Get("limit"), DefaultBackupsLimit)
+		if err != nil || limit < 1 || limit > MaxBackupsLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(MaxBackupsLimit), http.StatusBadRequest)
+			return
+		}
+		offset, err := queryInt(query.Get("offset"), 0)
+		if err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+
+		target := store
+		if prefix := query.Get("prefix"); prefix != "" {
+			target = storage.NewPrefixedStorage(store, prefix)
+		}
+
+		objects, err := target.List(r.Context(), "")
+		if err != nil {
+			logger.Warn("Failed to list backups", "error", err)
+			http.Error(w, "failed to list backups: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		objects = slices.DeleteFunc(objects, func(obj storage.ObjectInfo) bool {
+			return storage.IsReservedKey(obj.Key) || utils.IsSidecar(obj.Key) || !strings.HasPrefix(path.Base(obj.Key), filenamePrefix)
+		})
+		slices.SortStableFunc(objects, func(a, b storage.ObjectInfo) int {
+			return storage.BackupTime(b).Compare(storage.BackupTime(a))
+		})
+
+		resp := BackupsResponse{Backups: []BackupInfo{}, Total: len(objects), Offset: offset, Limit: limit}
+		page := objects[min(offset, len(objects)):min(offset+limit, len(objects))]
+		for _, obj := range page {
+			if obj.Metadata == nil {
+				if info, err := target.Stat(r.Context(), obj.Key); err == nil {
+					obj.Metadata = info.Metadata
+				}
+			}
+			resp.Backups = append(resp.Backups, BackupInfo{
+				Key:       obj.Key,
+				SizeBytes: obj.Size,
+				Time:      storage.BackupTime(obj).UTC(),
+				Metadata:  obj.Metadata,
+			})
+		}
+		if next := offset + limit; next < len(objects) {
+			resp.NextOffset = &next
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// queryInt parses an integer query parameter, or returns def when it is empty.
+func queryInt(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestBackupsHandler(t *testing.T) {
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{
+		"2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz",
+		"2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz.sha256",
+		"2025/01/backup-pg16-2025-01-02T00-00-00-000Z.tar.gz",
+		"2025/01/backup-pg16-2025-01-03T00-00-00-000Z.tar.gz",
+		"2025/01/other-pg16-2025-01-04T00-00-00-000Z.tar.gz",
+		storage.StateKey,
+	} {
+		metadata := map[string]string{"backup-label": "nightly"}
+		if err := fs.Upload(ctx, key, strings.NewReader("data"), metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := BackupsHandler(fs, "backup", "secret", logger)
+
+	tests := []struct {
+		name       string
+		query      string
+		auth       string
+		wantStatus int
+		wantKeys   string
+		wantNext   int
+	}{
+		{name: "missing token", wantStatus: http.StatusUnauthorized},
+		{name: "all backups, newest first", auth: "Bearer secret", wantStatus: http.StatusOK,
+			wantKeys: "2025/01/backup-pg16-2025-01-03T00-00-00-000Z.tar.gz,2025/01/backup-pg16-2025-01-02T00-00-00-000Z.tar.gz,2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz"},
+		{name: "first page", query: "limit=2", auth: "Bearer secret", wantStatus: http.StatusOK,
+			wantKeys: "2025/01/backup-pg16-2025-01-03T00-00-00-000Z.tar.gz,2025/01/backup-pg16-2025-01-02T00-00-00-000Z.tar.gz", wantNext: 2},
+		{name: "last page", query: "limit=2&offset=2", auth: "Bearer secret", wantStatus: http.StatusOK,
+			wantKeys: "2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz"},
+		{name: "past the end", query: "offset=10", auth: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "limit too large", query: "limit=5000", auth: "Bearer secret", wantStatus: http.StatusBadRequest},
+		{name: "negative offset", query: "offset=-1", auth: "Bearer secret", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/backups?"+tt.query, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp BackupsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, b := range resp.Backups {
+				keys = append(keys, b.Key)
+				if b.Metadata["backup-label"] != "nightly" || b.SizeBytes != 4 || b.Time.IsZero() {
+					t.Errorf("backup = %+v, want its size, time and metadata", b)
+				}
+			}
+			if got := strings.Join(keys, ","); got != tt.wantKeys {
+				t.Errorf("keys = %s, want %s", got, tt.wantKeys)
+			}
+			if resp.Total != 3 {
+				t.Errorf("total = %d, want 3", resp.Total)
+			}
+			if (resp.NextOffset == nil) != (tt.wantNext == 0) || (resp.NextOffset != nil && *resp.NextOffset != tt.wantNext) {
+				t.Errorf("next offset = %v, want %d", resp.NextOffset, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/catalog"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// MonitorRecentRuns is the number of runs reported by the monitor endpoint.
+const MonitorRecentRuns = 10
+
+// MonitorResponse is the body returned by the monitor endpoint.
+type MonitorResponse struct {
+	Time                time.Time               `json:"time"`
+	Run                 *storage.RunCheckpoint  `json:"run,omitempty"` // The latest run, in progress or finished
+	LastBackupTime      time.Time               `json:"last_backup_time"`
+	LastKey             string                  `json:"last_key,omitempty"`
+	LastSize            int64                   `json:"last_size,omitempty"`
+	ConsecutiveFailures int                     `json:"consecutive_failures,omitempty"`
+	Paused              *storage.Pause          `json:"paused,omitempty"`
+	RecentRuns          []storage.HistoryRecord `json:"recent_runs"` // Newest first
+	Catalog             []catalog.Summary       `json:"catalog"`
+}
+
+// MonitorHandler reports what the `top` command displays: the phase of the
+// latest run from the state object, the recent runs from the run history, the
+// pause, and the catalog summaries of the serve-mode scanner. When token is
+// set, callers must present it as a bearer token. The "prefix" query
+// parameter scopes the run state to one fleet service.
+func MonitorHandler(store storage.Storage, summaries func() []catalog.Summary, token string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		target := store
+		if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+			target = storage.NewPrefixedStorage(store, prefix)
+		}
+
+		resp, err := monitorStatus(r.Context(), target)
+		if err != nil {
+			logger.Warn("Failed to read run status", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Catalog = summaries()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// monitorStatus reads the run state, pause and recent history from store. A
+// state or history object that cannot be read, such as before the first run,
+// is reported as empty.
+func monitorStatus(ctx context.Context, store storage.Storage) (MonitorResponse, error) {
+	resp := MonitorResponse{Time: time.Now().UTC(), RecentRuns: []storage.HistoryRecord{}}
+
+	if state, err := storage.ReadState(ctx, store); err == nil {
+		resp.Run = state.Run
+		resp.LastBackupTime = state.LastBackupTime
+		resp.LastKey = state.LastKey
+		resp.LastSize = state.LastSize
+		resp.ConsecutiveFailures = state.ConsecutiveFailures
+	}
+
+	var err error
+	if resp.Paused, err = storage.ReadPause(ctx, store); err != nil {
+		return resp, err
+	}
+
+	history, _ := storage.ReadHistory(ctx, store)
+	history = history[max(0, len(history)-MonitorRecentRuns):]
+	slices.Reverse(history)
+	resp.RecentRuns = append(resp.RecentRuns, history...)
+	return resp, nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/catalog"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestMonitorHandler(t *testing.T) {
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	summaries := func() []catalog.Summary {
+		return []catalog.Summary{{Destination: "s3", Backups: 2, Bytes: 300}}
+	}
+	handler := MonitorHandler(fs, summaries, "secret", logger)
+
+	get := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/monitor", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := get(""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Before the first run there is no state or history
+	rec := get("Bearer secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var resp MonitorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Run != nil || len(resp.RecentRuns) != 0 || len(resp.Catalog) != 1 {
+		t.Errorf("empty bucket response = %+v, want only the catalog", resp)
+	}
+
+	started := time.Now().UTC().Add(-time.Minute)
+	run := &storage.RunCheckpoint{Key: "backup-2.sql.gz", StartedAt: started}
+	run.Enter(storage.PhaseDumping)
+	if err := storage.WriteState(ctx, fs, storage.State{LastKey: "backup-1.sql.gz", LastBackupTime: started, Run: run}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < MonitorRecentRuns+2; i++ {
+		record := storage.HistoryRecord{Time: started.Add(time.Duration(i) * time.Second), Key: "backup", Status: "success", SizeBytes: int64(i)}
+		if err := storage.AppendHistory(ctx, fs, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.WritePause(ctx, fs, storage.Pause{Reason: "maintenance", PausedAt: started}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = get("Bearer secret")
+	resp = MonitorResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Run == nil || resp.Run.Phase != storage.PhaseDumping || resp.LastKey != "backup-1.sql.gz" {
+		t.Errorf("run = %+v, last key = %q, want dumping after backup-1.sql.gz", resp.Run, resp.LastKey)
+	}
+	if len(resp.RecentRuns) != MonitorRecentRuns || resp.RecentRuns[0].SizeBytes != MonitorRecentRuns+1 {
+		t.Errorf("recent runs = %+v, want the newest %d, newest first", resp.RecentRuns, MonitorRecentRuns)
+	}
+	if resp.Paused == nil || resp.Paused.Reason != "maintenance" {
+		t.Errorf("paused = %+v, want the maintenance pause", resp.Paused)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// PauseResponse is the body returned by the pause endpoint.
+type PauseResponse struct {
+	Paused   bool       `json:"paused"`
+	Reason   string     `json:"reason,omitempty"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+}
+
+// PauseHandler pauses and resumes backups for callers presenting token as a
+// bearer token: GET reports the pause, POST pauses with an optional "reason"
+// query parameter, and DELETE resumes. The pause is stored next to the
+// backups, so every instance sharing the bucket honours it; the "prefix" query
+// parameter scopes it to one fleet service.
+func PauseHandler(store storage.Storage, token string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		target := store
+		prefix := r.URL.Query().Get("prefix")
+		if prefix != "" {
+			target = storage.NewPrefixedStorage(store, prefix)
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			pause := storage.Pause{Reason: r.URL.Query().Get("reason"), PausedAt: time.Now().UTC()}
+			if err := storage.WritePause(r.Context(), target, pause); err != nil {
+				logger.Warn("Failed to pause backups", "prefix", prefix, "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			logger.Info("Backups paused", "prefix", prefix, "reason", pause.Reason, "remote_addr", r.RemoteAddr)
+		case http.MethodDelete:
+			if err := storage.ClearPause(r.Context(), target); err != nil {
+				logger.Warn("Failed to resume backups", "prefix", prefix, "error", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			logger.Info("Backups resumed", "prefix", prefix, "remote_addr", r.RemoteAddr)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		pause, err := storage.ReadPause(r.Context(), target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := PauseResponse{}
+		if pause != nil {
+			resp = PauseResponse{Paused: true, Reason: pause.Reason, PausedAt: &pause.PausedAt}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestPauseHandler(t *testing.T) {
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := PauseHandler(fs, "secret", logger)
+
+	// Steps run in order against the same bucket
+	tests := []struct {
+		name       string
+		method     string
+		query      string
+		auth       string
+		wantStatus int
+		wantPaused bool
+		wantReason string
+	}{
+		{name: "missing token", method: http.MethodPost, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, auth: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "not paused", method: http.MethodGet, auth: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "pauses", method: http.MethodPost, query: "reason=maintenance", auth: "Bearer secret", wantStatus: http.StatusOK, wantPaused: true, wantReason: "maintenance"},
+		{name: "reports the pause", method: http.MethodGet, auth: "Bearer secret", wantStatus: http.StatusOK, wantPaused: true, wantReason: "maintenance"},
+		{name: "other prefixes are not paused", method: http.MethodGet, query: "prefix=other/", auth: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "resumes", method: http.MethodDelete, auth: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "unsupported method", method: http.MethodPut, auth: "Bearer secret", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/pause?"+tt.query, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp PauseResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Paused != tt.wantPaused || resp.Reason != tt.wantReason {
+				t.Errorf("response = %+v, want paused %v with reason %q", resp, tt.wantPaused, tt.wantReason)
+			}
+		})
+	}
+}
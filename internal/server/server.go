@@ -9,12 +9,14 @@ import (
 	"time"
 
 	"github.com/imedwei/railway-postgres-backup/internal/health"
+	"github.com/imedwei/railway-postgres-backup/internal/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Server represents the HTTP server for metrics and health checks.
 type Server struct {
 	server  *http.Server
+	mux     *http.ServeMux
 	logger  *slog.Logger
 	checker *health.Checker
 }
@@ -47,6 +49,7 @@ func New(config Config, logger *slog.Logger) *Server {
 	mux.HandleFunc("/health", checker.Handler())
 	mux.HandleFunc("/ready", health.ReadinessHandler())
 	mux.HandleFunc("/live", health.LivenessHandler())
+	mux.HandleFunc("/version", version.Handler())
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", config.Port),
@@ -57,6 +60,7 @@ func New(config Config, logger *slog.Logger) *Server {
 
 	return &Server{
 		server:  server,
+		mux:     mux,
 		logger:  logger,
 		checker: checker,
 	}
@@ -67,6 +71,11 @@ func (s *Server) RegisterHealthCheck(name string, checkFunc func(context.Context
 	s.checker.RegisterCheck(name, checkFunc)
 }
 
+// Handle registers an additional route.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
 // Start starts the HTTP server.
 func (s *Server) Start() error {
 	s.logger.Info("Starting HTTP server", "addr", s.server.Addr)
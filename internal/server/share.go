@@ -0,0 +1,80 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// ShareResponse is the body returned by the share endpoint.
+type ShareResponse struct {
+	Key       string    `json:"key"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// authorized reports whether r presents token as a bearer token.
+func authorized(r *http.Request, token string) bool {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// ShareHandler serves time-limited download URLs for backups to callers
+// presenting token as a bearer token. The key is given by the "key" query
+// parameter, and "expires" optionally overrides defaultExpiry (e.g. "30m").
+func ShareHandler(store storage.Storage, token string, defaultExpiry time.Duration, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key is required", http.StatusBadRequest)
+			return
+		}
+		expiry := defaultExpiry
+		if value := r.URL.Query().Get("expires"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				http.Error(w, "invalid expires: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			expiry = d
+		}
+
+		url, err := storage.ShareURL(r.Context(), store, key, expiry)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, storage.ErrSharingUnsupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		case err != nil:
+			logger.Warn("Failed to share backup", "key", key, "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The URL is a credential, so only the key is logged
+		expiresAt := time.Now().Add(expiry).UTC()
+		logger.Info("Shared backup", "key", key, "expires_at", expiresAt, "remote_addr", r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(ShareResponse{Key: key, URL: url, ExpiresAt: expiresAt})
+	}
+}
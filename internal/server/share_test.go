@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// signingStorage adds URL signing to filesystem storage.
+type signingStorage struct {
+	*storage.FilesystemStorage
+}
+
+func (s *signingStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "https://signed.example/" + key, nil
+}
+
+func TestShareHandler(t *testing.T) {
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Upload(context.Background(), "backup.tar.gz", strings.NewReader("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := ShareHandler(&signingStorage{fs}, "secret", time.Hour, logger)
+
+	tests := []struct {
+		name       string
+		query      string
+		auth       string
+		wantStatus int
+	}{
+		{name: "shares a backup", query: "key=backup.tar.gz", auth: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "missing token", query: "key=backup.tar.gz", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", query: "key=backup.tar.gz", auth: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "missing key", auth: "Bearer secret", wantStatus: http.StatusBadRequest},
+		{name: "invalid expiry", query: "key=backup.tar.gz&expires=soon", auth: "Bearer secret", wantStatus: http.StatusBadRequest},
+		{name: "unknown backup", query: "key=other.tar.gz", auth: "Bearer secret", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/share?"+tt.query, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp ShareResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.URL != "https://signed.example/backup.tar.gz" || resp.ExpiresAt.IsZero() {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/ratelimit"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+// StatusResponse is the body returned by the status endpoint.
+type StatusResponse struct {
+	Time           time.Time      `json:"time"`
+	LastRun        *RunStatus     `json:"last_run,omitempty"` // The latest run, in progress or finished
+	LastBackupTime time.Time      `json:"last_backup_time"`
+	LastKey        string         `json:"last_key,omitempty"`
+	BackupAllowed  bool           `json:"backup_allowed"`         // Whether respawn protection lets a run back up now
+	NextAllowed    *time.Time     `json:"next_allowed,omitempty"` // Unset before the first backup
+	Paused         *storage.Pause `json:"paused,omitempty"`
+}
+
+// RunStatus describes a run from its checkpoint in the state object.
+type RunStatus struct {
+	Key             string                    `json:"key"`
+	Phase           string                    `json:"phase"`
+	StartedAt       time.Time                 `json:"started_at"`
+	EndedAt         *time.Time                `json:"ended_at,omitempty"` // Unset while the run is in progress or after it was killed
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Phases          []PhaseDuration           `json:"phases"`
+	BytesWritten    int64                     `json:"bytes_written"`
+	Error           string                    `json:"error,omitempty"`
+	Retention       *storage.RetentionOutcome `json:"retention,omitempty"`
+}
+
+// PhaseDuration is the time a run spent in a phase.
+type PhaseDuration struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
+
+// StatusHandler reports the outcome of the latest run from the state object,
+// when respawn protection allows the next backup according to limiter, and the
+// pause. When token is set, callers must present it as a bearer token. The
+// "prefix" query parameter scopes the status to one fleet service.
+func StatusHandler(store storage.Storage, limiter ratelimit.RateLimiter, token string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		target := store
+		if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+			target = storage.NewPrefixedStorage(store, prefix)
+		}
+
+		resp, err := runStatus(r.Context(), target, limiter)
+		if err != nil {
+			logger.Warn("Failed to read run status", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// runStatus reads the run state and pause from store. The last backup time is
+// read as respawn protection reads it, listing the bucket when the state
+// object is missing.
+func runStatus(ctx context.Context, store storage.Storage, limiter ratelimit.RateLimiter) (StatusResponse, error) {
+	now := time.Now().UTC()
+	resp := StatusResponse{Time: now}
+
+	if state, err := storage.ReadState(ctx, store); err == nil {
+		resp.LastKey = state.LastKey
+		if state.Run != nil {
+			resp.LastRun = newRunStatus(state.Run, now)
+		}
+	}
+
+	var err error
+	if resp.LastBackupTime, err = storage.LastBackupTime(ctx, store); err != nil {
+		return resp, err
+	}
+	decision := limiter.Decide(resp.LastBackupTime)
+	resp.BackupAllowed = decision.Allowed
+	if !decision.NextAllowed.IsZero() {
+		next := decision.NextAllowed.UTC()
+		resp.NextAllowed = &next
+	}
+
+	if resp.Paused, err = storage.ReadPause(ctx, store); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// newRunStatus derives the phase durations and end of run from its
+// checkpoint. A phase lasts until the next one was entered; the phase of a run
+// still in progress, or killed, is counted until now.
+func newRunStatus(run *storage.RunCheckpoint, now time.Time) *RunStatus {
+	status := &RunStatus{
+		Key:          run.Key,
+		Phase:        run.Phase,
+		StartedAt:    run.StartedAt,
+		Phases:       []PhaseDuration{},
+		BytesWritten: run.SizeBytes,
+		Error:        run.Error,
+		Retention:    run.Retention,
+	}
+
+	end := now
+	if !run.Interrupted() {
+		end = run.UpdatedAt
+		status.EndedAt = &end
+	}
+	status.DurationSeconds = end.Sub(run.StartedAt).Seconds()
+
+	for i, transition := range run.Phases {
+		if transition.Phase == storage.PhaseComplete || transition.Phase == storage.PhaseFailed {
+			break
+		}
+		until := end
+		if i+1 < len(run.Phases) {
+			until = run.Phases[i+1].At
+		}
+		status.Phases = append(status.Phases, PhaseDuration{Phase: transition.Phase, Seconds: until.Sub(transition.At).Seconds()})
+	}
+	return status
+}
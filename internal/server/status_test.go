@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/ratelimit"
+	"github.com/imedwei/railway-postgres-backup/internal/storage"
+)
+
+func TestStatusHandler(t *testing.T) {
+	fs, err := storage.NewFilesystemStorage(storage.FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := ratelimit.NewTimeBasedLimiter(ratelimit.Config{MinInterval: 23 * time.Hour})
+	handler := StatusHandler(fs, limiter, "secret", logger)
+
+	get := func(auth string) (*httptest.ResponseRecorder, StatusResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp StatusResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, resp
+	}
+
+	if rec, _ := get(""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Before the first run a backup is allowed right away
+	rec, resp := get("Bearer secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if resp.LastRun != nil || !resp.BackupAllowed || resp.NextAllowed != nil {
+		t.Errorf("empty bucket response = %+v, want no run and a backup allowed", resp)
+	}
+
+	started := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	run := &storage.RunCheckpoint{
+		Key:       "backup-1.tar.gz",
+		Phase:     storage.PhaseComplete,
+		StartedAt: started,
+		UpdatedAt: started.Add(100 * time.Second),
+		Phases: []storage.PhaseTransition{
+			{Phase: storage.PhaseDumping, At: started.Add(10 * time.Second)},
+			{Phase: storage.PhaseUploading, At: started.Add(70 * time.Second)},
+			{Phase: storage.PhaseRetaining, At: started.Add(95 * time.Second)},
+			{Phase: storage.PhaseComplete, At: started.Add(100 * time.Second)},
+		},
+		SizeBytes: 4096,
+		Retention: &storage.RetentionOutcome{RetentionDays: 7, Deleted: 2},
+	}
+	state := storage.State{LastBackupTime: started, LastKey: run.Key, LastSize: run.SizeBytes, Run: run}
+	if err := storage.WriteState(ctx, fs, state); err != nil {
+		t.Fatal(err)
+	}
+
+	_, resp = get("Bearer secret")
+	last := resp.LastRun
+	if last == nil || last.Key != "backup-1.tar.gz" || last.BytesWritten != 4096 || last.DurationSeconds != 100 {
+		t.Fatalf("last run = %+v, want backup-1.tar.gz with 4096 bytes in 100s", last)
+	}
+	if last.EndedAt == nil || !last.EndedAt.Equal(started.Add(100*time.Second)) {
+		t.Errorf("ended at = %v, want the completion time", last.EndedAt)
+	}
+	want := []PhaseDuration{{storage.PhaseDumping, 60}, {storage.PhaseUploading, 25}, {storage.PhaseRetaining, 5}}
+	if len(last.Phases) != len(want) {
+		t.Fatalf("phases = %+v, want %+v", last.Phases, want)
+	}
+	for i := range want {
+		if last.Phases[i] != want[i] {
+			t.Errorf("phases[%d] = %+v, want %+v", i, last.Phases[i], want[i])
+		}
+	}
+	if last.Retention == nil || last.Retention.Deleted != 2 {
+		t.Errorf("retention = %+v, want 2 deleted", last.Retention)
+	}
+	if resp.BackupAllowed || resp.NextAllowed == nil || !resp.NextAllowed.Equal(started.Add(23*time.Hour)) {
+		t.Errorf("backup allowed = %v, next allowed = %v, want %v", resp.BackupAllowed, resp.NextAllowed, started.Add(23*time.Hour))
+	}
+
+	// A run still in progress has no end, and its phase is counted until now
+	state.Run = &storage.RunCheckpoint{Key: "backup-2.tar.gz", StartedAt: started}
+	state.Run.Enter(storage.PhaseDumping)
+	if err := storage.WriteState(ctx, fs, state); err != nil {
+		t.Fatal(err)
+	}
+	_, resp = get("Bearer secret")
+	if last := resp.LastRun; last == nil || last.EndedAt != nil || len(last.Phases) != 1 || last.Phases[0].Phase != storage.PhaseDumping {
+		t.Errorf("last run = %+v, want backup-2.tar.gz dumping", last)
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// BucketCreator is implemented by providers that can create their bucket on first run.
+type BucketCreator interface {
+	// EnsureBucket creates the bucket when it does not exist and reports whether it did.
+	EnsureBucket(ctx context.Context) (bool, error)
+}
+
+// BucketAPI is the subset of the S3 client used to create a bucket.
+type BucketAPI interface {
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	PutPublicAccessBlock(ctx context.Context, params *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error)
+}
+
+// EnsureBucket implements BucketCreator.
+func (s *S3Storage) EnsureBucket(ctx context.Context) (bool, error) {
+	// S3-compatible services take their location from the endpoint and
+	// generally do not implement the public access block API
+	onAWS := s.endpoint == ""
+	region := ""
+	if onAWS {
+		region = s.region
+	}
+	return ensureS3Bucket(ctx, s.client, s.bucket, region, onAWS)
+}
+
+// ensureS3Bucket creates bucket in region when it is missing. New buckets keep
+// versioning off and, when blockPublic is set, have all public access blocked.
+func ensureS3Bucket(ctx context.Context, client BucketAPI, bucket, region string, blockPublic bool) (bool, error) {
+	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return false, nil
+	}
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		return false, fmt.Errorf("failed to check S3 bucket %s: %w", bucket, err)
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	// us-east-1 is the default location and must not be sent as a constraint
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+
+	if _, err := client.CreateBucket(ctx, input); err != nil {
+		// Another replica may have created it between the check and now
+		var owned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &owned) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create S3 bucket %s: %w", bucket, err)
+	}
+
+	if blockPublic {
+		_, err := client.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+			Bucket: aws.String(bucket),
+			PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+				BlockPublicAcls:       aws.Bool(true),
+				BlockPublicPolicy:     aws.Bool(true),
+				IgnorePublicAcls:      aws.Bool(true),
+				RestrictPublicBuckets: aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return true, fmt.Errorf("created S3 bucket %s but failed to block public access: %w", bucket, err)
+		}
+	}
+
+	return true, nil
+}
+
+// EnsureBucket implements BucketCreator. New buckets use uniform bucket-level
+// access with public access prevention enforced.
+func (g *GCSStorage) EnsureBucket(ctx context.Context) (bool, error) {
+	bucket := g.client.Bucket(g.bucket)
+
+	_, err := bucket.Attrs(ctx)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, storage.ErrBucketNotExist) {
+		return false, fmt.Errorf("failed to check GCS bucket %s: %w", g.bucket, err)
+	}
+
+	attrs := &storage.BucketAttrs{
+		Location:                 g.location, // Empty uses the GCS default (US multi-region)
+		UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true},
+		PublicAccessPrevention:   storage.PublicAccessPreventionEnforced,
+	}
+	if err := bucket.Create(ctx, g.projectID, attrs); err != nil {
+		return false, fmt.Errorf("failed to create GCS bucket %s: %w", g.bucket, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// CachedStorage mirrors uploaded backups to a local directory, such as a mounted
+// volume, while streaming them to remote storage. Only the most recent backups
+// are kept locally, so an emergency restore need not wait on the network.
+type CachedStorage struct {
+	remote    Storage
+	cache     *FilesystemStorage
+	retention CacheRetention
+	logger    *slog.Logger
+}
+
+// cacheChecksumKey is the cache metadata key holding the SHA-256 of a mirrored
+// backup, recorded once the copy is complete.
+const cacheChecksumKey = "cache-sha256"
+
+// errCacheChecksum reports a cached copy that does not match its recorded checksum.
+var errCacheChecksum = errors.New("cached copy does not match its checksum")
+
+// CacheRetention bounds the backups kept in a local cache; a zero field is
+// unlimited. The newest backup is always kept.
+type CacheRetention struct {
+	Keep     int   // Most recent backups kept
+	MaxBytes int64 // Total size of the cached backups and their sidecars
+}
+
+// NewCachedStorage creates a storage that uploads to remote and keeps the most
+// recent backups allowed by retention in cache.
+func NewCachedStorage(remote Storage, cache *FilesystemStorage, retention CacheRetention, logger *slog.Logger) *CachedStorage {
+	return &CachedStorage{
+		remote:    remote,
+		cache:     cache,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// Uncached returns store without its local cache, so reads go to remote storage.
+func Uncached(store Storage) Storage {
+	if cached, ok := store.(*CachedStorage); ok {
+		return cached.Remote()
+	}
+	return store
+}
+
+// Remote returns the storage backups are uploaded to.
+func (c *CachedStorage) Remote() Storage {
+	return c.remote
+}
+
+// Cache returns the local directory backups are mirrored to.
+func (c *CachedStorage) Cache() *FilesystemStorage {
+	return c.cache
+}
+
+// Upload implements Storage.Upload. The remote upload decides the outcome: a
+// failed local copy is only logged, and a failed remote upload is not cached.
+func (c *CachedStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	if IsReservedKey(key) {
+		return c.remote.Upload(ctx, key, reader, metadata)
+	}
+
+	pr, pw := io.Pipe()
+	cached := make(chan error, 1)
+	hash := sha256.New()
+	go func() {
+		err := c.cache.Upload(ctx, key, io.TeeReader(pr, hash), metadata)
+		// Unblock the mirror if the cache stopped reading
+		_ = pr.CloseWithError(err)
+		cached <- err
+	}()
+
+	mirror := &mirrorWriter{w: pw}
+	if err := c.remote.Upload(ctx, key, io.TeeReader(reader, mirror), metadata); err != nil {
+		_ = pw.CloseWithError(err)
+		<-cached
+		return err
+	}
+	_ = pw.Close()
+
+	if err := <-cached; err != nil {
+		c.logger.Warn("Failed to mirror backup to the local cache", "key", key, "error", err)
+		return nil
+	}
+
+	// Only a complete copy gets a checksum, so reads can trust it
+	cacheMetadata := maps.Clone(metadata)
+	if cacheMetadata == nil {
+		cacheMetadata = make(map[string]string)
+	}
+	cacheMetadata[cacheChecksumKey] = hex.EncodeToString(hash.Sum(nil))
+	if err := c.cache.writeMetadata(ctx, key, cacheMetadata); err != nil {
+		c.logger.Warn("Failed to record the checksum of the cached backup", "key", key, "error", err)
+	}
+	if err := c.prune(ctx); err != nil {
+		c.logger.Warn("Failed to prune the local cache", "error", err)
+	}
+	return nil
+}
+
+// mirrorWriter copies data to the cache until the cache fails, after which it
+// discards writes so the remote upload is unaffected.
+type mirrorWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (m *mirrorWriter) Write(p []byte) (int, error) {
+	if m.err == nil {
+		_, m.err = m.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// prune evicts the cached backups, with their sidecars, that fall outside the
+// retention policy, counting from the newest.
+func (c *CachedStorage) prune(ctx context.Context) error {
+	objects, err := c.cache.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	// Group sidecars with their backup so both count towards its size
+	var backups []ObjectInfo
+	sizes := make(map[string]int64)
+	for _, obj := range objects {
+		key := utils.TrimSidecarSuffix(obj.Key)
+		sizes[key] += obj.Size
+		if key == obj.Key {
+			backups = append(backups, obj)
+		}
+	}
+
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].LastModified.After(backups[j].LastModified)
+	})
+
+	evicted := make(map[string]bool)
+	var kept int
+	var keptBytes int64
+	for i, obj := range backups {
+		size := sizes[obj.Key]
+		overCount := c.retention.Keep > 0 && kept >= c.retention.Keep
+		overSize := c.retention.MaxBytes > 0 && keptBytes+size > c.retention.MaxBytes
+		if i > 0 && (overCount || overSize) {
+			evicted[obj.Key] = true
+			continue
+		}
+		kept++
+		keptBytes += size
+	}
+
+	for _, obj := range objects {
+		if !evicted[utils.TrimSidecarSuffix(obj.Key)] {
+			continue
+		}
+		if err := c.cache.Delete(ctx, obj.Key); err != nil {
+			return fmt.Errorf("failed to evict %s: %w", obj.Key, err)
+		}
+		if !utils.IsSidecar(obj.Key) {
+			metrics.LocalCacheEvictions.Inc()
+		}
+		c.logger.Debug("Evicted backup from the local cache", "key", obj.Key)
+	}
+
+	metrics.LocalCacheBackups.Set(float64(kept))
+	metrics.LocalCacheBytes.Set(float64(keptBytes))
+	return nil
+}
+
+// Open implements Storage.Open, serving backups from the cache when a copy
+// matching its checksum is present. A corrupt copy is evicted.
+func (c *CachedStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if IsReservedKey(key) {
+		return c.remote.Open(ctx, key)
+	}
+
+	r, err := c.openCached(ctx, key)
+	if err == nil {
+		metrics.LocalCacheReads.WithLabelValues("hit").Inc()
+		c.logger.Info("Reading backup from the local cache", "key", key)
+		return r, nil
+	}
+	if errors.Is(err, errCacheChecksum) {
+		c.logger.Warn("Evicting corrupt backup from the local cache", "key", key)
+		if err := c.cache.Delete(ctx, key); err != nil {
+			c.logger.Warn("Failed to evict corrupt backup", "key", key, "error", err)
+		}
+	} else {
+		c.logger.Debug("Backup not usable from the local cache", "key", key, "reason", err)
+	}
+
+	metrics.LocalCacheReads.WithLabelValues("miss").Inc()
+	return c.remote.Open(ctx, key)
+}
+
+// openCached opens the cached copy of key after checking it against the
+// checksum recorded when it was mirrored.
+func (c *CachedStorage) openCached(ctx context.Context, key string) (io.ReadCloser, error) {
+	fullPath, err := c.cache.getFullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	want := readMetadata(fullPath)[cacheChecksumKey]
+	if want == "" {
+		return nil, fmt.Errorf("no checksum recorded for %s", key)
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, &contextReader{ctx: ctx, reader: file}); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to read cached copy: %w", err)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != want {
+		_ = file.Close()
+		return nil, errCacheChecksum
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenRange implements Storage.OpenRange on remote storage, as a cached copy is
+// only trusted after checking the checksum of the whole file.
+func (c *CachedStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return c.remote.OpenRange(ctx, key, offset, length)
+}
+
+// Delete implements Storage.Delete on remote storage; the cache has its own retention.
+func (c *CachedStorage) Delete(ctx context.Context, key string) error {
+	return c.remote.Delete(ctx, key)
+}
+
+// Copy implements Storage.Copy on remote storage.
+func (c *CachedStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return c.remote.Copy(ctx, srcKey, dstKey)
+}
+
+// Stat implements Storage.Stat on remote storage.
+func (c *CachedStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return c.remote.Stat(ctx, key)
+}
+
+// List implements Storage.List.
+func (c *CachedStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return c.remote.List(ctx, prefix)
+}
+
+// ListWithMetadata implements MetadataLister.
+func (c *CachedStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return ListWithMetadata(ctx, c.remote, prefix)
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime.
+func (c *CachedStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return c.remote.GetLastBackupTime(ctx)
+}
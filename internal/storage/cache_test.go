@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	newFS := func(t *testing.T) *FilesystemStorage {
+		fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewFilesystemStorage() error = %v", err)
+		}
+		return fs
+	}
+	keys := func(t *testing.T, fs *FilesystemStorage) []string {
+		objects, err := fs.List(ctx, "")
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		var keys []string
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+		return keys
+	}
+
+	t.Run("mirrors uploads and keeps the most recent", func(t *testing.T) {
+		remote, cache := newFS(t), newFS(t)
+		c := NewCachedStorage(remote, cache, CacheRetention{Keep: 2}, logger)
+
+		for _, key := range []string{"2024/01/a.tar.gz", "2024/01/b.tar.gz", "2024/02/c.tar.gz"} {
+			if err := c.Upload(ctx, key, strings.NewReader("data "+key), map[string]string{"k": "v"}); err != nil {
+				t.Fatalf("Upload(%s) error = %v", key, err)
+			}
+			if key == "2024/01/a.tar.gz" {
+				// A sidecar is evicted with its backup
+				if err := c.Upload(ctx, key+utils.SettingsSuffix, strings.NewReader("{}"), nil); err != nil {
+					t.Fatalf("Upload() sidecar error = %v", err)
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := c.Upload(ctx, StateKey, strings.NewReader("{}"), nil); err != nil {
+			t.Fatalf("Upload() state error = %v", err)
+		}
+
+		if got := keys(t, remote); len(got) != 5 {
+			t.Errorf("remote keys = %v, want every upload", got)
+		}
+		got := keys(t, cache)
+		if strings.Join(got, ",") != "2024/01/b.tar.gz,2024/02/c.tar.gz" {
+			t.Errorf("cache keys = %v, want the two most recent backups", got)
+		}
+
+		r, err := cache.Open(ctx, "2024/02/c.tar.gz")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		data, _ := io.ReadAll(r)
+		_ = r.Close()
+		if string(data) != "data 2024/02/c.tar.gz" {
+			t.Errorf("cached data = %q", data)
+		}
+	})
+
+	t.Run("size budget evicts older backups but keeps the newest", func(t *testing.T) {
+		remote, cache := newFS(t), newFS(t)
+		c := NewCachedStorage(remote, cache, CacheRetention{MaxBytes: 250}, logger)
+
+		for _, upload := range []struct {
+			key  string
+			size int
+		}{{"a", 100}, {"b", 100}, {"c", 100}, {"d", 400}} {
+			if err := c.Upload(ctx, upload.key, strings.NewReader(strings.Repeat("x", upload.size)), nil); err != nil {
+				t.Fatalf("Upload(%s) error = %v", upload.key, err)
+			}
+			if upload.key == "c" {
+				if got := strings.Join(keys(t, cache), ","); got != "b,c" {
+					t.Errorf("cache keys after c = %s, want b,c within 250 bytes", got)
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := strings.Join(keys(t, cache), ","); got != "d" {
+			t.Errorf("cache keys = %s, want only the newest backup even over budget", got)
+		}
+	})
+
+	t.Run("reads are served from the cache when present", func(t *testing.T) {
+		remote, cache := newFS(t), newFS(t)
+		c := NewCachedStorage(remote, cache, CacheRetention{Keep: 1}, logger)
+		if err := c.Upload(ctx, "a.tar.gz", strings.NewReader("cached"), nil); err != nil {
+			t.Fatal(err)
+		}
+		// Only the remote copy of an older backup remains
+		if err := remote.Upload(ctx, "old.tar.gz", strings.NewReader("remote"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Upload(ctx, "a.tar.gz", strings.NewReader("changed remotely"), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for key, want := range map[string]string{"a.tar.gz": "cached", "old.tar.gz": "remote"} {
+			r, err := c.Open(ctx, key)
+			if err != nil {
+				t.Fatalf("Open(%s) error = %v", key, err)
+			}
+			data, _ := io.ReadAll(r)
+			_ = r.Close()
+			if string(data) != want {
+				t.Errorf("Open(%s) = %q, want %q", key, data, want)
+			}
+		}
+	})
+
+	t.Run("corrupt or unverified copies are read from remote", func(t *testing.T) {
+		remote, cache := newFS(t), newFS(t)
+		c := NewCachedStorage(remote, cache, CacheRetention{Keep: 3}, logger)
+		for _, key := range []string{"a.tar.gz", "b.tar.gz"} {
+			if err := c.Upload(ctx, key, strings.NewReader("data "+key), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Damage one copy and place another without a recorded checksum
+		if err := os.WriteFile(filepath.Join(cache.root, "a.tar.gz"), []byte("bit rot"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Upload(ctx, "c.tar.gz", strings.NewReader("stale"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Upload(ctx, "c.tar.gz", strings.NewReader("data c.tar.gz"), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, key := range []string{"a.tar.gz", "b.tar.gz", "c.tar.gz"} {
+			r, err := c.Open(ctx, key)
+			if err != nil {
+				t.Fatalf("Open(%s) error = %v", key, err)
+			}
+			data, _ := io.ReadAll(r)
+			_ = r.Close()
+			if string(data) != "data "+key {
+				t.Errorf("Open(%s) = %q, want the uploaded data", key, data)
+			}
+		}
+		if got := strings.Join(keys(t, cache), ","); got != "b.tar.gz,c.tar.gz" {
+			t.Errorf("cache keys = %s, want the corrupt copy evicted", got)
+		}
+
+		if Uncached(c) != Storage(remote) || Uncached(remote) != Storage(remote) {
+			t.Error("Uncached() did not return the remote storage")
+		}
+	})
+
+	t.Run("failed remote upload is not cached", func(t *testing.T) {
+		cache := newFS(t)
+		c := NewCachedStorage(&mockStorage{uploadErr: errors.New("remote down")}, cache, CacheRetention{Keep: 2}, logger)
+
+		if err := c.Upload(ctx, "a.tar.gz", strings.NewReader("data"), nil); err == nil {
+			t.Fatal("Upload() expected the remote error")
+		}
+		if got := keys(t, cache); len(got) != 0 {
+			t.Errorf("cache keys = %v, want nothing", got)
+		}
+	})
+
+	t.Run("failed cache does not fail the upload", func(t *testing.T) {
+		remote, cache := newFS(t), newFS(t)
+		c := NewCachedStorage(remote, cache, CacheRetention{Keep: 2}, logger)
+
+		// A file where the key's directory should be makes the cache write fail
+		if err := os.WriteFile(filepath.Join(cache.root, "2024"), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Upload(ctx, "2024/01/a.tar.gz", strings.NewReader(strings.Repeat("x", 1<<20)), nil); err != nil {
+			t.Fatalf("Upload() error = %v, want the remote upload to succeed", err)
+		}
+		if got := keys(t, remote); len(got) != 1 {
+			t.Errorf("remote keys = %v, want the backup", got)
+		}
+	})
+}
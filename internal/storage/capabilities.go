@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CapabilityProbeKey names the object written while probing an S3 endpoint.
+// It is deleted after the probe.
+const CapabilityProbeKey = ".capability-probe"
+
+// maxSinglePutSize is the largest object S3 accepts in a single PutObject.
+const maxSinglePutSize = 5 * 1024 * 1024 * 1024
+
+// S3Capabilities records which optional S3 features an endpoint supports.
+// Ceph RGW, MinIO and other S3 implementations each leave out some of them.
+type S3Capabilities struct {
+	Checksums  bool // Flexible checksums, such as the SDK's default CRC32
+	Tagging    bool // Object tags sent with an upload
+	ObjectLock bool // Object Lock enabled on the bucket, so uploads need Content-MD5
+	Multipart  bool // Multipart uploads
+
+	// Errors holds the error of each unsupported feature's probe
+	Errors map[string]string
+}
+
+// CapabilityAPI is the subset of the S3 client used to probe an endpoint.
+type CapabilityAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	GetObjectLockConfiguration(ctx context.Context, params *s3.GetObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// ProbeCapabilities implements the probe of ProbeS3Capabilities against the
+// storage's bucket, deleting the probe object with the delete client.
+func (s *S3Storage) ProbeCapabilities(ctx context.Context) (S3Capabilities, error) {
+	return ProbeS3Capabilities(ctx, s.client, s.deleter, s.bucket, s.getFullKey(CapabilityProbeKey))
+}
+
+// ProbeS3Capabilities finds out which optional features the endpoint supports
+// by using each once on key: after reading the bucket's Object Lock
+// configuration, a plain upload, which must succeed, then an upload with a
+// CRC32 checksum, one with a tag, and a one-part multipart upload, which is
+// aborted. Checksums are only sent where the probe asks for them. The probe
+// object is deleted with deleter; a failure to delete it is ignored.
+func ProbeS3Capabilities(ctx context.Context, client, deleter CapabilityAPI, bucket, key string) (S3Capabilities, error) {
+	caps := S3Capabilities{Errors: make(map[string]string)}
+	body := []byte("railway-postgres-backup capability probe\n")
+	sum := md5.Sum(body)
+	contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	whenRequired := func(o *s3.Options) {
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+	}
+
+	// Uploads to a bucket with Object Lock must carry Content-MD5, so it is
+	// probed first. Buckets without it answer with an error.
+	lock, err := client.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{Bucket: aws.String(bucket)})
+	if err == nil && lock.ObjectLockConfiguration != nil {
+		caps.ObjectLock = lock.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled
+	}
+
+	put := func(mutate func(*s3.PutObjectInput)) error {
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(body),
+		}
+		if caps.ObjectLock {
+			input.ContentMD5 = aws.String(contentMD5)
+		}
+		if mutate != nil {
+			mutate(input)
+		}
+		_, err := client.PutObject(ctx, input, whenRequired)
+		return err
+	}
+
+	if err := put(nil); err != nil {
+		return caps, fmt.Errorf("failed to upload probe object: %w", err)
+	}
+	defer func() {
+		_, _ = deleter.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	}()
+
+	if err := put(func(in *s3.PutObjectInput) { in.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32 }); err == nil {
+		caps.Checksums = true
+	} else {
+		caps.Errors["checksums"] = err.Error()
+	}
+
+	if err := put(func(in *s3.PutObjectInput) { in.Tagging = aws.String("probe=true") }); err == nil {
+		caps.Tagging = true
+	} else {
+		caps.Errors["tagging"] = err.Error()
+	}
+
+	if err := probeMultipart(ctx, client, bucket, key, body, contentMD5, whenRequired); err == nil {
+		caps.Multipart = true
+	} else {
+		caps.Errors["multipart"] = err.Error()
+	}
+	return caps, nil
+}
+
+// probeMultipart starts a multipart upload, uploads one part and aborts it.
+func probeMultipart(ctx context.Context, client CapabilityAPI, bucket, key string, body []byte, contentMD5 string, optFns ...func(*s3.Options)) error {
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, optFns...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		}, optFns...)
+	}()
+
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   created.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       bytes.NewReader(body),
+		ContentMD5: aws.String(contentMD5),
+	}, optFns...)
+	return err
+}
+
+// Apply adjusts cfg to the capabilities and returns a description of each
+// change: checksums are only sent when required, tags are dropped, uploads
+// to an Object Lock bucket carry Content-MD5, and without multipart uploads
+// backups are spooled to disk and sent in a single request.
+func (c S3Capabilities) Apply(cfg *S3Config) []string {
+	var changes []string
+	if !c.Checksums && !cfg.ChecksumsWhenRequired {
+		cfg.ChecksumsWhenRequired = true
+		changes = append(changes, "checksums are only sent when an API requires them")
+	}
+	if !c.Tagging && len(cfg.Tags) > 0 {
+		cfg.Tags = nil
+		changes = append(changes, "BACKUP_TAGS are not applied")
+	}
+	if c.ObjectLock && !cfg.ObjectLock {
+		cfg.ObjectLock = true
+		changes = append(changes, "uploads are spooled to disk to send Content-MD5 for Object Lock")
+	}
+	if !c.Multipart && !cfg.SinglePut {
+		cfg.SinglePut = true
+		changes = append(changes, "uploads are spooled to disk and sent in a single request of at most 5 GiB")
+	}
+	return changes
+}
+
+// probeS3 probes the endpoint of store and, when it lacks a feature cfg
+// relies on, returns a storage created with the adjusted configuration. The
+// capability report and every adjustment are logged. A failed probe keeps
+// store as configured.
+func probeS3(ctx context.Context, provider string, store *S3Storage, cfg S3Config) (*S3Storage, error) {
+	logger := slog.Default().With("component", "s3-capabilities", "provider", provider)
+	caps, err := store.ProbeCapabilities(ctx)
+	if err != nil {
+		logger.Warn("Failed to probe S3 capabilities, keeping the configured options", "error", err)
+		return store, nil
+	}
+
+	logger.Info("S3 capabilities", "checksums", caps.Checksums, "tagging", caps.Tagging,
+		"object_lock", caps.ObjectLock, "multipart", caps.Multipart)
+	for feature, msg := range caps.Errors {
+		logger.Debug("S3 capability probe failed", "feature", feature, "error", msg)
+	}
+
+	changes := caps.Apply(&cfg)
+	if len(changes) == 0 {
+		return store, nil
+	}
+	for _, change := range changes {
+		logger.Warn("Adjusted S3 options to the endpoint's capabilities", "change", change)
+	}
+	return NewS3Storage(ctx, cfg)
+}
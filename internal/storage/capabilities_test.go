@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeCapabilityAPI rejects the features it is configured without and
+// records the probe's requests.
+type fakeCapabilityAPI struct {
+	noChecksums bool
+	noTagging   bool
+	noMultipart bool
+	objectLock  bool
+	putErr      error
+	puts        []*s3.PutObjectInput
+	deleted     []string
+	aborted     bool
+	partWithMD5 bool
+}
+
+func (f *fakeCapabilityAPI) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.puts = append(f.puts, in)
+	switch {
+	case f.putErr != nil:
+		return nil, f.putErr
+	case f.objectLock && in.ContentMD5 == nil:
+		return nil, errors.New("InvalidRequest: Content-MD5 required")
+	case f.noChecksums && in.ChecksumAlgorithm != "":
+		return nil, errors.New("NotImplemented: checksum")
+	case f.noTagging && in.Tagging != nil:
+		return nil, errors.New("NotImplemented: tagging")
+	}
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeCapabilityAPI) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.deleted = append(f.deleted, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeCapabilityAPI) GetObjectLockConfiguration(ctx context.Context, in *s3.GetObjectLockConfigurationInput, _ ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	if !f.objectLock {
+		return nil, errors.New("ObjectLockConfigurationNotFoundError")
+	}
+	return &s3.GetObjectLockConfigurationOutput{
+		ObjectLockConfiguration: &types.ObjectLockConfiguration{ObjectLockEnabled: types.ObjectLockEnabledEnabled},
+	}, nil
+}
+
+func (f *fakeCapabilityAPI) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if f.noMultipart {
+		return nil, errors.New("NotImplemented: multipart")
+	}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (f *fakeCapabilityAPI) UploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	f.partWithMD5 = in.ContentMD5 != nil
+	return &s3.UploadPartOutput{ETag: aws.String(`"etag"`)}, nil
+}
+
+func (f *fakeCapabilityAPI) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func TestProbeS3Capabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		api         *fakeCapabilityAPI
+		want        S3Capabilities
+		wantErrors  []string
+		wantErr     bool
+		wantDeleted bool
+	}{
+		{
+			name:        "AWS S3",
+			api:         &fakeCapabilityAPI{},
+			want:        S3Capabilities{Checksums: true, Tagging: true, Multipart: true},
+			wantDeleted: true,
+		},
+		{
+			name:        "Ceph RGW without checksums or tagging",
+			api:         &fakeCapabilityAPI{noChecksums: true, noTagging: true},
+			want:        S3Capabilities{Multipart: true},
+			wantErrors:  []string{"checksums", "tagging"},
+			wantDeleted: true,
+		},
+		{
+			name:        "no multipart uploads",
+			api:         &fakeCapabilityAPI{noMultipart: true},
+			want:        S3Capabilities{Checksums: true, Tagging: true},
+			wantErrors:  []string{"multipart"},
+			wantDeleted: true,
+		},
+		{
+			name:        "Object Lock bucket",
+			api:         &fakeCapabilityAPI{objectLock: true},
+			want:        S3Capabilities{Checksums: true, Tagging: true, ObjectLock: true, Multipart: true},
+			wantDeleted: true,
+		},
+		{
+			name:    "upload denied",
+			api:     &fakeCapabilityAPI{putErr: errors.New("AccessDenied")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProbeS3Capabilities(context.Background(), tt.api, tt.api, "backups", "svc/"+CapabilityProbeKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProbeS3Capabilities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(tt.api.deleted) != 0 {
+					t.Errorf("deleted %v after a failed upload, want nothing", tt.api.deleted)
+				}
+				return
+			}
+
+			if got.Checksums != tt.want.Checksums || got.Tagging != tt.want.Tagging ||
+				got.ObjectLock != tt.want.ObjectLock || got.Multipart != tt.want.Multipart {
+				t.Errorf("ProbeS3Capabilities() = %+v, want %+v", got, tt.want)
+			}
+			var features []string
+			for feature := range got.Errors {
+				features = append(features, feature)
+			}
+			slices.Sort(features)
+			if !slices.Equal(features, tt.wantErrors) {
+				t.Errorf("Errors = %v, want %v", features, tt.wantErrors)
+			}
+			if tt.wantDeleted && !slices.Equal(tt.api.deleted, []string{"svc/" + CapabilityProbeKey}) {
+				t.Errorf("deleted = %v, want the probe object", tt.api.deleted)
+			}
+			if got.Multipart && (!tt.api.aborted || !tt.api.partWithMD5) {
+				t.Errorf("multipart probe aborted = %v, part with Content-MD5 = %v, want both", tt.api.aborted, tt.api.partWithMD5)
+			}
+			for _, in := range tt.api.puts {
+				if (in.ContentMD5 != nil) != tt.want.ObjectLock {
+					t.Errorf("PutObject Content-MD5 set = %v, want %v", in.ContentMD5 != nil, tt.want.ObjectLock)
+				}
+			}
+		})
+	}
+}
+
+func TestS3Capabilities_Apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		caps        S3Capabilities
+		cfg         S3Config
+		want        S3Config
+		wantChanges int
+	}{
+		{
+			name: "everything supported",
+			caps: S3Capabilities{Checksums: true, Tagging: true, Multipart: true},
+			cfg:  S3Config{Tags: map[string]string{"env": "prod"}},
+			want: S3Config{Tags: map[string]string{"env": "prod"}},
+		},
+		{
+			name:        "Ceph RGW without checksums or tagging",
+			caps:        S3Capabilities{Multipart: true},
+			cfg:         S3Config{Tags: map[string]string{"env": "prod"}},
+			want:        S3Config{ChecksumsWhenRequired: true},
+			wantChanges: 2,
+		},
+		{
+			name:        "tags not configured",
+			caps:        S3Capabilities{Checksums: true, Multipart: true},
+			want:        S3Config{},
+			wantChanges: 0,
+		},
+		{
+			name:        "Object Lock bucket without multipart uploads",
+			caps:        S3Capabilities{Checksums: true, Tagging: true, ObjectLock: true},
+			want:        S3Config{ObjectLock: true, SinglePut: true},
+			wantChanges: 2,
+		},
+		{
+			name: "already configured",
+			caps: S3Capabilities{ObjectLock: true},
+			cfg:  S3Config{ChecksumsWhenRequired: true, ObjectLock: true, SinglePut: true},
+			want: S3Config{ChecksumsWhenRequired: true, ObjectLock: true, SinglePut: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			changes := tt.caps.Apply(&cfg)
+			if len(changes) != tt.wantChanges {
+				t.Errorf("Apply() changes = %v, want %d", changes, tt.wantChanges)
+			}
+			if cfg.ChecksumsWhenRequired != tt.want.ChecksumsWhenRequired || cfg.ObjectLock != tt.want.ObjectLock ||
+				cfg.SinglePut != tt.want.SinglePut || len(cfg.Tags) != len(tt.want.Tags) {
+				t.Errorf("Apply() config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
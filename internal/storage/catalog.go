@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// CatalogKey names the object indexing every backup, so backups can be found
+// without listing the bucket.
+const CatalogKey = "catalog.json"
+
+// Catalog entry statuses. A backup found by listing the bucket when the catalog
+// was started has not been checked by a run.
+const (
+	CatalogStatusComplete = "complete"
+	CatalogStatusFailed   = "failed"
+	CatalogStatusListed   = "listed"
+)
+
+// Catalog indexes the backups in a storage, oldest first.
+type Catalog struct {
+	UpdatedAt time.Time      `json:"updated_at"`
+	Backups   []CatalogEntry `json:"backups"`
+}
+
+// CatalogEntry describes one backup.
+type CatalogEntry struct {
+	Key       string            `json:"key"`
+	Time      time.Time         `json:"time"`
+	SizeBytes int64             `json:"size_bytes"`
+	SHA256    string            `json:"sha256,omitempty"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+
+	// Recovery is the time the backup took to restore when it was verified
+	Recovery *RecoveryTime `json:"recovery,omitempty"`
+}
+
+// RecoveryTime is how long restoring a backup took, split into the stages of
+// a recovery. The stages overlap while the backup streams through them; each
+// counts the time spent waiting on it, so they add up to the total.
+type RecoveryTime struct {
+	DownloadSeconds   float64 `json:"download_seconds"`
+	DecompressSeconds float64 `json:"decompress_seconds"`
+	RestoreSeconds    float64 `json:"restore_seconds"`
+	TotalSeconds      float64 `json:"total_seconds"`
+}
+
+// Put adds entry to the catalog, replacing any entry with the same key.
+func (c *Catalog) Put(entry CatalogEntry) {
+	c.Remove(entry.Key)
+	c.Backups = append(c.Backups, entry)
+	slices.SortStableFunc(c.Backups, func(a, b CatalogEntry) int {
+		return a.Time.Compare(b.Time)
+	})
+}
+
+// Remove drops the entries of keys. Keys not in the catalog are ignored.
+func (c *Catalog) Remove(keys ...string) {
+	c.Backups = slices.DeleteFunc(c.Backups, func(e CatalogEntry) bool {
+		return slices.Contains(keys, e.Key)
+	})
+}
+
+// Latest returns the newest complete backup whose filename starts with
+// filenamePrefix.
+func (c *Catalog) Latest(filenamePrefix string) (*CatalogEntry, bool) {
+	for i := len(c.Backups) - 1; i >= 0; i-- {
+		e := &c.Backups[i]
+		if e.Status != CatalogStatusFailed && strings.HasPrefix(path.Base(e.Key), filenamePrefix) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Object returns the entry as the listing of its object.
+func (e *CatalogEntry) Object() ObjectInfo {
+	return ObjectInfo{Key: e.Key, Size: e.SizeBytes, LastModified: e.Time, Metadata: e.Metadata}
+}
+
+// ReadCatalog reads the catalog from store.
+func ReadCatalog(ctx context.Context, store Storage) (*Catalog, error) {
+	r, err := store.Open(ctx, CatalogKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var catalog Catalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", CatalogKey, err)
+	}
+	return &catalog, nil
+}
+
+// UpdateCatalog applies update to the catalog in store and writes it back, as
+// one conditional write where the storage supports it. A missing catalog is
+// first built from a listing of the bucket, so it also covers the backups
+// taken before it was enabled.
+func UpdateCatalog(ctx context.Context, store Storage, update func(*Catalog)) error {
+	return updateObject(ctx, store, CatalogKey, func(data []byte) ([]byte, error) {
+		catalog := &Catalog{}
+		if data == nil {
+			var err error
+			if catalog, err = BuildCatalog(ctx, store); err != nil {
+				return nil, err
+			}
+		} else if err := json.Unmarshal(data, catalog); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", CatalogKey, err)
+		}
+
+		update(catalog)
+		catalog.UpdatedAt = time.Now().UTC()
+		return json.MarshalIndent(catalog, "", "  ")
+	})
+}
+
+// BuildCatalog lists the backups in store into a catalog. Backup times come
+// from the filename, falling back to the object's backup-timestamp metadata,
+// read with Stat, and then its last modified time.
+func BuildCatalog(ctx context.Context, store Storage) (*Catalog, error) {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	catalog := &Catalog{Backups: []CatalogEntry{}}
+	for _, obj := range objects {
+		if IsReservedKey(obj.Key) || utils.IsSidecar(obj.Key) {
+			continue
+		}
+		t, err := utils.ParseBackupFilename(path.Base(obj.Key))
+		if err != nil {
+			t = obj.LastModified
+			if info, err := store.Stat(ctx, obj.Key); err == nil {
+				t = BackupTime(*info)
+			}
+		}
+		catalog.Backups = append(catalog.Backups, CatalogEntry{Key: obj.Key, Time: t.UTC(), SizeBytes: obj.Size, Status: CatalogStatusListed})
+	}
+	slices.SortStableFunc(catalog.Backups, func(a, b CatalogEntry) int {
+		return a.Time.Compare(b.Time)
+	})
+	return catalog, nil
+}
+
+// LatestCatalogued returns the newest backup recorded in the catalog, without
+// listing the bucket.
+func LatestCatalogued(ctx context.Context, store Storage, filenamePrefix string) (*ObjectInfo, time.Time, error) {
+	catalog, err := ReadCatalog(ctx, store)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read %s: %w", CatalogKey, err)
+	}
+	entry, ok := catalog.Latest(filenamePrefix)
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("no backups found in %s: %w", CatalogKey, ErrNotFound)
+	}
+	obj := entry.Object()
+	return &obj, entry.Time, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateCatalog(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"conditional", "plain"} {
+		t.Run(name, func(t *testing.T) {
+			fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var store Storage = fs
+			if name == "plain" {
+				store = plainStorage{fs}
+			}
+
+			// Backups taken before the catalog existed are picked up from the listing
+			for _, key := range []string{
+				"2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz",
+				"2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz.sha256",
+				"2025/01/backup-pg16-2025-01-02T00-00-00-000Z.tar.gz",
+				StateKey,
+			} {
+				if err := fs.Upload(ctx, key, strings.NewReader("data"), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			newest := CatalogEntry{Key: "2025/01/backup-pg16-2025-01-03T00-00-00-000Z.tar.gz", Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), SizeBytes: 10, Status: CatalogStatusFailed}
+			if err := UpdateCatalog(ctx, store, func(c *Catalog) { c.Put(newest) }); err != nil {
+				t.Fatalf("UpdateCatalog() error = %v", err)
+			}
+			err = UpdateCatalog(ctx, store, func(c *Catalog) {
+				c.Remove("2025/01/backup-pg16-2025-01-01T00-00-00-000Z.tar.gz")
+			})
+			if err != nil {
+				t.Fatalf("UpdateCatalog() error = %v", err)
+			}
+
+			catalog, err := ReadCatalog(ctx, store)
+			if err != nil {
+				t.Fatalf("ReadCatalog() error = %v", err)
+			}
+			var keys []string
+			for _, e := range catalog.Backups {
+				keys = append(keys, e.Key)
+			}
+			want := "2025/01/backup-pg16-2025-01-02T00-00-00-000Z.tar.gz," + newest.Key
+			if got := strings.Join(keys, ","); got != want {
+				t.Errorf("catalog keys = %s, want %s", got, want)
+			}
+			if catalog.Backups[0].Status != CatalogStatusListed || catalog.UpdatedAt.IsZero() {
+				t.Errorf("catalog = %+v, want the listed backup marked as such", catalog)
+			}
+
+			// Failed backups are never the latest
+			obj, taken, err := LatestCatalogued(ctx, store, "backup")
+			if err != nil || obj.Key != keys[0] || !taken.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("LatestCatalogued() = %v, %v, %v; want %s", obj, taken, err, keys[0])
+			}
+			if _, _, err := LatestCatalogued(ctx, store, "other"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("LatestCatalogued() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestBuildCatalog_ReadsTemplatedKeyTimes(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A templated key carries no timestamp; its metadata does
+	taken := time.Date(2025, 1, 5, 3, 0, 0, 0, time.UTC)
+	metadata := map[string]string{"backup-timestamp": taken.Format(time.RFC3339)}
+	if err := fs.Upload(ctx, "app/nightly.dump", strings.NewReader("data"), metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := BuildCatalog(ctx, fs)
+	if err != nil {
+		t.Fatalf("BuildCatalog() error = %v", err)
+	}
+	if len(catalog.Backups) != 1 || !catalog.Backups[0].Time.Equal(taken) {
+		t.Errorf("BuildCatalog() = %+v, want app/nightly.dump taken at %v", catalog.Backups, taken)
+	}
+}
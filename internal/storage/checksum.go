@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// ErrChecksumMismatch is returned when a backup does not match its stored checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// WriteChecksum records the SHA-256 of the backup at key as a sidecar object in
+// sha256sum format. The backup itself is left untouched: rewriting an object's
+// metadata takes a full copy on S3.
+func WriteChecksum(ctx context.Context, store Storage, key string, sum []byte) error {
+	line := hex.EncodeToString(sum) + "  " + path.Base(key) + "\n"
+	if err := store.Upload(ctx, key+utils.ChecksumSuffix, strings.NewReader(line), nil); err != nil {
+		return fmt.Errorf("failed to store checksum: %w", err)
+	}
+	return nil
+}
+
+// ReadChecksum returns the SHA-256 recorded in the checksum sidecar of key.
+func ReadChecksum(ctx context.Context, store Storage, key string) ([]byte, error) {
+	r, err := store.Open(ctx, key+utils.ChecksumSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(r, 1024))
+	if err != nil {
+		return nil, err
+	}
+	digest, _, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid checksum sidecar for %s", key)
+	}
+	return sum, nil
+}
+
+// OpenVerified opens the backup at key and checks it against its checksum
+// sidecar as it is read: once the whole object has been read, a mismatch is
+// returned by Read in place of io.EOF. Backups without a sidecar, written
+// before checksums were recorded, are opened unverified and verified is false.
+func OpenVerified(ctx context.Context, store Storage, key string) (reader io.ReadCloser, verified bool, err error) {
+	want, sumErr := ReadChecksum(ctx, store, key)
+
+	r, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if sumErr != nil {
+		return r, false, nil
+	}
+	return &verifyingReader{ReadCloser: r, key: key, hash: sha256.New(), want: want}, true, nil
+}
+
+// verifyingReader hashes an object as it is read and compares the result at EOF.
+type verifyingReader struct {
+	io.ReadCloser
+	key  string
+	hash hash.Hash
+	want []byte
+}
+
+// Read implements io.Reader.
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF {
+		if got := v.hash.Sum(nil); !bytes.Equal(got, v.want) {
+			return n, fmt.Errorf("%w for %s: read sha256 %x, stored %x", ErrChecksumMismatch, v.key, got, v.want)
+		}
+	}
+	return n, err
+}
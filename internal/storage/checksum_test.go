@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChecksum_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	data := "backup data"
+	key := "2024/01/backup.tar.gz"
+	if err := fs.Upload(ctx, key, strings.NewReader(data), map[string]string{"database": "app"}); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(data))
+	if err := WriteChecksum(ctx, fs, key, sum[:]); err != nil {
+		t.Fatalf("WriteChecksum() error = %v", err)
+	}
+
+	sidecar, err := fs.Open(ctx, key+".sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _ := io.ReadAll(sidecar)
+	_ = sidecar.Close()
+	if !strings.HasSuffix(string(line), "  backup.tar.gz\n") {
+		t.Errorf("sidecar = %q, want sha256sum format", line)
+	}
+	fullPath, err := fs.getFullPath(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The backup is not rewritten to carry the checksum
+	if md := readMetadata(fullPath); len(md) != 1 || md["database"] != "app" {
+		t.Errorf("metadata = %v, want the backup's own metadata untouched", md)
+	}
+
+	tests := []struct {
+		name         string
+		content      string
+		wantVerified bool
+		wantErr      error
+	}{
+		{name: "intact backup", content: data, wantVerified: true},
+		{name: "altered backup", content: "backup dat4", wantVerified: true, wantErr: ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(fullPath, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			r, verified, err := OpenVerified(ctx, fs, key)
+			if err != nil {
+				t.Fatalf("OpenVerified() error = %v", err)
+			}
+			defer func() {
+				_ = r.Close()
+			}()
+			if verified != tt.wantVerified {
+				t.Errorf("OpenVerified() verified = %v, want %v", verified, tt.wantVerified)
+			}
+			_, err = io.ReadAll(r)
+			if (tt.wantErr == nil && err != nil) || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadAll() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("backup without sidecar", func(t *testing.T) {
+		if err := fs.Upload(ctx, "old.tar.gz", strings.NewReader(data), nil); err != nil {
+			t.Fatal(err)
+		}
+		r, verified, err := OpenVerified(ctx, fs, "old.tar.gz")
+		if err != nil || verified {
+			t.Fatalf("OpenVerified() = %v, %v; want unverified reader", verified, err)
+		}
+		_ = r.Close()
+	})
+}
+
+func TestCopySource(t *testing.T) {
+	if got := copySource("backups", "db/2024/01/a b+c.tar.gz"); got != "backups/db/2024/01/a%20b+c.tar.gz" {
+		t.Errorf("copySource() = %s", got)
+	}
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// clockResolution is the coarsest last modified time kept by the providers;
+// S3 truncates it to the second.
+const clockResolution = time.Second
+
+// ClockSkew returns how far the local clock is ahead of the storage
+// provider's, negative when it is behind, judged from the last modified time
+// of an object written between start and end by the local clock. Offsets
+// within the write and the providers' resolution count as no skew.
+func ClockSkew(lastModified, start, end time.Time) time.Duration {
+	switch {
+	case lastModified.Before(start.Add(-clockResolution)):
+		return start.Sub(lastModified)
+	case lastModified.After(end.Add(clockResolution)):
+		return end.Sub(lastModified)
+	}
+	return 0
+}
+
+// MeasureClockSkew returns the ClockSkew of the object stored under key,
+// written between start and end. Use a small object written in a single
+// request: multipart uploads are dated when they start.
+func MeasureClockSkew(ctx context.Context, store Storage, key string, start, end time.Time) (time.Duration, error) {
+	objects, err := store.List(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %w", key, err)
+	}
+	for _, obj := range objects {
+		if obj.Key == key {
+			return ClockSkew(obj.LastModified, start, end), nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not listed after upload: %w", key, ErrNotFound)
+}
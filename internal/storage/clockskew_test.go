@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockSkew(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Second)
+
+	tests := []struct {
+		name         string
+		lastModified time.Time
+		want         time.Duration
+	}{
+		{"during the write", start.Add(time.Second), 0},
+		{"truncated to the second", start.Add(-500 * time.Millisecond), 0},
+		{"local clock ahead", start.Add(-5 * time.Minute), 5 * time.Minute},
+		{"local clock behind", end.Add(90 * time.Second), -90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClockSkew(tt.lastModified, start, end); got != tt.want {
+				t.Errorf("ClockSkew() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeasureClockSkew(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	store := NewPrefixedStorage(fs, "db/")
+
+	start := time.Now()
+	if err := store.Upload(ctx, "marker.txt", strings.NewReader("marker"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	skew, err := MeasureClockSkew(ctx, store, "marker.txt", start, time.Now())
+	if err != nil || skew != 0 {
+		t.Errorf("MeasureClockSkew() = %v, %v; want no skew", skew, err)
+	}
+
+	if _, err := MeasureClockSkew(ctx, store, "missing.txt", start, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MeasureClockSkew() of a missing object error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	// ErrNotFound is returned by conditional operations on a missing object.
+	ErrNotFound = errors.New("object not found")
+
+	// ErrPreconditionFailed is returned when an object changed since it was read.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// ConditionalStorage is implemented by storages that can replace an object only
+// if nobody else has replaced it since it was read, which makes it safe for
+// several instances to coordinate through a single object.
+type ConditionalStorage interface {
+	// ReadVersion returns the content of key and an opaque version, or ErrNotFound.
+	ReadVersion(ctx context.Context, key string) ([]byte, string, error)
+
+	// WriteIfVersion writes data to key if its current version is version, or if
+	// it does not exist when version is empty, and returns the new version.
+	// ErrPreconditionFailed is returned when the condition does not hold.
+	WriteIfVersion(ctx context.Context, key string, data []byte, version string) (string, error)
+
+	// DeleteIfVersion removes key if its current version is version.
+	DeleteIfVersion(ctx context.Context, key, version string) error
+}
+
+// AsConditional returns the conditional operations of store: those of the first
+// destination of a MultiStorage, scoped to the prefix of a PrefixedStorage.
+// Conditional operations are never retried, as a retried write that had
+// succeeded would fail its own precondition.
+func AsConditional(store Storage) (ConditionalStorage, bool) {
+	switch s := store.(type) {
+	case *RetryableStorage:
+		return AsConditional(s.storage)
+	case *CachedStorage:
+		return AsConditional(s.remote)
+	case *MultiStorage:
+		if len(s.destinations) == 0 {
+			return nil, false
+		}
+		return AsConditional(s.destinations[0].Storage)
+	case *PrefixedStorage:
+		inner, ok := AsConditional(s.storage)
+		if !ok {
+			return nil, false
+		}
+		return &prefixedConditional{storage: inner, prefix: s.prefix}, true
+	case *FaultyStorage:
+		inner, ok := AsConditional(s.storage)
+		if !ok {
+			return nil, false
+		}
+		return &faultyConditional{storage: inner, faults: s}, true
+	case ConditionalStorage:
+		return s, true
+	}
+	return nil, false
+}
+
+// prefixedConditional scopes conditional operations to the keys under a prefix.
+type prefixedConditional struct {
+	storage ConditionalStorage
+	prefix  string
+}
+
+func (p *prefixedConditional) ReadVersion(ctx context.Context, key string) ([]byte, string, error) {
+	return p.storage.ReadVersion(ctx, p.prefix+key)
+}
+
+func (p *prefixedConditional) WriteIfVersion(ctx context.Context, key string, data []byte, version string) (string, error) {
+	return p.storage.WriteIfVersion(ctx, p.prefix+key, data, version)
+}
+
+func (p *prefixedConditional) DeleteIfVersion(ctx context.Context, key, version string) error {
+	return p.storage.DeleteIfVersion(ctx, p.prefix+key, version)
+}
+
+// ConditionalAPI is the subset of the S3 client used for conditional requests.
+type ConditionalAPI interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// ReadVersion implements ConditionalStorage, using the ETag as the version.
+func (s *S3Storage) ReadVersion(ctx context.Context, key string) ([]byte, string, error) {
+	return s3ReadVersion(ctx, s.client, s.bucket, s.getFullKey(key))
+}
+
+// WriteIfVersion implements ConditionalStorage with If-Match and If-None-Match.
+func (s *S3Storage) WriteIfVersion(ctx context.Context, key string, data []byte, version string) (string, error) {
+	return s3WriteIfVersion(ctx, s.client, s.bucket, s.getFullKey(key), data, version, s.objectLock)
+}
+
+// DeleteIfVersion implements ConditionalStorage with If-Match.
+func (s *S3Storage) DeleteIfVersion(ctx context.Context, key, version string) error {
+	return s3DeleteIfVersion(ctx, s.client, s.bucket, s.getFullKey(key), version)
+}
+
+func s3ReadVersion(ctx context.Context, client ConditionalAPI, bucket, key string) ([]byte, string, error) {
+	resp, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) || httpStatus(err) == http.StatusNotFound {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to read S3 object: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read S3 object: %w", err)
+	}
+	return data, aws.ToString(resp.ETag), nil
+}
+
+func s3WriteIfVersion(ctx context.Context, client ConditionalAPI, bucket, key string, data []byte, version string, objectLock bool) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if version == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(version)
+	}
+	if objectLock {
+		sum := md5.Sum(data)
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	resp, err := client.PutObject(ctx, input)
+	if err != nil {
+		if preconditionFailed(err) {
+			return "", ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("failed to write S3 object: %w", err)
+	}
+	return aws.ToString(resp.ETag), nil
+}
+
+func s3DeleteIfVersion(ctx context.Context, client ConditionalAPI, bucket, key, version string) error {
+	_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		IfMatch: aws.String(version),
+	})
+	if err != nil {
+		if httpStatus(err) == http.StatusNotFound {
+			return ErrNotFound
+		}
+		if preconditionFailed(err) {
+			return ErrPreconditionFailed
+		}
+		return fmt.Errorf("failed to delete S3 object: %w", err)
+	}
+	return nil
+}
+
+// preconditionFailed reports whether a request was rejected by its condition.
+// S3 answers 409 when a concurrent conditional write to the same key won.
+func preconditionFailed(err error) bool {
+	status := httpStatus(err)
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
+
+// httpStatus returns the HTTP status code carried by an SDK error, or 0.
+func httpStatus(err error) int {
+	var resp interface{ HTTPStatusCode() int }
+	if errors.As(err, &resp) {
+		return resp.HTTPStatusCode()
+	}
+	return 0
+}
+
+// ReadVersion implements ConditionalStorage, using the generation as the version.
+func (g *GCSStorage) ReadVersion(ctx context.Context, key string) ([]byte, string, error) {
+	r, err := g.client.Bucket(g.bucket).Object(g.getFullKey(key)).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to read GCS object: %w", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read GCS object: %w", err)
+	}
+	return data, strconv.FormatInt(r.Attrs.Generation, 10), nil
+}
+
+// WriteIfVersion implements ConditionalStorage with generation preconditions.
+func (g *GCSStorage) WriteIfVersion(ctx context.Context, key string, data []byte, version string) (string, error) {
+	cond, err := gcsConditions(version)
+	if err != nil {
+		return "", err
+	}
+
+	w := g.client.Bucket(g.bucket).Object(g.getFullKey(key)).If(cond).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return "", fmt.Errorf("failed to write GCS object: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		if gcsPreconditionFailed(err) {
+			return "", ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("failed to write GCS object: %w", err)
+	}
+	return strconv.FormatInt(w.Attrs().Generation, 10), nil
+}
+
+// DeleteIfVersion implements ConditionalStorage with a generation precondition.
+func (g *GCSStorage) DeleteIfVersion(ctx context.Context, key, version string) error {
+	cond, err := gcsConditions(version)
+	if err != nil {
+		return err
+	}
+
+	if err := g.client.Bucket(g.bucket).Object(g.getFullKey(key)).If(cond).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrNotFound
+		}
+		if gcsPreconditionFailed(err) {
+			return ErrPreconditionFailed
+		}
+		return fmt.Errorf("failed to delete GCS object: %w", err)
+	}
+	return nil
+}
+
+// gcsConditions maps a version to generation preconditions.
+func gcsConditions(version string) (storage.Conditions, error) {
+	if version == "" {
+		return storage.Conditions{DoesNotExist: true}, nil
+	}
+	generation, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return storage.Conditions{}, fmt.Errorf("invalid GCS generation %q: %w", version, err)
+	}
+	return storage.Conditions{GenerationMatch: generation}, nil
+}
+
+// gcsPreconditionFailed reports whether GCS rejected a request by its condition.
+func gcsPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
+
+// ReadVersion implements ConditionalStorage, using a content hash as the version.
+func (f *FilesystemStorage) ReadVersion(ctx context.Context, key string) ([]byte, string, error) {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to read from filesystem: %w", err)
+	}
+	return data, contentVersion(data), nil
+}
+
+// WriteIfVersion implements ConditionalStorage. Creation is atomic through a
+// hard link. A replacement is compared and renamed under a lock held by this
+// storage, so it is only race-free between instances sharing the process.
+func (f *FilesystemStorage) WriteIfVersion(ctx context.Context, key string, data []byte, version string) (string, error) {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if version != "" {
+		if err := f.checkVersion(fullPath, version); err != nil {
+			return "", err
+		}
+		if err := writeFileAtomic(ctx, fullPath, bytes.NewReader(data)); err != nil {
+			return "", fmt.Errorf("failed to write to filesystem: %w", err)
+		}
+		return contentVersion(data), nil
+	}
+
+	// Write a temp file and link it into place, which fails if the key exists
+	tmp := filepath.Join(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".tmp-"+strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return "", fmt.Errorf("failed to write to filesystem: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp)
+	}()
+	if err := os.Link(tmp, fullPath); err != nil {
+		if os.IsExist(err) {
+			return "", ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("failed to write to filesystem: %w", err)
+	}
+	return contentVersion(data), nil
+}
+
+// DeleteIfVersion implements ConditionalStorage.
+func (f *FilesystemStorage) DeleteIfVersion(ctx context.Context, key, version string) error {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkVersion(fullPath, version); err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil {
+		return fmt.Errorf("failed to delete from filesystem: %w", err)
+	}
+	return nil
+}
+
+// checkVersion verifies the file at fullPath still has the given version.
+func (f *FilesystemStorage) checkVersion(fullPath, version string) error {
+	current, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to read from filesystem: %w", err)
+	}
+	if contentVersion(current) != version {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// contentVersion identifies file content for conditional writes.
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
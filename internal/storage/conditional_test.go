@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// statusError mimics an SDK response error carrying an HTTP status code.
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("http %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+// fakeConditionalAPI stores objects in memory and honours If-Match and If-None-Match
+// the way S3 does, using a counter as the ETag.
+type fakeConditionalAPI struct {
+	objects map[string][]byte
+	etags   map[string]string
+	next    int
+}
+
+func newFakeConditionalAPI() *fakeConditionalAPI {
+	return &fakeConditionalAPI{objects: map[string][]byte{}, etags: map[string]string{}}
+}
+
+func (f *fakeConditionalAPI) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ETag: aws.String(f.etags[aws.ToString(in.Key)])}, nil
+}
+
+func (f *fakeConditionalAPI) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	etag, exists := f.etags[key]
+	if in.IfNoneMatch != nil && exists {
+		return nil, statusError(412)
+	}
+	if in.IfMatch != nil && (!exists || aws.ToString(in.IfMatch) != etag) {
+		return nil, statusError(412)
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.next++
+	f.objects[key] = data
+	f.etags[key] = fmt.Sprintf(`"%d"`, f.next)
+	return &s3.PutObjectOutput{ETag: aws.String(f.etags[key])}, nil
+}
+
+func (f *fakeConditionalAPI) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	etag, exists := f.etags[key]
+	if !exists {
+		return nil, statusError(404)
+	}
+	if in.IfMatch != nil && aws.ToString(in.IfMatch) != etag {
+		return nil, statusError(412)
+	}
+	delete(f.objects, key)
+	delete(f.etags, key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Conditional(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeConditionalAPI()
+
+	if _, _, err := s3ReadVersion(ctx, api, "bucket", "lock.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("s3ReadVersion() error = %v, want ErrNotFound", err)
+	}
+
+	v1, err := s3WriteIfVersion(ctx, api, "bucket", "lock.json", []byte("a"), "", false)
+	if err != nil {
+		t.Fatalf("create error = %v", err)
+	}
+	if _, err := s3WriteIfVersion(ctx, api, "bucket", "lock.json", []byte("b"), "", false); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("second create error = %v, want ErrPreconditionFailed", err)
+	}
+
+	v2, err := s3WriteIfVersion(ctx, api, "bucket", "lock.json", []byte("b"), v1, true)
+	if err != nil {
+		t.Fatalf("replace error = %v", err)
+	}
+	if _, err := s3WriteIfVersion(ctx, api, "bucket", "lock.json", []byte("c"), v1, false); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("stale replace error = %v, want ErrPreconditionFailed", err)
+	}
+
+	data, version, err := s3ReadVersion(ctx, api, "bucket", "lock.json")
+	if err != nil || string(data) != "b" || version != v2 {
+		t.Errorf("s3ReadVersion() = %q, %q, %v; want %q, %q", data, version, err, "b", v2)
+	}
+
+	if err := s3DeleteIfVersion(ctx, api, "bucket", "lock.json", v1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("stale delete error = %v, want ErrPreconditionFailed", err)
+	}
+	if err := s3DeleteIfVersion(ctx, api, "bucket", "lock.json", v2); err != nil {
+		t.Errorf("delete error = %v", err)
+	}
+	if err := s3DeleteIfVersion(ctx, api, "bucket", "lock.json", v2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete of missing object error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFilesystemConditional(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	v1, err := fs.WriteIfVersion(ctx, "db/lock.json", []byte("a"), "")
+	if err != nil {
+		t.Fatalf("create error = %v", err)
+	}
+	if _, err := fs.WriteIfVersion(ctx, "db/lock.json", []byte("b"), ""); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("second create error = %v, want ErrPreconditionFailed", err)
+	}
+
+	v2, err := fs.WriteIfVersion(ctx, "db/lock.json", []byte("b"), v1)
+	if err != nil {
+		t.Fatalf("replace error = %v", err)
+	}
+	if _, err := fs.WriteIfVersion(ctx, "db/lock.json", []byte("c"), v1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("stale replace error = %v, want ErrPreconditionFailed", err)
+	}
+
+	// Temp files used for the atomic create are not left behind
+	objects, err := fs.List(ctx, "")
+	if err != nil || len(objects) != 1 || objects[0].Key != "db/lock.json" {
+		t.Errorf("List() = %v, %v; want only db/lock.json", objects, err)
+	}
+
+	if err := fs.DeleteIfVersion(ctx, "db/lock.json", v1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("stale delete error = %v, want ErrPreconditionFailed", err)
+	}
+	if err := fs.DeleteIfVersion(ctx, "db/lock.json", v2); err != nil {
+		t.Errorf("delete error = %v", err)
+	}
+	if _, _, err := fs.ReadVersion(ctx, "db/lock.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadVersion() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAsConditional(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	multi := NewMultiStorage([]Destination{
+		{Name: "filesystem", Storage: NewRetryableStorage(fs, DefaultRetryConfig())},
+		{Name: "other", Storage: &mockStorage{}},
+	}, true, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	store, ok := AsConditional(NewPrefixedStorage(multi, "orders"))
+	if !ok {
+		t.Fatal("AsConditional() = false, want the first destination")
+	}
+	if _, err := store.WriteIfVersion(ctx, LeaseKey, []byte("{}"), ""); err != nil {
+		t.Fatalf("WriteIfVersion() error = %v", err)
+	}
+	if _, _, err := fs.ReadVersion(ctx, "orders/"+LeaseKey); err != nil {
+		t.Errorf("lease not written under the prefix: %v", err)
+	}
+
+	if _, ok := AsConditional(&mockStorage{}); ok {
+		t.Error("AsConditional() = true for a storage without conditional writes")
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeCopier records the server-side copy requests made by s3Copy.
+type fakeCopier struct {
+	size      int64
+	failPart  int32 // Part number whose copy fails (0 = never)
+	copied    *s3.CopyObjectInput
+	created   *s3.CreateMultipartUploadInput
+	ranges    []string
+	completed []types.CompletedPart
+	aborted   bool
+}
+
+func (f *fakeCopier) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(f.size),
+		ContentType:   aws.String("application/gzip"),
+		Metadata:      map[string]string{"backup-tool": "railway-postgres-backup"},
+	}, nil
+}
+
+func (f *fakeCopier) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.copied = params
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeCopier) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.created = params
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (f *fakeCopier) UploadPartCopy(ctx context.Context, params *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	if aws.ToInt32(params.PartNumber) == f.failPart {
+		return nil, errors.New("internal error")
+	}
+	f.ranges = append(f.ranges, aws.ToString(params.CopySourceRange))
+	return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{ETag: aws.String("etag")}}, nil
+}
+
+func (f *fakeCopier) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.completed = params.MultipartUpload.Parts
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeCopier) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func TestS3Copy(t *testing.T) {
+	ctx := context.Background()
+	const gib = 1024 * 1024 * 1024
+
+	t.Run("single request", func(t *testing.T) {
+		fake := &fakeCopier{size: 1024}
+		if err := s3Copy(ctx, fake, "bucket", "db/2024 01/backup.tar.gz", "db/trash/backup.tar.gz", ""); err != nil {
+			t.Fatalf("s3Copy() error = %v", err)
+		}
+		if fake.copied == nil || fake.created != nil {
+			t.Fatalf("small object was not copied with CopyObject")
+		}
+		if got := aws.ToString(fake.copied.CopySource); got != "bucket/db/2024%2001/backup.tar.gz" {
+			t.Errorf("CopySource = %q, want the escaped source", got)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		fake := &fakeCopier{size: 6*gib + 1}
+		if err := s3Copy(ctx, fake, "bucket", "backup.tar.gz", "copy.tar.gz", "env=prod"); err != nil {
+			t.Fatalf("s3Copy() error = %v", err)
+		}
+		if fake.copied != nil || fake.created == nil {
+			t.Fatalf("large object was not copied part by part")
+		}
+		if fake.created.Metadata["backup-tool"] == "" || aws.ToString(fake.created.Tagging) != "env=prod" {
+			t.Errorf("multipart copy = %+v, want the source metadata and the tags", fake.created)
+		}
+		if len(fake.ranges) != 13 || len(fake.completed) != 13 {
+			t.Fatalf("copied %d parts, completed %d, want 13", len(fake.ranges), len(fake.completed))
+		}
+		if fake.ranges[0] != "bytes=0-536870911" || fake.ranges[12] != "bytes=6442450944-6442450944" {
+			t.Errorf("ranges = %q ... %q", fake.ranges[0], fake.ranges[12])
+		}
+	})
+
+	t.Run("failed part aborts the upload", func(t *testing.T) {
+		fake := &fakeCopier{size: 6 * gib, failPart: 3}
+		if err := s3Copy(ctx, fake, "bucket", "backup.tar.gz", "copy.tar.gz", ""); err == nil {
+			t.Fatal("s3Copy() expected an error")
+		}
+		if !fake.aborted || fake.completed != nil {
+			t.Errorf("aborted = %v, completed = %v; want the upload aborted", fake.aborted, fake.completed)
+		}
+	})
+}
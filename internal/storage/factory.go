@@ -2,8 +2,10 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"time"
 
 	"github.com/imedwei/railway-postgres-backup/internal/config"
@@ -48,6 +50,28 @@ func (r *RetryableStorage) Upload(ctx context.Context, key string, reader io.Rea
 	})
 }
 
+// Open implements Storage.Open with retry logic.
+func (r *RetryableStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	var result io.ReadCloser
+	err := r.retry(ctx, func() error {
+		var err error
+		result, err = r.storage.Open(ctx, key)
+		return err
+	})
+	return result, err
+}
+
+// OpenRange implements Storage.OpenRange with retry logic.
+func (r *RetryableStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	var result io.ReadCloser
+	err := r.retry(ctx, func() error {
+		var err error
+		result, err = r.storage.OpenRange(ctx, key, offset, length)
+		return err
+	})
+	return result, err
+}
+
 // Delete implements Storage.Delete with retry logic.
 func (r *RetryableStorage) Delete(ctx context.Context, key string) error {
 	return r.retry(ctx, func() error {
@@ -55,6 +79,33 @@ func (r *RetryableStorage) Delete(ctx context.Context, key string) error {
 	})
 }
 
+// Copy implements Storage.Copy with retry logic.
+func (r *RetryableStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return r.retry(ctx, func() error {
+		return r.storage.Copy(ctx, srcKey, dstKey)
+	})
+}
+
+// Stat implements Storage.Stat with retry logic. A missing object is
+// reported without retrying.
+func (r *RetryableStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	var result *ObjectInfo
+	var missing error
+	err := r.retry(ctx, func() error {
+		var err error
+		result, err = r.storage.Stat(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			missing = err
+			return nil
+		}
+		return err
+	})
+	if missing != nil {
+		return nil, missing
+	}
+	return result, err
+}
+
 // List implements Storage.List with retry logic.
 func (r *RetryableStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
 	var result []ObjectInfo
@@ -77,7 +128,8 @@ func (r *RetryableStorage) GetLastBackupTime(ctx context.Context) (time.Time, er
 	return result, err
 }
 
-// retry executes a function with exponential backoff retry logic.
+// retry executes a function with exponential backoff retry logic. A full
+// destination is reported as ErrQuotaExceeded without retrying.
 func (r *RetryableStorage) retry(ctx context.Context, fn func() error) error {
 	delay := r.config.InitialDelay
 
@@ -87,6 +139,10 @@ func (r *RetryableStorage) retry(ctx context.Context, fn func() error) error {
 			return nil
 		}
 
+		if err := classifyQuota(err); errors.Is(err, ErrQuotaExceeded) {
+			return err
+		}
+
 		// Check if this is the last attempt
 		if attempt == r.config.MaxAttempts {
 			return fmt.Errorf("operation failed after %d attempts: %w", r.config.MaxAttempts, err)
@@ -110,28 +166,79 @@ func (r *RetryableStorage) retry(ctx context.Context, fn func() error) error {
 }
 
 // NewStorage creates a storage provider based on configuration.
+// When several providers are configured, they are combined into a MultiStorage,
+// and with LOCAL_CACHE_PATH set, backups are mirrored to a local cache as well.
 func NewStorage(ctx context.Context, cfg *config.Config) (Storage, error) {
+	store, err := newDestinations(ctx, cfg)
+	if err != nil || cfg.LocalCachePath == "" {
+		return store, err
+	}
+
+	cache, err := NewFilesystemStorage(FilesystemConfig{Path: cfg.LocalCachePath})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local cache: %w", err)
+	}
+	logger := slog.Default().With("component", "local-cache")
+	retention := CacheRetention{
+		Keep:     cfg.LocalCacheKeep,
+		MaxBytes: int64(cfg.LocalCacheMaxSizeMB) * 1024 * 1024,
+	}
+	return NewCachedStorage(store, cache, retention, logger), nil
+}
+
+// newDestinations creates the configured remote storage providers.
+func newDestinations(ctx context.Context, cfg *config.Config) (Storage, error) {
+	providers := cfg.StorageProviders()
+	if len(providers) == 1 {
+		return newProvider(ctx, providers[0], cfg)
+	}
+
+	destinations := make([]Destination, 0, len(providers))
+	for _, provider := range providers {
+		s, err := newProvider(ctx, provider, cfg)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, Destination{
+			Name:          provider,
+			Storage:       s,
+			RetentionDays: cfg.GetRetentionDays(provider),
+		})
+	}
+
+	logger := slog.Default().With("component", "multi-storage")
+	return NewMultiStorage(destinations, cfg.RequireAllDestinations, logger), nil
+}
+
+// newProvider creates a single storage provider wrapped with retry logic.
+func newProvider(ctx context.Context, provider string, cfg *config.Config) (Storage, error) {
 	var storage Storage
 	var err error
 
-	switch cfg.StorageProvider {
-	case "s3":
-		s3Config := S3Config{
-			AccessKeyID:     cfg.AWSAccessKeyID,
-			SecretAccessKey: cfg.AWSSecretAccessKey,
-			Region:          cfg.S3Region,
-			Bucket:          cfg.S3Bucket,
-			Endpoint:        cfg.S3Endpoint,
-			Prefix:          cfg.BackupFilePrefix,
-			ObjectLock:      false,                // Could be made configurable
-			UsePathStyle:    cfg.S3Endpoint != "", // Use path style for custom endpoints
+	switch provider {
+	case "s3", "r2", "spaces", "wasabi":
+		s3Config, cfgErr := newS3Config(provider, cfg)
+		if cfgErr != nil {
+			return nil, cfgErr
+		}
+		var s3Storage *S3Storage
+		s3Storage, err = NewS3Storage(ctx, s3Config)
+		if err == nil && cfg.S3ProbeCapabilities {
+			s3Storage, err = probeS3(ctx, provider, s3Storage, s3Config)
 		}
-		storage, err = NewS3Storage(ctx, s3Config)
+		storage = s3Storage
 
 	case "gcs":
-		// Validate service account JSON
-		if err := ValidateServiceAccountJSON(cfg.GoogleServiceAccountJSON); err != nil {
-			return nil, fmt.Errorf("invalid GCS service account: %w", err)
+		// Validate service account JSON; without it the client uses Application Default Credentials
+		if cfg.GoogleServiceAccountJSON != "" {
+			if err := ValidateServiceAccountJSON(cfg.GoogleServiceAccountJSON); err != nil {
+				return nil, fmt.Errorf("invalid GCS service account: %w", err)
+			}
+		}
+		if cfg.GoogleDeleteServiceAccountJSON != "" {
+			if err := ValidateServiceAccountJSON(cfg.GoogleDeleteServiceAccountJSON); err != nil {
+				return nil, fmt.Errorf("invalid GCS delete service account: %w", err)
+			}
 		}
 
 		gcsConfig := GCSConfig{
@@ -139,17 +246,90 @@ func NewStorage(ctx context.Context, cfg *config.Config) (Storage, error) {
 			ProjectID:          cfg.GoogleProjectID,
 			ServiceAccountJSON: cfg.GoogleServiceAccountJSON,
 			Prefix:             cfg.BackupFilePrefix,
+			Location:           cfg.GCSLocation,
+			VerifyCRC32C:       cfg.UploadIntegrityCheck,
+
+			DeleteServiceAccountJSON: cfg.GoogleDeleteServiceAccountJSON,
 		}
 		storage, err = NewGCSStorage(ctx, gcsConfig)
 
+	case "filesystem":
+		fsConfig := FilesystemConfig{
+			Path:   cfg.FilesystemPath,
+			Prefix: cfg.BackupFilePrefix,
+		}
+		storage, err = NewFilesystemStorage(fsConfig)
+
+	case "rclone":
+		storage, err = NewRcloneStorage(RcloneConfig{
+			Remote: cfg.RcloneRemote,
+			Binary: cfg.RcloneBinary,
+			Prefix: cfg.BackupFilePrefix,
+		})
+
 	default:
-		return nil, fmt.Errorf("unsupported storage provider: %s", cfg.StorageProvider)
+		return nil, fmt.Errorf("unsupported storage provider: %s", provider)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create %s storage: %w", cfg.StorageProvider, err)
+		return nil, fmt.Errorf("failed to create %s storage: %w", provider, err)
+	}
+
+	if cfg.CreateBucketIfMissing {
+		if creator, ok := storage.(BucketCreator); ok {
+			created, err := creator.EnsureBucket(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if created {
+				slog.Default().Info("Created missing bucket", "provider", provider)
+			}
+		}
+	}
+
+	// Faults are injected beneath the retries, which absorb them like real provider errors
+	if cfg.FaultInjection() {
+		storage = NewFaultyStorage(storage, FaultConfig{
+			ReadPercent:   cfg.FaultReadPercent,
+			UploadPercent: cfg.FaultUploadPercent,
+			DeletePercent: cfg.FaultDeletePercent,
+			Seed:          uint64(cfg.FaultSeed),
+		}, slog.Default().With("component", "fault-injection", "provider", provider))
 	}
 
 	// Wrap with retry logic
 	return NewRetryableStorage(storage, DefaultRetryConfig()), nil
 }
+
+// newS3Config builds the client settings for an S3-compatible provider,
+// applying the quirks of presets such as R2.
+func newS3Config(provider string, cfg *config.Config) (S3Config, error) {
+	tags, err := cfg.GetBackupTags()
+	if err != nil {
+		return S3Config{}, fmt.Errorf("invalid BACKUP_TAGS: %w", err)
+	}
+
+	endpoint := cfg.GetS3Endpoint(provider)
+	return S3Config{
+		AccessKeyID:     cfg.AWSAccessKeyID,
+		SecretAccessKey: cfg.AWSSecretAccessKey,
+		Region:          cfg.GetS3Region(provider),
+		Bucket:          cfg.S3Bucket,
+		Endpoint:        endpoint,
+		Prefix:          cfg.BackupFilePrefix,
+		ObjectLock:      false,          // Could be made configurable
+		UsePathStyle:    endpoint != "", // Use path style for custom endpoints
+		Tags:            tags,
+		TempDir:         cfg.TempDir,
+		PartSize:        int64(cfg.S3UploadPartSizeMB) * 1024 * 1024,
+		Concurrency:     cfg.S3UploadConcurrency,
+		Threshold:       int64(cfg.S3MultipartThresholdMB) * 1024 * 1024,
+		VerifyETags:     cfg.UploadIntegrityCheck,
+
+		DeleteAccessKeyID:     cfg.AWSDeleteAccessKeyID,
+		DeleteSecretAccessKey: cfg.AWSDeleteSecretAccessKey,
+
+		// S3-compatible services lag behind the SDK's default checksum behaviour
+		ChecksumsWhenRequired: provider != "s3",
+	}, nil
+}
@@ -3,17 +3,30 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/config"
 )
 
 // mockStorage is a mock implementation for testing retry logic.
 type mockStorage struct {
 	uploadCalls int
 	uploadErr   error
+	openCalls   int
+	openErr     error
 	deleteCalls int
 	deleteErr   error
+	copyCalls   int
+	copyErr     error
+	statCalls   int
 	listCalls   int
 	listErr     error
 	listResult  []ObjectInfo
@@ -27,11 +40,46 @@ func (m *mockStorage) Upload(ctx context.Context, key string, reader io.Reader,
 	return m.uploadErr
 }
 
+func (m *mockStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.openCalls++
+	if m.openErr != nil {
+		return nil, m.openErr
+	}
+	return io.NopCloser(strings.NewReader("backup")), nil
+}
+
+func (m *mockStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	r, err := m.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.CopyN(io.Discard, r, offset)
+	return limitReadCloser(r, length), nil
+}
+
 func (m *mockStorage) Delete(ctx context.Context, key string) error {
 	m.deleteCalls++
 	return m.deleteErr
 }
 
+func (m *mockStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	m.copyCalls++
+	return m.copyErr
+}
+
+func (m *mockStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	m.statCalls++
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	for _, obj := range m.listResult {
+		if obj.Key == key {
+			return &obj, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+}
+
 func (m *mockStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
 	m.listCalls++
 	return m.listResult, m.listErr
@@ -64,6 +112,13 @@ func TestRetryableStorage_Upload(t *testing.T) {
 			wantCalls:   3,
 			wantErr:     true,
 		},
+		{
+			name:        "full disk is not retried",
+			uploadErr:   &os.PathError{Op: "write", Path: "/backups/x", Err: syscall.ENOSPC},
+			maxAttempts: 3,
+			wantCalls:   1,
+			wantErr:     true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -89,6 +144,78 @@ func TestRetryableStorage_Upload(t *testing.T) {
 	}
 }
 
+func TestRetryableStorage_Stat(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 2.0}
+	ctx := context.Background()
+
+	mock := &mockStorage{listResult: []ObjectInfo{{Key: "a.tar.gz", Size: 4}}}
+	info, err := NewRetryableStorage(mock, config).Stat(ctx, "a.tar.gz")
+	if err != nil || info.Size != 4 {
+		t.Fatalf("Stat() = %+v, %v; want a.tar.gz", info, err)
+	}
+
+	// A missing object is reported at once
+	mock.statCalls = 0
+	if _, err := NewRetryableStorage(mock, config).Stat(ctx, "missing.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() of a missing key error = %v, want ErrNotFound", err)
+	}
+	if mock.statCalls != 1 {
+		t.Errorf("Stat() of a missing key made %d calls, want 1", mock.statCalls)
+	}
+
+	mock = &mockStorage{listErr: errors.New("throttled")}
+	if _, err := NewRetryableStorage(mock, config).Stat(ctx, "a.tar.gz"); err == nil || mock.statCalls != 3 {
+		t.Errorf("Stat() = %v after %d calls, want an error after 3", err, mock.statCalls)
+	}
+}
+
+// TestNewStorage_ListWithMetadata lists an S3 bucket through the storage
+// NewStorage builds, so the metadata of the listed objects must be read
+// through the retry wrapper.
+func TestNewStorage_ListWithMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/backups":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>backups</Name><KeyCount>1</KeyCount><IsTruncated>false</IsTruncated>
+  <Contents><Key>a.tar.gz</Key><Size>4</Size><LastModified>2024-01-15T10:30:00.000Z</LastModified></Contents>
+</ListBucketResult>`)
+		case r.Method == http.MethodHead && r.URL.Path == "/backups/a.tar.gz":
+			w.Header().Set("Content-Length", "4")
+			w.Header().Set("x-amz-meta-backup-timestamp", "2024-01-15T10:30:00Z")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		StorageProvider:    "s3",
+		S3Bucket:           "backups",
+		S3Region:           "us-east-1",
+		S3Endpoint:         server.URL,
+		AWSAccessKeyID:     "key",
+		AWSSecretAccessKey: "secret",
+	}
+	store, err := NewStorage(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	if _, ok := store.(*RetryableStorage); !ok {
+		t.Fatalf("NewStorage() = %T, want the retry wrapper", store)
+	}
+
+	objects, err := ListWithMetadata(context.Background(), store, "")
+	if err != nil {
+		t.Fatalf("ListWithMetadata() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Metadata["backup-timestamp"] != "2024-01-15T10:30:00Z" {
+		t.Errorf("ListWithMetadata() = %+v, want a.tar.gz with its metadata", objects)
+	}
+}
+
 func TestRetryableStorage_ContextCancellation(t *testing.T) {
 	mock := &mockStorage{uploadErr: errors.New("upload failed")}
 	config := RetryConfig{
@@ -134,3 +261,77 @@ func TestDefaultRetryConfig(t *testing.T) {
 		t.Errorf("Multiplier = %v, want 2.0", cfg.Multiplier)
 	}
 }
+
+func TestNewS3Config(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      string
+		cfg           *config.Config
+		wantEndpoint  string
+		wantRegion    string
+		wantPathStyle bool
+		wantChecksums bool
+	}{
+		{
+			name:       "aws",
+			provider:   "s3",
+			cfg:        &config.Config{S3Region: "eu-west-1"},
+			wantRegion: "eu-west-1",
+		},
+		{
+			name:          "custom endpoint",
+			provider:      "s3",
+			cfg:           &config.Config{S3Endpoint: "http://minio:9000"},
+			wantEndpoint:  "http://minio:9000",
+			wantPathStyle: true,
+		},
+		{
+			name:          "r2",
+			provider:      "r2",
+			cfg:           &config.Config{R2AccountID: "abc123"},
+			wantEndpoint:  "https://abc123.r2.cloudflarestorage.com",
+			wantRegion:    "auto",
+			wantPathStyle: true,
+			wantChecksums: true,
+		},
+		{
+			name:          "spaces",
+			provider:      "spaces",
+			cfg:           &config.Config{S3Region: "fra1"},
+			wantEndpoint:  "https://fra1.digitaloceanspaces.com",
+			wantRegion:    "fra1",
+			wantPathStyle: true,
+			wantChecksums: true,
+		},
+		{
+			name:          "wasabi",
+			provider:      "wasabi",
+			cfg:           &config.Config{S3Region: "us-west-1"},
+			wantEndpoint:  "https://s3.us-west-1.wasabisys.com",
+			wantRegion:    "us-west-1",
+			wantPathStyle: true,
+			wantChecksums: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newS3Config(tt.provider, tt.cfg)
+			if err != nil {
+				t.Fatalf("newS3Config() error = %v", err)
+			}
+			if got.Endpoint != tt.wantEndpoint {
+				t.Errorf("Endpoint = %q, want %q", got.Endpoint, tt.wantEndpoint)
+			}
+			if got.Region != tt.wantRegion {
+				t.Errorf("Region = %q, want %q", got.Region, tt.wantRegion)
+			}
+			if got.UsePathStyle != tt.wantPathStyle {
+				t.Errorf("UsePathStyle = %v, want %v", got.UsePathStyle, tt.wantPathStyle)
+			}
+			if got.ChecksumsWhenRequired != tt.wantChecksums {
+				t.Errorf("ChecksumsWhenRequired = %v, want %v", got.ChecksumsWhenRequired, tt.wantChecksums)
+			}
+		})
+	}
+}
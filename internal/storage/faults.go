@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by operations a FaultyStorage failed on purpose.
+var ErrInjectedFault = errors.New("injected storage fault")
+
+// FaultConfig sets how often a FaultyStorage fails each kind of operation, in
+// percent.
+type FaultConfig struct {
+	ReadPercent   int    // Open, OpenRange, Stat, List and GetLastBackupTime
+	UploadPercent int    // Upload and Copy
+	DeletePercent int    // Delete
+	Seed          uint64 // Seeds the fault sequence so a run can be reproduced (0 picks a random seed)
+}
+
+// FaultyStorage fails operations on the storage it wraps at random, to
+// exercise the retry, cleanup and resume paths against the flakiness of real
+// providers. Faults are injected before the call reaches the storage, as a
+// rejected request would be, so a failed upload leaves its reader unread and
+// nothing is written. It is meant for non-production deployments only.
+type FaultyStorage struct {
+	storage Storage
+	config  FaultConfig
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	counts map[string]int
+}
+
+// NewFaultyStorage returns a storage that fails operations on storage at the
+// rates of config.
+func NewFaultyStorage(storage Storage, config FaultConfig, logger *slog.Logger) *FaultyStorage {
+	seed := config.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	logger.Warn("Storage fault injection enabled", "read_percent", config.ReadPercent,
+		"upload_percent", config.UploadPercent, "delete_percent", config.DeletePercent, "seed", seed)
+	return &FaultyStorage{
+		storage: storage,
+		config:  config,
+		logger:  logger,
+		rng:     rand.New(rand.NewPCG(seed, seed)),
+		counts:  make(map[string]int),
+	}
+}
+
+// Faults returns how many times each operation was failed.
+func (f *FaultyStorage) Faults() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	counts := make(map[string]int, len(f.counts))
+	for op, n := range f.counts {
+		counts[op] = n
+	}
+	return counts
+}
+
+// Upload implements Storage.Upload, failing at the upload rate.
+func (f *FaultyStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	if err := f.fault("upload", key, f.config.UploadPercent); err != nil {
+		return err
+	}
+	return f.storage.Upload(ctx, key, reader, metadata)
+}
+
+// Open implements Storage.Open, failing at the read rate.
+func (f *FaultyStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := f.fault("open", key, f.config.ReadPercent); err != nil {
+		return nil, err
+	}
+	return f.storage.Open(ctx, key)
+}
+
+// OpenRange implements Storage.OpenRange, failing at the read rate.
+func (f *FaultyStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := f.fault("open", key, f.config.ReadPercent); err != nil {
+		return nil, err
+	}
+	return f.storage.OpenRange(ctx, key, offset, length)
+}
+
+// Delete implements Storage.Delete, failing at the delete rate.
+func (f *FaultyStorage) Delete(ctx context.Context, key string) error {
+	if err := f.fault("delete", key, f.config.DeletePercent); err != nil {
+		return err
+	}
+	return f.storage.Delete(ctx, key)
+}
+
+// Copy implements Storage.Copy, failing at the upload rate.
+func (f *FaultyStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if err := f.fault("copy", dstKey, f.config.UploadPercent); err != nil {
+		return err
+	}
+	return f.storage.Copy(ctx, srcKey, dstKey)
+}
+
+// Stat implements Storage.Stat, failing at the read rate.
+func (f *FaultyStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := f.fault("stat", key, f.config.ReadPercent); err != nil {
+		return nil, err
+	}
+	return f.storage.Stat(ctx, key)
+}
+
+// List implements Storage.List, failing at the read rate.
+func (f *FaultyStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := f.fault("list", prefix, f.config.ReadPercent); err != nil {
+		return nil, err
+	}
+	return f.storage.List(ctx, prefix)
+}
+
+// ListIter implements ListIterator, failing at the read rate before the
+// listing starts.
+func (f *FaultyStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	if err := f.fault("list", prefix, f.config.ReadPercent); err != nil {
+		return err
+	}
+	return ListIter(ctx, f.storage, prefix, fn)
+}
+
+// ListWithMetadata implements MetadataLister, failing at the read rate.
+func (f *FaultyStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := f.fault("list", prefix, f.config.ReadPercent); err != nil {
+		return nil, err
+	}
+	return ListWithMetadata(ctx, f.storage, prefix)
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime, failing at the read rate.
+func (f *FaultyStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	if err := f.fault("list", "", f.config.ReadPercent); err != nil {
+		return time.Time{}, err
+	}
+	return f.storage.GetLastBackupTime(ctx)
+}
+
+// faultyConditional fails the conditional operations of a FaultyStorage at
+// its read, upload and delete rates.
+type faultyConditional struct {
+	storage ConditionalStorage
+	faults  *FaultyStorage
+}
+
+func (f *faultyConditional) ReadVersion(ctx context.Context, key string) ([]byte, string, error) {
+	if err := f.faults.fault("open", key, f.faults.config.ReadPercent); err != nil {
+		return nil, "", err
+	}
+	return f.storage.ReadVersion(ctx, key)
+}
+
+func (f *faultyConditional) WriteIfVersion(ctx context.Context, key string, data []byte, version string) (string, error) {
+	if err := f.faults.fault("upload", key, f.faults.config.UploadPercent); err != nil {
+		return "", err
+	}
+	return f.storage.WriteIfVersion(ctx, key, data, version)
+}
+
+func (f *faultyConditional) DeleteIfVersion(ctx context.Context, key, version string) error {
+	if err := f.faults.fault("delete", key, f.faults.config.DeletePercent); err != nil {
+		return err
+	}
+	return f.storage.DeleteIfVersion(ctx, key, version)
+}
+
+// fault decides whether op on key fails, returning ErrInjectedFault with
+// percent probability.
+func (f *FaultyStorage) fault(op, key string, percent int) error {
+	if percent <= 0 {
+		return nil
+	}
+	f.mu.Lock()
+	failed := f.rng.IntN(100) < percent
+	if failed {
+		f.counts[op]++
+	}
+	f.mu.Unlock()
+	if !failed {
+		return nil
+	}
+	f.logger.Warn("Injected storage fault", "operation", op, "key", key)
+	return fmt.Errorf("%s %s: %w", op, key, ErrInjectedFault)
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFaultyFilesystem(t *testing.T, config FaultConfig) (*FilesystemStorage, *FaultyStorage) {
+	t.Helper()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	return fs, NewFaultyStorage(fs, config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestFaultyStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no faults", func(t *testing.T) {
+		_, faulty := newFaultyFilesystem(t, FaultConfig{})
+		for i := range 20 {
+			key := fmt.Sprintf("backup-%d.tar.gz", i)
+			if err := faulty.Upload(ctx, key, strings.NewReader("data"), nil); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+			if _, err := faulty.Stat(ctx, key); err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if err := faulty.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+		}
+		if faults := faulty.Faults(); len(faults) != 0 {
+			t.Errorf("Faults() = %v, want none", faults)
+		}
+	})
+
+	t.Run("every operation failed", func(t *testing.T) {
+		fs, faulty := newFaultyFilesystem(t, FaultConfig{ReadPercent: 100, UploadPercent: 100, DeletePercent: 100})
+		if err := fs.Upload(ctx, "backup.tar.gz", strings.NewReader("data"), nil); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+
+		reader := strings.NewReader("new")
+		if err := faulty.Upload(ctx, "new.tar.gz", reader, nil); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("Upload() error = %v, want ErrInjectedFault", err)
+		}
+		if reader.Len() != 3 {
+			t.Errorf("failed Upload() read %d bytes, want the reader untouched", 3-reader.Len())
+		}
+		if _, err := faulty.Open(ctx, "backup.tar.gz"); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("Open() error = %v, want ErrInjectedFault", err)
+		}
+		if _, err := faulty.Stat(ctx, "backup.tar.gz"); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("Stat() error = %v, want ErrInjectedFault", err)
+		}
+		if err := ListIter(ctx, faulty, "", func(ObjectInfo) error { return nil }); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("ListIter() error = %v, want ErrInjectedFault", err)
+		}
+		if err := faulty.Copy(ctx, "backup.tar.gz", "copy.tar.gz"); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("Copy() error = %v, want ErrInjectedFault", err)
+		}
+		if err := faulty.Delete(ctx, "backup.tar.gz"); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("Delete() error = %v, want ErrInjectedFault", err)
+		}
+
+		if objects, _ := fs.List(ctx, ""); len(objects) != 1 || objects[0].Key != "backup.tar.gz" {
+			t.Errorf("storage holds %v after failed operations, want only backup.tar.gz", objects)
+		}
+		want := map[string]int{"upload": 1, "open": 1, "stat": 1, "list": 1, "copy": 1, "delete": 1}
+		for op, n := range want {
+			if got := faulty.Faults()[op]; got != n {
+				t.Errorf("Faults()[%s] = %d, want %d", op, got, n)
+			}
+		}
+	})
+
+	t.Run("same seed, same faults", func(t *testing.T) {
+		sequence := func() []bool {
+			_, faulty := newFaultyFilesystem(t, FaultConfig{ReadPercent: 50, Seed: 42})
+			var failed []bool
+			for range 50 {
+				_, err := faulty.List(ctx, "")
+				failed = append(failed, errors.Is(err, ErrInjectedFault))
+			}
+			return failed
+		}
+		first, second := sequence(), sequence()
+		var faults int
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("call %d failed = %v, then %v with the same seed", i, first[i], second[i])
+			}
+			if first[i] {
+				faults++
+			}
+		}
+		if faults == 0 || faults == len(first) {
+			t.Errorf("%d of %d calls failed at 50%%, want some", faults, len(first))
+		}
+	})
+}
+
+// TestFaultyStorage_Retried runs the storage operations a backup run depends
+// on through the retry wrapper, against a storage failing a third of every
+// operation, and checks they all end up applied exactly as without faults.
+// Conditional writes are hidden, as they are never retried.
+func TestFaultyStorage_Retried(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	faulty := NewFaultyStorage(plainStorage{fs}, FaultConfig{ReadPercent: 30, UploadPercent: 30, DeletePercent: 30, Seed: 7},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	store := NewRetryableStorage(faulty, RetryConfig{MaxAttempts: 10, InitialDelay: time.Microsecond, MaxDelay: time.Microsecond, Multiplier: 1})
+
+	const runs = 30
+	for i := range runs {
+		key := fmt.Sprintf("backup-%02d.tar.gz", i)
+		data := strings.Repeat(key, 100)
+		if err := store.Upload(ctx, key, strings.NewReader(data), map[string]string{"run": key}); err != nil {
+			t.Fatalf("Upload(%s) error = %v", key, err)
+		}
+
+		r, err := store.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", key, err)
+		}
+		got, _ := io.ReadAll(r)
+		_ = r.Close()
+		if string(got) != data {
+			t.Fatalf("Open(%s) read %d bytes, want %d", key, len(got), len(data))
+		}
+
+		if err := AppendHistory(ctx, store, HistoryRecord{Key: key, Status: "success"}); err != nil {
+			t.Fatalf("AppendHistory(%s) error = %v", key, err)
+		}
+		if err := UpdateCatalog(ctx, store, func(c *Catalog) {
+			c.Put(CatalogEntry{Key: key, SizeBytes: int64(len(data)), Status: CatalogStatusComplete})
+		}); err != nil {
+			t.Fatalf("UpdateCatalog(%s) error = %v", key, err)
+		}
+		if i%2 == 1 {
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete(%s) error = %v", key, err)
+			}
+		}
+	}
+
+	faults := faulty.Faults()
+	if faults["upload"] == 0 || faults["open"] == 0 || faults["delete"] == 0 {
+		t.Fatalf("Faults() = %v, want uploads, reads and deletes failed", faults)
+	}
+
+	history, err := ReadHistory(ctx, fs)
+	if err != nil || len(history) != runs {
+		t.Errorf("ReadHistory() = %d records, %v, want %d", len(history), err, runs)
+	}
+	catalog, err := ReadCatalog(ctx, fs)
+	if err != nil || len(catalog.Backups) != runs {
+		t.Fatalf("ReadCatalog() = %v, %v, want %d entries", catalog, err, runs)
+	}
+	var backups int
+	err = ListIter(ctx, fs, "", func(obj ObjectInfo) error {
+		if !IsReservedKey(obj.Key) {
+			backups++
+		}
+		return nil
+	})
+	if err != nil || backups != runs/2 {
+		t.Errorf("storage holds %d backups, %v, want %d", backups, err, runs/2)
+	}
+}
+
+// TestUpdateObject_ReadFault checks that a history object that cannot be read
+// is left alone, rather than replaced by the update alone, with and without
+// conditional writes.
+func TestUpdateObject_ReadFault(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, conditional := range []bool{true, false} {
+		t.Run(fmt.Sprintf("conditional %v", conditional), func(t *testing.T) {
+			fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+			if err != nil {
+				t.Fatalf("NewFilesystemStorage() error = %v", err)
+			}
+			var store Storage = fs
+			if !conditional {
+				store = plainStorage{fs}
+			}
+
+			// A missing object is started afresh
+			if err := AppendHistory(ctx, NewFaultyStorage(store, FaultConfig{}, logger), HistoryRecord{Key: "first.tar.gz", Status: "success"}); err != nil {
+				t.Fatalf("AppendHistory() error = %v", err)
+			}
+
+			faulty := NewFaultyStorage(store, FaultConfig{ReadPercent: 100}, logger)
+			if err := AppendHistory(ctx, faulty, HistoryRecord{Key: "second.tar.gz", Status: "success"}); !errors.Is(err, ErrInjectedFault) {
+				t.Errorf("AppendHistory() error = %v, want ErrInjectedFault", err)
+			}
+			if history, err := ReadHistory(ctx, fs); err != nil || len(history) != 1 || history[0].Key != "first.tar.gz" {
+				t.Errorf("ReadHistory() = %v, %v, want the first record kept", history, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataSuffix is appended to the hidden sidecar file holding object metadata.
+const metadataSuffix = ".metadata.json"
+
+// ErrInvalidKey is returned for keys that resolve outside the storage directory.
+var ErrInvalidKey = errors.New("invalid object key")
+
+// FilesystemStorage implements Storage interface for a local directory or mounted volume.
+type FilesystemStorage struct {
+	root   string
+	prefix string
+	mu     sync.Mutex // Serialises conditional writes
+}
+
+// FilesystemConfig holds filesystem-specific configuration.
+type FilesystemConfig struct {
+	Path   string // Base directory, e.g. a mounted Railway volume
+	Prefix string // Optional prefix for all keys
+}
+
+// NewFilesystemStorage creates a new filesystem storage provider.
+func NewFilesystemStorage(cfg FilesystemConfig) (*FilesystemStorage, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("filesystem path is required")
+	}
+
+	root, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve filesystem path: %w", err)
+	}
+
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	return &FilesystemStorage{
+		root:   root,
+		prefix: cfg.Prefix,
+	}, nil
+}
+
+// Upload implements Storage.Upload.
+// Data is written to a temporary file in the destination directory and renamed
+// into place once complete, so a failed upload never leaves a partial backup.
+func (f *FilesystemStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(fullPath)
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := writeFileAtomic(ctx, fullPath, reader); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	if len(metadata) > 0 {
+		return f.writeMetadata(ctx, key, metadata)
+	}
+
+	return nil
+}
+
+// writeMetadata replaces the metadata sidecar of key.
+func (f *FilesystemStorage) writeMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ctx, metadataPath(fullPath), strings.NewReader(string(data))); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	return nil
+}
+
+// Open implements Storage.Open.
+func (f *FilesystemStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open from filesystem: %w", err)
+	}
+
+	return file, nil
+}
+
+// OpenRange implements Storage.OpenRange.
+func (f *FilesystemStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open from filesystem: %w", err)
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to seek in filesystem: %w", err)
+	}
+
+	return limitReadCloser(file, length), nil
+}
+
+// Delete implements Storage.Delete.
+func (f *FilesystemStorage) Delete(ctx context.Context, key string) error {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fullPath); err != nil {
+		return fmt.Errorf("failed to delete from filesystem: %w", err)
+	}
+
+	// Metadata is optional, so a missing sidecar is not an error
+	if err := os.Remove(metadataPath(fullPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete metadata file: %w", err)
+	}
+
+	return nil
+}
+
+// Copy implements Storage.Copy, copying the file and its metadata sidecar
+// through a temporary file as Upload does.
+func (f *FilesystemStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	srcPath, err := f.getFullPath(srcKey)
+	if err != nil {
+		return err
+	}
+	dstPath, err := f.getFullPath(dstKey)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy in filesystem: %w", err)
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := writeFileAtomic(ctx, dstPath, src); err != nil {
+		return fmt.Errorf("failed to copy in filesystem: %w", err)
+	}
+
+	if metadata := readMetadata(srcPath); len(metadata) > 0 {
+		return f.writeMetadata(ctx, dstKey, metadata)
+	}
+	return nil
+}
+
+// List implements Storage.List.
+func (f *FilesystemStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := f.ListIter(ctx, prefix, func(obj ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListIter implements ListIterator, walking the directory tree.
+func (f *FilesystemStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	base, err := f.getFullPath("")
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	err = filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == base {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Skip hidden entries: temp files and metadata sidecars
+		if strings.HasPrefix(d.Name(), ".") && p != base {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fnErr = fn(ObjectInfo{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+			Metadata:     readMetadata(p),
+		})
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list filesystem objects: %w", err)
+	}
+
+	return nil
+}
+
+// Stat implements Storage.Stat, reading metadata from the sidecar.
+func (f *FilesystemStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat in filesystem: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		Metadata:     readMetadata(fullPath),
+	}, nil
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime.
+func (f *FilesystemStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return listedBackupTime(ctx, f)
+}
+
+// getFullPath returns the absolute file path for a key. Keys that climb out of
+// the storage directory, such as "../../etc/passwd", are rejected.
+func (f *FilesystemStorage) getFullPath(key string) (string, error) {
+	base := filepath.Join(f.root, filepath.FromSlash(f.prefix))
+	fullPath := filepath.Join(base, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(base, fullPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q leaves the storage directory", ErrInvalidKey, key)
+	}
+	return fullPath, nil
+}
+
+// metadataPath returns the hidden sidecar path holding metadata for a file.
+func metadataPath(fullPath string) string {
+	return filepath.Join(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+metadataSuffix)
+}
+
+// readMetadata loads the metadata sidecar for a file, returning an empty map if absent.
+func readMetadata(fullPath string) map[string]string {
+	metadata := make(map[string]string)
+	data, err := os.ReadFile(metadataPath(fullPath))
+	if err != nil {
+		return metadata
+	}
+	_ = json.Unmarshal(data, &metadata)
+	return metadata
+}
+
+// writeFileAtomic writes reader to a temp file next to dst and renames it into place.
+func writeFileAtomic(ctx context.Context, dst string, reader io.Reader) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Remove the temp file on any failure path
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, &contextReader{ctx: ctx, reader: reader}); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// contextReader stops reading once the context is cancelled.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read implements io.Reader.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
+}
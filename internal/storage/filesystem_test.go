@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilesystemStorage_UploadAndList(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: dir, Prefix: "backups"})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := map[string]string{"backup-tool": "railway-postgres-backup"}
+	if err := fs.Upload(ctx, "2024/01/test.tar.gz", strings.NewReader("backup data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "backups", "2024", "01", "test.tar.gz"))
+	if err != nil {
+		t.Fatalf("backup file not written: %v", err)
+	}
+	if string(data) != "backup data" {
+		t.Errorf("file content = %q, want %q", string(data), "backup data")
+	}
+
+	objects, err := fs.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("List() returned %d objects, want 1 (sidecars must be hidden)", len(objects))
+	}
+	if objects[0].Key != "2024/01/test.tar.gz" {
+		t.Errorf("Key = %v, want 2024/01/test.tar.gz", objects[0].Key)
+	}
+	if objects[0].Size != int64(len("backup data")) {
+		t.Errorf("Size = %v, want %v", objects[0].Size, len("backup data"))
+	}
+	if objects[0].Metadata["backup-tool"] != "railway-postgres-backup" {
+		t.Errorf("Metadata not round-tripped: %v", objects[0].Metadata)
+	}
+
+	filtered, err := fs.List(ctx, "2023/")
+	if err != nil {
+		t.Fatalf("List() with prefix error = %v", err)
+	}
+	if len(filtered) != 0 {
+		t.Errorf("List() with non-matching prefix returned %d objects", len(filtered))
+	}
+}
+
+func TestFilesystemStorage_UploadFailureLeavesNoFile(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	reader := io.MultiReader(strings.NewReader("partial"), &failingReader{err: errors.New("dump failed")})
+	if err := fs.Upload(context.Background(), "test.tar.gz", reader, nil); err == nil {
+		t.Fatal("Upload() expected error, got nil")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files after failed upload, found %d", len(entries))
+	}
+}
+
+func TestFilesystemStorage_Delete(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := map[string]string{"backup-timestamp": time.Now().Format(time.RFC3339)}
+	if err := fs.Upload(ctx, "test.tar.gz", strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	if err := fs.Delete(ctx, "test.tar.gz"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected backup and metadata to be removed, found %d entries", len(entries))
+	}
+
+	if err := fs.Delete(ctx, "test.tar.gz"); err == nil {
+		t.Error("Delete() of missing key expected error, got nil")
+	}
+}
+
+func TestFilesystemStorage_Copy(t *testing.T) {
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir(), Prefix: "db"})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := map[string]string{"backup-timestamp": "2024-01-15T10:30:00Z"}
+	if err := fs.Upload(ctx, "2024/01/test.tar.gz", strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	if err := fs.Copy(ctx, "2024/01/test.tar.gz", "trash/test.tar.gz"); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	objects, err := fs.List(ctx, "trash/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Size != 4 || objects[0].Metadata["backup-timestamp"] != "2024-01-15T10:30:00Z" {
+		t.Fatalf("List() = %+v, want the copy with its metadata", objects)
+	}
+
+	// The source is untouched
+	if objects, _ := fs.List(ctx, "2024/"); len(objects) != 1 {
+		t.Errorf("source listing = %v, want the original", objects)
+	}
+
+	if err := fs.Copy(ctx, "missing.tar.gz", "trash/missing.tar.gz"); err == nil {
+		t.Error("Copy() of missing key expected error, got nil")
+	}
+}
+
+func TestFilesystemStorage_Open(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: dir, Prefix: "backups"})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := fs.Upload(ctx, "2025/01/test.tar.gz", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	r, err := fs.Open(ctx, "2025/01/test.tar.gz")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "data" {
+		t.Errorf("Open() content = %q, want %q", data, "data")
+	}
+
+	if _, err := fs.Open(ctx, "missing.tar.gz"); err == nil {
+		t.Error("Open() of missing key expected error, got nil")
+	}
+}
+
+func TestFilesystemStorage_OpenRange(t *testing.T) {
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := fs.Upload(ctx, "test.tar.gz", strings.NewReader("0123456789"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           string
+	}{
+		{"head", 0, 4, "0123"},
+		{"middle", 3, 4, "3456"},
+		{"rest", 6, -1, "6789"},
+		{"past the end", 8, 10, "89"},
+		{"empty", 2, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := fs.OpenRange(ctx, "test.tar.gz", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("OpenRange() error = %v", err)
+			}
+			defer func() {
+				_ = r.Close()
+			}()
+			data, err := io.ReadAll(r)
+			if err != nil || string(data) != tt.want {
+				t.Errorf("OpenRange(%d, %d) read %q, %v; want %q", tt.offset, tt.length, data, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := fs.OpenRange(ctx, "missing.tar.gz", 0, 1); err == nil {
+		t.Error("OpenRange() of missing key expected error, got nil")
+	}
+}
+
+func TestFilesystemStorage_Stat(t *testing.T) {
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir(), Prefix: "db"})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	metadata := map[string]string{"backup-timestamp": "2024-01-15T10:30:00Z"}
+	if err := fs.Upload(ctx, "2024/01/test.tar.gz", strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	info, err := fs.Stat(ctx, "2024/01/test.tar.gz")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Key != "2024/01/test.tar.gz" || info.Size != 4 || info.LastModified.IsZero() ||
+		info.Metadata["backup-timestamp"] != "2024-01-15T10:30:00Z" {
+		t.Errorf("Stat() = %+v, want the object with its metadata", info)
+	}
+
+	for _, key := range []string{"missing.tar.gz", "2024/01"} {
+		if _, err := fs.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat(%q) error = %v, want ErrNotFound", key, err)
+		}
+	}
+}
+
+func TestFilesystemStorage_KeyOutsideRoot(t *testing.T) {
+	parent := t.TempDir()
+	secret := filepath.Join(parent, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: filepath.Join(parent, "bucket"), Prefix: "db"})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	ctx := context.Background()
+
+	operations := map[string]func(key string) error{
+		"Upload": func(key string) error { return fs.Upload(ctx, key, strings.NewReader("data"), nil) },
+		"Open": func(key string) error {
+			r, err := fs.Open(ctx, key)
+			if err == nil {
+				_ = r.Close()
+			}
+			return err
+		},
+		"Stat":   func(key string) error { _, err := fs.Stat(ctx, key); return err },
+		"Delete": func(key string) error { return fs.Delete(ctx, key) },
+		"Copy":   func(key string) error { return fs.Copy(ctx, "2024/01/test.tar.gz", key) },
+		"WriteIfVersion": func(key string) error {
+			_, err := fs.WriteIfVersion(ctx, key, []byte("data"), "")
+			return err
+		},
+	}
+	if err := fs.Upload(ctx, "2024/01/test.tar.gz", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	for _, key := range []string{"../../secret.txt", "../other/test.tar.gz", "2024/../../../secret.txt", ".."} {
+		for name, op := range operations {
+			if err := op(key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("%s(%q) error = %v, want ErrInvalidKey", name, key, err)
+			}
+		}
+	}
+	if data, err := os.ReadFile(secret); err != nil || string(data) != "secret" {
+		t.Errorf("file outside the root = %q, %v; want it untouched", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "bucket", "other")); !os.IsNotExist(err) {
+		t.Errorf("directory created outside the prefix: %v", err)
+	}
+
+	// Keys that stay inside the directory once cleaned are accepted
+	for _, key := range []string{"2024/../inside.txt", "/rooted.txt"} {
+		if err := fs.Upload(ctx, key, strings.NewReader("data"), nil); err != nil {
+			t.Errorf("Upload(%q) error = %v", key, err)
+		}
+	}
+}
+
+func TestFilesystemStorage_GetLastBackupTime(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+
+	last, err := fs.GetLastBackupTime(ctx)
+	if err != nil {
+		t.Fatalf("GetLastBackupTime() on empty storage error = %v", err)
+	}
+	if !last.IsZero() {
+		t.Errorf("GetLastBackupTime() on empty storage = %v, want zero", last)
+	}
+
+	want := time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC)
+	metadata := map[string]string{"backup-timestamp": want.Format(time.RFC3339)}
+	if err := fs.Upload(ctx, "test.tar.gz", strings.NewReader("data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	last, err = fs.GetLastBackupTime(ctx)
+	if err != nil {
+		t.Fatalf("GetLastBackupTime() error = %v", err)
+	}
+	if !last.Equal(want) {
+		t.Errorf("GetLastBackupTime() = %v, want %v", last, want)
+	}
+}
+
+// failingReader always returns the configured error.
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	return 0, f.err
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"path"
+	"strings"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// foreignExtensions maps the filename extensions of dumps written by other
+// tools, such as pg_dump scripts, to the archive format they hold: "plain" SQL
+// or a pg_dump "custom" archive.
+var foreignExtensions = []struct {
+	ext    string
+	format string
+}{
+	{".sql", "plain"},
+	{".sql.gz", "plain"},
+	{".sql.zst", "plain"},
+	{".dump", "custom"},
+	{".backup", "custom"},
+	{".pgdump", "custom"},
+}
+
+// ForeignDumpFormat returns the archive format of a dump written by another
+// tool, judged from its extension, or "" when obj was written by this tool or
+// is not a recognised dump.
+func ForeignDumpFormat(obj ObjectInfo) string {
+	if obj.Metadata["backup-tool"] != "" || IsReservedKey(obj.Key) || utils.IsSidecar(obj.Key) {
+		return ""
+	}
+	name := path.Base(obj.Key)
+	if _, err := utils.ParseBackupFilename(name); err == nil {
+		return ""
+	}
+	for _, f := range foreignExtensions {
+		if strings.HasSuffix(name, f.ext) {
+			return f.format
+		}
+	}
+	return ""
+}
@@ -3,10 +3,11 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"path"
-	"sort"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -16,23 +17,34 @@ import (
 
 // GCSStorage implements Storage interface for Google Cloud Storage.
 type GCSStorage struct {
-	client *storage.Client
-	bucket string
-	prefix string
+	client       *storage.Client
+	deleter      *storage.Client // Client for deletes; the delete service account when configured
+	bucket       string
+	prefix       string
+	projectID    string
+	location     string
+	verifyCRC32C bool
 }
 
 // GCSConfig holds GCS-specific configuration.
 type GCSConfig struct {
 	Bucket             string
 	ProjectID          string
-	ServiceAccountJSON string
+	ServiceAccountJSON string // Optional; Application Default Credentials are used when empty
 	Prefix             string // Optional prefix for all keys
 	CustomerManagedKey string // Optional CMEK
+	Location           string // Location for a bucket created on first run
+	VerifyCRC32C       bool   // Fail uploads whose stored CRC32C does not match the data sent
+
+	// DeleteServiceAccountJSON is an optional service account used only for
+	// deletes, so the main one can be limited to creating and reading objects
+	DeleteServiceAccountJSON string
 }
 
 // NewGCSStorage creates a new GCS storage provider.
 func NewGCSStorage(ctx context.Context, cfg GCSConfig) (*GCSStorage, error) {
-	// Parse service account JSON
+	// Use explicit credentials when provided; otherwise the client falls back to
+	// Application Default Credentials (metadata server, workload identity)
 	var opts []option.ClientOption
 	if cfg.ServiceAccountJSON != "" {
 		opts = append(opts, option.WithCredentialsJSON([]byte(cfg.ServiceAccountJSON)))
@@ -44,10 +56,22 @@ func NewGCSStorage(ctx context.Context, cfg GCSConfig) (*GCSStorage, error) {
 		return nil, fmt.Errorf("failed to create GCS client: %w", err)
 	}
 
+	deleter := client
+	if cfg.DeleteServiceAccountJSON != "" {
+		deleter, err = storage.NewClient(ctx, option.WithCredentialsJSON([]byte(cfg.DeleteServiceAccountJSON)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS delete client: %w", err)
+		}
+	}
+
 	return &GCSStorage{
-		client: client,
-		bucket: cfg.Bucket,
-		prefix: cfg.Prefix,
+		client:       client,
+		deleter:      deleter,
+		bucket:       cfg.Bucket,
+		prefix:       cfg.Prefix,
+		projectID:    cfg.ProjectID,
+		location:     cfg.Location,
+		verifyCRC32C: cfg.VerifyCRC32C,
 	}, nil
 }
 
@@ -63,8 +87,9 @@ func (g *GCSStorage) Upload(ctx context.Context, key string, reader io.Reader, m
 	w := obj.NewWriter(ctx)
 	w.Metadata = metadata
 
-	// Copy data
-	if _, err := io.Copy(w, reader); err != nil {
+	// Copy data, computing the CRC32C GCS stores for the object
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	if _, err := io.Copy(w, io.TeeReader(reader, crc)); err != nil {
 		_ = w.Close()
 		return fmt.Errorf("failed to upload to GCS: %w", err)
 	}
@@ -74,14 +99,44 @@ func (g *GCSStorage) Upload(ctx context.Context, key string, reader io.Reader, m
 		return fmt.Errorf("failed to finalize GCS upload: %w", err)
 	}
 
+	if attrs := w.Attrs(); g.verifyCRC32C && attrs != nil && attrs.CRC32C != crc.Sum32() {
+		_ = g.deleter.Bucket(g.bucket).Object(fullKey).Delete(context.WithoutCancel(ctx))
+		return fmt.Errorf("failed to upload to GCS: %w: the provider stored CRC32C %08x, the data sent has %08x",
+			ErrIntegrity, attrs.CRC32C, crc.Sum32())
+	}
+
 	return nil
 }
 
+// Open implements Storage.Open.
+func (g *GCSStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj := g.client.Bucket(g.bucket).Object(g.getFullKey(key))
+
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GCS object: %w", err)
+	}
+
+	return r, nil
+}
+
+// OpenRange implements Storage.OpenRange.
+func (g *GCSStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	obj := g.client.Bucket(g.bucket).Object(g.getFullKey(key))
+
+	r, err := obj.NewRangeReader(ctx, offset, max(length, -1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GCS object range: %w", err)
+	}
+
+	return r, nil
+}
+
 // Delete implements Storage.Delete.
 func (g *GCSStorage) Delete(ctx context.Context, key string) error {
 	fullKey := g.getFullKey(key)
 
-	bucket := g.client.Bucket(g.bucket)
+	bucket := g.deleter.Bucket(g.bucket)
 	obj := bucket.Object(fullKey)
 
 	if err := obj.Delete(ctx); err != nil {
@@ -91,67 +146,93 @@ func (g *GCSStorage) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Copy implements Storage.Copy with a server-side copy; the metadata is
+// copied along with the data.
+func (g *GCSStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	bucket := g.client.Bucket(g.bucket)
+	src := bucket.Object(g.getFullKey(srcKey))
+	dst := bucket.Object(g.getFullKey(dstKey))
+
+	if _, err := dst.CopierFrom(src).Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy in GCS: %w", err)
+	}
+
+	return nil
+}
+
 // List implements Storage.List.
 func (g *GCSStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
-	fullPrefix := g.getFullKey(prefix)
-
 	var objects []ObjectInfo
-	bucket := g.client.Bucket(g.bucket)
+	err := g.ListIter(ctx, prefix, func(obj ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
 
+// ListIter implements ListIterator, following the object iterator's pages.
+func (g *GCSStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
 	query := &storage.Query{
-		Prefix: fullPrefix,
+		Prefix: g.getFullKey(prefix),
 	}
 
-	it := bucket.Objects(ctx, query)
+	it := g.client.Bucket(g.bucket).Objects(ctx, query)
 	for {
 		attrs, err := it.Next()
 		if err == iterator.Done {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to list GCS objects: %w", err)
+			return fmt.Errorf("failed to list GCS objects: %w", err)
 		}
 
-		objects = append(objects, ObjectInfo{
+		err = fn(ObjectInfo{
 			Key:          g.stripPrefix(attrs.Name),
 			Size:         attrs.Size,
 			LastModified: attrs.Updated,
 			Metadata:     attrs.Metadata,
 		})
+		if err != nil {
+			return err
+		}
 	}
-
-	return objects, nil
 }
 
-// GetLastBackupTime implements Storage.GetLastBackupTime.
-func (g *GCSStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
-	objects, err := g.List(ctx, "")
+// Stat implements Storage.Stat from the object's attributes.
+func (g *GCSStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	attrs, err := g.client.Bucket(g.bucket).Object(g.getFullKey(key)).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
 	if err != nil {
-		return time.Time{}, err
+		return nil, fmt.Errorf("failed to stat GCS object %s: %w", key, err)
 	}
 
-	if len(objects) == 0 {
-		return time.Time{}, nil
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		Metadata:     attrs.Metadata,
 	}
-
-	// Sort by last modified time descending
-	sort.Slice(objects, func(i, j int) bool {
-		return objects[i].LastModified.After(objects[j].LastModified)
-	})
-
-	// Check for backup timestamp in metadata
-	if timestamp, ok := objects[0].Metadata["backup-timestamp"]; ok {
-		t, err := time.Parse(time.RFC3339, timestamp)
-		if err == nil {
-			return t, nil
-		}
+	if info.Metadata == nil {
+		info.Metadata = make(map[string]string)
 	}
+	return info, nil
+}
 
-	return objects[0].LastModified, nil
+// GetLastBackupTime implements Storage.GetLastBackupTime.
+func (g *GCSStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return listedBackupTime(ctx, g)
 }
 
-// Close closes the GCS client connection.
+// Close closes the GCS client connections.
 func (g *GCSStorage) Close() error {
+	if g.deleter != g.client {
+		_ = g.deleter.Close()
+	}
 	return g.client.Close()
 }
 
@@ -175,6 +256,7 @@ func (g *GCSStorage) stripPrefix(key string) string {
 }
 
 // ValidateServiceAccountJSON validates the service account JSON string.
+// Workload identity federation configs ("external_account") are accepted as well.
 func ValidateServiceAccountJSON(jsonStr string) error {
 	var sa struct {
 		Type string `json:"type"`
@@ -184,7 +266,7 @@ func ValidateServiceAccountJSON(jsonStr string) error {
 		return fmt.Errorf("invalid service account JSON: %w", err)
 	}
 
-	if sa.Type != "service_account" {
+	if sa.Type != "service_account" && sa.Type != "external_account" {
 		return fmt.Errorf("invalid service account type: %s", sa.Type)
 	}
 
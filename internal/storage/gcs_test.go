@@ -93,6 +93,11 @@ func TestValidateServiceAccountJSON(t *testing.T) {
 			json:    `{"type": "service_account", "project_id": "test"}`,
 			wantErr: false,
 		},
+		{
+			name:    "workload identity federation",
+			json:    `{"type": "external_account", "audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/p/providers/q"}`,
+			wantErr: false,
+		},
 		{
 			name:    "invalid type",
 			json:    `{"type": "user", "project_id": "test"}`,
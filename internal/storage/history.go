@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// HistoryKey names the object holding one JSON line per backup run, so backup
+// performance can be graphed from the bucket alone.
+const HistoryKey = "metrics/history.jsonl"
+
+// MaxHistoryRecords bounds the history object; the oldest records are dropped
+// first. It covers a year of hourly runs.
+const MaxHistoryRecords = 10000
+
+// HistoryRecord describes one backup run.
+type HistoryRecord struct {
+	Time            time.Time `json:"time"`
+	Key             string    `json:"key"`
+	Database        string    `json:"database,omitempty"`
+	Status          string    `json:"status"` // success or failed
+	Error           string    `json:"error,omitempty"`
+	Compression     string    `json:"compression,omitempty"`
+	Label           string    `json:"label,omitempty"`
+	SizeBytes       int64     `json:"size_bytes"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	UploadSeconds   float64   `json:"upload_seconds,omitempty"`
+	BytesPerSecond  float64   `json:"bytes_per_second,omitempty"`
+}
+
+// ReadHistory reads the run history from store, oldest first. Lines that do
+// not parse are skipped.
+func ReadHistory(ctx context.Context, store Storage) ([]HistoryRecord, error) {
+	r, err := store.Open(ctx, HistoryKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", HistoryKey, err)
+	}
+	return records, nil
+}
+
+// updateAttempts bounds the retries of an update that raced another writer or
+// failed to read or write.
+const updateAttempts = 3
+
+// AppendHistory adds record to the run history in store.
+func AppendHistory(ctx context.Context, store Storage, record HistoryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return updateObject(ctx, store, HistoryKey, func(data []byte) ([]byte, error) {
+		return appendLine(data, line), nil
+	})
+}
+
+// updateObject rewrites the object at key with the result of update, which
+// receives its current content (nil when it does not exist). Object storage has
+// no append or in-place edit, so the object is read and rewritten: with a
+// conditional write where the storage supports one (in the first destination
+// of several), retrying when another writer got there first or a step failed,
+// otherwise replaced outright. The precondition makes a failed step safe to
+// retry from the read: a write that had gone through is read back first.
+func updateObject(ctx context.Context, store Storage, key string, update func([]byte) ([]byte, error)) error {
+	if cs, ok := AsConditional(store); ok {
+		var lastErr error
+		for attempt := 1; attempt <= updateAttempts; attempt++ {
+			if lastErr != nil && !errors.Is(lastErr, ErrPreconditionFailed) {
+				select {
+				case <-ctx.Done():
+					return lastErr
+				case <-time.After(time.Duration(attempt-1) * 100 * time.Millisecond):
+				}
+			}
+
+			data, version, err := cs.ReadVersion(ctx, key)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				lastErr = fmt.Errorf("failed to read %s: %w", key, err)
+				continue
+			}
+			updated, err := update(data)
+			if err != nil {
+				return err
+			}
+			if _, err := cs.WriteIfVersion(ctx, key, updated, version); err != nil {
+				lastErr = fmt.Errorf("failed to write %s: %w", key, err)
+				continue
+			}
+			return nil
+		}
+		return lastErr
+	}
+
+	// Only a missing object is started afresh: one that failed to open is
+	// kept, rather than replaced with the update alone
+	var data []byte
+	r, err := store.Open(ctx, key)
+	if err != nil {
+		if _, statErr := store.Stat(ctx, key); !errors.Is(statErr, ErrNotFound) {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+	} else {
+		data, err = io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+	}
+	updated, err := update(data)
+	if err != nil {
+		return err
+	}
+	if err := store.Upload(ctx, key, bytes.NewReader(updated), nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// appendLine adds line to a JSON lines document, dropping the oldest lines
+// beyond MaxHistoryRecords.
+func appendLine(data, line []byte) []byte {
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines[0]) == 0 {
+		lines = lines[:0]
+	}
+	lines = append(lines, line)
+	if len(lines) > MaxHistoryRecords {
+		lines = lines[len(lines)-MaxHistoryRecords:]
+	}
+	return append(bytes.Join(lines, []byte("\n")), '\n')
+}
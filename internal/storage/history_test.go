@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// plainStorage hides the conditional operations of a storage.
+type plainStorage struct {
+	Storage
+}
+
+func TestAppendHistory(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	for _, store := range []Storage{NewPrefixedStorage(fs, "db/"), plainStorage{fs}} {
+		for i := range 3 {
+			record := HistoryRecord{Time: time.Unix(int64(i), 0).UTC(), Key: "backup.tar.gz", Status: "success", SizeBytes: int64(i)}
+			if err := AppendHistory(ctx, store, record); err != nil {
+				t.Fatalf("AppendHistory() error = %v", err)
+			}
+		}
+
+		records, err := ReadHistory(ctx, store)
+		if err != nil {
+			t.Fatalf("ReadHistory() error = %v", err)
+		}
+		if len(records) != 3 || records[0].SizeBytes != 0 || records[2].SizeBytes != 2 {
+			t.Errorf("ReadHistory() = %+v, want 3 records oldest first", records)
+		}
+	}
+
+	// Retention and the catalog skip the history like the state object
+	if !IsReservedKey(HistoryKey) {
+		t.Errorf("IsReservedKey(%s) = false", HistoryKey)
+	}
+}
+
+// readFailingStorage fails every read of an object, as a transient outage does,
+// while Stat still finds it.
+type readFailingStorage struct {
+	*FilesystemStorage
+}
+
+func (s readFailingStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func (s readFailingStorage) ReadVersion(ctx context.Context, key string) ([]byte, string, error) {
+	return nil, "", errors.New("connection reset by peer")
+}
+
+func TestAppendHistory_ReadFails(t *testing.T) {
+	ctx := context.Background()
+
+	for _, conditional := range []bool{true, false} {
+		t.Run(fmt.Sprintf("conditional %v", conditional), func(t *testing.T) {
+			fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+			if err != nil {
+				t.Fatalf("NewFilesystemStorage() error = %v", err)
+			}
+			if err := AppendHistory(ctx, fs, HistoryRecord{Key: "first.tar.gz", Status: "success"}); err != nil {
+				t.Fatalf("AppendHistory() error = %v", err)
+			}
+
+			// Only a missing history is started afresh; an unreadable one is kept
+			var store Storage = readFailingStorage{fs}
+			if !conditional {
+				store = plainStorage{store}
+			}
+			if err := AppendHistory(ctx, store, HistoryRecord{Key: "second.tar.gz", Status: "success"}); err == nil {
+				t.Error("AppendHistory() error = nil, want the read error")
+			}
+			if history, err := ReadHistory(ctx, fs); err != nil || len(history) != 1 || history[0].Key != "first.tar.gz" {
+				t.Errorf("ReadHistory() = %v, %v, want the first record kept", history, err)
+			}
+		})
+	}
+}
+
+func TestAppendLine(t *testing.T) {
+	data := []byte(strings.Repeat("{}\n", MaxHistoryRecords))
+	got := appendLine(data, []byte(`{"key":"new"}`))
+	lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+	if len(lines) != MaxHistoryRecords || lines[len(lines)-1] != `{"key":"new"}` {
+		t.Errorf("appendLine() kept %d lines ending %s, want %d ending with the new record", len(lines), lines[len(lines)-1], MaxHistoryRecords)
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrIntegrity reports an upload whose checksum returned by the provider does
+// not match the one computed from the data sent.
+var ErrIntegrity = errors.New("upload integrity check failed")
+
+// etagIsMD5 reports whether S3 derives the ETag of an object from MD5 digests;
+// with KMS or customer-provided keys the ETag is opaque.
+func etagIsMD5(sse types.ServerSideEncryption, sseCustomerAlgorithm *string) bool {
+	return !strings.HasPrefix(string(sse), "aws:kms") && aws.ToString(sseCustomerAlgorithm) == ""
+}
+
+// multipartETag returns the ETag S3 gives a multipart upload of parts with
+// the given MD5 digests: the MD5 of the concatenated digests, a dash and the
+// number of parts.
+func multipartETag(partSums [][]byte) string {
+	h := md5.New()
+	for _, sum := range partSums {
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partSums))
+}
+
+// uploadManagerETag returns the ETag of data uploaded in memory by the upload
+// manager, which sends it in one request up to partSize bytes and in parts of
+// partSize beyond.
+func uploadManagerETag(data []byte, partSize int64) string {
+	if int64(len(data)) <= partSize {
+		sum := md5.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+	var sums [][]byte
+	for start := int64(0); start < int64(len(data)); start += partSize {
+		sum := md5.Sum(data[start:min(start+partSize, int64(len(data)))])
+		sums = append(sums, sum[:])
+	}
+	return multipartETag(sums)
+}
+
+// checkETag compares an ETag returned by S3 with the one computed locally.
+func checkETag(got *string, want string) error {
+	if etag := strings.Trim(aws.ToString(got), `"`); etag != want {
+		return fmt.Errorf("%w: the provider returned ETag %q, the data sent has %q", ErrIntegrity, etag, want)
+	}
+	return nil
+}
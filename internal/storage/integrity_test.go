@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestUploadManagerETag(t *testing.T) {
+	md5Hex := func(data []byte) string {
+		sum := md5.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+
+	small := []byte("hello")
+	if got, want := uploadManagerETag(small, 8), md5Hex(small); got != want {
+		t.Errorf("single request ETag = %q, want %q", got, want)
+	}
+
+	// Two full parts and a short one
+	data := bytes.Repeat([]byte("abcd"), 5)
+	a, b, c := md5.Sum(data[:8]), md5.Sum(data[8:16]), md5.Sum(data[16:])
+	want := multipartETag([][]byte{a[:], b[:], c[:]})
+	if got := uploadManagerETag(data, 8); got != want {
+		t.Errorf("multipart ETag = %q, want %q", got, want)
+	}
+	if len(want) < 2 || want[len(want)-2:] != "-3" {
+		t.Errorf("multipart ETag %q does not end in the part count", want)
+	}
+}
+
+func TestEtagIsMD5(t *testing.T) {
+	tests := []struct {
+		name string
+		sse  types.ServerSideEncryption
+		sseC *string
+		want bool
+	}{
+		{"no encryption", "", nil, true},
+		{"SSE-S3", types.ServerSideEncryptionAes256, nil, true},
+		{"SSE-KMS", types.ServerSideEncryptionAwsKms, nil, false},
+		{"DSSE-KMS", types.ServerSideEncryptionAwsKmsDsse, nil, false},
+		{"SSE-C", types.ServerSideEncryptionAes256, aws.String("AES256"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagIsMD5(tt.sse, tt.sseC); got != tt.want {
+				t.Errorf("etagIsMD5() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckETag(t *testing.T) {
+	if err := checkETag(aws.String(`"abc-2"`), "abc-2"); err != nil {
+		t.Errorf("checkETag() with matching quoted ETag error = %v", err)
+	}
+	if err := checkETag(aws.String(`"abc"`), "abd"); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("checkETag() with mismatch error = %v, want ErrIntegrity", err)
+	}
+	if err := checkETag(nil, "abc"); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("checkETag() with missing ETag error = %v, want ErrIntegrity", err)
+	}
+}
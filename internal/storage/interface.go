@@ -12,9 +12,24 @@ type Storage interface {
 	// Upload stores a backup file with the given key.
 	Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error
 
+	// Open returns a reader for the backup file with the given key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// OpenRange returns a reader for length bytes of the object stored under
+	// key, starting at offset, or for the rest of it when length is negative.
+	OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
 	// Delete removes a backup file with the given key.
 	Delete(ctx context.Context, key string) error
 
+	// Copy copies the object at srcKey to dstKey with its metadata, within the
+	// provider when it can, without downloading the data.
+	Copy(ctx context.Context, srcKey, dstKey string) error
+
+	// Stat returns the size, last modified time and metadata of the object
+	// stored under key, or an error wrapping ErrNotFound when there is none.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
 	// List returns all backup files matching the given prefix.
 	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
 
@@ -29,3 +44,34 @@ type ObjectInfo struct {
 	LastModified time.Time
 	Metadata     map[string]string
 }
+
+// IsReservedKey reports whether key names an object kept next to the backups,
+// such as the state, lease, history, catalog, pause or retention report object,
+// a stored schema or a capability probe left behind, rather than a backup.
+func IsReservedKey(key string) bool {
+	return key == StateKey || key == LeaseKey || key == HistoryKey || key == CatalogKey || key == PauseKey || key == RetentionReportKey ||
+		key == CapabilityProbeKey || IsSchemaKey(key)
+}
+
+// limitReadCloser returns a reader for the first length bytes of rc, or for
+// all of it when length is negative, that closes rc.
+func limitReadCloser(rc io.ReadCloser, length int64) io.ReadCloser {
+	if length < 0 {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, length), rc}
+}
+
+// backupObjects drops reserved objects from a listing.
+func backupObjects(objects []ObjectInfo) []ObjectInfo {
+	backups := objects[:0]
+	for _, obj := range objects {
+		if !IsReservedKey(obj.Key) {
+			backups = append(backups, obj)
+		}
+	}
+	return backups
+}
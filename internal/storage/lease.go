@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LeaseKey names the object that locks a backup run, so two instances sharing
+// a bucket never dump and upload the same database concurrently.
+const LeaseKey = "lock.json"
+
+// ErrLeaseLost is the cause of a lease context cancelled because the lease
+// could not be renewed before it expired or was taken over.
+var ErrLeaseLost = errors.New("lease lost")
+
+// LeaseRecord is the content of the lease object.
+type LeaseRecord struct {
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LeaseHeldError is returned when another holder has an unexpired lease.
+type LeaseHeldError struct {
+	Holder    string
+	ExpiresAt time.Time
+}
+
+func (e *LeaseHeldError) Error() string {
+	return fmt.Sprintf("lease is held by %s until %s", e.Holder, e.ExpiresAt.Format(time.RFC3339))
+}
+
+// Lease is a lock held in storage. It is renewed in the background until it
+// is released, and expires on its own if the holder dies.
+type Lease struct {
+	store  ConditionalStorage
+	record LeaseRecord
+	ttl    time.Duration
+	logger *slog.Logger
+
+	// version is only touched by renew once the lease is acquired
+	version string
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// AcquireLease takes the lease for holder, replacing an expired lease left by a
+// holder that died. A *LeaseHeldError is returned when the lease is taken.
+func AcquireLease(ctx context.Context, store ConditionalStorage, holder string, ttl time.Duration, logger *slog.Logger) (*Lease, error) {
+	data, version, err := store.ReadVersion(ctx, LeaseKey)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		version = ""
+	case err != nil:
+		return nil, fmt.Errorf("failed to read lease: %w", err)
+	default:
+		var current LeaseRecord
+		if err := json.Unmarshal(data, &current); err != nil {
+			logger.Warn("Replacing unreadable lease", "error", err)
+		} else if time.Now().Before(current.ExpiresAt) {
+			return nil, &LeaseHeldError{Holder: current.Holder, ExpiresAt: current.ExpiresAt}
+		} else {
+			logger.Warn("Taking over expired lease", "holder", current.Holder, "expired_at", current.ExpiresAt)
+		}
+	}
+
+	now := time.Now().UTC()
+	l := &Lease{
+		store:  store,
+		record: LeaseRecord{Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(ttl)},
+		ttl:    ttl,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	l.version, err = l.write(ctx, version)
+	if errors.Is(err, ErrPreconditionFailed) {
+		// Another instance acquired or renewed the lease since it was read
+		return nil, &LeaseHeldError{Holder: "another instance", ExpiresAt: now.Add(ttl)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	l.ctx, l.cancel = context.WithCancelCause(ctx)
+	go l.renew()
+	return l, nil
+}
+
+// Context returns a context derived from the one passed to AcquireLease that
+// is cancelled with ErrLeaseLost if the lease can no longer be renewed.
+func (l *Lease) Context() context.Context {
+	return l.ctx
+}
+
+// releaseAttempts bounds the deletes of a lease that failed to release. A
+// lease left behind blocks every run until it expires.
+const releaseAttempts = 3
+
+// Release stops renewing the lease and deletes it, unless it was taken over. A
+// failed delete is retried; one that went through before failing is reported
+// as missing on the retry.
+func (l *Lease) Release(ctx context.Context) error {
+	l.cancel(nil)
+	<-l.done
+
+	var err error
+	for attempt := 1; attempt <= releaseAttempts; attempt++ {
+		err = l.store.DeleteIfVersion(ctx, LeaseKey, l.version)
+		switch {
+		case err == nil, errors.Is(err, ErrNotFound):
+			return nil
+		case errors.Is(err, ErrPreconditionFailed):
+			return fmt.Errorf("lease was taken over by another instance")
+		}
+		if attempt < releaseAttempts {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("failed to release lease: %w", err)
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		}
+	}
+	return fmt.Errorf("failed to release lease: %w", err)
+}
+
+// renew extends the lease every third of its TTL, so a couple of failed
+// renewals are tolerated before it expires.
+func (l *Lease) renew() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		expires := l.record.ExpiresAt
+		l.record.ExpiresAt = time.Now().UTC().Add(l.ttl)
+		version, err := l.write(l.ctx, l.version)
+		if err == nil {
+			l.version = version
+		} else {
+			l.record.ExpiresAt = expires
+		}
+
+		switch {
+		case err == nil:
+			l.logger.Debug("Renewed lease", "expires_at", l.record.ExpiresAt)
+		case l.ctx.Err() != nil:
+			return
+		case errors.Is(err, ErrPreconditionFailed), errors.Is(err, ErrNotFound):
+			l.logger.Error("Lease was taken over by another instance")
+			l.cancel(ErrLeaseLost)
+			return
+		case time.Now().After(expires):
+			l.logger.Error("Lease expired before it could be renewed", "error", err)
+			l.cancel(ErrLeaseLost)
+			return
+		default:
+			l.logger.Warn("Failed to renew lease, retrying", "error", err, "expires_at", expires)
+		}
+	}
+}
+
+// write stores the lease record if the lease object has the given version.
+func (l *Lease) write(ctx context.Context, version string) (string, error) {
+	data, err := json.Marshal(l.record)
+	if err != nil {
+		return "", err
+	}
+	return l.store.WriteIfVersion(ctx, LeaseKey, data, version)
+}
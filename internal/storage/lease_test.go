@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	newStore := func(t *testing.T) *FilesystemStorage {
+		fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewFilesystemStorage() error = %v", err)
+		}
+		return fs
+	}
+
+	t.Run("held lease blocks a second holder until released", func(t *testing.T) {
+		fs := newStore(t)
+		lease, err := AcquireLease(ctx, fs, "a", time.Minute, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+
+		_, err = AcquireLease(ctx, fs, "b", time.Minute, logger)
+		var held *LeaseHeldError
+		if !errors.As(err, &held) || held.Holder != "a" {
+			t.Fatalf("second AcquireLease() error = %v, want held by a", err)
+		}
+
+		if err := lease.Release(ctx); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+		if lease.Context().Err() == nil {
+			t.Error("lease context not cancelled after Release()")
+		}
+
+		next, err := AcquireLease(ctx, fs, "b", time.Minute, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() after release error = %v", err)
+		}
+		_ = next.Release(ctx)
+	})
+
+	t.Run("expired lease is taken over", func(t *testing.T) {
+		fs := newStore(t)
+		stale, _ := json.Marshal(LeaseRecord{Holder: "dead", ExpiresAt: time.Now().Add(-time.Minute)})
+		if _, err := fs.WriteIfVersion(ctx, LeaseKey, stale, ""); err != nil {
+			t.Fatalf("WriteIfVersion() error = %v", err)
+		}
+
+		lease, err := AcquireLease(ctx, fs, "b", time.Minute, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() error = %v, want the expired lease taken over", err)
+		}
+		_ = lease.Release(ctx)
+	})
+
+	t.Run("renewal keeps the lease", func(t *testing.T) {
+		fs := newStore(t)
+		lease, err := AcquireLease(ctx, fs, "a", 30*time.Millisecond, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+
+		// Several renewals happen, each extending the expiry
+		time.Sleep(100 * time.Millisecond)
+		data, _, err := fs.ReadVersion(ctx, LeaseKey)
+		if err != nil {
+			t.Fatalf("ReadVersion() error = %v", err)
+		}
+		var record LeaseRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatalf("invalid lease record: %v", err)
+		}
+		if !record.ExpiresAt.After(record.AcquiredAt.Add(30 * time.Millisecond)) {
+			t.Errorf("lease expires at %v, want renewed past %v", record.ExpiresAt, record.AcquiredAt.Add(30*time.Millisecond))
+		}
+		if lease.Context().Err() != nil {
+			t.Errorf("lease context cancelled: %v", context.Cause(lease.Context()))
+		}
+
+		if err := lease.Release(ctx); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+		if _, _, err := fs.ReadVersion(ctx, LeaseKey); !errors.Is(err, ErrNotFound) {
+			t.Errorf("lease object still present after Release(): %v", err)
+		}
+	})
+
+	t.Run("lease taken over cancels the holder", func(t *testing.T) {
+		fs := newStore(t)
+		lease, err := AcquireLease(ctx, fs, "a", 30*time.Millisecond, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+
+		// Another instance overwrites the lease
+		if err := fs.Upload(ctx, LeaseKey, strings.NewReader("{}"), nil); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+
+		select {
+		case <-lease.Context().Done():
+		case <-time.After(time.Second):
+			t.Fatal("lease context not cancelled after takeover")
+		}
+		if !errors.Is(context.Cause(lease.Context()), ErrLeaseLost) {
+			t.Errorf("cause = %v, want ErrLeaseLost", context.Cause(lease.Context()))
+		}
+		if err := lease.Release(ctx); err == nil {
+			t.Error("Release() expected error for a lease taken over")
+		}
+	})
+
+	t.Run("failed release is retried", func(t *testing.T) {
+		fs := newStore(t)
+		store := &flakyDeletes{ConditionalStorage: fs, failures: 1}
+		lease, err := AcquireLease(ctx, store, "a", time.Minute, logger)
+		if err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+
+		if err := lease.Release(ctx); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+		if _, _, err := fs.ReadVersion(ctx, LeaseKey); !errors.Is(err, ErrNotFound) {
+			t.Errorf("lease object still present after Release(): %v", err)
+		}
+	})
+}
+
+// flakyDeletes fails the first conditional deletes of the storage it embeds.
+type flakyDeletes struct {
+	ConditionalStorage
+	failures int
+}
+
+func (f *flakyDeletes) DeleteIfVersion(ctx context.Context, key, version string) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("connection reset")
+	}
+	return f.ConditionalStorage.DeleteIfVersion(ctx, key, version)
+}
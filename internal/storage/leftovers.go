@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// LeftoverCleaner is implemented by storages that can remove the partial
+// artifacts of an upload killed midway, such as temp files or unfinished
+// multipart uploads.
+type LeftoverCleaner interface {
+	// CleanupLeftovers removes the partial artifacts of an upload to key and
+	// returns how many were removed.
+	CleanupLeftovers(ctx context.Context, key string) (int, error)
+}
+
+// CleanupLeftovers removes the partial artifacts of an upload to key from every
+// storage behind store, unwrapping retries, the local cache, destinations,
+// key prefixes and fault injection. Storages without leftovers to clean are
+// skipped.
+func CleanupLeftovers(ctx context.Context, store Storage, key string) (int, error) {
+	switch s := store.(type) {
+	case *RetryableStorage:
+		// Cleaning up is idempotent, so a failed attempt is retried
+		var removed int
+		err := s.retry(ctx, func() error {
+			n, err := CleanupLeftovers(ctx, s.storage, key)
+			removed += n
+			return err
+		})
+		return removed, err
+	case *CachedStorage:
+		n, err := CleanupLeftovers(ctx, s.remote, key)
+		m, cacheErr := s.cache.CleanupLeftovers(ctx, key)
+		return n + m, errors.Join(err, cacheErr)
+	case *MultiStorage:
+		total := 0
+		var errs []error
+		for _, dest := range s.destinations {
+			n, err := CleanupLeftovers(ctx, dest.Storage, key)
+			total += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", dest.Name, err))
+			}
+		}
+		return total, errors.Join(errs...)
+	case *PrefixedStorage:
+		return CleanupLeftovers(ctx, s.storage, s.prefix+key)
+	case *FaultyStorage:
+		if err := s.fault("delete", key, s.config.DeletePercent); err != nil {
+			return 0, err
+		}
+		return CleanupLeftovers(ctx, s.storage, key)
+	case LeftoverCleaner:
+		return s.CleanupLeftovers(ctx, key)
+	}
+	return 0, nil
+}
+
+// CleanupLeftovers implements LeftoverCleaner by removing the temp files an
+// atomic write leaves behind when the process dies before renaming them.
+func (f *FilesystemStorage) CleanupLeftovers(ctx context.Context, key string) (int, error) {
+	fullPath, err := f.getFullPath(key)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var errs []error
+	for _, dst := range []string{fullPath, metadataPath(fullPath)} {
+		// Named as in writeFileAtomic
+		matches, err := filepath.Glob(filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*"))
+		if err != nil {
+			return removed, err
+		}
+		for _, match := range matches {
+			if err := os.Remove(match); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// CleanupLeftovers implements LeftoverCleaner by aborting the unfinished
+// multipart uploads of key, whose parts are otherwise billed until a bucket
+// lifecycle rule removes them.
+func (s *S3Storage) CleanupLeftovers(ctx context.Context, key string) (int, error) {
+	fullKey := s.getFullKey(key)
+	output, err := s.client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(fullKey),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list multipart uploads: %w", err)
+	}
+
+	aborted := 0
+	var errs []error
+	for _, upload := range output.Uploads {
+		if aws.ToString(upload.Key) != fullKey {
+			continue
+		}
+		_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      upload.Key,
+			UploadId: upload.UploadId,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to abort multipart upload %s: %w", aws.ToString(upload.UploadId), err))
+			continue
+		}
+		aborted++
+	}
+	return aborted, errors.Join(errs...)
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilesystemStorage_CleanupLeftovers(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: root})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	if err := fs.Upload(ctx, "db/2024/01/other.tar.gz", strings.NewReader("data"), map[string]string{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Temp files of a write killed before the rename
+	dir := filepath.Join(root, "db", "2024", "01")
+	for _, name := range []string{".backup.tar.gz.tmp-123", "..backup.tar.gz.metadata.json.tmp-456", ".other.tar.gz.tmp-789"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("partial"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := NewRetryableStorage(NewPrefixedStorage(fs, "db/"), DefaultRetryConfig())
+	removed, err := CleanupLeftovers(ctx, store, "2024/01/backup.tar.gz")
+	if err != nil {
+		t.Fatalf("CleanupLeftovers() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("CleanupLeftovers() removed %d, want 2", removed)
+	}
+
+	// Leftovers of other keys and complete objects are kept
+	for _, name := range []string{".other.tar.gz.tmp-789", "other.tar.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ListIterator is implemented by storages that can stream a listing rather
+// than hold all of it in memory, for buckets with tens of thousands of objects.
+type ListIterator interface {
+	// ListIter calls fn for each object matching prefix, stopping at and
+	// returning the first error fn returns.
+	ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// ListIter calls fn for each object in store matching prefix, a page at a time
+// for storages that support it and from a full listing otherwise. It stops at
+// and returns the first error fn returns.
+func ListIter(ctx context.Context, store Storage, prefix string, fn func(ObjectInfo) error) error {
+	if iter, ok := store.(ListIterator); ok {
+		return iter.ListIter(ctx, prefix, fn)
+	}
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListIter implements ListIterator with retry logic. Once objects have been
+// passed to fn, a failed listing is returned rather than restarted, so fn
+// never sees an object twice.
+func (r *RetryableStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	delivered := false
+	var stopped error
+	err := r.retry(ctx, func() error {
+		err := ListIter(ctx, r.storage, prefix, func(obj ObjectInfo) error {
+			delivered = true
+			return fn(obj)
+		})
+		if err != nil && delivered {
+			stopped = err
+			return nil
+		}
+		return err
+	})
+	if stopped != nil {
+		return stopped
+	}
+	return err
+}
+
+// ListIter implements ListIterator, streaming each destination in turn and
+// skipping keys already seen in an earlier one.
+func (m *MultiStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	seen := make(map[string]bool)
+	var failed []error
+
+	for _, dest := range m.destinations {
+		var fnErr error
+		err := ListIter(ctx, dest.Storage, prefix, func(obj ObjectInfo) error {
+			if seen[obj.Key] {
+				return nil
+			}
+			seen[obj.Key] = true
+			fnErr = fn(obj)
+			return fnErr
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+		}
+	}
+
+	if len(failed) == len(m.destinations) {
+		return fmt.Errorf("list failed on all destinations: %w", errors.Join(failed...))
+	}
+	return nil
+}
+
+// ListIter implements ListIterator for the keys under the prefix.
+func (p *PrefixedStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return ListIter(ctx, p.storage, p.prefix+prefix, func(obj ObjectInfo) error {
+		key, ok := strings.CutPrefix(obj.Key, p.prefix)
+		if !ok {
+			return nil
+		}
+		obj.Key = key
+		return fn(obj)
+	})
+}
+
+// ListIter implements ListIterator.
+func (c *CachedStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return ListIter(ctx, c.remote, prefix, fn)
+}
+
+// ListIter implements ListIterator.
+func (r *ReadOnlyStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return ListIter(ctx, r.storage, prefix, fn)
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagedStorage streams its listing, failing with err after the listed objects.
+type pagedStorage struct {
+	*mockStorage
+	err   error
+	calls int
+}
+
+func (p *pagedStorage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	p.calls++
+	for _, obj := range p.listResult {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return p.err
+}
+
+// listKeys collects the keys ListIter passes to its callback.
+func listKeys(t *testing.T, store Storage, prefix string) ([]string, error) {
+	t.Helper()
+	var keys []string
+	err := ListIter(context.Background(), store, prefix, func(obj ObjectInfo) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	return keys, err
+}
+
+func TestListIter_FallsBackToList(t *testing.T) {
+	store := &mockStorage{listResult: []ObjectInfo{{Key: "a"}, {Key: "b"}, {Key: "c"}}}
+
+	keys, err := listKeys(t, store, "")
+	if err != nil || !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("ListIter() = %v, %v; want [a b c]", keys, err)
+	}
+
+	// An error from the callback stops the listing and is returned
+	stop := errors.New("stop")
+	var seen []string
+	err = ListIter(context.Background(), store, "", func(obj ObjectInfo) error {
+		seen = append(seen, obj.Key)
+		return stop
+	})
+	if !errors.Is(err, stop) || len(seen) != 1 {
+		t.Errorf("ListIter() saw %v, error = %v; want [a], %v", seen, err, stop)
+	}
+}
+
+func TestFilesystemStorage_ListIter(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	for _, key := range []string{"backup-1.tar.gz", "backup-2.tar.gz", "other.tar.gz"} {
+		if err := fs.Upload(ctx, key, strings.NewReader("data"), nil); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	keys, err := listKeys(t, fs, "backup-")
+	if err != nil || !slices.Equal(keys, []string{"backup-1.tar.gz", "backup-2.tar.gz"}) {
+		t.Errorf("ListIter() = %v, %v; want the two backups", keys, err)
+	}
+
+	stop := errors.New("stop")
+	err = fs.ListIter(ctx, "", func(ObjectInfo) error { return stop })
+	if err != stop {
+		t.Errorf("ListIter() error = %v, want the callback's error unwrapped", err)
+	}
+}
+
+func TestRetryableStorage_ListIter(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 2.0}
+	listErr := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		objects   []ObjectInfo
+		wantCalls int
+		wantKeys  []string
+	}{
+		{
+			name:      "failure before any object is retried",
+			wantCalls: 3,
+		},
+		{
+			name:      "failure after delivering objects is not restarted",
+			objects:   []ObjectInfo{{Key: "a"}},
+			wantCalls: 1,
+			wantKeys:  []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged := &pagedStorage{mockStorage: &mockStorage{listResult: tt.objects}, err: listErr}
+
+			keys, err := listKeys(t, NewRetryableStorage(paged, config), "")
+			if !errors.Is(err, listErr) {
+				t.Errorf("ListIter() error = %v, want %v", err, listErr)
+			}
+			if paged.calls != tt.wantCalls {
+				t.Errorf("ListIter() made %d calls, want %d", paged.calls, tt.wantCalls)
+			}
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("ListIter() = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestMultiStorage_ListIter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	unreachable := &mockStorage{listErr: errors.New("unreachable")}
+
+	multi := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: &mockStorage{listResult: []ObjectInfo{{Key: "a"}, {Key: "b"}}}},
+		{Name: "filesystem", Storage: unreachable},
+		{Name: "gcs", Storage: &pagedStorage{mockStorage: &mockStorage{listResult: []ObjectInfo{{Key: "b"}, {Key: "c"}}}}},
+	}, true, logger)
+
+	keys, err := listKeys(t, multi, "")
+	if err != nil || !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("ListIter() = %v, %v; want [a b c] once each", keys, err)
+	}
+
+	all := NewMultiStorage([]Destination{{Name: "filesystem", Storage: unreachable}}, true, logger)
+	if _, err := listKeys(t, all, ""); err == nil {
+		t.Error("ListIter() error = nil when every destination failed")
+	}
+}
+
+func TestPrefixedStorage_ListIter(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	for _, key := range []string{"orders/a.tar.gz", "orders-archive/b.tar.gz", "users/c.tar.gz"} {
+		if err := fs.Upload(ctx, key, strings.NewReader("data"), nil); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	keys, err := listKeys(t, NewPrefixedStorage(fs, "orders"), "")
+	if err != nil || !slices.Equal(keys, []string{"a.tar.gz"}) {
+		t.Errorf("ListIter() = %v, %v; want [a.tar.gz]", keys, err)
+	}
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// headConcurrency bounds the HEAD requests sent to fill in listed metadata.
+const headConcurrency = 8
+
+// MetadataLister is implemented by storages whose List leaves metadata empty
+// because reading it costs an extra request per object.
+type MetadataLister interface {
+	// ListWithMetadata returns the objects matching prefix with their metadata.
+	ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// ListWithMetadata lists the objects matching prefix with their metadata,
+// making the extra requests only for storages that need them. Narrow the
+// prefix where possible, as each listed object may cost a request.
+func ListWithMetadata(ctx context.Context, store Storage, prefix string) ([]ObjectInfo, error) {
+	if lister, ok := store.(MetadataLister); ok {
+		return lister.ListWithMetadata(ctx, prefix)
+	}
+	return store.List(ctx, prefix)
+}
+
+// ListBackups lists the backups whose filename starts with filenamePrefix (any
+// backup when empty) with their metadata, newest first by BackupTime. State,
+// lease and sidecar objects are skipped.
+func ListBackups(ctx context.Context, store Storage, filenamePrefix string) ([]ObjectInfo, error) {
+	objects, err := ListWithMetadata(ctx, store, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	backups := slices.DeleteFunc(objects, func(obj ObjectInfo) bool {
+		return IsReservedKey(obj.Key) || utils.IsSidecar(obj.Key) || !strings.HasPrefix(path.Base(obj.Key), filenamePrefix)
+	})
+	slices.SortStableFunc(backups, func(a, b ObjectInfo) int {
+		return BackupTime(b).Compare(BackupTime(a))
+	})
+	return backups, nil
+}
+
+// BackupTime returns when the backup obj was taken, read from its
+// backup-timestamp metadata, else its filename, else its last modified time.
+func BackupTime(obj ObjectInfo) time.Time {
+	if t, err := time.Parse(time.RFC3339, obj.Metadata["backup-timestamp"]); err == nil {
+		return t
+	}
+	if t, err := utils.ParseBackupFilename(path.Base(obj.Key)); err == nil {
+		return t
+	}
+	return obj.LastModified
+}
+
+// listedBackupTime returns when the most recently modified backup in store was
+// taken, from its backup-timestamp metadata, else its last modified time.
+// The listing is streamed, keeping only the newest object, and metadata it
+// omitted is read with Stat. Zero is returned when there are no backups.
+func listedBackupTime(ctx context.Context, store Storage) (time.Time, error) {
+	var newest *ObjectInfo
+	err := ListIter(ctx, store, "", func(obj ObjectInfo) error {
+		if !IsReservedKey(obj.Key) && (newest == nil || obj.LastModified.After(newest.LastModified)) {
+			newest = &obj
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if newest == nil {
+		return time.Time{}, nil
+	}
+
+	if _, ok := newest.Metadata["backup-timestamp"]; !ok {
+		if info, err := store.Stat(ctx, newest.Key); err == nil {
+			newest = info
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, newest.Metadata["backup-timestamp"]); err == nil {
+		return t, nil
+	}
+	return newest.LastModified, nil
+}
+
+// LatestBackup returns the most recent backup whose filename starts with
+// filenamePrefix (any backup when empty) and the time it was taken, as
+// returned by BackupTime. The newest backup is picked from the listing, by
+// its filename timestamp where the listing omits metadata, and only that
+// object is read with Stat.
+func LatestBackup(ctx context.Context, store Storage, filenamePrefix string) (*ObjectInfo, time.Time, error) {
+	var newest *ObjectInfo
+	var newestTime time.Time
+	err := ListIter(ctx, store, "", func(obj ObjectInfo) error {
+		if IsReservedKey(obj.Key) || utils.IsSidecar(obj.Key) || !strings.HasPrefix(path.Base(obj.Key), filenamePrefix) {
+			return nil
+		}
+		if taken := BackupTime(obj); newest == nil || taken.After(newestTime) {
+			newest, newestTime = &obj, taken
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to list backups: %w", err)
+	}
+	if newest == nil {
+		return nil, time.Time{}, fmt.Errorf("no backups found: %w", ErrNotFound)
+	}
+
+	info, err := store.Stat(ctx, newest.Key)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat latest backup %s: %w", newest.Key, err)
+	}
+	return info, BackupTime(*info), nil
+}
+
+// HeadObjectAPI is the subset of the S3 client used to read object metadata.
+type HeadObjectAPI interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// ListWithMetadata implements MetadataLister with a HEAD request per object.
+func (s *S3Storage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return headObjects(ctx, s.client, s.bucket, s.getFullKey, objects, headConcurrency)
+}
+
+// headObject returns the details of key, stored under fullKey, read with a
+// HEAD request.
+func headObject(ctx context.Context, client HeadObjectAPI, bucket, fullKey, key string) (*ObjectInfo, error) {
+	resp, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(fullKey),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to stat S3 object %s: %w", key, err)
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(resp.ContentLength),
+		LastModified: aws.ToTime(resp.LastModified),
+		Metadata:     resp.Metadata,
+	}
+	if info.Metadata == nil {
+		info.Metadata = make(map[string]string)
+	}
+	return info, nil
+}
+
+// headObjects fills in the metadata of objects with at most concurrency HEAD
+// requests in flight. Objects deleted since they were listed are dropped.
+func headObjects(ctx context.Context, client HeadObjectAPI, bucket string, fullKey func(string) string,
+	objects []ObjectInfo, concurrency int) ([]ObjectInfo, error) {
+	found := make([]bool, len(objects))
+	errs := make([]error, len(objects))
+	slots := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i := range objects {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			resp, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(fullKey(objects[i].Key)),
+			})
+			if err != nil {
+				var notFound *types.NotFound
+				if !errors.As(err, &notFound) {
+					errs[i] = fmt.Errorf("failed to read metadata of %s: %w", objects[i].Key, err)
+				}
+				return
+			}
+			objects[i].Metadata = resp.Metadata
+			if objects[i].Metadata == nil {
+				objects[i].Metadata = make(map[string]string)
+			}
+			found[i] = true
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	result := objects[:0]
+	for i, obj := range objects {
+		if found[i] {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+// ListWithMetadata implements MetadataLister with retry logic.
+func (r *RetryableStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var result []ObjectInfo
+	err := r.retry(ctx, func() error {
+		var err error
+		result, err = ListWithMetadata(ctx, r.storage, prefix)
+		return err
+	})
+	return result, err
+}
+
+// ListWithMetadata implements MetadataLister, merging results from all destinations by key.
+func (m *MultiStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return m.list(ctx, prefix, ListWithMetadata)
+}
+
+// ListWithMetadata implements MetadataLister for the keys under the prefix.
+func (p *PrefixedStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := ListWithMetadata(ctx, p.storage, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	return p.scope(objects), nil
+}
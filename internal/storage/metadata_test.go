@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeHeadAPI serves object metadata and records the peak number of requests in flight.
+type fakeHeadAPI struct {
+	metadata map[string]map[string]string
+	errs     map[string]error
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (f *fakeHeadAPI) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	time.Sleep(time.Millisecond)
+
+	key := aws.ToString(in.Key)
+	if err, ok := f.errs[key]; ok {
+		return nil, err
+	}
+	metadata, ok := f.metadata[key]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{Metadata: metadata}, nil
+}
+
+func TestHeadObjects(t *testing.T) {
+	fullKey := func(key string) string { return "backups/" + key }
+
+	t.Run("fills metadata with bounded concurrency", func(t *testing.T) {
+		api := &fakeHeadAPI{metadata: map[string]map[string]string{}}
+		var objects []ObjectInfo
+		for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+			api.metadata["backups/"+key] = map[string]string{"backup-timestamp": key}
+			objects = append(objects, ObjectInfo{Key: key})
+		}
+		// Deleted between the listing and the HEAD request
+		objects = append(objects, ObjectInfo{Key: "gone"})
+
+		result, err := headObjects(context.Background(), api, "bucket", fullKey, objects, 2)
+		if err != nil {
+			t.Fatalf("headObjects() error = %v", err)
+		}
+		if len(result) != 6 {
+			t.Fatalf("headObjects() returned %d objects, want 6", len(result))
+		}
+		for _, obj := range result {
+			if obj.Metadata["backup-timestamp"] != obj.Key {
+				t.Errorf("metadata of %s = %v", obj.Key, obj.Metadata)
+			}
+		}
+		if api.peak > 2 {
+			t.Errorf("peak concurrent requests = %d, want at most 2", api.peak)
+		}
+	})
+
+	t.Run("returns request errors", func(t *testing.T) {
+		api := &fakeHeadAPI{
+			metadata: map[string]map[string]string{"backups/a": {}},
+			errs:     map[string]error{"backups/b": errors.New("access denied")},
+		}
+		_, err := headObjects(context.Background(), api, "bucket", fullKey, []ObjectInfo{{Key: "a"}, {Key: "b"}}, 4)
+		if err == nil {
+			t.Error("headObjects() expected error")
+		}
+	})
+}
+
+func TestHeadObject(t *testing.T) {
+	api := &fakeHeadAPI{
+		metadata: map[string]map[string]string{"backups/a": {"backup-timestamp": "2024-01-15T10:30:00Z"}},
+		errs:     map[string]error{"backups/b": errors.New("access denied")},
+	}
+	ctx := context.Background()
+
+	info, err := headObject(ctx, api, "bucket", "backups/a", "a")
+	if err != nil || info.Key != "a" || info.Metadata["backup-timestamp"] != "2024-01-15T10:30:00Z" {
+		t.Errorf("headObject() = %+v, %v; want a with its metadata", info, err)
+	}
+	if _, err := headObject(ctx, api, "bucket", "backups/gone", "gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("headObject() of a missing key error = %v, want ErrNotFound", err)
+	}
+	if _, err := headObject(ctx, api, "bucket", "backups/b", "b"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("headObject() error = %v, want the request error", err)
+	}
+}
+
+// statStorage serves metadata from Stat that its listing omits.
+type statStorage struct {
+	*mockStorage
+	stat map[string]ObjectInfo
+}
+
+func (s *statStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	s.statCalls++
+	if info, ok := s.stat[key]; ok {
+		return &info, nil
+	}
+	return nil, ErrNotFound
+}
+
+func TestListedBackupTime(t *testing.T) {
+	taken := time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC)
+	modified := time.Date(2024, 1, 15, 3, 5, 0, 0, time.UTC)
+	objects := []ObjectInfo{
+		{Key: "old.tar.gz", LastModified: modified.Add(-time.Hour)},
+		{Key: "new.tar.gz", LastModified: modified},
+		{Key: StateKey, LastModified: modified.Add(time.Hour)},
+	}
+	ctx := context.Background()
+
+	// The listing omitted metadata, so the newest backup is read with Stat
+	store := &statStorage{mockStorage: &mockStorage{listResult: objects}, stat: map[string]ObjectInfo{
+		"new.tar.gz": {Key: "new.tar.gz", LastModified: modified, Metadata: map[string]string{"backup-timestamp": taken.Format(time.RFC3339)}},
+	}}
+	if got, err := listedBackupTime(ctx, store); err != nil || !got.Equal(taken) || store.statCalls != 1 {
+		t.Errorf("listedBackupTime() = %v, %v after %d Stat calls, want %v after 1", got, err, store.statCalls, taken)
+	}
+
+	// Without metadata the last modified time is used
+	if got, err := listedBackupTime(ctx, &mockStorage{listResult: objects}); err != nil || !got.Equal(modified) {
+		t.Errorf("listedBackupTime() = %v, %v, want %v", got, err, modified)
+	}
+
+	if got, err := listedBackupTime(ctx, &mockStorage{listResult: objects[2:]}); err != nil || !got.IsZero() {
+		t.Errorf("listedBackupTime() of reserved objects only = %v, %v, want zero", got, err)
+	}
+
+	listErr := errors.New("list failed")
+	if _, err := listedBackupTime(ctx, &mockStorage{listErr: listErr}); !errors.Is(err, listErr) {
+		t.Errorf("listedBackupTime() error = %v, want %v", err, listErr)
+	}
+}
+
+// headingStorage records whether its metadata was listed with a request per object.
+type headingStorage struct {
+	*statStorage
+	listedWithMetadata bool
+}
+
+func (s *headingStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	s.listedWithMetadata = true
+	return s.List(ctx, prefix)
+}
+
+func TestLatestBackup_StatsOnlyTheNewest(t *testing.T) {
+	taken := time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)
+	newest := "2024/03/app-pg16-2024-03-01T03-00-00-000Z.tar.gz"
+	// Listed without metadata: the filename timestamps decide, not LastModified
+	objects := []ObjectInfo{
+		{Key: newest, LastModified: taken.Add(time.Minute)},
+		{Key: newest + ".sha256", LastModified: taken.Add(2 * time.Minute)},
+		{Key: "2024/01/app-pg16-2024-01-01T03-00-00-000Z.tar.gz", LastModified: taken.Add(time.Hour)},
+		{Key: StateKey, LastModified: taken.Add(2 * time.Hour)},
+	}
+	store := &headingStorage{statStorage: &statStorage{mockStorage: &mockStorage{listResult: objects}, stat: map[string]ObjectInfo{
+		newest: {Key: newest, Size: 42, LastModified: taken.Add(time.Minute), Metadata: map[string]string{"backup-timestamp": taken.Format(time.RFC3339)}},
+	}}}
+
+	obj, got, err := LatestBackup(context.Background(), store, "app")
+	if err != nil {
+		t.Fatalf("LatestBackup() error = %v", err)
+	}
+	if obj.Key != newest || obj.Size != 42 || !got.Equal(taken) {
+		t.Errorf("LatestBackup() = %+v at %v, want the stat of %s at %v", obj, got, newest, taken)
+	}
+	if store.statCalls != 1 || store.listedWithMetadata {
+		t.Errorf("LatestBackup() made %d Stat calls, listed with metadata %v; want 1 and false", store.statCalls, store.listedWithMetadata)
+	}
+
+	// A backup deleted since it was listed is not returned
+	store.stat = nil
+	if _, _, err := LatestBackup(context.Background(), store, "app"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LatestBackup() of a deleted backup error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListWithMetadata_FallsBackToList(t *testing.T) {
+	store := &mockStorage{listResult: []ObjectInfo{{Key: "a", Metadata: map[string]string{"k": "v"}}}}
+
+	objects, err := ListWithMetadata(context.Background(), store, "")
+	if err != nil {
+		t.Fatalf("ListWithMetadata() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Metadata["k"] != "v" {
+		t.Errorf("ListWithMetadata() = %v, want the listed objects", objects)
+	}
+}
+
+func TestLatestBackup(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	if _, _, err := LatestBackup(ctx, fs, "app"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("LatestBackup() on empty storage error = %v, want ErrNotFound", err)
+	}
+
+	newest := time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)
+	uploads := []struct {
+		key       string
+		timestamp time.Time
+	}{
+		// Uploaded last, but taken first: the metadata timestamp decides
+		{key: "2024/03/app-pg16-2024-03-01T03-00-00-000Z.tar.gz", timestamp: newest},
+		{key: "2024/02/other-pg16-2024-04-01T03-00-00-000Z.tar.gz", timestamp: newest.AddDate(0, 1, 0)},
+		{key: "2024/01/app-pg16-2024-01-01T03-00-00-000Z.tar.gz", timestamp: newest.AddDate(0, -2, 0)},
+	}
+	for _, u := range uploads {
+		metadata := map[string]string{"backup-timestamp": u.timestamp.Format(time.RFC3339)}
+		if err := fs.Upload(ctx, u.key, strings.NewReader("data"), metadata); err != nil {
+			t.Fatalf("Upload(%s) error = %v", u.key, err)
+		}
+	}
+	if err := fs.Upload(ctx, StateKey, strings.NewReader("{}"), nil); err != nil {
+		t.Fatalf("Upload() state error = %v", err)
+	}
+
+	obj, taken, err := LatestBackup(ctx, fs, "app")
+	if err != nil {
+		t.Fatalf("LatestBackup() error = %v", err)
+	}
+	if obj.Key != uploads[0].key || !taken.Equal(newest) {
+		t.Errorf("LatestBackup() = %s at %v, want %s at %v", obj.Key, taken, uploads[0].key, newest)
+	}
+
+	if obj, _, err := LatestBackup(ctx, fs, ""); err != nil || obj.Key != uploads[1].key {
+		t.Errorf("LatestBackup() without prefix = %v, %v; want %s", obj, err, uploads[1].key)
+	}
+
+	backups, err := ListBackups(ctx, fs, "app")
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 || backups[0].Key != uploads[0].key || backups[1].Key != uploads[2].key {
+		t.Errorf("ListBackups() = %v, want %s then %s", backups, uploads[0].key, uploads[2].key)
+	}
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/metrics"
+)
+
+// Destination is a named storage backend participating in a MultiStorage.
+type Destination struct {
+	Name          string
+	Storage       Storage
+	RetentionDays int
+}
+
+// DestinationError is the failure of one destination of a MultiStorage upload.
+type DestinationError struct {
+	Name string
+	Err  error
+}
+
+func (e *DestinationError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// MultiStorage replicates backups to several destinations from a single stream.
+type MultiStorage struct {
+	destinations []Destination
+	requireAll   bool
+	logger       *slog.Logger
+}
+
+// NewMultiStorage creates a storage that fans out uploads to all destinations.
+// When requireAll is false, an upload succeeds as long as one destination succeeds.
+func NewMultiStorage(destinations []Destination, requireAll bool, logger *slog.Logger) *MultiStorage {
+	return &MultiStorage{
+		destinations: destinations,
+		requireAll:   requireAll,
+		logger:       logger,
+	}
+}
+
+// Destinations returns the configured destinations.
+func (m *MultiStorage) Destinations() []Destination {
+	return m.destinations
+}
+
+// Upload implements Storage.Upload by streaming the reader to every destination concurrently.
+// A destination that fails is dropped from the fan-out so the others can finish.
+func (m *MultiStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	writers := make([]*io.PipeWriter, len(m.destinations))
+	errs := make([]error, len(m.destinations))
+
+	var wg sync.WaitGroup
+	for i, dest := range m.destinations {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+
+		wg.Add(1)
+		go func(i int, dest Destination, pr *io.PipeReader) {
+			defer wg.Done()
+			err := dest.Storage.Upload(ctx, key, pr, metadata)
+			if err != nil {
+				// Unblock the fan-out loop if the destination stopped reading
+				_ = pr.CloseWithError(err)
+			} else {
+				_ = pr.Close()
+			}
+			errs[i] = err
+		}(i, dest, pr)
+	}
+
+	readErr := fanOut(reader, writers)
+	wg.Wait()
+
+	var failed []error
+	for i, dest := range m.destinations {
+		if errs[i] == nil && readErr != nil {
+			errs[i] = readErr
+		}
+		metrics.RecordStorageOperation("upload", dest.Name, errs[i] == nil)
+		if errs[i] != nil {
+			m.logger.Error("Upload to destination failed", "destination", dest.Name, "key", key, "error", errs[i])
+			failed = append(failed, &DestinationError{Name: dest.Name, Err: errs[i]})
+		}
+	}
+
+	return m.result("upload", failed)
+}
+
+// fanOut copies reader to all writers, dropping writers that fail.
+// It returns the read error, if any, after closing all writers accordingly.
+func fanOut(reader io.Reader, writers []*io.PipeWriter) error {
+	alive := make([]bool, len(writers))
+	for i := range alive {
+		alive[i] = true
+	}
+
+	buf := make([]byte, 32*1024)
+	var readErr error
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			remaining := 0
+			for i, w := range writers {
+				if !alive[i] {
+					continue
+				}
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					alive[i] = false
+					continue
+				}
+				remaining++
+			}
+			if remaining == 0 {
+				readErr = errors.New("all destinations failed")
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("failed to read backup data: %w", err)
+			break
+		}
+	}
+
+	for _, w := range writers {
+		if readErr != nil {
+			_ = w.CloseWithError(readErr)
+		} else {
+			_ = w.Close()
+		}
+	}
+
+	return readErr
+}
+
+// Open implements Storage.Open, reading from the first destination that has the key.
+func (m *MultiStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	var failed []error
+	for _, dest := range m.destinations {
+		r, err := dest.Storage.Open(ctx, key)
+		if err == nil {
+			return r, nil
+		}
+		failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+	}
+	return nil, fmt.Errorf("open failed on all destinations: %w", errors.Join(failed...))
+}
+
+// OpenRange implements Storage.OpenRange, reading from the first destination that has the key.
+func (m *MultiStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	var failed []error
+	for _, dest := range m.destinations {
+		r, err := dest.Storage.OpenRange(ctx, key, offset, length)
+		if err == nil {
+			return r, nil
+		}
+		failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+	}
+	return nil, fmt.Errorf("open failed on all destinations: %w", errors.Join(failed...))
+}
+
+// Delete implements Storage.Delete on every destination.
+func (m *MultiStorage) Delete(ctx context.Context, key string) error {
+	var failed []error
+	for _, dest := range m.destinations {
+		err := dest.Storage.Delete(ctx, key)
+		metrics.RecordStorageOperation("delete", dest.Name, err == nil)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+		}
+	}
+	return m.result("delete", failed)
+}
+
+// Copy implements Storage.Copy on every destination.
+func (m *MultiStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	var failed []error
+	for _, dest := range m.destinations {
+		err := dest.Storage.Copy(ctx, srcKey, dstKey)
+		metrics.RecordStorageOperation("copy", dest.Name, err == nil)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+		}
+	}
+	return m.result("copy", failed)
+}
+
+// Stat implements Storage.Stat from the first destination that has the key.
+func (m *MultiStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	var failed []error
+	for _, dest := range m.destinations {
+		info, err := dest.Storage.Stat(ctx, key)
+		if err == nil {
+			return info, nil
+		}
+		failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+	}
+	return nil, fmt.Errorf("stat failed on all destinations: %w", errors.Join(failed...))
+}
+
+// List implements Storage.List, merging results from all destinations by key.
+func (m *MultiStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return m.list(ctx, prefix, func(ctx context.Context, store Storage, prefix string) ([]ObjectInfo, error) {
+		return store.List(ctx, prefix)
+	})
+}
+
+// list merges the objects listed from every destination by key.
+func (m *MultiStorage) list(ctx context.Context, prefix string,
+	listFn func(ctx context.Context, store Storage, prefix string) ([]ObjectInfo, error)) ([]ObjectInfo, error) {
+	seen := make(map[string]bool)
+	var objects []ObjectInfo
+	var failed []error
+
+	for _, dest := range m.destinations {
+		result, err := listFn(ctx, dest.Storage, prefix)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+			continue
+		}
+		for _, obj := range result {
+			if !seen[obj.Key] {
+				seen[obj.Key] = true
+				objects = append(objects, obj)
+			}
+		}
+	}
+
+	if len(failed) == len(m.destinations) {
+		return nil, fmt.Errorf("list failed on all destinations: %w", errors.Join(failed...))
+	}
+	return objects, nil
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime, returning the most recent
+// backup across all reachable destinations.
+func (m *MultiStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	var latest time.Time
+	var failed []error
+
+	for _, dest := range m.destinations {
+		t, err := dest.Storage.GetLastBackupTime(ctx)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", dest.Name, err))
+			continue
+		}
+		if t.After(latest) {
+			latest = t
+		}
+	}
+
+	if len(failed) == len(m.destinations) {
+		return time.Time{}, fmt.Errorf("failed to get last backup time from all destinations: %w", errors.Join(failed...))
+	}
+	return latest, nil
+}
+
+// result applies the failure policy to per-destination errors.
+func (m *MultiStorage) result(operation string, failed []error) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	if m.requireAll || len(failed) == len(m.destinations) {
+		return fmt.Errorf("%s failed on %d of %d destinations: %w",
+			operation, len(failed), len(m.destinations), errors.Join(failed...))
+	}
+	m.logger.Warn("Operation failed on some destinations, continuing",
+		"operation", operation,
+		"failed", len(failed),
+		"destinations", len(m.destinations))
+	return nil
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingStorage consumes uploads and records what it received.
+type recordingStorage struct {
+	mockStorage
+	received string
+	failAt   int // fail after reading this many bytes (0 = never)
+}
+
+func (r *recordingStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	r.uploadCalls++
+	if r.failAt > 0 {
+		buf := make([]byte, r.failAt)
+		_, _ = io.ReadFull(reader, buf)
+		return errors.New("destination unavailable")
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	r.received = string(data)
+	return nil
+}
+
+func TestMultiStorage_Upload(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	payload := strings.Repeat("backup data ", 10000)
+
+	tests := []struct {
+		name       string
+		failAt     int
+		requireAll bool
+		wantErr    bool
+	}{
+		{
+			name:       "all destinations succeed",
+			requireAll: true,
+			wantErr:    false,
+		},
+		{
+			name:       "one destination fails with require all",
+			failAt:     100,
+			requireAll: true,
+			wantErr:    true,
+		},
+		{
+			name:       "one destination fails with best effort",
+			failAt:     100,
+			requireAll: false,
+			wantErr:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy := &recordingStorage{}
+			flaky := &recordingStorage{failAt: tt.failAt}
+			multi := NewMultiStorage([]Destination{
+				{Name: "s3", Storage: healthy},
+				{Name: "gcs", Storage: flaky},
+			}, tt.requireAll, logger)
+
+			err := multi.Upload(context.Background(), "test.tar.gz", strings.NewReader(payload), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// The healthy destination always receives the full stream
+			if healthy.received != payload {
+				t.Errorf("healthy destination received %d bytes, want %d", len(healthy.received), len(payload))
+			}
+			if tt.failAt == 0 && flaky.received != payload {
+				t.Errorf("second destination received %d bytes, want %d", len(flaky.received), len(payload))
+			}
+		})
+	}
+}
+
+func TestMultiStorage_Copy(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		requireAll bool
+		wantErr    bool
+	}{
+		{name: "one destination fails with require all", requireAll: true, wantErr: true},
+		{name: "one destination fails with best effort", requireAll: false, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy := &mockStorage{}
+			broken := &mockStorage{copyErr: errors.New("source missing")}
+			multi := NewMultiStorage([]Destination{
+				{Name: "s3", Storage: healthy},
+				{Name: "gcs", Storage: broken},
+			}, tt.requireAll, logger)
+
+			err := multi.Copy(context.Background(), "test.tar.gz", "trash/test.tar.gz")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Copy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if healthy.copyCalls != 1 || broken.copyCalls != 1 {
+				t.Errorf("copies = %d and %d, want one in each destination", healthy.copyCalls, broken.copyCalls)
+			}
+		})
+	}
+}
+
+func TestMultiStorage_Stat(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	missing := &mockStorage{}
+	holding := &mockStorage{listResult: []ObjectInfo{{Key: "test.tar.gz", Size: 4}}}
+	multi := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: missing},
+		{Name: "gcs", Storage: holding},
+	}, true, logger)
+
+	info, err := multi.Stat(context.Background(), "test.tar.gz")
+	if err != nil || info.Size != 4 {
+		t.Fatalf("Stat() = %+v, %v; want the object from the second destination", info, err)
+	}
+
+	if _, err := multi.Stat(context.Background(), "other.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() of a key in no destination error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMultiStorage_UploadReadError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	multi := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: &recordingStorage{}},
+		{Name: "gcs", Storage: &recordingStorage{}},
+	}, false, logger)
+
+	reader := io.MultiReader(strings.NewReader("partial"), &failingReader{err: errors.New("dump failed")})
+	if err := multi.Upload(context.Background(), "test.tar.gz", reader, nil); err == nil {
+		t.Error("Upload() expected error when source fails, got nil")
+	}
+}
+
+func TestMultiStorage_GetLastBackupTime(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	older := time.Now().Add(-48 * time.Hour)
+	newer := time.Now().Add(-1 * time.Hour)
+
+	multi := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: &mockStorage{timeResult: older}},
+		{Name: "gcs", Storage: &mockStorage{timeResult: newer}},
+		{Name: "filesystem", Storage: &mockStorage{timeErr: errors.New("unreachable")}},
+	}, true, logger)
+
+	got, err := multi.GetLastBackupTime(context.Background())
+	if err != nil {
+		t.Fatalf("GetLastBackupTime() error = %v", err)
+	}
+	if !got.Equal(newer) {
+		t.Errorf("GetLastBackupTime() = %v, want %v", got, newer)
+	}
+}
+
+func TestMultiStorage_List(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	multi := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: &mockStorage{listResult: []ObjectInfo{{Key: "a"}, {Key: "b"}}}},
+		{Name: "gcs", Storage: &mockStorage{listResult: []ObjectInfo{{Key: "b"}, {Key: "c"}}}},
+	}, true, logger)
+
+	objects, err := multi.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 3 {
+		t.Errorf("List() returned %d objects, want 3 unique keys", len(objects))
+	}
+}
+
+func TestMultiStorage_Open(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	down := &mockStorage{openErr: errors.New("unreachable")}
+	up := &mockStorage{}
+
+	multi := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: down},
+		{Name: "gcs", Storage: up},
+	}, true, logger)
+
+	r, err := multi.Open(context.Background(), "test.tar.gz")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	_ = r.Close()
+	if down.openCalls != 1 || up.openCalls != 1 {
+		t.Errorf("Open() calls = %d/%d, want 1/1", down.openCalls, up.openCalls)
+	}
+
+	all := NewMultiStorage([]Destination{
+		{Name: "s3", Storage: &mockStorage{openErr: errors.New("unreachable")}},
+	}, true, logger)
+	if _, err := all.Open(context.Background(), "test.tar.gz"); err == nil {
+		t.Error("Open() expected error when no destination has the key, got nil")
+	}
+}
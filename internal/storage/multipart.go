@@ -2,19 +2,38 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// minMultipartPartSize is the smallest part size S3 accepts (except for the last part).
+const minMultipartPartSize = 5 * 1024 * 1024
+
+// abortTimeout bounds the cleanup request sent after a failed upload.
+const abortTimeout = 30 * time.Second
+
+// MultipartAPI is the subset of the S3 client used for multipart uploads.
+type MultipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
 // MultipartUploader handles multipart uploads for S3.
 type MultipartUploader struct {
-	client      *s3.Client
+	client      MultipartAPI
 	bucket      string
 	key         string
 	uploadID    string
@@ -22,20 +41,35 @@ type MultipartUploader struct {
 	partNumber  int32
 	mu          sync.Mutex
 	minPartSize int64
+	tagging     string
+	partSums    map[int32][]byte // MD5 digests of the parts, when ETags are checked
+}
+
+// MultipartOptions configures a streaming multipart upload.
+type MultipartOptions struct {
+	PartSize    int64             // Bytes per part (raised to the 5 MiB S3 minimum)
+	Concurrency int               // Parts uploaded in parallel (at least 1)
+	Tags        map[string]string // Object tags applied when the upload is started
+	VerifyETags bool              // Compare the ETags S3 returns with MD5 digests of the parts
 }
 
 // NewMultipartUploader creates a new multipart uploader.
-func NewMultipartUploader(client *s3.Client, bucket, key string) *MultipartUploader {
+func NewMultipartUploader(client MultipartAPI, bucket, key string) *MultipartUploader {
 	return &MultipartUploader{
 		client:      client,
 		bucket:      bucket,
 		key:         key,
 		parts:       make([]types.CompletedPart, 0),
 		partNumber:  1,
-		minPartSize: 5 * 1024 * 1024, // 5MB minimum part size
+		minPartSize: minMultipartPartSize,
 	}
 }
 
+// SetTags sets object tags applied when the upload is started.
+func (m *MultipartUploader) SetTags(tags map[string]string) {
+	m.tagging = encodeTags(tags)
+}
+
 // Start initiates a multipart upload.
 func (m *MultipartUploader) Start(ctx context.Context, metadata map[string]string) error {
 	input := &s3.CreateMultipartUploadInput{
@@ -43,6 +77,9 @@ func (m *MultipartUploader) Start(ctx context.Context, metadata map[string]strin
 		Key:      aws.String(m.key),
 		Metadata: metadata,
 	}
+	if m.tagging != "" {
+		input.Tagging = aws.String(m.tagging)
+	}
 
 	output, err := m.client.CreateMultipartUpload(ctx, input)
 	if err != nil {
@@ -53,13 +90,23 @@ func (m *MultipartUploader) Start(ctx context.Context, metadata map[string]strin
 	return nil
 }
 
-// UploadPart uploads a single part.
+// UploadPart uploads the next part in sequence.
 func (m *MultipartUploader) UploadPart(ctx context.Context, reader io.Reader, size int64) error {
+	_, err := m.uploadPart(ctx, m.nextPartNumber(), reader, size)
+	return err
+}
+
+// nextPartNumber reserves the next part number.
+func (m *MultipartUploader) nextPartNumber() int32 {
 	m.mu.Lock()
+	defer m.mu.Unlock()
 	partNumber := m.partNumber
 	m.partNumber++
-	m.mu.Unlock()
+	return partNumber
+}
 
+// uploadPart uploads a single part with a reserved part number.
+func (m *MultipartUploader) uploadPart(ctx context.Context, partNumber int32, reader io.Reader, size int64) (*s3.UploadPartOutput, error) {
 	input := &s3.UploadPartInput{
 		Bucket:        aws.String(m.bucket),
 		Key:           aws.String(m.key),
@@ -71,7 +118,7 @@ func (m *MultipartUploader) UploadPart(ctx context.Context, reader io.Reader, si
 
 	output, err := m.client.UploadPart(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
 	}
 
 	m.mu.Lock()
@@ -81,26 +128,76 @@ func (m *MultipartUploader) UploadPart(ctx context.Context, reader io.Reader, si
 	})
 	m.mu.Unlock()
 
+	return output, nil
+}
+
+// checkPart compares the ETag S3 returned for a part with the MD5 digest of
+// its data, and records the digest for the ETag of the completed object.
+func (m *MultipartUploader) checkPart(partNumber int32, output *s3.UploadPartOutput, data []byte) error {
+	sum := md5.Sum(data)
+	if etagIsMD5(output.ServerSideEncryption, output.SSECustomerAlgorithm) {
+		if err := checkETag(output.ETag, hex.EncodeToString(sum[:])); err != nil {
+			return fmt.Errorf("part %d: %w", partNumber, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.partSums == nil {
+		m.partSums = make(map[int32][]byte)
+	}
+	m.partSums[partNumber] = sum[:]
 	return nil
 }
 
 // Complete finalizes the multipart upload.
 func (m *MultipartUploader) Complete(ctx context.Context) error {
+	_, err := m.complete(ctx)
+	return err
+}
+
+// complete finalizes the multipart upload and returns the response.
+func (m *MultipartUploader) complete(ctx context.Context) (*s3.CompleteMultipartUploadOutput, error) {
+	// Parts finish out of order when uploaded concurrently; S3 requires ascending order
+	m.mu.Lock()
+	parts := append([]types.CompletedPart(nil), m.parts...)
+	m.mu.Unlock()
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+
 	input := &s3.CompleteMultipartUploadInput{
 		Bucket:   aws.String(m.bucket),
 		Key:      aws.String(m.key),
 		UploadId: aws.String(m.uploadID),
 		MultipartUpload: &types.CompletedMultipartUpload{
-			Parts: m.parts,
+			Parts: parts,
 		},
 	}
 
-	_, err := m.client.CompleteMultipartUpload(ctx, input)
+	output, err := m.client.CompleteMultipartUpload(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to complete multipart upload: %w", err)
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
 	}
 
-	return nil
+	return output, nil
+}
+
+// checkComplete compares the ETag of the completed object with the one
+// computed from the digests of its parts.
+func (m *MultipartUploader) checkComplete(output *s3.CompleteMultipartUploadOutput) error {
+	if !etagIsMD5(output.ServerSideEncryption, nil) {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sums := make([][]byte, 0, len(m.partSums))
+	for partNumber := int32(1); partNumber < m.partNumber; partNumber++ {
+		if sum, ok := m.partSums[partNumber]; ok {
+			sums = append(sums, sum)
+		}
+	}
+	return checkETag(output.ETag, multipartETag(sums))
 }
 
 // Abort cancels the multipart upload.
@@ -123,40 +220,88 @@ func (m *MultipartUploader) Abort(ctx context.Context) error {
 	return nil
 }
 
-// StreamingMultipartUpload handles streaming multipart uploads.
-func StreamingMultipartUpload(ctx context.Context, client *s3.Client, bucket, key string, reader io.Reader, metadata map[string]string) error {
+// StreamingMultipartUpload uploads reader as a multipart upload without knowing its
+// size in advance. At most Concurrency parts are buffered at a time, and the upload
+// is aborted on any failure so no incomplete upload is left billing storage.
+// With VerifyETags, a part whose ETag does not match its data fails the upload
+// with ErrIntegrity, as does a completed object whose ETag does not match.
+func StreamingMultipartUpload(ctx context.Context, client MultipartAPI, bucket, key string, reader io.Reader, metadata map[string]string, opts MultipartOptions) (uploadErr error) {
 	uploader := NewMultipartUploader(client, bucket, key)
+	uploader.SetTags(opts.Tags)
+
+	partSize := max(opts.PartSize, uploader.minPartSize)
+	concurrency := max(opts.Concurrency, 1)
 
 	// Start multipart upload
 	if err := uploader.Start(ctx, metadata); err != nil {
 		return err
 	}
 
-	// Ensure cleanup on error
-	var uploadErr error
+	// Ensure cleanup on error, even if ctx was cancelled
+	completed := false
 	defer func() {
-		if uploadErr != nil {
-			// Try to abort the upload
-			_ = uploader.Abort(context.Background())
+		if uploadErr != nil && !completed {
+			abortCtx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+			defer cancel()
+			if err := uploader.Abort(abortCtx); err != nil {
+				uploadErr = fmt.Errorf("%w (%v)", uploadErr, err)
+			}
 		}
 	}()
 
-	// Buffer for reading parts
-	buffer := make([]byte, uploader.minPartSize)
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// Each slot holds one part buffer, bounding memory to partSize * concurrency
+	slots := make(chan struct{}, concurrency)
+	uploaded := 0
 
+read:
 	for {
+		select {
+		case slots <- struct{}{}:
+		case <-partCtx.Done():
+			break read
+		}
+
+		buffer := make([]byte, partSize)
 		n, err := io.ReadFull(reader, buffer)
 		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-			uploadErr = fmt.Errorf("failed to read data: %w", err)
-			return uploadErr
+			<-slots
+			fail(fmt.Errorf("failed to read data: %w", err))
+			break
 		}
 
-		if n > 0 {
-			// Upload the part
-			partReader := io.LimitReader(reader, int64(n))
-			if uploadErr = uploader.UploadPart(ctx, partReader, int64(n)); uploadErr != nil {
-				return uploadErr
-			}
+		// S3 requires at least one part, even for an empty stream
+		if n > 0 || uploaded == 0 {
+			uploaded++
+			partNumber := uploader.nextPartNumber()
+			wg.Add(1)
+			go func(data []byte) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				output, err := uploader.uploadPart(partCtx, partNumber, bytes.NewReader(data), int64(len(data)))
+				if err == nil && opts.VerifyETags {
+					err = uploader.checkPart(partNumber, output, data)
+				}
+				if err != nil {
+					fail(err)
+				}
+			}(buffer[:n])
+		} else {
+			<-slots
 		}
 
 		if err == io.EOF || err == io.ErrUnexpectedEOF {
@@ -164,10 +309,26 @@ func StreamingMultipartUpload(ctx context.Context, client *s3.Client, bucket, ke
 		}
 	}
 
-	// Complete the upload
-	if uploadErr = uploader.Complete(ctx); uploadErr != nil {
-		return uploadErr
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("multipart upload cancelled: %w", err)
 	}
 
+	// Complete the upload
+	output, err := uploader.complete(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.VerifyETags {
+		// The object exists now, so it is not aborted; the caller deletes it
+		if err := uploader.checkComplete(output); err != nil {
+			completed = true
+			return err
+		}
+	}
 	return nil
 }
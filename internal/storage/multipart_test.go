@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 records multipart and single-object uploads in memory.
+type fakeS3 struct {
+	mu          sync.Mutex
+	failPart    int32 // fail this part number (0 = never)
+	md5ETags    bool  // return MD5 ETags as S3 does, rather than placeholders
+	corruptPart int32 // store this part number with a flipped byte (0 = never)
+	badComplete bool  // return a wrong ETag for the completed upload
+	parts       map[int32][]byte
+	completed   []int32
+	tagging     string
+	putBody     []byte
+	putCalls    int
+	createCalls int
+	aborted     bool
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.putCalls++
+	f.putBody = data
+	if f.md5ETags {
+		return &s3.PutObjectOutput{ETag: aws.String(quotedMD5(data))}, nil
+	}
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.createCalls++
+	f.parts = make(map[int32][]byte)
+	f.tagging = aws.ToString(in.Tagging)
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	partNumber := aws.ToInt32(in.PartNumber)
+	if partNumber == f.failPart {
+		return nil, errors.New("part rejected")
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != aws.ToInt64(in.ContentLength) {
+		return nil, fmt.Errorf("part %d: read %d bytes, content length %d", partNumber, len(data), aws.ToInt64(in.ContentLength))
+	}
+	if partNumber == f.corruptPart {
+		data = bytes.Clone(data)
+		data[0] ^= 0xff
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts[partNumber] = data
+	if f.md5ETags {
+		return &s3.UploadPartOutput{ETag: aws.String(quotedMD5(data))}, nil
+	}
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", partNumber))}, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sums [][]byte
+	for _, p := range in.MultipartUpload.Parts {
+		f.completed = append(f.completed, aws.ToInt32(p.PartNumber))
+		sum := md5.Sum(f.parts[aws.ToInt32(p.PartNumber)])
+		sums = append(sums, sum[:])
+	}
+	if f.badComplete {
+		sums = sums[1:]
+	}
+	if f.md5ETags {
+		return &s3.CompleteMultipartUploadOutput{ETag: aws.String(`"` + multipartETag(sums) + `"`)}, nil
+	}
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = true
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// quotedMD5 returns the quoted hex MD5 of data, as S3 returns ETags.
+func quotedMD5(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// assembled joins the uploaded parts in part-number order.
+func (f *fakeS3) assembled() []byte {
+	numbers := make([]int, 0, len(f.parts))
+	for n := range f.parts {
+		numbers = append(numbers, int(n))
+	}
+	sort.Ints(numbers)
+	var buf bytes.Buffer
+	for _, n := range numbers {
+		buf.Write(f.parts[int32(n)])
+	}
+	return buf.Bytes()
+}
+
+func TestStreamingMultipartUpload(t *testing.T) {
+	// 12 MiB plus change: two full 5 MiB parts and a short last part
+	payload := bytes.Repeat([]byte("0123456789abcdef"), (12*1024*1024+100)/16)
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{name: "sequential", concurrency: 1},
+		{name: "concurrent", concurrency: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{}
+			err := StreamingMultipartUpload(context.Background(), fake, "bucket", "key", bytes.NewReader(payload), nil, MultipartOptions{
+				PartSize:    minMultipartPartSize,
+				Concurrency: tt.concurrency,
+				Tags:        map[string]string{"env": "prod"},
+			})
+			if err != nil {
+				t.Fatalf("StreamingMultipartUpload() error = %v", err)
+			}
+
+			if !bytes.Equal(fake.assembled(), payload) {
+				t.Errorf("assembled upload differs from payload (%d vs %d bytes)", len(fake.assembled()), len(payload))
+			}
+			if want := []int32{1, 2, 3}; fmt.Sprint(fake.completed) != fmt.Sprint(want) {
+				t.Errorf("completed parts = %v, want %v", fake.completed, want)
+			}
+			if fake.tagging != "env=prod" {
+				t.Errorf("tagging = %q, want %q", fake.tagging, "env=prod")
+			}
+			if fake.aborted {
+				t.Error("successful upload was aborted")
+			}
+		})
+	}
+}
+
+func TestStreamingMultipartUpload_AbortsOnError(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 3*minMultipartPartSize)
+
+	tests := []struct {
+		name     string
+		failPart int32
+		reader   io.Reader
+		ctx      func() context.Context
+	}{
+		{
+			name:     "part upload fails",
+			failPart: 2,
+			reader:   bytes.NewReader(payload),
+		},
+		{
+			name:   "source read fails",
+			reader: io.MultiReader(bytes.NewReader(payload[:minMultipartPartSize+10]), &failingReader{err: errors.New("dump failed")}),
+		},
+		{
+			name:   "context cancelled",
+			reader: bytes.NewReader(payload),
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+			fake := &fakeS3{failPart: tt.failPart}
+
+			err := StreamingMultipartUpload(ctx, fake, "bucket", "key", tt.reader, nil, MultipartOptions{Concurrency: 2})
+			if err == nil {
+				t.Fatal("StreamingMultipartUpload() expected error, got nil")
+			}
+			if !fake.aborted {
+				t.Error("failed upload was not aborted")
+			}
+			if len(fake.completed) != 0 {
+				t.Errorf("failed upload was completed with parts %v", fake.completed)
+			}
+		})
+	}
+}
+
+func TestStreamingMultipartUpload_VerifyETags(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), (12*1024*1024+100)/16)
+
+	tests := []struct {
+		name        string
+		fake        *fakeS3
+		wantErr     bool
+		wantAborted bool
+	}{
+		{name: "matching ETags", fake: &fakeS3{md5ETags: true}},
+		{name: "corrupted part", fake: &fakeS3{md5ETags: true, corruptPart: 2}, wantErr: true, wantAborted: true},
+		{name: "wrong completed ETag", fake: &fakeS3{md5ETags: true, badComplete: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := StreamingMultipartUpload(context.Background(), tt.fake, "bucket", "key", bytes.NewReader(payload), nil, MultipartOptions{
+				PartSize:    minMultipartPartSize,
+				Concurrency: 2,
+				VerifyETags: true,
+			})
+			if tt.wantErr {
+				if !errors.Is(err, ErrIntegrity) {
+					t.Fatalf("StreamingMultipartUpload() error = %v, want ErrIntegrity", err)
+				}
+			} else if err != nil {
+				t.Fatalf("StreamingMultipartUpload() error = %v", err)
+			}
+			if tt.fake.aborted != tt.wantAborted {
+				t.Errorf("aborted = %v, want %v", tt.fake.aborted, tt.wantAborted)
+			}
+		})
+	}
+}
+
+func TestS3Storage_UploadSelectsMultipart(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          int
+		wantMultipart bool
+	}{
+		{name: "below threshold uses single request", size: 1024, wantMultipart: false},
+		{name: "above threshold streams multipart", size: 2*minMultipartPartSize + 1, wantMultipart: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{md5ETags: true}
+			s := &S3Storage{
+				uploader:    manager.NewUploader(fake),
+				multipart:   fake,
+				bucket:      "bucket",
+				threshold:   minMultipartPartSize,
+				tags:        map[string]string{"app": "db"},
+				tagging:     url.Values{"app": {"db"}}.Encode(),
+				verifyETags: true,
+			}
+
+			payload := strings.Repeat("b", tt.size)
+			if err := s.Upload(context.Background(), "backup.tar.gz", strings.NewReader(payload), nil); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			if gotMultipart := fake.createCalls > 0; gotMultipart != tt.wantMultipart {
+				t.Fatalf("multipart = %v, want %v", gotMultipart, tt.wantMultipart)
+			}
+			got := string(fake.putBody)
+			if tt.wantMultipart {
+				got = string(fake.assembled())
+			}
+			if got != payload {
+				t.Errorf("uploaded %d bytes, want %d", len(got), len(payload))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PauseKey names the object that holds backups, so a pause set through the
+// API reaches every instance backing up into the bucket.
+const PauseKey = "pause.json"
+
+// Pause records why and since when backups are paused.
+type Pause struct {
+	Reason   string    `json:"reason,omitempty"`
+	PausedAt time.Time `json:"paused_at"`
+}
+
+// ReadPause returns the pause object in store, or nil when backups are not paused.
+func ReadPause(ctx context.Context, store Storage) (*Pause, error) {
+	objects, err := store.List(ctx, PauseKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", PauseKey, err)
+	}
+	found := false
+	for _, obj := range objects {
+		found = found || obj.Key == PauseKey
+	}
+	if !found {
+		return nil, nil
+	}
+
+	r, err := store.Open(ctx, PauseKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var pause Pause
+	if err := json.NewDecoder(r).Decode(&pause); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", PauseKey, err)
+	}
+	return &pause, nil
+}
+
+// WritePause pauses backups until ClearPause is called.
+func WritePause(ctx context.Context, store Storage, pause Pause) error {
+	data, err := json.Marshal(pause)
+	if err != nil {
+		return err
+	}
+	if err := store.Upload(ctx, PauseKey, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", PauseKey, err)
+	}
+	return nil
+}
+
+// ClearPause resumes backups. Clearing when not paused is not an error.
+func ClearPause(ctx context.Context, store Storage) error {
+	pause, err := ReadPause(ctx, store)
+	if err != nil || pause == nil {
+		return err
+	}
+	if err := store.Delete(ctx, PauseKey); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", PauseKey, err)
+	}
+	return nil
+}
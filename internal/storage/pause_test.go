@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	store := NewPrefixedStorage(fs, "db/")
+
+	if pause, err := ReadPause(ctx, store); err != nil || pause != nil {
+		t.Fatalf("ReadPause() = %v, %v; want not paused", pause, err)
+	}
+	// Resuming when not paused is not an error
+	if err := ClearPause(ctx, store); err != nil {
+		t.Fatalf("ClearPause() error = %v", err)
+	}
+
+	pausedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := WritePause(ctx, store, Pause{Reason: "maintenance", PausedAt: pausedAt}); err != nil {
+		t.Fatalf("WritePause() error = %v", err)
+	}
+	pause, err := ReadPause(ctx, store)
+	if err != nil || pause == nil || pause.Reason != "maintenance" || !pause.PausedAt.Equal(pausedAt) {
+		t.Fatalf("ReadPause() = %+v, %v; want the written pause", pause, err)
+	}
+
+	// A pause under one prefix does not hold the others
+	if pause, err := ReadPause(ctx, fs); err != nil || pause != nil {
+		t.Errorf("ReadPause() at the root = %v, %v; want not paused", pause, err)
+	}
+
+	if err := ClearPause(ctx, store); err != nil {
+		t.Fatalf("ClearPause() error = %v", err)
+	}
+	if pause, err := ReadPause(ctx, store); err != nil || pause != nil {
+		t.Errorf("ReadPause() after ClearPause = %v, %v; want not paused", pause, err)
+	}
+
+	// Retention and the catalog skip the pause object like the state object
+	if !IsReservedKey(PauseKey) {
+		t.Errorf("IsReservedKey(%s) = false", PauseKey)
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// PrefixedStorage scopes a storage to the keys under a fixed prefix, so several
+// databases can share one bucket without seeing each other's backups.
+type PrefixedStorage struct {
+	storage Storage
+	prefix  string
+}
+
+// NewPrefixedStorage returns a storage that stores every key under prefix/.
+func NewPrefixedStorage(storage Storage, prefix string) *PrefixedStorage {
+	return &PrefixedStorage{
+		storage: storage,
+		prefix:  strings.TrimSuffix(prefix, "/") + "/",
+	}
+}
+
+// Upload implements Storage.Upload.
+func (p *PrefixedStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	return p.storage.Upload(ctx, p.prefix+key, reader, metadata)
+}
+
+// Open implements Storage.Open.
+func (p *PrefixedStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return p.storage.Open(ctx, p.prefix+key)
+}
+
+// OpenRange implements Storage.OpenRange.
+func (p *PrefixedStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return p.storage.OpenRange(ctx, p.prefix+key, offset, length)
+}
+
+// Delete implements Storage.Delete.
+func (p *PrefixedStorage) Delete(ctx context.Context, key string) error {
+	return p.storage.Delete(ctx, p.prefix+key)
+}
+
+// Copy implements Storage.Copy.
+func (p *PrefixedStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return p.storage.Copy(ctx, p.prefix+srcKey, p.prefix+dstKey)
+}
+
+// Stat implements Storage.Stat.
+func (p *PrefixedStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := p.storage.Stat(ctx, p.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	info.Key = key
+	return info, nil
+}
+
+// List implements Storage.List, returning keys relative to the prefix.
+func (p *PrefixedStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := p.storage.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	return p.scope(objects), nil
+}
+
+// scope drops objects outside the prefix and makes keys relative to it.
+func (p *PrefixedStorage) scope(objects []ObjectInfo) []ObjectInfo {
+	scoped := objects[:0]
+	for _, obj := range objects {
+		if key, ok := strings.CutPrefix(obj.Key, p.prefix); ok {
+			obj.Key = key
+			scoped = append(scoped, obj)
+		}
+	}
+	return scoped
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime for the backups under the prefix.
+func (p *PrefixedStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return listedBackupTime(ctx, p)
+}
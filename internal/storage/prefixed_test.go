@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrefixedStorage(t *testing.T) {
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	orders := NewPrefixedStorage(fs, "orders")
+	users := NewPrefixedStorage(fs, "users/")
+
+	if err := orders.Upload(ctx, "2024/01/orders.tar.gz", strings.NewReader("orders"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// The key is stored under the prefix in the shared storage
+	all, err := fs.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(all) != 1 || all[0].Key != "orders/2024/01/orders.tar.gz" {
+		t.Fatalf("shared storage holds %v, want orders/2024/01/orders.tar.gz", all)
+	}
+
+	// Each prefixed storage only sees its own keys, relative to the prefix
+	objects, err := orders.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != "2024/01/orders.tar.gz" {
+		t.Errorf("orders.List() = %v, want 2024/01/orders.tar.gz", objects)
+	}
+
+	objects, err = users.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("users.List() = %v, want none", objects)
+	}
+
+	last, err := users.GetLastBackupTime(ctx)
+	if err != nil {
+		t.Fatalf("GetLastBackupTime() error = %v", err)
+	}
+	if !last.IsZero() {
+		t.Errorf("users.GetLastBackupTime() = %v, want zero", last)
+	}
+
+	last, err = orders.GetLastBackupTime(ctx)
+	if err != nil {
+		t.Fatalf("GetLastBackupTime() error = %v", err)
+	}
+	if time.Since(last) > time.Minute {
+		t.Errorf("orders.GetLastBackupTime() = %v, want recent", last)
+	}
+
+	if r, err := orders.OpenRange(ctx, "2024/01/orders.tar.gz", 1, 3); err != nil {
+		t.Errorf("orders.OpenRange() error = %v", err)
+	} else {
+		data, _ := io.ReadAll(r)
+		_ = r.Close()
+		if string(data) != "rde" {
+			t.Errorf("orders.OpenRange() read %q, want %q", data, "rde")
+		}
+	}
+
+	info, err := orders.Stat(ctx, "2024/01/orders.tar.gz")
+	if err != nil || info.Key != "2024/01/orders.tar.gz" || info.Size != 6 {
+		t.Errorf("orders.Stat() = %+v, %v; want the key relative to the prefix", info, err)
+	}
+	if _, err := users.Stat(ctx, "2024/01/orders.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("users.Stat() error = %v, want ErrNotFound", err)
+	}
+
+	// Both keys of a copy are under the prefix
+	if err := orders.Copy(ctx, "2024/01/orders.tar.gz", "trash/orders.tar.gz"); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if all, _ := fs.List(ctx, "orders/trash/"); len(all) != 1 {
+		t.Errorf("shared storage holds %v under orders/trash/, want the copy", all)
+	}
+	if err := orders.Delete(ctx, "trash/orders.tar.gz"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := orders.Delete(ctx, "2024/01/orders.tar.gz"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if all, _ := fs.List(ctx, ""); len(all) != 0 {
+		t.Errorf("shared storage holds %v after delete, want none", all)
+	}
+}
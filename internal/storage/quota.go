@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ErrQuotaExceeded is returned when a destination refuses a write because its
+// bucket quota or disk is full. Retrying does not help until space is freed.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// quotaErrorCodes are the S3 error codes S3-compatible providers answer with
+// when a bucket or account is full.
+var quotaErrorCodes = map[string]bool{
+	"QuotaExceeded":                  true, // Ceph RGW, Wasabi
+	"XMinioStorageFull":              true,
+	"XMinioAdminBucketQuotaExceeded": true,
+	"InsufficientStorage":            true,
+}
+
+// isQuotaExceeded reports whether err means the destination is out of space:
+// a full disk or disk quota, an HTTP 507 or a provider quota error code.
+func isQuotaExceeded(err error) bool {
+	if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return true
+	}
+	if httpStatus(err) == http.StatusInsufficientStorage {
+		return true
+	}
+
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) && quotaErrorCodes[apiErr.ErrorCode()] {
+		return true
+	}
+
+	var gcsErr *googleapi.Error
+	return errors.As(err, &gcsErr) && gcsErr.Code == http.StatusInsufficientStorage
+}
+
+// QuotaExceededDestinations returns the names of the MultiStorage destinations
+// whose upload failed with ErrQuotaExceeded in err.
+func QuotaExceededDestinations(err error) []string {
+	var names []string
+	switch e := err.(type) {
+	case *DestinationError:
+		if errors.Is(e.Err, ErrQuotaExceeded) {
+			names = append(names, e.Name)
+		}
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			names = append(names, QuotaExceededDestinations(err)...)
+		}
+	case interface{ Unwrap() error }:
+		names = QuotaExceededDestinations(e.Unwrap())
+	}
+	return names
+}
+
+// classifyQuota wraps err with ErrQuotaExceeded when it means the destination
+// is out of space, so callers can tell it apart without knowing the provider.
+func classifyQuota(err error) error {
+	if err == nil || errors.Is(err, ErrQuotaExceeded) || !isQuotaExceeded(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+}
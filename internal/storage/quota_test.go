@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+// apiError mimics an SDK error carrying a provider error code and HTTP status.
+type apiError struct {
+	code   string
+	status int
+}
+
+func (e *apiError) Error() string       { return fmt.Sprintf("api error %s", e.code) }
+func (e *apiError) ErrorCode() string   { return e.code }
+func (e *apiError) HTTPStatusCode() int { return e.status }
+
+func TestClassifyQuota(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"full disk", &os.PathError{Op: "write", Path: "/backups/x", Err: syscall.ENOSPC}, true},
+		{"disk quota", fmt.Errorf("copy: %w", syscall.EDQUOT), true},
+		{"Ceph bucket quota", &apiError{code: "QuotaExceeded", status: 403}, true},
+		{"MinIO storage full", &apiError{code: "XMinioStorageFull", status: 507}, true},
+		{"insufficient storage status", &apiError{code: "Unknown", status: 507}, true},
+		{"GCS insufficient storage", &googleapi.Error{Code: 507}, true},
+		{"access denied", &apiError{code: "AccessDenied", status: 403}, false},
+		{"GCS rate limit", &googleapi.Error{Code: 429}, false},
+		{"network error", errors.New("connection reset by peer"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyQuota(tt.err)
+			if got := errors.Is(err, ErrQuotaExceeded); got != tt.want {
+				t.Errorf("classifyQuota(%v) is ErrQuotaExceeded = %v, want %v", tt.err, got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("classifyQuota(%v) = %v, lost the original error", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestQuotaExceededDestinations(t *testing.T) {
+	full := fmt.Errorf("%w: disk full", ErrQuotaExceeded)
+	err := fmt.Errorf("upload failed on 3 of 3 destinations: %w", errors.Join(
+		&DestinationError{Name: "s3", Err: full},
+		&DestinationError{Name: "gcs", Err: errors.New("connection reset by peer")},
+		&DestinationError{Name: "filesystem", Err: fmt.Errorf("write: %w", full)},
+	))
+	if got := QuotaExceededDestinations(err); !slices.Equal(got, []string{"s3", "filesystem"}) {
+		t.Errorf("QuotaExceededDestinations() = %v, want [s3 filesystem]", got)
+	}
+	if got := QuotaExceededDestinations(full); got != nil {
+		t.Errorf("QuotaExceededDestinations() of a single destination = %v, want none", got)
+	}
+}
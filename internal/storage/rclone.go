@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// RcloneStorage implements Storage by running the rclone CLI against a remote
+// from rclone's configuration, reaching any backend rclone supports.
+type RcloneStorage struct {
+	remote string
+	prefix string
+	run    rcloneRunner
+}
+
+// RcloneConfig holds rclone-specific configuration.
+type RcloneConfig struct {
+	Remote string // Remote name and optional path, e.g. "b2:my-bucket/backups"
+	Binary string // rclone executable (defaults to "rclone" on PATH)
+	Prefix string // Optional prefix for all keys
+}
+
+// rcloneRunner runs rclone with args, connecting stdin and stdout when non-nil.
+type rcloneRunner func(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error
+
+// rcloneError reports a failed rclone command with its stderr output.
+type rcloneError struct {
+	command  string
+	exitCode int
+	stderr   string
+}
+
+func (e *rcloneError) Error() string {
+	return fmt.Sprintf("rclone %s failed with exit code %d: %s", e.command, e.exitCode, e.stderr)
+}
+
+// notFound reports whether rclone exited because a directory (3) or file (4) is missing.
+func (e *rcloneError) notFound() bool {
+	return e.exitCode == 3 || e.exitCode == 4
+}
+
+// isRcloneNotFound reports whether err is an rclone missing directory or file error.
+func isRcloneNotFound(err error) bool {
+	var rerr *rcloneError
+	return errors.As(err, &rerr) && rerr.notFound()
+}
+
+// NewRcloneStorage creates a new rclone storage provider.
+func NewRcloneStorage(cfg RcloneConfig) (*RcloneStorage, error) {
+	if !strings.Contains(cfg.Remote, ":") {
+		return nil, fmt.Errorf("rclone remote %q must be of the form name:path", cfg.Remote)
+	}
+
+	binary := cfg.Binary
+	if binary == "" {
+		binary = "rclone"
+	}
+	bin, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("rclone executable not found: %w", err)
+	}
+
+	return &RcloneStorage{
+		remote: strings.TrimSuffix(cfg.Remote, "/"),
+		prefix: cfg.Prefix,
+		run:    execRclone(bin),
+	}, nil
+}
+
+// execRclone returns a runner executing the rclone binary.
+func execRclone(binary string) rcloneRunner {
+	return func(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+		cmd := exec.CommandContext(ctx, binary, args...)
+		cmd.Stdin = stdin
+		cmd.Stdout = stdout
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return &rcloneError{command: args[0], exitCode: exitErr.ExitCode(), stderr: utils.RedactCredentials(strings.TrimSpace(stderr.String()))}
+			}
+			return fmt.Errorf("failed to run rclone %s: %w", args[0], err)
+		}
+		return nil
+	}
+}
+
+// Upload implements Storage.Upload.
+// rclone rcat streams the data and only creates the object once it completes;
+// metadata is kept in a hidden sidecar object as on filesystem storage.
+func (r *RcloneStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	if err := r.run(ctx, &contextReader{ctx: ctx, reader: reader}, nil, "rcat", r.remotePath(key)); err != nil {
+		return fmt.Errorf("failed to upload with rclone: %w", err)
+	}
+
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata: %w", err)
+		}
+		if err := r.run(ctx, bytes.NewReader(data), nil, "rcat", r.remotePath(sidecarKey(key))); err != nil {
+			return fmt.Errorf("failed to upload metadata with rclone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Open implements Storage.Open, streaming the object from rclone cat.
+func (r *RcloneStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return r.cat(ctx, "cat", r.remotePath(key))
+}
+
+// OpenRange implements Storage.OpenRange with rclone cat --offset and --count.
+func (r *RcloneStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	args := []string{"cat", "--offset", strconv.FormatInt(offset, 10)}
+	if length >= 0 {
+		args = append(args, "--count", strconv.FormatInt(length, 10))
+	}
+	return r.cat(ctx, append(args, r.remotePath(key))...)
+}
+
+// cat streams the output of an rclone cat command.
+func (r *RcloneStorage) cat(ctx context.Context, args ...string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		err := r.run(ctx, nil, pw, args...)
+		if err != nil {
+			err = fmt.Errorf("failed to open with rclone: %w", err)
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return &rcloneReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// rcloneReader stops the rclone process when closed.
+type rcloneReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.
+func (r *rcloneReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// Copy implements Storage.Copy with rclone copyto, which copies server-side
+// when the backend supports it.
+func (r *RcloneStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if err := r.run(ctx, nil, nil, "copyto", r.remotePath(srcKey), r.remotePath(dstKey)); err != nil {
+		return fmt.Errorf("failed to copy with rclone: %w", err)
+	}
+
+	// Metadata is optional, so a missing sidecar is not an error
+	if err := r.run(ctx, nil, nil, "copyto", r.remotePath(sidecarKey(srcKey)), r.remotePath(sidecarKey(dstKey))); err != nil && !isRcloneNotFound(err) {
+		return fmt.Errorf("failed to copy metadata with rclone: %w", err)
+	}
+
+	return nil
+}
+
+// Delete implements Storage.Delete.
+func (r *RcloneStorage) Delete(ctx context.Context, key string) error {
+	if err := r.run(ctx, nil, nil, "deletefile", r.remotePath(key)); err != nil {
+		return fmt.Errorf("failed to delete with rclone: %w", err)
+	}
+
+	// Metadata is optional, so a missing sidecar is not an error
+	if err := r.run(ctx, nil, nil, "deletefile", r.remotePath(sidecarKey(key))); err != nil && !isRcloneNotFound(err) {
+		return fmt.Errorf("failed to delete metadata with rclone: %w", err)
+	}
+
+	return nil
+}
+
+// List implements Storage.List. Metadata is left empty; ListWithMetadata reads it.
+func (r *RcloneStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, _, err := r.list(ctx, prefix)
+	return objects, err
+}
+
+// ListWithMetadata implements MetadataLister, reading the sidecar of each
+// listed object that has one.
+func (r *RcloneStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, sidecars, err := r.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range objects {
+		if !sidecars[sidecarKey(objects[i].Key)] {
+			continue
+		}
+		metadata, err := r.readMetadata(ctx, objects[i].Key)
+		if err != nil {
+			return nil, err
+		}
+		objects[i].Metadata = metadata
+	}
+	return objects, nil
+}
+
+// rcloneEntry is an item of rclone lsjson output.
+type rcloneEntry struct {
+	Path    string    `json:"Path"`
+	Size    int64     `json:"Size"`
+	ModTime time.Time `json:"ModTime"`
+}
+
+// list returns the objects matching prefix and the set of sidecar keys beside them.
+// Only the directory containing the prefix is listed.
+func (r *RcloneStorage) list(ctx context.Context, prefix string) ([]ObjectInfo, map[string]bool, error) {
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i+1]
+	}
+
+	var out bytes.Buffer
+	err := r.run(ctx, nil, &out, "lsjson", "--recursive", "--files-only", "--no-mimetype", r.remotePath(dir))
+	if isRcloneNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list with rclone: %w", err)
+	}
+
+	var entries []rcloneEntry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		return nil, nil, fmt.Errorf("invalid rclone lsjson output: %w", err)
+	}
+
+	var objects []ObjectInfo
+	sidecars := make(map[string]bool)
+	for _, entry := range entries {
+		key := dir + entry.Path
+		// Hidden objects are metadata sidecars or not ours
+		if strings.HasPrefix(path.Base(key), ".") {
+			sidecars[key] = true
+			continue
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         entry.Size,
+			LastModified: entry.ModTime,
+			Metadata:     make(map[string]string), // Filled in by ListWithMetadata
+		})
+	}
+	return objects, sidecars, nil
+}
+
+// readMetadata reads the metadata sidecar of key.
+func (r *RcloneStorage) readMetadata(ctx context.Context, key string) (map[string]string, error) {
+	var out bytes.Buffer
+	if err := r.run(ctx, nil, &out, "cat", r.remotePath(sidecarKey(key))); err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", key, err)
+	}
+
+	metadata := make(map[string]string)
+	_ = json.Unmarshal(out.Bytes(), &metadata)
+	return metadata, nil
+}
+
+// Stat implements Storage.Stat with rclone lsjson --stat, reading metadata
+// from the sidecar when there is one.
+func (r *RcloneStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	var out bytes.Buffer
+	err := r.run(ctx, nil, &out, "lsjson", "--stat", "--no-mimetype", r.remotePath(key))
+	if isRcloneNotFound(err) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat with rclone: %w", err)
+	}
+
+	var entry rcloneEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		return nil, fmt.Errorf("invalid rclone lsjson output: %w", err)
+	}
+
+	// Metadata is optional, so a missing sidecar is not an error
+	metadata, err := r.readMetadata(ctx, key)
+	if isRcloneNotFound(err) {
+		metadata = make(map[string]string)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         entry.Size,
+		LastModified: entry.ModTime,
+		Metadata:     metadata,
+	}, nil
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime.
+func (r *RcloneStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return listedBackupTime(ctx, r)
+}
+
+// remotePath returns the rclone path of a key, including the remote and prefix.
+func (r *RcloneStorage) remotePath(key string) string {
+	key = strings.TrimPrefix(path.Join(r.prefix, key), "/")
+	if strings.HasSuffix(r.remote, ":") {
+		return r.remote + key
+	}
+	return r.remote + "/" + key
+}
+
+// sidecarKey returns the hidden key holding the metadata of key.
+func sidecarKey(key string) string {
+	dir, base := path.Split(key)
+	return dir + "." + base + metadataSuffix
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRclone emulates the rclone commands used by RcloneStorage on an in-memory remote.
+type fakeRclone struct {
+	objects map[string][]byte // Remote path -> content
+	modTime map[string]time.Time
+	calls   []string
+}
+
+func newFakeRclone() *fakeRclone {
+	return &fakeRclone{objects: map[string][]byte{}, modTime: map[string]time.Time{}}
+}
+
+func (f *fakeRclone) run(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+	f.calls = append(f.calls, strings.Join(args, " "))
+	target := args[len(args)-1]
+
+	switch args[0] {
+	case "rcat":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		f.objects[target] = data
+		f.modTime[target] = time.Now()
+	case "cat":
+		data, ok := f.objects[target]
+		if !ok {
+			return &rcloneError{command: "cat", exitCode: 4}
+		}
+		if i := slices.Index(args, "--offset"); i >= 0 {
+			offset, _ := strconv.Atoi(args[i+1])
+			data = data[min(offset, len(data)):]
+		}
+		if i := slices.Index(args, "--count"); i >= 0 {
+			count, _ := strconv.Atoi(args[i+1])
+			data = data[:min(count, len(data))]
+		}
+		_, err := stdout.Write(data)
+		return err
+	case "copyto":
+		data, ok := f.objects[args[1]]
+		if !ok {
+			return &rcloneError{command: "copyto", exitCode: 3}
+		}
+		f.objects[target] = data
+		f.modTime[target] = time.Now()
+	case "deletefile":
+		if _, ok := f.objects[target]; !ok {
+			return &rcloneError{command: "deletefile", exitCode: 4}
+		}
+		delete(f.objects, target)
+	case "lsjson":
+		if slices.Contains(args, "--stat") {
+			data, ok := f.objects[target]
+			if !ok {
+				return &rcloneError{command: "lsjson", exitCode: 3}
+			}
+			return json.NewEncoder(stdout).Encode(rcloneEntry{Path: path.Base(target), Size: int64(len(data)), ModTime: f.modTime[target]})
+		}
+		dir := target
+		if !strings.HasSuffix(dir, ":") {
+			dir += "/"
+		}
+		var entries []rcloneEntry
+		for p, data := range f.objects {
+			if rel, ok := strings.CutPrefix(p, dir); ok {
+				entries = append(entries, rcloneEntry{Path: rel, Size: int64(len(data)), ModTime: f.modTime[p]})
+			}
+		}
+		if len(entries) == 0 {
+			return &rcloneError{command: "lsjson", exitCode: 3}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+		return json.NewEncoder(stdout).Encode(entries)
+	default:
+		return fmt.Errorf("unexpected rclone command %q", args[0])
+	}
+	return nil
+}
+
+func TestRcloneStorage(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRclone()
+	r := &RcloneStorage{remote: "b2:bucket", prefix: "db", run: fake.run}
+
+	if objects, err := r.List(ctx, ""); err != nil || len(objects) != 0 {
+		t.Fatalf("List() on an empty remote = %v, %v; want nothing", objects, err)
+	}
+
+	metadata := map[string]string{"backup-timestamp": "2024-01-15T10:30:00Z"}
+	if err := r.Upload(ctx, "2024/01/backup.tar.gz", strings.NewReader("backup data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if err := r.Upload(ctx, "2024/02/other.tar.gz", strings.NewReader("more"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if _, ok := fake.objects["b2:bucket/db/2024/01/.backup.tar.gz.metadata.json"]; !ok {
+		t.Errorf("metadata sidecar not uploaded; objects = %v", fake.objects)
+	}
+
+	objects, err := r.List(ctx, "2024/01/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != "2024/01/backup.tar.gz" || objects[0].Size != 11 {
+		t.Errorf("List() = %+v, want only 2024/01/backup.tar.gz without its sidecar", objects)
+	}
+	if fake.calls[len(fake.calls)-1] != "lsjson --recursive --files-only --no-mimetype b2:bucket/db/2024/01" {
+		t.Errorf("List() ran %q, want the prefix directory listed", fake.calls[len(fake.calls)-1])
+	}
+
+	objects, err = r.ListWithMetadata(ctx, "2024/01/backup")
+	if err != nil {
+		t.Fatalf("ListWithMetadata() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Metadata["backup-timestamp"] != "2024-01-15T10:30:00Z" {
+		t.Errorf("ListWithMetadata() = %+v, want the sidecar metadata", objects)
+	}
+
+	info, err := r.Stat(ctx, "2024/01/backup.tar.gz")
+	if err != nil || info.Size != 11 || info.Metadata["backup-timestamp"] != "2024-01-15T10:30:00Z" {
+		t.Errorf("Stat() = %+v, %v; want the object with its sidecar metadata", info, err)
+	}
+	if info, err := r.Stat(ctx, "2024/02/other.tar.gz"); err != nil || len(info.Metadata) != 0 {
+		t.Errorf("Stat() without a sidecar = %+v, %v; want empty metadata", info, err)
+	}
+	if _, err := r.Stat(ctx, "2024/03/missing.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() of a missing key error = %v, want ErrNotFound", err)
+	}
+
+	reader, err := r.Open(ctx, "2024/01/backup.tar.gz")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil || string(data) != "backup data" {
+		t.Errorf("Open() read %q, %v; want the uploaded data", data, err)
+	}
+
+	reader, err = r.OpenRange(ctx, "2024/01/backup.tar.gz", 7, 4)
+	if err != nil {
+		t.Fatalf("OpenRange() error = %v", err)
+	}
+	data, err = io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil || string(data) != "data" {
+		t.Errorf("OpenRange() read %q, %v; want %q", data, err, "data")
+	}
+	if want := "cat --offset 7 --count 4 b2:bucket/db/2024/01/backup.tar.gz"; fake.calls[len(fake.calls)-1] != want {
+		t.Errorf("OpenRange() ran %q, want %q", fake.calls[len(fake.calls)-1], want)
+	}
+
+	reader, _ = r.Open(ctx, "missing.tar.gz")
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("reading a missing object expected an error")
+	}
+	_ = reader.Close()
+
+	// The sidecar goes with the backup; a backup without one deletes cleanly
+	if err := r.Delete(ctx, "2024/01/backup.tar.gz"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := r.Delete(ctx, "2024/02/other.tar.gz"); err != nil {
+		t.Fatalf("Delete() without sidecar error = %v", err)
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("objects left after Delete() = %v", fake.objects)
+	}
+}
+
+func TestRcloneStorage_Copy(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRclone()
+	r := &RcloneStorage{remote: "b2:bucket", prefix: "db", run: fake.run}
+
+	metadata := map[string]string{"backup-timestamp": "2024-01-15T10:30:00Z"}
+	if err := r.Upload(ctx, "2024/01/backup.tar.gz", strings.NewReader("backup data"), metadata); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if err := r.Upload(ctx, "2024/02/other.tar.gz", strings.NewReader("more"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// The sidecar is copied with the backup; a backup without one copies cleanly
+	if err := r.Copy(ctx, "2024/01/backup.tar.gz", "trash/backup.tar.gz"); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if err := r.Copy(ctx, "2024/02/other.tar.gz", "trash/other.tar.gz"); err != nil {
+		t.Fatalf("Copy() without sidecar error = %v", err)
+	}
+	if string(fake.objects["b2:bucket/db/trash/backup.tar.gz"]) != "backup data" {
+		t.Errorf("copy holds %q, want the source data", fake.objects["b2:bucket/db/trash/backup.tar.gz"])
+	}
+	if _, ok := fake.objects["b2:bucket/db/trash/.backup.tar.gz.metadata.json"]; !ok {
+		t.Errorf("metadata sidecar not copied; objects = %v", fake.objects)
+	}
+	if !slices.Contains(fake.calls, "copyto b2:bucket/db/2024/01/backup.tar.gz b2:bucket/db/trash/backup.tar.gz") {
+		t.Errorf("Copy() ran %v, want rclone copyto", fake.calls)
+	}
+
+	if err := r.Copy(ctx, "missing.tar.gz", "trash/missing.tar.gz"); err == nil {
+		t.Error("Copy() of a missing object expected an error")
+	}
+}
+
+func TestRcloneStorage_GetLastBackupTime(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRclone()
+	r := &RcloneStorage{remote: "sftp:", run: fake.run}
+
+	want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	if err := r.Upload(ctx, "old.tar.gz", strings.NewReader("a"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Upload(ctx, "new.tar.gz", strings.NewReader("b"), map[string]string{"backup-timestamp": want.Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["sftp:new.tar.gz"]; !ok {
+		t.Errorf("remote root path not joined without a slash; objects = %v", fake.objects)
+	}
+	// Reserved objects written after the backup are not backups
+	if err := r.Upload(ctx, StateKey, strings.NewReader("{}"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.GetLastBackupTime(ctx)
+	if err != nil {
+		t.Fatalf("GetLastBackupTime() error = %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("GetLastBackupTime() = %v, want %v from the newest backup's metadata", got, want)
+	}
+}
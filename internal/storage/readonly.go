@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrReadOnly is returned by writes to a ReadOnlyStorage.
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ReadOnlyStorage refuses every write to the storage it wraps, so a deployment
+// holding read-only credentials fails loudly, before reaching the provider,
+// if anything tries to write.
+type ReadOnlyStorage struct {
+	storage Storage
+}
+
+// NewReadOnlyStorage returns a storage that reads from storage and refuses writes.
+func NewReadOnlyStorage(storage Storage) *ReadOnlyStorage {
+	return &ReadOnlyStorage{storage: storage}
+}
+
+// Upload implements Storage.Upload by refusing the write.
+func (r *ReadOnlyStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata map[string]string) error {
+	return fmt.Errorf("%w: refusing to upload %s", ErrReadOnly, key)
+}
+
+// Open implements Storage.Open.
+func (r *ReadOnlyStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return r.storage.Open(ctx, key)
+}
+
+// OpenRange implements Storage.OpenRange.
+func (r *ReadOnlyStorage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return r.storage.OpenRange(ctx, key, offset, length)
+}
+
+// Delete implements Storage.Delete by refusing the write.
+func (r *ReadOnlyStorage) Delete(ctx context.Context, key string) error {
+	return fmt.Errorf("%w: refusing to delete %s", ErrReadOnly, key)
+}
+
+// Copy implements Storage.Copy by refusing the write.
+func (r *ReadOnlyStorage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	return fmt.Errorf("%w: refusing to copy %s to %s", ErrReadOnly, srcKey, dstKey)
+}
+
+// Stat implements Storage.Stat.
+func (r *ReadOnlyStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return r.storage.Stat(ctx, key)
+}
+
+// List implements Storage.List.
+func (r *ReadOnlyStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return r.storage.List(ctx, prefix)
+}
+
+// ListWithMetadata implements MetadataLister.
+func (r *ReadOnlyStorage) ListWithMetadata(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return ListWithMetadata(ctx, r.storage, prefix)
+}
+
+// GetLastBackupTime implements Storage.GetLastBackupTime.
+func (r *ReadOnlyStorage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
+	return r.storage.GetLastBackupTime(ctx)
+}
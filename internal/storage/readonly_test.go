@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyStorage(t *testing.T) {
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := fs.Upload(ctx, "backup.tar.gz", strings.NewReader("backup"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	ro := NewReadOnlyStorage(fs)
+
+	// Reads reach the wrapped storage
+	objects, err := ro.List(ctx, "")
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("List() = %v, %v, want one object", objects, err)
+	}
+	reader, err := ro.Open(ctx, "backup.tar.gz")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	if string(data) != "backup" {
+		t.Errorf("Open() read %q, want backup", data)
+	}
+
+	// Writes are refused and leave the storage untouched
+	if err := ro.Upload(ctx, "other.tar.gz", strings.NewReader("x"), nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Upload() error = %v, want ErrReadOnly", err)
+	}
+	if err := ro.Delete(ctx, "backup.tar.gz"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete() error = %v, want ErrReadOnly", err)
+	}
+	if err := ro.Copy(ctx, "backup.tar.gz", "copy.tar.gz"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Copy() error = %v, want ErrReadOnly", err)
+	}
+	if objects, _ := fs.List(ctx, ""); len(objects) != 1 || objects[0].Key != "backup.tar.gz" {
+		t.Errorf("storage holds %v after refused writes, want only backup.tar.gz", objects)
+	}
+}
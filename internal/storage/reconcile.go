@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
+)
+
+// Reconciliation lists the differences between the catalog and the bucket.
+type Reconciliation struct {
+	Added   []CatalogEntry `json:"added"`   // Backups stored out-of-band, now catalogued
+	Removed []CatalogEntry `json:"removed"` // Entries whose backup was deleted out-of-band
+	Changed []CatalogEntry `json:"changed"` // Entries whose backup was replaced with a different size
+	Unknown []ObjectInfo   `json:"unknown"` // Objects under the prefix that are not backups
+}
+
+// Empty reports whether the catalog matched the bucket.
+func (r *Reconciliation) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0 && len(r.Unknown) == 0
+}
+
+// ReconcileCatalog compares the catalog against a listing of the bucket, and
+// unless dryRun is set, repairs it: backups it does not know are added as
+// listed, entries whose backup is gone are removed, and entries whose backup
+// changed size are updated. Entries of failed runs are kept, as their backup
+// may have been deleted on purpose. Backups are objects with a backup filename
+// starting with filenamePrefix, backup-timestamp metadata, or the extension of
+// a dump written by another tool; backups named after another prefix are left
+// alone, and any other object is only reported. A missing catalog is started
+// empty, so every backup is reported as added.
+func ReconcileCatalog(ctx context.Context, store Storage, filenamePrefix string, dryRun bool) (*Reconciliation, error) {
+	var objects []ObjectInfo
+	err := ListIter(ctx, store, "", func(obj ObjectInfo) error {
+		if !IsReservedKey(obj.Key) && !utils.IsSidecar(obj.Key) {
+			objects = append(objects, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if dryRun {
+		catalog := &Catalog{}
+		if r, err := store.Open(ctx, CatalogKey); err == nil {
+			err = json.NewDecoder(r).Decode(catalog)
+			_ = r.Close()
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", CatalogKey, err)
+			}
+		}
+		return reconcile(ctx, store, catalog, objects, filenamePrefix), nil
+	}
+
+	var result *Reconciliation
+	err = updateObject(ctx, store, CatalogKey, func(data []byte) ([]byte, error) {
+		catalog := &Catalog{Backups: []CatalogEntry{}}
+		if data != nil {
+			if err := json.Unmarshal(data, catalog); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", CatalogKey, err)
+			}
+		}
+		result = reconcile(ctx, store, catalog, objects, filenamePrefix)
+		catalog.UpdatedAt = time.Now().UTC()
+		return json.MarshalIndent(catalog, "", "  ")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reconcile applies the differences between catalog and the listed objects
+// to catalog and returns them. Objects the catalog does not know are read
+// with Stat for their backup time and metadata.
+func reconcile(ctx context.Context, store Storage, catalog *Catalog, objects []ObjectInfo, filenamePrefix string) *Reconciliation {
+	result := &Reconciliation{}
+	listed := make(map[string]ObjectInfo, len(objects))
+	for _, obj := range objects {
+		listed[obj.Key] = obj
+	}
+
+	catalogued := make(map[string]bool, len(catalog.Backups))
+	for _, e := range catalog.Backups {
+		catalogued[e.Key] = true
+		if otherPrefix(e.Key, filenamePrefix) {
+			continue
+		}
+		obj, ok := listed[e.Key]
+		switch {
+		case !ok && e.Status != CatalogStatusFailed:
+			result.Removed = append(result.Removed, e)
+		case ok && obj.Size != e.SizeBytes:
+			e.SizeBytes = obj.Size
+			e.SHA256 = ""
+			e.Status = CatalogStatusListed
+			result.Changed = append(result.Changed, e)
+		}
+	}
+
+	for _, obj := range objects {
+		if catalogued[obj.Key] || otherPrefix(obj.Key, filenamePrefix) {
+			continue
+		}
+		if info, err := store.Stat(ctx, obj.Key); err == nil {
+			obj.Metadata = info.Metadata
+		}
+		_, err := utils.ParseBackupFilename(path.Base(obj.Key))
+		_, stamped := obj.Metadata["backup-timestamp"]
+		if err != nil && !stamped && ForeignDumpFormat(obj) == "" {
+			result.Unknown = append(result.Unknown, obj)
+			continue
+		}
+		result.Added = append(result.Added, CatalogEntry{
+			Key:       obj.Key,
+			Time:      BackupTime(obj).UTC(),
+			SizeBytes: obj.Size,
+			Status:    CatalogStatusListed,
+			Metadata:  obj.Metadata,
+		})
+	}
+
+	for _, e := range result.Removed {
+		catalog.Remove(e.Key)
+	}
+	for _, e := range append(result.Changed, result.Added...) {
+		catalog.Put(e)
+	}
+	return result
+}
+
+// otherPrefix reports whether key is named as a backup of another database
+// than the one whose filenames start with filenamePrefix.
+func otherPrefix(key, filenamePrefix string) bool {
+	name := path.Base(key)
+	_, err := utils.ParseBackupFilename(name)
+	return err == nil && !strings.HasPrefix(name, filenamePrefix)
+}
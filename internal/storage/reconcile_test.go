@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconcileCatalog(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		kept     = "backup-pg16-2025-01-01T00-00-00-000Z.tar.gz"
+		deleted  = "backup-pg16-2025-01-02T00-00-00-000Z.tar.gz"
+		failed   = "backup-pg16-2025-01-03T00-00-00-000Z.tar.gz"
+		replaced = "backup-pg16-2025-01-04T00-00-00-000Z.tar.gz"
+		copied   = "backup-pg16-2025-01-05T00-00-00-000Z.tar.gz"
+		stamped  = "backup-manual.dump"
+		unknown  = "backup-notes.txt"
+		other    = "other-pg16-2025-01-06T00-00-00-000Z.tar.gz"
+
+		// Objects not named after the prefix are still classified
+		stray   = "notes.txt"
+		foreign = "nightly.sql.gz"
+	)
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	catalog := Catalog{Backups: []CatalogEntry{
+		{Key: kept, Time: day(1), SizeBytes: 4, Status: CatalogStatusComplete},
+		{Key: deleted, Time: day(2), SizeBytes: 4, Status: CatalogStatusComplete},
+		{Key: failed, Time: day(3), SizeBytes: 4, Status: CatalogStatusFailed},
+		{Key: replaced, Time: day(4), SizeBytes: 4, SHA256: "abc", Status: CatalogStatusComplete},
+		{Key: "other-pg16-2025-01-01T00-00-00-000Z.tar.gz", Time: day(1), SizeBytes: 4, Status: CatalogStatusComplete},
+	}}
+	if err := UpdateCatalog(ctx, fs, func(c *Catalog) { *c = catalog }); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, data := range map[string]string{
+		kept:             "data",
+		kept + ".sha256": "sum",
+		replaced:         "longer data",
+		copied:           "data",
+		unknown:          "data",
+		other:            "data",
+		stray:            "data",
+		foreign:          "data",
+	} {
+		if err := fs.Upload(ctx, key, strings.NewReader(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadata := map[string]string{"backup-timestamp": day(6).Format(time.RFC3339), "backup-label": "manual"}
+	if err := fs.Upload(ctx, stamped, strings.NewReader("data"), metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(entries []CatalogEntry) string {
+		var keys []string
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		return strings.Join(keys, ",")
+	}
+
+	// A dry run reports the differences without repairing them
+	dry, err := ReconcileCatalog(ctx, fs, "backup", true)
+	if err != nil {
+		t.Fatalf("ReconcileCatalog(dry run) error = %v", err)
+	}
+	if got, _ := ReadCatalog(ctx, fs); len(got.Backups) != len(catalog.Backups) {
+		t.Errorf("dry run changed the catalog: %+v", got.Backups)
+	}
+
+	result, err := ReconcileCatalog(ctx, fs, "backup", false)
+	if err != nil {
+		t.Fatalf("ReconcileCatalog() error = %v", err)
+	}
+	for _, r := range []*Reconciliation{dry, result} {
+		if got, want := keys(r.Added), stamped+","+copied+","+foreign; got != want {
+			t.Errorf("added = %s, want %s", got, want)
+		}
+		if got := keys(r.Removed); got != deleted {
+			t.Errorf("removed = %s, want %s", got, deleted)
+		}
+		if got := keys(r.Changed); got != replaced {
+			t.Errorf("changed = %s, want %s", got, replaced)
+		}
+		if len(r.Unknown) != 2 || r.Unknown[0].Key != unknown || r.Unknown[1].Key != stray {
+			t.Errorf("unknown = %+v, want %s and %s", r.Unknown, unknown, stray)
+		}
+	}
+
+	got, err := ReadCatalog(ctx, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := kept + ",other-pg16-2025-01-01T00-00-00-000Z.tar.gz," + failed + "," + replaced + "," + copied + "," + stamped + "," + foreign
+	if got := keys(got.Backups); got != want {
+		t.Errorf("catalog keys = %s, want %s", got, want)
+	}
+	for _, e := range got.Backups {
+		switch e.Key {
+		case replaced:
+			if e.SizeBytes != int64(len("longer data")) || e.SHA256 != "" || e.Status != CatalogStatusListed {
+				t.Errorf("replaced entry = %+v, want the new size, listed, without a checksum", e)
+			}
+		case stamped:
+			if !e.Time.Equal(day(6)) || e.Metadata["backup-label"] != "manual" {
+				t.Errorf("stamped entry = %+v, want its metadata time and label", e)
+			}
+		}
+	}
+
+	// A second pass finds nothing left to repair but the unknown objects
+	again, err := ReconcileCatalog(ctx, fs, "backup", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Added)+len(again.Removed)+len(again.Changed) != 0 || len(again.Unknown) != 2 {
+		t.Errorf("second ReconcileCatalog() = %+v, want only the unknown objects", again)
+	}
+
+	// Without a catalog every backup is reported as added
+	empty, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.Upload(ctx, copied, strings.NewReader("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := ReconcileCatalog(ctx, empty, "backup", false); err != nil || keys(r.Added) != copied {
+		t.Errorf("ReconcileCatalog() without a catalog = %+v, %v; want %s added", r, err, copied)
+	}
+}
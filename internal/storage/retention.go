@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
+)
+
+// RetentionReportKey names the report of what a retention dry run would
+// delete, stored in the destination it describes.
+const RetentionReportKey = "retention-report.json"
+
+// RetentionReport lists the keys retention would delete from a destination
+// and why, for review before real deletion is enabled.
+type RetentionReport struct {
+	Destination   string           `json:"destination"`
+	GeneratedAt   time.Time        `json:"generated_at"`
+	RetentionDays int              `json:"retention_days"`
+	Cutoff        time.Time        `json:"cutoff"`
+	WouldDelete   []hooks.Deletion `json:"would_delete"`
+}
+
+// WriteRetentionReport stores report, replacing the previous one.
+func WriteRetentionReport(ctx context.Context, store Storage, report RetentionReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := store.Upload(ctx, RetentionReportKey, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", RetentionReportKey, err)
+	}
+	return nil
+}
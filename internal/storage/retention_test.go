@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/imedwei/railway-postgres-backup/internal/hooks"
+)
+
+func TestWriteRetentionReport(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	taken := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	report := RetentionReport{
+		Destination:   "filesystem",
+		GeneratedAt:   taken.AddDate(0, 0, 30),
+		RetentionDays: 7,
+		Cutoff:        taken.AddDate(0, 0, 23),
+		WouldDelete:   []hooks.Deletion{{Key: "backup.tar.gz", BackupTime: taken, Reason: "older than 7 days"}},
+	}
+	if err := WriteRetentionReport(ctx, fs, report); err != nil {
+		t.Fatalf("WriteRetentionReport() error = %v", err)
+	}
+
+	r, err := fs.Open(ctx, RetentionReportKey)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	data, _ := io.ReadAll(r)
+	var got RetentionReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if len(got.WouldDelete) != 1 || got.WouldDelete[0] != report.WouldDelete[0] {
+		t.Errorf("report = %+v, want %+v", got, report)
+	}
+
+	// The report is not a backup
+	if !IsReservedKey(RetentionReportKey) {
+		t.Error("IsReservedKey(RetentionReportKey) = false")
+	}
+}
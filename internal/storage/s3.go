@@ -5,10 +5,11 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"path"
-	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,39 +17,80 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
 )
 
 // S3Storage implements Storage interface for AWS S3.
 type S3Storage struct {
 	client       *s3.Client
+	deleter      *s3.Client // Client for deletes; the delete credentials when configured
 	uploader     *manager.Uploader
+	multipart    MultipartAPI
 	bucket       string
+	region       string
+	endpoint     string
 	prefix       string
 	objectLock   bool
 	usePathStyle bool
+	tags         map[string]string
+	tagging      string
+	tempDir      string
+	partSize     int64
+	concurrency  int
+	threshold    int64
+	verifyETags  bool
+	singlePut    bool
 }
 
+// defaultMultipartThreshold is the upload size above which backups are streamed
+// as a multipart upload instead of being sent in a single request.
+const defaultMultipartThreshold = 64 * 1024 * 1024
+
 // S3Config holds S3-specific configuration.
 type S3Config struct {
-	AccessKeyID     string
+	AccessKeyID     string // Optional; the default credential chain is used when empty
 	SecretAccessKey string
 	Region          string
 	Bucket          string
-	Endpoint        string // Optional custom endpoint
-	Prefix          string // Optional prefix for all keys
-	ObjectLock      bool   // Enable object lock with MD5
-	UsePathStyle    bool   // For S3-compatible services
+	Endpoint        string            // Optional custom endpoint
+	Prefix          string            // Optional prefix for all keys
+	ObjectLock      bool              // Enable object lock with MD5
+	UsePathStyle    bool              // For S3-compatible services
+	Tags            map[string]string // Optional object tags applied to every upload
+	TempDir         string            // Spool directory for object-lock uploads (defaults to TMPDIR)
+	PartSize        int64             // Multipart part size in bytes (0 uses the SDK default)
+	Concurrency     int               // Parts uploaded in parallel (0 uses the SDK default)
+	Threshold       int64             // Size above which uploads stream as multipart (0 uses 64 MiB)
+	VerifyETags     bool              // Fail uploads whose returned ETag does not match the data sent
+	SinglePut       bool              // Spool uploads to TempDir and send them in one request, for endpoints without multipart uploads
+
+	// DeleteAccessKeyID and DeleteSecretAccessKey are optional keys used only
+	// for deletes, so the main keys can be limited to writing and reading
+	DeleteAccessKeyID     string
+	DeleteSecretAccessKey string
+
+	// ChecksumsWhenRequired only sends and validates checksums when an API requires
+	// them; S3-compatible services such as R2 reject the SDK's default trailing checksums
+	ChecksumsWhenRequired bool
 }
 
 // NewS3Storage creates a new S3 storage provider.
 func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
 	// Create AWS config
-	awsCfg, err := config.LoadDefaultConfig(ctx,
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
-		),
+	loadOpts := []func(*config.LoadOptions) error{
 		config.WithRegion(cfg.Region),
-	)
+	}
+
+	// Static keys take precedence; otherwise rely on the default credential chain
+	// (instance profile, IRSA, web identity token, shared config)
+	if cfg.AccessKeyID != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -60,6 +102,13 @@ func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
 		},
 	}
 
+	if cfg.ChecksumsWhenRequired {
+		clientOpts = append(clientOpts, func(o *s3.Options) {
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		})
+	}
+
 	// Add custom endpoint if provided
 	if cfg.Endpoint != "" {
 		clientOpts = append(clientOpts, func(o *s3.Options) {
@@ -70,16 +119,52 @@ func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
 	// Create S3 client
 	client := s3.NewFromConfig(awsCfg, clientOpts...)
 
-	// Create uploader
-	uploader := manager.NewUploader(client)
+	deleter := client
+	if cfg.DeleteAccessKeyID != "" {
+		deleteCfg := awsCfg.Copy()
+		deleteCfg.Credentials = aws.NewCredentialsCache(
+			credentials.NewStaticCredentialsProvider(cfg.DeleteAccessKeyID, cfg.DeleteSecretAccessKey, ""),
+		)
+		deleter = s3.NewFromConfig(deleteCfg, clientOpts...)
+	}
+
+	// Create uploader; memory use is roughly PartSize * Concurrency
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		if cfg.PartSize > 0 {
+			u.PartSize = cfg.PartSize
+		}
+		if cfg.SinglePut {
+			u.PartSize = maxSinglePutSize
+		}
+		if cfg.Concurrency > 0 {
+			u.Concurrency = cfg.Concurrency
+		}
+	})
+
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = defaultMultipartThreshold
+	}
 
 	return &S3Storage{
 		client:       client,
+		deleter:      deleter,
 		uploader:     uploader,
+		multipart:    client,
 		bucket:       cfg.Bucket,
+		region:       cfg.Region,
+		endpoint:     cfg.Endpoint,
 		prefix:       cfg.Prefix,
 		objectLock:   cfg.ObjectLock,
 		usePathStyle: cfg.UsePathStyle,
+		tags:         cfg.Tags,
+		tagging:      encodeTags(cfg.Tags),
+		tempDir:      cfg.TempDir,
+		partSize:     uploader.PartSize,
+		concurrency:  uploader.Concurrency,
+		threshold:    threshold,
+		verifyETags:  cfg.VerifyETags,
+		singlePut:    cfg.SinglePut,
 	}, nil
 }
 
@@ -94,36 +179,141 @@ func (s *S3Storage) Upload(ctx context.Context, key string, reader io.Reader, me
 		Metadata: metadata,
 	}
 
-	// If object lock is enabled, calculate MD5
-	if s.objectLock {
-		data, err := io.ReadAll(reader)
+	// The upload manager carries tagging over to multipart uploads
+	if s.tagging != "" {
+		input.Tagging = aws.String(s.tagging)
+	}
+
+	// If object lock is enabled, calculate MD5 while spooling to disk so
+	// large backups are not held in memory. Without multipart uploads the
+	// spooled backup is sent in a single request.
+	if s.objectLock || s.singlePut {
+		spool, contentMD5, err := spoolWithMD5(utils.TempDir(s.tempDir), reader, uploadSize(ctx))
 		if err != nil {
-			return fmt.Errorf("failed to read data for MD5: %w", err)
+			return err
+		}
+		defer func() {
+			_ = spool.Cleanup()
+		}()
+
+		// S3 checks the body against Content-MD5 itself
+		if s.objectLock {
+			input.ContentMD5 = aws.String(contentMD5)
 		}
+		input.Body = spool
+		return s.put(ctx, input, nil)
+	}
+
+	// Buffer up to the threshold: small backups are sent in a single request,
+	// larger ones are streamed as a multipart upload of unknown size
+	head, complete, err := readHead(reader, s.threshold)
+	if err != nil {
+		return fmt.Errorf("failed to read backup data: %w", err)
+	}
+	if complete {
+		input.Body = bytes.NewReader(head)
+		return s.put(ctx, input, head)
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), reader)
+	err = StreamingMultipartUpload(ctx, s.multipart, s.bucket, fullKey, body, metadata, MultipartOptions{
+		PartSize:    s.partSize,
+		Concurrency: s.concurrency,
+		Tags:        s.tags,
+		VerifyETags: s.verifyETags,
+	})
+	if errors.Is(err, ErrIntegrity) {
+		s.deleteCorrupt(ctx, fullKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
 
-		// Calculate MD5
-		hash := md5.Sum(data)
-		contentMD5 := base64.StdEncoding.EncodeToString(hash[:])
-		input.ContentMD5 = aws.String(contentMD5)
+	return nil
+}
 
-		// Reset reader with the data we read
-		input.Body = bytes.NewReader(data)
+// put uploads a single object through the upload manager. When data holds
+// the body, the ETag S3 returns is checked against it.
+// readHead reads reader up to limit bytes, growing the buffer with the data
+// read instead of allocating limit bytes up front, and reports whether the
+// reader ended before the limit.
+func readHead(reader io.Reader, limit int64) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(reader, limit))
+	if err != nil {
+		return nil, false, err
 	}
+	return buf.Bytes(), n < limit, nil
+}
 
-	// Upload the file
-	_, err := s.uploader.Upload(ctx, input)
+func (s *S3Storage) put(ctx context.Context, input *s3.PutObjectInput, data []byte) error {
+	output, err := s.uploader.Upload(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
 
+	if s.verifyETags && data != nil && etagIsMD5(output.ServerSideEncryption, input.SSECustomerAlgorithm) {
+		if err := checkETag(output.ETag, uploadManagerETag(data, s.uploader.PartSize)); err != nil {
+			s.deleteCorrupt(ctx, aws.ToString(input.Key))
+			return fmt.Errorf("failed to upload to S3: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// deleteCorrupt removes an object that failed its integrity check, so it is
+// never mistaken for a good backup. Deleting a key that was never stored is
+// not an error on S3.
+func (s *S3Storage) deleteCorrupt(ctx context.Context, fullKey string) {
+	_, _ = s.deleter.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fullKey),
+	})
+}
+
+// Open implements Storage.Open.
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.getFullKey(key)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open S3 object: %w", err)
+	}
+
+	return resp.Body, nil
+}
+
+// OpenRange implements Storage.OpenRange with a ranged GET request.
+func (s *S3Storage) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.getFullKey(key)),
+		Range:  aws.String(byteRange(offset, length)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open S3 object range: %w", err)
+	}
+
+	// A range cannot be empty, so a zero length reads one byte and drops it
+	return limitReadCloser(resp.Body, length), nil
+}
+
+// byteRange returns the HTTP Range header value for length bytes from offset,
+// or for the rest of the object when length is negative.
+func byteRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+max(length, 1)-1)
+}
+
 // Delete implements Storage.Delete.
 func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	fullKey := s.getFullKey(key)
 
-	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+	_, err := s.deleter.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(fullKey),
 	})
@@ -134,71 +324,64 @@ func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Copy implements Storage.Copy with a server-side copy.
+func (s *S3Storage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if err := s3Copy(ctx, s.client, s.bucket, s.getFullKey(srcKey), s.getFullKey(dstKey), s.tagging); err != nil {
+		return fmt.Errorf("failed to copy in S3: %w", err)
+	}
+	return nil
+}
+
 // List implements Storage.List.
 func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
-	fullPrefix := s.getFullKey(prefix)
-
 	var objects []ObjectInfo
+	err := s.ListIter(ctx, prefix, func(obj ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListIter implements ListIterator a page of the listing at a time.
+func (s *S3Storage) ListIter(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
 	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(fullPrefix),
+		Prefix: aws.String(s.getFullKey(prefix)),
 	})
 
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+			return fmt.Errorf("failed to list S3 objects: %w", err)
 		}
 
 		for _, obj := range page.Contents {
-			objects = append(objects, ObjectInfo{
+			err := fn(ObjectInfo{
 				Key:          s.stripPrefix(*obj.Key),
 				Size:         *obj.Size,
 				LastModified: *obj.LastModified,
-				Metadata:     make(map[string]string), // Metadata requires separate HEAD request
+				Metadata:     make(map[string]string), // Filled in by ListWithMetadata
 			})
+			if err != nil {
+				return err
+			}
 		}
 	}
 
-	return objects, nil
+	return nil
+}
+
+// Stat implements Storage.Stat with a HEAD request.
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return headObject(ctx, s.client, s.bucket, s.getFullKey(key), key)
 }
 
 // GetLastBackupTime implements Storage.GetLastBackupTime.
 func (s *S3Storage) GetLastBackupTime(ctx context.Context) (time.Time, error) {
-	objects, err := s.List(ctx, "")
-	if err != nil {
-		return time.Time{}, err
-	}
-
-	if len(objects) == 0 {
-		return time.Time{}, nil
-	}
-
-	// Sort by last modified time descending
-	sort.Slice(objects, func(i, j int) bool {
-		return objects[i].LastModified.After(objects[j].LastModified)
-	})
-
-	// Get metadata for the most recent object
-	fullKey := s.getFullKey(objects[0].Key)
-	headResp, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(fullKey),
-	})
-	if err != nil {
-		// If we can't get metadata, return the last modified time
-		return objects[0].LastModified, nil
-	}
-
-	// Check for backup timestamp in metadata
-	if timestamp, ok := headResp.Metadata["backup-timestamp"]; ok {
-		t, err := time.Parse(time.RFC3339, timestamp)
-		if err == nil {
-			return t, nil
-		}
-	}
-
-	return objects[0].LastModified, nil
+	return listedBackupTime(ctx, s)
 }
 
 // getFullKey returns the full S3 key with prefix.
@@ -217,6 +400,41 @@ func (s *S3Storage) stripPrefix(key string) string {
 	return key[len(s.prefix)+1:]
 }
 
+// spoolWithMD5 copies reader to a spool file in dir, returning the file rewound
+// to the start together with the base64-encoded MD5 of its content. The spool
+// is refused when dir cannot hold estimatedSize bytes.
+func spoolWithMD5(dir string, reader io.Reader, estimatedSize int64) (*utils.SpoolFile, string, error) {
+	spool, err := utils.NewSpoolFile(dir, "s3-upload-*", estimatedSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(spool, hash), reader); err != nil {
+		_ = spool.Cleanup()
+		return nil, "", fmt.Errorf("failed to read data for MD5: %w", err)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		_ = spool.Cleanup()
+		return nil, "", fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+
+	return spool, base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// encodeTags formats object tags as the URL-encoded query string S3 expects.
+func encodeTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
 // readerAt wraps a byte slice to implement io.ReaderAt.
 type readerAt struct {
 	data []byte
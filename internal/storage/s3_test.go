@@ -1,7 +1,22 @@
 package storage
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/imedwei/railway-postgres-backup/internal/utils"
 )
 
 // MockS3Client is a mock implementation for testing.
@@ -165,3 +180,272 @@ func TestS3Config_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestNewS3Storage_DeleteCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         S3Config
+		wantDeleter string
+	}{
+		{
+			name:        "shared keys",
+			cfg:         S3Config{AccessKeyID: "writer", SecretAccessKey: "secret", Region: "us-east-1", Bucket: "b"},
+			wantDeleter: "writer",
+		},
+		{
+			name: "separate delete keys",
+			cfg: S3Config{AccessKeyID: "writer", SecretAccessKey: "secret", Region: "us-east-1", Bucket: "b",
+				DeleteAccessKeyID: "pruner", DeleteSecretAccessKey: "prune-secret"},
+			wantDeleter: "pruner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, err := NewS3Storage(ctx, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewS3Storage() error = %v", err)
+			}
+
+			writer, err := s.client.Options().Credentials.Retrieve(ctx)
+			if err != nil {
+				t.Fatalf("Retrieve() error = %v", err)
+			}
+			if writer.AccessKeyID != "writer" {
+				t.Errorf("client key = %q, want %q", writer.AccessKeyID, "writer")
+			}
+
+			deleter, err := s.deleter.Options().Credentials.Retrieve(ctx)
+			if err != nil {
+				t.Fatalf("Retrieve() error = %v", err)
+			}
+			if deleter.AccessKeyID != tt.wantDeleter {
+				t.Errorf("deleter key = %q, want %q", deleter.AccessKeyID, tt.wantDeleter)
+			}
+		})
+	}
+}
+
+func TestEncodeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want string
+	}{
+		{
+			name: "no tags",
+			tags: nil,
+			want: "",
+		},
+		{
+			name: "sorted and encoded",
+			tags: map[string]string{"env": "prod", "app": "my service"},
+			want: "app=my+service&env=prod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeTags(tt.tags); got != tt.want {
+				t.Errorf("encodeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 512, "bytes=0-511"},
+		{1024, 1, "bytes=1024-1024"},
+		{1024, -1, "bytes=1024-"},
+		{10, 0, "bytes=10-10"},
+	}
+
+	for _, tt := range tests {
+		if got := byteRange(tt.offset, tt.length); got != tt.want {
+			t.Errorf("byteRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestSpoolWithMD5(t *testing.T) {
+	dir := t.TempDir()
+	payload := strings.Repeat("backup data ", 100000)
+
+	spool, contentMD5, err := spoolWithMD5(dir, strings.NewReader(payload), int64(len(payload)))
+	if err != nil {
+		t.Fatalf("spoolWithMD5() error = %v", err)
+	}
+
+	sum := md5.Sum([]byte(payload))
+	if want := base64.StdEncoding.EncodeToString(sum[:]); contentMD5 != want {
+		t.Errorf("spoolWithMD5() md5 = %v, want %v", contentMD5, want)
+	}
+
+	data, err := io.ReadAll(spool)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != payload {
+		t.Errorf("spool content length = %d, want %d", len(data), len(payload))
+	}
+
+	if err := spool.Cleanup(); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected spool file to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestReadHead(t *testing.T) {
+	const limit = 64 * 1024 * 1024
+
+	// A small upload only holds its own bytes, not the multipart threshold
+	head, complete, err := readHead(strings.NewReader("data"), limit)
+	if err != nil || string(head) != "data" || !complete {
+		t.Errorf("readHead() = %q, %v, %v; want the whole input", head, complete, err)
+	}
+	if cap(head) >= limit {
+		t.Errorf("readHead() buffer capacity = %d, want it sized to the data", cap(head))
+	}
+
+	// Input reaching the limit is streamed as a multipart upload
+	for _, size := range []int{8, 9} {
+		head, complete, err := readHead(strings.NewReader(strings.Repeat("x", size)), 8)
+		if err != nil || len(head) != 8 || complete {
+			t.Errorf("readHead() of %d bytes = %d bytes, %v, %v; want 8 bytes and more to come", size, len(head), complete, err)
+		}
+	}
+
+	if _, _, err := readHead(iotest.ErrReader(errors.New("pipe closed")), limit); err == nil {
+		t.Error("readHead() expected the read error")
+	}
+}
+
+func TestS3Storage_UploadSpoolTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	if available, _ := utils.AvailableDiskSpace(dir); available == math.MaxInt64 {
+		t.Skip("disk space is not reported on this platform")
+	}
+	s, err := NewS3Storage(context.Background(), S3Config{
+		Bucket: "backups", Region: "us-east-1", AccessKeyID: "key", SecretAccessKey: "secret",
+		SinglePut: true, TempDir: dir,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage() error = %v", err)
+	}
+
+	ctx := WithUploadSize(context.Background(), math.MaxInt64)
+	if err := s.Upload(ctx, "backup.tar.gz", strings.NewReader("data"), nil); !errors.Is(err, utils.ErrInsufficientDiskSpace) {
+		t.Errorf("Upload() error = %v, want ErrInsufficientDiskSpace", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spool directory holds %d entries, want none", len(entries))
+	}
+	if !SpoolsUploads(NewRetryableStorage(s, DefaultRetryConfig())) {
+		t.Error("SpoolsUploads() = false, want true for single-request uploads")
+	}
+}
+
+// fakeBucketAPI records bucket creation requests.
+type fakeBucketAPI struct {
+	exists        bool
+	headErr       error
+	createErr     error
+	created       *s3.CreateBucketInput
+	blockedPublic bool
+}
+
+func (f *fakeBucketAPI) HeadBucket(ctx context.Context, in *s3.HeadBucketInput, _ ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if f.headErr != nil {
+		return nil, f.headErr
+	}
+	if f.exists {
+		return &s3.HeadBucketOutput{}, nil
+	}
+	return nil, &types.NotFound{}
+}
+
+func (f *fakeBucketAPI) CreateBucket(ctx context.Context, in *s3.CreateBucketInput, _ ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	f.created = in
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeBucketAPI) PutPublicAccessBlock(ctx context.Context, in *s3.PutPublicAccessBlockInput, _ ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	f.blockedPublic = aws.ToBool(in.PublicAccessBlockConfiguration.BlockPublicAcls)
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+func TestEnsureS3Bucket(t *testing.T) {
+	tests := []struct {
+		name           string
+		api            *fakeBucketAPI
+		region         string
+		blockPublic    bool
+		wantCreated    bool
+		wantErr        bool
+		wantConstraint string
+	}{
+		{
+			name: "existing bucket",
+			api:  &fakeBucketAPI{exists: true},
+		},
+		{
+			name:           "missing bucket in eu-west-1",
+			api:            &fakeBucketAPI{},
+			region:         "eu-west-1",
+			blockPublic:    true,
+			wantCreated:    true,
+			wantConstraint: "eu-west-1",
+		},
+		{
+			name:        "missing bucket in us-east-1",
+			api:         &fakeBucketAPI{},
+			region:      "us-east-1",
+			wantCreated: true,
+		},
+		{
+			name:    "access denied",
+			api:     &fakeBucketAPI{headErr: errors.New("forbidden")},
+			wantErr: true,
+		},
+		{
+			name: "created concurrently",
+			api:  &fakeBucketAPI{createErr: &types.BucketAlreadyOwnedByYou{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := ensureS3Bucket(context.Background(), tt.api, "backups", tt.region, tt.blockPublic)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureS3Bucket() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("ensureS3Bucket() created = %v, want %v", created, tt.wantCreated)
+			}
+			if !tt.wantCreated {
+				return
+			}
+
+			var constraint string
+			if cfg := tt.api.created.CreateBucketConfiguration; cfg != nil {
+				constraint = string(cfg.LocationConstraint)
+			}
+			if constraint != tt.wantConstraint {
+				t.Errorf("LocationConstraint = %q, want %q", constraint, tt.wantConstraint)
+			}
+			if tt.api.blockedPublic != tt.blockPublic {
+				t.Errorf("public access blocked = %v, want %v", tt.api.blockedPublic, tt.blockPublic)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package storage
+
+import "strings"
+
+// SchemaPrefix is where SCHEMA_DEDUP keeps the schema-only dumps that data
+// backups reference, one object per distinct schema.
+const SchemaPrefix = "schemas/"
+
+// SchemaKey returns the key of the schema with the given hex SHA-256 for the
+// backups of filenamePrefix. Schemas are addressed by content, so a schema
+// that did not change between backups is stored once.
+func SchemaKey(filenamePrefix, sha256, ext string) string {
+	return SchemaPrefix + filenamePrefix + "-" + sha256 + ".sql" + ext
+}
+
+// IsSchemaKey reports whether key names a stored schema.
+func IsSchemaKey(key string) bool {
+	return strings.HasPrefix(key, SchemaPrefix)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MaxShareExpiry is the longest validity of a signed URL accepted by S3 and GCS.
+const MaxShareExpiry = 7 * 24 * time.Hour
+
+// ErrSharingUnsupported is returned for storage providers without signed URLs.
+var ErrSharingUnsupported = errors.New("storage provider does not support signed URLs")
+
+// URLSigner is implemented by storages that can hand out time-limited download
+// URLs, so a backup can be shared without sharing bucket credentials.
+type URLSigner interface {
+	// SignedURL returns a URL that downloads key until expiry has passed.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// AsURLSigner returns the URL signer behind a storage, unwrapping retries,
+// the local cache and key prefixes. With multiple destinations the first that
+// can sign is used.
+func AsURLSigner(store Storage) (URLSigner, bool) {
+	switch s := store.(type) {
+	case *RetryableStorage:
+		return AsURLSigner(s.storage)
+	case *CachedStorage:
+		return AsURLSigner(s.remote)
+	case *FaultyStorage:
+		return AsURLSigner(s.storage)
+	case *MultiStorage:
+		for _, dest := range s.destinations {
+			if signer, ok := AsURLSigner(dest.Storage); ok {
+				return signer, true
+			}
+		}
+		return nil, false
+	case *PrefixedStorage:
+		inner, ok := AsURLSigner(s.storage)
+		if !ok {
+			return nil, false
+		}
+		return &prefixedSigner{signer: inner, prefix: s.prefix}, true
+	case URLSigner:
+		return s, true
+	}
+	return nil, false
+}
+
+// prefixedSigner signs the keys under a prefix.
+type prefixedSigner struct {
+	signer URLSigner
+	prefix string
+}
+
+func (p *prefixedSigner) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return p.signer.SignedURL(ctx, p.prefix+key, expiry)
+}
+
+// ShareURL returns a time-limited download URL for the backup stored under key.
+// Reserved objects are never shared, and the key must exist.
+func ShareURL(ctx context.Context, store Storage, key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > MaxShareExpiry {
+		return "", fmt.Errorf("expiry must be between 1s and %s", MaxShareExpiry)
+	}
+	if IsReservedKey(key) {
+		return "", fmt.Errorf("%s is not a backup", key)
+	}
+
+	signer, ok := AsURLSigner(store)
+	if !ok {
+		return "", ErrSharingUnsupported
+	}
+
+	objects, err := store.List(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to list backups: %w", err)
+	}
+	found := false
+	for _, obj := range objects {
+		found = found || obj.Key == key
+	}
+	if !found {
+		return "", fmt.Errorf("backup %s: %w", key, ErrNotFound)
+	}
+
+	return signer.SignedURL(ctx, key, expiry)
+}
+
+// SignedURL implements URLSigner with a presigned GetObject request.
+func (s *S3Storage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.getFullKey(key)),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign S3 URL: %w", err)
+	}
+	return req.URL, nil
+}
+
+// SignedURL implements URLSigner with a V4 signed URL. The credentials must be
+// able to sign: a service account key, or the IAM signBlob permission.
+func (g *GCSStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	url, err := g.client.Bucket(g.bucket).SignedURL(g.getFullKey(key), &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(expiry),
+		Scheme:  storage.SigningSchemeV4,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GCS URL: %w", err)
+	}
+	return url, nil
+}
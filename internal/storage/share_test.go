@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// signingStorage adds URL signing to filesystem storage.
+type signingStorage struct {
+	*FilesystemStorage
+}
+
+func (s *signingStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "https://signed.example/" + key + "?expires=" + expiry.String(), nil
+}
+
+func TestShareURL(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+	for _, key := range []string{"db/2024/01/backup.tar.gz", "db/" + StateKey} {
+		if err := fs.Upload(ctx, key, strings.NewReader("data"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := NewPrefixedStorage(&signingStorage{fs}, "db/")
+
+	url, err := ShareURL(ctx, store, "2024/01/backup.tar.gz", time.Hour)
+	if err != nil {
+		t.Fatalf("ShareURL() error = %v", err)
+	}
+	if url != "https://signed.example/db/2024/01/backup.tar.gz?expires=1h0m0s" {
+		t.Errorf("ShareURL() = %s, want the prefixed key signed", url)
+	}
+
+	tests := []struct {
+		name    string
+		store   Storage
+		key     string
+		expiry  time.Duration
+		wantErr error
+	}{
+		{name: "missing backup", store: store, key: "2024/01/other.tar.gz", expiry: time.Hour, wantErr: ErrNotFound},
+		{name: "reserved object", store: store, key: StateKey, expiry: time.Hour},
+		{name: "expiry too long", store: store, key: "2024/01/backup.tar.gz", expiry: 8 * 24 * time.Hour},
+		{name: "provider cannot sign", store: fs, key: "db/2024/01/backup.tar.gz", expiry: time.Hour, wantErr: ErrSharingUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ShareURL(ctx, tt.store, tt.key, tt.expiry)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("ShareURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3Storage_SignedURL(t *testing.T) {
+	client := s3.New(s3.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+	})
+	s := &S3Storage{client: client, bucket: "backups", prefix: "db"}
+
+	url, err := s.SignedURL(context.Background(), "2024/01/backup.tar.gz", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL() error = %v", err)
+	}
+	for _, want := range []string{"backups", "db/2024/01/backup.tar.gz", "X-Amz-Expires=900", "X-Amz-Signature="} {
+		if !strings.Contains(url, want) {
+			t.Errorf("SignedURL() = %s, want it to contain %s", url, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import "context"
+
+// uploadSizeKey is the context key of the estimated upload size.
+type uploadSizeKey struct{}
+
+// WithUploadSize returns a context carrying the estimated size of the data
+// uploaded under it, so a storage that stages uploads on local disk can check
+// the spool directory has room before writing to it.
+func WithUploadSize(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, uploadSizeKey{}, size)
+}
+
+// uploadSize returns the estimated upload size carried by ctx, or 0 when it is
+// unknown.
+func uploadSize(ctx context.Context) int64 {
+	size, _ := ctx.Value(uploadSizeKey{}).(int64)
+	return size
+}
+
+// SpoolsUploads reports whether uploads to store are staged on local disk
+// before they are sent, as S3 uploads are for Object Lock buckets and
+// endpoints without multipart uploads. Retries, the local cache, destinations
+// and key prefixes are unwrapped.
+func SpoolsUploads(store Storage) bool {
+	switch s := store.(type) {
+	case *RetryableStorage:
+		return SpoolsUploads(s.storage)
+	case *CachedStorage:
+		return SpoolsUploads(s.remote)
+	case *FaultyStorage:
+		return SpoolsUploads(s.storage)
+	case *PrefixedStorage:
+		return SpoolsUploads(s.storage)
+	case *MultiStorage:
+		for _, dest := range s.destinations {
+			if SpoolsUploads(dest.Storage) {
+				return true
+			}
+		}
+	case *S3Storage:
+		return s.objectLock || s.singlePut
+	}
+	return false
+}
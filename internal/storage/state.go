@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StateKey names the object recording the most recent backup, so the last
+// backup time can be read without listing every object in the bucket.
+const StateKey = "state.json"
+
+// State describes the most recent successful backup and the progress of the
+// latest run.
+type State struct {
+	LastBackupTime time.Time      `json:"last_backup_time"`
+	LastKey        string         `json:"last_key"`
+	LastSize       int64          `json:"last_size"`
+	Run            *RunCheckpoint `json:"run,omitempty"`
+
+	// ConsecutiveFailures counts the failed runs since the last successful upload
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+}
+
+// Run phases recorded in the state object. A run whose last recorded phase is
+// neither complete nor failed was killed in that phase.
+const (
+	PhaseDumping   = "dumping"
+	PhaseUploading = "uploading"
+	PhaseVerifying = "verifying"
+	PhaseRetaining = "retaining"
+	PhaseComplete  = "complete"
+	PhaseFailed    = "failed"
+)
+
+// RunCheckpoint records the phase transitions of a backup run.
+type RunCheckpoint struct {
+	Key       string            `json:"key"`
+	Holder    string            `json:"holder"`
+	Phase     string            `json:"phase"`
+	StartedAt time.Time         `json:"started_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Error     string            `json:"error,omitempty"`
+	Phases    []PhaseTransition `json:"phases"`
+	Commands  []string          `json:"commands,omitempty"`   // pg_dump, psql and pg_restore command lines, credentials redacted
+	SizeBytes int64             `json:"size_bytes,omitempty"` // Bytes uploaded, once the upload completed
+	Retention *RetentionOutcome `json:"retention,omitempty"`  // Set when the run applied retention
+}
+
+// RetentionOutcome summarizes the retention pass of a run.
+type RetentionOutcome struct {
+	RetentionDays int    `json:"retention_days"`
+	DryRun        bool   `json:"dry_run,omitempty"`
+	Deleted       int    `json:"deleted"` // Keys deleted, or that a dry run would delete, sidecars included
+	Error         string `json:"error,omitempty"`
+}
+
+// PhaseTransition is the time a run entered a phase.
+type PhaseTransition struct {
+	Phase string    `json:"phase"`
+	At    time.Time `json:"at"`
+}
+
+// Interrupted reports whether the run ended without recording an outcome.
+func (r *RunCheckpoint) Interrupted() bool {
+	return r.Phase != PhaseComplete && r.Phase != PhaseFailed
+}
+
+// Enter records a transition to phase.
+func (r *RunCheckpoint) Enter(phase string) {
+	now := time.Now().UTC()
+	r.Phase = phase
+	r.UpdatedAt = now
+	r.Phases = append(r.Phases, PhaseTransition{Phase: phase, At: now})
+}
+
+// ReadState reads the state object from store.
+func ReadState(ctx context.Context, store Storage) (*State, error) {
+	r, err := store.Open(ctx, StateKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", StateKey, err)
+	}
+	return &state, nil
+}
+
+// WriteState replaces the state object in store.
+func WriteState(ctx context.Context, store Storage, state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := store.Upload(ctx, StateKey, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", StateKey, err)
+	}
+	return nil
+}
+
+// LastBackupTime returns the time of the most recent backup from the state
+// object, falling back to listing the storage when the state is missing or
+// unreadable (e.g. before the first backup written by this version).
+func LastBackupTime(ctx context.Context, store Storage) (time.Time, error) {
+	if state, err := ReadState(ctx, store); err == nil && !state.LastBackupTime.IsZero() {
+		return state.LastBackupTime, nil
+	}
+	return store.GetLastBackupTime(ctx)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastBackupTime(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystemStorage(FilesystemConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFilesystemStorage() error = %v", err)
+	}
+
+	// Without a state object the storage is listed
+	if err := fs.Upload(ctx, "2024/01/backup.tar.gz", strings.NewReader("data"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	got, err := LastBackupTime(ctx, fs)
+	if err != nil {
+		t.Fatalf("LastBackupTime() error = %v", err)
+	}
+	if time.Since(got) > time.Minute {
+		t.Errorf("LastBackupTime() = %v, want the listed backup's time", got)
+	}
+
+	// A written state is read back instead
+	want := time.Date(2024, 1, 15, 10, 30, 0, 123000000, time.UTC)
+	state := State{LastBackupTime: want, LastKey: "2024/01/backup.tar.gz", LastSize: 4}
+	if err := WriteState(ctx, fs, state); err != nil {
+		t.Fatalf("WriteState() error = %v", err)
+	}
+	read, err := ReadState(ctx, fs)
+	if err != nil {
+		t.Fatalf("ReadState() error = %v", err)
+	}
+	if *read != state {
+		t.Errorf("ReadState() = %+v, want %+v", *read, state)
+	}
+	got, err = LastBackupTime(ctx, fs)
+	if err != nil {
+		t.Fatalf("LastBackupTime() error = %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("LastBackupTime() = %v, want %v from the state", got, want)
+	}
+
+	// A corrupt state falls back to listing
+	if err := fs.Upload(ctx, StateKey, strings.NewReader("{not json"), nil); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if _, err := ReadState(ctx, fs); err == nil {
+		t.Error("ReadState() expected error for a corrupt state")
+	}
+	got, err = LastBackupTime(ctx, fs)
+	if err != nil {
+		t.Fatalf("LastBackupTime() error = %v", err)
+	}
+	if got.Equal(want) || time.Since(got) > time.Minute {
+		t.Errorf("LastBackupTime() = %v, want the listing's time", got)
+	}
+}
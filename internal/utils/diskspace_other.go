@@ -0,0 +1,12 @@
+//go:build !unix
+
+package utils
+
+import (
+	"math"
+)
+
+// AvailableDiskSpace reports unlimited space on platforms without statfs support.
+func AvailableDiskSpace(dir string) (int64, error) {
+	return math.MaxInt64, nil
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package utils
+
+import (
+	"syscall"
+)
+
+// AvailableDiskSpace returns the number of bytes available to unprivileged users in dir.
+func AvailableDiskSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
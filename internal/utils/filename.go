@@ -9,43 +9,59 @@ import (
 
 // GenerateBackupFilename creates a timestamped backup filename with PostgreSQL version.
 func GenerateBackupFilename(prefix string, timestamp time.Time, pgVersion string) string {
+	return GenerateBackupFilenameWithExtension(prefix, timestamp, pgVersion, ".tar.gz")
+}
+
+// GenerateBackupFilenameWithExtension creates a timestamped backup filename ending in ext,
+// e.g. ".tar.zst" for zstd-compressed backups.
+func GenerateBackupFilenameWithExtension(prefix string, timestamp time.Time, pgVersion string, ext string) string {
 	// Format: prefix-pg15-2006-01-02T15-04-05-000Z.tar.gz
-	// Using dashes instead of colons for better filesystem compatibility
+	return fmt.Sprintf("%s-pg%s-%s%s", filenamePrefix(prefix), majorVersion(pgVersion), formatTimestamp(timestamp), ext)
+}
+
+// formatTimestamp formats a backup timestamp as 2006-01-02T15-04-05-000Z.
+// Dashes replace colons for better filesystem compatibility.
+func formatTimestamp(timestamp time.Time) string {
 	// Format milliseconds manually to ensure 3 digits
 	t := timestamp.UTC()
 	ms := t.Nanosecond() / 1000000
-	timeStr := fmt.Sprintf("%s-%03dZ", t.Format("2006-01-02T15-04-05"), ms)
+	return fmt.Sprintf("%s-%03dZ", t.Format("2006-01-02T15-04-05"), ms)
+}
+
+// filenamePrefix returns the filename prefix, defaulting to "backup".
+func filenamePrefix(prefix string) string {
+	if prefix == "" {
+		return "backup"
+	}
+	// Ensure prefix doesn't end with dash
+	return strings.TrimSuffix(prefix, "-")
+}
 
-	// Extract major version from version string (e.g., "PostgreSQL 15.2" -> "15")
-	versionPart := "unknown"
+// majorVersion extracts the major version from a version string
+// (e.g., "PostgreSQL 15.2" -> "15"), or "unknown".
+func majorVersion(pgVersion string) string {
 	if pgVersion != "" && pgVersion != "unknown" {
 		// Try to extract major version number
-		parts := strings.Fields(pgVersion)
-		for _, part := range parts {
+		for _, part := range strings.Fields(pgVersion) {
 			if strings.Contains(part, ".") {
 				versionParts := strings.Split(part, ".")
 				if len(versionParts) > 0 {
-					versionPart = versionParts[0]
-					break
+					return versionParts[0]
 				}
 			}
 		}
 	}
-
-	if prefix != "" {
-		// Ensure prefix doesn't end with dash
-		prefix = strings.TrimSuffix(prefix, "-")
-		return fmt.Sprintf("%s-pg%s-%s.tar.gz", prefix, versionPart, timeStr)
-	}
-
-	return fmt.Sprintf("backup-pg%s-%s.tar.gz", versionPart, timeStr)
+	return "unknown"
 }
 
 // ParseBackupFilename extracts the timestamp from a backup filename.
 // Updated format includes version: prefix-pgXX-2006-01-02T15-04-05-000Z.tar.gz
 func ParseBackupFilename(filename string) (time.Time, error) {
-	// Remove .tar.gz extension
-	name := strings.TrimSuffix(filename, ".tar.gz")
+	// Remove the extension (.tar.gz, .tar.zst, ...), which follows the trailing Z of the timestamp
+	name := filename
+	if idx := strings.LastIndex(name, "Z."); idx >= 0 {
+		name = name[:idx+1]
+	}
 
 	// Find the timestamp part (last 24 characters: 2006-01-02T15-04-05-000Z)
 	if len(name) < 24 {
@@ -77,3 +93,63 @@ func ParseBackupFilename(filename string) (time.Time, error) {
 	// Add milliseconds
 	return t.Add(time.Duration(ms) * time.Millisecond).UTC(), nil
 }
+
+// ParseBackupVersion extracts the PostgreSQL major version from a backup
+// filename (e.g. "16" from "backup-pg16-2006-01-02T15-04-05-000Z.tar.gz"),
+// or "" if the filename does not record one.
+func ParseBackupVersion(filename string) string {
+	name := filename
+	if idx := strings.LastIndex(name, "Z."); idx >= 0 {
+		name = name[:idx+1]
+	}
+	if len(name) < 25 {
+		return ""
+	}
+	// Drop the timestamp and its separator, leaving prefix-pgXX
+	name = name[:len(name)-25]
+	idx := strings.LastIndex(name, "-pg")
+	if idx < 0 {
+		return ""
+	}
+	return name[idx+len("-pg"):]
+}
+
+// SettingsSuffix names the pg_settings snapshot stored next to a backup.
+const SettingsSuffix = ".settings.json"
+
+// RestoreCheckSuffix names the outcome of restoring a backup into a scratch database.
+const RestoreCheckSuffix = ".restore-check.json"
+
+// ChecksumSuffix names the SHA-256 of a backup, in sha256sum format.
+const ChecksumSuffix = ".sha256"
+
+// ManifestSuffix names the description of a backup's content.
+const ManifestSuffix = ".manifest.json"
+
+// RunbookSuffix names the restore instructions generated for a backup.
+const RunbookSuffix = ".RESTORE.md"
+
+// sidecarSuffixes are appended to a backup key to name the objects stored alongside it.
+var sidecarSuffixes = []string{SettingsSuffix, RestoreCheckSuffix, ChecksumSuffix, ManifestSuffix, RunbookSuffix}
+
+// TrimSidecarSuffix returns the key of the backup a sidecar belongs to,
+// or key unchanged if it is not a sidecar.
+func TrimSidecarSuffix(key string) string {
+	for _, suffix := range sidecarSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return strings.TrimSuffix(key, suffix)
+		}
+	}
+	return key
+}
+
+// IsSidecar reports whether key names an object stored alongside a backup
+// rather than a backup itself.
+func IsSidecar(key string) bool {
+	for _, suffix := range sidecarSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -82,6 +82,12 @@ func TestParseBackupFilename(t *testing.T) {
 			want:     time.Date(2025, 1, 21, 10, 30, 45, 123000000, time.UTC),
 			wantErr:  false,
 		},
+		{
+			name:     "zstd extension",
+			filename: "backup-pg16-2025-01-21T10-30-45-123Z.tar.zst",
+			want:     time.Date(2025, 1, 21, 10, 30, 45, 123000000, time.UTC),
+			wantErr:  false,
+		},
 		{
 			name:     "too short",
 			filename: "backup.tar.gz",
@@ -110,6 +116,24 @@ func TestParseBackupFilename(t *testing.T) {
 	}
 }
 
+func TestParseBackupVersion(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"postgres-pg15-2025-01-21T10-30-45-123Z.tar.gz", "15"},
+		{"my-app-pg16-2025-01-21T10-30-45-123Z.tar.zst", "16"},
+		{"backup-pgunknown-2025-01-21T10-30-45-123Z.tar.gz", "unknown"},
+		{"backup-2025-01-21T10-30-45-123Z.tar.gz", ""},
+		{"backup.tar.gz", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseBackupVersion(tt.filename); got != tt.want {
+			t.Errorf("ParseBackupVersion(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
 func TestRoundTrip(t *testing.T) {
 	// Test that generate and parse are inverse operations
 	prefixes := []string{"", "backup", "postgres-db", "my-app"}
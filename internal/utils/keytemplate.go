@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultKeyTemplate stores backups in year/month directories.
+const DefaultKeyTemplate = "{{.Year}}/{{.Month}}/{{.Filename}}"
+
+// KeyFields are the values available to a storage key template.
+type KeyFields struct {
+	Year      string // 4-digit UTC year
+	Month     string // 2-digit UTC month
+	Day       string // 2-digit UTC day
+	Hour      string // 2-digit UTC hour
+	Prefix    string // BACKUP_FILE_PREFIX, defaulting to "backup"
+	Timestamp string // 2006-01-02T15-04-05-000Z
+	PGVersion string // Major server version, e.g. "16"
+	Ext       string // File extension, e.g. ".tar.gz"
+	Filename  string // Default filename: <prefix>-pg<version>-<timestamp><ext>
+}
+
+// NewKeyFields returns the template values for a backup taken at timestamp.
+func NewKeyFields(prefix string, timestamp time.Time, pgVersion, ext string) KeyFields {
+	t := timestamp.UTC()
+	return KeyFields{
+		Year:      fmt.Sprintf("%04d", t.Year()),
+		Month:     fmt.Sprintf("%02d", int(t.Month())),
+		Day:       fmt.Sprintf("%02d", t.Day()),
+		Hour:      fmt.Sprintf("%02d", t.Hour()),
+		Prefix:    filenamePrefix(prefix),
+		Timestamp: formatTimestamp(t),
+		PGVersion: majorVersion(pgVersion),
+		Ext:       ext,
+		Filename:  GenerateBackupFilenameWithExtension(prefix, t, pgVersion, ext),
+	}
+}
+
+// keyFieldPatterns match each field when parsing a rendered key.
+var keyFieldPatterns = map[string]string{
+	"Year":      `(\d{4})`,
+	"Month":     `(\d{2})`,
+	"Day":       `(\d{2})`,
+	"Hour":      `(\d{2})`,
+	"Prefix":    `([^/]*?)`,
+	"Timestamp": `(\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}-\d{3}Z)`,
+	"PGVersion": `(\w+)`,
+	"Ext":       `(\.[^/]+)`,
+	"Filename":  `([^/]+)`,
+}
+
+// KeyTemplate renders storage keys for backups and parses backup times back
+// out of keys it rendered, so retention works with any layout.
+type KeyTemplate struct {
+	tmpl    *template.Template
+	pattern *regexp.Regexp
+	fields  []string // Field captured by each pattern group
+}
+
+// NewKeyTemplate parses a key template such as
+// "{{.Year}}/{{.Month}}/{{.Day}}/{{.Prefix}}-{{.Timestamp}}.tar.gz".
+// The template must include the timestamp (directly or through Filename)
+// so that every backup gets a unique key.
+func NewKeyTemplate(text string) (*KeyTemplate, error) {
+	tmpl, err := template.New("key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key template: %w", err)
+	}
+
+	kt := &KeyTemplate{tmpl: tmpl}
+
+	// Render with a sentinel per field, then turn the sentinels into capture groups
+	sentinels := KeyFields{}
+	names := make(map[string]string)
+	for i, name := range []string{"Year", "Month", "Day", "Hour", "Prefix", "Timestamp", "PGVersion", "Ext", "Filename"} {
+		sentinel := fmt.Sprintf("\x00%d\x00", i)
+		names[sentinel] = name
+		setKeyField(&sentinels, name, sentinel)
+	}
+	rendered, err := kt.execute(sentinels)
+	if err != nil {
+		return nil, err
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, part := range regexp.MustCompile("\x00[0-9]+\x00|[^\x00]+").FindAllString(rendered, -1) {
+		if name, ok := names[part]; ok {
+			pattern.WriteString(keyFieldPatterns[name])
+			kt.fields = append(kt.fields, name)
+		} else {
+			pattern.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	pattern.WriteString("$")
+	kt.pattern = regexp.MustCompile(pattern.String())
+
+	// Keys one millisecond apart must differ, or backups would overwrite each other
+	t := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	first, err := kt.Render(NewKeyFields("", t, "", ".tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	second, err := kt.Render(NewKeyFields("", t.Add(time.Millisecond), "", ".tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	if first == second {
+		return nil, fmt.Errorf("key template must include {{.Timestamp}} or {{.Filename}}")
+	}
+
+	return kt, nil
+}
+
+// Render returns the storage key for a backup.
+func (kt *KeyTemplate) Render(fields KeyFields) (string, error) {
+	key, err := kt.execute(fields)
+	if err != nil {
+		return "", err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("key template rendered an invalid key %q", key)
+	}
+	return key, nil
+}
+
+// ParseTime extracts the backup time from a key rendered by the template,
+// using the most precise field available.
+func (kt *KeyTemplate) ParseTime(key string) (time.Time, error) {
+	match := kt.pattern.FindStringSubmatch(key)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("key %s does not match the key template", key)
+	}
+
+	values := make(map[string]string)
+	for i, name := range kt.fields {
+		values[name] = match[i+1]
+	}
+
+	if ts, ok := values["Timestamp"]; ok {
+		return ParseBackupFilename(ts)
+	}
+	if filename, ok := values["Filename"]; ok {
+		return ParseBackupFilename(filename)
+	}
+
+	// Without a timestamp, fall back to the date directories
+	parts := []int{0, 1, 1, 0}
+	for i, name := range []string{"Year", "Month", "Day", "Hour"} {
+		if v, ok := values[name]; ok {
+			parts[i], _ = strconv.Atoi(v)
+		}
+	}
+	if parts[0] == 0 {
+		return time.Time{}, fmt.Errorf("key template has no time fields")
+	}
+	return time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], 0, 0, 0, time.UTC), nil
+}
+
+func (kt *KeyTemplate) execute(fields KeyFields) (string, error) {
+	var buf bytes.Buffer
+	if err := kt.tmpl.Execute(&buf, fields); err != nil {
+		return "", fmt.Errorf("failed to render key template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// setKeyField sets a KeyFields field by name.
+func setKeyField(f *KeyFields, name, value string) {
+	switch name {
+	case "Year":
+		f.Year = value
+	case "Month":
+		f.Month = value
+	case "Day":
+		f.Day = value
+	case "Hour":
+		f.Hour = value
+	case "Prefix":
+		f.Prefix = value
+	case "Timestamp":
+		f.Timestamp = value
+	case "PGVersion":
+		f.PGVersion = value
+	case "Ext":
+		f.Ext = value
+	case "Filename":
+		f.Filename = value
+	}
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyTemplate_Render(t *testing.T) {
+	timestamp := time.Date(2024, 3, 7, 9, 30, 15, 250000000, time.UTC)
+	fields := NewKeyFields("mydb", timestamp, "PostgreSQL 16.2", ".tar.zst")
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "default",
+			template: DefaultKeyTemplate,
+			want:     "2024/03/mydb-pg16-2024-03-07T09-30-15-250Z.tar.zst",
+		},
+		{
+			name:     "daily directories",
+			template: "{{.Year}}/{{.Month}}/{{.Day}}/{{.Prefix}}-{{.Timestamp}}.tar.gz",
+			want:     "2024/03/07/mydb-2024-03-07T09-30-15-250Z.tar.gz",
+		},
+		{
+			name:     "version directory",
+			template: "pg{{.PGVersion}}/{{.Prefix}}/{{.Timestamp}}{{.Ext}}",
+			want:     "pg16/mydb/2024-03-07T09-30-15-250Z.tar.zst",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kt, err := NewKeyTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("NewKeyTemplate() error = %v", err)
+			}
+			got, err := kt.Render(fields)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+
+			// Every rendered key must parse back to the backup time
+			parsed, err := kt.ParseTime(got)
+			if err != nil {
+				t.Fatalf("ParseTime(%q) error = %v", got, err)
+			}
+			if !parsed.Equal(timestamp) {
+				t.Errorf("ParseTime(%q) = %v, want %v", got, parsed, timestamp)
+			}
+		})
+	}
+}
+
+func TestNewKeyTemplate_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"syntax error", "{{.Year}/{{.Filename}}"},
+		{"unknown field", "{{.Year}}/{{.Minute}}/{{.Filename}}"},
+		{"no timestamp", "{{.Year}}/{{.Month}}/{{.Day}}/backup.tar.gz"},
+		{"directory only", "{{.Timestamp}}/"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyTemplate(tt.template); err == nil {
+				t.Errorf("NewKeyTemplate(%q) expected error", tt.template)
+			}
+		})
+	}
+}
+
+func TestKeyTemplate_ParseTime(t *testing.T) {
+	kt, err := NewKeyTemplate("{{.Year}}/{{.Month}}/{{.Day}}/{{.Prefix}}-{{.Timestamp}}.tar.gz")
+	if err != nil {
+		t.Fatalf("NewKeyTemplate() error = %v", err)
+	}
+
+	tests := []struct {
+		key     string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			key:  "2024/01/15/backup-2024-01-15T10-30-45-123Z.tar.gz",
+			want: time.Date(2024, 1, 15, 10, 30, 45, 123000000, time.UTC),
+		},
+		{
+			// Prefixes may contain dashes
+			key:  "2024/01/15/my-app-db-2024-01-15T10-30-45-000Z.tar.gz",
+			want: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+		},
+		{key: "2024/01/backup-pg16-2024-01-15T10-30-45-000Z.tar.gz", wantErr: true},
+		{key: "2024/01/15/backup-2024-01-15T10-30-45-000Z.tar.zst", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := kt.ParseTime(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimSidecarSuffix(t *testing.T) {
+	key := "2024/01/backup-pg16-2024-01-15T10-30-45-000Z.tar.gz"
+	if got := TrimSidecarSuffix(key + SettingsSuffix); got != key {
+		t.Errorf("TrimSidecarSuffix() = %q, want %q", got, key)
+	}
+	if got := TrimSidecarSuffix(key); got != key {
+		t.Errorf("TrimSidecarSuffix() = %q, want %q", got, key)
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SamplingHandler thins out retry logs during long waits, such as a database
+// cold boot. Records below error level with an "attempt" attribute are passed
+// on for the first attempt and every Nth one only (1st, 5th, 10th... for 5);
+// all other records are passed on unchanged.
+type SamplingHandler struct {
+	handler slog.Handler
+	every   int64
+}
+
+// NewSamplingHandler wraps handler, keeping every Nth retry attempt's logs.
+func NewSamplingHandler(handler slog.Handler, every int) *SamplingHandler {
+	return &SamplingHandler{handler: handler, every: int64(max(every, 1))}
+}
+
+// Enabled implements slog.Handler.
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError && !h.sampled(r) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// sampled reports whether r is not a retry log or falls on a kept attempt.
+func (h *SamplingHandler) sampled(r slog.Record) bool {
+	keep := true
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != "attempt" {
+			return true
+		}
+		if v := a.Value.Resolve(); v.Kind() == slog.KindInt64 {
+			n := v.Int64()
+			keep = n <= 1 || n%h.every == 0
+		}
+		return false
+	})
+	return keep
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{handler: h.handler.WithAttrs(attrs), every: h.every}
+}
+
+// WithGroup implements slog.Handler.
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{handler: h.handler.WithGroup(name), every: h.every}
+}
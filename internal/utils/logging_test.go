@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewSamplingHandler(slog.NewTextHandler(&buf, nil), 5)).With("component", "pool")
+
+	for attempt := 1; attempt <= 12; attempt++ {
+		logger.Warn("Retrying database connection", "attempt", attempt)
+	}
+	logger.Error("Failed to connect to database", "attempt", 7)
+	logger.Info("Connected", "attempts", 12)
+
+	var kept []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		_, attr, _ := strings.Cut(line, "attempt")
+		kept = append(kept, strings.Fields(attr)[0])
+	}
+	// The 1st, 5th and 10th retries; errors and other records are never dropped
+	want := []string{"=1", "=5", "=10", "=7", "s=12"}
+	if strings.Join(kept, " ") != strings.Join(want, " ") {
+		t.Errorf("logged attempts %v, want %v\n%s", kept, want, buf.String())
+	}
+}
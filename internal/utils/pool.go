@@ -145,7 +145,7 @@ func NewConnectionPoolWithRetry(ctx context.Context, databaseURL string, retryCo
 		}
 
 		// Record the error for this attempt
-		attemptErrors = append(attemptErrors, fmt.Sprintf("attempt %d: %v", attempt+1, err))
+		attemptErrors = append(attemptErrors, RedactCredentials(fmt.Sprintf("attempt %d: %v", attempt+1, err)))
 
 		// Check if this is a cold boot error
 		if isColdBootError(err) {
@@ -248,6 +248,70 @@ func (p *ConnectionPool) GetDatabaseInfo() (*DatabaseInfo, error) {
 	return info, nil
 }
 
+// BlockingSession describes a session holding a lock that can block pg_dump.
+type BlockingSession struct {
+	PID             int
+	User            string
+	ApplicationName string
+	State           string
+	Query           string
+	LockMode        string
+	Relation        string
+	TransactionAge  time.Duration
+}
+
+// GetBlockingSessions returns sessions blocking pg_dump, plus any session holding
+// an ACCESS EXCLUSIVE relation lock in the current database.
+func (p *ConnectionPool) GetBlockingSessions(ctx context.Context) ([]BlockingSession, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT DISTINCT a.pid,
+			coalesce(a.usename, ''),
+			coalesce(a.application_name, ''),
+			coalesce(a.state, ''),
+			left(coalesce(a.query, ''), 200),
+			l.mode,
+			coalesce(l.relation::regclass::text, ''),
+			coalesce(extract(epoch FROM now() - a.xact_start), 0)::bigint
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.granted
+			AND a.pid <> pg_backend_pid()
+			AND (
+				a.pid IN (
+					SELECT unnest(pg_blocking_pids(w.pid))
+					FROM pg_stat_activity w
+					WHERE w.application_name = 'pg_dump'
+				)
+				OR (
+					l.locktype = 'relation'
+					AND l.mode = 'AccessExclusiveLock'
+					AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+				)
+			)
+		ORDER BY a.pid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocking sessions: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var sessions []BlockingSession
+	for rows.Next() {
+		var s BlockingSession
+		var ageSeconds int64
+		if err := rows.Scan(&s.PID, &s.User, &s.ApplicationName, &s.State, &s.Query,
+			&s.LockMode, &s.Relation, &ageSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan blocking session: %w", err)
+		}
+		s.TransactionAge = time.Duration(ageSeconds) * time.Second
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
 // Close closes the connection pool.
 func (p *ConnectionPool) Close() error {
 	return p.db.Close()
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes accepted by ProcessLimits.
+const (
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// ProcessLimits lowers the scheduling priority of a child process so it does not
+// starve co-located workloads.
+type ProcessLimits struct {
+	Nice    int    // CPU niceness from 0 (unchanged) to 19 (lowest priority)
+	IOClass string // I/O scheduling class: "" (unchanged), "best-effort" or "idle"
+	IOLevel int    // Priority within the best-effort class, 0 (highest) to 7
+}
+
+// IsZero reports whether the limits leave the process unchanged.
+func (l ProcessLimits) IsZero() bool {
+	return l.Nice == 0 && l.IOClass == ""
+}
+
+// Apply sets the limits on a running process. Processes it forks afterwards,
+// such as parallel pg_restore workers, inherit them.
+func (l ProcessLimits) Apply(pid int) error {
+	if l.Nice != 0 {
+		if err := setNice(pid, l.Nice); err != nil {
+			return err
+		}
+	}
+	if l.IOClass != "" {
+		return setIOPriority(pid, l.IOClass, l.IOLevel)
+	}
+	return nil
+}
+
+// cgroupRoot is where the container's cgroup filesystem is mounted.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// CgroupCPULimit returns the number of CPUs the container's cgroup allows,
+// rounded up. It reports false when there is no CPU quota.
+func CgroupCPULimit() (int, bool) {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile(cgroupRoot + "/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			return cpuQuota(fields[0], fields[1])
+		}
+		return 0, false
+	}
+
+	// cgroup v1: quota of -1 means unlimited
+	quota, err := os.ReadFile(cgroupRoot + "/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(cgroupRoot + "/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuQuota converts a CFS quota and period into a whole number of CPUs.
+func cpuQuota(quota, period string) (int, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return int(math.Ceil(q / p)), true
+}
@@ -0,0 +1,46 @@
+//go:build linux
+
+package utils
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// ioprio_set(2) constants.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+	ioprioMaxBELevel = 7
+)
+
+// setNice sets the CPU niceness of a process.
+func setNice(pid, nice int) error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice); err != nil {
+		return fmt.Errorf("failed to set niceness %d: %w", nice, err)
+	}
+	return nil
+}
+
+// setIOPriority sets the I/O scheduling class of a process, as ionice does.
+func setIOPriority(pid int, class string, level int) error {
+	var prio int
+	switch class {
+	case IOClassBestEffort:
+		if level < 0 || level > ioprioMaxBELevel {
+			return fmt.Errorf("invalid best-effort I/O priority level %d", level)
+		}
+		prio = ioprioClassBE<<ioprioClassShift | level
+	case IOClassIdle:
+		prio = ioprioClassIdle << ioprioClassShift
+	default:
+		return fmt.Errorf("invalid I/O class %q", class)
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+		return fmt.Errorf("failed to set I/O class %s: %w", class, errno)
+	}
+	return nil
+}
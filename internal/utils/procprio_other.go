@@ -0,0 +1,18 @@
+//go:build !linux
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// setNice is only supported on Linux.
+func setNice(pid, nice int) error {
+	return fmt.Errorf("process niceness is not supported on %s", runtime.GOOS)
+}
+
+// setIOPriority is only supported on Linux.
+func setIOPriority(pid int, class string, level int) error {
+	return fmt.Errorf("I/O scheduling classes are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCgroupCPULimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		want   int
+		wantOK bool
+	}{
+		{
+			name:   "cgroup v2 quota",
+			files:  map[string]string{"cpu.max": "150000 100000\n"},
+			want:   2,
+			wantOK: true,
+		},
+		{
+			name:  "cgroup v2 unlimited",
+			files: map[string]string{"cpu.max": "max 100000\n"},
+		},
+		{
+			name: "cgroup v1 quota",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":  "400000\n",
+				"cpu/cpu.cfs_period_us": "100000\n",
+			},
+			want:   4,
+			wantOK: true,
+		},
+		{
+			name: "cgroup v1 unlimited",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":  "-1\n",
+				"cpu/cpu.cfs_period_us": "100000\n",
+			},
+		},
+		{
+			name: "no cgroup",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			old := cgroupRoot
+			cgroupRoot = root
+			defer func() { cgroupRoot = old }()
+
+			got, ok := CgroupCPULimit()
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("CgroupCPULimit() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestProcessLimits_IsZero(t *testing.T) {
+	if !(ProcessLimits{IOLevel: 4}).IsZero() {
+		t.Error("IsZero() = false for limits without niceness or I/O class")
+	}
+	if (ProcessLimits{Nice: 10}).IsZero() {
+		t.Error("IsZero() = true with niceness set")
+	}
+	if (ProcessLimits{IOClass: IOClassIdle}).IsZero() {
+		t.Error("IsZero() = true with an I/O class set")
+	}
+}
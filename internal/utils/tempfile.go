@@ -0,0 +1,79 @@
+// Package utils provides utility functions for the backup service.
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrInsufficientDiskSpace is returned when a spool location cannot hold the estimated data.
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// TempDir returns the directory used for spooling backup data to disk.
+// Precedence: the explicit override (BACKUP_TMPDIR), then TMPDIR via os.TempDir.
+func TempDir(override string) string {
+	if override != "" {
+		return override
+	}
+	return os.TempDir()
+}
+
+// CheckDiskSpace verifies that dir has at least required bytes available.
+// A non-positive required size skips the check since the size is unknown.
+func CheckDiskSpace(dir string, required int64) error {
+	if required <= 0 {
+		return nil
+	}
+
+	available, err := AvailableDiskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check disk space in %s: %w", dir, err)
+	}
+
+	if available < required {
+		return fmt.Errorf("%w in %s: need %s, have %s",
+			ErrInsufficientDiskSpace, dir, FormatBytes(required), FormatBytes(available))
+	}
+
+	return nil
+}
+
+// SpoolFile is a temporary file used to stage backup data on disk.
+// Cleanup is idempotent and safe to defer on every code path.
+type SpoolFile struct {
+	*os.File
+	once sync.Once
+}
+
+// NewSpoolFile creates a temporary spool file in dir after checking that the
+// estimated size fits in the available disk space.
+func NewSpoolFile(dir, pattern string, estimatedSize int64) (*SpoolFile, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	if err := CheckDiskSpace(dir, estimatedSize); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+
+	return &SpoolFile{File: f}, nil
+}
+
+// Cleanup closes and removes the spool file.
+func (s *SpoolFile) Cleanup() error {
+	var err error
+	s.once.Do(func() {
+		_ = s.File.Close()
+		if rmErr := os.Remove(s.File.Name()); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = fmt.Errorf("failed to remove spool file: %w", rmErr)
+		}
+	})
+	return err
+}
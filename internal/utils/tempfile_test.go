@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	if got := TempDir("/data/tmp"); got != "/data/tmp" {
+		t.Errorf("TempDir() with override = %v, want /data/tmp", got)
+	}
+
+	if got := TempDir(""); got != os.TempDir() {
+		t.Errorf("TempDir() without override = %v, want %v", got, os.TempDir())
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := CheckDiskSpace(dir, 0); err != nil {
+		t.Errorf("CheckDiskSpace() with unknown size error = %v", err)
+	}
+
+	if err := CheckDiskSpace(dir, 1); err != nil {
+		t.Errorf("CheckDiskSpace() with 1 byte error = %v", err)
+	}
+
+	err := CheckDiskSpace(dir, math.MaxInt64)
+	if !errors.Is(err, ErrInsufficientDiskSpace) {
+		// Platforms without statfs report unlimited space
+		if available, _ := AvailableDiskSpace(dir); available != math.MaxInt64 {
+			t.Errorf("CheckDiskSpace() error = %v, want ErrInsufficientDiskSpace", err)
+		}
+	}
+}
+
+func TestSpoolFile_Cleanup(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := NewSpoolFile(dir, "spool-*", 1024)
+	if err != nil {
+		t.Fatalf("NewSpoolFile() error = %v", err)
+	}
+
+	if _, err := spool.WriteString("backup data"); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+
+	name := spool.Name()
+	if err := spool.Cleanup(); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("spool file still exists after Cleanup()")
+	}
+
+	// Cleanup must be safe to call again from deferred paths
+	if err := spool.Cleanup(); err != nil {
+		t.Errorf("second Cleanup() error = %v", err)
+	}
+}
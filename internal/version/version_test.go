@@ -0,0 +1,43 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, BuildDate = v, c, d }(Version, Commit, BuildDate)
+
+	Version, Commit, BuildDate = "1.2.3", "0123456789abcdef0123", "2025-01-10T03:00:00Z"
+	info := Get()
+	if info.Version != "1.2.3" || info.Commit != Commit || info.BuildDate != BuildDate || info.GoVersion == "" {
+		t.Errorf("Get() = %+v, want the values set at build time", info)
+	}
+	if got := info.String(); !strings.Contains(got, "1.2.3 (commit 0123456789ab, built 2025-01-10T03:00:00Z") {
+		t.Errorf("String() = %q", got)
+	}
+
+	// Test binaries carry no VCS information, so unset values fall back to placeholders
+	Version, Commit, BuildDate = "", "", ""
+	if info := Get(); info.Version != "dev" || info.Commit == "" || info.BuildDate == "" {
+		t.Errorf("Get() = %+v, want placeholders for unset values", info)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler()(rec, httptest.NewRequest("GET", "/version", nil))
+
+	var info Info
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info != Get() {
+		t.Errorf("/version = %+v, want %+v", info, Get())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}